@@ -32,6 +32,30 @@ func (diags Diagnostics) HasError() bool {
 	return false
 }
 
+// Errors returns the diagnostics with Severity == Error, in their original
+// order.
+func (diags Diagnostics) Errors() Diagnostics {
+	return diags.withSeverity(Error)
+}
+
+// Warnings returns the diagnostics with Severity == Warning, in their
+// original order.
+func (diags Diagnostics) Warnings() Diagnostics {
+	return diags.withSeverity(Warning)
+}
+
+func (diags Diagnostics) withSeverity(severity Severity) Diagnostics {
+	var result Diagnostics
+
+	for i := range diags {
+		if diags[i].Severity == severity {
+			result = append(result, diags[i])
+		}
+	}
+
+	return result
+}
+
 // Diagnostic is a contextual message intended at outlining problems in user
 // configuration.
 //
@@ -96,6 +120,15 @@ func (d Diagnostic) Validate() error {
 	return nil
 }
 
+// WithPath returns a copy of the Diagnostic with the AttributePath populated
+// by the supplied path, such as a path used with ResourceData.GetRawConfigAt.
+// It is the method form of the package level WithPath function.
+//
+//	diags = append(diags, d.WithPath(cty.GetAttrPath("name")))
+func (d Diagnostic) WithPath(path cty.Path) Diagnostic {
+	return WithPath(path, d)
+}
+
 // Severity is an enum type marking the severity level of a Diagnostic
 type Severity int
 
@@ -0,0 +1,148 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package diag
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/go-cty/cty"
+)
+
+var pathComparer = cmp.Comparer(func(a, b cty.Path) bool { return a.Equals(b) })
+
+func TestDiagnosticsSeverityFilters(t *testing.T) {
+	t.Parallel()
+
+	diags := Diagnostics{
+		{
+			Severity:      Warning,
+			Summary:       "warning 1",
+			AttributePath: cty.GetAttrPath("first"),
+		},
+		{
+			Severity:      Error,
+			Summary:       "error 1",
+			AttributePath: cty.GetAttrPath("list").IndexInt(0).GetAttr("attr"),
+		},
+		{
+			Severity: Warning,
+			Summary:  "warning 2",
+		},
+		{
+			Severity:      Error,
+			Summary:       "error 2",
+			AttributePath: cty.GetAttrPath("map").IndexString("key"),
+		},
+	}
+
+	tests := map[string]struct {
+		diags Diagnostics
+		want  Diagnostics
+	}{
+		"Errors": {
+			diags: diags.Errors(),
+			want: Diagnostics{
+				{
+					Severity:      Error,
+					Summary:       "error 1",
+					AttributePath: cty.GetAttrPath("list").IndexInt(0).GetAttr("attr"),
+				},
+				{
+					Severity:      Error,
+					Summary:       "error 2",
+					AttributePath: cty.GetAttrPath("map").IndexString("key"),
+				},
+			},
+		},
+		"Warnings": {
+			diags: diags.Warnings(),
+			want: Diagnostics{
+				{
+					Severity:      Warning,
+					Summary:       "warning 1",
+					AttributePath: cty.GetAttrPath("first"),
+				},
+				{
+					Severity: Warning,
+					Summary:  "warning 2",
+				},
+			},
+		},
+		"Errors without errors": {
+			diags: diags.Warnings().Errors(),
+			want:  nil,
+		},
+		"Warnings without warnings": {
+			diags: diags.Errors().Warnings(),
+			want:  nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if diff := cmp.Diff(tc.want, tc.diags, pathComparer); diff != "" {
+				t.Fatalf("unexpected diagnostics difference: %s", diff)
+			}
+		})
+	}
+
+	if !diags.Errors().HasError() {
+		t.Error("expected filtered errors to have an error")
+	}
+
+	if diags.Warnings().HasError() {
+		t.Error("expected filtered warnings to have no error")
+	}
+}
+
+func TestDiagnosticWithPath(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		diag Diagnostic
+		path cty.Path
+		want Diagnostic
+	}{
+		"number index and map key": {
+			diag: Diagnostic{
+				Severity: Error,
+				Summary:  "error",
+			},
+			path: cty.GetAttrPath("list").Index(cty.NumberIntVal(2)).GetAttr("map").Index(cty.StringVal("key")),
+			want: Diagnostic{
+				Severity:      Error,
+				Summary:       "error",
+				AttributePath: cty.GetAttrPath("list").IndexInt(2).GetAttr("map").IndexString("key"),
+			},
+		},
+		"replaces existing path": {
+			diag: Diagnostic{
+				Severity:      Warning,
+				Summary:       "warning",
+				AttributePath: cty.GetAttrPath("old"),
+			},
+			path: cty.GetAttrPath("new"),
+			want: Diagnostic{
+				Severity:      Warning,
+				Summary:       "warning",
+				AttributePath: cty.GetAttrPath("new"),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := tc.diag.WithPath(tc.path)
+
+			if diff := cmp.Diff(tc.want, got, pathComparer); diff != "" {
+				t.Fatalf("unexpected diagnostic difference: %s", diff)
+			}
+		})
+	}
+}
@@ -3,7 +3,11 @@
 
 package diag
 
-import "fmt"
+import (
+	"fmt"
+
+	"github.com/hashicorp/go-cty/cty"
+)
 
 // FromErr will convert an error into a Diagnostics. This returns Diagnostics
 // as the most common use case in Go will be handling a single error
@@ -40,3 +44,94 @@ func Errorf(format string, a ...interface{}) Diagnostics {
 		},
 	}
 }
+
+// WithPath returns a copy of the given Diagnostic with the AttributePath
+// populated by the supplied path. The path is copied so that later changes
+// to the given path do not affect the returned Diagnostic.
+//
+//	return diag.Diagnostics{
+//	  diag.WithPath(cty.GetAttrPath("name"), d),
+//	}
+func WithPath(path cty.Path, d Diagnostic) Diagnostic {
+	d.AttributePath = path.Copy()
+
+	return d
+}
+
+// FromErrWithPath will convert an error into a Diagnostics with the
+// AttributePath populated by the supplied path. This returns nil if the
+// error is nil.
+//
+//	if err != nil {
+//	  return diag.FromErrWithPath(cty.GetAttrPath("name"), err)
+//	}
+func FromErrWithPath(path cty.Path, err error) Diagnostics {
+	if err == nil {
+		return nil
+	}
+
+	return Diagnostics{
+		WithPath(path, Diagnostic{
+			Severity: Error,
+			Summary:  err.Error(),
+		}),
+	}
+}
+
+// FromErrPath is equivalent to FromErrWithPath, with the arguments in the
+// same order as FromErr followed by the path, which is convenient when
+// wrapping an existing FromErr call.
+//
+//	if err != nil {
+//	  return diag.FromErrPath(err, cty.GetAttrPath("block").IndexInt(0).GetAttr("name"))
+//	}
+func FromErrPath(err error, path cty.Path) Diagnostics {
+	return FromErrWithPath(path, err)
+}
+
+// Deduplicate returns the diagnostics without any duplicates, preserving the
+// order of the first occurrence of each diagnostic. Diagnostics are duplicates
+// if their Severity, Summary, Detail and AttributePath are all equal. This
+// returns nil if there are no diagnostics.
+//
+//	return diag.Deduplicate(diags)
+func Deduplicate(diags Diagnostics) Diagnostics {
+	if len(diags) == 0 {
+		return nil
+	}
+
+	type diagnosticKey struct {
+		severity Severity
+		summary  string
+		detail   string
+	}
+
+	seen := make(map[diagnosticKey][]cty.Path, len(diags))
+	result := make(Diagnostics, 0, len(diags))
+
+	for _, d := range diags {
+		key := diagnosticKey{
+			severity: d.Severity,
+			summary:  d.Summary,
+			detail:   d.Detail,
+		}
+
+		duplicate := false
+
+		for _, path := range seen[key] {
+			if path.Equals(d.AttributePath) {
+				duplicate = true
+				break
+			}
+		}
+
+		if duplicate {
+			continue
+		}
+
+		seen[key] = append(seen[key], d.AttributePath)
+		result = append(result, d)
+	}
+
+	return result
+}
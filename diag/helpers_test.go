@@ -0,0 +1,216 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package diag
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/go-cty/cty"
+)
+
+func TestFromErrWithPath(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		path cty.Path
+		err  error
+		want Diagnostics
+	}{
+		"nil error": {
+			path: cty.GetAttrPath("attr"),
+			err:  nil,
+			want: nil,
+		},
+		"nested block attribute": {
+			path: cty.GetAttrPath("block").IndexInt(0).GetAttr("nested").IndexString("key").GetAttr("attr"),
+			err:  errors.New("error"),
+			want: Diagnostics{
+				{
+					Severity:      Error,
+					Summary:       "error",
+					AttributePath: cty.GetAttrPath("block").IndexInt(0).GetAttr("nested").IndexString("key").GetAttr("attr"),
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := FromErrWithPath(tc.path, tc.err)
+
+			if diff := cmp.Diff(tc.want, got, pathComparer); diff != "" {
+				t.Fatalf("unexpected diagnostics difference: %s", diff)
+			}
+		})
+	}
+}
+
+func TestFromErrPath(t *testing.T) {
+	t.Parallel()
+
+	path := cty.GetAttrPath("block").IndexInt(0).GetAttr("name")
+	err := errors.New("error")
+
+	if diff := cmp.Diff(FromErrWithPath(path, err), FromErrPath(err, path), pathComparer); diff != "" {
+		t.Fatalf("expected FromErrPath to match FromErrWithPath: %s", diff)
+	}
+
+	if got := FromErrPath(nil, path); got != nil {
+		t.Fatalf("expected nil diagnostics for nil error, got: %v", got)
+	}
+}
+
+func TestDeduplicate(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		diags Diagnostics
+		want  Diagnostics
+	}{
+		"nil": {
+			diags: nil,
+			want:  nil,
+		},
+		"empty": {
+			diags: Diagnostics{},
+			want:  nil,
+		},
+		"duplicates": {
+			diags: Diagnostics{
+				{
+					Severity:      Warning,
+					Summary:       "deprecated",
+					AttributePath: cty.GetAttrPath("list").IndexInt(0),
+				},
+				{
+					Severity: Error,
+					Summary:  "error",
+				},
+				{
+					Severity:      Warning,
+					Summary:       "deprecated",
+					AttributePath: cty.GetAttrPath("list").IndexInt(0),
+				},
+				{
+					Severity: Error,
+					Summary:  "error",
+				},
+			},
+			want: Diagnostics{
+				{
+					Severity:      Warning,
+					Summary:       "deprecated",
+					AttributePath: cty.GetAttrPath("list").IndexInt(0),
+				},
+				{
+					Severity: Error,
+					Summary:  "error",
+				},
+			},
+		},
+		"different severities": {
+			diags: Diagnostics{
+				{
+					Severity: Warning,
+					Summary:  "summary",
+				},
+				{
+					Severity: Error,
+					Summary:  "summary",
+				},
+			},
+			want: Diagnostics{
+				{
+					Severity: Warning,
+					Summary:  "summary",
+				},
+				{
+					Severity: Error,
+					Summary:  "summary",
+				},
+			},
+		},
+		"different details": {
+			diags: Diagnostics{
+				{
+					Severity: Error,
+					Summary:  "summary",
+					Detail:   "detail 1",
+				},
+				{
+					Severity: Error,
+					Summary:  "summary",
+					Detail:   "detail 2",
+				},
+				{
+					Severity: Error,
+					Summary:  "summary",
+					Detail:   "detail 1",
+				},
+			},
+			want: Diagnostics{
+				{
+					Severity: Error,
+					Summary:  "summary",
+					Detail:   "detail 1",
+				},
+				{
+					Severity: Error,
+					Summary:  "summary",
+					Detail:   "detail 2",
+				},
+			},
+		},
+		"different paths": {
+			diags: Diagnostics{
+				{
+					Severity:      Error,
+					Summary:       "summary",
+					AttributePath: cty.GetAttrPath("list").IndexInt(0),
+				},
+				{
+					Severity:      Error,
+					Summary:       "summary",
+					AttributePath: cty.GetAttrPath("list").IndexInt(1),
+				},
+				{
+					Severity: Error,
+					Summary:  "summary",
+				},
+			},
+			want: Diagnostics{
+				{
+					Severity:      Error,
+					Summary:       "summary",
+					AttributePath: cty.GetAttrPath("list").IndexInt(0),
+				},
+				{
+					Severity:      Error,
+					Summary:       "summary",
+					AttributePath: cty.GetAttrPath("list").IndexInt(1),
+				},
+				{
+					Severity: Error,
+					Summary:  "summary",
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := Deduplicate(tc.diags)
+
+			if diff := cmp.Diff(tc.want, got, pathComparer); diff != "" {
+				t.Fatalf("unexpected diagnostics difference: %s", diff)
+			}
+		})
+	}
+}
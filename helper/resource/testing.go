@@ -290,6 +290,11 @@ type TestCheckFunc func(*terraform.State) error
 // ImportStateCheckFunc is the check function for ImportState tests
 type ImportStateCheckFunc func([]*terraform.InstanceState) error
 
+// ImportStateVerifyIgnoreFunc is the function used to ignore attributes
+// during ImportStateVerify. It receives the flatmapped attribute key and value
+// and returns true if the attribute should not be verified.
+type ImportStateVerifyIgnoreFunc func(k, v string) bool
+
 // ImportStateIdFunc is an ID generation function to help with complex ID
 // generation for ImportState tests.
 type ImportStateIdFunc func(*terraform.State) (string, error)
@@ -576,6 +581,15 @@ type TestStep struct {
 	ImportStateVerify       bool
 	ImportStateVerifyIgnore []string
 
+	// ImportStateVerifyIgnoreFunc, if set, is called with each flatmapped
+	// attribute key and value during ImportStateVerify, such as
+	// "tags.Name" and its value, or "tags.%" and the number of map elements.
+	// If it returns true for the attribute in either the imported or the
+	// original state, the attribute is not verified to be equal. This is an
+	// alternative to ImportStateVerifyIgnore for attributes whose names are
+	// not known in advance, such as map keys which change.
+	ImportStateVerifyIgnoreFunc ImportStateVerifyIgnoreFunc
+
 	// ImportStatePersist, if true, will update the persisted state with the
 	// state generated by the import operation (i.e., terraform import). When
 	// false (default) the state generated by the import operation is discarded
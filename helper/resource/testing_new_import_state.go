@@ -239,6 +239,24 @@ func testStepNewImportState(ctx context.Context, t testing.T, helper *plugintest
 				}
 			}
 
+			if step.ImportStateVerifyIgnoreFunc != nil {
+				ignored := make(map[string]struct{})
+				for k, v := range actual {
+					if step.ImportStateVerifyIgnoreFunc(k, v) {
+						ignored[k] = struct{}{}
+					}
+				}
+				for k, v := range expected {
+					if step.ImportStateVerifyIgnoreFunc(k, v) {
+						ignored[k] = struct{}{}
+					}
+				}
+				for k := range ignored {
+					delete(actual, k)
+					delete(expected, k)
+				}
+			}
+
 			// timeouts are only _sometimes_ added to state. To
 			// account for this, just don't compare timeouts at
 			// all.
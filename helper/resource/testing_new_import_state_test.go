@@ -7,6 +7,7 @@ import (
 	"context"
 	"fmt"
 	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
@@ -198,6 +199,73 @@ func TestTest_TestStep_ImportStateVerifyIgnore(t *testing.T) {
 	})
 }
 
+func TestTest_TestStep_ImportStateVerifyIgnoreFunc(t *testing.T) {
+	t.Parallel()
+
+	UnitTest(t, TestCase{
+		ProviderFactories: map[string]func() (*schema.Provider, error){
+			"examplecloud": func() (*schema.Provider, error) { //nolint:unparam // required signature
+				return &schema.Provider{
+					ResourcesMap: map[string]*schema.Resource{
+						"examplecloud_thing": {
+							CreateContext: func(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+								d.SetId("resource-test")
+
+								_ = d.Set("labels", map[string]interface{}{
+									"created-by": "testvalue",
+								})
+
+								return nil
+							},
+							DeleteContext: func(_ context.Context, _ *schema.ResourceData, _ interface{}) diag.Diagnostics {
+								return nil
+							},
+							ReadContext: func(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+								_ = d.Set("read_only", "testvalue")
+
+								return nil
+							},
+							Schema: map[string]*schema.Schema{
+								"labels": {
+									Computed: true,
+									Type:     schema.TypeMap,
+									Elem: &schema.Schema{
+										Type: schema.TypeString,
+									},
+								},
+								"read_only": {
+									Computed: true,
+									Type:     schema.TypeString,
+								},
+								"id": {
+									Computed: true,
+									Type:     schema.TypeString,
+								},
+							},
+							Importer: &schema.ResourceImporter{
+								StateContext: schema.ImportStatePassthroughContext,
+							},
+						},
+					},
+				}, nil
+			},
+		},
+		Steps: []TestStep{
+			{
+				Config: `resource "examplecloud_thing" "test" {}`,
+			},
+			{
+				ResourceName:      "examplecloud_thing.test",
+				ImportState:       true,
+				ImportStateVerify: true,
+				ImportStateVerifyIgnoreFunc: func(k, v string) bool {
+					return k == "labels.%" || strings.HasPrefix(k, "labels.created-")
+				},
+			},
+		},
+	})
+}
+
 func TestTest_TestStep_ExpectError_ImportState(t *testing.T) {
 	t.Parallel()
 
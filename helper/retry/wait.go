@@ -6,6 +6,7 @@ package retry
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -14,7 +15,9 @@ import (
 // a function until it no longer returns an error.
 //
 // Cancellation from the passed in context will propagate through to the
-// underlying StateChangeConf
+// underlying StateChangeConf. If the context is cancelled or its deadline is
+// exceeded before the function succeeds, the returned error wraps the context
+// error, along with the last retryable error if there was one.
 func RetryContext(ctx context.Context, timeout time.Duration, f RetryFunc) error {
 	// These are used to pull the error out of the function; need a mutex to
 	// avoid a data race.
@@ -58,6 +61,11 @@ func RetryContext(ctx context.Context, timeout time.Duration, f RetryFunc) error
 	if resultErr == nil {
 		return waitErr
 	}
+	// the last retryable error would otherwise hide that the context ended
+	// the wait
+	if ctxErr := ctx.Err(); ctxErr != nil && errors.Is(waitErr, ctxErr) {
+		return fmt.Errorf("%w, last error: %w", ctxErr, resultErr)
+	}
 	// resultErr takes precedence over waitErr if both are set because it is
 	// more likely to be useful
 	return resultErr
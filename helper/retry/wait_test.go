@@ -5,6 +5,7 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"testing"
 	"time"
@@ -160,3 +161,46 @@ func TestRetryContext_deadline(t *testing.T) {
 		t.Fatalf("Expected context.DeadlineExceeded error, got: %s", err)
 	}
 }
+
+func TestRetryContext_cancelAfterRetryableError(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	retryableErr := errors.New("still creating")
+
+	f := func() *RetryError {
+		cancel()
+		return RetryableError(retryableErr)
+	}
+
+	err := RetryContext(ctx, 10*time.Second, f)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled error, got: %s", err)
+	}
+	if !errors.Is(err, retryableErr) {
+		t.Fatalf("Expected error to wrap the last retryable error, got: %s", err)
+	}
+}
+
+func TestRetryContext_deadlineAfterRetryableError(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	retryableErr := errors.New("still creating")
+
+	f := func() *RetryError {
+		return RetryableError(retryableErr)
+	}
+
+	err := RetryContext(ctx, 10*time.Second, f)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded error, got: %s", err)
+	}
+	if !errors.Is(err, retryableErr) {
+		t.Fatalf("Expected error to wrap the last retryable error, got: %s", err)
+	}
+}
@@ -3,8 +3,73 @@
 
 package schema
 
+import "context"
+
 type Key string
 
 var (
 	StopContextKey = Key("StopContext")
+
+	rawRequestContextKey = Key("RawRequest")
+
+	clientCapabilitiesContextKey = Key("ClientCapabilities")
+
+	terraformVersionContextKey = Key("TerraformVersion")
+
+	deferredContextKey = Key("Deferred")
+
+	rateLimiterContextKey = Key("RateLimiter")
 )
+
+// RawRequestFromContext returns the terraform-plugin-go protocol request which
+// originated the callback receiving the context, such as a
+// *tfprotov5.ReadResourceRequest in a ReadContext function. It returns false
+// unless the provider has enabled Provider.ExposeRawRequest.
+//
+// This is an escape hatch for advanced use cases, such as proxying requests to
+// another provider, which cannot be implemented with ResourceData. The request
+// types are defined by the terraform-plugin-go module and this function is
+// not protected by version compatibility guarantees. It may change or be
+// removed without warning.
+func RawRequestFromContext(ctx context.Context) (interface{}, bool) {
+	req := ctx.Value(rawRequestContextKey)
+
+	return req, req != nil
+}
+
+// ClientCapabilities describes optional protocol features supported by the
+// Terraform client which sent the request that originated a callback.
+type ClientCapabilities struct {
+	// DeferralAllowed indicates that the Terraform client supports deferred
+	// responses. It is sent with requests which configure the provider, read,
+	// plan, or import resources, read data sources, and open ephemeral
+	// resources.
+	DeferralAllowed bool
+
+	// WriteOnlyAttributesAllowed indicates that the Terraform client supports
+	// write-only attributes. It is sent with requests which validate managed
+	// resource configuration.
+	WriteOnlyAttributesAllowed bool
+}
+
+// ClientCapabilitiesFromContext returns the client capabilities of the request
+// which originated the callback receiving the context, such as a ReadContext
+// or CustomizeDiff function. Capabilities which were not sent with the
+// request are false.
+func ClientCapabilitiesFromContext(ctx context.Context) ClientCapabilities {
+	caps, _ := ctx.Value(clientCapabilitiesContextKey).(ClientCapabilities)
+
+	return caps
+}
+
+// TerraformVersionFromContext returns the version of Terraform which
+// configured the provider, such as "1.11.0", in the callback receiving the
+// context, such as a ReadContext function. This is the same value as
+// ConfigureProviderRequest.TerraformVersion, without requiring it to be
+// stored in the provider meta. It is empty if the version is not known, such
+// as before the provider is configured.
+func TerraformVersionFromContext(ctx context.Context) string {
+	version, _ := ctx.Value(terraformVersionContextKey).(string)
+
+	return version
+}
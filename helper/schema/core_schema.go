@@ -80,8 +80,10 @@ func (m schemaMap) CoreConfigSchema() *configschema.Block {
 			// *Resource (since that would be ambiguous in flatmap) and
 			// so Elem is treated as a TypeString schema if so. This matches
 			// how the field readers treat this situation, for compatibility
-			// with configurations targeting Terraform 0.11 and earlier.
-			if _, isResource := schema.Elem.(*Resource); isResource {
+			// with configurations targeting Terraform 0.11 and earlier. The
+			// exception is a map of objects, which must explicitly set
+			// ConfigMode to SchemaConfigModeAttr.
+			if _, isResource := schema.Elem.(*Resource); isResource && !schema.isObjectMap() {
 				sch := *schema // shallow copy
 				sch.Elem = &Schema{
 					Type: TypeString,
@@ -151,12 +153,7 @@ func (s *Schema) coreConfigSchemaAttribute() *configschema.Attribute {
 		}
 	}
 
-	desc := SchemaDescriptionBuilder(s)
-	descKind := configschema.StringKind(DescriptionKind)
-	if desc == "" {
-		// fallback to plain text if empty
-		descKind = configschema.StringPlain
-	}
+	desc, descKind := s.coreConfigSchemaDescription()
 
 	return &configschema.Attribute{
 		Type:            s.coreConfigSchemaType(),
@@ -174,6 +171,38 @@ func (s *Schema) coreConfigSchemaAttribute() *configschema.Attribute {
 	}
 }
 
+// coreConfigSchemaDescription returns the description of the schema and its
+// kind, falling back to the MarkdownDescription if the SchemaDescriptionBuilder
+// returns an empty description.
+func (s *Schema) coreConfigSchemaDescription() (string, configschema.StringKind) {
+	desc := SchemaDescriptionBuilder(s)
+	descKind := configschema.StringKind(DescriptionKind)
+
+	if desc == "" && s.MarkdownDescription != "" {
+		desc = s.MarkdownDescription
+		descKind = configschema.StringMarkdown
+	}
+
+	if desc == "" {
+		// fallback to plain text if empty
+		descKind = configschema.StringPlain
+	}
+
+	return desc, descKind
+}
+
+// sensitiveBlock marks all attributes within the block and its nested blocks
+// as Sensitive.
+func sensitiveBlock(block *configschema.Block) {
+	for _, attr := range block.Attributes {
+		attr.Sensitive = true
+	}
+
+	for _, nested := range block.BlockTypes {
+		sensitiveBlock(&nested.Block)
+	}
+}
+
 // coreConfigSchemaBlock prepares a configschema.NestedBlock representation of
 // a schema. This is appropriate only for collections whose Elem is an instance
 // of Resource, and will panic otherwise.
@@ -182,16 +211,17 @@ func (s *Schema) coreConfigSchemaBlock() *configschema.NestedBlock {
 	if nested := s.Elem.(*Resource).coreConfigSchema(); nested != nil {
 		ret.Block = *nested
 
-		desc := SchemaDescriptionBuilder(s)
-		descKind := configschema.StringKind(DescriptionKind)
-		if desc == "" {
-			// fallback to plain text if empty
-			descKind = configschema.StringPlain
-		}
+		desc, descKind := s.coreConfigSchemaDescription()
 		// set these on the block from the attribute Schema
 		ret.Block.Description = desc
 		ret.Block.DescriptionKind = descKind
 		ret.Block.Deprecated = s.Deprecated != ""
+
+		// The protocol has no concept of sensitive blocks, so mark all of the
+		// nested attributes instead.
+		if s.Sensitive {
+			sensitiveBlock(&ret.Block)
+		}
 	}
 	switch s.Type {
 	case TypeList:
@@ -392,8 +422,72 @@ func (r *Resource) coreIdentitySchema() (*configschema.Block, error) {
 	if r.Identity.SchemaMap() == nil {
 		return nil, fmt.Errorf("resource does not have an identity schema")
 	}
+
+	return identitySchemaBlock(r.Identity.SchemaMap())
+}
+
+// identitySchemaBlock converts the given identity schema to a
+// configschema.Block.
+func identitySchemaBlock(identitySchema map[string]*Schema) (*configschema.Block, error) {
 	// while there is schemaMapWithIdentity, we don't need to use it here
 	// as we're only interested in the existing CoreConfigSchema() method
 	// to convert our schema
-	return schemaMap(r.Identity.SchemaMap()).CoreConfigSchema(), nil
+	m := schemaMap(identitySchema)
+	block := m.CoreConfigSchema()
+
+	// Identity schemas have no notion of nested blocks, so collections of
+	// nested objects are always represented as attributes whose element
+	// type is the object type implied by the nested resource.
+	for name, schema := range m {
+		if err := validateIdentityElem(name, schema); err != nil {
+			return nil, err
+		}
+
+		if _, ok := schema.Elem.(*Resource); !ok {
+			continue
+		}
+
+		delete(block.BlockTypes, name)
+		if block.Attributes == nil {
+			block.Attributes = map[string]*configschema.Attribute{}
+		}
+		block.Attributes[name] = schema.coreConfigSchemaAttribute()
+	}
+
+	return block, nil
+}
+
+// validateIdentityElem returns an error if the Elem of the given identity
+// attribute cannot be represented in a resource identity schema.
+func validateIdentityElem(name string, schema *Schema) error {
+	switch elem := schema.Elem.(type) {
+	case nil, ValueType:
+		return nil
+	case *Schema:
+		switch elem.Type {
+		case TypeString, TypeBool, TypeInt, TypeFloat:
+			return nil
+		case TypeList:
+			return validateIdentityElem(name, elem)
+		default:
+			return fmt.Errorf("identity attribute %q: element type %s is not supported", name, elem.Type)
+		}
+	case *Resource:
+		// Maps of objects are not representable in the identity protocol,
+		// and the schema conversion would otherwise silently treat them as
+		// maps of strings.
+		if schema.Type != TypeList {
+			return fmt.Errorf("identity attribute %q: nested objects are only supported in TypeList", name)
+		}
+		for k, v := range elem.SchemaMap() {
+			switch v.Type {
+			case TypeString, TypeBool, TypeInt, TypeFloat:
+			default:
+				return fmt.Errorf("identity attribute %q: nested attribute %q has unsupported type %s", name, k, v.Type)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("identity attribute %q: unsupported Elem %T; need ValueType, *Schema or *Resource", name, schema.Elem)
+	}
 }
@@ -394,6 +394,100 @@ func TestSchemaMapCoreConfigSchema(t *testing.T) {
 				BlockTypes: map[string]*configschema.NestedBlock{},
 			}),
 		},
+		"map of objects": {
+			map[string]*Schema{
+				"map": {
+					Type:       TypeMap,
+					Optional:   true,
+					ConfigMode: SchemaConfigModeAttr,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"name": {
+								Type:     TypeString,
+								Optional: true,
+							},
+							"port": {
+								Type:     TypeInt,
+								Optional: true,
+							},
+						},
+					},
+				},
+			},
+			testResource(&configschema.Block{
+				Attributes: map[string]*configschema.Attribute{
+					"map": {
+						Type: cty.Map(cty.Object(map[string]cty.Type{
+							"name": cty.String,
+							"port": cty.Number,
+						})),
+						Optional: true,
+					},
+				},
+				BlockTypes: map[string]*configschema.NestedBlock{},
+			}),
+		},
+		"sensitive block": {
+			map[string]*Schema{
+				"list": {
+					Type:      TypeList,
+					Optional:  true,
+					Sensitive: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"string": {
+								Type:     TypeString,
+								Optional: true,
+							},
+							"set": {
+								Type:     TypeSet,
+								Optional: true,
+								Elem: &Resource{
+									Schema: map[string]*Schema{
+										"int": {
+											Type:     TypeInt,
+											Optional: true,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			testResource(&configschema.Block{
+				Attributes: map[string]*configschema.Attribute{},
+				BlockTypes: map[string]*configschema.NestedBlock{
+					"list": {
+						Nesting: configschema.NestingList,
+						Block: configschema.Block{
+							Attributes: map[string]*configschema.Attribute{
+								"string": {
+									Type:      cty.String,
+									Optional:  true,
+									Sensitive: true,
+								},
+							},
+							BlockTypes: map[string]*configschema.NestedBlock{
+								"set": {
+									Nesting: configschema.NestingSet,
+									Block: configschema.Block{
+										Attributes: map[string]*configschema.Attribute{
+											"int": {
+												Type:      cty.Number,
+												Optional:  true,
+												Sensitive: true,
+											},
+										},
+										BlockTypes: map[string]*configschema.NestedBlock{},
+									},
+								},
+							},
+						},
+					},
+				},
+			}),
+		},
 		"conditionally required on": {
 			map[string]*Schema{
 				"string": {
@@ -488,3 +582,80 @@ func TestSchemaMapCoreConfigSchema(t *testing.T) {
 		})
 	}
 }
+
+func TestSchemaMapCoreConfigSchema_markdownDescription(t *testing.T) {
+	setPlainDescriptions(t)
+
+	got := schemaMap(map[string]*Schema{
+		"plain": {
+			Type:                TypeString,
+			Optional:            true,
+			Description:         "plain description",
+			MarkdownDescription: "**markdown** description",
+		},
+		"markdown": {
+			Type:                TypeString,
+			Required:            true,
+			MarkdownDescription: "**markdown** description",
+		},
+		"none": {
+			Type:     TypeString,
+			Optional: true,
+		},
+		"block": {
+			Type:                TypeList,
+			Optional:            true,
+			MarkdownDescription: "**markdown** block description",
+			Elem: &Resource{
+				Schema: map[string]*Schema{
+					"nested": {
+						Type:     TypeString,
+						Optional: true,
+					},
+				},
+			},
+		},
+	}).CoreConfigSchema()
+
+	want := &configschema.Block{
+		Attributes: map[string]*configschema.Attribute{
+			"plain": {
+				Type:            cty.String,
+				Optional:        true,
+				Description:     "plain description",
+				DescriptionKind: configschema.StringPlain,
+			},
+			"markdown": {
+				Type:            cty.String,
+				Required:        true,
+				Description:     "**markdown** description",
+				DescriptionKind: configschema.StringMarkdown,
+			},
+			"none": {
+				Type:            cty.String,
+				Optional:        true,
+				DescriptionKind: configschema.StringPlain,
+			},
+		},
+		BlockTypes: map[string]*configschema.NestedBlock{
+			"block": {
+				Nesting: configschema.NestingList,
+				Block: configschema.Block{
+					Attributes: map[string]*configschema.Attribute{
+						"nested": {
+							Type:     cty.String,
+							Optional: true,
+						},
+					},
+					BlockTypes:      map[string]*configschema.NestedBlock{},
+					Description:     "**markdown** block description",
+					DescriptionKind: configschema.StringMarkdown,
+				},
+			},
+		},
+	}
+
+	if !cmp.Equal(got, want, equateEmpty, typeComparer) {
+		t.Error(cmp.Diff(got, want, equateEmpty, typeComparer))
+	}
+}
@@ -3,9 +3,11 @@
 
 package schema
 
-// MAINTAINER NOTE: Only PROVIDER_CONFIG_UNKNOWN (enum value 2 in the plugin-protocol) is relevant
-// for SDKv2. Since (Deferred).Reason is mapped directly to the plugin-protocol,
-// the other enum values are intentionally omitted here.
+import "context"
+
+// MAINTAINER NOTE: Only PROVIDER_CONFIG_UNKNOWN (enum value 2 in the plugin-protocol) and
+// ABSENT_PREREQ (enum value 3) are relevant for SDKv2. Since (Deferred).Reason is mapped directly
+// to the plugin-protocol, the other enum values are intentionally omitted here.
 const (
 	// DeferredReasonUnknown is used to indicate an invalid `DeferredReason`.
 	// Provider developers should not use it.
@@ -14,6 +16,11 @@ const (
 	// DeferredReasonProviderConfigUnknown represents a deferred reason caused
 	// by unknown provider configuration.
 	DeferredReasonProviderConfigUnknown DeferredReason = 2
+
+	// DeferredReasonAbsentPrereq represents a deferred reason caused by a
+	// prerequisite, such as a resource the data source reads from, not
+	// existing yet.
+	DeferredReasonAbsentPrereq DeferredReason = 3
 )
 
 // Deferred is used to indicate to Terraform that a resource or data source is not able
@@ -28,6 +35,51 @@ type Deferred struct {
 	Reason DeferredReason
 }
 
+// deferredTypeName returns true if typeName is one of the type names a
+// scoped provider deferred response applies to.
+func deferredTypeName(typeName string, typeNames []string) bool {
+	for _, n := range typeNames {
+		if n == typeName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deferredResponse holds the deferred response set with SetDeferred during a
+// resource or data source read.
+type deferredResponse struct {
+	deferred *Deferred
+}
+
+// SetDeferred indicates to Terraform that the managed resource or data source
+// being read in the ReadContext function receiving the context should be
+// deferred, such as when a resource it depends on has not been created yet.
+// Terraform then keeps the current state of a managed resource, ignoring any
+// changes made during the read, and treats a data source result as unknown.
+// Only the Reason of the Deferred is used.
+//
+// The deferred response is only valid if the Terraform client supports
+// deferred actions, which is indicated by
+// ClientCapabilitiesFromContext(ctx).DeferralAllowed. Otherwise, the read
+// returns an error diagnostic. SetDeferred has no effect in other callbacks,
+// such as CreateContext, since Terraform cannot defer applying a change.
+//
+// NOTE: This functionality is related to deferred action support, which is currently experimental and is subject
+// to change or break without warning. It is not protected by version compatibility guarantees.
+func SetDeferred(ctx context.Context, deferred *Deferred) {
+	if resp, ok := ctx.Value(deferredContextKey).(*deferredResponse); ok {
+		resp.deferred = deferred
+	}
+}
+
+// withDeferredResponse returns a context in which SetDeferred records the
+// deferred response on resp.
+func withDeferredResponse(ctx context.Context, resp *deferredResponse) context.Context {
+	return context.WithValue(ctx, deferredContextKey, resp)
+}
+
 // DeferredReason represents different reasons for deferring a change.
 //
 // NOTE: This functionality is related to deferred action support, which is currently experimental and is subject
@@ -40,6 +92,8 @@ func (d DeferredReason) String() string {
 		return "Unknown"
 	case 2:
 		return "Provider Config Unknown"
+	case 3:
+		return "Absent Prerequisite"
 	}
 	return "Unknown"
 }
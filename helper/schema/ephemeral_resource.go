@@ -0,0 +1,200 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package schema
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/configs/configschema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+// ephemeralResourceID is the placeholder ID used to build the result of an
+// ephemeral resource from its ResourceData, since ephemeral resources have no
+// ID and are never persisted.
+const ephemeralResourceID = "ephemeral"
+
+// EphemeralResource represents an ephemeral resource in Terraform, which is
+// opened to produce a result, such as temporary credentials, that is never
+// persisted in the plan or state. Ephemeral resources are only supported in
+// Terraform 1.10 and later.
+type EphemeralResource struct {
+	// Schema is the schema for the configuration and result of this
+	// ephemeral resource. Computed attributes are set by OpenContext.
+	//
+	// Unlike managed resources and data sources, ephemeral resources have no
+	// implicit id attribute.
+	Schema map[string]*Schema
+
+	// Description is used as the description for docs and the language
+	// server.
+	Description string
+
+	// DeprecationMessage is shown as a warning when validating the
+	// configuration of this ephemeral resource, if non-empty.
+	DeprecationMessage string
+
+	// OpenContext is called to open the ephemeral resource with the given
+	// configuration. The result is built from the attributes set on the
+	// request ResourceData. It is required.
+	OpenContext EphemeralOpenContextFunc
+
+	// RenewContext is called to renew the ephemeral resource, such as to
+	// extend a lease, before the RenewAt time returned by OpenContext or a
+	// previous RenewContext. It is optional.
+	RenewContext EphemeralRenewContextFunc
+
+	// CloseContext is called to close the ephemeral resource, such as to
+	// revoke a lease, once Terraform no longer needs its result. It is
+	// optional.
+	CloseContext EphemeralCloseContextFunc
+}
+
+// EphemeralOpenContextFunc is the function called to open an ephemeral
+// resource.
+type EphemeralOpenContextFunc func(context.Context, EphemeralOpenRequest, *EphemeralOpenResponse)
+
+// EphemeralRenewContextFunc is the function called to renew an ephemeral
+// resource.
+type EphemeralRenewContextFunc func(context.Context, EphemeralRenewRequest, *EphemeralRenewResponse)
+
+// EphemeralCloseContextFunc is the function called to close an ephemeral
+// resource.
+type EphemeralCloseContextFunc func(context.Context, EphemeralCloseRequest, *EphemeralCloseResponse)
+
+// EphemeralOpenRequest is the request passed to the OpenContext function of
+// an EphemeralResource.
+type EphemeralOpenRequest struct {
+	// ResourceData contains the configuration of the ephemeral resource and
+	// is used to set its computed attributes.
+	ResourceData *ResourceData
+
+	// Meta is the value returned when configuring the provider.
+	Meta interface{}
+}
+
+// EphemeralOpenResponse is the response populated by the OpenContext
+// function of an EphemeralResource. The result is built from the attributes
+// set on the request ResourceData.
+type EphemeralOpenResponse struct {
+	// Private is opaque data which is passed to RenewContext and
+	// CloseContext, such as a lease identifier.
+	Private []byte
+
+	// RenewAt is the time at which Terraform should call RenewContext, if
+	// non-zero.
+	RenewAt time.Time
+
+	// Diagnostics report errors or warnings related to opening the ephemeral
+	// resource.
+	Diagnostics diag.Diagnostics
+}
+
+// EphemeralRenewRequest is the request passed to the RenewContext function
+// of an EphemeralResource. Only the private data is available, as Terraform
+// does not send the configuration or result when renewing.
+type EphemeralRenewRequest struct {
+	// Private is the private data returned by OpenContext or the previous
+	// RenewContext.
+	Private []byte
+
+	// Meta is the value returned when configuring the provider.
+	Meta interface{}
+}
+
+// EphemeralRenewResponse is the response populated by the RenewContext
+// function of an EphemeralResource.
+type EphemeralRenewResponse struct {
+	// Private is the new private data of the ephemeral resource. It is
+	// initialized to the request private data.
+	Private []byte
+
+	// RenewAt is the time at which Terraform should call RenewContext again,
+	// if non-zero.
+	RenewAt time.Time
+
+	// Diagnostics report errors or warnings related to renewing the
+	// ephemeral resource.
+	Diagnostics diag.Diagnostics
+}
+
+// EphemeralCloseRequest is the request passed to the CloseContext function
+// of an EphemeralResource. Only the private data is available, as Terraform
+// does not send the configuration or result when closing.
+type EphemeralCloseRequest struct {
+	// Private is the private data returned by OpenContext or the last
+	// RenewContext.
+	Private []byte
+
+	// Meta is the value returned when configuring the provider.
+	Meta interface{}
+}
+
+// EphemeralCloseResponse is the response populated by the CloseContext
+// function of an EphemeralResource.
+type EphemeralCloseResponse struct {
+	// Diagnostics report errors or warnings related to closing the
+	// ephemeral resource.
+	Diagnostics diag.Diagnostics
+}
+
+// CoreConfigSchema is a convenient shortcut for calling CoreConfigSchema on
+// the ephemeral resource schema.
+func (r *EphemeralResource) CoreConfigSchema() *configschema.Block {
+	block := schemaMap(r.Schema).CoreConfigSchema()
+	block.Description = r.Description
+
+	return block
+}
+
+// Validate validates the ephemeral resource configuration against the schema.
+func (r *EphemeralResource) Validate(c *terraform.ResourceConfig) diag.Diagnostics {
+	diags := schemaMap(r.Schema).Validate(c)
+
+	if r.DeprecationMessage != "" {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Deprecated Ephemeral Resource",
+			Detail:   r.DeprecationMessage,
+		})
+	}
+
+	return diags
+}
+
+// InternalValidate should be called to validate the structure of the
+// ephemeral resource. This should be called in a unit test.
+//
+// Provider.InternalValidate() will automatically call this for all
+// ephemeral resources.
+func (r *EphemeralResource) InternalValidate() error {
+	if r == nil {
+		return errors.New("ephemeral resource is nil")
+	}
+
+	if r.OpenContext == nil {
+		return errors.New("OpenContext must be implemented")
+	}
+
+	sm := schemaMap(r.Schema)
+	if err := sm.InternalValidate(sm); err != nil {
+		return err
+	}
+
+	if sm.hasWriteOnly() {
+		return errors.New("ephemeral resources cannot contain write-only attributes")
+	}
+
+	for k, s := range r.Schema {
+		if s.ForceNew || s.ForceNewFunc != nil {
+			return fmt.Errorf("%s: ForceNew is not valid for ephemeral resources", k)
+		}
+	}
+
+	return nil
+}
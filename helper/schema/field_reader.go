@@ -6,6 +6,7 @@ package schema
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 // FieldReaders are responsible for decoding fields out of data into
@@ -131,6 +132,18 @@ func addrToSchema(addr []string, schemaMap map[string]*Schema) []*Schema {
 					current = &Schema{Type: v}
 				case *Schema:
 					current, _ = current.Elem.(*Schema)
+				case *Resource:
+					if current.isObjectMap() {
+						current = &Schema{
+							Type: typeObject,
+							Elem: v.SchemaMap(),
+						}
+						break
+					}
+
+					// maps of *Resource without ConfigMode of attribute
+					// are treated as maps of strings.
+					current = &Schema{Type: TypeString}
 				default:
 					// maps default to string values. This is all we can have
 					// if this is nested in another list or map.
@@ -138,14 +151,14 @@ func addrToSchema(addr []string, schemaMap map[string]*Schema) []*Schema {
 				}
 			}
 		case typeObject:
-			// If we're already in the object, then we want to handle Sets
-			// and Lists specially. Basically, their next key is the lookup
-			// key (the set value or the list element). For these scenarios,
-			// we just want to skip it and move to the next element if there
-			// is one.
+			// If we're already in the object, then we want to handle Sets,
+			// Lists and Maps specially. Basically, their next key is the
+			// lookup key (the set value, the list element or the map key).
+			// For these scenarios, we just want to skip it and move to the
+			// next element if there is one.
 			if len(result) > 0 {
 				lastType := result[len(result)-2].Type
-				if lastType == TypeSet || lastType == TypeList {
+				if lastType == TypeSet || lastType == TypeList || lastType == TypeMap {
 					if len(addr) == 0 {
 						break
 					}
@@ -223,6 +236,59 @@ func readListField(
 	}, nil
 }
 
+// readObjectMapField is a generic method for reading a map of objects out of
+// a FieldReader, given the keys of the map. It is based on the assumption
+// that building an address of []string{k, KEY, FIELD} will result in the
+// proper field data.
+func readObjectMapField(
+	r FieldReader,
+	addr []string,
+	keys map[string]struct{},
+	schema map[string]*Schema) (FieldReadResult, error) {
+	result := make(map[string]interface{}, len(keys))
+	for key := range keys {
+		addrRead := make([]string, len(addr), len(addr)+1)
+		copy(addrRead, addr)
+		addrRead = append(addrRead, key)
+		rawResult, err := readObjectField(r, addrRead, schema)
+		if err != nil {
+			return FieldReadResult{}, err
+		}
+
+		result[key] = rawResult.Value
+	}
+
+	return FieldReadResult{
+		Value:  result,
+		Exists: true,
+	}, nil
+}
+
+// objectMapKeys returns the map keys of a map of objects from the given
+// flatmap keys, which are of the form "KEY.FIELD" after the prefix of the
+// map address. The map count is ignored.
+func objectMapKeys(prefix string, flatKeys []string) map[string]struct{} {
+	keys := make(map[string]struct{})
+	for _, k := range flatKeys {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+
+		key := k[len(prefix):]
+		if i := strings.Index(key, "."); i >= 0 {
+			key = key[:i]
+		}
+
+		if key == "%" || key == "" {
+			continue
+		}
+
+		keys[key] = struct{}{}
+	}
+
+	return keys
+}
+
 // readObjectField is a generic method for reading objects out of FieldReaders
 // based on the assumption that building an address of []string{k, FIELD}
 // will result in the proper field data.
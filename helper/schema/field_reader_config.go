@@ -4,6 +4,7 @@
 package schema
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strconv"
@@ -23,6 +24,9 @@ type ConfigFieldReader struct {
 	Config *terraform.ResourceConfig
 	Schema map[string]*Schema
 
+	// ctx is passed to Schema.DefaultContextFunc when reading defaults.
+	ctx context.Context
+
 	indexMaps map[string]map[string]int
 	once      sync.Once
 }
@@ -105,6 +109,10 @@ func (r *ConfigFieldReader) readField(
 	case TypeList:
 		return readListField(&nestedConfigFieldReader{r}, address)
 	case TypeMap:
+		if schema.isObjectMap() {
+			return r.readObjectMap(address, schema)
+		}
+
 		return r.readMap(k, schema)
 	case TypeSet:
 		return r.readSet(address, schema)
@@ -221,13 +229,53 @@ func (r *ConfigFieldReader) readMap(k string, schema *Schema) (FieldReadResult,
 	}, nil
 }
 
+func (r *ConfigFieldReader) readObjectMap(address []string, schema *Schema) (FieldReadResult, error) {
+	k := strings.Join(address, ".")
+
+	raw, ok := r.Config.Get(k)
+	if !ok {
+		return FieldReadResult{}, nil
+	}
+
+	keys := make(map[string]struct{})
+	switch m := raw.(type) {
+	case map[string]interface{}:
+		for key := range m {
+			if r.Config.IsComputed(k + "." + key) {
+				return FieldReadResult{
+					Exists:   true,
+					Computed: true,
+				}, nil
+			}
+
+			keys[key] = struct{}{}
+		}
+	case nil:
+		// the map may have been empty on the configuration, so we leave the
+		// empty result
+	default:
+		// anything else, such as an unknown value, must be computed
+		return FieldReadResult{
+			Exists:   true,
+			Computed: true,
+		}, nil
+	}
+
+	return readObjectMapField(&nestedConfigFieldReader{r}, address, keys, schema.Elem.(*Resource).SchemaMap())
+}
+
 func (r *ConfigFieldReader) readPrimitive(
 	k string, schema *Schema) (FieldReadResult, error) {
 	raw, ok := r.Config.Get(k)
 	if !ok {
 		// Nothing in config, but we might still have a default from the schema
 		var err error
-		raw, err = schema.DefaultValue()
+		ctx := r.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+
+		raw, err = schema.DefaultValueContext(ctx)
 		if err != nil {
 			return FieldReadResult{}, fmt.Errorf("%s, error loading default: %s", k, err)
 		}
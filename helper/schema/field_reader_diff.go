@@ -72,7 +72,11 @@ func (r *DiffFieldReader) ReadField(address []string) (FieldReadResult, error) {
 	case TypeList:
 		res, err = readListField(r, address)
 	case TypeMap:
-		res, err = r.readMap(address, schema)
+		if schema.isObjectMap() {
+			res, err = r.readObjectMap(address, schema)
+		} else {
+			res, err = r.readMap(address, schema)
+		}
 	case TypeSet:
 		res, err = r.readSet(address, schema)
 	case typeObject:
@@ -145,6 +149,61 @@ func (r *DiffFieldReader) readMap(
 	}, nil
 }
 
+func (r *DiffFieldReader) readObjectMap(
+	address []string, schema *Schema) (FieldReadResult, error) {
+	keys := make(map[string]struct{})
+
+	// First read the map keys from the underlying source
+	source, err := r.Source.ReadField(address)
+	if err != nil {
+		return FieldReadResult{}, err
+	}
+
+	exists := source.Exists
+	if m, ok := source.Value.(map[string]interface{}); ok {
+		for key := range m {
+			keys[key] = struct{}{}
+		}
+	}
+
+	// Next, apply the keys of all the elements we have in our diff. Keys are
+	// only removed if all of their attributes are removed.
+	prefix := strings.Join(address, ".") + "."
+	removed := make(map[string]struct{})
+	present := make(map[string]struct{})
+	for k, v := range r.Diff.Attributes {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+
+		exists = true
+
+		for key := range objectMapKeys(prefix, []string{k}) {
+			if v.NewRemoved {
+				removed[key] = struct{}{}
+			} else {
+				present[key] = struct{}{}
+			}
+		}
+	}
+
+	if !exists {
+		return FieldReadResult{}, nil
+	}
+
+	for key := range removed {
+		if _, ok := present[key]; !ok {
+			delete(keys, key)
+		}
+	}
+
+	for key := range present {
+		keys[key] = struct{}{}
+	}
+
+	return readObjectMapField(r, address, keys, schema.Elem.(*Resource).SchemaMap())
+}
+
 func (r *DiffFieldReader) readPrimitive(
 	address []string, schema *Schema) (FieldReadResult, error) {
 	result, err := r.Source.ReadField(address)
@@ -29,6 +29,10 @@ func (r *MapFieldReader) ReadField(address []string) (FieldReadResult, error) {
 	case TypeList:
 		return readListField(r, address)
 	case TypeMap:
+		if schema.isObjectMap() {
+			return r.readObjectMap(address, schema)
+		}
+
 		return r.readMap(k, schema)
 	case TypeSet:
 		return r.readSet(address, schema)
@@ -80,6 +84,32 @@ func (r *MapFieldReader) readMap(k string, schema *Schema) (FieldReadResult, err
 	}, nil
 }
 
+func (r *MapFieldReader) readObjectMap(address []string, schema *Schema) (FieldReadResult, error) {
+	k := strings.Join(address, ".")
+
+	// If the name of the map field is directly in the map with an
+	// empty string, it means that the map is being deleted, so mark
+	// that is is set.
+	v, exists := r.Map.Access(k)
+	exists = exists && v == ""
+
+	var flatKeys []string
+	prefix := k + "."
+	r.Map.Range(func(k, _ string) bool {
+		if strings.HasPrefix(k, prefix) {
+			flatKeys = append(flatKeys, k)
+		}
+
+		return true
+	})
+
+	if !exists && len(flatKeys) == 0 {
+		return FieldReadResult{}, nil
+	}
+
+	return readObjectMapField(r, address, objectMapKeys(prefix, flatKeys), schema.Elem.(*Resource).SchemaMap())
+}
+
 func (r *MapFieldReader) readPrimitive(
 	address []string, schema *Schema) (FieldReadResult, error) {
 	k := strings.Join(address, ".")
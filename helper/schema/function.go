@@ -0,0 +1,154 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package schema
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/go-cty/cty"
+	ctyconvert "github.com/hashicorp/go-cty/cty/convert"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/configs/hcl2shim"
+)
+
+// Function represents a provider-defined function, which can be called from
+// Terraform configuration with the provider::<provider>::<name>() syntax.
+type Function struct {
+	// Summary is a short human-readable description of the function.
+	Summary string
+
+	// Description is the longer human-readable documentation for the
+	// function.
+	Description string
+
+	// DeprecationMessage, if non-empty, marks the function as deprecated and
+	// is shown to practitioners calling it.
+	DeprecationMessage string
+
+	// Parameters is the ordered list of positional parameters of the
+	// function. Terraform ensures each argument is converted to the Type of
+	// its parameter before calling the function.
+	Parameters []*Parameter
+
+	// Return defines the result of the function. It is required.
+	Return *Return
+
+	// Run is called with the decoded argument values, in the same order as
+	// Parameters, after each argument has passed its ValidateFunc. The
+	// returned value must either be a cty.Value or a Go value, such as a
+	// string, number, bool, []interface{}, or map[string]interface{}, which
+	// can be converted to the Return type. Error diagnostics are returned to
+	// Terraform as the function error. It is required.
+	Run FunctionRunFunc
+}
+
+// FunctionRunFunc is the function called to compute the result of a
+// provider-defined function.
+type FunctionRunFunc func(ctx context.Context, args []cty.Value) (interface{}, diag.Diagnostics)
+
+// Parameter is a positional parameter of a provider-defined function.
+type Parameter struct {
+	// Name is the name of the parameter, which is used in documentation and
+	// error messages.
+	Name string
+
+	// Description is the human-readable documentation for the parameter.
+	Description string
+
+	// Type is the type of argument values for the parameter. It is required.
+	Type cty.Type
+
+	// AllowNullValue allows null argument values to be passed to the
+	// function. By default Terraform returns an error for null arguments.
+	AllowNullValue bool
+
+	// AllowUnknownValues allows unknown argument values to be passed to the
+	// function. By default Terraform skips calling the function when an
+	// argument is unknown and uses an unknown result.
+	AllowUnknownValues bool
+
+	// ValidateFunc is an optional function to validate the argument value
+	// before the function is run. Error diagnostics are returned to Terraform
+	// as the function error for this argument.
+	ValidateFunc ParameterValidateFunc
+}
+
+// ParameterValidateFunc is the function called to validate the argument
+// value of a provider-defined function parameter.
+type ParameterValidateFunc func(ctx context.Context, value cty.Value) diag.Diagnostics
+
+// Return is the result of a provider-defined function.
+type Return struct {
+	// Type is the type of the function result. It is required.
+	Type cty.Type
+}
+
+// InternalValidate should be called to validate the structure of the
+// function. This should be called in a unit test.
+//
+// Provider.InternalValidate() will automatically call this for all
+// functions.
+func (f *Function) InternalValidate() error {
+	if f == nil {
+		return fmt.Errorf("function is nil")
+	}
+
+	if f.Run == nil {
+		return fmt.Errorf("Run must be implemented")
+	}
+
+	if f.Return == nil || f.Return.Type == cty.NilType {
+		return fmt.Errorf("Return type must be specified")
+	}
+
+	for i, param := range f.Parameters {
+		if param == nil {
+			return fmt.Errorf("parameter %d is nil", i)
+		}
+
+		if param.Type == cty.NilType {
+			return fmt.Errorf("parameter %d (%q): Type must be specified", i, param.Name)
+		}
+	}
+
+	return nil
+}
+
+// resultValue converts the result of Run into a value of the Return type.
+func (f *Function) resultValue(result interface{}) (cty.Value, error) {
+	if result == nil {
+		return cty.NullVal(f.Return.Type), nil
+	}
+
+	val, ok := result.(cty.Value)
+	if !ok {
+		val = hcl2shim.HCL2ValueFromConfigValue(result)
+	}
+
+	return ctyconvert.Convert(val, f.Return.Type)
+}
+
+// functionErrorText combines the error diagnostics into the text of a
+// function error.
+func functionErrorText(diags diag.Diagnostics) string {
+	var parts []string
+
+	for _, d := range diags {
+		if d.Severity != diag.Error {
+			continue
+		}
+
+		if d.Detail == "" {
+			parts = append(parts, d.Summary)
+			continue
+		}
+
+		parts = append(parts, d.Summary+": "+d.Detail)
+	}
+
+	return strings.Join(parts, "\n")
+}
@@ -7,26 +7,36 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/hashicorp/go-cty/cty"
 	ctyconvert "github.com/hashicorp/go-cty/cty/convert"
+	ctyjson "github.com/hashicorp/go-cty/cty/json"
 	"github.com/hashicorp/go-cty/cty/msgpack"
 
 	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
 	"github.com/hashicorp/terraform-plugin-go/tftypes"
 
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/configs/configschema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/configs/hcl2shim"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/diagutils"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/logging"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/plans/objchange"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/plugin/convert"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/tfdiags"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
 const (
 	newExtraKey = "_new_extra_shim"
+
+	// privateKey is the key of the private state which contains the values
+	// set with ResourceDiff.SetPrivate.
+	privateKey = "_private_shim"
 )
 
 // Verify provider server interface implementation.
@@ -39,11 +49,73 @@ func NewGRPCProviderServer(p *Provider) *GRPCProviderServer {
 	}
 }
 
+// NewGRPCProviderServerWithOptions is like NewGRPCProviderServer, but also
+// applies the given ServerOption to the server.
+func NewGRPCProviderServerWithOptions(p *Provider, opts ...ServerOption) *GRPCProviderServer {
+	s := NewGRPCProviderServer(p)
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// ServerOption configures optional behavior of a GRPCProviderServer.
+type ServerOption func(*GRPCProviderServer)
+
+// WithMaxConcurrentApply limits the number of ApplyResourceChange calls which
+// can run concurrently to n, across all resource types. Further calls wait
+// until a running call completes, or return an error diagnostic if their
+// context is done first. Values of n less than 1 leave the number of
+// concurrent calls unbounded.
+func WithMaxConcurrentApply(n int) ServerOption {
+	return func(s *GRPCProviderServer) {
+		if n < 1 {
+			s.applySem = nil
+			return
+		}
+
+		s.applySem = make(chan struct{}, n)
+	}
+}
+
+// WithInternalValidate runs Provider.InternalValidate when the server is
+// created and panics with the resulting error diagnostics if the provider is
+// invalid, so that schema problems are reported when the provider starts
+// rather than by a later RPC.
+func WithInternalValidate() ServerOption {
+	return func(s *GRPCProviderServer) {
+		diags := schemaErrorDiagnostics(s.provider.SchemaErrors())
+		if diags.HasError() {
+			panic(fmt.Sprintf("invalid provider schema:\n\n%s", diagutils.ErrorDiags(diags)))
+		}
+	}
+}
+
 // GRPCProviderServer handles the server, or plugin side of the rpc connection.
 type GRPCProviderServer struct {
 	provider *Provider
 	stopCh   chan struct{}
 	stopMu   sync.Mutex
+
+	// schemaResp is the response of the first successful GetProviderSchema
+	// call, which is reused by later calls since the schemas of a provider
+	// do not change while it is served. Changes to the Provider schemas
+	// after the first call are therefore never returned. It is guarded by
+	// schemaMu.
+	schemaResp *tfprotov5.GetProviderSchemaResponse
+	schemaMu   sync.Mutex
+
+	// applySem, if non-nil, bounds the number of concurrent
+	// ApplyResourceChange calls to its capacity.
+	applySem chan struct{}
+
+	// rateLimiters holds the rate limiter of each resource and data source
+	// type with a RateLimit, created on first use. It is guarded by
+	// rateLimitersMu.
+	rateLimiters   map[rateLimiterKey]*rateLimiter
+	rateLimitersMu sync.Mutex
 }
 
 // mergeStop is called in a goroutine and waits for the global stop signal
@@ -58,6 +130,56 @@ func mergeStop(ctx context.Context, cancel context.CancelFunc, stopCh chan struc
 	}
 }
 
+// withRawRequest returns a context containing the given protocol request if
+// the provider has opted in with ExposeRawRequest.
+func (s *GRPCProviderServer) withRawRequest(ctx context.Context, req interface{}) context.Context {
+	if !s.provider.ExposeRawRequest {
+		return ctx
+	}
+
+	return context.WithValue(ctx, rawRequestContextKey, req)
+}
+
+// withRateLimiter returns a context which applies the RateLimit of res to
+// calls of its create, read, update, and delete functions. The limiter is
+// shared by all calls for the same resource or data source type name.
+func (s *GRPCProviderServer) withRateLimiter(ctx context.Context, typeName string, dataSource bool, res *Resource) context.Context {
+	if res.RateLimit == nil {
+		return ctx
+	}
+
+	key := rateLimiterKey{typeName: typeName, dataSource: dataSource}
+
+	s.rateLimitersMu.Lock()
+	defer s.rateLimitersMu.Unlock()
+
+	limiter, ok := s.rateLimiters[key]
+	if !ok {
+		if s.rateLimiters == nil {
+			s.rateLimiters = make(map[rateLimiterKey]*rateLimiter)
+		}
+
+		limiter = newRateLimiter(res.RateLimit)
+		s.rateLimiters[key] = limiter
+	}
+
+	return withRateLimiter(ctx, limiter)
+}
+
+// withTerraformVersion returns a context which makes the version of Terraform
+// which configured the provider available to provider callbacks through
+// TerraformVersionFromContext.
+func withTerraformVersion(ctx context.Context, version string) context.Context {
+	return context.WithValue(ctx, terraformVersionContextKey, version)
+}
+
+// withClientCapabilities returns a context which makes the client
+// capabilities of the request available to provider callbacks through
+// ClientCapabilitiesFromContext.
+func withClientCapabilities(ctx context.Context, caps ClientCapabilities) context.Context {
+	return context.WithValue(ctx, clientCapabilitiesContextKey, caps)
+}
+
 // StopContext derives a new context from the passed in grpc context.
 // It creates a goroutine to wait for the server stop and propagates
 // cancellation to the derived grpc context.
@@ -72,8 +194,18 @@ func (s *GRPCProviderServer) StopContext(ctx context.Context) context.Context {
 }
 
 func (s *GRPCProviderServer) serverCapabilities() *tfprotov5.ServerCapabilities {
+	moveResourceState := false
+
+	for _, res := range s.provider.ResourcesMap {
+		if res != nil && len(res.MoveState) > 0 {
+			moveResourceState = true
+			break
+		}
+	}
+
 	return &tfprotov5.ServerCapabilities{
 		GetProviderSchemaOptional: true,
+		MoveResourceState:         moveResourceState,
 	}
 }
 
@@ -110,6 +242,8 @@ func (s *GRPCProviderServer) GetResourceIdentitySchemas(ctx context.Context, req
 
 func (s *GRPCProviderServer) UpgradeResourceIdentity(ctx context.Context, req *tfprotov5.UpgradeResourceIdentityRequest) (*tfprotov5.UpgradeResourceIdentityResponse, error) {
 	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
+	ctx = withTerraformVersion(ctx, s.provider.TerraformVersion)
 	resp := &tfprotov5.UpgradeResourceIdentityResponse{}
 
 	res, ok := s.provider.ResourcesMap[req.TypeName]
@@ -193,8 +327,8 @@ func (s *GRPCProviderServer) GetMetadata(ctx context.Context, req *tfprotov5.Get
 
 	resp := &tfprotov5.GetMetadataResponse{
 		DataSources:        make([]tfprotov5.DataSourceMetadata, 0, len(s.provider.DataSourcesMap)),
-		EphemeralResources: make([]tfprotov5.EphemeralResourceMetadata, 0),
-		Functions:          make([]tfprotov5.FunctionMetadata, 0),
+		EphemeralResources: make([]tfprotov5.EphemeralResourceMetadata, 0, len(s.provider.EphemeralResourcesMap)),
+		Functions:          make([]tfprotov5.FunctionMetadata, 0, len(s.provider.Functions)),
 		Resources:          make([]tfprotov5.ResourceMetadata, 0, len(s.provider.ResourcesMap)),
 		ServerCapabilities: s.serverCapabilities(),
 	}
@@ -205,6 +339,18 @@ func (s *GRPCProviderServer) GetMetadata(ctx context.Context, req *tfprotov5.Get
 		})
 	}
 
+	for typeName := range s.provider.EphemeralResourcesMap {
+		resp.EphemeralResources = append(resp.EphemeralResources, tfprotov5.EphemeralResourceMetadata{
+			TypeName: typeName,
+		})
+	}
+
+	for name := range s.provider.Functions {
+		resp.Functions = append(resp.Functions, tfprotov5.FunctionMetadata{
+			Name: name,
+		})
+	}
+
 	for typeName := range s.provider.ResourcesMap {
 		resp.Resources = append(resp.Resources, tfprotov5.ResourceMetadata{
 			TypeName: typeName,
@@ -214,19 +360,41 @@ func (s *GRPCProviderServer) GetMetadata(ctx context.Context, req *tfprotov5.Get
 	return resp, nil
 }
 
+// GetProviderSchema returns the schemas of the provider, which are built on
+// the first successful call and cached for the lifetime of the server, so
+// changes to the Provider schemas after the first call are never picked up.
+// Each call returns a copy of the response and its maps, while the
+// *tfprotov5.Schema values within them are shared between calls and must
+// not be modified.
 func (s *GRPCProviderServer) GetProviderSchema(ctx context.Context, req *tfprotov5.GetProviderSchemaRequest) (*tfprotov5.GetProviderSchemaResponse, error) {
 	ctx = logging.InitContext(ctx)
 
 	logging.HelperSchemaTrace(ctx, "Getting provider schema")
 
+	s.schemaMu.Lock()
+	defer s.schemaMu.Unlock()
+
+	if s.schemaResp != nil {
+		logging.HelperSchemaTrace(ctx, "Using cached provider schema")
+
+		return copyProviderSchemaResponse(s.schemaResp), nil
+	}
+
 	resp := &tfprotov5.GetProviderSchemaResponse{
 		DataSourceSchemas:        make(map[string]*tfprotov5.Schema, len(s.provider.DataSourcesMap)),
-		EphemeralResourceSchemas: make(map[string]*tfprotov5.Schema, 0),
-		Functions:                make(map[string]*tfprotov5.Function, 0),
+		EphemeralResourceSchemas: make(map[string]*tfprotov5.Schema, len(s.provider.EphemeralResourcesMap)),
 		ResourceSchemas:          make(map[string]*tfprotov5.Schema, len(s.provider.ResourcesMap)),
 		ServerCapabilities:       s.serverCapabilities(),
 	}
 
+	functions, err := s.getFunctions()
+	if err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		return resp, nil
+	}
+
+	resp.Functions = functions
+
 	resp.Provider = &tfprotov5.Schema{
 		Block: convert.ConfigSchemaToProto(ctx, s.getProviderSchemaBlock()),
 	}
@@ -253,7 +421,53 @@ func (s *GRPCProviderServer) GetProviderSchema(ctx context.Context, req *tfproto
 		}
 	}
 
-	return resp, nil
+	for typ, res := range s.provider.EphemeralResourcesMap {
+		logging.HelperSchemaTrace(ctx, "Found ephemeral resource type", map[string]interface{}{logging.KeyEphemeralResourceType: typ})
+
+		resp.EphemeralResourceSchemas[typ] = &tfprotov5.Schema{
+			Block: convert.ConfigSchemaToProto(ctx, res.CoreConfigSchema()),
+		}
+	}
+
+	s.schemaResp = resp
+
+	return copyProviderSchemaResponse(resp), nil
+}
+
+// copyProviderSchemaResponse returns a copy of resp and its maps and
+// diagnostics, so callers cannot modify the cached response. The schemas
+// and functions within it are not copied.
+func copyProviderSchemaResponse(resp *tfprotov5.GetProviderSchemaResponse) *tfprotov5.GetProviderSchemaResponse {
+	result := *resp
+
+	result.ResourceSchemas = make(map[string]*tfprotov5.Schema, len(resp.ResourceSchemas))
+	for k, v := range resp.ResourceSchemas {
+		result.ResourceSchemas[k] = v
+	}
+
+	result.DataSourceSchemas = make(map[string]*tfprotov5.Schema, len(resp.DataSourceSchemas))
+	for k, v := range resp.DataSourceSchemas {
+		result.DataSourceSchemas[k] = v
+	}
+
+	result.EphemeralResourceSchemas = make(map[string]*tfprotov5.Schema, len(resp.EphemeralResourceSchemas))
+	for k, v := range resp.EphemeralResourceSchemas {
+		result.EphemeralResourceSchemas[k] = v
+	}
+
+	if resp.Functions != nil {
+		result.Functions = make(map[string]*tfprotov5.Function, len(resp.Functions))
+		for k, v := range resp.Functions {
+			result.Functions[k] = v
+		}
+	}
+
+	if resp.Diagnostics != nil {
+		result.Diagnostics = make([]*tfprotov5.Diagnostic, len(resp.Diagnostics))
+		copy(result.Diagnostics, resp.Diagnostics)
+	}
+
+	return &result
 }
 
 func (s *GRPCProviderServer) getProviderSchemaBlock() *configschema.Block {
@@ -281,6 +495,7 @@ func (s *GRPCProviderServer) getDatasourceSchemaBlock(name string) *configschema
 
 func (s *GRPCProviderServer) PrepareProviderConfig(ctx context.Context, req *tfprotov5.PrepareProviderConfigRequest) (*tfprotov5.PrepareProviderConfigResponse, error) {
 	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
 	resp := &tfprotov5.PrepareProviderConfigResponse{}
 
 	logging.HelperSchemaTrace(ctx, "Preparing provider configuration")
@@ -325,7 +540,7 @@ func (s *GRPCProviderServer) PrepareProviderConfig(ctx context.Context, req *tfp
 		}
 
 		// find a default value if it exists
-		def, err := attrSchema.DefaultValue()
+		def, err := attrSchema.DefaultValueContext(ctx)
 		if err != nil {
 			return val, fmt.Errorf("error getting default for %q: %w", getAttr.Name, err)
 		}
@@ -345,6 +560,17 @@ func (s *GRPCProviderServer) PrepareProviderConfig(ctx context.Context, req *tfp
 			tmpVal = cty.False
 		}
 
+		if attrSchema.WarnOnCoercion && val.Type().IsPrimitiveType() && !tmpVal.Type().Equals(val.Type()) {
+			resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, diag.Diagnostics{
+				{
+					Severity:      diag.Warning,
+					Summary:       "Value converted to a different type",
+					Detail:        fmt.Sprintf("The %s default value for %q was converted to a %s.", tmpVal.Type().FriendlyName(), getAttr.Name, val.Type().FriendlyName()),
+					AttributePath: path,
+				},
+			})
+		}
+
 		val, err = ctyconvert.Convert(tmpVal, val.Type())
 		if err != nil {
 			return val, fmt.Errorf("error setting default for %q: %w", getAttr.Name, err)
@@ -365,14 +591,19 @@ func (s *GRPCProviderServer) PrepareProviderConfig(ctx context.Context, req *tfp
 
 	// Ensure there are no nulls that will cause helper/schema to panic.
 	if err := validateConfigNulls(ctx, configVal, nil); err != nil {
-		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, redactSensitiveSetElementPaths(err, schemaBlock))
 		return resp, nil
 	}
 
 	config := terraform.NewResourceConfigShimmed(configVal, schemaBlock)
 
 	logging.HelperSchemaTrace(ctx, "Calling downstream")
-	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, s.provider.Validate(config))
+	validateDiags := s.provider.Validate(config)
+	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, validateDiags)
+
+	if s.provider.ValidateProviderConfig != nil && !validateDiags.HasError() {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, s.provider.validateProviderConfig(ctx, config, configVal))
+	}
 	logging.HelperSchemaTrace(ctx, "Called downstream")
 
 	preparedConfigMP, err := msgpack.Marshal(configVal, schemaBlock.ImpliedType())
@@ -388,6 +619,11 @@ func (s *GRPCProviderServer) PrepareProviderConfig(ctx context.Context, req *tfp
 
 func (s *GRPCProviderServer) ValidateResourceTypeConfig(ctx context.Context, req *tfprotov5.ValidateResourceTypeConfigRequest) (*tfprotov5.ValidateResourceTypeConfigResponse, error) {
 	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
+	ctx = withTerraformVersion(ctx, s.provider.TerraformVersion)
+	ctx = withClientCapabilities(ctx, ClientCapabilities{
+		WriteOnlyAttributesAllowed: req.ClientCapabilities != nil && req.ClientCapabilities.WriteOnlyAttributesAllowed,
+	})
 	resp := &tfprotov5.ValidateResourceTypeConfigResponse{}
 
 	schemaBlock := s.getResourceSchemaBlock(req.TypeName)
@@ -426,6 +662,10 @@ func (s *GRPCProviderServer) ValidateResourceTypeConfig(ctx context.Context, req
 
 	config := terraform.NewResourceConfigShimmed(configVal, schemaBlock)
 
+	// The raw configuration is used to build the attribute paths of
+	// diagnostics for set elements, which are addressed by value.
+	config.CtyValue = configVal
+
 	logging.HelperSchemaTrace(ctx, "Calling downstream")
 	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, s.provider.ValidateResource(req.TypeName, config))
 	logging.HelperSchemaTrace(ctx, "Called downstream")
@@ -435,6 +675,8 @@ func (s *GRPCProviderServer) ValidateResourceTypeConfig(ctx context.Context, req
 
 func (s *GRPCProviderServer) ValidateDataSourceConfig(ctx context.Context, req *tfprotov5.ValidateDataSourceConfigRequest) (*tfprotov5.ValidateDataSourceConfigResponse, error) {
 	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
+	ctx = withTerraformVersion(ctx, s.provider.TerraformVersion)
 	resp := &tfprotov5.ValidateDataSourceConfigResponse{}
 
 	schemaBlock := s.getDatasourceSchemaBlock(req.TypeName)
@@ -447,12 +689,30 @@ func (s *GRPCProviderServer) ValidateDataSourceConfig(ctx context.Context, req *
 
 	// Ensure there are no nulls that will cause helper/schema to panic.
 	if err := validateConfigNulls(ctx, configVal, nil); err != nil {
-		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, redactSensitiveSetElementPaths(err, schemaBlock))
 		return resp, nil
 	}
 
+	// Calling all ValidateDataSourceConfigFunc here since they validate on
+	// the raw go-cty config value.
+	if r, ok := s.provider.DataSourcesMap[req.TypeName]; ok && r.ValidateRawDataSourceConfigFuncs != nil {
+		validateReq := ValidateDataSourceConfigFuncRequest{
+			RawConfig: configVal,
+		}
+
+		for _, validateFunc := range r.ValidateRawDataSourceConfigFuncs {
+			validateResp := &ValidateDataSourceConfigFuncResponse{}
+			validateFunc(ctx, validateReq, validateResp)
+			resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, validateResp.Diagnostics)
+		}
+	}
+
 	config := terraform.NewResourceConfigShimmed(configVal, schemaBlock)
 
+	// The raw configuration is used to build the attribute paths of
+	// diagnostics for set elements, which are addressed by value.
+	config.CtyValue = configVal
+
 	logging.HelperSchemaTrace(ctx, "Calling downstream")
 	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, s.provider.ValidateDataSource(req.TypeName, config))
 	logging.HelperSchemaTrace(ctx, "Called downstream")
@@ -462,6 +722,8 @@ func (s *GRPCProviderServer) ValidateDataSourceConfig(ctx context.Context, req *
 
 func (s *GRPCProviderServer) UpgradeResourceState(ctx context.Context, req *tfprotov5.UpgradeResourceStateRequest) (*tfprotov5.UpgradeResourceStateResponse, error) {
 	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
+	ctx = withTerraformVersion(ctx, s.provider.TerraformVersion)
 	resp := &tfprotov5.UpgradeResourceStateResponse{}
 
 	res, ok := s.provider.ResourcesMap[req.TypeName]
@@ -537,6 +799,17 @@ func (s *GRPCProviderServer) UpgradeResourceState(ctx context.Context, req *tfpr
 	// Set any write-only attribute values to null
 	val = setWriteOnlyNullValues(val, schemaBlock)
 
+	if res.PreferJSONStateEncoding {
+		newStateJSON, err := ctyjson.Marshal(val, schemaBlock.ImpliedType())
+		if err != nil {
+			resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+			return resp, nil
+		}
+
+		resp.UpgradedState = &tfprotov5.DynamicValue{JSON: newStateJSON}
+		return resp, nil
+	}
+
 	// encode the final state to the expected msgpack format
 	newStateMP, err := msgpack.Marshal(val, schemaBlock.ImpliedType())
 	if err != nil {
@@ -714,6 +987,11 @@ func (s *GRPCProviderServer) StopProvider(ctx context.Context, _ *tfprotov5.Stop
 
 func (s *GRPCProviderServer) ConfigureProvider(ctx context.Context, req *tfprotov5.ConfigureProviderRequest) (*tfprotov5.ConfigureProviderResponse, error) {
 	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
+	ctx = withTerraformVersion(ctx, req.TerraformVersion)
+	ctx = withClientCapabilities(ctx, ClientCapabilities{
+		DeferralAllowed: req.ClientCapabilities != nil && req.ClientCapabilities.DeferralAllowed,
+	})
 	resp := &tfprotov5.ConfigureProviderResponse{}
 
 	schemaBlock := s.getProviderSchemaBlock()
@@ -728,7 +1006,7 @@ func (s *GRPCProviderServer) ConfigureProvider(ctx context.Context, req *tfproto
 
 	// Ensure there are no nulls that will cause helper/schema to panic.
 	if err := validateConfigNulls(ctx, configVal, nil); err != nil {
-		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, redactSensitiveSetElementPaths(err, schemaBlock))
 		return resp, nil
 	}
 
@@ -764,18 +1042,23 @@ func (s *GRPCProviderServer) ConfigureProvider(ctx context.Context, req *tfproto
 	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, diags)
 
 	if s.provider.providerDeferred != nil {
+		scope := "all resources and data sources"
+		if s.provider.providerDeferredScoped() {
+			scope = "specific resources or data sources"
+		}
+
 		// Check if a deferred response was incorrectly set on the provider. This would cause an error during later RPCs.
 		if !s.provider.deferralAllowed {
 			resp.Diagnostics = append(resp.Diagnostics, &tfprotov5.Diagnostic{
 				Severity: tfprotov5.DiagnosticSeverityError,
 				Summary:  "Invalid Deferred Provider Response",
-				Detail: "Provider configured a deferred response for all resources and data sources but the Terraform request " +
+				Detail: "Provider configured a deferred response for " + scope + " but the Terraform request " +
 					"did not indicate support for deferred actions. This is an issue with the provider and should be reported to the provider developers.",
 			})
 		} else {
 			logging.HelperSchemaDebug(
 				ctx,
-				"Provider has configured a deferred response, all associated resources and data sources will automatically return a deferred response.",
+				"Provider has configured a deferred response, "+scope+" will automatically return a deferred response.",
 				map[string]interface{}{
 					logging.KeyDeferredReason: s.provider.providerDeferred.Reason.String(),
 				},
@@ -788,6 +1071,11 @@ func (s *GRPCProviderServer) ConfigureProvider(ctx context.Context, req *tfproto
 
 func (s *GRPCProviderServer) ReadResource(ctx context.Context, req *tfprotov5.ReadResourceRequest) (*tfprotov5.ReadResourceResponse, error) {
 	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
+	ctx = withTerraformVersion(ctx, s.provider.TerraformVersion)
+	ctx = withClientCapabilities(ctx, ClientCapabilities{
+		DeferralAllowed: req.ClientCapabilities != nil && req.ClientCapabilities.DeferralAllowed,
+	})
 	resp := &tfprotov5.ReadResourceResponse{
 		// helper/schema did previously handle private data during refresh, but
 		// core is now going to expect this to be maintained in order to
@@ -802,19 +1090,21 @@ func (s *GRPCProviderServer) ReadResource(ctx context.Context, req *tfprotov5.Re
 	}
 	schemaBlock := s.getResourceSchemaBlock(req.TypeName)
 
-	if s.provider.providerDeferred != nil {
+	providerDeferred := s.provider.resourceDeferred(req.TypeName)
+
+	if providerDeferred != nil {
 		logging.HelperSchemaDebug(
 			ctx,
 			"Provider has deferred response configured, automatically returning deferred response.",
 			map[string]interface{}{
-				logging.KeyDeferredReason: s.provider.providerDeferred.Reason.String(),
+				logging.KeyDeferredReason: providerDeferred.Reason.String(),
 			},
 		)
 
 		resp.NewState = req.CurrentState
 		resp.NewIdentity = req.CurrentIdentity
 		resp.Deferred = &tfprotov5.Deferred{
-			Reason: tfprotov5.DeferredReason(s.provider.providerDeferred.Reason),
+			Reason: tfprotov5.DeferredReason(providerDeferred.Reason),
 		}
 		return resp, nil
 	}
@@ -832,6 +1122,17 @@ func (s *GRPCProviderServer) ReadResource(ctx context.Context, req *tfprotov5.Re
 	}
 	instanceState.RawState = stateVal
 
+	private := make(map[string]interface{})
+	if len(req.Private) > 0 {
+		if err := json.Unmarshal(req.Private, &private); err != nil {
+			resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+			return resp, nil
+		}
+	}
+	instanceState.Meta = private
+
+	var identityUpgraded bool
+
 	// TODO: is there a more elegant way to do this? this requires us to look for the identity schema block again
 	if req.CurrentIdentity != nil && req.CurrentIdentity.IdentityData != nil {
 
@@ -848,21 +1149,25 @@ func (s *GRPCProviderServer) ReadResource(ctx context.Context, req *tfprotov5.Re
 			resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
 			return resp, nil
 		}
+
+		// The resource state may have been upgraded to a new schema version
+		// by UpgradeResourceState, which cannot return identity data.
+		if res.UpgradeIdentityState != nil && recordedSchemaVersion(private) < res.SchemaVersion {
+			identityVal, err = s.upgradeIdentityState(ctx, identityVal, identityBlock, res)
+			if err != nil {
+				resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+				return resp, nil
+			}
+
+			identityUpgraded = true
+		}
+
 		// Step 2: Turn cty.Value into flatmap representation
 		identityAttrs := hcl2shim.FlatmapValueFromHCL2(identityVal)
 		// Step 3: Well, set it in the instanceState
 		instanceState.Identity = identityAttrs
 	}
 
-	private := make(map[string]interface{})
-	if len(req.Private) > 0 {
-		if err := json.Unmarshal(req.Private, &private); err != nil {
-			resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
-			return resp, nil
-		}
-	}
-	instanceState.Meta = private
-
 	pmSchemaBlock := s.getProviderMetaSchemaBlock()
 	if pmSchemaBlock != nil && req.ProviderMeta != nil {
 		providerSchemaVal, err := msgpack.Unmarshal(req.ProviderMeta.MsgPack, pmSchemaBlock.ImpliedType())
@@ -873,12 +1178,45 @@ func (s *GRPCProviderServer) ReadResource(ctx context.Context, req *tfprotov5.Re
 		instanceState.ProviderMeta = providerSchemaVal
 	}
 
+	deferralAllowed := req.ClientCapabilities != nil && req.ClientCapabilities.DeferralAllowed
+	resourceDeferred := &deferredResponse{}
+	ctx = withDeferredResponse(ctx, resourceDeferred)
+	ctx = s.withRateLimiter(ctx, req.TypeName, false, res)
+
 	newInstanceState, diags := res.RefreshWithoutUpgrade(ctx, instanceState, s.provider.Meta())
 	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, diags)
 	if diags.HasError() {
 		return resp, nil
 	}
 
+	if resourceDeferred.deferred != nil {
+		if !deferralAllowed {
+			resp.Diagnostics = append(resp.Diagnostics, &tfprotov5.Diagnostic{
+				Severity: tfprotov5.DiagnosticSeverityError,
+				Summary:  "Invalid Deferred Resource Response",
+				Detail: "Resource returned a deferred response but the Terraform request " +
+					"did not indicate support for deferred actions. This is an issue with the provider and should be reported to the provider developers.",
+			})
+			return resp, nil
+		}
+
+		logging.HelperSchemaDebug(
+			ctx,
+			"Resource returned a deferred response.",
+			map[string]interface{}{
+				logging.KeyDeferredReason: resourceDeferred.deferred.Reason.String(),
+			},
+		)
+
+		// Keep the current state, as for a provider deferred response
+		resp.NewState = req.CurrentState
+		resp.NewIdentity = req.CurrentIdentity
+		resp.Deferred = &tfprotov5.Deferred{
+			Reason: tfprotov5.DeferredReason(resourceDeferred.deferred.Reason),
+		}
+		return resp, nil
+	}
+
 	if newInstanceState == nil || newInstanceState.ID == "" {
 		// The old provider API used an empty id to signal that the remote
 		// object appears to have been deleted, but our new protocol expects
@@ -893,10 +1231,18 @@ func (s *GRPCProviderServer) ReadResource(ctx context.Context, req *tfprotov5.Re
 		return resp, nil
 	}
 
+	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, s.applyComputedAttributeProviders(ctx, req.TypeName, res, newInstanceState))
+
 	// helper/schema should always copy the ID over, but do it again just to be safe
 	newInstanceState.Attributes["id"] = newInstanceState.ID
 
-	newStateVal, err := hcl2shim.HCL2ValueFromFlatmap(newInstanceState.Attributes, schemaBlock.ImpliedType())
+	var newStateVal cty.Value
+	if !newInstanceState.RawState.IsNull() {
+		// The provider set the entire state with ResourceData.SetRawState
+		newStateVal, err = rawStateValue(newInstanceState, schemaBlock)
+	} else {
+		newStateVal, err = hcl2shim.HCL2ValueFromFlatmap(newInstanceState.Attributes, schemaBlock.ImpliedType())
+	}
 	if err != nil {
 		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
 		return resp, nil
@@ -904,6 +1250,11 @@ func (s *GRPCProviderServer) ReadResource(ctx context.Context, req *tfprotov5.Re
 
 	newStateVal = normalizeNullValues(newStateVal, stateVal, false)
 	newStateVal = copyTimeoutValues(newStateVal, stateVal)
+
+	if res.ErrorOnWriteOnlyInState {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, validateWriteOnlyStateNullValues(newStateVal, schemaBlock))
+	}
+
 	newStateVal = setWriteOnlyNullValues(newStateVal, schemaBlock)
 
 	newStateMP, err := msgpack.Marshal(newStateVal, schemaBlock.ImpliedType())
@@ -942,11 +1293,28 @@ func (s *GRPCProviderServer) ReadResource(ctx context.Context, req *tfprotov5.Re
 		}
 	}
 
+	// Record the schema version in the private state, so that
+	// UpgradeIdentityState is only called once for each schema version.
+	if identityUpgraded {
+		private["schema_version"] = strconv.Itoa(res.SchemaVersion)
+
+		resp.Private, err = json.Marshal(private)
+		if err != nil {
+			resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+			return resp, nil
+		}
+	}
+
 	return resp, nil
 }
 
 func (s *GRPCProviderServer) PlanResourceChange(ctx context.Context, req *tfprotov5.PlanResourceChangeRequest) (*tfprotov5.PlanResourceChangeResponse, error) {
 	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
+	ctx = withTerraformVersion(ctx, s.provider.TerraformVersion)
+	ctx = withClientCapabilities(ctx, ClientCapabilities{
+		DeferralAllowed: req.ClientCapabilities != nil && req.ClientCapabilities.DeferralAllowed,
+	})
 	resp := &tfprotov5.PlanResourceChangeResponse{}
 
 	res, ok := s.provider.ResourcesMap[req.TypeName]
@@ -967,21 +1335,23 @@ func (s *GRPCProviderServer) PlanResourceChange(ctx context.Context, req *tfprot
 		resp.UnsafeToUseLegacyTypeSystem = true
 	}
 
+	providerDeferred := s.provider.resourceDeferred(req.TypeName)
+
 	// Provider deferred response is present and the resource hasn't opted-in to CustomizeDiff being called, return early
 	// with proposed new state as a best effort for PlannedState.
-	if s.provider.providerDeferred != nil && !res.ResourceBehavior.ProviderDeferred.EnablePlanModification {
+	if providerDeferred != nil && !res.ResourceBehavior.ProviderDeferred.EnablePlanModification {
 		logging.HelperSchemaDebug(
 			ctx,
 			"Provider has deferred response configured, automatically returning deferred response.",
 			map[string]interface{}{
-				logging.KeyDeferredReason: s.provider.providerDeferred.Reason.String(),
+				logging.KeyDeferredReason: providerDeferred.Reason.String(),
 			},
 		)
 
 		resp.PlannedState = req.ProposedNewState
 		resp.PlannedPrivate = req.PriorPrivate
 		resp.Deferred = &tfprotov5.Deferred{
-			Reason: tfprotov5.DeferredReason(s.provider.providerDeferred.Reason),
+			Reason: tfprotov5.DeferredReason(providerDeferred.Reason),
 		}
 		resp.PlannedIdentity = req.PriorIdentity
 		return resp, nil
@@ -1045,7 +1415,7 @@ func (s *GRPCProviderServer) PlanResourceChange(ctx context.Context, req *tfprot
 
 	// Ensure there are no nulls that will cause helper/schema to panic.
 	if err := validateConfigNulls(ctx, proposedNewStateVal, nil); err != nil {
-		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, redactSensitiveSetElementPaths(err, schemaBlock))
 		return resp, nil
 	}
 
@@ -1079,6 +1449,10 @@ func (s *GRPCProviderServer) PlanResourceChange(ctx context.Context, req *tfprot
 		return resp, nil
 	}
 
+	if res.ValidateProposedState && !create && providerDeferred == nil && (diff == nil || !diff.RequiresNew()) {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, validateProposedComputedValues(priorStateVal, proposedNewStateVal, res.SchemaMap()))
+	}
+
 	// if this is a new instance, we need to make sure ID is going to be computed
 	if create {
 		if diff == nil {
@@ -1091,6 +1465,18 @@ func (s *GRPCProviderServer) PlanResourceChange(ctx context.Context, req *tfprot
 		// TODO: we could error here if a new Diff got no Identity set
 	}
 
+	if len(s.provider.ComputedAttributeProviders) > 0 {
+		if diff == nil {
+			diff = terraform.NewInstanceDiff()
+		}
+
+		diags := s.planComputedAttributeProviders(ctx, req.TypeName, res, priorState, diff)
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, diags)
+		if diags.HasError() {
+			return resp, nil
+		}
+	}
+
 	if diff == nil || (len(diff.Attributes) == 0 && len(diff.Identity) == 0) {
 		// schema.Provider.Diff returns nil if it ends up making a diff with no
 		// changes, but our new interface wants us to return an actual change
@@ -1135,6 +1521,20 @@ func (s *GRPCProviderServer) PlanResourceChange(ctx context.Context, req *tfprot
 
 	plannedStateVal = copyTimeoutValues(plannedStateVal, proposedNewStateVal)
 
+	// if this was creating the resource, we need to set any remaining computed
+	// fields
+	if create {
+		plannedStateVal = SetUnknowns(plannedStateVal, schemaBlock)
+	}
+
+	// call any attribute plan modifiers before checking for equivalence with
+	// the prior state, so normalized values do not produce a change
+	plannedStateVal, diags := modifyPlannedValues(ctx, priorStateVal, configVal, plannedStateVal, res.SchemaMap())
+	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, diags)
+	if diags.HasError() {
+		return resp, nil
+	}
+
 	// The old SDK code has some imprecisions that cause it to sometimes
 	// generate differences that the SDK itself does not consider significant
 	// but Terraform Core would. To avoid producing weird do-nothing diffs
@@ -1149,12 +1549,6 @@ func (s *GRPCProviderServer) PlanResourceChange(ctx context.Context, req *tfprot
 		forceNoChanges = true
 	}
 
-	// if this was creating the resource, we need to set any remaining computed
-	// fields
-	if create {
-		plannedStateVal = SetUnknowns(plannedStateVal, schemaBlock)
-	}
-
 	// Set any write-only attribute values to null
 	plannedStateVal = setWriteOnlyNullValues(plannedStateVal, schemaBlock)
 
@@ -1234,18 +1628,28 @@ func (s *GRPCProviderServer) PlanResourceChange(ctx context.Context, req *tfprot
 		resp.RequiresReplace = append(resp.RequiresReplace, pathToAttributePath(p))
 	}
 
+	// add any paths flagged via ResourceDiff.SetRequiresReplace, which are
+	// not reflected in the RequiresNew attribute diffs
+	if !forceNoChanges {
+		for _, p := range diff.RequiresReplace {
+			if !hasAttributePath(resp.RequiresReplace, p) {
+				resp.RequiresReplace = append(resp.RequiresReplace, p)
+			}
+		}
+	}
+
 	// Provider deferred response is present, add the deferred response alongside the provider-modified plan
-	if s.provider.providerDeferred != nil {
+	if providerDeferred != nil {
 		logging.HelperSchemaDebug(
 			ctx,
 			"Provider has deferred response configured, returning deferred response with modified plan.",
 			map[string]interface{}{
-				logging.KeyDeferredReason: s.provider.providerDeferred.Reason.String(),
+				logging.KeyDeferredReason: providerDeferred.Reason.String(),
 			},
 		)
 
 		resp.Deferred = &tfprotov5.Deferred{
-			Reason: tfprotov5.DeferredReason(s.provider.providerDeferred.Reason),
+			Reason: tfprotov5.DeferredReason(providerDeferred.Reason),
 		}
 	}
 
@@ -1281,11 +1685,29 @@ func (s *GRPCProviderServer) PlanResourceChange(ctx context.Context, req *tfprot
 
 func (s *GRPCProviderServer) ApplyResourceChange(ctx context.Context, req *tfprotov5.ApplyResourceChangeRequest) (*tfprotov5.ApplyResourceChangeResponse, error) {
 	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
+	ctx = withTerraformVersion(ctx, s.provider.TerraformVersion)
 	resp := &tfprotov5.ApplyResourceChangeResponse{
 		// Start with the existing state as a fallback
 		NewState: req.PriorState,
 	}
 
+	if s.applySem != nil {
+		select {
+		case s.applySem <- struct{}{}:
+			defer func() { <-s.applySem }()
+		case <-ctx.Done():
+			resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, diag.Diagnostics{
+				{
+					Severity: diag.Error,
+					Summary:  "Apply Wait Interrupted",
+					Detail:   fmt.Sprintf("The operation was interrupted while waiting for other resource changes to be applied: %s", ctx.Err()),
+				},
+			})
+			return resp, nil
+		}
+	}
+
 	res, ok := s.provider.ResourcesMap[req.TypeName]
 	if !ok {
 		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, fmt.Errorf("unknown resource type: %s", req.TypeName))
@@ -1425,6 +1847,7 @@ func (s *GRPCProviderServer) ApplyResourceChange(ctx context.Context, req *tfpro
 		priorState.ProviderMeta = providerSchemaVal
 	}
 
+	ctx = s.withRateLimiter(ctx, req.TypeName, false, res)
 	newInstanceState, diags := res.Apply(ctx, priorState, diff, s.provider.Meta())
 	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, diags)
 
@@ -1445,9 +1868,16 @@ func (s *GRPCProviderServer) ApplyResourceChange(ctx context.Context, req *tfpro
 		return resp, nil
 	}
 
+	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, s.applyComputedAttributeProviders(ctx, req.TypeName, res, newInstanceState))
+
 	// We keep the null val if we destroyed the resource, otherwise build the
 	// entire object, even if the new state was nil.
-	newStateVal, err = StateValueFromInstanceState(newInstanceState, schemaBlock.ImpliedType())
+	if !newInstanceState.RawState.IsNull() {
+		// The provider set the entire state with ResourceData.SetRawState
+		newStateVal, err = rawStateValue(newInstanceState, schemaBlock)
+	} else {
+		newStateVal, err = StateValueFromInstanceState(newInstanceState, schemaBlock.ImpliedType())
+	}
 	if err != nil {
 		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
 		return resp, nil
@@ -1457,6 +1887,10 @@ func (s *GRPCProviderServer) ApplyResourceChange(ctx context.Context, req *tfpro
 
 	newStateVal = copyTimeoutValues(newStateVal, plannedStateVal)
 
+	if res.ErrorOnWriteOnlyInState {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, validateWriteOnlyStateNullValues(newStateVal, schemaBlock))
+	}
+
 	newStateVal = setWriteOnlyNullValues(newStateVal, schemaBlock)
 
 	newStateMP, err := msgpack.Marshal(newStateVal, schemaBlock.ImpliedType())
@@ -1518,18 +1952,25 @@ func (s *GRPCProviderServer) ApplyResourceChange(ctx context.Context, req *tfpro
 
 func (s *GRPCProviderServer) ImportResourceState(ctx context.Context, req *tfprotov5.ImportResourceStateRequest) (*tfprotov5.ImportResourceStateResponse, error) {
 	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
+	ctx = withTerraformVersion(ctx, s.provider.TerraformVersion)
+	ctx = withClientCapabilities(ctx, ClientCapabilities{
+		DeferralAllowed: req.ClientCapabilities != nil && req.ClientCapabilities.DeferralAllowed,
+	})
 	resp := &tfprotov5.ImportResourceStateResponse{}
 
 	info := &terraform.InstanceInfo{
 		Type: req.TypeName,
 	}
 
-	if s.provider.providerDeferred != nil {
+	providerDeferred := s.provider.resourceDeferred(req.TypeName)
+
+	if providerDeferred != nil {
 		logging.HelperSchemaDebug(
 			ctx,
 			"Provider has deferred response configured, automatically returning deferred response.",
 			map[string]interface{}{
-				logging.KeyDeferredReason: s.provider.providerDeferred.Reason.String(),
+				logging.KeyDeferredReason: providerDeferred.Reason.String(),
 			},
 		)
 
@@ -1560,7 +2001,7 @@ func (s *GRPCProviderServer) ImportResourceState(ctx context.Context, req *tfpro
 		}
 
 		resp.Deferred = &tfprotov5.Deferred{
-			Reason: tfprotov5.DeferredReason(s.provider.providerDeferred.Reason),
+			Reason: tfprotov5.DeferredReason(providerDeferred.Reason),
 		}
 
 		return resp, nil
@@ -1630,6 +2071,32 @@ func (s *GRPCProviderServer) ImportResourceState(ctx context.Context, req *tfpro
 			Private: meta,
 		}
 
+		if res, ok := s.provider.ResourcesMap[resourceType]; ok && res.Identity != nil && is.Identity != nil {
+			identityBlock, err := s.getResourceIdentitySchemaBlock(resourceType)
+			if err != nil {
+				resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+				return resp, nil
+			}
+
+			newIdentityVal, err := hcl2shim.HCL2ValueFromFlatmap(is.Identity, identityBlock.ImpliedType())
+			if err != nil {
+				resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+				return resp, nil
+			}
+
+			newIdentityMP, err := msgpack.Marshal(newIdentityVal, identityBlock.ImpliedType())
+			if err != nil {
+				resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+				return resp, nil
+			}
+
+			importedResource.Identity = &tfprotov5.ResourceIdentityData{
+				IdentityData: &tfprotov5.DynamicValue{
+					MsgPack: newIdentityMP,
+				},
+			}
+		}
+
 		resp.ImportedResources = append(resp.ImportedResources, importedResource)
 	}
 
@@ -1642,12 +2109,12 @@ func (s *GRPCProviderServer) MoveResourceState(ctx context.Context, req *tfproto
 	}
 
 	ctx = logging.InitContext(ctx)
-
-	logging.HelperSchemaTrace(ctx, "Returning error for MoveResourceState")
+	ctx = s.withRawRequest(ctx, req)
+	ctx = withTerraformVersion(ctx, s.provider.TerraformVersion)
 
 	resp := &tfprotov5.MoveResourceStateResponse{}
 
-	_, ok := s.provider.ResourcesMap[req.TargetTypeName]
+	res, ok := s.provider.ResourcesMap[req.TargetTypeName]
 
 	if !ok {
 		resp.Diagnostics = []*tfprotov5.Diagnostic{
@@ -1661,29 +2128,207 @@ func (s *GRPCProviderServer) MoveResourceState(ctx context.Context, req *tfproto
 		return resp, nil
 	}
 
-	resp.Diagnostics = []*tfprotov5.Diagnostic{
-		{
-			Severity: tfprotov5.DiagnosticSeverityError,
-			Summary:  "Move Resource State Not Supported",
-			Detail:   fmt.Sprintf("The %q resource type does not support moving resource state across resource types.", req.TargetTypeName),
-		},
-	}
+	mover := res.stateMover(req.SourceTypeName)
 
-	return resp, nil
-}
+	if mover == nil {
+		logging.HelperSchemaTrace(ctx, "Returning error for MoveResourceState")
 
-func (s *GRPCProviderServer) ReadDataSource(ctx context.Context, req *tfprotov5.ReadDataSourceRequest) (*tfprotov5.ReadDataSourceResponse, error) {
-	ctx = logging.InitContext(ctx)
-	resp := &tfprotov5.ReadDataSourceResponse{}
+		resp.Diagnostics = []*tfprotov5.Diagnostic{
+			{
+				Severity: tfprotov5.DiagnosticSeverityError,
+				Summary:  "Move Resource State Not Supported",
+				Detail:   fmt.Sprintf("The %q resource type does not support moving resource state across resource types.", req.TargetTypeName),
+			},
+		}
 
-	schemaBlock := s.getDatasourceSchemaBlock(req.TypeName)
+		return resp, nil
+	}
 
-	if s.provider.providerDeferred != nil {
+	schemaBlock := s.getResourceSchemaBlock(req.TargetTypeName)
+
+	rawState := map[string]interface{}{}
+
+	if req.SourceState != nil {
+		switch {
+		// There should never be both a JSON and Flatmap state in the request.
+		case len(req.SourceState.Flatmap) > 0:
+			for k, v := range req.SourceState.Flatmap {
+				rawState[k] = v
+			}
+		case len(req.SourceState.JSON) > 0:
+			var err error
+			if res.UseJSONNumber {
+				err = unmarshalJSON(req.SourceState.JSON, &rawState)
+			} else {
+				err = json.Unmarshal(req.SourceState.JSON, &rawState)
+			}
+			if err != nil {
+				resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+				return resp, nil
+			}
+		}
+	}
+
+	moveReq := MoveStateRequest{
+		SourceProviderAddress: req.SourceProviderAddress,
+		SourceTypeName:        req.SourceTypeName,
+		SourceSchemaVersion:   req.SourceSchemaVersion,
+		SourceRawState:        rawState,
+		Meta:                  s.provider.Meta(),
+	}
+
+	if mover.SourceSchema != nil {
+		sourceState, err := s.moveSourceStateValue(ctx, req.SourceState, mover.SourceSchema, res.UseJSONNumber)
+		if err != nil {
+			resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+			return resp, nil
+		}
+
+		moveReq.SourceState = sourceState
+	}
+
+	if req.SourceIdentity != nil && len(req.SourceIdentity.JSON) > 0 {
+		if err := json.Unmarshal(req.SourceIdentity.JSON, &moveReq.SourceRawIdentity); err != nil {
+			resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+			return resp, nil
+		}
+	}
+
+	moveResp := &MoveStateResponse{}
+
+	logging.HelperSchemaTrace(ctx, "Calling downstream")
+	mover.StateMover(ctx, moveReq, moveResp)
+	logging.HelperSchemaTrace(ctx, "Called downstream")
+
+	jsonMap, identityMap, diags := moveResp.TargetState, moveResp.TargetIdentity, moveResp.Diagnostics
+
+	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, diags)
+	if diags.HasError() {
+		return resp, nil
+	}
+
+	// The provider isn't required to clean out removed fields
+	s.removeAttributes(ctx, jsonMap, schemaBlock.ImpliedType())
+
+	// now we need to turn the state into the default json representation, so
+	// that it can be re-decoded using the actual schema.
+	val, err := JSONMapToStateValue(jsonMap, schemaBlock)
+	if err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		return resp, nil
+	}
+
+	// Now we need to make sure blocks are represented correctly, which means
+	// that missing blocks are empty collections, rather than null.
+	// First we need to CoerceValue to ensure that all object types match.
+	val, err = schemaBlock.CoerceValue(val)
+	if err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		return resp, nil
+	}
+	// Normalize the value and fill in any missing blocks.
+	val = objchange.NormalizeObjectFromLegacySDK(val, schemaBlock)
+
+	// Set any write-only attribute values to null
+	val = setWriteOnlyNullValues(val, schemaBlock)
+
+	// encode the final state to the expected msgpack format
+	newStateMP, err := msgpack.Marshal(val, schemaBlock.ImpliedType())
+	if err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		return resp, nil
+	}
+
+	resp.TargetState = &tfprotov5.DynamicValue{MsgPack: newStateMP}
+	resp.TargetPrivate = req.SourcePrivate
+
+	if identityMap != nil && res.Identity != nil {
+		identityBlock, err := s.getResourceIdentitySchemaBlock(req.TargetTypeName)
+		if err != nil {
+			resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, fmt.Errorf("getting identity schema failed for resource '%s': %w", req.TargetTypeName, err))
+			return resp, nil
+		}
+
+		identityVal, err := JSONMapToStateValue(identityMap, identityBlock)
+		if err != nil {
+			resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+			return resp, nil
+		}
+
+		identityMP, err := msgpack.Marshal(identityVal, identityBlock.ImpliedType())
+		if err != nil {
+			resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+			return resp, nil
+		}
+
+		resp.TargetIdentity = &tfprotov5.ResourceIdentityData{
+			IdentityData: &tfprotov5.DynamicValue{MsgPack: identityMP},
+		}
+	}
+
+	return resp, nil
+}
+
+// moveSourceStateValue decodes the source state of a MoveResourceState
+// request with the SourceSchema of a StateMover.
+func (s *GRPCProviderServer) moveSourceStateValue(ctx context.Context, rawState *tfprotov5.RawState, sourceSchema map[string]*Schema, useJSONNumber bool) (cty.Value, error) {
+	schemaBlock := (&Resource{Schema: sourceSchema}).CoreConfigSchema()
+
+	var val cty.Value
+	var err error
+
+	switch {
+	case rawState == nil:
+		return cty.NullVal(schemaBlock.ImpliedType()), nil
+	case len(rawState.Flatmap) > 0:
+		val, err = hcl2shim.HCL2ValueFromFlatmap(rawState.Flatmap, schemaBlock.ImpliedType())
+	default:
+		jsonMap := map[string]interface{}{}
+
+		if len(rawState.JSON) > 0 {
+			if useJSONNumber {
+				err = unmarshalJSON(rawState.JSON, &jsonMap)
+			} else {
+				err = json.Unmarshal(rawState.JSON, &jsonMap)
+			}
+			if err != nil {
+				return cty.NilVal, err
+			}
+		}
+
+		// The source state may contain attributes which are not in the
+		// source schema.
+		s.removeAttributes(ctx, jsonMap, schemaBlock.ImpliedType())
+
+		val, err = JSONMapToStateValue(jsonMap, schemaBlock)
+	}
+
+	if err != nil {
+		return cty.NilVal, err
+	}
+
+	return schemaBlock.CoerceValue(val)
+}
+
+func (s *GRPCProviderServer) ReadDataSource(ctx context.Context, req *tfprotov5.ReadDataSourceRequest) (*tfprotov5.ReadDataSourceResponse, error) {
+	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
+	ctx = withTerraformVersion(ctx, s.provider.TerraformVersion)
+	ctx = withClientCapabilities(ctx, ClientCapabilities{
+		DeferralAllowed: req.ClientCapabilities != nil && req.ClientCapabilities.DeferralAllowed,
+	})
+	resp := &tfprotov5.ReadDataSourceResponse{}
+
+	schemaBlock := s.getDatasourceSchemaBlock(req.TypeName)
+
+	providerDeferred := s.provider.dataSourceDeferred(req.TypeName)
+
+	if providerDeferred != nil {
 		logging.HelperSchemaDebug(
 			ctx,
 			"Provider has deferred response configured, automatically returning deferred response.",
 			map[string]interface{}{
-				logging.KeyDeferredReason: s.provider.providerDeferred.Reason.String(),
+				logging.KeyDeferredReason: providerDeferred.Reason.String(),
 			},
 		)
 
@@ -1698,167 +2343,717 @@ func (s *GRPCProviderServer) ReadDataSource(ctx context.Context, req *tfprotov5.
 		resp.State = &tfprotov5.DynamicValue{
 			MsgPack: unknownStateMp,
 		}
-		resp.Deferred = &tfprotov5.Deferred{
-			Reason: tfprotov5.DeferredReason(s.provider.providerDeferred.Reason),
+		resp.Deferred = &tfprotov5.Deferred{
+			Reason: tfprotov5.DeferredReason(providerDeferred.Reason),
+		}
+		return resp, nil
+	}
+
+	configVal, err := msgpack.Unmarshal(req.Config.MsgPack, schemaBlock.ImpliedType())
+	if err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		return resp, nil
+	}
+
+	// Ensure there are no nulls that will cause helper/schema to panic.
+	if err := validateConfigNulls(ctx, configVal, nil); err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, redactSensitiveSetElementPaths(err, schemaBlock))
+		return resp, nil
+	}
+
+	config := terraform.NewResourceConfigShimmed(configVal, schemaBlock)
+
+	// we need to still build the diff separately with the Read method to match
+	// the old behavior
+	res, ok := s.provider.DataSourcesMap[req.TypeName]
+	if !ok {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, fmt.Errorf("unknown data source: %s", req.TypeName))
+		return resp, nil
+	}
+	diff, err := res.Diff(ctx, nil, config, s.provider.Meta())
+	if err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		return resp, nil
+	}
+
+	// Not setting RawConfig here is okay, as ResourceData.GetRawConfig()
+	// will return a NullVal of the schema if there is no InstanceDiff.
+	if diff != nil {
+		diff.RawConfig = configVal
+	}
+
+	deferralAllowed := req.ClientCapabilities != nil && req.ClientCapabilities.DeferralAllowed
+	dataSourceDeferred := &deferredResponse{}
+	ctx = withDeferredResponse(ctx, dataSourceDeferred)
+	ctx = s.withRateLimiter(ctx, req.TypeName, true, res)
+
+	// now we can get the new complete data source
+	newInstanceState, diags := res.ReadDataApply(ctx, diff, s.provider.Meta())
+	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, diags)
+	if diags.HasError() {
+		return resp, nil
+	}
+
+	if dataSourceDeferred.deferred != nil {
+		if !deferralAllowed {
+			resp.Diagnostics = append(resp.Diagnostics, &tfprotov5.Diagnostic{
+				Severity: tfprotov5.DiagnosticSeverityError,
+				Summary:  "Invalid Deferred Data Source Response",
+				Detail: "Data source returned a deferred response but the Terraform request " +
+					"did not indicate support for deferred actions. This is an issue with the provider and should be reported to the provider developers.",
+			})
+			return resp, nil
+		}
+
+		logging.HelperSchemaDebug(
+			ctx,
+			"Data source returned a deferred response.",
+			map[string]interface{}{
+				logging.KeyDeferredReason: dataSourceDeferred.deferred.Reason.String(),
+			},
+		)
+
+		// Send an unknown value for the data source
+		unknownStateMp, err := msgpack.Marshal(cty.UnknownVal(schemaBlock.ImpliedType()), schemaBlock.ImpliedType())
+		if err != nil {
+			resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+			return resp, nil
+		}
+
+		resp.State = &tfprotov5.DynamicValue{
+			MsgPack: unknownStateMp,
+		}
+		resp.Deferred = &tfprotov5.Deferred{
+			Reason: tfprotov5.DeferredReason(dataSourceDeferred.deferred.Reason),
+		}
+		return resp, nil
+	}
+
+	newStateVal, err := StateValueFromInstanceState(newInstanceState, schemaBlock.ImpliedType())
+	if err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		return resp, nil
+	}
+
+	newStateVal = copyTimeoutValues(newStateVal, configVal)
+
+	newStateMP, err := msgpack.Marshal(newStateVal, schemaBlock.ImpliedType())
+	if err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		return resp, nil
+	}
+	resp.State = &tfprotov5.DynamicValue{
+		MsgPack: newStateMP,
+	}
+	return resp, nil
+}
+
+func (s *GRPCProviderServer) CallFunction(ctx context.Context, req *tfprotov5.CallFunctionRequest) (*tfprotov5.CallFunctionResponse, error) {
+	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
+	ctx = withTerraformVersion(ctx, s.provider.TerraformVersion)
+
+	resp := &tfprotov5.CallFunctionResponse{}
+
+	f, ok := s.provider.Functions[req.Name]
+	if !ok || f == nil {
+		logging.HelperSchemaTrace(ctx, "Returning error for provider function call")
+
+		resp.Error = &tfprotov5.FunctionError{
+			Text: fmt.Sprintf("Function Not Found: No function named %q was found in the provider.", req.Name),
+		}
+
+		return resp, nil
+	}
+
+	if len(req.Arguments) != len(f.Parameters) {
+		resp.Error = &tfprotov5.FunctionError{
+			Text: fmt.Sprintf("Invalid Function Arguments: Function %q expects %d arguments, got %d.", req.Name, len(f.Parameters), len(req.Arguments)),
+		}
+
+		return resp, nil
+	}
+
+	args := make([]cty.Value, len(f.Parameters))
+	for i, param := range f.Parameters {
+		argIndex := int64(i)
+
+		var arg []byte
+		if req.Arguments[i] != nil {
+			arg = req.Arguments[i].MsgPack
+		}
+
+		val, err := msgpack.Unmarshal(arg, param.Type)
+		if err != nil {
+			resp.Error = &tfprotov5.FunctionError{
+				Text:             fmt.Sprintf("Invalid value for %q parameter: %s.", param.Name, err),
+				FunctionArgument: &argIndex,
+			}
+
+			return resp, nil
+		}
+
+		if param.ValidateFunc != nil {
+			if diags := param.ValidateFunc(ctx, val); diags.HasError() {
+				resp.Error = &tfprotov5.FunctionError{
+					Text:             functionErrorText(diags),
+					FunctionArgument: &argIndex,
+				}
+
+				return resp, nil
+			}
+		}
+
+		args[i] = val
+	}
+
+	logging.HelperSchemaTrace(ctx, "Calling downstream")
+	result, diags := f.Run(ctx, args)
+	logging.HelperSchemaTrace(ctx, "Called downstream")
+
+	if diags.HasError() {
+		resp.Error = &tfprotov5.FunctionError{
+			Text: functionErrorText(diags),
+		}
+
+		return resp, nil
+	}
+
+	resultVal, err := f.resultValue(result)
+	if err != nil {
+		resp.Error = &tfprotov5.FunctionError{
+			Text: fmt.Sprintf("Invalid Function Result: The function result does not match the return type: %s. "+
+				"This is always an issue in the provider and should be reported to the provider developers.", tfdiags.FormatError(err)),
+		}
+
+		return resp, nil
+	}
+
+	resultMP, err := msgpack.Marshal(resultVal, f.Return.Type)
+	if err != nil {
+		resp.Error = &tfprotov5.FunctionError{
+			Text: err.Error(),
+		}
+
+		return resp, nil
+	}
+
+	resp.Result = &tfprotov5.DynamicValue{
+		MsgPack: resultMP,
+	}
+
+	return resp, nil
+}
+
+func (s *GRPCProviderServer) GetFunctions(ctx context.Context, req *tfprotov5.GetFunctionsRequest) (*tfprotov5.GetFunctionsResponse, error) {
+	ctx = logging.InitContext(ctx)
+
+	logging.HelperSchemaTrace(ctx, "Getting provider functions")
+
+	resp := &tfprotov5.GetFunctionsResponse{}
+
+	functions, err := s.getFunctions()
+	if err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		return resp, nil
+	}
+
+	resp.Functions = functions
+
+	return resp, nil
+}
+
+// getFunctions returns the protocol definitions of the provider-defined
+// functions.
+func (s *GRPCProviderServer) getFunctions() (map[string]*tfprotov5.Function, error) {
+	functions := make(map[string]*tfprotov5.Function, len(s.provider.Functions))
+
+	for name, f := range s.provider.Functions {
+		if err := f.InternalValidate(); err != nil {
+			return nil, fmt.Errorf("function %s: %w", name, err)
+		}
+
+		returnType, err := convert.TFTypeFromCtyType(f.Return.Type)
+		if err != nil {
+			return nil, fmt.Errorf("function %s return: %w", name, err)
+		}
+
+		protoFunction := &tfprotov5.Function{
+			Parameters:         make([]*tfprotov5.FunctionParameter, 0, len(f.Parameters)),
+			Return:             &tfprotov5.FunctionReturn{Type: returnType},
+			Summary:            f.Summary,
+			Description:        f.Description,
+			DescriptionKind:    tfprotov5.StringKindPlain,
+			DeprecationMessage: f.DeprecationMessage,
+		}
+
+		for _, param := range f.Parameters {
+			paramType, err := convert.TFTypeFromCtyType(param.Type)
+			if err != nil {
+				return nil, fmt.Errorf("function %s parameter %q: %w", name, param.Name, err)
+			}
+
+			protoFunction.Parameters = append(protoFunction.Parameters, &tfprotov5.FunctionParameter{
+				AllowNullValue:     param.AllowNullValue,
+				AllowUnknownValues: param.AllowUnknownValues,
+				Description:        param.Description,
+				DescriptionKind:    tfprotov5.StringKindPlain,
+				Name:               param.Name,
+				Type:               paramType,
+			})
+		}
+
+		functions[name] = protoFunction
+	}
+
+	return functions, nil
+}
+
+func (s *GRPCProviderServer) ValidateEphemeralResourceConfig(ctx context.Context, req *tfprotov5.ValidateEphemeralResourceConfigRequest) (*tfprotov5.ValidateEphemeralResourceConfigResponse, error) {
+	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
+	resp := &tfprotov5.ValidateEphemeralResourceConfigResponse{}
+
+	res, ok := s.provider.EphemeralResourcesMap[req.TypeName]
+	if !ok {
+		resp.Diagnostics = unknownEphemeralResourceDiagnostics(req.TypeName)
+		return resp, nil
+	}
+
+	schemaBlock := res.CoreConfigSchema()
+
+	configVal, err := msgpack.Unmarshal(req.Config.MsgPack, schemaBlock.ImpliedType())
+	if err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		return resp, nil
+	}
+
+	// Ensure there are no nulls that will cause helper/schema to panic.
+	if err := validateConfigNulls(ctx, configVal, nil); err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, redactSensitiveSetElementPaths(err, schemaBlock))
+		return resp, nil
+	}
+
+	config := terraform.NewResourceConfigShimmed(configVal, schemaBlock)
+
+	// The raw configuration is used to build the attribute paths of
+	// diagnostics for set elements, which are addressed by value.
+	config.CtyValue = configVal
+
+	logging.HelperSchemaTrace(ctx, "Calling downstream")
+	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, s.provider.ValidateEphemeralResource(req.TypeName, config))
+	logging.HelperSchemaTrace(ctx, "Called downstream")
+
+	return resp, nil
+}
+
+func (s *GRPCProviderServer) OpenEphemeralResource(ctx context.Context, req *tfprotov5.OpenEphemeralResourceRequest) (*tfprotov5.OpenEphemeralResourceResponse, error) {
+	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
+	ctx = withTerraformVersion(ctx, s.provider.TerraformVersion)
+	ctx = withClientCapabilities(ctx, ClientCapabilities{
+		DeferralAllowed: req.ClientCapabilities != nil && req.ClientCapabilities.DeferralAllowed,
+	})
+	resp := &tfprotov5.OpenEphemeralResourceResponse{}
+
+	res, ok := s.provider.EphemeralResourcesMap[req.TypeName]
+	if !ok {
+		resp.Diagnostics = unknownEphemeralResourceDiagnostics(req.TypeName)
+		return resp, nil
+	}
+
+	schemaBlock := res.CoreConfigSchema()
+
+	configVal, err := msgpack.Unmarshal(req.Config.MsgPack, schemaBlock.ImpliedType())
+	if err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		return resp, nil
+	}
+
+	// Ensure there are no nulls that will cause helper/schema to panic.
+	if err := validateConfigNulls(ctx, configVal, nil); err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, redactSensitiveSetElementPaths(err, schemaBlock))
+		return resp, nil
+	}
+
+	config := terraform.NewResourceConfigShimmed(configVal, schemaBlock)
+
+	// Ephemeral resources are always built completely from the
+	// configuration, the same as data sources.
+	sm := schemaMap(res.Schema)
+	diff, err := sm.Diff(ctx, nil, config, nil, s.provider.Meta(), false)
+	if err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		return resp, nil
+	}
+
+	if diff != nil {
+		diff.RawConfig = configVal
+	}
+
+	data, err := sm.Data(nil, diff)
+	if err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		return resp, nil
+	}
+
+	openReq := EphemeralOpenRequest{
+		ResourceData: data,
+		Meta:         s.provider.Meta(),
+	}
+	openResp := &EphemeralOpenResponse{}
+
+	logging.HelperSchemaTrace(ctx, "Calling downstream")
+	res.OpenContext(ctx, openReq, openResp)
+	logging.HelperSchemaTrace(ctx, "Called downstream")
+
+	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, openResp.Diagnostics)
+	if openResp.Diagnostics.HasError() {
+		return resp, nil
+	}
+
+	// The result is built from the ResourceData state, which requires an ID.
+	data.SetId(ephemeralResourceID)
+
+	resultVal, err := StateValueFromInstanceState(data.State(), schemaBlock.ImpliedType())
+	if err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		return resp, nil
+	}
+
+	resultMP, err := msgpack.Marshal(resultVal, schemaBlock.ImpliedType())
+	if err != nil {
+		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
+		return resp, nil
+	}
+
+	resp.Result = &tfprotov5.DynamicValue{
+		MsgPack: resultMP,
+	}
+	resp.Private = openResp.Private
+	resp.RenewAt = openResp.RenewAt
+
+	return resp, nil
+}
+
+func (s *GRPCProviderServer) RenewEphemeralResource(ctx context.Context, req *tfprotov5.RenewEphemeralResourceRequest) (*tfprotov5.RenewEphemeralResourceResponse, error) {
+	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
+	ctx = withTerraformVersion(ctx, s.provider.TerraformVersion)
+	resp := &tfprotov5.RenewEphemeralResourceResponse{
+		Private: req.Private,
+	}
+
+	res, ok := s.provider.EphemeralResourcesMap[req.TypeName]
+	if !ok {
+		resp.Diagnostics = unknownEphemeralResourceDiagnostics(req.TypeName)
+		return resp, nil
+	}
+
+	if res.RenewContext == nil {
+		return resp, nil
+	}
+
+	renewReq := EphemeralRenewRequest{
+		Private: req.Private,
+		Meta:    s.provider.Meta(),
+	}
+	renewResp := &EphemeralRenewResponse{
+		Private: req.Private,
+	}
+
+	logging.HelperSchemaTrace(ctx, "Calling downstream")
+	res.RenewContext(ctx, renewReq, renewResp)
+	logging.HelperSchemaTrace(ctx, "Called downstream")
+
+	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, renewResp.Diagnostics)
+	if renewResp.Diagnostics.HasError() {
+		return resp, nil
+	}
+
+	resp.Private = renewResp.Private
+	resp.RenewAt = renewResp.RenewAt
+
+	return resp, nil
+}
+
+func (s *GRPCProviderServer) CloseEphemeralResource(ctx context.Context, req *tfprotov5.CloseEphemeralResourceRequest) (*tfprotov5.CloseEphemeralResourceResponse, error) {
+	ctx = logging.InitContext(ctx)
+	ctx = s.withRawRequest(ctx, req)
+	ctx = withTerraformVersion(ctx, s.provider.TerraformVersion)
+	resp := &tfprotov5.CloseEphemeralResourceResponse{}
+
+	res, ok := s.provider.EphemeralResourcesMap[req.TypeName]
+	if !ok {
+		resp.Diagnostics = unknownEphemeralResourceDiagnostics(req.TypeName)
+		return resp, nil
+	}
+
+	if res.CloseContext == nil {
+		return resp, nil
+	}
+
+	closeReq := EphemeralCloseRequest{
+		Private: req.Private,
+		Meta:    s.provider.Meta(),
+	}
+	closeResp := &EphemeralCloseResponse{}
+
+	logging.HelperSchemaTrace(ctx, "Calling downstream")
+	res.CloseContext(ctx, closeReq, closeResp)
+	logging.HelperSchemaTrace(ctx, "Called downstream")
+
+	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, closeResp.Diagnostics)
+
+	return resp, nil
+}
+
+// unknownEphemeralResourceDiagnostics returns the error diagnostics for an
+// ephemeral resource type which is not supported by the provider.
+func unknownEphemeralResourceDiagnostics(typeName string) []*tfprotov5.Diagnostic {
+	return []*tfprotov5.Diagnostic{
+		{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  "Unknown Ephemeral Resource Type",
+			Detail:   fmt.Sprintf("The %q ephemeral resource type is not supported by this provider.", typeName),
+		},
+	}
+}
+
+// applyComputedAttributeProviders overwrites the Computed attributes of the
+// given state which have a matching Provider.ComputedAttributeProviders
+// function with the value returned by that function.
+func (s *GRPCProviderServer) applyComputedAttributeProviders(ctx context.Context, typeName string, res *Resource, state *terraform.InstanceState) diag.Diagnostics {
+	if len(s.provider.ComputedAttributeProviders) == 0 || state == nil || state.Attributes == nil {
+		return nil
+	}
+
+	sm := schemaMap(res.SchemaMap())
+
+	var diags diag.Diagnostics
+
+	for _, name := range s.computedAttributeProviderNames(sm) {
+		d, err := sm.Data(state, nil)
+		if err != nil {
+			return append(diags, diag.FromErr(err)...)
+		}
+
+		attrs, valDiags := s.computedAttributeProviderValue(ctx, typeName, sm, name, d)
+		diags = append(diags, valDiags...)
+		if valDiags.HasError() {
+			continue
+		}
+
+		for k := range state.Attributes {
+			if k == name || strings.HasPrefix(k, name+".") {
+				delete(state.Attributes, k)
+			}
+		}
+		for k, v := range attrs {
+			state.Attributes[k] = v
 		}
-		return resp, nil
-	}
 
-	configVal, err := msgpack.Unmarshal(req.Config.MsgPack, schemaBlock.ImpliedType())
-	if err != nil {
-		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
-		return resp, nil
+		// A value given to ResourceData.SetRawState no longer reflects the
+		// complete state.
+		state.RawState = cty.NilVal
 	}
 
-	// Ensure there are no nulls that will cause helper/schema to panic.
-	if err := validateConfigNulls(ctx, configVal, nil); err != nil {
-		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
-		return resp, nil
+	return diags
+}
+
+// planComputedAttributeProviders calls the Provider.ComputedAttributeProviders
+// functions with the planned resource data, so the value applied later is
+// consistent with the plan. The attribute keeps its prior value if the
+// function returns that same value and the plan has no other unknown values,
+// otherwise it is planned as unknown.
+func (s *GRPCProviderServer) planComputedAttributeProviders(ctx context.Context, typeName string, res *Resource, priorState *terraform.InstanceState, diff *terraform.InstanceDiff) diag.Diagnostics {
+	if len(s.provider.ComputedAttributeProviders) == 0 {
+		return nil
 	}
 
-	config := terraform.NewResourceConfigShimmed(configVal, schemaBlock)
+	sm := schemaMap(res.SchemaMap())
+	names := s.computedAttributeProviderNames(sm)
 
-	// we need to still build the diff separately with the Read method to match
-	// the old behavior
-	res, ok := s.provider.DataSourcesMap[req.TypeName]
-	if !ok {
-		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, fmt.Errorf("unknown data source: %s", req.TypeName))
-		return resp, nil
-	}
-	diff, err := res.Diff(ctx, nil, config, s.provider.Meta())
-	if err != nil {
-		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
-		return resp, nil
-	}
+	// Values which are not known during plan, including the id of a new
+	// resource, may change the result of the functions during apply.
+	hasUnknowns := false
+	for k, attr := range diff.Attributes {
+		if attr == nil || !attr.NewComputed {
+			continue
+		}
 
-	// Not setting RawConfig here is okay, as ResourceData.GetRawConfig()
-	// will return a NullVal of the schema if there is no InstanceDiff.
-	if diff != nil {
-		diff.RawConfig = configVal
-	}
+		provided := false
+		for _, name := range names {
+			if k == name || strings.HasPrefix(k, name+".") {
+				provided = true
+				break
+			}
+		}
 
-	// now we can get the new complete data source
-	newInstanceState, diags := res.ReadDataApply(ctx, diff, s.provider.Meta())
-	resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, diags)
-	if diags.HasError() {
-		return resp, nil
+		if !provided {
+			hasUnknowns = true
+			break
+		}
 	}
 
-	newStateVal, err := StateValueFromInstanceState(newInstanceState, schemaBlock.ImpliedType())
-	if err != nil {
-		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
-		return resp, nil
+	var priorAttrs map[string]string
+	if priorState != nil {
+		priorAttrs = priorState.Attributes
 	}
 
-	newStateVal = copyTimeoutValues(newStateVal, configVal)
-
-	newStateMP, err := msgpack.Marshal(newStateVal, schemaBlock.ImpliedType())
-	if err != nil {
-		resp.Diagnostics = convert.AppendProtoDiag(ctx, resp.Diagnostics, err)
-		return resp, nil
-	}
-	resp.State = &tfprotov5.DynamicValue{
-		MsgPack: newStateMP,
-	}
-	return resp, nil
-}
+	var diags diag.Diagnostics
 
-func (s *GRPCProviderServer) CallFunction(ctx context.Context, req *tfprotov5.CallFunctionRequest) (*tfprotov5.CallFunctionResponse, error) {
-	ctx = logging.InitContext(ctx)
+	for _, name := range names {
+		d, err := sm.Data(priorState, diff)
+		if err != nil {
+			return append(diags, diag.FromErr(err)...)
+		}
 
-	logging.HelperSchemaTrace(ctx, "Returning error for provider function call")
+		attrs, valDiags := s.computedAttributeProviderValue(ctx, typeName, sm, name, d)
+		diags = append(diags, valDiags...)
+		if valDiags.HasError() {
+			continue
+		}
 
-	resp := &tfprotov5.CallFunctionResponse{
-		Error: &tfprotov5.FunctionError{
-			Text: fmt.Sprintf("Function Not Found: No function named %q was found in the provider.", req.Name),
-		},
-	}
+		priorValue := make(map[string]string)
+		for k, v := range priorAttrs {
+			if k == name || strings.HasPrefix(k, name+".") {
+				priorValue[k] = v
+			}
+		}
 
-	return resp, nil
-}
+		for k := range diff.Attributes {
+			if k == name || strings.HasPrefix(k, name+".") {
+				delete(diff.Attributes, k)
+			}
+		}
 
-func (s *GRPCProviderServer) GetFunctions(ctx context.Context, req *tfprotov5.GetFunctionsRequest) (*tfprotov5.GetFunctionsResponse, error) {
-	ctx = logging.InitContext(ctx)
+		if !hasUnknowns && computedAttributeValuesEqual(priorValue, attrs) {
+			continue
+		}
 
-	logging.HelperSchemaTrace(ctx, "Getting provider functions")
+		key := name
+		switch sm[name].Type {
+		case TypeList, TypeSet:
+			key = name + ".#"
+		case TypeMap:
+			key = name + ".%"
+		}
 
-	resp := &tfprotov5.GetFunctionsResponse{
-		Functions: make(map[string]*tfprotov5.Function, 0),
+		diff.Attributes[key] = &terraform.ResourceAttrDiff{
+			Old:         priorAttrs[key],
+			NewComputed: true,
+		}
 	}
 
-	return resp, nil
+	return diags
 }
 
-func (s *GRPCProviderServer) ValidateEphemeralResourceConfig(ctx context.Context, req *tfprotov5.ValidateEphemeralResourceConfigRequest) (*tfprotov5.ValidateEphemeralResourceConfigResponse, error) {
-	ctx = logging.InitContext(ctx)
-
-	logging.HelperSchemaTrace(ctx, "Returning error for ephemeral resource validate")
-
-	resp := &tfprotov5.ValidateEphemeralResourceConfigResponse{
-		Diagnostics: []*tfprotov5.Diagnostic{
-			{
-				Severity: tfprotov5.DiagnosticSeverityError,
-				Summary:  "Unknown Ephemeral Resource Type",
-				Detail:   fmt.Sprintf("The %q ephemeral resource type is not supported by this provider.", req.TypeName),
-			},
-		},
+// computedAttributeProviderNames returns the sorted names of the top level
+// Computed attributes which have a Provider.ComputedAttributeProviders
+// function.
+func (s *GRPCProviderServer) computedAttributeProviderNames(sm schemaMap) []string {
+	var names []string
+	for name, f := range s.provider.ComputedAttributeProviders {
+		if sch, ok := sm[name]; ok && sch.Computed && f != nil {
+			names = append(names, name)
+		}
 	}
+	sort.Strings(names)
 
-	return resp, nil
+	return names
 }
 
-func (s *GRPCProviderServer) OpenEphemeralResource(ctx context.Context, req *tfprotov5.OpenEphemeralResourceRequest) (*tfprotov5.OpenEphemeralResourceResponse, error) {
-	ctx = logging.InitContext(ctx)
+// computedAttributeProviderValue calls the Provider.ComputedAttributeProviders
+// function of the given attribute and returns its value as flatmap attributes.
+func (s *GRPCProviderServer) computedAttributeProviderValue(ctx context.Context, typeName string, sm schemaMap, name string, d *ResourceData) (map[string]string, diag.Diagnostics) {
+	logging.HelperSchemaTrace(ctx, "Calling computed attribute provider", map[string]interface{}{logging.KeyAttributePath: name})
 
-	logging.HelperSchemaTrace(ctx, "Returning error for ephemeral resource open")
+	v, err := s.provider.ComputedAttributeProviders[name](ctx, typeName, d, s.provider.Meta())
+	if err != nil {
+		return nil, diag.Diagnostics{
+			{
+				Severity:      diag.Error,
+				Summary:       "Error computing attribute value",
+				Detail:        fmt.Sprintf("The computed attribute provider for %q returned an error: %s", name, err),
+				AttributePath: cty.GetAttrPath(name),
+			},
+		}
+	}
 
-	resp := &tfprotov5.OpenEphemeralResourceResponse{
-		Diagnostics: []*tfprotov5.Diagnostic{
+	w := &MapFieldWriter{Schema: sm}
+	if err := w.WriteField([]string{name}, v); err != nil {
+		return nil, diag.Diagnostics{
 			{
-				Severity: tfprotov5.DiagnosticSeverityError,
-				Summary:  "Unknown Ephemeral Resource Type",
-				Detail:   fmt.Sprintf("The %q ephemeral resource type is not supported by this provider.", req.TypeName),
+				Severity:      diag.Error,
+				Summary:       "Invalid computed attribute value",
+				Detail:        fmt.Sprintf("The computed attribute provider for %q returned a value which does not match the attribute type: %s", name, err),
+				AttributePath: cty.GetAttrPath(name),
 			},
-		},
+		}
 	}
 
-	return resp, nil
+	return w.Map(), nil
 }
 
-func (s *GRPCProviderServer) RenewEphemeralResource(ctx context.Context, req *tfprotov5.RenewEphemeralResourceRequest) (*tfprotov5.RenewEphemeralResourceResponse, error) {
-	ctx = logging.InitContext(ctx)
+// computedAttributeValuesEqual returns whether two flatmap values of an
+// attribute are equal, treating a missing or zero collection count as empty.
+func computedAttributeValuesEqual(a, b map[string]string) bool {
+	isCount := func(k string) bool {
+		return strings.HasSuffix(k, ".#") || strings.HasSuffix(k, ".%")
+	}
 
-	logging.HelperSchemaTrace(ctx, "Returning error for ephemeral resource renew")
+	for k, v := range a {
+		if isCount(k) && v == "0" && b[k] == "" {
+			continue
+		}
+		if b[k] != v {
+			return false
+		}
+	}
 
-	resp := &tfprotov5.RenewEphemeralResourceResponse{
-		Diagnostics: []*tfprotov5.Diagnostic{
-			{
-				Severity: tfprotov5.DiagnosticSeverityError,
-				Summary:  "Unknown Ephemeral Resource Type",
-				Detail:   fmt.Sprintf("The %q ephemeral resource type is not supported by this provider.", req.TypeName),
-			},
-		},
+	for k, v := range b {
+		if isCount(k) && v == "0" && a[k] == "" {
+			continue
+		}
+		if _, ok := a[k]; !ok {
+			return false
+		}
 	}
 
-	return resp, nil
+	return true
 }
 
-func (s *GRPCProviderServer) CloseEphemeralResource(ctx context.Context, req *tfprotov5.CloseEphemeralResourceRequest) (*tfprotov5.CloseEphemeralResourceResponse, error) {
-	ctx = logging.InitContext(ctx)
+// rawStateValue expands the value a resource gave to ResourceData.SetRawState
+// into the full state type, filling in the implicit id attribute and leaving
+// any remaining attributes, such as timeouts, null.
+func rawStateValue(is *terraform.InstanceState, schemaBlock *configschema.Block) (cty.Value, error) {
+	ty := schemaBlock.ImpliedType()
+	attrs := make(map[string]cty.Value, len(ty.AttributeTypes()))
+
+	for name, attrTy := range ty.AttributeTypes() {
+		switch {
+		case name == "id":
+			attrs[name] = cty.StringVal(is.ID)
+		case is.RawState.Type().HasAttribute(name):
+			attrs[name] = is.RawState.GetAttr(name)
+		default:
+			attrs[name] = cty.NullVal(attrTy)
+		}
+	}
 
-	logging.HelperSchemaTrace(ctx, "Returning error for ephemeral resource close")
+	return schemaBlock.CoerceValue(cty.ObjectVal(attrs))
+}
 
-	resp := &tfprotov5.CloseEphemeralResourceResponse{
-		Diagnostics: []*tfprotov5.Diagnostic{
-			{
-				Severity: tfprotov5.DiagnosticSeverityError,
-				Summary:  "Unknown Ephemeral Resource Type",
-				Detail:   fmt.Sprintf("The %q ephemeral resource type is not supported by this provider.", req.TypeName),
-			},
-		},
+// hasAttributePath returns true if paths contains a path equal to p.
+func hasAttributePath(paths []*tftypes.AttributePath, p *tftypes.AttributePath) bool {
+	for _, path := range paths {
+		if path.Equal(p) {
+			return true
+		}
 	}
 
-	return resp, nil
+	return false
 }
 
 func pathToAttributePath(path cty.Path) *tftypes.AttributePath {
@@ -1892,6 +3087,132 @@ func pathToAttributePath(path cty.Path) *tftypes.AttributePath {
 	return tftypes.NewAttributePathWithSteps(steps)
 }
 
+// validateProposedComputedValues returns a warning for each top-level
+// Computed, non-Optional attribute which is known and non-null in the prior
+// state but null in the proposed new state.
+func validateProposedComputedValues(prior, proposed cty.Value, schemaMap map[string]*Schema) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if prior.IsNull() || !prior.IsKnown() || proposed.IsNull() || !proposed.IsKnown() {
+		return diags
+	}
+
+	names := make([]string, 0, len(schemaMap))
+	for name := range schemaMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		schema := schemaMap[name]
+		if !schema.Computed || schema.Optional {
+			continue
+		}
+
+		if !prior.Type().HasAttribute(name) || !proposed.Type().HasAttribute(name) {
+			continue
+		}
+
+		priorAttr := prior.GetAttr(name)
+		if priorAttr.IsNull() || !priorAttr.IsWhollyKnown() {
+			continue
+		}
+
+		if !proposed.GetAttr(name).IsNull() {
+			continue
+		}
+
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "Computed Attribute Missing From Proposed New State",
+			Detail: fmt.Sprintf("The proposed new state has a null value for computed attribute %q, which had a known value in the prior state. ", name) +
+				"Computed values are expected to be carried over from the prior state. This may indicate an issue with Terraform or the provider and should be reported to the provider developers.",
+			AttributePath: cty.GetAttrPath(name),
+		})
+	}
+
+	return diags
+}
+
+// modifyPlannedValues calls the PlanModifiers of each top-level attribute, in
+// attribute name order, and returns the planned value with their
+// modifications. It stops at the first error diagnostic.
+func modifyPlannedValues(ctx context.Context, prior, config, planned cty.Value, schemaMap map[string]*Schema) (cty.Value, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	if planned.IsNull() || !planned.IsKnown() {
+		return planned, diags
+	}
+
+	names := make([]string, 0, len(schemaMap))
+	for name, schema := range schemaMap {
+		if len(schema.PlanModifiers) > 0 && planned.Type().HasAttribute(name) {
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return planned, diags
+	}
+
+	sort.Strings(names)
+
+	plannedAttrs := planned.AsValueMap()
+
+	for _, name := range names {
+		path := cty.GetAttrPath(name)
+		ty := plannedAttrs[name].Type()
+
+		req := AttributePlanModifyRequest{
+			Key:         name,
+			PriorValue:  cty.NullVal(ty),
+			ConfigValue: cty.NullVal(ty),
+		}
+
+		if !prior.IsNull() && prior.IsKnown() {
+			req.PriorValue = prior.GetAttr(name)
+		}
+
+		if !config.IsNull() && config.IsKnown() {
+			req.ConfigValue = config.GetAttr(name)
+		}
+
+		for _, f := range schemaMap[name].PlanModifiers {
+			req.PlanValue = plannedAttrs[name]
+			resp := &AttributePlanModifyResponse{
+				PlanValue: req.PlanValue,
+			}
+
+			f(ctx, req, resp)
+
+			for i := range resp.Diagnostics {
+				if resp.Diagnostics[i].AttributePath == nil {
+					resp.Diagnostics[i].AttributePath = path
+				}
+			}
+
+			diags = append(diags, resp.Diagnostics...)
+
+			if resp.Diagnostics.HasError() {
+				return planned, diags
+			}
+
+			if resp.PlanValue == cty.NilVal || !resp.PlanValue.Type().Equals(ty) {
+				return planned, append(diags, diag.Diagnostic{
+					Severity:      diag.Error,
+					Summary:       "Invalid Planned Value",
+					Detail:        fmt.Sprintf("A plan modifier for attribute %q returned a value which does not conform to the attribute type. This is an issue with the provider and should be reported to the provider developers.", name),
+					AttributePath: path,
+				})
+			}
+
+			plannedAttrs[name] = resp.PlanValue
+		}
+	}
+
+	return cty.ObjectVal(plannedAttrs), diags
+}
+
 // helper/schema throws away timeout values from the config and stores them in
 // the Private/Meta fields. we need to copy those values into the planned state
 // so that core doesn't see a perpetual diff with the timeout block.
@@ -1937,12 +3258,50 @@ func stripResourceModifiers(r *Resource) *Resource {
 	if r == nil {
 		return nil
 	}
-	// start with a shallow copy
-	newResource := new(Resource)
-	*newResource = *r
-
-	newResource.CustomizeDiff = nil
-	newResource.Schema = map[string]*Schema{}
+	// start with a shallow copy, leaving out CustomizeDiff and SchemaFunc.
+	// The stripped SchemaFunc result is stored in Schema below, and the
+	// SchemaFunc memoization state must not be copied. New Resource fields
+	// must be added here, which TestStripResourceModifiers_fields enforces.
+	newResource := &Resource{
+		Schema:                            map[string]*Schema{},
+		SchemaVersion:                     r.SchemaVersion,
+		Identity:                          r.Identity,
+		MigrateState:                      r.MigrateState,
+		StateUpgraders:                    r.StateUpgraders,
+		UpgradeIdentityState:              r.UpgradeIdentityState,
+		MoveState:                         r.MoveState,
+		Create:                            r.Create,
+		Read:                              r.Read,
+		Update:                            r.Update,
+		Delete:                            r.Delete,
+		Exists:                            r.Exists,
+		CreateContext:                     r.CreateContext,
+		ReadContext:                       r.ReadContext,
+		UpdateContext:                     r.UpdateContext,
+		DeleteContext:                     r.DeleteContext,
+		CreateWithoutTimeout:              r.CreateWithoutTimeout,
+		ReadWithoutTimeout:                r.ReadWithoutTimeout,
+		UpdateWithoutTimeout:              r.UpdateWithoutTimeout,
+		DeleteWithoutTimeout:              r.DeleteWithoutTimeout,
+		Importer:                          r.Importer,
+		DeprecationMessage:                r.DeprecationMessage,
+		Timeouts:                          r.Timeouts,
+		CustomizeTimeout:                  r.CustomizeTimeout,
+		Description:                       r.Description,
+		UseJSONNumber:                     r.UseJSONNumber,
+		PreferJSONStateEncoding:           r.PreferJSONStateEncoding,
+		AllowEmptyID:                      r.AllowEmptyID,
+		SkipUnchangedComputedDiff:         r.SkipUnchangedComputedDiff,
+		WarnOnContextIgnored:              r.WarnOnContextIgnored,
+		EnableLegacyTypeSystemApplyErrors: r.EnableLegacyTypeSystemApplyErrors,
+		EnableLegacyTypeSystemPlanErrors:  r.EnableLegacyTypeSystemPlanErrors,
+		ErrorOnWriteOnlyInState:           r.ErrorOnWriteOnlyInState,
+		ValidateProposedState:             r.ValidateProposedState,
+		ResourceBehavior:                  r.ResourceBehavior,
+		RateLimit:                         r.RateLimit,
+		ValidateRawResourceConfigFuncs:    r.ValidateRawResourceConfigFuncs,
+		ValidateRawDataSourceConfigFuncs:  r.ValidateRawDataSourceConfigFuncs,
+	}
 
 	for k, s := range r.SchemaMap() {
 		newResource.Schema[k] = stripSchema(s)
@@ -2197,6 +3556,92 @@ func validateConfigNulls(ctx context.Context, v cty.Value, path cty.Path) []*tfp
 	return diags
 }
 
+// redactSensitiveSetElementPaths truncates the attribute paths of the given
+// diagnostics at any set element which contains a sensitive value. Set
+// elements are addressed by their value, so the path would otherwise include
+// the sensitive value in plaintext.
+func redactSensitiveSetElementPaths(diags []*tfprotov5.Diagnostic, block *configschema.Block) []*tfprotov5.Diagnostic {
+	for _, d := range diags {
+		if d == nil || d.Attribute == nil {
+			continue
+		}
+
+		steps := d.Attribute.Steps()
+		if i := sensitiveSetElementStep(steps, block); i >= 0 {
+			d.Attribute = tftypes.NewAttributePathWithSteps(steps[:i])
+		}
+	}
+
+	return diags
+}
+
+// sensitiveSetElementStep returns the index of the first step addressing a
+// set element which contains a sensitive value, or -1 if there is none.
+func sensitiveSetElementStep(steps []tftypes.AttributePathStep, block *configschema.Block) int {
+	for i := 0; i < len(steps) && block != nil; i++ {
+		name, ok := steps[i].(tftypes.AttributeName)
+		if !ok {
+			return -1
+		}
+
+		if attr, ok := block.Attributes[string(name)]; ok {
+			if !attr.Sensitive {
+				return -1
+			}
+
+			for j := i + 1; j < len(steps); j++ {
+				if _, ok := steps[j].(tftypes.ElementKeyValue); ok {
+					return j
+				}
+			}
+
+			return -1
+		}
+
+		nested, ok := block.BlockTypes[string(name)]
+		if !ok {
+			return -1
+		}
+
+		block = &nested.Block
+
+		if i+1 >= len(steps) {
+			return -1
+		}
+
+		switch steps[i+1].(type) {
+		case tftypes.ElementKeyValue:
+			if blockContainsSensitive(block) {
+				return i + 1
+			}
+
+			i++
+		case tftypes.ElementKeyInt, tftypes.ElementKeyString:
+			i++
+		}
+	}
+
+	return -1
+}
+
+// blockContainsSensitive returns true if the block or any of its nested blocks
+// contains a sensitive attribute.
+func blockContainsSensitive(block *configschema.Block) bool {
+	for _, attr := range block.Attributes {
+		if attr.Sensitive {
+			return true
+		}
+	}
+
+	for _, nested := range block.BlockTypes {
+		if blockContainsSensitive(&nested.Block) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Helper function that check a ConfigureProviderClientCapabilities struct to determine if a deferred response can be
 // returned to the Terraform client. If no ConfigureProviderClientCapabilities have been passed from the client, then false
 // is returned.
@@ -2208,12 +3653,48 @@ func configureDeferralAllowed(in *tfprotov5.ConfigureProviderClientCapabilities)
 	return in.DeferralAllowed
 }
 
+// upgradeIdentityState calls the resource UpgradeIdentityState function with
+// the current identity and returns the updated identity value.
+func (s *GRPCProviderServer) upgradeIdentityState(ctx context.Context, identityVal cty.Value, identityBlock *configschema.Block, res *Resource) (cty.Value, error) {
+	m, err := stateValueToJSONMap(identityVal, identityBlock.ImpliedType(), res.UseJSONNumber)
+	if err != nil {
+		return cty.NilVal, err
+	}
+
+	logging.HelperSchemaTrace(ctx, "Calling provider defined UpgradeIdentityState")
+
+	m, err = res.UpgradeIdentityState(ctx, res.SchemaVersion, m, s.provider.Meta())
+	if err != nil {
+		return cty.NilVal, err
+	}
+
+	// The provider isn't required to clean out removed fields
+	s.removeAttributes(ctx, m, identityBlock.ImpliedType())
+
+	return JSONMapToStateValue(m, identityBlock)
+}
+
 // Resource Identity version of upgradeJSONState
 func (s *GRPCProviderServer) upgradeJSONIdentity(ctx context.Context, version int64, m map[string]interface{}, res *Resource) (map[string]interface{}, error) {
 	var err error
 
-	for _, upgrader := range res.Identity.IdentityUpgraders {
-		if version != upgrader.Version {
+	upgraders := res.Identity.IdentityUpgraders
+	for i, upgrader := range upgraders {
+		if upgrader.Version < version {
+			continue
+		}
+
+		if upgrader.Version > version {
+			// The versions after a passthrough upgrader are compatible with
+			// the version of the next upgrader.
+			if i == 0 || !upgraders[i-1].Passthrough {
+				return nil, fmt.Errorf("missing IdentityUpgrader for identity version %d", version)
+			}
+
+			version = upgrader.Version
+		}
+
+		if upgrader.Passthrough {
 			continue
 		}
 
@@ -4,18 +4,24 @@
 package schema
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/hashicorp/go-cty/cty"
+	ctyjson "github.com/hashicorp/go-cty/cty/json"
 	"github.com/hashicorp/go-cty/cty/msgpack"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
 	"github.com/hashicorp/terraform-plugin-go/tftypes"
@@ -1148,6 +1154,43 @@ func TestGRPCProviderServerConfigureProvider(t *testing.T) {
 			},
 			expected: &tfprotov5.ConfigureProviderResponse{},
 		},
+		"ConfigureProvider-TerraformVersion": {
+			server: NewGRPCProviderServer(&Provider{
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Optional: true,
+					},
+				},
+				ConfigureProvider: func(ctx context.Context, req ConfigureProviderRequest, resp *ConfigureProviderResponse) {
+					if req.TerraformVersion != "1.11.0" {
+						resp.Diagnostics = diag.Errorf("unexpected TerraformVersion: expected: %s, got: %s", "1.11.0", req.TerraformVersion)
+						return
+					}
+
+					resp.Meta = &FakeMetaStruct{
+						Attr: req.TerraformVersion,
+					}
+				},
+			}),
+			req: &tfprotov5.ConfigureProviderRequest{
+				TerraformVersion: "1.11.0",
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"test": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"test": cty.NullVal(cty.String),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.ConfigureProviderResponse{},
+			expectedMeta: &FakeMetaStruct{
+				Attr: "1.11.0",
+			},
+		},
 		"ConfigureFunc-GetOk-zero-value": {
 			server: NewGRPCProviderServer(&Provider{
 				Schema: map[string]*Schema{
@@ -3224,6 +3267,48 @@ func TestGRPCProviderServerConfigureProvider(t *testing.T) {
 				},
 			},
 		},
+		"ConfigureProvider-Scoped-Deferred-Not-Allowed-Diagnostic": {
+			server: NewGRPCProviderServer(&Provider{
+				ConfigureProvider: func(ctx context.Context, req ConfigureProviderRequest, resp *ConfigureProviderResponse) {
+					resp.Deferred = &Deferred{
+						Reason: DeferredReasonProviderConfigUnknown,
+					}
+					resp.DeferredResources = []string{"test_resource"}
+				},
+				Schema: map[string]*Schema{
+					"test": {
+						Optional: true,
+						Type:     TypeString,
+					},
+				},
+			}),
+			req: &tfprotov5.ConfigureProviderRequest{
+				ClientCapabilities: &tfprotov5.ConfigureProviderClientCapabilities{
+					// Deferred response will cause a diagnostic to be returned
+					DeferralAllowed: false,
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"test": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"test": cty.StringVal("test-value"),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.ConfigureProviderResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "Invalid Deferred Provider Response",
+						Detail: "Provider configured a deferred response for specific resources or data sources but the Terraform request " +
+							"did not indicate support for deferred actions. This is an issue with the provider and should be reported to the provider developers.",
+					},
+				},
+			},
+		},
 		"ConfigureProvider-Deferred-Not-Allowed-Diagnostic": {
 			server: NewGRPCProviderServer(&Provider{
 				ConfigureProvider: func(ctx context.Context, req ConfigureProviderRequest, resp *ConfigureProviderResponse) {
@@ -3427,6 +3512,154 @@ func TestGRPCProviderServerGetResourceIdentitySchemas(t *testing.T) {
 				},
 			},
 		},
+		"list of nested objects": {
+			Provider: &Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_resource": {
+						Identity: &ResourceIdentity{
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{
+									"list_obj_attr": {
+										Type: TypeList,
+										Elem: &Resource{
+											Schema: map[string]*Schema{
+												"name": {
+													Type: TypeString,
+												},
+												"region": {
+													Type: TypeString,
+												},
+											},
+										},
+										RequiredForImport: true,
+										Description:       "List Object attribute",
+									},
+								}
+							},
+						},
+					},
+				},
+			},
+			Expected: &tfprotov5.GetResourceIdentitySchemasResponse{
+				IdentitySchemas: map[string]*tfprotov5.ResourceIdentitySchema{
+					"test_resource": {
+						IdentityAttributes: []*tfprotov5.ResourceIdentitySchemaAttribute{
+							{
+								Name: "list_obj_attr",
+								Type: tftypes.List{
+									ElementType: tftypes.Object{
+										AttributeTypes: map[string]tftypes.Type{
+											"name":   tftypes.String,
+											"region": tftypes.String,
+										},
+									},
+								},
+								RequiredForImport: true,
+								Description:       "List Object attribute",
+							},
+						},
+					},
+				},
+			},
+		},
+		"unsupported nested object attribute": {
+			Provider: &Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_resource": {
+						Identity: &ResourceIdentity{
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{
+									"list_obj_attr": {
+										Type: TypeList,
+										Elem: &Resource{
+											Schema: map[string]*Schema{
+												"tags": {
+													Type: TypeSet,
+													Elem: &Schema{Type: TypeString},
+												},
+											},
+										},
+										RequiredForImport: true,
+									},
+								}
+							},
+						},
+					},
+				},
+			},
+			Expected: &tfprotov5.GetResourceIdentitySchemasResponse{
+				IdentitySchemas: map[string]*tfprotov5.ResourceIdentitySchema{},
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "getting identity schema failed for resource 'test_resource': identity attribute \"list_obj_attr\": nested attribute \"tags\" has unsupported type TypeSet",
+					},
+				},
+			},
+		},
+		"map attributes": {
+			Provider: &Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_resource": {
+						Identity: &ResourceIdentity{
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{
+									"map_bool_attr":   {Type: TypeMap, Elem: &Schema{Type: TypeBool}, Description: "Map Bool attribute"},
+									"map_int_attr":    {Type: TypeMap, Elem: &Schema{Type: TypeInt}, Description: "Map Int attribute"},
+									"map_list_attr":   {Type: TypeMap, Elem: &Schema{Type: TypeList, Elem: &Schema{Type: TypeString}}, Description: "Map List attribute"},
+									"map_string_attr": {Type: TypeMap, Elem: &Schema{Type: TypeString}, Description: "Map String attribute"},
+								}
+							},
+						},
+					},
+				},
+			},
+			Expected: &tfprotov5.GetResourceIdentitySchemasResponse{
+				IdentitySchemas: map[string]*tfprotov5.ResourceIdentitySchema{
+					"test_resource": {
+						IdentityAttributes: []*tfprotov5.ResourceIdentitySchemaAttribute{
+							{Name: "map_bool_attr", Type: tftypes.Map{ElementType: tftypes.Bool}, Description: "Map Bool attribute"},
+							{Name: "map_int_attr", Type: tftypes.Map{ElementType: tftypes.Number}, Description: "Map Int attribute"},
+							{Name: "map_list_attr", Type: tftypes.Map{ElementType: tftypes.List{ElementType: tftypes.String}}, Description: "Map List attribute"},
+							{Name: "map_string_attr", Type: tftypes.Map{ElementType: tftypes.String}, Description: "Map String attribute"},
+						},
+					},
+				},
+			},
+		},
+		"map of nested objects": {
+			Provider: &Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_resource": {
+						Identity: &ResourceIdentity{
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{
+									"map_obj_attr": {
+										Type: TypeMap,
+										Elem: &Resource{
+											Schema: map[string]*Schema{
+												"name": {
+													Type: TypeString,
+												},
+											},
+										},
+									},
+								}
+							},
+						},
+					},
+				},
+			},
+			Expected: &tfprotov5.GetResourceIdentitySchemasResponse{
+				IdentitySchemas: map[string]*tfprotov5.ResourceIdentitySchema{},
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "getting identity schema failed for resource 'test_resource': identity attribute \"map_obj_attr\": nested objects are only supported in TypeList",
+					},
+				},
+			},
+		},
 		"no identity schema": {
 			Provider: &Provider{
 				ResourcesMap: map[string]*Resource{
@@ -3559,12 +3792,7 @@ func TestUpgradeResourceIdentity_jsonState(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if len(resp.Diagnostics) > 0 {
-		for _, d := range resp.Diagnostics {
-			t.Errorf("%#v", d)
-		}
-		t.Fatal("error")
-	}
+	AssertNoDiagnostics(t, resp.Diagnostics)
 
 	idschema, err := r.CoreIdentitySchema()
 
@@ -3645,12 +3873,7 @@ func TestUpgradeResourceIdentity_removedAttr(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if len(resp.Diagnostics) > 0 {
-		for _, d := range resp.Diagnostics {
-			t.Errorf("%#v", d)
-		}
-		t.Fatal("error")
-	}
+	AssertNoDiagnostics(t, resp.Diagnostics)
 
 	idschema, err := r.CoreIdentitySchema()
 	if err != nil {
@@ -3672,65 +3895,217 @@ func TestUpgradeResourceIdentity_removedAttr(t *testing.T) {
 }
 
 // Based on TestUpgradeState_jsonStateBigInt
-// This test currently does not return the integer and does not recognize it as an attribute
-func TestUpgradeResourceIdentity_jsonStateBigInt(t *testing.T) {
-	r := &Resource{
-		UseJSONNumber: true,
-		SchemaVersion: 1,
-		Identity: &ResourceIdentity{
-			Version: 1,
-			SchemaFunc: func() map[string]*Schema {
-				return map[string]*Schema{
-					"int": {
-						Type:              TypeInt,
-						RequiredForImport: true,
-						OptionalForImport: false,
-						Description:       "",
-					},
-				}
-			},
-		},
+func TestUpgradeResourceIdentity_nonContiguousVersions(t *testing.T) {
+	t.Parallel()
+
+	appendSuffix := func(suffix string) ResourceIdentityUpgradeFunc {
+		return func(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+			rawState["id"] = rawState["id"].(string) + suffix
+			return rawState, nil
+		}
 	}
 
-	server := NewGRPCProviderServer(&Provider{
-		ResourcesMap: map[string]*Resource{
-			"test": r,
+	passthroughUpgraders := []IdentityUpgrader{
+		{
+			Version: 0,
+			Upgrade: appendSuffix("-v1"),
 		},
-	})
-
-	req := &tfprotov5.UpgradeResourceIdentityRequest{
-		TypeName: "test",
-		Version:  0,
-		RawIdentity: &tfprotov5.RawState{
-			JSON: []byte(`{"int":7227701560655103598}`),
+		{
+			Version:     1,
+			Passthrough: true,
+		},
+		{
+			Version: 3,
+			Upgrade: appendSuffix("-v4"),
 		},
 	}
 
-	resp, err := server.UpgradeResourceIdentity(context.Background(), req)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if len(resp.Diagnostics) > 0 {
-		for _, d := range resp.Diagnostics {
-			t.Errorf("%#v", d)
-		}
-		t.Fatal("error")
+	testCases := map[string]struct {
+		upgraders     []IdentityUpgrader
+		version       int64
+		expected      cty.Value
+		expectedError string
+	}{
+		"version 0": {
+			upgraders: passthroughUpgraders,
+			version:   0,
+			expected:  cty.StringVal("foo-v1-v4"),
+		},
+		"passthrough version": {
+			upgraders: passthroughUpgraders,
+			version:   1,
+			expected:  cty.StringVal("foo-v4"),
+		},
+		"version after passthrough": {
+			upgraders: passthroughUpgraders,
+			version:   2,
+			expected:  cty.StringVal("foo-v4"),
+		},
+		"version of upgrader after passthrough": {
+			upgraders: passthroughUpgraders,
+			version:   3,
+			expected:  cty.StringVal("foo-v4"),
+		},
+		"current version": {
+			upgraders: passthroughUpgraders,
+			version:   4,
+			expected:  cty.StringVal("foo"),
+		},
+		"missing upgrader": {
+			upgraders: []IdentityUpgrader{
+				{
+					Version: 0,
+					Upgrade: appendSuffix("-v1"),
+				},
+				{
+					Version: 3,
+					Upgrade: appendSuffix("-v4"),
+				},
+			},
+			version:       1,
+			expectedError: "missing IdentityUpgrader for identity version 1",
+		},
 	}
 
-	idschema, err := r.CoreIdentitySchema()
-	if err != nil {
-		t.Fatal(err)
-	}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
 
-	val, err := msgpack.Unmarshal(resp.UpgradedIdentity.IdentityData.MsgPack, idschema.ImpliedType())
-	if err != nil {
-		t.Fatal(err)
-	}
+			r := &Resource{
+				Identity: &ResourceIdentity{
+					Version: 4,
+					SchemaFunc: func() map[string]*Schema {
+						return map[string]*Schema{
+							"id": {
+								Type:              TypeString,
+								RequiredForImport: true,
+							},
+						}
+					},
+					IdentityUpgraders: testCase.upgraders,
+				},
+			}
 
-	expected := cty.ObjectVal(map[string]cty.Value{
-		"int": cty.NumberIntVal(7227701560655103598),
-	})
+			server := NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": r,
+				},
+			})
+
+			resp, err := server.UpgradeResourceIdentity(context.Background(), &tfprotov5.UpgradeResourceIdentityRequest{
+				TypeName: "test",
+				Version:  testCase.version,
+				RawIdentity: &tfprotov5.RawState{
+					JSON: []byte(`{"id":"foo"}`),
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if testCase.expectedError != "" {
+				AssertDiagnosticCount(t, resp.Diagnostics, 1)
+				AssertDiagnosticContains(t, resp.Diagnostics, tfprotov5.DiagnosticSeverityError, testCase.expectedError)
+
+				return
+			}
+
+			if len(resp.Diagnostics) > 0 {
+				t.Fatalf("unexpected diagnostics: %#v", resp.Diagnostics)
+			}
+
+			idschema, err := r.CoreIdentitySchema()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			val, err := msgpack.Unmarshal(resp.UpgradedIdentity.IdentityData.MsgPack, idschema.ImpliedType())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := val.GetAttr("id"); !got.RawEquals(testCase.expected) {
+				t.Fatalf("expected %#v, got %#v", testCase.expected, got)
+			}
+		})
+	}
+}
+
+func TestUpgradeResourceIdentity_jsonStateBigInt(t *testing.T) {
+	r := &Resource{
+		UseJSONNumber: true,
+		SchemaVersion: 1,
+		Identity: &ResourceIdentity{
+			Version: 1,
+			IdentityUpgraders: []IdentityUpgrader{
+				{
+					Version: 0,
+					Type: tftypes.Object{
+						AttributeTypes: map[string]tftypes.Type{
+							"int": tftypes.Number,
+						},
+					},
+					Upgrade: func(ctx context.Context, m map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+						// the upgrader must see the exact number, rather
+						// than a float64 which has lost precision
+						n, ok := m["int"].(json.Number)
+						if !ok {
+							return nil, fmt.Errorf("expected json.Number, got %T", m["int"])
+						}
+						if n.String() != "7227701560655103598" {
+							return nil, fmt.Errorf("unexpected value %s", n)
+						}
+						return m, nil
+					},
+				},
+			},
+			SchemaFunc: func() map[string]*Schema {
+				return map[string]*Schema{
+					"int": {
+						Type:              TypeInt,
+						RequiredForImport: true,
+						OptionalForImport: false,
+						Description:       "",
+					},
+				}
+			},
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": r,
+		},
+	})
+
+	req := &tfprotov5.UpgradeResourceIdentityRequest{
+		TypeName: "test",
+		Version:  0,
+		RawIdentity: &tfprotov5.RawState{
+			JSON: []byte(`{"int":7227701560655103598}`),
+		},
+	}
+
+	resp, err := server.UpgradeResourceIdentity(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	AssertNoDiagnostics(t, resp.Diagnostics)
+
+	idschema, err := r.CoreIdentitySchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	val, err := msgpack.Unmarshal(resp.UpgradedIdentity.IdentityData.MsgPack, idschema.ImpliedType())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"int": cty.NumberIntVal(7227701560655103598),
+	})
 
 	if !cmp.Equal(expected, val, valueComparer, equateEmpty) {
 		t.Fatal(cmp.Diff(expected, val, valueComparer, equateEmpty))
@@ -3827,6 +4202,75 @@ func TestGRPCProviderServerGetMetadata(t *testing.T) {
 				},
 			},
 		},
+		"functions": {
+			Provider: &Provider{
+				Functions: map[string]*Function{
+					"test_function": nil, // implementation not necessary
+				},
+			},
+			Expected: &tfprotov5.GetMetadataResponse{
+				DataSources: []tfprotov5.DataSourceMetadata{},
+				Functions: []tfprotov5.FunctionMetadata{
+					{
+						Name: "test_function",
+					},
+				},
+				EphemeralResources: []tfprotov5.EphemeralResourceMetadata{},
+				Resources:          []tfprotov5.ResourceMetadata{},
+				ServerCapabilities: &tfprotov5.ServerCapabilities{
+					GetProviderSchemaOptional: true,
+				},
+			},
+		},
+		"ephemeral resources": {
+			Provider: &Provider{
+				EphemeralResourcesMap: map[string]*EphemeralResource{
+					"test_ephemeral_resource": nil, // implementation not necessary
+				},
+			},
+			Expected: &tfprotov5.GetMetadataResponse{
+				DataSources: []tfprotov5.DataSourceMetadata{},
+				Functions:   []tfprotov5.FunctionMetadata{},
+				EphemeralResources: []tfprotov5.EphemeralResourceMetadata{
+					{
+						TypeName: "test_ephemeral_resource",
+					},
+				},
+				Resources: []tfprotov5.ResourceMetadata{},
+				ServerCapabilities: &tfprotov5.ServerCapabilities{
+					GetProviderSchemaOptional: true,
+				},
+			},
+		},
+		"resources with MoveState": {
+			Provider: &Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_resource1": {
+						MoveState: []StateMover{
+							{
+								StateMover: func(ctx context.Context, req MoveStateRequest, resp *MoveStateResponse) {
+									resp.TargetState = req.SourceRawState
+								},
+							},
+						},
+					},
+				},
+			},
+			Expected: &tfprotov5.GetMetadataResponse{
+				DataSources:        []tfprotov5.DataSourceMetadata{},
+				Functions:          []tfprotov5.FunctionMetadata{},
+				EphemeralResources: []tfprotov5.EphemeralResourceMetadata{},
+				Resources: []tfprotov5.ResourceMetadata{
+					{
+						TypeName: "test_resource1",
+					},
+				},
+				ServerCapabilities: &tfprotov5.ServerCapabilities{
+					GetProviderSchemaOptional: true,
+					MoveResourceState:         true,
+				},
+			},
+		},
 	}
 
 	for name, testCase := range testCases {
@@ -3859,50 +4303,90 @@ func TestGRPCProviderServerGetMetadata(t *testing.T) {
 	}
 }
 
-func TestGRPCProviderServerMoveResourceState(t *testing.T) {
+func TestGRPCProviderServerValidateEphemeralResourceConfig(t *testing.T) {
 	t.Parallel()
 
+	res := &EphemeralResource{
+		Schema: map[string]*Schema{
+			"name": {
+				Type:     TypeString,
+				Required: true,
+				ValidateFunc: func(v interface{}, k string) ([]string, []error) {
+					if v.(string) == "invalid" {
+						return nil, []error{fmt.Errorf("%s is invalid", k)}
+					}
+
+					return nil, nil
+				},
+			},
+			"token": {
+				Type:     TypeString,
+				Computed: true,
+			},
+		},
+		OpenContext: func(ctx context.Context, req EphemeralOpenRequest, resp *EphemeralOpenResponse) {},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		EphemeralResourcesMap: map[string]*EphemeralResource{
+			"test_ephemeral_resource": res,
+		},
+	})
+
+	ty := res.CoreConfigSchema().ImpliedType()
+
 	testCases := map[string]struct {
-		server   *GRPCProviderServer
-		request  *tfprotov5.MoveResourceStateRequest
-		expected *tfprotov5.MoveResourceStateResponse
+		typeName string
+		config   cty.Value
+		expected []*tfprotov5.Diagnostic
 	}{
-		"nil": {
-			server:   NewGRPCProviderServer(&Provider{}),
-			request:  nil,
-			expected: nil,
+		"valid": {
+			typeName: "test_ephemeral_resource",
+			config: cty.ObjectVal(map[string]cty.Value{
+				"name":  cty.StringVal("test"),
+				"token": cty.NullVal(cty.String),
+			}),
 		},
-		"request-TargetTypeName-missing": {
-			server: NewGRPCProviderServer(&Provider{}),
-			request: &tfprotov5.MoveResourceStateRequest{
-				TargetTypeName: "test_resource",
-			},
-			expected: &tfprotov5.MoveResourceStateResponse{
-				Diagnostics: []*tfprotov5.Diagnostic{
-					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "Unknown Resource Type",
-						Detail:   "The \"test_resource\" resource type is not supported by this provider.",
-					},
+		"invalid": {
+			typeName: "test_ephemeral_resource",
+			config: cty.ObjectVal(map[string]cty.Value{
+				"name":  cty.StringVal("invalid"),
+				"token": cty.NullVal(cty.String),
+			}),
+			expected: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "name is invalid",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("name"),
 				},
 			},
 		},
-		"request-TargetTypeName": {
-			server: NewGRPCProviderServer(&Provider{
-				ResourcesMap: map[string]*Resource{
-					"test_resource": {},
-				},
+		"missing required": {
+			typeName: "test_ephemeral_resource",
+			config: cty.ObjectVal(map[string]cty.Value{
+				"name":  cty.NullVal(cty.String),
+				"token": cty.NullVal(cty.String),
 			}),
-			request: &tfprotov5.MoveResourceStateRequest{
-				TargetTypeName: "test_resource",
+			expected: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "Missing required argument",
+					Detail:    "The argument \"name\" is required, but no definition was found.",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("name"),
+				},
 			},
-			expected: &tfprotov5.MoveResourceStateResponse{
-				Diagnostics: []*tfprotov5.Diagnostic{
-					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "Move Resource State Not Supported",
-						Detail:   "The \"test_resource\" resource type does not support moving resource state across resource types.",
-					},
+		},
+		"unknown type": {
+			typeName: "test_unknown",
+			config: cty.ObjectVal(map[string]cty.Value{
+				"name":  cty.StringVal("test"),
+				"token": cty.NullVal(cty.String),
+			}),
+			expected: []*tfprotov5.Diagnostic{
+				{
+					Severity: tfprotov5.DiagnosticSeverityError,
+					Summary:  "Unknown Ephemeral Resource Type",
+					Detail:   "The \"test_unknown\" ephemeral resource type is not supported by this provider.",
 				},
 			},
 		},
@@ -3912,478 +4396,568 @@ func TestGRPCProviderServerMoveResourceState(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			resp, err := testCase.server.MoveResourceState(context.Background(), testCase.request)
-
-			if testCase.request != nil && err != nil {
+			resp, err := server.ValidateEphemeralResourceConfig(context.Background(), &tfprotov5.ValidateEphemeralResourceConfigRequest{
+				TypeName: testCase.typeName,
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, testCase.config),
+				},
+			})
+			if err != nil {
 				t.Fatalf("unexpected error: %s", err)
 			}
 
-			if diff := cmp.Diff(resp, testCase.expected); diff != "" {
+			if diff := cmp.Diff(testCase.expected, resp.Diagnostics); diff != "" {
 				t.Errorf("unexpected difference: %s", diff)
 			}
 		})
 	}
 }
 
-func TestGRPCProviderServerValidateResourceTypeConfig(t *testing.T) {
+func TestGRPCProviderServerEphemeralResourceLifecycle(t *testing.T) {
 	t.Parallel()
 
-	testCases := map[string]struct {
-		server   *GRPCProviderServer
-		request  *tfprotov5.ValidateResourceTypeConfigRequest
-		expected *tfprotov5.ValidateResourceTypeConfigResponse
-	}{
-		"Provider with empty resource returns no errors": {
-			server: NewGRPCProviderServer(&Provider{
-				ResourcesMap: map[string]*Resource{
-					"test_resource": {},
-				},
-			}),
-			request: &tfprotov5.ValidateResourceTypeConfigRequest{
-				TypeName: "test_resource",
-				Config: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id": cty.NullVal(cty.String),
-						}),
-					),
-				},
+	renewAt := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	var closed []byte
+
+	res := &EphemeralResource{
+		Schema: map[string]*Schema{
+			"name": {
+				Type:     TypeString,
+				Required: true,
+			},
+			"description": {
+				Type:     TypeString,
+				Optional: true,
+			},
+			"token": {
+				Type:      TypeString,
+				Computed:  true,
+				Sensitive: true,
 			},
-			expected: &tfprotov5.ValidateResourceTypeConfigResponse{},
 		},
-		"Client without WriteOnlyAttributesAllowed capabilities: null WriteOnly attribute returns no errors": {
-			server: NewGRPCProviderServer(&Provider{
-				ResourcesMap: map[string]*Resource{
-					"test_resource": {
-						Schema: map[string]*Schema{
-							"foo": {
-								Type:      TypeInt,
-								Optional:  true,
-								WriteOnly: true,
-							},
-						},
-					},
+		OpenContext: func(ctx context.Context, req EphemeralOpenRequest, resp *EphemeralOpenResponse) {
+			name := req.ResourceData.Get("name").(string)
+
+			if err := req.ResourceData.Set("token", name+"-token"); err != nil {
+				resp.Diagnostics = diag.FromErr(err)
+				return
+			}
+
+			resp.Private = []byte("lease-1")
+			resp.RenewAt = renewAt
+		},
+		RenewContext: func(ctx context.Context, req EphemeralRenewRequest, resp *EphemeralRenewResponse) {
+			if string(req.Private) != "lease-1" {
+				resp.Diagnostics = diag.Errorf("unexpected private data: %s", req.Private)
+				return
+			}
+
+			resp.Private = []byte("lease-2")
+			resp.RenewAt = renewAt.Add(time.Hour)
+		},
+		CloseContext: func(ctx context.Context, req EphemeralCloseRequest, resp *EphemeralCloseResponse) {
+			closed = req.Private
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		EphemeralResourcesMap: map[string]*EphemeralResource{
+			"test_ephemeral_resource": res,
+		},
+	})
+
+	ty := res.CoreConfigSchema().ImpliedType()
+
+	openResp, err := server.OpenEphemeralResource(context.Background(), &tfprotov5.OpenEphemeralResourceRequest{
+		TypeName: "test_ephemeral_resource",
+		Config: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+				"name":        cty.StringVal("test"),
+				"description": cty.NullVal(cty.String),
+				"token":       cty.NullVal(cty.String),
+			})),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	AssertNoDiagnostics(t, openResp.Diagnostics)
+
+	result, err := msgpack.Unmarshal(openResp.Result.MsgPack, ty)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expectedResult := cty.ObjectVal(map[string]cty.Value{
+		"name":        cty.StringVal("test"),
+		"description": cty.NullVal(cty.String),
+		"token":       cty.StringVal("test-token"),
+	})
+
+	if !result.RawEquals(expectedResult) {
+		t.Fatalf("unexpected result\ngot:  %#v\nwant: %#v", result, expectedResult)
+	}
+
+	if string(openResp.Private) != "lease-1" {
+		t.Fatalf("unexpected private data: %s", openResp.Private)
+	}
+
+	if !openResp.RenewAt.Equal(renewAt) {
+		t.Fatalf("unexpected renew at: %s", openResp.RenewAt)
+	}
+
+	renewResp, err := server.RenewEphemeralResource(context.Background(), &tfprotov5.RenewEphemeralResourceRequest{
+		TypeName: "test_ephemeral_resource",
+		Private:  openResp.Private,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	AssertNoDiagnostics(t, renewResp.Diagnostics)
+
+	if string(renewResp.Private) != "lease-2" {
+		t.Fatalf("unexpected private data: %s", renewResp.Private)
+	}
+
+	if !renewResp.RenewAt.Equal(renewAt.Add(time.Hour)) {
+		t.Fatalf("unexpected renew at: %s", renewResp.RenewAt)
+	}
+
+	closeResp, err := server.CloseEphemeralResource(context.Background(), &tfprotov5.CloseEphemeralResourceRequest{
+		TypeName: "test_ephemeral_resource",
+		Private:  renewResp.Private,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	AssertNoDiagnostics(t, closeResp.Diagnostics)
+
+	if string(closed) != "lease-2" {
+		t.Fatalf("unexpected private data on close: %s", closed)
+	}
+}
+
+func TestGRPCProviderServerGetProviderSchema_cached(t *testing.T) {
+	t.Parallel()
+
+	server := NewGRPCProviderServer(benchmarkProvider(2))
+
+	first, err := server.GetProviderSchema(context.Background(), &tfprotov5.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	AssertNoDiagnostics(t, first.Diagnostics)
+
+	second, err := server.GetProviderSchema(context.Background(), &tfprotov5.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	for typ, schema := range first.ResourceSchemas {
+		if second.ResourceSchemas[typ] != schema {
+			t.Fatalf("expected the cached %s schema to be returned", typ)
+		}
+	}
+
+	// Modifying a response must not affect later responses.
+	for typ := range first.ResourceSchemas {
+		delete(first.ResourceSchemas, typ)
+	}
+	first.Diagnostics = append(first.Diagnostics, &tfprotov5.Diagnostic{
+		Severity: tfprotov5.DiagnosticSeverityError,
+		Summary:  "modified",
+	})
+
+	third, err := server.GetProviderSchema(context.Background(), &tfprotov5.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	AssertNoDiagnostics(t, third.Diagnostics)
+
+	if len(second.ResourceSchemas) != 2 {
+		t.Fatalf("expected 2 resource schemas, got: %d", len(second.ResourceSchemas))
+	}
+
+	if len(third.ResourceSchemas) != 2 {
+		t.Fatalf("expected 2 resource schemas, got: %d", len(third.ResourceSchemas))
+	}
+}
+
+func BenchmarkGRPCProviderServerGetProviderSchema(b *testing.B) {
+	p := benchmarkProvider(50)
+
+	b.Run("cached", func(b *testing.B) {
+		server := NewGRPCProviderServer(p)
+
+		for i := 0; i < b.N; i++ {
+			if _, err := server.GetProviderSchema(context.Background(), &tfprotov5.GetProviderSchemaRequest{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			server := NewGRPCProviderServer(p)
+
+			if _, err := server.GetProviderSchema(context.Background(), &tfprotov5.GetProviderSchemaRequest{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// benchmarkProvider returns a provider with the given number of resources,
+// each with a few attributes and a nested block.
+func benchmarkProvider(resources int) *Provider {
+	p := &Provider{
+		ResourcesMap: make(map[string]*Resource, resources),
+	}
+
+	for i := 0; i < resources; i++ {
+		p.ResourcesMap[fmt.Sprintf("test_resource_%d", i)] = &Resource{
+			Schema: map[string]*Schema{
+				"name": {
+					Type:     TypeString,
+					Required: true,
 				},
-			}),
-			request: &tfprotov5.ValidateResourceTypeConfigRequest{
-				TypeName: "test_resource",
-				Config: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.Number,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.NullVal(cty.String),
-							"foo": cty.NullVal(cty.Number),
-						}),
-					),
+				"tags": {
+					Type:     TypeMap,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
 				},
-			},
-			expected: &tfprotov5.ValidateResourceTypeConfigResponse{},
-		},
-		"Server without WriteOnlyAttributesAllowed capabilities: WriteOnly Attribute with Value returns an error": {
-			server: NewGRPCProviderServer(&Provider{
-				ResourcesMap: map[string]*Resource{
-					"test_resource": {
+				"rule": {
+					Type:     TypeList,
+					Optional: true,
+					Elem: &Resource{
 						Schema: map[string]*Schema{
-							"foo": {
-								Type:      TypeInt,
-								Optional:  true,
-								WriteOnly: true,
+							"port": {
+								Type:     TypeInt,
+								Required: true,
+							},
+							"protocol": {
+								Type:     TypeString,
+								Optional: true,
+								Default:  "tcp",
 							},
 						},
 					},
 				},
-			}),
-			request: &tfprotov5.ValidateResourceTypeConfigRequest{
-				TypeName: "test_resource",
-				Config: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.Number,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.NullVal(cty.String),
-							"foo": cty.NumberIntVal(2),
-						}),
-					),
-				},
 			},
-			expected: &tfprotov5.ValidateResourceTypeConfigResponse{
-				Diagnostics: []*tfprotov5.Diagnostic{
-					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "Write-only Attribute Not Allowed",
-						Detail: "The resource contains a non-null value for write-only attribute \"foo\" " +
-							"Write-only attributes are only supported in Terraform 1.11 and later.",
-						Attribute: tftypes.NewAttributePath().WithAttributeName("foo"),
+		}
+	}
+
+	return p
+}
+
+func TestGRPCProviderServerGetProviderSchema_ephemeralResources(t *testing.T) {
+	t.Parallel()
+
+	server := NewGRPCProviderServer(&Provider{
+		EphemeralResourcesMap: map[string]*EphemeralResource{
+			"test_ephemeral_resource": {
+				Description: "test ephemeral resource",
+				Schema: map[string]*Schema{
+					"name": {
+						Type:     TypeString,
+						Required: true,
 					},
-				},
-			},
-		},
-		"Server without WriteOnlyAttributesAllowed capabilities: multiple WriteOnly Attributes with Value returns multiple errors": {
-			server: NewGRPCProviderServer(&Provider{
-				ResourcesMap: map[string]*Resource{
-					"test_resource": {
-						Schema: map[string]*Schema{
-							"foo": {
-								Type:      TypeInt,
-								Optional:  true,
-								WriteOnly: true,
-							},
-							"bar": {
-								Type:      TypeInt,
-								Optional:  true,
-								WriteOnly: true,
-							},
-						},
+					"token": {
+						Type:      TypeString,
+						Computed:  true,
+						Sensitive: true,
 					},
 				},
-			}),
-			request: &tfprotov5.ValidateResourceTypeConfigRequest{
-				TypeName: "test_resource",
-				Config: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.Number,
-							"bar": cty.Number,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.NullVal(cty.String),
-							"foo": cty.NumberIntVal(2),
-							"bar": cty.NumberIntVal(2),
-						}),
-					),
-				},
+				OpenContext: func(ctx context.Context, req EphemeralOpenRequest, resp *EphemeralOpenResponse) {},
 			},
-			expected: &tfprotov5.ValidateResourceTypeConfigResponse{
-				Diagnostics: []*tfprotov5.Diagnostic{
+		},
+	})
+
+	resp, err := server.GetProviderSchema(context.Background(), &tfprotov5.GetProviderSchemaRequest{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	AssertNoDiagnostics(t, resp.Diagnostics)
+
+	if len(resp.ResourceSchemas) != 0 {
+		t.Fatalf("expected no resource schemas, got: %#v", resp.ResourceSchemas)
+	}
+
+	expected := map[string]*tfprotov5.Schema{
+		"test_ephemeral_resource": {
+			Block: &tfprotov5.SchemaBlock{
+				Description:     "test ephemeral resource",
+				DescriptionKind: tfprotov5.StringKindPlain,
+				Attributes: []*tfprotov5.SchemaAttribute{
 					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "Write-only Attribute Not Allowed",
-						Detail: "The resource contains a non-null value for write-only attribute \"bar\" " +
-							"Write-only attributes are only supported in Terraform 1.11 and later.",
-						Attribute: tftypes.NewAttributePath().WithAttributeName("bar"),
+						Name:            "name",
+						Type:            tftypes.String,
+						Required:        true,
+						DescriptionKind: tfprotov5.StringKindPlain,
 					},
 					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "Write-only Attribute Not Allowed",
-						Detail: "The resource contains a non-null value for write-only attribute \"foo\" " +
-							"Write-only attributes are only supported in Terraform 1.11 and later.",
-						Attribute: tftypes.NewAttributePath().WithAttributeName("foo"),
+						Name:            "token",
+						Type:            tftypes.String,
+						Computed:        true,
+						Sensitive:       true,
+						DescriptionKind: tfprotov5.StringKindPlain,
 					},
 				},
 			},
 		},
-		"Server without WriteOnlyAttributesAllowed capabilities: multiple nested WriteOnly Attributes with Value returns multiple errors": {
-			server: NewGRPCProviderServer(&Provider{
-				ResourcesMap: map[string]*Resource{
-					"test_resource": {
-						Schema: map[string]*Schema{
-							"foo": {
-								Type:      TypeInt,
-								Optional:  true,
-								WriteOnly: true,
-							},
-							"bar": {
-								Type:     TypeInt,
-								Optional: true,
-							},
-							"config_block_attr": {
-								Type:      TypeList,
-								Optional:  true,
-								WriteOnly: true,
-								Elem: &Resource{
-									Schema: map[string]*Schema{
-										"nested_attr": {
-											Type:     TypeString,
-											Optional: true,
-										},
-										"writeonly_nested_attr": {
-											Type:      TypeString,
-											WriteOnly: true,
-											Optional:  true,
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			}),
-			request: &tfprotov5.ValidateResourceTypeConfigRequest{
-				TypeName: "test_resource",
-				Config: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.Number,
-							"bar": cty.Number,
-							"config_block_attr": cty.List(cty.Object(map[string]cty.Type{
-								"nested_attr":           cty.String,
-								"writeonly_nested_attr": cty.String,
-							})),
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.NullVal(cty.String),
-							"foo": cty.NumberIntVal(2),
-							"bar": cty.NumberIntVal(2),
-							"config_block_attr": cty.ListVal([]cty.Value{
-								cty.ObjectVal(map[string]cty.Value{
-									"nested_attr":           cty.StringVal("value"),
-									"writeonly_nested_attr": cty.StringVal("value"),
-								}),
-							}),
-						}),
-					),
-				},
+	}
+
+	if diff := cmp.Diff(expected, resp.EphemeralResourceSchemas); diff != "" {
+		t.Errorf("unexpected difference: %s", diff)
+	}
+}
+
+func TestGRPCProviderServerEphemeralResourceOpenClose(t *testing.T) {
+	t.Parallel()
+
+	res := &EphemeralResource{
+		Schema: map[string]*Schema{
+			"value": {
+				Type:     TypeString,
+				Computed: true,
 			},
-			expected: &tfprotov5.ValidateResourceTypeConfigResponse{
-				Diagnostics: []*tfprotov5.Diagnostic{
+		},
+		OpenContext: func(ctx context.Context, req EphemeralOpenRequest, resp *EphemeralOpenResponse) {
+			if err := req.ResourceData.Set("value", "test"); err != nil {
+				resp.Diagnostics = diag.FromErr(err)
+			}
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		EphemeralResourcesMap: map[string]*EphemeralResource{
+			"test_ephemeral_resource": res,
+		},
+	})
+
+	ty := res.CoreConfigSchema().ImpliedType()
+
+	openResp, err := server.OpenEphemeralResource(context.Background(), &tfprotov5.OpenEphemeralResourceRequest{
+		TypeName: "test_ephemeral_resource",
+		Config: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+				"value": cty.NullVal(cty.String),
+			})),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expectedResult := &tfprotov5.DynamicValue{
+		MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+			"value": cty.StringVal("test"),
+		})),
+	}
+
+	if diff := cmp.Diff(&tfprotov5.OpenEphemeralResourceResponse{Result: expectedResult}, openResp); diff != "" {
+		t.Errorf("unexpected open response difference: %s", diff)
+	}
+
+	// CloseContext is optional.
+	closeResp, err := server.CloseEphemeralResource(context.Background(), &tfprotov5.CloseEphemeralResourceRequest{
+		TypeName: "test_ephemeral_resource",
+		Private:  openResp.Private,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if diff := cmp.Diff(&tfprotov5.CloseEphemeralResourceResponse{}, closeResp); diff != "" {
+		t.Errorf("unexpected close response difference: %s", diff)
+	}
+}
+
+func TestGRPCProviderServerGetFunctions(t *testing.T) {
+	t.Parallel()
+
+	server := NewGRPCProviderServer(&Provider{
+		Functions: map[string]*Function{
+			"join": {
+				Summary: "Joins strings",
+				Parameters: []*Parameter{
 					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "Write-only Attribute Not Allowed",
-						Detail: "The resource contains a non-null value for write-only attribute \"foo\" " +
-							"Write-only attributes are only supported in Terraform 1.11 and later.",
-						Attribute: tftypes.NewAttributePath().WithAttributeName("foo"),
+						Name: "values",
+						Type: cty.List(cty.String),
 					},
 					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "Write-only Attribute Not Allowed",
-						Detail: "The resource contains a non-null value for write-only attribute \"writeonly_nested_attr\" " +
-							"Write-only attributes are only supported in Terraform 1.11 and later.",
-						Attribute: tftypes.NewAttributePath().
-							WithAttributeName("config_block_attr").
-							WithElementKeyInt(0).
-							WithAttributeName("writeonly_nested_attr"),
-					},
-				},
-			},
-		},
-		"Server with ValidateRawResourceConfigFunc: WriteOnlyAttributesAllowed true returns diags": {
-			server: NewGRPCProviderServer(&Provider{
-				ResourcesMap: map[string]*Resource{
-					"test_resource": {
-						ValidateRawResourceConfigFuncs: []ValidateRawResourceConfigFunc{
-							func(ctx context.Context, req ValidateResourceConfigFuncRequest, resp *ValidateResourceConfigFuncResponse) {
-								if req.WriteOnlyAttributesAllowed {
-									resp.Diagnostics = diag.Diagnostics{
-										{
-											Severity: diag.Error,
-											Summary:  "ValidateRawResourceConfigFunc Error",
-										},
-									}
-								}
-							},
-							func(ctx context.Context, req ValidateResourceConfigFuncRequest, resp *ValidateResourceConfigFuncResponse) {
-								if req.WriteOnlyAttributesAllowed {
-									resp.Diagnostics = diag.Diagnostics{
-										{
-											Severity: diag.Error,
-											Summary:  "ValidateRawResourceConfigFunc Error",
-										},
-									}
-								}
-							},
-						},
-						Schema: map[string]*Schema{
-							"foo": {
-								Type:      TypeInt,
-								Optional:  true,
-								WriteOnly: true,
-							},
-							"bar": {
-								Type:     TypeInt,
-								Optional: true,
-							},
-						},
+						Name:           "separator",
+						Type:           cty.String,
+						AllowNullValue: true,
 					},
 				},
-			}),
-			request: &tfprotov5.ValidateResourceTypeConfigRequest{
-				TypeName: "test_resource",
-				ClientCapabilities: &tfprotov5.ValidateResourceTypeConfigClientCapabilities{
-					WriteOnlyAttributesAllowed: true,
+				Return: &Return{
+					Type: cty.String,
 				},
-				Config: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.Number,
-							"bar": cty.Number,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.NullVal(cty.String),
-							"foo": cty.NumberIntVal(2),
-							"bar": cty.NumberIntVal(2),
-						}),
-					),
+				Run: func(ctx context.Context, args []cty.Value) (interface{}, diag.Diagnostics) {
+					return "", nil
 				},
 			},
-			expected: &tfprotov5.ValidateResourceTypeConfigResponse{
-				Diagnostics: []*tfprotov5.Diagnostic{
+		},
+	})
+
+	resp, err := server.GetFunctions(context.Background(), &tfprotov5.GetFunctionsRequest{})
+	if err != nil {
+		t.Fatalf("unexpected gRPC error: %s", err)
+	}
+
+	expected := &tfprotov5.GetFunctionsResponse{
+		Functions: map[string]*tfprotov5.Function{
+			"join": {
+				Parameters: []*tfprotov5.FunctionParameter{
 					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "ValidateRawResourceConfigFunc Error",
+						DescriptionKind: tfprotov5.StringKindPlain,
+						Name:            "values",
+						Type:            tftypes.List{ElementType: tftypes.String},
 					},
 					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "ValidateRawResourceConfigFunc Error",
+						AllowNullValue:  true,
+						DescriptionKind: tfprotov5.StringKindPlain,
+						Name:            "separator",
+						Type:            tftypes.String,
 					},
 				},
+				Return: &tfprotov5.FunctionReturn{
+					Type: tftypes.String,
+				},
+				Summary:         "Joins strings",
+				DescriptionKind: tfprotov5.StringKindPlain,
 			},
 		},
-		"Server with ValidateRawResourceConfigFunc: WriteOnlyAttributesAllowed false returns diags": {
-			server: NewGRPCProviderServer(&Provider{
-				ResourcesMap: map[string]*Resource{
-					"test_resource": {
-						ValidateRawResourceConfigFuncs: []ValidateRawResourceConfigFunc{
-							func(ctx context.Context, req ValidateResourceConfigFuncRequest, resp *ValidateResourceConfigFuncResponse) {
-								if !req.WriteOnlyAttributesAllowed {
-									resp.Diagnostics = diag.Diagnostics{
-										{
-											Severity: diag.Error,
-											Summary:  "ValidateRawResourceConfigFunc Error",
-										},
-									}
-								}
-							},
-							func(ctx context.Context, req ValidateResourceConfigFuncRequest, resp *ValidateResourceConfigFuncResponse) {
-								if !req.WriteOnlyAttributesAllowed {
-									resp.Diagnostics = diag.Diagnostics{
-										{
-											Severity: diag.Error,
-											Summary:  "ValidateRawResourceConfigFunc Error",
-										},
-									}
-								}
-							},
-						},
-						Schema: map[string]*Schema{
-							"foo": {
-								Type:     TypeInt,
-								Optional: true,
-							},
-							"bar": {
-								Type:     TypeInt,
-								Optional: true,
-							},
+	}
+
+	if diff := cmp.Diff(resp, expected); diff != "" {
+		t.Errorf("unexpected response difference: %s", diff)
+	}
+}
+
+func TestGRPCProviderServerCallFunction(t *testing.T) {
+	t.Parallel()
+
+	argIndex := func(i int64) *int64 {
+		return &i
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		Functions: map[string]*Function{
+			"repeat": {
+				Parameters: []*Parameter{
+					{
+						Name: "value",
+						Type: cty.String,
+					},
+					{
+						Name: "count",
+						Type: cty.Number,
+						ValidateFunc: func(ctx context.Context, value cty.Value) diag.Diagnostics {
+							if value.LessThan(cty.Zero).True() {
+								return diag.Errorf("count must not be negative")
+							}
+
+							return nil
 						},
 					},
 				},
-			}),
-			request: &tfprotov5.ValidateResourceTypeConfigRequest{
-				TypeName: "test_resource",
-				Config: &tfprotov5.DynamicValue{
+				Return: &Return{
+					Type: cty.List(cty.String),
+				},
+				Run: func(ctx context.Context, args []cty.Value) (interface{}, diag.Diagnostics) {
+					value := args[0].AsString()
+					count, _ := args[1].AsBigFloat().Int64()
+
+					if value == "" {
+						return nil, diag.Errorf("value must not be empty")
+					}
+
+					result := make([]interface{}, count)
+					for i := range result {
+						result[i] = value
+					}
+
+					return result, nil
+				},
+			},
+		},
+	})
+
+	testCases := map[string]struct {
+		name      string
+		arguments []cty.Value
+		types     []cty.Type
+		expected  *tfprotov5.CallFunctionResponse
+	}{
+		"success": {
+			name:      "repeat",
+			arguments: []cty.Value{cty.StringVal("a"), cty.NumberIntVal(2)},
+			types:     []cty.Type{cty.String, cty.Number},
+			expected: &tfprotov5.CallFunctionResponse{
+				Result: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.Number,
-							"bar": cty.Number,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.NullVal(cty.String),
-							"foo": cty.NumberIntVal(2),
-							"bar": cty.NumberIntVal(2),
-						}),
+						cty.List(cty.String),
+						cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("a")}),
 					),
 				},
 			},
-			expected: &tfprotov5.ValidateResourceTypeConfigResponse{
-				Diagnostics: []*tfprotov5.Diagnostic{
-					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "ValidateRawResourceConfigFunc Error",
-					},
-					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "ValidateRawResourceConfigFunc Error",
-					},
+		},
+		"unknown function": {
+			name: "unknown",
+			expected: &tfprotov5.CallFunctionResponse{
+				Error: &tfprotov5.FunctionError{
+					Text: "Function Not Found: No function named \"unknown\" was found in the provider.",
 				},
 			},
 		},
-		"Server with ValidateRawResourceConfigFunc: equal config value returns diags": {
-			server: NewGRPCProviderServer(&Provider{
-				ResourcesMap: map[string]*Resource{
-					"test_resource": {
-						ValidateRawResourceConfigFuncs: []ValidateRawResourceConfigFunc{
-							func(ctx context.Context, req ValidateResourceConfigFuncRequest, resp *ValidateResourceConfigFuncResponse) {
-								equals := req.RawConfig.Equals(cty.ObjectVal(map[string]cty.Value{
-									"id":  cty.NullVal(cty.String),
-									"foo": cty.NumberIntVal(2),
-									"bar": cty.NumberIntVal(2),
-								}))
-								if equals.True() {
-									resp.Diagnostics = diag.Diagnostics{
-										{
-											Severity: diag.Error,
-											Summary:  "ValidateRawResourceConfigFunc Error",
-										},
-									}
-								}
-							},
-							func(ctx context.Context, req ValidateResourceConfigFuncRequest, resp *ValidateResourceConfigFuncResponse) {
-								equals := req.RawConfig.Equals(cty.ObjectVal(map[string]cty.Value{
-									"id":  cty.NullVal(cty.String),
-									"foo": cty.NumberIntVal(2),
-									"bar": cty.NumberIntVal(2),
-								}))
-								if equals.True() {
-									resp.Diagnostics = diag.Diagnostics{
-										{
-											Severity: diag.Error,
-											Summary:  "ValidateRawResourceConfigFunc Error",
-										},
-									}
-								}
-							},
-						},
-						Schema: map[string]*Schema{
-							"foo": {
-								Type:     TypeInt,
-								Optional: true,
-							},
-							"bar": {
-								Type:     TypeInt,
-								Optional: true,
-							},
-						},
-					},
+		"wrong argument count": {
+			name:      "repeat",
+			arguments: []cty.Value{cty.StringVal("a")},
+			types:     []cty.Type{cty.String},
+			expected: &tfprotov5.CallFunctionResponse{
+				Error: &tfprotov5.FunctionError{
+					Text: "Invalid Function Arguments: Function \"repeat\" expects 2 arguments, got 1.",
 				},
-			}),
-			request: &tfprotov5.ValidateResourceTypeConfigRequest{
-				TypeName: "test_resource",
-				Config: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.Number,
-							"bar": cty.Number,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.NullVal(cty.String),
-							"foo": cty.NumberIntVal(2),
-							"bar": cty.NumberIntVal(2),
-						}),
-					),
+			},
+		},
+		"bad argument type": {
+			name:      "repeat",
+			arguments: []cty.Value{cty.StringVal("a"), cty.StringVal("two")},
+			types:     []cty.Type{cty.String, cty.String},
+			expected: &tfprotov5.CallFunctionResponse{
+				Error: &tfprotov5.FunctionError{
+					Text:             "Invalid value for \"count\" parameter: number is required.",
+					FunctionArgument: argIndex(1),
 				},
 			},
-			expected: &tfprotov5.ValidateResourceTypeConfigResponse{
-				Diagnostics: []*tfprotov5.Diagnostic{
-					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "ValidateRawResourceConfigFunc Error",
-					},
-					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "ValidateRawResourceConfigFunc Error",
-					},
+		},
+		"argument validation error": {
+			name:      "repeat",
+			arguments: []cty.Value{cty.StringVal("a"), cty.NumberIntVal(-1)},
+			types:     []cty.Type{cty.String, cty.Number},
+			expected: &tfprotov5.CallFunctionResponse{
+				Error: &tfprotov5.FunctionError{
+					Text:             "count must not be negative",
+					FunctionArgument: argIndex(1),
+				},
+			},
+		},
+		"run error": {
+			name:      "repeat",
+			arguments: []cty.Value{cty.StringVal(""), cty.NumberIntVal(1)},
+			types:     []cty.Type{cty.String, cty.Number},
+			expected: &tfprotov5.CallFunctionResponse{
+				Error: &tfprotov5.FunctionError{
+					Text: "value must not be empty",
 				},
 			},
 		},
@@ -4393,1566 +4967,7393 @@ func TestGRPCProviderServerValidateResourceTypeConfig(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			resp, err := testCase.server.ValidateResourceTypeConfig(context.Background(), testCase.request)
+			req := &tfprotov5.CallFunctionRequest{
+				Name: testCase.name,
+			}
 
-			if testCase.request != nil && err != nil {
-				t.Fatalf("unexpected error: %s", err)
+			for i, arg := range testCase.arguments {
+				req.Arguments = append(req.Arguments, &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(testCase.types[i], arg),
+				})
+			}
+
+			resp, err := server.CallFunction(context.Background(), req)
+			if err != nil {
+				t.Fatalf("unexpected gRPC error: %s", err)
 			}
 
 			if diff := cmp.Diff(resp, testCase.expected); diff != "" {
-				t.Errorf("unexpected difference: %s", diff)
+				t.Errorf("unexpected response difference: %s", diff)
 			}
 		})
 	}
 }
 
-func TestUpgradeState_jsonState(t *testing.T) {
-	r := &Resource{
-		SchemaVersion: 2,
-		Schema: map[string]*Schema{
-			"two": {
-				Type:     TypeInt,
-				Optional: true,
-			},
-		},
-	}
+func TestGRPCProviderServerCallFunction_metadataAndSchema(t *testing.T) {
+	t.Parallel()
 
-	r.StateUpgraders = []StateUpgrader{
-		{
-			Version: 0,
-			Type: cty.Object(map[string]cty.Type{
-				"id":   cty.String,
-				"zero": cty.Number,
-			}),
-			Upgrade: func(ctx context.Context, m map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
-				_, ok := m["zero"].(float64)
-				if !ok {
-					return nil, fmt.Errorf("zero not found in %#v", m)
-				}
-				m["one"] = float64(1)
-				delete(m, "zero")
-				return m, nil
-			},
-		},
-		{
-			Version: 1,
-			Type: cty.Object(map[string]cty.Type{
-				"id":  cty.String,
-				"one": cty.Number,
-			}),
-			Upgrade: func(ctx context.Context, m map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
-				_, ok := m["one"].(float64)
-				if !ok {
-					return nil, fmt.Errorf("one not found in %#v", m)
-				}
-				m["two"] = float64(2)
-				delete(m, "one")
-				return m, nil
+	server := (&Provider{
+		Functions: map[string]*Function{
+			"add": {
+				Summary: "Adds two numbers",
+				Parameters: []*Parameter{
+					{
+						Name: "a",
+						Type: cty.Number,
+					},
+					{
+						Name: "b",
+						Type: cty.Number,
+					},
+				},
+				Return: &Return{
+					Type: cty.Number,
+				},
+				Run: func(ctx context.Context, args []cty.Value) (interface{}, diag.Diagnostics) {
+					return args[0].Add(args[1]), nil
+				},
 			},
 		},
-	}
-
-	server := NewGRPCProviderServer(&Provider{
-		ResourcesMap: map[string]*Resource{
-			"test": r,
-		},
-	})
-
-	req := &tfprotov5.UpgradeResourceStateRequest{
-		TypeName: "test",
-		Version:  0,
-		RawState: &tfprotov5.RawState{
-			JSON: []byte(`{"id":"bar","zero":0}`),
-		},
-	}
+	}).GRPCProvider()
 
-	resp, err := server.UpgradeResourceState(context.Background(), req)
+	metadataResp, err := server.GetMetadata(context.Background(), &tfprotov5.GetMetadataRequest{})
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("unexpected gRPC error: %s", err)
 	}
 
-	if len(resp.Diagnostics) > 0 {
-		for _, d := range resp.Diagnostics {
-			t.Errorf("%#v", d)
-		}
-		t.Fatal("error")
+	expectedMetadata := []tfprotov5.FunctionMetadata{{Name: "add"}}
+	if diff := cmp.Diff(metadataResp.Functions, expectedMetadata); diff != "" {
+		t.Errorf("unexpected function metadata difference: %s", diff)
 	}
 
-	val, err := msgpack.Unmarshal(resp.UpgradedState.MsgPack, r.CoreConfigSchema().ImpliedType())
+	schemaResp, err := server.GetProviderSchema(context.Background(), &tfprotov5.GetProviderSchemaRequest{})
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("unexpected gRPC error: %s", err)
 	}
 
-	expected := cty.ObjectVal(map[string]cty.Value{
-		"id":  cty.StringVal("bar"),
-		"two": cty.NumberIntVal(2),
-	})
-
-	if !cmp.Equal(expected, val, valueComparer, equateEmpty) {
-		t.Fatal(cmp.Diff(expected, val, valueComparer, equateEmpty))
+	f, ok := schemaResp.Functions["add"]
+	if !ok {
+		t.Fatalf("expected function \"add\" in provider schema, got: %#v", schemaResp.Functions)
 	}
-}
 
-func TestUpgradeState_jsonStateBigInt(t *testing.T) {
-	r := &Resource{
-		UseJSONNumber: true,
-		SchemaVersion: 2,
-		Schema: map[string]*Schema{
-			"int": {
-				Type:     TypeInt,
-				Required: true,
-			},
-		},
+	if len(f.Parameters) != 2 || f.Summary != "Adds two numbers" {
+		t.Errorf("unexpected function definition: %#v", f)
 	}
 
-	server := NewGRPCProviderServer(&Provider{
-		ResourcesMap: map[string]*Resource{
-			"test": r,
+	callResp, err := server.CallFunction(context.Background(), &tfprotov5.CallFunctionRequest{
+		Name: "add",
+		Arguments: []*tfprotov5.DynamicValue{
+			{MsgPack: mustMsgpackMarshal(cty.Number, cty.NumberIntVal(2))},
+			{MsgPack: mustMsgpackMarshal(cty.Number, cty.NumberIntVal(3))},
 		},
 	})
-
-	req := &tfprotov5.UpgradeResourceStateRequest{
-		TypeName: "test",
-		Version:  0,
-		RawState: &tfprotov5.RawState{
-			JSON: []byte(`{"id":"bar","int":7227701560655103598}`),
-		},
-	}
-
-	resp, err := server.UpgradeResourceState(context.Background(), req)
 	if err != nil {
-		t.Fatal(err)
-	}
-
-	if len(resp.Diagnostics) > 0 {
-		for _, d := range resp.Diagnostics {
-			t.Errorf("%#v", d)
-		}
-		t.Fatal("error")
+		t.Fatalf("unexpected gRPC error: %s", err)
 	}
 
-	val, err := msgpack.Unmarshal(resp.UpgradedState.MsgPack, r.CoreConfigSchema().ImpliedType())
-	if err != nil {
-		t.Fatal(err)
+	if callResp.Error != nil {
+		t.Fatalf("unexpected function error: %s", callResp.Error.Text)
 	}
 
-	expected := cty.ObjectVal(map[string]cty.Value{
-		"id":  cty.StringVal("bar"),
-		"int": cty.NumberIntVal(7227701560655103598),
-	})
-
-	if !cmp.Equal(expected, val, valueComparer, equateEmpty) {
-		t.Fatal(cmp.Diff(expected, val, valueComparer, equateEmpty))
+	result := mustMsgpackUnmarshal(cty.Number, callResp.Result.MsgPack)
+	if !result.RawEquals(cty.NumberIntVal(5)) {
+		t.Errorf("expected result 5, got: %#v", result)
 	}
 }
 
-func TestUpgradeState_removedAttr(t *testing.T) {
-	r1 := &Resource{
-		Schema: map[string]*Schema{
-			"two": {
-				Type:     TypeString,
-				Optional: true,
-			},
-		},
-	}
+func TestGRPCProviderServerMoveResourceState(t *testing.T) {
+	t.Parallel()
 
-	r2 := &Resource{
-		Schema: map[string]*Schema{
-			"multi": {
-				Type:     TypeSet,
-				Optional: true,
-				Elem: &Resource{
-					Schema: map[string]*Schema{
-						"set": {
-							Type:     TypeSet,
-							Optional: true,
-							Elem: &Resource{
-								Schema: map[string]*Schema{
-									"required": {
-										Type:     TypeString,
-										Required: true,
-									},
-								},
-							},
-						},
+	testCases := map[string]struct {
+		server   *GRPCProviderServer
+		request  *tfprotov5.MoveResourceStateRequest
+		expected *tfprotov5.MoveResourceStateResponse
+	}{
+		"nil": {
+			server:   NewGRPCProviderServer(&Provider{}),
+			request:  nil,
+			expected: nil,
+		},
+		"request-TargetTypeName-missing": {
+			server: NewGRPCProviderServer(&Provider{}),
+			request: &tfprotov5.MoveResourceStateRequest{
+				TargetTypeName: "test_resource",
+			},
+			expected: &tfprotov5.MoveResourceStateResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "Unknown Resource Type",
+						Detail:   "The \"test_resource\" resource type is not supported by this provider.",
 					},
 				},
 			},
 		},
-	}
-
-	r3 := &Resource{
-		Schema: map[string]*Schema{
-			"config_mode_attr": {
-				Type:       TypeList,
-				ConfigMode: SchemaConfigModeAttr,
-				Optional:   true,
-				Elem: &Resource{
-					Schema: map[string]*Schema{
-						"foo": {
-							Type:     TypeString,
-							Optional: true,
-						},
+		"request-TargetTypeName": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_resource": {},
+				},
+			}),
+			request: &tfprotov5.MoveResourceStateRequest{
+				TargetTypeName: "test_resource",
+			},
+			expected: &tfprotov5.MoveResourceStateResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "Move Resource State Not Supported",
+						Detail:   "The \"test_resource\" resource type does not support moving resource state across resource types.",
 					},
 				},
 			},
 		},
-	}
-
-	p := &Provider{
-		ResourcesMap: map[string]*Resource{
-			"r1": r1,
-			"r2": r2,
-			"r3": r3,
-		},
-	}
-
-	server := NewGRPCProviderServer(p)
+		"MoveState-any-source-flatmap": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_resource_v2": {
+						Schema: map[string]*Schema{
+							"name": {
+								Type:     TypeString,
+								Required: true,
+							},
+							"tags": {
+								Type:     TypeList,
+								Optional: true,
+								Elem:     &Schema{Type: TypeString},
+							},
+						},
+						MoveState: []StateMover{
+							{
+								StateMover: func(ctx context.Context, req MoveStateRequest, resp *MoveStateResponse) {
+									if req.SourceTypeName != "test_resource_v1" || req.SourceSchemaVersion != 1 {
+										resp.Diagnostics = diag.Errorf("unexpected source: %s (version %d)", req.SourceTypeName, req.SourceSchemaVersion)
+										return
+									}
 
-	for _, tc := range []struct {
-		name     string
-		raw      string
-		expected cty.Value
-	}{
-		{
-			name: "r1",
-			raw:  `{"id":"bar","removed":"removed","two":"2"}`,
-			expected: cty.ObjectVal(map[string]cty.Value{
-				"id":  cty.StringVal("bar"),
-				"two": cty.StringVal("2"),
+									resp.TargetState = map[string]interface{}{
+										"id":   req.SourceRawState["id"],
+										"name": req.SourceRawState["old_name"],
+										"tags": []interface{}{req.SourceRawState["tags.0"]},
+									}
+								},
+							},
+						},
+					},
+				},
 			}),
-		},
-		{
-			name: "r2",
-			raw:  `{"id":"bar","multi":[{"set":[{"required":"ok","removed":"removed"}]}]}`,
-			expected: cty.ObjectVal(map[string]cty.Value{
-				"id": cty.StringVal("bar"),
-				"multi": cty.SetVal([]cty.Value{
-					cty.ObjectVal(map[string]cty.Value{
-						"set": cty.SetVal([]cty.Value{
-							cty.ObjectVal(map[string]cty.Value{
-								"required": cty.StringVal("ok"),
-							}),
+			request: &tfprotov5.MoveResourceStateRequest{
+				SourcePrivate:       []byte(`{"private":true}`),
+				SourceSchemaVersion: 1,
+				SourceState: &tfprotov5.RawState{
+					Flatmap: map[string]string{
+						"id":       "test-id",
+						"old_name": "test-name",
+						"tags.#":   "1",
+						"tags.0":   "test-tag",
+					},
+				},
+				SourceTypeName: "test_resource_v1",
+				TargetTypeName: "test_resource_v2",
+			},
+			expected: &tfprotov5.MoveResourceStateResponse{
+				TargetPrivate: []byte(`{"private":true}`),
+				TargetState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":   cty.String,
+							"name": cty.String,
+							"tags": cty.List(cty.String),
 						}),
-					}),
-				}),
-			}),
-		},
-		{
-			name: "r3",
-			raw:  `{"id":"bar","config_mode_attr":[{"foo":"ok","removed":"removed"}]}`,
-			expected: cty.ObjectVal(map[string]cty.Value{
-				"id": cty.StringVal("bar"),
-				"config_mode_attr": cty.ListVal([]cty.Value{
-					cty.ObjectVal(map[string]cty.Value{
-						"foo": cty.StringVal("ok"),
-					}),
-				}),
-			}),
-		},
-	} {
-		t.Run(tc.name, func(t *testing.T) {
-			req := &tfprotov5.UpgradeResourceStateRequest{
-				TypeName: tc.name,
-				Version:  0,
-				RawState: &tfprotov5.RawState{
-					JSON: []byte(tc.raw),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":   cty.StringVal("test-id"),
+							"name": cty.StringVal("test-name"),
+							"tags": cty.ListVal([]cty.Value{cty.StringVal("test-tag")}),
+						}),
+					),
 				},
-			}
-			resp, err := server.UpgradeResourceState(context.Background(), req)
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			if len(resp.Diagnostics) > 0 {
-				for _, d := range resp.Diagnostics {
-					t.Errorf("%#v", d)
-				}
-				t.Fatal("error")
-			}
-			val, err := msgpack.Unmarshal(resp.UpgradedState.MsgPack, p.ResourcesMap[tc.name].CoreConfigSchema().ImpliedType())
-			if err != nil {
-				t.Fatal(err)
-			}
-			if !tc.expected.RawEquals(val) {
-				t.Fatalf("\nexpected: %#v\ngot:      %#v\n", tc.expected, val)
-			}
-		})
-	}
-
-}
-
-func TestUpgradeState_flatmapState(t *testing.T) {
-	r := &Resource{
-		SchemaVersion: 4,
-		Schema: map[string]*Schema{
-			"four": {
-				Type:     TypeInt,
-				Required: true,
 			},
-			"block": {
-				Type:     TypeList,
-				Optional: true,
-				Elem: &Resource{
-					Schema: map[string]*Schema{
-						"attr": {
-							Type:     TypeString,
-							Optional: true,
+		},
+		"MoveState-any-source-json": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_resource_v2": {
+						Schema: map[string]*Schema{
+							"name": {
+								Type:     TypeString,
+								Required: true,
+							},
+						},
+						MoveState: []StateMover{
+							{
+								StateMover: func(ctx context.Context, req MoveStateRequest, resp *MoveStateResponse) {
+									req.SourceRawState["name"] = req.SourceRawState["old_name"]
+									resp.TargetState = req.SourceRawState
+								},
+							},
 						},
 					},
 				},
-			},
-		},
-		// this MigrateState will take the state to version 2
-		MigrateState: func(v int, is *terraform.InstanceState, _ interface{}) (*terraform.InstanceState, error) {
-			switch v {
-			case 0:
-				_, ok := is.Attributes["zero"]
-				if !ok {
-					return nil, fmt.Errorf("zero not found in %#v", is.Attributes)
-				}
-				is.Attributes["one"] = "1"
-				delete(is.Attributes, "zero")
-				fallthrough
-			case 1:
-				_, ok := is.Attributes["one"]
-				if !ok {
-					return nil, fmt.Errorf("one not found in %#v", is.Attributes)
-				}
-				is.Attributes["two"] = "2"
-				delete(is.Attributes, "one")
-			default:
-				return nil, fmt.Errorf("invalid schema version %d", v)
-			}
-			return is, nil
-		},
-	}
-
-	r.StateUpgraders = []StateUpgrader{
-		{
-			Version: 2,
-			Type: cty.Object(map[string]cty.Type{
-				"id":  cty.String,
-				"two": cty.Number,
 			}),
-			Upgrade: func(ctx context.Context, m map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
-				_, ok := m["two"].(float64)
-				if !ok {
-					return nil, fmt.Errorf("two not found in %#v", m)
-				}
-				m["three"] = float64(3)
-				delete(m, "two")
-				return m, nil
+			request: &tfprotov5.MoveResourceStateRequest{
+				SourceState: &tfprotov5.RawState{
+					JSON: []byte(`{"id":"test-id","old_name":"test-name"}`),
+				},
+				SourceTypeName: "test_resource_v1",
+				TargetTypeName: "test_resource_v2",
+			},
+			expected: &tfprotov5.MoveResourceStateResponse{
+				TargetState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":   cty.String,
+							"name": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":   cty.StringVal("test-id"),
+							"name": cty.StringVal("test-name"),
+						}),
+					),
+				},
 			},
 		},
-		{
-			Version: 3,
-			Type: cty.Object(map[string]cty.Type{
-				"id":    cty.String,
-				"three": cty.Number,
+		"MoveState-any-source-error": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_resource_v2": {
+						Schema: map[string]*Schema{
+							"name": {
+								Type:     TypeString,
+								Required: true,
+							},
+						},
+						MoveState: []StateMover{
+							{
+								StateMover: func(ctx context.Context, req MoveStateRequest, resp *MoveStateResponse) {
+									resp.Diagnostics = diag.Errorf("unsupported source resource type: %s", req.SourceTypeName)
+								},
+							},
+						},
+					},
+				},
 			}),
-			Upgrade: func(ctx context.Context, m map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
-				_, ok := m["three"].(float64)
-				if !ok {
-					return nil, fmt.Errorf("three not found in %#v", m)
-				}
-				m["four"] = float64(4)
-				delete(m, "three")
-				return m, nil
+			request: &tfprotov5.MoveResourceStateRequest{
+				SourceState: &tfprotov5.RawState{
+					JSON: []byte(`{"id":"test-id"}`),
+				},
+				SourceTypeName: "test_other",
+				TargetTypeName: "test_resource_v2",
 			},
-		},
-	}
-
-	server := NewGRPCProviderServer(&Provider{
-		ResourcesMap: map[string]*Resource{
-			"test": r,
-		},
-	})
-
-	testReqs := []*tfprotov5.UpgradeResourceStateRequest{
-		{
-			TypeName: "test",
-			Version:  0,
-			RawState: &tfprotov5.RawState{
-				Flatmap: map[string]string{
-					"id":   "bar",
-					"zero": "0",
+			expected: &tfprotov5.MoveResourceStateResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "unsupported source resource type: test_other",
+					},
 				},
 			},
 		},
-		{
-			TypeName: "test",
-			Version:  1,
-			RawState: &tfprotov5.RawState{
-				Flatmap: map[string]string{
-					"id":  "bar",
-					"one": "1",
+		"MoveState": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"new_type": {
+						Schema: map[string]*Schema{
+							"name": {
+								Type:     TypeString,
+								Required: true,
+							},
+						},
+						MoveState: []StateMover{
+							{
+								SourceTypeName: "other_type",
+								StateMover: func(ctx context.Context, req MoveStateRequest, resp *MoveStateResponse) {
+									resp.Diagnostics = diag.Errorf("unexpected mover called")
+								},
+							},
+							{
+								SourceTypeName: "old_type",
+								SourceSchema: map[string]*Schema{
+									"old_name": {
+										Type:     TypeString,
+										Required: true,
+									},
+								},
+								StateMover: func(ctx context.Context, req MoveStateRequest, resp *MoveStateResponse) {
+									resp.TargetState = map[string]interface{}{
+										"id":   req.SourceState.GetAttr("id").AsString(),
+										"name": req.SourceState.GetAttr("old_name").AsString(),
+									}
+								},
+							},
+							{
+								StateMover: func(ctx context.Context, req MoveStateRequest, resp *MoveStateResponse) {
+									resp.Diagnostics = diag.Errorf("unexpected mover called")
+								},
+							},
+						},
+					},
 				},
-			},
-		},
-		// two and  up could be stored in flatmap or json states
-		{
-			TypeName: "test",
-			Version:  2,
-			RawState: &tfprotov5.RawState{
-				Flatmap: map[string]string{
-					"id":  "bar",
-					"two": "2",
+			}),
+			request: &tfprotov5.MoveResourceStateRequest{
+				SourceState: &tfprotov5.RawState{
+					JSON: []byte(`{"id":"test-id","old_name":"test-name","removed":"test"}`),
 				},
+				SourceTypeName: "old_type",
+				TargetTypeName: "new_type",
 			},
-		},
-		{
-			TypeName: "test",
-			Version:  2,
-			RawState: &tfprotov5.RawState{
-				JSON: []byte(`{"id":"bar","two":2}`),
+			expected: &tfprotov5.MoveResourceStateResponse{
+				TargetState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":   cty.String,
+							"name": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":   cty.StringVal("test-id"),
+							"name": cty.StringVal("test-name"),
+						}),
+					),
+				},
 			},
 		},
-		{
-			TypeName: "test",
-			Version:  3,
-			RawState: &tfprotov5.RawState{
-				Flatmap: map[string]string{
-					"id":    "bar",
-					"three": "3",
+		"MoveState-flatmap-identity": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"new_type": {
+						Schema: map[string]*Schema{
+							"name": {
+								Type:     TypeString,
+								Required: true,
+							},
+						},
+						Identity: &ResourceIdentity{
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{
+									"name": {
+										Type:              TypeString,
+										RequiredForImport: true,
+									},
+								}
+							},
+						},
+						MoveState: []StateMover{
+							{
+								SourceTypeName: "old_type",
+								StateMover: func(ctx context.Context, req MoveStateRequest, resp *MoveStateResponse) {
+									resp.TargetState = map[string]interface{}{
+										"id":   req.SourceRawState["id"],
+										"name": req.SourceRawState["old_name"],
+									}
+									resp.TargetIdentity = map[string]interface{}{
+										"name": req.SourceRawIdentity["old_name"],
+									}
+								},
+							},
+						},
+					},
+				},
+			}),
+			request: &tfprotov5.MoveResourceStateRequest{
+				SourceState: &tfprotov5.RawState{
+					Flatmap: map[string]string{
+						"id":       "test-id",
+						"old_name": "test-name",
+					},
+				},
+				SourceIdentity: &tfprotov5.RawState{
+					JSON: []byte(`{"old_name":"test-name"}`),
 				},
+				SourceTypeName: "old_type",
+				TargetTypeName: "new_type",
 			},
-		},
-		{
-			TypeName: "test",
-			Version:  3,
-			RawState: &tfprotov5.RawState{
-				JSON: []byte(`{"id":"bar","three":3}`),
+			expected: &tfprotov5.MoveResourceStateResponse{
+				TargetState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":   cty.String,
+							"name": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":   cty.StringVal("test-id"),
+							"name": cty.StringVal("test-name"),
+						}),
+					),
+				},
+				TargetIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"name": cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"name": cty.StringVal("test-name"),
+							}),
+						),
+					},
+				},
 			},
 		},
-		{
-			TypeName: "test",
-			Version:  4,
-			RawState: &tfprotov5.RawState{
-				Flatmap: map[string]string{
-					"id":   "bar",
-					"four": "4",
+		"MoveState-unsupported-source": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"new_type": {
+						Schema: map[string]*Schema{
+							"name": {
+								Type:     TypeString,
+								Required: true,
+							},
+						},
+						MoveState: []StateMover{
+							{
+								SourceTypeName: "old_type",
+								StateMover: func(ctx context.Context, req MoveStateRequest, resp *MoveStateResponse) {
+									resp.Diagnostics = diag.Errorf("unexpected mover called")
+								},
+							},
+						},
+					},
+				},
+			}),
+			request: &tfprotov5.MoveResourceStateRequest{
+				SourceState: &tfprotov5.RawState{
+					JSON: []byte(`{"id":"test-id"}`),
 				},
+				SourceTypeName: "other_type",
+				TargetTypeName: "new_type",
 			},
-		},
-		{
-			TypeName: "test",
-			Version:  4,
-			RawState: &tfprotov5.RawState{
-				JSON: []byte(`{"id":"bar","four":4}`),
+			expected: &tfprotov5.MoveResourceStateResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "Move Resource State Not Supported",
+						Detail:   "The \"new_type\" resource type does not support moving resource state across resource types.",
+					},
+				},
 			},
 		},
 	}
 
-	for i, req := range testReqs {
-		t.Run(fmt.Sprintf("%d-%d", i, req.Version), func(t *testing.T) {
-			resp, err := server.UpgradeResourceState(context.Background(), req)
-			if err != nil {
-				t.Fatal(err)
-			}
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
 
-			if len(resp.Diagnostics) > 0 {
-				for _, d := range resp.Diagnostics {
-					t.Errorf("%#v", d)
-				}
-				t.Fatal("error")
-			}
+			resp, err := testCase.server.MoveResourceState(context.Background(), testCase.request)
 
-			val, err := msgpack.Unmarshal(resp.UpgradedState.MsgPack, r.CoreConfigSchema().ImpliedType())
-			if err != nil {
-				t.Fatal(err)
+			if testCase.request != nil && err != nil {
+				t.Fatalf("unexpected error: %s", err)
 			}
 
-			expected := cty.ObjectVal(map[string]cty.Value{
-				"block": cty.ListValEmpty(cty.Object(map[string]cty.Type{"attr": cty.String})),
-				"id":    cty.StringVal("bar"),
-				"four":  cty.NumberIntVal(4),
-			})
-
-			if !cmp.Equal(expected, val, valueComparer, equateEmpty) {
-				t.Fatal(cmp.Diff(expected, val, valueComparer, equateEmpty))
+			if diff := cmp.Diff(resp, testCase.expected); diff != "" {
+				t.Errorf("unexpected difference: %s", diff)
 			}
 		})
 	}
 }
 
-func TestUpgradeState_flatmapStateMissingMigrateState(t *testing.T) {
-	r := &Resource{
-		SchemaVersion: 1,
-		Schema: map[string]*Schema{
-			"one": {
-				Type:     TypeInt,
-				Required: true,
-			},
-		},
-	}
-
-	server := NewGRPCProviderServer(&Provider{
-		ResourcesMap: map[string]*Resource{
-			"test": r,
-		},
-	})
+func TestGRPCProviderServerValidateResourceTypeConfig(t *testing.T) {
+	t.Parallel()
 
-	testReqs := []*tfprotov5.UpgradeResourceStateRequest{
-		{
-			TypeName: "test",
-			Version:  0,
-			RawState: &tfprotov5.RawState{
-				Flatmap: map[string]string{
-					"id":  "bar",
-					"one": "1",
+	testCases := map[string]struct {
+		server   *GRPCProviderServer
+		request  *tfprotov5.ValidateResourceTypeConfigRequest
+		expected *tfprotov5.ValidateResourceTypeConfigResponse
+	}{
+		"Provider with empty resource returns no errors": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_resource": {},
 				},
-			},
-		},
-		{
-			TypeName: "test",
-			Version:  1,
-			RawState: &tfprotov5.RawState{
-				Flatmap: map[string]string{
-					"id":  "bar",
-					"one": "1",
+			}),
+			request: &tfprotov5.ValidateResourceTypeConfigRequest{
+				TypeName: "test_resource",
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id": cty.NullVal(cty.String),
+						}),
+					),
 				},
 			},
+			expected: &tfprotov5.ValidateResourceTypeConfigResponse{},
 		},
-		{
-			TypeName: "test",
-			Version:  1,
-			RawState: &tfprotov5.RawState{
-				JSON: []byte(`{"id":"bar","one":1}`),
-			},
-		},
-	}
-
-	for i, req := range testReqs {
-		t.Run(fmt.Sprintf("%d-%d", i, req.Version), func(t *testing.T) {
-			resp, err := server.UpgradeResourceState(context.Background(), req)
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			if len(resp.Diagnostics) > 0 {
-				for _, d := range resp.Diagnostics {
-					t.Errorf("%#v", d)
-				}
-				t.Fatal("error")
-			}
-
-			val, err := msgpack.Unmarshal(resp.UpgradedState.MsgPack, r.CoreConfigSchema().ImpliedType())
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			expected := cty.ObjectVal(map[string]cty.Value{
-				"id":  cty.StringVal("bar"),
-				"one": cty.NumberIntVal(1),
-			})
-
-			if !cmp.Equal(expected, val, valueComparer, equateEmpty) {
-				t.Fatal(cmp.Diff(expected, val, valueComparer, equateEmpty))
-			}
-		})
-	}
-}
-
-func TestUpgradeState_writeOnlyNullification(t *testing.T) {
-	r := &Resource{
-		SchemaVersion: 2,
-		Schema: map[string]*Schema{
-			"two": {
-				Type:      TypeInt,
-				Optional:  true,
-				WriteOnly: true,
-			},
-		},
-	}
-
-	r.StateUpgraders = []StateUpgrader{
-		{
-			Version: 0,
-			Type: cty.Object(map[string]cty.Type{
-				"id":   cty.String,
-				"zero": cty.Number,
-			}),
-			Upgrade: func(ctx context.Context, m map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
-				_, ok := m["zero"].(float64)
-				if !ok {
-					return nil, fmt.Errorf("zero not found in %#v", m)
-				}
-				m["one"] = float64(1)
-				delete(m, "zero")
-				return m, nil
-			},
-		},
-		{
-			Version: 1,
-			Type: cty.Object(map[string]cty.Type{
-				"id":  cty.String,
-				"one": cty.Number,
-			}),
-			Upgrade: func(ctx context.Context, m map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
-				_, ok := m["one"].(float64)
-				if !ok {
-					return nil, fmt.Errorf("one not found in %#v", m)
-				}
-				m["two"] = float64(2)
-				delete(m, "one")
-				return m, nil
-			},
-		},
-	}
-
-	server := NewGRPCProviderServer(&Provider{
-		ResourcesMap: map[string]*Resource{
-			"test": r,
-		},
-	})
-
-	req := &tfprotov5.UpgradeResourceStateRequest{
-		TypeName: "test",
-		Version:  0,
-		RawState: &tfprotov5.RawState{
-			JSON: []byte(`{"id":"bar","zero":0}`),
-		},
-	}
-
-	resp, err := server.UpgradeResourceState(context.Background(), req)
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	if len(resp.Diagnostics) > 0 {
-		for _, d := range resp.Diagnostics {
-			t.Errorf("%#v", d)
-		}
-		t.Fatal("error")
-	}
-
-	val, err := msgpack.Unmarshal(resp.UpgradedState.MsgPack, r.CoreConfigSchema().ImpliedType())
-	if err != nil {
-		t.Fatal(err)
-	}
-
-	expected := cty.ObjectVal(map[string]cty.Value{
-		"id":  cty.StringVal("bar"),
-		"two": cty.NullVal(cty.Number),
-	})
-
-	if !cmp.Equal(expected, val, valueComparer, equateEmpty) {
-		t.Fatal(cmp.Diff(expected, val, valueComparer, equateEmpty))
-	}
-}
-
-func TestReadResource(t *testing.T) {
-	t.Parallel()
-
-	testCases := map[string]struct {
-		server   *GRPCProviderServer
-		req      *tfprotov5.ReadResourceRequest
-		expected *tfprotov5.ReadResourceResponse
-	}{
-		"read-resource": {
+		"Client without WriteOnlyAttributesAllowed capabilities: null WriteOnly attribute returns no errors": {
 			server: NewGRPCProviderServer(&Provider{
 				ResourcesMap: map[string]*Resource{
-					"test": {
-						SchemaVersion: 1,
+					"test_resource": {
 						Schema: map[string]*Schema{
-							"id": {
-								Type:     TypeString,
-								Required: true,
-							},
-							"test_bool": {
-								Type:     TypeBool,
-								Computed: true,
-							},
-							"test_string": {
-								Type:     TypeString,
-								Computed: true,
-							},
-							"test_list": {
-								Type: TypeList,
-								Elem: &Schema{
-									Type: TypeString,
-								},
-								Computed: true,
-							},
-						},
-						Identity: &ResourceIdentity{
-							Version: 1,
-							SchemaFunc: func() map[string]*Schema {
-								return map[string]*Schema{
-									"instance_id": {
-										Type:              TypeString,
-										RequiredForImport: true,
-									},
-									"region": {
-										Type:              TypeString,
-										OptionalForImport: true,
-									},
-								}
+							"foo": {
+								Type:      TypeInt,
+								Optional:  true,
+								WriteOnly: true,
 							},
 						},
-						ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
-							err := d.Set("test_bool", true)
-							if err != nil {
-								return diag.FromErr(err)
-							}
-
-							err = d.Set("test_string", "new-state-val")
-							if err != nil {
-								return diag.FromErr(err)
-							}
-
-							identity, err := d.Identity()
-							if err != nil {
-								return diag.FromErr(err)
-							}
-							err = identity.Set("region", "new-region")
-							if err != nil {
-								return diag.FromErr(err)
-							}
-
-							return nil
-						},
 					},
 				},
 			}),
-			req: &tfprotov5.ReadResourceRequest{
-				TypeName: "test",
-				CurrentIdentity: &tfprotov5.ResourceIdentityData{
-					IdentityData: &tfprotov5.DynamicValue{
-						MsgPack: mustMsgpackMarshal(
-							cty.Object(map[string]cty.Type{
-								"instance_id": cty.String,
-								"region":      cty.String,
-							}),
-							cty.ObjectVal(map[string]cty.Value{
-								"instance_id": cty.StringVal("test-id"),
-								"region":      cty.StringVal("test-region"),
-							}),
-						),
-					},
-				},
-				CurrentState: &tfprotov5.DynamicValue{
+			request: &tfprotov5.ValidateResourceTypeConfigRequest{
+				TypeName: "test_resource",
+				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":          cty.String,
-							"test_bool":   cty.Bool,
-							"test_string": cty.String,
-							"test_list":   cty.List(cty.String),
+							"id":  cty.String,
+							"foo": cty.Number,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":          cty.StringVal("test-id"),
-							"test_bool":   cty.BoolVal(false),
-							"test_string": cty.StringVal("prior-state-val"),
-							"test_list": cty.ListVal([]cty.Value{
-								cty.StringVal("hello"),
-								cty.StringVal("world"),
-							}),
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.NullVal(cty.Number),
 						}),
 					),
 				},
 			},
-			expected: &tfprotov5.ReadResourceResponse{
-				NewState: &tfprotov5.DynamicValue{
+			expected: &tfprotov5.ValidateResourceTypeConfigResponse{},
+		},
+		"WarnOnCoercion default of a different type returns a warning": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_resource": {
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:           TypeString,
+								Optional:       true,
+								Default:        8080,
+								WarnOnCoercion: true,
+							},
+						},
+					},
+				},
+			}),
+			request: &tfprotov5.ValidateResourceTypeConfigRequest{
+				TypeName: "test_resource",
+				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":          cty.String,
-							"test_bool":   cty.Bool,
-							"test_string": cty.String,
-							"test_list":   cty.List(cty.String),
+							"id":  cty.String,
+							"foo": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":          cty.StringVal("test-id"),
-							"test_bool":   cty.BoolVal(true),
-							"test_string": cty.StringVal("new-state-val"),
-							"test_list": cty.ListVal([]cty.Value{
-								cty.StringVal("hello"),
-								cty.StringVal("world"),
-							}),
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.NullVal(cty.String),
 						}),
 					),
 				},
-				NewIdentity: &tfprotov5.ResourceIdentityData{
-					IdentityData: &tfprotov5.DynamicValue{
-						MsgPack: mustMsgpackMarshal(
-							cty.Object(map[string]cty.Type{
-								"instance_id": cty.String,
-								"region":      cty.String,
-							}),
-							cty.ObjectVal(map[string]cty.Value{
-								"instance_id": cty.StringVal("test-id"),
-								"region":      cty.StringVal("new-region"),
-							}),
-						),
+			},
+			expected: &tfprotov5.ValidateResourceTypeConfigResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity:  tfprotov5.DiagnosticSeverityWarning,
+						Summary:   "Value converted to a different type",
+						Detail:    "The number default value for \"foo\" was converted to a string.",
+						Attribute: tftypes.NewAttributePath().WithAttributeName("foo"),
 					},
 				},
 			},
 		},
-		"no-identity-schema": {
+		"Server without WriteOnlyAttributesAllowed capabilities: WriteOnly Attribute with Value returns an error": {
 			server: NewGRPCProviderServer(&Provider{
 				ResourcesMap: map[string]*Resource{
-					"test": {
-						SchemaVersion: 1,
-						Identity: &ResourceIdentity{
-							Version: 1,
+					"test_resource": {
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:      TypeInt,
+								Optional:  true,
+								WriteOnly: true,
+							},
 						},
 					},
 				},
 			}),
-			req: &tfprotov5.ReadResourceRequest{
-				TypeName: "test",
-				CurrentIdentity: &tfprotov5.ResourceIdentityData{
-					IdentityData: &tfprotov5.DynamicValue{
-						MsgPack: mustMsgpackMarshal(
-							cty.Object(map[string]cty.Type{
-								"instance_id": cty.String,
-							}),
-							cty.ObjectVal(map[string]cty.Value{
-								"instance_id": cty.StringVal("test-id"),
-							}),
-						),
-					},
-				},
-				CurrentState: &tfprotov5.DynamicValue{
+			request: &tfprotov5.ValidateResourceTypeConfigRequest{
+				TypeName: "test_resource",
+				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id": cty.String,
+							"id":  cty.String,
+							"foo": cty.Number,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id": cty.StringVal("test-id"),
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.NumberIntVal(2),
 						}),
 					),
 				},
 			},
-			expected: &tfprotov5.ReadResourceResponse{
+			expected: &tfprotov5.ValidateResourceTypeConfigResponse{
 				Diagnostics: []*tfprotov5.Diagnostic{
 					{
 						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "getting identity schema failed for resource 'test': resource does not have an identity schema",
+						Summary:  "Write-only Attribute Not Allowed",
+						Detail: "The resource contains a non-null value for write-only attribute \"foo\" " +
+							"Write-only attributes are only supported in Terraform 1.11 and later.",
+						Attribute: tftypes.NewAttributePath().WithAttributeName("foo"),
 					},
 				},
 			},
 		},
-		"empty-identity": {
+		"Server without WriteOnlyAttributesAllowed capabilities: multiple WriteOnly Attributes with Value returns multiple errors": {
 			server: NewGRPCProviderServer(&Provider{
 				ResourcesMap: map[string]*Resource{
-					"test": {
-						SchemaVersion: 1,
-						Identity: &ResourceIdentity{
-							Version: 1,
-							SchemaFunc: func() map[string]*Schema {
-								return map[string]*Schema{}
+					"test_resource": {
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:      TypeInt,
+								Optional:  true,
+								WriteOnly: true,
+							},
+							"bar": {
+								Type:      TypeInt,
+								Optional:  true,
+								WriteOnly: true,
 							},
 						},
 					},
 				},
 			}),
-			req: &tfprotov5.ReadResourceRequest{
-				TypeName: "test",
-				CurrentIdentity: &tfprotov5.ResourceIdentityData{
-					IdentityData: &tfprotov5.DynamicValue{
-						MsgPack: mustMsgpackMarshal(
-							cty.Object(map[string]cty.Type{
-								"instance_id": cty.String,
-							}),
-							cty.ObjectVal(map[string]cty.Value{
-								"instance_id": cty.StringVal("test-id"),
-							}),
-						),
-					},
-				},
-				CurrentState: &tfprotov5.DynamicValue{
+			request: &tfprotov5.ValidateResourceTypeConfigRequest{
+				TypeName: "test_resource",
+				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id": cty.String,
+							"id":  cty.String,
+							"foo": cty.Number,
+							"bar": cty.Number,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id": cty.StringVal("test-id"),
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.NumberIntVal(2),
+							"bar": cty.NumberIntVal(2),
 						}),
 					),
 				},
 			},
-			expected: &tfprotov5.ReadResourceResponse{
+			expected: &tfprotov5.ValidateResourceTypeConfigResponse{
 				Diagnostics: []*tfprotov5.Diagnostic{
 					{
 						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "getting identity schema failed for resource 'test': identity schema must have at least one attribute",
+						Summary:  "Write-only Attribute Not Allowed",
+						Detail: "The resource contains a non-null value for write-only attribute \"bar\" " +
+							"Write-only attributes are only supported in Terraform 1.11 and later.",
+						Attribute: tftypes.NewAttributePath().WithAttributeName("bar"),
+					},
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "Write-only Attribute Not Allowed",
+						Detail: "The resource contains a non-null value for write-only attribute \"foo\" " +
+							"Write-only attributes are only supported in Terraform 1.11 and later.",
+						Attribute: tftypes.NewAttributePath().WithAttributeName("foo"),
 					},
 				},
 			},
 		},
-		"deferred-response-unknown-val": {
+		"Server without WriteOnlyAttributesAllowed capabilities: multiple nested WriteOnly Attributes with Value returns multiple errors": {
 			server: NewGRPCProviderServer(&Provider{
-				// Deferred response will skip read function and return current state
-				providerDeferred: &Deferred{
-					Reason: DeferredReasonProviderConfigUnknown,
-				},
 				ResourcesMap: map[string]*Resource{
-					"test": {
-						SchemaVersion: 1,
+					"test_resource": {
 						Schema: map[string]*Schema{
-							"id": {
-								Type:     TypeString,
-								Required: true,
+							"foo": {
+								Type:      TypeInt,
+								Optional:  true,
+								WriteOnly: true,
 							},
-							"test_bool": {
-								Type:     TypeBool,
-								Computed: true,
+							"bar": {
+								Type:     TypeInt,
+								Optional: true,
 							},
-							"test_string": {
-								Type:     TypeString,
-								Computed: true,
+							"config_block_attr": {
+								Type:      TypeList,
+								Optional:  true,
+								WriteOnly: true,
+								Elem: &Resource{
+									Schema: map[string]*Schema{
+										"nested_attr": {
+											Type:     TypeString,
+											Optional: true,
+										},
+										"writeonly_nested_attr": {
+											Type:      TypeString,
+											WriteOnly: true,
+											Optional:  true,
+										},
+									},
+								},
 							},
 						},
-						ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
-							return diag.Errorf("Test assertion failed: read shouldn't be called when provider deferred response is present")
-						},
 					},
 				},
 			}),
-			req: &tfprotov5.ReadResourceRequest{
-				ClientCapabilities: &tfprotov5.ReadResourceClientCapabilities{
-					DeferralAllowed: true,
-				},
-				TypeName: "test",
-				CurrentState: &tfprotov5.DynamicValue{
+			request: &tfprotov5.ValidateResourceTypeConfigRequest{
+				TypeName: "test_resource",
+				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":          cty.String,
-							"test_bool":   cty.Bool,
-							"test_string": cty.String,
+							"id":  cty.String,
+							"foo": cty.Number,
+							"bar": cty.Number,
+							"config_block_attr": cty.List(cty.Object(map[string]cty.Type{
+								"nested_attr":           cty.String,
+								"writeonly_nested_attr": cty.String,
+							})),
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":          cty.StringVal("test-id"),
-							"test_bool":   cty.BoolVal(false),
-							"test_string": cty.StringVal("prior-state-val"),
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.NumberIntVal(2),
+							"bar": cty.NumberIntVal(2),
+							"config_block_attr": cty.ListVal([]cty.Value{
+								cty.ObjectVal(map[string]cty.Value{
+									"nested_attr":           cty.StringVal("value"),
+									"writeonly_nested_attr": cty.StringVal("value"),
+								}),
+							}),
 						}),
 					),
 				},
 			},
-			expected: &tfprotov5.ReadResourceResponse{
-				NewState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":          cty.String,
-							"test_bool":   cty.Bool,
-							"test_string": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":          cty.StringVal("test-id"),
-							"test_bool":   cty.BoolVal(false),
-							"test_string": cty.StringVal("prior-state-val"),
-						}),
-					),
-				},
-				Deferred: &tfprotov5.Deferred{
-					Reason: tfprotov5.DeferredReasonProviderConfigUnknown,
+			expected: &tfprotov5.ValidateResourceTypeConfigResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "Write-only Attribute Not Allowed",
+						Detail: "The resource contains a non-null value for write-only attribute \"foo\" " +
+							"Write-only attributes are only supported in Terraform 1.11 and later.",
+						Attribute: tftypes.NewAttributePath().WithAttributeName("foo"),
+					},
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "Write-only Attribute Not Allowed",
+						Detail: "The resource contains a non-null value for write-only attribute \"writeonly_nested_attr\" " +
+							"Write-only attributes are only supported in Terraform 1.11 and later.",
+						Attribute: tftypes.NewAttributePath().
+							WithAttributeName("config_block_attr").
+							WithElementKeyInt(0).
+							WithAttributeName("writeonly_nested_attr"),
+					},
 				},
 			},
 		},
-		"write-only values are nullified in ReadResourceResponse": {
+		"Server with ValidateRawResourceConfigFunc: WriteOnlyAttributesAllowed true returns diags": {
 			server: NewGRPCProviderServer(&Provider{
 				ResourcesMap: map[string]*Resource{
-					"test": {
-						SchemaVersion: 1,
-						Schema: map[string]*Schema{
-							"id": {
-								Type:     TypeString,
-								Required: true,
+					"test_resource": {
+						ValidateRawResourceConfigFuncs: []ValidateRawResourceConfigFunc{
+							func(ctx context.Context, req ValidateResourceConfigFuncRequest, resp *ValidateResourceConfigFuncResponse) {
+								if req.WriteOnlyAttributesAllowed {
+									resp.Diagnostics = diag.Diagnostics{
+										{
+											Severity: diag.Error,
+											Summary:  "ValidateRawResourceConfigFunc Error",
+										},
+									}
+								}
 							},
-							"test_bool": {
-								Type:     TypeBool,
-								Computed: true,
+							func(ctx context.Context, req ValidateResourceConfigFuncRequest, resp *ValidateResourceConfigFuncResponse) {
+								if req.WriteOnlyAttributesAllowed {
+									resp.Diagnostics = diag.Diagnostics{
+										{
+											Severity: diag.Error,
+											Summary:  "ValidateRawResourceConfigFunc Error",
+										},
+									}
+								}
 							},
-							"test_string": {
-								Type:     TypeString,
-								Computed: true,
-							},
-							"test_write_only": {
-								Type:      TypeString,
+						},
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:      TypeInt,
+								Optional:  true,
 								WriteOnly: true,
 							},
-						},
-						ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
-							err := d.Set("test_bool", true)
-							if err != nil {
-								return diag.FromErr(err)
-							}
-
-							err = d.Set("test_string", "new-state-val")
-							if err != nil {
-								return diag.FromErr(err)
-							}
-
-							err = d.Set("test_write_only", "write-only-val")
-							if err != nil {
-								return diag.FromErr(err)
-							}
-
-							return nil
+							"bar": {
+								Type:     TypeInt,
+								Optional: true,
+							},
 						},
 					},
 				},
 			}),
-			req: &tfprotov5.ReadResourceRequest{
-				TypeName: "test",
-				CurrentState: &tfprotov5.DynamicValue{
+			request: &tfprotov5.ValidateResourceTypeConfigRequest{
+				TypeName: "test_resource",
+				ClientCapabilities: &tfprotov5.ValidateResourceTypeConfigClientCapabilities{
+					WriteOnlyAttributesAllowed: true,
+				},
+				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":              cty.String,
-							"test_bool":       cty.Bool,
-							"test_string":     cty.String,
-							"test_write_only": cty.String,
+							"id":  cty.String,
+							"foo": cty.Number,
+							"bar": cty.Number,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":              cty.StringVal("test-id"),
-							"test_bool":       cty.BoolVal(false),
-							"test_string":     cty.StringVal("prior-state-val"),
-							"test_write_only": cty.NullVal(cty.String),
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.NumberIntVal(2),
+							"bar": cty.NumberIntVal(2),
 						}),
 					),
 				},
 			},
-			expected: &tfprotov5.ReadResourceResponse{
-				NewState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":              cty.String,
-							"test_bool":       cty.Bool,
-							"test_string":     cty.String,
-							"test_write_only": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":              cty.StringVal("test-id"),
-							"test_bool":       cty.BoolVal(true),
-							"test_string":     cty.StringVal("new-state-val"),
-							"test_write_only": cty.NullVal(cty.String),
-						}),
-					),
+			expected: &tfprotov5.ValidateResourceTypeConfigResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "ValidateRawResourceConfigFunc Error",
+					},
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "ValidateRawResourceConfigFunc Error",
+					},
 				},
 			},
 		},
-	}
-
-	for name, testCase := range testCases {
-		t.Run(name, func(t *testing.T) {
-			t.Parallel()
-			resp, err := testCase.server.ReadResource(context.Background(), testCase.req)
-
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			if diff := cmp.Diff(resp, testCase.expected, valueComparer); diff != "" {
-				ty := testCase.server.getResourceSchemaBlock("test").ImpliedType()
-
-				if resp != nil && resp.NewState != nil {
-					t.Logf("resp.NewState.MsgPack: %s", mustMsgpackUnmarshal(ty, resp.NewState.MsgPack))
-				}
-
-				if testCase.expected != nil && testCase.expected.NewState != nil {
-					t.Logf("expected: %s", mustMsgpackUnmarshal(ty, testCase.expected.NewState.MsgPack))
-				}
-
-				t.Error(diff)
-			}
-		})
-	}
-}
-
-func TestPlanResourceChange(t *testing.T) {
-	t.Parallel()
-
-	testCases := map[string]struct {
-		server   *GRPCProviderServer
-		req      *tfprotov5.PlanResourceChangeRequest
-		expected *tfprotov5.PlanResourceChangeResponse
-	}{
-		"basic-plan": {
+		"Server with ValidateRawResourceConfigFunc: WriteOnlyAttributesAllowed false returns diags": {
 			server: NewGRPCProviderServer(&Provider{
 				ResourcesMap: map[string]*Resource{
-					"test": {
-						SchemaVersion: 4,
+					"test_resource": {
+						ValidateRawResourceConfigFuncs: []ValidateRawResourceConfigFunc{
+							func(ctx context.Context, req ValidateResourceConfigFuncRequest, resp *ValidateResourceConfigFuncResponse) {
+								if !req.WriteOnlyAttributesAllowed {
+									resp.Diagnostics = diag.Diagnostics{
+										{
+											Severity: diag.Error,
+											Summary:  "ValidateRawResourceConfigFunc Error",
+										},
+									}
+								}
+							},
+							func(ctx context.Context, req ValidateResourceConfigFuncRequest, resp *ValidateResourceConfigFuncResponse) {
+								if !req.WriteOnlyAttributesAllowed {
+									resp.Diagnostics = diag.Diagnostics{
+										{
+											Severity: diag.Error,
+											Summary:  "ValidateRawResourceConfigFunc Error",
+										},
+									}
+								}
+							},
+						},
 						Schema: map[string]*Schema{
 							"foo": {
 								Type:     TypeInt,
 								Optional: true,
 							},
+							"bar": {
+								Type:     TypeInt,
+								Optional: true,
+							},
 						},
 					},
 				},
 			}),
-			req: &tfprotov5.PlanResourceChangeRequest{
-				TypeName: "test",
-				PriorState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"foo": cty.Number,
-						}),
-						cty.NullVal(
-							cty.Object(map[string]cty.Type{
-								"foo": cty.Number,
-							}),
-						),
-					),
-				},
-				ProposedNewState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.Number,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.NullVal(cty.Number),
-						}),
-					),
-				},
+			request: &tfprotov5.ValidateResourceTypeConfigRequest{
+				TypeName: "test_resource",
 				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
 							"id":  cty.String,
 							"foo": cty.Number,
+							"bar": cty.Number,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
 							"id":  cty.NullVal(cty.String),
-							"foo": cty.NullVal(cty.Number),
+							"foo": cty.NumberIntVal(2),
+							"bar": cty.NumberIntVal(2),
 						}),
 					),
 				},
 			},
-			expected: &tfprotov5.PlanResourceChangeResponse{
-				PlannedState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.Number,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.NullVal(cty.Number),
-						}),
-					),
-				},
-				RequiresReplace: []*tftypes.AttributePath{
-					tftypes.NewAttributePath().WithAttributeName("id"),
+			expected: &tfprotov5.ValidateResourceTypeConfigResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "ValidateRawResourceConfigFunc Error",
+					},
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "ValidateRawResourceConfigFunc Error",
+					},
 				},
-				PlannedPrivate:              []byte(`{"_new_extra_shim":{}}`),
-				UnsafeToUseLegacyTypeSystem: true,
 			},
 		},
-		"basic-plan-with-identity": {
+		"Server with ValidateRawResourceConfigFunc: cty.Path diagnostic paths": {
 			server: NewGRPCProviderServer(&Provider{
 				ResourcesMap: map[string]*Resource{
-					"test": {
-						SchemaVersion: 4,
-						Schema: map[string]*Schema{
-							"foo": {
-								Type:     TypeInt,
-								Optional: true,
+					"test_resource": {
+						ValidateRawResourceConfigFuncs: []ValidateRawResourceConfigFunc{
+							func(ctx context.Context, req ValidateResourceConfigFuncRequest, resp *ValidateResourceConfigFuncResponse) {
+								rules := req.RawConfig.GetAttr("rule").AsValueSlice()
+								for i, rule := range rules {
+									for key := range rule.GetAttr("tags").AsValueMap() {
+										path := cty.GetAttrPath("rule").Index(cty.NumberIntVal(int64(i))).GetAttr("tags").Index(cty.StringVal(key))
+										resp.Diagnostics = append(resp.Diagnostics, diag.Diagnostic{
+											Severity: diag.Error,
+											Summary:  "Invalid tag",
+										}.WithPath(path))
+									}
+								}
 							},
 						},
-						Identity: &ResourceIdentity{
-							Version: 1,
-							SchemaFunc: func() map[string]*Schema {
-								return map[string]*Schema{
-									"name": {
-										Type:              TypeString,
-										RequiredForImport: true,
+						Schema: map[string]*Schema{
+							"rule": {
+								Type:     TypeList,
+								Optional: true,
+								Elem: &Resource{
+									Schema: map[string]*Schema{
+										"tags": {
+											Type:     TypeMap,
+											Optional: true,
+											Elem:     &Schema{Type: TypeString},
+										},
 									},
-								}
+								},
 							},
 						},
 					},
 				},
 			}),
-			req: &tfprotov5.PlanResourceChangeRequest{
-				TypeName: "test",
-				PriorState: &tfprotov5.DynamicValue{
+			request: &tfprotov5.ValidateResourceTypeConfigRequest{
+				TypeName: "test_resource",
+				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"foo": cty.Number,
+							"id": cty.String,
+							"rule": cty.List(cty.Object(map[string]cty.Type{
+								"tags": cty.Map(cty.String),
+							})),
 						}),
-						cty.NullVal(
-							cty.Object(map[string]cty.Type{
-								"foo": cty.Number,
+						cty.ObjectVal(map[string]cty.Value{
+							"id": cty.NullVal(cty.String),
+							"rule": cty.ListVal([]cty.Value{
+								cty.ObjectVal(map[string]cty.Value{
+									"tags": cty.MapValEmpty(cty.String),
+								}),
+								cty.ObjectVal(map[string]cty.Value{
+									"tags": cty.MapVal(map[string]cty.Value{
+										"env": cty.StringVal("prod"),
+									}),
+								}),
 							}),
-						),
+						}),
 					),
 				},
-				ProposedNewState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.Number,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.NullVal(cty.Number),
-						}),
-					),
+			},
+			expected: &tfprotov5.ValidateResourceTypeConfigResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity:  tfprotov5.DiagnosticSeverityError,
+						Summary:   "Invalid tag",
+						Attribute: tftypes.NewAttributePath().WithAttributeName("rule").WithElementKeyInt(1).WithAttributeName("tags").WithElementKeyString("env"),
+					},
+				},
+			},
+		},
+		"Server with ValidateRawResourceConfigFunc: equal config value returns diags": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_resource": {
+						ValidateRawResourceConfigFuncs: []ValidateRawResourceConfigFunc{
+							func(ctx context.Context, req ValidateResourceConfigFuncRequest, resp *ValidateResourceConfigFuncResponse) {
+								equals := req.RawConfig.Equals(cty.ObjectVal(map[string]cty.Value{
+									"id":  cty.NullVal(cty.String),
+									"foo": cty.NumberIntVal(2),
+									"bar": cty.NumberIntVal(2),
+								}))
+								if equals.True() {
+									resp.Diagnostics = diag.Diagnostics{
+										{
+											Severity: diag.Error,
+											Summary:  "ValidateRawResourceConfigFunc Error",
+										},
+									}
+								}
+							},
+							func(ctx context.Context, req ValidateResourceConfigFuncRequest, resp *ValidateResourceConfigFuncResponse) {
+								equals := req.RawConfig.Equals(cty.ObjectVal(map[string]cty.Value{
+									"id":  cty.NullVal(cty.String),
+									"foo": cty.NumberIntVal(2),
+									"bar": cty.NumberIntVal(2),
+								}))
+								if equals.True() {
+									resp.Diagnostics = diag.Diagnostics{
+										{
+											Severity: diag.Error,
+											Summary:  "ValidateRawResourceConfigFunc Error",
+										},
+									}
+								}
+							},
+						},
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeInt,
+								Optional: true,
+							},
+							"bar": {
+								Type:     TypeInt,
+								Optional: true,
+							},
+						},
+					},
 				},
+			}),
+			request: &tfprotov5.ValidateResourceTypeConfigRequest{
+				TypeName: "test_resource",
 				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
 							"id":  cty.String,
 							"foo": cty.Number,
+							"bar": cty.Number,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
 							"id":  cty.NullVal(cty.String),
-							"foo": cty.NullVal(cty.Number),
+							"foo": cty.NumberIntVal(2),
+							"bar": cty.NumberIntVal(2),
 						}),
 					),
 				},
-				PriorIdentity: &tfprotov5.ResourceIdentityData{
-					IdentityData: &tfprotov5.DynamicValue{
-						MsgPack: mustMsgpackMarshal(
-							cty.Object(map[string]cty.Type{
-								"name": cty.String,
-							}),
-							cty.ObjectVal(map[string]cty.Value{
-								"name": cty.StringVal("test-name"),
-							}),
-						),
+			},
+			expected: &tfprotov5.ValidateResourceTypeConfigResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "ValidateRawResourceConfigFunc Error",
+					},
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "ValidateRawResourceConfigFunc Error",
 					},
 				},
 			},
-			expected: &tfprotov5.PlanResourceChangeResponse{
-				PlannedState: &tfprotov5.DynamicValue{
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, err := testCase.server.ValidateResourceTypeConfig(context.Background(), testCase.request)
+
+			if testCase.request != nil && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if diff := cmp.Diff(resp, testCase.expected); diff != "" {
+				t.Errorf("unexpected difference: %s", diff)
+			}
+		})
+	}
+}
+
+func TestGRPCProviderServerValidateDataSourceConfig(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		server   *GRPCProviderServer
+		request  *tfprotov5.ValidateDataSourceConfigRequest
+		expected *tfprotov5.ValidateDataSourceConfigResponse
+	}{
+		"Server without ValidateRawDataSourceConfigFuncs returns no errors": {
+			server: NewGRPCProviderServer(&Provider{
+				DataSourcesMap: map[string]*Resource{
+					"test_data_source": {
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeInt,
+								Optional: true,
+							},
+						},
+					},
+				},
+			}),
+			request: &tfprotov5.ValidateDataSourceConfigRequest{
+				TypeName: "test_data_source",
+				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
 							"id":  cty.String,
 							"foo": cty.Number,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.NullVal(cty.Number),
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.NumberIntVal(2),
 						}),
 					),
 				},
-				RequiresReplace: []*tftypes.AttributePath{
-					tftypes.NewAttributePath().WithAttributeName("id"),
-				},
-				PlannedPrivate:              []byte(`{"_new_extra_shim":{}}`),
-				UnsafeToUseLegacyTypeSystem: true,
-				PlannedIdentity: &tfprotov5.ResourceIdentityData{
-					IdentityData: &tfprotov5.DynamicValue{
-						MsgPack: mustMsgpackMarshal(
-							cty.Object(map[string]cty.Type{
-								"name": cty.String,
-							}),
-							cty.ObjectVal(map[string]cty.Value{
-								"name": cty.StringVal("test-name"),
-							}),
-						),
-					},
-				},
 			},
+			expected: &tfprotov5.ValidateDataSourceConfigResponse{},
 		},
-		"new-resource-with-identity": {
+		"Server with ValidateRawDataSourceConfigFuncs: multiple functions accumulate diags": {
 			server: NewGRPCProviderServer(&Provider{
-				ResourcesMap: map[string]*Resource{
-					"test": {
-						SchemaVersion: 4,
-						Schema: map[string]*Schema{
-							"foo": {
-								Type:     TypeString,
-								Optional: true,
+				DataSourcesMap: map[string]*Resource{
+					"test_data_source": {
+						ValidateRawDataSourceConfigFuncs: []ValidateDataSourceConfigFunc{
+							func(ctx context.Context, req ValidateDataSourceConfigFuncRequest, resp *ValidateDataSourceConfigFuncResponse) {
+								resp.Diagnostics = diag.Diagnostics{
+									{
+										Severity: diag.Warning,
+										Summary:  "ValidateDataSourceConfigFunc Warning",
+									},
+								}
 							},
-						},
-						Identity: &ResourceIdentity{
-							Version: 1,
-							SchemaFunc: func() map[string]*Schema {
-								return map[string]*Schema{
-									"name": {
-										Type:              TypeString,
-										RequiredForImport: true,
+							func(ctx context.Context, req ValidateDataSourceConfigFuncRequest, resp *ValidateDataSourceConfigFuncResponse) {
+								resp.Diagnostics = diag.Diagnostics{
+									{
+										Severity: diag.Error,
+										Summary:  "ValidateDataSourceConfigFunc Error",
 									},
 								}
 							},
 						},
-						CustomizeDiff: func(ctx context.Context, d *ResourceDiff, meta interface{}) error {
-							identity, err := d.Identity()
-							if err != nil {
-								return err
-							}
-							err = identity.Set("name", "Peter")
-							if err != nil {
-								return err
-							}
-							return nil
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeInt,
+								Optional: true,
+							},
 						},
 					},
 				},
 			}),
-			req: &tfprotov5.PlanResourceChangeRequest{
-				TypeName: "test",
-				PriorState: &tfprotov5.DynamicValue{
+			request: &tfprotov5.ValidateDataSourceConfigRequest{
+				TypeName: "test_data_source",
+				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
 							"id":  cty.String,
-							"foo": cty.String,
+							"foo": cty.Number,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.StringVal("baz"),
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.NumberIntVal(2),
 						}),
 					),
 				},
-				ProposedNewState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.StringVal("baz"),
-						}),
-					),
+			},
+			expected: &tfprotov5.ValidateDataSourceConfigResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityWarning,
+						Summary:  "ValidateDataSourceConfigFunc Warning",
+					},
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "ValidateDataSourceConfigFunc Error",
+					},
+				},
+			},
+		},
+		"Server with ValidateRawDataSourceConfigFunc: equal config value returns diags": {
+			server: NewGRPCProviderServer(&Provider{
+				DataSourcesMap: map[string]*Resource{
+					"test_data_source": {
+						ValidateRawDataSourceConfigFuncs: []ValidateDataSourceConfigFunc{
+							func(ctx context.Context, req ValidateDataSourceConfigFuncRequest, resp *ValidateDataSourceConfigFuncResponse) {
+								equals := req.RawConfig.Equals(cty.ObjectVal(map[string]cty.Value{
+									"id":  cty.NullVal(cty.String),
+									"foo": cty.NumberIntVal(2),
+								}))
+								if equals.True() {
+									resp.Diagnostics = diag.Diagnostics{
+										{
+											Severity: diag.Error,
+											Summary:  "ValidateDataSourceConfigFunc Error",
+										},
+									}
+								}
+							},
+						},
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeInt,
+								Optional: true,
+							},
+						},
+					},
 				},
+			}),
+			request: &tfprotov5.ValidateDataSourceConfigRequest{
+				TypeName: "test_data_source",
 				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
 							"id":  cty.String,
-							"foo": cty.String,
+							"foo": cty.Number,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
 							"id":  cty.NullVal(cty.String),
-							"foo": cty.StringVal("baz"),
+							"foo": cty.NumberIntVal(2),
 						}),
 					),
 				},
 			},
-			expected: &tfprotov5.PlanResourceChangeResponse{
-				PlannedState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.StringVal("baz"),
-						}),
-					),
-				},
-				RequiresReplace: []*tftypes.AttributePath{
-					tftypes.NewAttributePath().WithAttributeName("id"),
-				},
-				PlannedPrivate:              []byte(`{"_new_extra_shim":{}}`),
-				UnsafeToUseLegacyTypeSystem: true,
-				PlannedIdentity: &tfprotov5.ResourceIdentityData{
-					IdentityData: &tfprotov5.DynamicValue{
-						MsgPack: mustMsgpackMarshal(
-							cty.Object(map[string]cty.Type{
-								"name": cty.String,
-							}),
-							cty.ObjectVal(map[string]cty.Value{
-								"name": cty.StringVal("Peter"),
-							}),
-						),
+			expected: &tfprotov5.ValidateDataSourceConfigResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "ValidateDataSourceConfigFunc Error",
 					},
 				},
 			},
 		},
-		"no identity schema": {
-			server: NewGRPCProviderServer(&Provider{
-				ResourcesMap: map[string]*Resource{
-					"test": {
-						SchemaVersion: 4,
-						Schema: map[string]*Schema{
-							"foo": {
-								Type:     TypeInt,
-								Optional: true,
-							},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, err := testCase.server.ValidateDataSourceConfig(context.Background(), testCase.request)
+
+			if testCase.request != nil && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if diff := cmp.Diff(resp, testCase.expected); diff != "" {
+				t.Errorf("unexpected difference: %s", diff)
+			}
+		})
+	}
+}
+
+func TestGRPCProviderServerValidateResourceTypeConfig_constraints(t *testing.T) {
+	t.Parallel()
+
+	r := &Resource{
+		Schema: map[string]*Schema{
+			"block": {
+				Type:     TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &Resource{
+					Schema: map[string]*Schema{
+						"conflicting_a": {
+							Type:          TypeString,
+							Optional:      true,
+							ConflictsWith: []string{"block.0.conflicting_b"},
 						},
-						Identity: &ResourceIdentity{
-							Version: 1,
+						"conflicting_b": {
+							Type:     TypeString,
+							Optional: true,
+						},
+						"exactly_a": {
+							Type:          TypeString,
+							Optional:      true,
+							ExactlyOneOf:  []string{"block.0.exactly_a", "block.0.exactly_b"},
+							ConflictsWith: []string{"block.0.conflicting_b"},
+						},
+						"exactly_b": {
+							Type:         TypeString,
+							Optional:     true,
+							ExactlyOneOf: []string{"block.0.exactly_a", "block.0.exactly_b"},
+						},
+						"at_least_a": {
+							Type:         TypeString,
+							Optional:     true,
+							AtLeastOneOf: []string{"block.0.at_least_a", "block.0.at_least_b"},
+						},
+						"at_least_b": {
+							Type:         TypeString,
+							Optional:     true,
+							AtLeastOneOf: []string{"block.0.at_least_a", "block.0.at_least_b"},
 						},
 					},
 				},
+			},
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": r,
+		},
+	})
+
+	ty := r.CoreConfigSchema().ImpliedType()
+
+	blockVal := func(vals map[string]cty.Value) cty.Value {
+		attrs := map[string]cty.Value{}
+		for name := range ty.AttributeType("block").ElementType().AttributeTypes() {
+			attrs[name] = cty.NullVal(cty.String)
+		}
+		for name, val := range vals {
+			attrs[name] = val
+		}
+		return cty.ObjectVal(map[string]cty.Value{
+			"id":    cty.NullVal(cty.String),
+			"block": cty.ListVal([]cty.Value{cty.ObjectVal(attrs)}),
+		})
+	}
+
+	blockPath := func(name string) *tftypes.AttributePath {
+		return tftypes.NewAttributePath().WithAttributeName("block").WithElementKeyInt(0).WithAttributeName(name)
+	}
+
+	testCases := map[string]struct {
+		Config   cty.Value
+		Expected []*tfprotov5.Diagnostic
+	}{
+		"valid": {
+			Config: blockVal(map[string]cty.Value{
+				"conflicting_a": cty.StringVal("a"),
+				"exactly_a":     cty.StringVal("a"),
+				"at_least_b":    cty.StringVal("b"),
 			}),
-			req: &tfprotov5.PlanResourceChangeRequest{
-				TypeName: "test",
-				PriorState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"foo": cty.Number,
-						}),
-						cty.NullVal(
-							cty.Object(map[string]cty.Type{
-								"foo": cty.Number,
-							}),
-						),
-					),
-				},
-				ProposedNewState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.Number,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.NullVal(cty.Number),
-						}),
-					),
+		},
+		"ConflictsWith": {
+			Config: blockVal(map[string]cty.Value{
+				"conflicting_a": cty.StringVal("a"),
+				"conflicting_b": cty.StringVal("b"),
+				"exactly_b":     cty.StringVal("b"),
+				"at_least_a":    cty.StringVal("a"),
+			}),
+			Expected: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "Conflicting configuration arguments",
+					Detail:    `"block.0.conflicting_a": conflicts with block.0.conflicting_b`,
+					Attribute: blockPath("conflicting_a"),
 				},
-				Config: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.Number,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.NullVal(cty.String),
-							"foo": cty.NullVal(cty.Number),
-						}),
-					),
+			},
+		},
+		"ExactlyOneOf none specified": {
+			Config: blockVal(map[string]cty.Value{
+				"at_least_a": cty.StringVal("a"),
+			}),
+			Expected: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "Invalid combination of arguments",
+					Detail:    "\"block.0.exactly_a\": one of `block.0.exactly_a,block.0.exactly_b` must be specified",
+					Attribute: blockPath("exactly_a"),
 				},
-				PriorIdentity: &tfprotov5.ResourceIdentityData{
-					IdentityData: &tfprotov5.DynamicValue{
-						MsgPack: mustMsgpackMarshal(
-							cty.Object(map[string]cty.Type{
-								"name": cty.String,
-							}),
-							cty.ObjectVal(map[string]cty.Value{
-								"name": cty.StringVal("test-name"),
-							}),
-						),
-					},
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "Invalid combination of arguments",
+					Detail:    "\"block.0.exactly_b\": one of `block.0.exactly_a,block.0.exactly_b` must be specified",
+					Attribute: blockPath("exactly_b"),
 				},
 			},
-			expected: &tfprotov5.PlanResourceChangeResponse{
-				Diagnostics: []*tfprotov5.Diagnostic{
-					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "getting identity schema failed for resource 'test': resource does not have an identity schema",
-					},
+		},
+		"AtLeastOneOf none specified": {
+			Config: blockVal(map[string]cty.Value{
+				"exactly_a": cty.StringVal("a"),
+			}),
+			Expected: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "Missing required argument",
+					Detail:    "\"block.0.at_least_a\": one of `block.0.at_least_a,block.0.at_least_b` must be specified",
+					Attribute: blockPath("at_least_a"),
+				},
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "Missing required argument",
+					Detail:    "\"block.0.at_least_b\": one of `block.0.at_least_a,block.0.at_least_b` must be specified",
+					Attribute: blockPath("at_least_b"),
 				},
-				UnsafeToUseLegacyTypeSystem: true,
 			},
 		},
-		"empty identity schema": {
-			server: NewGRPCProviderServer(&Provider{
-				ResourcesMap: map[string]*Resource{
-					"test": {
-						SchemaVersion: 4,
-						Schema: map[string]*Schema{
-							"foo": {
-								Type:     TypeInt,
-								Optional: true,
-							},
-						},
-						Identity: &ResourceIdentity{
-							Version: 1,
-							SchemaFunc: func() map[string]*Schema {
-								return map[string]*Schema{}
-							},
-						},
-					},
-				},
+		"ExactlyOneOf and ConflictsWith on the same attribute": {
+			Config: blockVal(map[string]cty.Value{
+				"conflicting_b": cty.StringVal("b"),
+				"exactly_a":     cty.StringVal("a"),
+				"exactly_b":     cty.StringVal("b"),
+				"at_least_a":    cty.StringVal("a"),
 			}),
-			req: &tfprotov5.PlanResourceChangeRequest{
-				TypeName: "test",
-				PriorState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"foo": cty.Number,
-						}),
-						cty.NullVal(
-							cty.Object(map[string]cty.Type{
-								"foo": cty.Number,
-							}),
-						),
-					),
+			Expected: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "Conflicting configuration arguments",
+					Detail:    `"block.0.exactly_a": conflicts with block.0.conflicting_b`,
+					Attribute: blockPath("exactly_a"),
 				},
-				ProposedNewState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.Number,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.NullVal(cty.Number),
-						}),
-					),
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "Invalid combination of arguments",
+					Detail:    "\"block.0.exactly_a\": only one of `block.0.exactly_a,block.0.exactly_b` can be specified, but `block.0.exactly_a,block.0.exactly_b` were specified.",
+					Attribute: blockPath("exactly_a"),
 				},
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "Invalid combination of arguments",
+					Detail:    "\"block.0.exactly_b\": only one of `block.0.exactly_a,block.0.exactly_b` can be specified, but `block.0.exactly_a,block.0.exactly_b` were specified.",
+					Attribute: blockPath("exactly_b"),
+				},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, err := server.ValidateResourceTypeConfig(context.Background(), &tfprotov5.ValidateResourceTypeConfigRequest{
+				TypeName: "test",
 				Config: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.Number,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.NullVal(cty.String),
-							"foo": cty.NullVal(cty.Number),
-						}),
-					),
+					MsgPack: mustMsgpackMarshal(ty, testCase.Config),
 				},
-				PriorIdentity: &tfprotov5.ResourceIdentityData{
-					IdentityData: &tfprotov5.DynamicValue{
-						MsgPack: mustMsgpackMarshal(
-							cty.Object(map[string]cty.Type{
-								"name": cty.String,
-							}),
-							cty.ObjectVal(map[string]cty.Value{
-								"name": cty.StringVal("test-name"),
-							}),
-						),
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			// attributes are validated in map order
+			sort.Slice(resp.Diagnostics, func(i, j int) bool {
+				a, b := resp.Diagnostics[i], resp.Diagnostics[j]
+				if a.Attribute.String() != b.Attribute.String() {
+					return a.Attribute.String() < b.Attribute.String()
+				}
+				return a.Summary < b.Summary
+			})
+
+			if diff := cmp.Diff(testCase.Expected, resp.Diagnostics); diff != "" {
+				t.Errorf("unexpected difference: %s", diff)
+			}
+		})
+	}
+}
+
+func TestGRPCProviderServerValidateResourceTypeConfig_nestedValidateFunc(t *testing.T) {
+	t.Parallel()
+
+	validateName := func(v interface{}, k string) ([]string, []error) {
+		if v.(string) == "invalid" {
+			return nil, []error{fmt.Errorf("%s is invalid", k)}
+		}
+
+		return nil, nil
+	}
+
+	nestedBlock := func(t ValueType) *Schema {
+		return &Schema{
+			Type:     t,
+			Optional: true,
+			Elem: &Resource{
+				Schema: map[string]*Schema{
+					"name": {
+						Type:         TypeString,
+						Optional:     true,
+						ValidateFunc: validateName,
+					},
+					"inner": {
+						Type:     TypeList,
+						Optional: true,
+						Elem: &Resource{
+							Schema: map[string]*Schema{
+								"name": {
+									Type:         TypeString,
+									Optional:     true,
+									ValidateFunc: validateName,
+								},
+							},
+						},
 					},
 				},
 			},
-			expected: &tfprotov5.PlanResourceChangeResponse{
-				Diagnostics: []*tfprotov5.Diagnostic{
-					{
+		}
+	}
+
+	r := &Resource{
+		Schema: map[string]*Schema{
+			"list_block": nestedBlock(TypeList),
+			"set_block":  nestedBlock(TypeSet),
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": r,
+		},
+	})
+
+	ty := r.CoreConfigSchema().ImpliedType()
+	elemTy := ty.AttributeType("list_block").ElementType()
+	innerTy := elemTy.AttributeType("inner").ElementType()
+
+	elemVal := func(name string, innerNames ...string) cty.Value {
+		inner := cty.ListValEmpty(innerTy)
+		if len(innerNames) > 0 {
+			var innerVals []cty.Value
+			for _, innerName := range innerNames {
+				innerVals = append(innerVals, cty.ObjectVal(map[string]cty.Value{
+					"name": cty.StringVal(innerName),
+				}))
+			}
+			inner = cty.ListVal(innerVals)
+		}
+
+		return cty.ObjectVal(map[string]cty.Value{
+			"name":  cty.StringVal(name),
+			"inner": inner,
+		})
+	}
+
+	configVal := func(listElems, setElems []cty.Value) cty.Value {
+		listVal := cty.NullVal(cty.List(elemTy))
+		if len(listElems) > 0 {
+			listVal = cty.ListVal(listElems)
+		}
+
+		setVal := cty.NullVal(cty.Set(elemTy))
+		if len(setElems) > 0 {
+			setVal = cty.SetVal(setElems)
+		}
+
+		return cty.ObjectVal(map[string]cty.Value{
+			"id":         cty.NullVal(cty.String),
+			"list_block": listVal,
+			"set_block":  setVal,
+		})
+	}
+
+	elemKey := func(val cty.Value) tftypes.Value {
+		tfType, err := convert.TFTypeFromCtyType(elemTy)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		tfVal, err := (&tfprotov5.DynamicValue{MsgPack: mustMsgpackMarshal(elemTy, val)}).Unmarshal(tfType)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+
+		return tfVal
+	}
+
+	testCases := map[string]struct {
+		Config   cty.Value
+		Expected []*tfprotov5.Diagnostic
+	}{
+		"valid": {
+			Config: configVal(
+				[]cty.Value{elemVal("a", "b")},
+				[]cty.Value{elemVal("c", "d")},
+			),
+		},
+		"list elements": {
+			Config: configVal(
+				[]cty.Value{elemVal("valid"), elemVal("invalid"), elemVal("invalid")},
+				nil,
+			),
+			Expected: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "list_block.1.name is invalid",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("list_block").WithElementKeyInt(1).WithAttributeName("name"),
+				},
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "list_block.2.name is invalid",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("list_block").WithElementKeyInt(2).WithAttributeName("name"),
+				},
+			},
+		},
+		"nested list elements": {
+			Config: configVal(
+				[]cty.Value{elemVal("valid", "valid", "invalid")},
+				nil,
+			),
+			Expected: []*tfprotov5.Diagnostic{
+				{
+					Severity: tfprotov5.DiagnosticSeverityError,
+					Summary:  "list_block.0.inner.1.name is invalid",
+					Attribute: tftypes.NewAttributePath().
+						WithAttributeName("list_block").
+						WithElementKeyInt(0).
+						WithAttributeName("inner").
+						WithElementKeyInt(1).
+						WithAttributeName("name"),
+				},
+			},
+		},
+		"set elements": {
+			Config: configVal(
+				nil,
+				[]cty.Value{elemVal("valid"), elemVal("invalid", "invalid")},
+			),
+			Expected: []*tfprotov5.Diagnostic{
+				{
+					Severity: tfprotov5.DiagnosticSeverityError,
+					Summary:  "set_block.0.inner.0.name is invalid",
+					Attribute: tftypes.NewAttributePath().
+						WithAttributeName("set_block").
+						WithElementKeyValue(elemKey(elemVal("invalid", "invalid"))).
+						WithAttributeName("inner").
+						WithElementKeyInt(0).
+						WithAttributeName("name"),
+				},
+				{
+					Severity: tfprotov5.DiagnosticSeverityError,
+					Summary:  "set_block.0.name is invalid",
+					Attribute: tftypes.NewAttributePath().
+						WithAttributeName("set_block").
+						WithElementKeyValue(elemKey(elemVal("invalid", "invalid"))).
+						WithAttributeName("name"),
+				},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, err := server.ValidateResourceTypeConfig(context.Background(), &tfprotov5.ValidateResourceTypeConfigRequest{
+				TypeName: "test",
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, testCase.Config),
+				},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			// attributes are validated in map order
+			sort.Slice(resp.Diagnostics, func(i, j int) bool {
+				return resp.Diagnostics[i].Summary < resp.Diagnostics[j].Summary
+			})
+
+			if diff := cmp.Diff(testCase.Expected, resp.Diagnostics); diff != "" {
+				t.Errorf("unexpected difference: %s", diff)
+			}
+		})
+	}
+}
+
+func TestGRPCProviderServerValidateResourceTypeConfig_sensitiveSetElementPaths(t *testing.T) {
+	t.Parallel()
+
+	r := &Resource{
+		Schema: map[string]*Schema{
+			"set_block": {
+				Type:     TypeSet,
+				Optional: true,
+				Elem: &Resource{
+					Schema: map[string]*Schema{
+						"name": {
+							Type:     TypeString,
+							Optional: true,
+							ValidateFunc: func(v interface{}, k string) ([]string, []error) {
+								return nil, []error{fmt.Errorf("%s is invalid", k)}
+							},
+						},
+						"secret": {
+							Type:      TypeString,
+							Optional:  true,
+							Sensitive: true,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": r,
+		},
+	})
+
+	ty := r.CoreConfigSchema().ImpliedType()
+
+	config := cty.ObjectVal(map[string]cty.Value{
+		"id": cty.NullVal(cty.String),
+		"set_block": cty.SetVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{
+				"name":   cty.StringVal("invalid"),
+				"secret": cty.StringVal("hunter2"),
+			}),
+		}),
+	})
+
+	resp, err := server.ValidateResourceTypeConfig(context.Background(), &tfprotov5.ValidateResourceTypeConfigRequest{
+		TypeName: "test",
+		Config: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, config),
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []*tfprotov5.Diagnostic{
+		{
+			Severity:  tfprotov5.DiagnosticSeverityError,
+			Summary:   "set_block.0.name is invalid",
+			Attribute: tftypes.NewAttributePath().WithAttributeName("set_block"),
+		},
+	}
+
+	if diff := cmp.Diff(expected, resp.Diagnostics); diff != "" {
+		t.Errorf("unexpected difference: %s", diff)
+	}
+}
+
+func TestUpgradeState_jsonState(t *testing.T) {
+	r := &Resource{
+		SchemaVersion: 2,
+		Schema: map[string]*Schema{
+			"two": {
+				Type:     TypeInt,
+				Optional: true,
+			},
+		},
+	}
+
+	r.StateUpgraders = []StateUpgrader{
+		{
+			Version: 0,
+			Type: cty.Object(map[string]cty.Type{
+				"id":   cty.String,
+				"zero": cty.Number,
+			}),
+			Upgrade: func(ctx context.Context, m map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+				_, ok := m["zero"].(float64)
+				if !ok {
+					return nil, fmt.Errorf("zero not found in %#v", m)
+				}
+				m["one"] = float64(1)
+				delete(m, "zero")
+				return m, nil
+			},
+		},
+		{
+			Version: 1,
+			Type: cty.Object(map[string]cty.Type{
+				"id":  cty.String,
+				"one": cty.Number,
+			}),
+			Upgrade: func(ctx context.Context, m map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+				_, ok := m["one"].(float64)
+				if !ok {
+					return nil, fmt.Errorf("one not found in %#v", m)
+				}
+				m["two"] = float64(2)
+				delete(m, "one")
+				return m, nil
+			},
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": r,
+		},
+	})
+
+	req := &tfprotov5.UpgradeResourceStateRequest{
+		TypeName: "test",
+		Version:  0,
+		RawState: &tfprotov5.RawState{
+			JSON: []byte(`{"id":"bar","zero":0}`),
+		},
+	}
+
+	resp, err := server.UpgradeResourceState(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	AssertNoDiagnostics(t, resp.Diagnostics)
+
+	val, err := msgpack.Unmarshal(resp.UpgradedState.MsgPack, r.CoreConfigSchema().ImpliedType())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"id":  cty.StringVal("bar"),
+		"two": cty.NumberIntVal(2),
+	})
+
+	if !cmp.Equal(expected, val, valueComparer, equateEmpty) {
+		t.Fatal(cmp.Diff(expected, val, valueComparer, equateEmpty))
+	}
+}
+
+func TestUpgradeState_jsonStateBigInt(t *testing.T) {
+	r := &Resource{
+		UseJSONNumber: true,
+		SchemaVersion: 2,
+		Schema: map[string]*Schema{
+			"int": {
+				Type:     TypeInt,
+				Required: true,
+			},
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": r,
+		},
+	})
+
+	req := &tfprotov5.UpgradeResourceStateRequest{
+		TypeName: "test",
+		Version:  0,
+		RawState: &tfprotov5.RawState{
+			JSON: []byte(`{"id":"bar","int":7227701560655103598}`),
+		},
+	}
+
+	resp, err := server.UpgradeResourceState(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	AssertNoDiagnostics(t, resp.Diagnostics)
+
+	val, err := msgpack.Unmarshal(resp.UpgradedState.MsgPack, r.CoreConfigSchema().ImpliedType())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"id":  cty.StringVal("bar"),
+		"int": cty.NumberIntVal(7227701560655103598),
+	})
+
+	if !cmp.Equal(expected, val, valueComparer, equateEmpty) {
+		t.Fatal(cmp.Diff(expected, val, valueComparer, equateEmpty))
+	}
+}
+
+func TestUpgradeState_preferJSONStateEncoding(t *testing.T) {
+	upgrade := func(preferJSON bool) (*tfprotov5.DynamicValue, cty.Type) {
+		t.Helper()
+
+		r := &Resource{
+			SchemaVersion:           1,
+			PreferJSONStateEncoding: preferJSON,
+			Schema: map[string]*Schema{
+				"name": {
+					Type:     TypeString,
+					Optional: true,
+				},
+				"tags": {
+					Type:     TypeList,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+				},
+			},
+			StateUpgraders: []StateUpgrader{
+				{
+					Version: 0,
+					Type: cty.Object(map[string]cty.Type{
+						"id":   cty.String,
+						"old":  cty.String,
+						"tags": cty.List(cty.String),
+					}),
+					Upgrade: func(ctx context.Context, m map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+						m["name"] = m["old"]
+						delete(m, "old")
+						return m, nil
+					},
+				},
+			},
+		}
+
+		server := NewGRPCProviderServer(&Provider{
+			ResourcesMap: map[string]*Resource{
+				"test": r,
+			},
+		})
+
+		resp, err := server.UpgradeResourceState(context.Background(), &tfprotov5.UpgradeResourceStateRequest{
+			TypeName: "test",
+			Version:  0,
+			RawState: &tfprotov5.RawState{
+				JSON: []byte(`{"id":"bar","old":"foo","tags":["a","b"]}`),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(resp.Diagnostics) > 0 {
+			t.Fatalf("unexpected diagnostics: %#v", resp.Diagnostics)
+		}
+
+		return resp.UpgradedState, r.CoreConfigSchema().ImpliedType()
+	}
+
+	msgpackState, ty := upgrade(false)
+	if len(msgpackState.JSON) > 0 || len(msgpackState.MsgPack) == 0 {
+		t.Fatalf("expected only MsgPack upgraded state, got: %#v", msgpackState)
+	}
+
+	jsonState, _ := upgrade(true)
+	if len(jsonState.MsgPack) > 0 || len(jsonState.JSON) == 0 {
+		t.Fatalf("expected only JSON upgraded state, got: %#v", jsonState)
+	}
+
+	msgpackVal, err := msgpack.Unmarshal(msgpackState.MsgPack, ty)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsonVal, err := ctyjson.Unmarshal(jsonState.JSON, ty)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.StringVal("bar"),
+		"name": cty.StringVal("foo"),
+		"tags": cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")}),
+	})
+
+	if !cmp.Equal(expected, msgpackVal, valueComparer, equateEmpty) {
+		t.Fatal(cmp.Diff(expected, msgpackVal, valueComparer, equateEmpty))
+	}
+
+	if !cmp.Equal(msgpackVal, jsonVal, valueComparer, equateEmpty) {
+		t.Fatal(cmp.Diff(msgpackVal, jsonVal, valueComparer, equateEmpty))
+	}
+}
+
+func TestUpgradeState_removedAttr(t *testing.T) {
+	r1 := &Resource{
+		Schema: map[string]*Schema{
+			"two": {
+				Type:     TypeString,
+				Optional: true,
+			},
+		},
+	}
+
+	r2 := &Resource{
+		Schema: map[string]*Schema{
+			"multi": {
+				Type:     TypeSet,
+				Optional: true,
+				Elem: &Resource{
+					Schema: map[string]*Schema{
+						"set": {
+							Type:     TypeSet,
+							Optional: true,
+							Elem: &Resource{
+								Schema: map[string]*Schema{
+									"required": {
+										Type:     TypeString,
+										Required: true,
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	r3 := &Resource{
+		Schema: map[string]*Schema{
+			"config_mode_attr": {
+				Type:       TypeList,
+				ConfigMode: SchemaConfigModeAttr,
+				Optional:   true,
+				Elem: &Resource{
+					Schema: map[string]*Schema{
+						"foo": {
+							Type:     TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	p := &Provider{
+		ResourcesMap: map[string]*Resource{
+			"r1": r1,
+			"r2": r2,
+			"r3": r3,
+		},
+	}
+
+	server := NewGRPCProviderServer(p)
+
+	for _, tc := range []struct {
+		name     string
+		raw      string
+		expected cty.Value
+	}{
+		{
+			name: "r1",
+			raw:  `{"id":"bar","removed":"removed","two":"2"}`,
+			expected: cty.ObjectVal(map[string]cty.Value{
+				"id":  cty.StringVal("bar"),
+				"two": cty.StringVal("2"),
+			}),
+		},
+		{
+			name: "r2",
+			raw:  `{"id":"bar","multi":[{"set":[{"required":"ok","removed":"removed"}]}]}`,
+			expected: cty.ObjectVal(map[string]cty.Value{
+				"id": cty.StringVal("bar"),
+				"multi": cty.SetVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"set": cty.SetVal([]cty.Value{
+							cty.ObjectVal(map[string]cty.Value{
+								"required": cty.StringVal("ok"),
+							}),
+						}),
+					}),
+				}),
+			}),
+		},
+		{
+			name: "r3",
+			raw:  `{"id":"bar","config_mode_attr":[{"foo":"ok","removed":"removed"}]}`,
+			expected: cty.ObjectVal(map[string]cty.Value{
+				"id": cty.StringVal("bar"),
+				"config_mode_attr": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"foo": cty.StringVal("ok"),
+					}),
+				}),
+			}),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := &tfprotov5.UpgradeResourceStateRequest{
+				TypeName: tc.name,
+				Version:  0,
+				RawState: &tfprotov5.RawState{
+					JSON: []byte(tc.raw),
+				},
+			}
+			resp, err := server.UpgradeResourceState(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			AssertNoDiagnostics(t, resp.Diagnostics)
+			val, err := msgpack.Unmarshal(resp.UpgradedState.MsgPack, p.ResourcesMap[tc.name].CoreConfigSchema().ImpliedType())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tc.expected.RawEquals(val) {
+				t.Fatalf("\nexpected: %#v\ngot:      %#v\n", tc.expected, val)
+			}
+		})
+	}
+
+}
+
+func TestUpgradeState_flatmapState(t *testing.T) {
+	r := &Resource{
+		SchemaVersion: 4,
+		Schema: map[string]*Schema{
+			"four": {
+				Type:     TypeInt,
+				Required: true,
+			},
+			"block": {
+				Type:     TypeList,
+				Optional: true,
+				Elem: &Resource{
+					Schema: map[string]*Schema{
+						"attr": {
+							Type:     TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+		},
+		// this MigrateState will take the state to version 2
+		MigrateState: func(v int, is *terraform.InstanceState, _ interface{}) (*terraform.InstanceState, error) {
+			switch v {
+			case 0:
+				_, ok := is.Attributes["zero"]
+				if !ok {
+					return nil, fmt.Errorf("zero not found in %#v", is.Attributes)
+				}
+				is.Attributes["one"] = "1"
+				delete(is.Attributes, "zero")
+				fallthrough
+			case 1:
+				_, ok := is.Attributes["one"]
+				if !ok {
+					return nil, fmt.Errorf("one not found in %#v", is.Attributes)
+				}
+				is.Attributes["two"] = "2"
+				delete(is.Attributes, "one")
+			default:
+				return nil, fmt.Errorf("invalid schema version %d", v)
+			}
+			return is, nil
+		},
+	}
+
+	r.StateUpgraders = []StateUpgrader{
+		{
+			Version: 2,
+			Type: cty.Object(map[string]cty.Type{
+				"id":  cty.String,
+				"two": cty.Number,
+			}),
+			Upgrade: func(ctx context.Context, m map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+				_, ok := m["two"].(float64)
+				if !ok {
+					return nil, fmt.Errorf("two not found in %#v", m)
+				}
+				m["three"] = float64(3)
+				delete(m, "two")
+				return m, nil
+			},
+		},
+		{
+			Version: 3,
+			Type: cty.Object(map[string]cty.Type{
+				"id":    cty.String,
+				"three": cty.Number,
+			}),
+			Upgrade: func(ctx context.Context, m map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+				_, ok := m["three"].(float64)
+				if !ok {
+					return nil, fmt.Errorf("three not found in %#v", m)
+				}
+				m["four"] = float64(4)
+				delete(m, "three")
+				return m, nil
+			},
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": r,
+		},
+	})
+
+	testReqs := []*tfprotov5.UpgradeResourceStateRequest{
+		{
+			TypeName: "test",
+			Version:  0,
+			RawState: &tfprotov5.RawState{
+				Flatmap: map[string]string{
+					"id":   "bar",
+					"zero": "0",
+				},
+			},
+		},
+		{
+			TypeName: "test",
+			Version:  1,
+			RawState: &tfprotov5.RawState{
+				Flatmap: map[string]string{
+					"id":  "bar",
+					"one": "1",
+				},
+			},
+		},
+		// two and  up could be stored in flatmap or json states
+		{
+			TypeName: "test",
+			Version:  2,
+			RawState: &tfprotov5.RawState{
+				Flatmap: map[string]string{
+					"id":  "bar",
+					"two": "2",
+				},
+			},
+		},
+		{
+			TypeName: "test",
+			Version:  2,
+			RawState: &tfprotov5.RawState{
+				JSON: []byte(`{"id":"bar","two":2}`),
+			},
+		},
+		{
+			TypeName: "test",
+			Version:  3,
+			RawState: &tfprotov5.RawState{
+				Flatmap: map[string]string{
+					"id":    "bar",
+					"three": "3",
+				},
+			},
+		},
+		{
+			TypeName: "test",
+			Version:  3,
+			RawState: &tfprotov5.RawState{
+				JSON: []byte(`{"id":"bar","three":3}`),
+			},
+		},
+		{
+			TypeName: "test",
+			Version:  4,
+			RawState: &tfprotov5.RawState{
+				Flatmap: map[string]string{
+					"id":   "bar",
+					"four": "4",
+				},
+			},
+		},
+		{
+			TypeName: "test",
+			Version:  4,
+			RawState: &tfprotov5.RawState{
+				JSON: []byte(`{"id":"bar","four":4}`),
+			},
+		},
+	}
+
+	for i, req := range testReqs {
+		t.Run(fmt.Sprintf("%d-%d", i, req.Version), func(t *testing.T) {
+			resp, err := server.UpgradeResourceState(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			AssertNoDiagnostics(t, resp.Diagnostics)
+
+			val, err := msgpack.Unmarshal(resp.UpgradedState.MsgPack, r.CoreConfigSchema().ImpliedType())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := cty.ObjectVal(map[string]cty.Value{
+				"block": cty.ListValEmpty(cty.Object(map[string]cty.Type{"attr": cty.String})),
+				"id":    cty.StringVal("bar"),
+				"four":  cty.NumberIntVal(4),
+			})
+
+			if !cmp.Equal(expected, val, valueComparer, equateEmpty) {
+				t.Fatal(cmp.Diff(expected, val, valueComparer, equateEmpty))
+			}
+		})
+	}
+}
+
+func TestUpgradeState_flatmapStateMissingMigrateState(t *testing.T) {
+	r := &Resource{
+		SchemaVersion: 1,
+		Schema: map[string]*Schema{
+			"one": {
+				Type:     TypeInt,
+				Required: true,
+			},
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": r,
+		},
+	})
+
+	testReqs := []*tfprotov5.UpgradeResourceStateRequest{
+		{
+			TypeName: "test",
+			Version:  0,
+			RawState: &tfprotov5.RawState{
+				Flatmap: map[string]string{
+					"id":  "bar",
+					"one": "1",
+				},
+			},
+		},
+		{
+			TypeName: "test",
+			Version:  1,
+			RawState: &tfprotov5.RawState{
+				Flatmap: map[string]string{
+					"id":  "bar",
+					"one": "1",
+				},
+			},
+		},
+		{
+			TypeName: "test",
+			Version:  1,
+			RawState: &tfprotov5.RawState{
+				JSON: []byte(`{"id":"bar","one":1}`),
+			},
+		},
+	}
+
+	for i, req := range testReqs {
+		t.Run(fmt.Sprintf("%d-%d", i, req.Version), func(t *testing.T) {
+			resp, err := server.UpgradeResourceState(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			AssertNoDiagnostics(t, resp.Diagnostics)
+
+			val, err := msgpack.Unmarshal(resp.UpgradedState.MsgPack, r.CoreConfigSchema().ImpliedType())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := cty.ObjectVal(map[string]cty.Value{
+				"id":  cty.StringVal("bar"),
+				"one": cty.NumberIntVal(1),
+			})
+
+			if !cmp.Equal(expected, val, valueComparer, equateEmpty) {
+				t.Fatal(cmp.Diff(expected, val, valueComparer, equateEmpty))
+			}
+		})
+	}
+}
+
+func TestUpgradeState_writeOnlyNullification(t *testing.T) {
+	r := &Resource{
+		SchemaVersion: 2,
+		Schema: map[string]*Schema{
+			"two": {
+				Type:      TypeInt,
+				Optional:  true,
+				WriteOnly: true,
+			},
+		},
+	}
+
+	r.StateUpgraders = []StateUpgrader{
+		{
+			Version: 0,
+			Type: cty.Object(map[string]cty.Type{
+				"id":   cty.String,
+				"zero": cty.Number,
+			}),
+			Upgrade: func(ctx context.Context, m map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+				_, ok := m["zero"].(float64)
+				if !ok {
+					return nil, fmt.Errorf("zero not found in %#v", m)
+				}
+				m["one"] = float64(1)
+				delete(m, "zero")
+				return m, nil
+			},
+		},
+		{
+			Version: 1,
+			Type: cty.Object(map[string]cty.Type{
+				"id":  cty.String,
+				"one": cty.Number,
+			}),
+			Upgrade: func(ctx context.Context, m map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+				_, ok := m["one"].(float64)
+				if !ok {
+					return nil, fmt.Errorf("one not found in %#v", m)
+				}
+				m["two"] = float64(2)
+				delete(m, "one")
+				return m, nil
+			},
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": r,
+		},
+	})
+
+	req := &tfprotov5.UpgradeResourceStateRequest{
+		TypeName: "test",
+		Version:  0,
+		RawState: &tfprotov5.RawState{
+			JSON: []byte(`{"id":"bar","zero":0}`),
+		},
+	}
+
+	resp, err := server.UpgradeResourceState(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	AssertNoDiagnostics(t, resp.Diagnostics)
+
+	val, err := msgpack.Unmarshal(resp.UpgradedState.MsgPack, r.CoreConfigSchema().ImpliedType())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"id":  cty.StringVal("bar"),
+		"two": cty.NullVal(cty.Number),
+	})
+
+	if !cmp.Equal(expected, val, valueComparer, equateEmpty) {
+		t.Fatal(cmp.Diff(expected, val, valueComparer, equateEmpty))
+	}
+}
+
+func TestReadResource(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		server   *GRPCProviderServer
+		req      *tfprotov5.ReadResourceRequest
+		expected *tfprotov5.ReadResourceResponse
+	}{
+		"read-resource": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 1,
+						Schema: map[string]*Schema{
+							"id": {
+								Type:     TypeString,
+								Required: true,
+							},
+							"test_bool": {
+								Type:     TypeBool,
+								Computed: true,
+							},
+							"test_string": {
+								Type:     TypeString,
+								Computed: true,
+							},
+							"test_list": {
+								Type: TypeList,
+								Elem: &Schema{
+									Type: TypeString,
+								},
+								Computed: true,
+							},
+						},
+						Identity: &ResourceIdentity{
+							Version: 1,
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{
+									"instance_id": {
+										Type:              TypeString,
+										RequiredForImport: true,
+									},
+									"region": {
+										Type:              TypeString,
+										OptionalForImport: true,
+									},
+								}
+							},
+						},
+						ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+							err := d.Set("test_bool", true)
+							if err != nil {
+								return diag.FromErr(err)
+							}
+
+							err = d.Set("test_string", "new-state-val")
+							if err != nil {
+								return diag.FromErr(err)
+							}
+
+							identity, err := d.Identity()
+							if err != nil {
+								return diag.FromErr(err)
+							}
+							err = identity.Set("region", "new-region")
+							if err != nil {
+								return diag.FromErr(err)
+							}
+
+							return nil
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.ReadResourceRequest{
+				TypeName: "test",
+				CurrentIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"instance_id": cty.String,
+								"region":      cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"instance_id": cty.StringVal("test-id"),
+								"region":      cty.StringVal("test-region"),
+							}),
+						),
+					},
+				},
+				CurrentState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":          cty.String,
+							"test_bool":   cty.Bool,
+							"test_string": cty.String,
+							"test_list":   cty.List(cty.String),
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":          cty.StringVal("test-id"),
+							"test_bool":   cty.BoolVal(false),
+							"test_string": cty.StringVal("prior-state-val"),
+							"test_list": cty.ListVal([]cty.Value{
+								cty.StringVal("hello"),
+								cty.StringVal("world"),
+							}),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.ReadResourceResponse{
+				NewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":          cty.String,
+							"test_bool":   cty.Bool,
+							"test_string": cty.String,
+							"test_list":   cty.List(cty.String),
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":          cty.StringVal("test-id"),
+							"test_bool":   cty.BoolVal(true),
+							"test_string": cty.StringVal("new-state-val"),
+							"test_list": cty.ListVal([]cty.Value{
+								cty.StringVal("hello"),
+								cty.StringVal("world"),
+							}),
+						}),
+					),
+				},
+				NewIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"instance_id": cty.String,
+								"region":      cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"instance_id": cty.StringVal("test-id"),
+								"region":      cty.StringVal("new-region"),
+							}),
+						),
+					},
+				},
+			},
+		},
+		"read-resource-identity-raw": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						Schema: map[string]*Schema{
+							"id": {
+								Type:     TypeString,
+								Required: true,
+							},
+						},
+						Identity: &ResourceIdentity{
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{
+									"instance_id": {
+										Type:              TypeString,
+										RequiredForImport: true,
+									},
+									"region": {
+										Type:              TypeString,
+										OptionalForImport: true,
+									},
+								}
+							},
+						},
+						ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+							identity, err := d.Identity()
+							if err != nil {
+								return diag.FromErr(err)
+							}
+
+							raw := identity.GetRaw()
+							expected := cty.ObjectVal(map[string]cty.Value{
+								"instance_id": cty.StringVal("test-id"),
+								"region":      cty.StringVal("test-region"),
+							})
+							if !raw.RawEquals(expected) {
+								return diag.Errorf("expected identity %#v, got %#v", expected, raw)
+							}
+
+							err = identity.SetRaw(cty.ObjectVal(map[string]cty.Value{
+								"instance_id": raw.GetAttr("instance_id"),
+								"region":      cty.StringVal(strings.ToUpper(raw.GetAttr("region").AsString())),
+							}))
+							if err != nil {
+								return diag.FromErr(err)
+							}
+
+							return nil
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.ReadResourceRequest{
+				TypeName: "test",
+				CurrentIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"instance_id": cty.String,
+								"region":      cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"instance_id": cty.StringVal("test-id"),
+								"region":      cty.StringVal("test-region"),
+							}),
+						),
+					},
+				},
+				CurrentState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id": cty.StringVal("test-id"),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.ReadResourceResponse{
+				NewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id": cty.StringVal("test-id"),
+						}),
+					),
+				},
+				NewIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"instance_id": cty.String,
+								"region":      cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"instance_id": cty.StringVal("test-id"),
+								"region":      cty.StringVal("TEST-REGION"),
+							}),
+						),
+					},
+				},
+			},
+		},
+		"no-identity-schema": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 1,
+						Identity: &ResourceIdentity{
+							Version: 1,
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.ReadResourceRequest{
+				TypeName: "test",
+				CurrentIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"instance_id": cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"instance_id": cty.StringVal("test-id"),
+							}),
+						),
+					},
+				},
+				CurrentState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id": cty.StringVal("test-id"),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.ReadResourceResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "getting identity schema failed for resource 'test': resource does not have an identity schema",
+					},
+				},
+			},
+		},
+		"empty-identity": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 1,
+						Identity: &ResourceIdentity{
+							Version: 1,
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{}
+							},
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.ReadResourceRequest{
+				TypeName: "test",
+				CurrentIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"instance_id": cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"instance_id": cty.StringVal("test-id"),
+							}),
+						),
+					},
+				},
+				CurrentState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id": cty.StringVal("test-id"),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.ReadResourceResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "getting identity schema failed for resource 'test': identity schema must have at least one attribute",
+					},
+				},
+			},
+		},
+		"deferred-response-unknown-val": {
+			server: NewGRPCProviderServer(&Provider{
+				// Deferred response will skip read function and return current state
+				providerDeferred: &Deferred{
+					Reason: DeferredReasonProviderConfigUnknown,
+				},
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 1,
+						Schema: map[string]*Schema{
+							"id": {
+								Type:     TypeString,
+								Required: true,
+							},
+							"test_bool": {
+								Type:     TypeBool,
+								Computed: true,
+							},
+							"test_string": {
+								Type:     TypeString,
+								Computed: true,
+							},
+						},
+						ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+							return diag.Errorf("Test assertion failed: read shouldn't be called when provider deferred response is present")
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.ReadResourceRequest{
+				ClientCapabilities: &tfprotov5.ReadResourceClientCapabilities{
+					DeferralAllowed: true,
+				},
+				TypeName: "test",
+				CurrentState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":          cty.String,
+							"test_bool":   cty.Bool,
+							"test_string": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":          cty.StringVal("test-id"),
+							"test_bool":   cty.BoolVal(false),
+							"test_string": cty.StringVal("prior-state-val"),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.ReadResourceResponse{
+				NewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":          cty.String,
+							"test_bool":   cty.Bool,
+							"test_string": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":          cty.StringVal("test-id"),
+							"test_bool":   cty.BoolVal(false),
+							"test_string": cty.StringVal("prior-state-val"),
+						}),
+					),
+				},
+				Deferred: &tfprotov5.Deferred{
+					Reason: tfprotov5.DeferredReasonProviderConfigUnknown,
+				},
+			},
+		},
+		"SetDeferred-deferral-allowed": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 1,
+						Schema: map[string]*Schema{
+							"id": {
+								Type:     TypeString,
+								Required: true,
+							},
+							"test_bool": {
+								Type:     TypeBool,
+								Computed: true,
+							},
+							"test_string": {
+								Type:     TypeString,
+								Computed: true,
+							},
+						},
+						ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+							if err := d.Set("test_string", "new-state-val"); err != nil {
+								return diag.FromErr(err)
+							}
+
+							SetDeferred(ctx, &Deferred{
+								Reason: DeferredReasonAbsentPrereq,
+							})
+							return nil
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.ReadResourceRequest{
+				ClientCapabilities: &tfprotov5.ReadResourceClientCapabilities{
+					DeferralAllowed: true,
+				},
+				TypeName: "test",
+				CurrentState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":          cty.String,
+							"test_bool":   cty.Bool,
+							"test_string": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":          cty.StringVal("test-id"),
+							"test_bool":   cty.BoolVal(false),
+							"test_string": cty.StringVal("prior-state-val"),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.ReadResourceResponse{
+				NewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":          cty.String,
+							"test_bool":   cty.Bool,
+							"test_string": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":          cty.StringVal("test-id"),
+							"test_bool":   cty.BoolVal(false),
+							"test_string": cty.StringVal("prior-state-val"),
+						}),
+					),
+				},
+				Deferred: &tfprotov5.Deferred{
+					Reason: tfprotov5.DeferredReasonAbsentPrereq,
+				},
+			},
+		},
+		"SetDeferred-deferral-not-allowed": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 1,
+						Schema: map[string]*Schema{
+							"id": {
+								Type:     TypeString,
+								Required: true,
+							},
+							"test_bool": {
+								Type:     TypeBool,
+								Computed: true,
+							},
+							"test_string": {
+								Type:     TypeString,
+								Computed: true,
+							},
+						},
+						ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+							if err := d.Set("test_string", "new-state-val"); err != nil {
+								return diag.FromErr(err)
+							}
+
+							SetDeferred(ctx, &Deferred{
+								Reason: DeferredReasonAbsentPrereq,
+							})
+							return nil
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.ReadResourceRequest{
+				TypeName: "test",
+				CurrentState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":          cty.String,
+							"test_bool":   cty.Bool,
+							"test_string": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":          cty.StringVal("test-id"),
+							"test_bool":   cty.BoolVal(false),
+							"test_string": cty.StringVal("prior-state-val"),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.ReadResourceResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "Invalid Deferred Resource Response",
+						Detail: "Resource returned a deferred response but the Terraform request " +
+							"did not indicate support for deferred actions. This is an issue with the provider and should be reported to the provider developers.",
+					},
+				},
+			},
+		},
+		"write-only values are nullified in ReadResourceResponse": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 1,
+						Schema: map[string]*Schema{
+							"id": {
+								Type:     TypeString,
+								Required: true,
+							},
+							"test_bool": {
+								Type:     TypeBool,
+								Computed: true,
+							},
+							"test_string": {
+								Type:     TypeString,
+								Computed: true,
+							},
+							"test_write_only": {
+								Type:      TypeString,
+								WriteOnly: true,
+							},
+						},
+						ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+							err := d.Set("test_bool", true)
+							if err != nil {
+								return diag.FromErr(err)
+							}
+
+							err = d.Set("test_string", "new-state-val")
+							if err != nil {
+								return diag.FromErr(err)
+							}
+
+							err = d.Set("test_write_only", "write-only-val")
+							if err != nil {
+								return diag.FromErr(err)
+							}
+
+							return nil
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.ReadResourceRequest{
+				TypeName: "test",
+				CurrentState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":              cty.String,
+							"test_bool":       cty.Bool,
+							"test_string":     cty.String,
+							"test_write_only": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":              cty.StringVal("test-id"),
+							"test_bool":       cty.BoolVal(false),
+							"test_string":     cty.StringVal("prior-state-val"),
+							"test_write_only": cty.NullVal(cty.String),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.ReadResourceResponse{
+				NewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":              cty.String,
+							"test_bool":       cty.Bool,
+							"test_string":     cty.String,
+							"test_write_only": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":              cty.StringVal("test-id"),
+							"test_bool":       cty.BoolVal(true),
+							"test_string":     cty.StringVal("new-state-val"),
+							"test_write_only": cty.NullVal(cty.String),
+						}),
+					),
+				},
+			},
+		},
+		"write-only values in ReadResourceResponse return error with ErrorOnWriteOnlyInState": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion:           1,
+						ErrorOnWriteOnlyInState: true,
+						Schema: map[string]*Schema{
+							"id": {
+								Type:     TypeString,
+								Required: true,
+							},
+							"test_bool": {
+								Type:     TypeBool,
+								Computed: true,
+							},
+							"test_string": {
+								Type:     TypeString,
+								Computed: true,
+							},
+							"test_write_only": {
+								Type:      TypeString,
+								WriteOnly: true,
+							},
+						},
+						ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+							err := d.Set("test_bool", true)
+							if err != nil {
+								return diag.FromErr(err)
+							}
+
+							err = d.Set("test_string", "new-state-val")
+							if err != nil {
+								return diag.FromErr(err)
+							}
+
+							err = d.Set("test_write_only", "write-only-val")
+							if err != nil {
+								return diag.FromErr(err)
+							}
+
+							return nil
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.ReadResourceRequest{
+				TypeName: "test",
+				CurrentState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":              cty.String,
+							"test_bool":       cty.Bool,
+							"test_string":     cty.String,
+							"test_write_only": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":              cty.StringVal("test-id"),
+							"test_bool":       cty.BoolVal(false),
+							"test_string":     cty.StringVal("prior-state-val"),
+							"test_write_only": cty.NullVal(cty.String),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.ReadResourceResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "Write-only Attribute Set in State",
+						Detail: "The resource state contains a non-null value for write-only attribute \"test_write_only\". " +
+							"Write-only values are never persisted. This is always an issue in the provider and should be reported to the provider developers.",
+						Attribute: tftypes.NewAttributePath().WithAttributeName("test_write_only"),
+					},
+				},
+				NewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":              cty.String,
+							"test_bool":       cty.Bool,
+							"test_string":     cty.String,
+							"test_write_only": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":              cty.StringVal("test-id"),
+							"test_bool":       cty.BoolVal(true),
+							"test_string":     cty.StringVal("new-state-val"),
+							"test_write_only": cty.NullVal(cty.String),
+						}),
+					),
+				},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			resp, err := testCase.server.ReadResource(context.Background(), testCase.req)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(resp, testCase.expected, valueComparer); diff != "" {
+				ty := testCase.server.getResourceSchemaBlock("test").ImpliedType()
+
+				if resp != nil && resp.NewState != nil {
+					t.Logf("resp.NewState.MsgPack: %s", mustMsgpackUnmarshal(ty, resp.NewState.MsgPack))
+				}
+
+				if testCase.expected != nil && testCase.expected.NewState != nil {
+					t.Logf("expected: %s", mustMsgpackUnmarshal(ty, testCase.expected.NewState.MsgPack))
+				}
+
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestReadResource_upgradeIdentityState(t *testing.T) {
+	t.Parallel()
+
+	identityType := cty.Object(map[string]cty.Type{
+		"instance_id": cty.String,
+		"region":      cty.String,
+	})
+
+	newServer := func(upgrade IdentityStateUpgradeFunc) *GRPCProviderServer {
+		return NewGRPCProviderServer(&Provider{
+			ResourcesMap: map[string]*Resource{
+				"test": {
+					SchemaVersion: 2,
+					Schema: map[string]*Schema{
+						"id": {
+							Type:     TypeString,
+							Required: true,
+						},
+					},
+					Identity: &ResourceIdentity{
+						SchemaFunc: func() map[string]*Schema {
+							return map[string]*Schema{
+								"instance_id": {
+									Type:              TypeString,
+									RequiredForImport: true,
+								},
+								"region": {
+									Type:              TypeString,
+									OptionalForImport: true,
+								},
+							}
+						},
+					},
+					ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+						return nil
+					},
+					UpgradeIdentityState: upgrade,
+				},
+			},
+		})
+	}
+
+	setRegion := func(ctx context.Context, stateVersion int, rawIdentity map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+		if stateVersion != 2 {
+			return nil, fmt.Errorf("expected state version 2, got %d", stateVersion)
+		}
+
+		if rawIdentity["instance_id"] != "test-id" {
+			return nil, fmt.Errorf("unexpected identity: %#v", rawIdentity)
+		}
+
+		rawIdentity["region"] = "us-east-1"
+		rawIdentity["removed"] = "ignored"
+
+		return rawIdentity, nil
+	}
+
+	currentIdentity := cty.ObjectVal(map[string]cty.Value{
+		"instance_id": cty.StringVal("test-id"),
+		"region":      cty.NullVal(cty.String),
+	})
+	upgradedIdentity := cty.ObjectVal(map[string]cty.Value{
+		"instance_id": cty.StringVal("test-id"),
+		"region":      cty.StringVal("us-east-1"),
+	})
+
+	testCases := map[string]struct {
+		server           *GRPCProviderServer
+		private          []byte
+		expectedIdentity cty.Value
+		expectedPrivate  []byte
+		expectedDiags    []*tfprotov5.Diagnostic
+	}{
+		"older-schema-version": {
+			server:           newServer(setRegion),
+			private:          []byte(`{"schema_version":"1"}`),
+			expectedIdentity: upgradedIdentity,
+			expectedPrivate:  []byte(`{"schema_version":"2"}`),
+		},
+		"no-recorded-schema-version": {
+			server:           newServer(setRegion),
+			expectedIdentity: upgradedIdentity,
+			expectedPrivate:  []byte(`{"schema_version":"2"}`),
+		},
+		"no-recorded-schema-version-other-private-data": {
+			server:           newServer(setRegion),
+			private:          []byte(`{"foo":"bar"}`),
+			expectedIdentity: upgradedIdentity,
+			expectedPrivate:  []byte(`{"foo":"bar","schema_version":"2"}`),
+		},
+		"current-schema-version": {
+			server: newServer(func(ctx context.Context, stateVersion int, rawIdentity map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+				return nil, errors.New("UpgradeIdentityState should not be called")
+			}),
+			private:          []byte(`{"schema_version":"2"}`),
+			expectedIdentity: currentIdentity,
+			expectedPrivate:  []byte(`{"schema_version":"2"}`),
+		},
+		"error": {
+			server: newServer(func(ctx context.Context, stateVersion int, rawIdentity map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+				return nil, errors.New("test error")
+			}),
+			private: []byte(`{"schema_version":"1"}`),
+			expectedDiags: []*tfprotov5.Diagnostic{
+				{
+					Severity: tfprotov5.DiagnosticSeverityError,
+					Summary:  "test error",
+				},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, err := testCase.server.ReadResource(context.Background(), &tfprotov5.ReadResourceRequest{
+				TypeName: "test",
+				CurrentIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(identityType, currentIdentity),
+					},
+				},
+				CurrentState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id": cty.StringVal("test-id"),
+						}),
+					),
+				},
+				Private: testCase.private,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(testCase.expectedDiags, resp.Diagnostics); diff != "" {
+				t.Fatalf("unexpected diagnostics difference: %s", diff)
+			}
+
+			if testCase.expectedDiags != nil {
+				return
+			}
+
+			if resp.NewIdentity == nil {
+				t.Fatal("expected NewIdentity")
+			}
+
+			identity := mustMsgpackUnmarshal(identityType, resp.NewIdentity.IdentityData.MsgPack)
+			if !identity.RawEquals(testCase.expectedIdentity) {
+				t.Fatalf("expected identity %#v, got %#v", testCase.expectedIdentity, identity)
+			}
+
+			if diff := cmp.Diff(string(testCase.expectedPrivate), string(resp.Private)); diff != "" {
+				t.Fatalf("unexpected private difference: %s", diff)
+			}
+		})
+	}
+}
+
+func TestReadResource_diffSuppressOnRefresh(t *testing.T) {
+	t.Parallel()
+
+	ty := cty.Object(map[string]cty.Type{
+		"id":     cty.String,
+		"policy": cty.String,
+	})
+
+	canonicalJSON := func(s string) string {
+		var v interface{}
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			return s
+		}
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+
+	testCases := map[string]struct {
+		RemotePolicy   string
+		SetRawState    bool
+		ExpectedPolicy string
+	}{
+		"equivalent json": {
+			RemotePolicy:   `{ "b": 2,  "a": 1 }`,
+			ExpectedPolicy: `{"a":1,"b":2}`,
+		},
+		"equivalent json with raw state": {
+			RemotePolicy:   `{ "b": 2,  "a": 1 }`,
+			SetRawState:    true,
+			ExpectedPolicy: `{"a":1,"b":2}`,
+		},
+		"different json": {
+			RemotePolicy:   `{"a": 1, "b": 3}`,
+			ExpectedPolicy: `{"a": 1, "b": 3}`,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						Schema: map[string]*Schema{
+							"policy": {
+								Type:     TypeString,
+								Optional: true,
+								DiffSuppressFunc: func(k, oldValue, newValue string, d *ResourceData) bool {
+									return canonicalJSON(oldValue) == canonicalJSON(newValue)
+								},
+								DiffSuppressOnRefresh: true,
+							},
+						},
+						ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+							if testCase.SetRawState {
+								return diag.FromErr(d.SetRawState(cty.ObjectVal(map[string]cty.Value{
+									"policy": cty.StringVal(testCase.RemotePolicy),
+								})))
+							}
+							return diag.FromErr(d.Set("policy", testCase.RemotePolicy))
+						},
+					},
+				},
+			})
+
+			resp, err := server.ReadResource(context.Background(), &tfprotov5.ReadResourceRequest{
+				TypeName: "test",
+				CurrentState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+						"id":     cty.StringVal("test-id"),
+						"policy": cty.StringVal(`{"a":1,"b":2}`),
+					})),
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			AssertNoDiagnostics(t, resp.Diagnostics)
+
+			got, err := msgpack.Unmarshal(resp.NewState.MsgPack, ty)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := cty.ObjectVal(map[string]cty.Value{
+				"id":     cty.StringVal("test-id"),
+				"policy": cty.StringVal(testCase.ExpectedPolicy),
+			})
+
+			if !got.RawEquals(expected) {
+				t.Fatalf("unexpected new state\ngot:  %#v\nwant: %#v", got, expected)
+			}
+		})
+	}
+}
+
+func TestReadResource_scopedProviderDeferred(t *testing.T) {
+	t.Parallel()
+
+	ty := cty.Object(map[string]cty.Type{
+		"id":          cty.String,
+		"test_string": cty.String,
+	})
+
+	testResource := func() *Resource {
+		return &Resource{
+			Schema: map[string]*Schema{
+				"test_string": {
+					Type:     TypeString,
+					Computed: true,
+				},
+			},
+			ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+				return diag.FromErr(d.Set("test_string", "new-state-val"))
+			},
+		}
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		providerDeferred: &Deferred{
+			Reason: DeferredReasonProviderConfigUnknown,
+		},
+		providerDeferredResources: []string{"test_deferred"},
+		ResourcesMap: map[string]*Resource{
+			"test_deferred":     testResource(),
+			"test_not_deferred": testResource(),
+		},
+		DataSourcesMap: map[string]*Resource{
+			"test_deferred": testResource(),
+		},
+	})
+
+	currentState := &tfprotov5.DynamicValue{
+		MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+			"id":          cty.StringVal("test-id"),
+			"test_string": cty.StringVal("prior-state-val"),
+		})),
+	}
+
+	testCases := map[string]struct {
+		TypeName         string
+		ExpectedState    cty.Value
+		ExpectedDeferred *tfprotov5.Deferred
+	}{
+		"deferred": {
+			TypeName: "test_deferred",
+			ExpectedState: cty.ObjectVal(map[string]cty.Value{
+				"id":          cty.StringVal("test-id"),
+				"test_string": cty.StringVal("prior-state-val"),
+			}),
+			ExpectedDeferred: &tfprotov5.Deferred{
+				Reason: tfprotov5.DeferredReasonProviderConfigUnknown,
+			},
+		},
+		"not deferred": {
+			TypeName: "test_not_deferred",
+			ExpectedState: cty.ObjectVal(map[string]cty.Value{
+				"id":          cty.StringVal("test-id"),
+				"test_string": cty.StringVal("new-state-val"),
+			}),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, err := server.ReadResource(context.Background(), &tfprotov5.ReadResourceRequest{
+				ClientCapabilities: &tfprotov5.ReadResourceClientCapabilities{
+					DeferralAllowed: true,
+				},
+				TypeName:     testCase.TypeName,
+				CurrentState: currentState,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			AssertNoDiagnostics(t, resp.Diagnostics)
+
+			if diff := cmp.Diff(testCase.ExpectedDeferred, resp.Deferred); diff != "" {
+				t.Fatalf("unexpected deferred difference: %s", diff)
+			}
+
+			got, err := msgpack.Unmarshal(resp.NewState.MsgPack, ty)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !got.RawEquals(testCase.ExpectedState) {
+				t.Fatalf("unexpected new state\ngot:  %#v\nwant: %#v", got, testCase.ExpectedState)
+			}
+		})
+	}
+
+	t.Run("data source not deferred", func(t *testing.T) {
+		t.Parallel()
+
+		resp, err := server.ReadDataSource(context.Background(), &tfprotov5.ReadDataSourceRequest{
+			ClientCapabilities: &tfprotov5.ReadDataSourceClientCapabilities{
+				DeferralAllowed: true,
+			},
+			TypeName: "test_deferred",
+			Config: &tfprotov5.DynamicValue{
+				MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+					"id":          cty.NullVal(cty.String),
+					"test_string": cty.NullVal(cty.String),
+				})),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.Deferred != nil {
+			t.Fatalf("expected data source not to be deferred, got: %#v", resp.Deferred)
+		}
+	})
+}
+
+func TestPlanResourceChange(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		server   *GRPCProviderServer
+		req      *tfprotov5.PlanResourceChangeRequest
+		expected *tfprotov5.PlanResourceChangeResponse
+	}{
+		"basic-plan": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 4,
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeInt,
+								Optional: true,
+							},
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"foo": cty.Number,
+						}),
+						cty.NullVal(
+							cty.Object(map[string]cty.Type{
+								"foo": cty.Number,
+							}),
+						),
+					),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.Number,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.NullVal(cty.Number),
+						}),
+					),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.Number,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.NullVal(cty.Number),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.PlanResourceChangeResponse{
+				PlannedState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.Number,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.NullVal(cty.Number),
+						}),
+					),
+				},
+				RequiresReplace: []*tftypes.AttributePath{
+					tftypes.NewAttributePath().WithAttributeName("id"),
+				},
+				PlannedPrivate:              []byte(`{"_new_extra_shim":{}}`),
+				UnsafeToUseLegacyTypeSystem: true,
+			},
+		},
+		"basic-plan-with-identity": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 4,
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeInt,
+								Optional: true,
+							},
+						},
+						Identity: &ResourceIdentity{
+							Version: 1,
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{
+									"name": {
+										Type:              TypeString,
+										RequiredForImport: true,
+									},
+								}
+							},
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"foo": cty.Number,
+						}),
+						cty.NullVal(
+							cty.Object(map[string]cty.Type{
+								"foo": cty.Number,
+							}),
+						),
+					),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.Number,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.NullVal(cty.Number),
+						}),
+					),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.Number,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.NullVal(cty.Number),
+						}),
+					),
+				},
+				PriorIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"name": cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"name": cty.StringVal("test-name"),
+							}),
+						),
+					},
+				},
+			},
+			expected: &tfprotov5.PlanResourceChangeResponse{
+				PlannedState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.Number,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.NullVal(cty.Number),
+						}),
+					),
+				},
+				RequiresReplace: []*tftypes.AttributePath{
+					tftypes.NewAttributePath().WithAttributeName("id"),
+				},
+				PlannedPrivate:              []byte(`{"_new_extra_shim":{}}`),
+				UnsafeToUseLegacyTypeSystem: true,
+				PlannedIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"name": cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"name": cty.StringVal("test-name"),
+							}),
+						),
+					},
+				},
+			},
+		},
+		"new-resource-with-identity": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 4,
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+						Identity: &ResourceIdentity{
+							Version: 1,
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{
+									"name": {
+										Type:              TypeString,
+										RequiredForImport: true,
+									},
+								}
+							},
+						},
+						CustomizeDiff: func(ctx context.Context, d *ResourceDiff, meta interface{}) error {
+							identity, err := d.Identity()
+							if err != nil {
+								return err
+							}
+							err = identity.Set("name", "Peter")
+							if err != nil {
+								return err
+							}
+							return nil
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.StringVal("baz"),
+						}),
+					),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.StringVal("baz"),
+						}),
+					),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.StringVal("baz"),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.PlanResourceChangeResponse{
+				PlannedState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.StringVal("baz"),
+						}),
+					),
+				},
+				RequiresReplace: []*tftypes.AttributePath{
+					tftypes.NewAttributePath().WithAttributeName("id"),
+				},
+				PlannedPrivate:              []byte(`{"_new_extra_shim":{}}`),
+				UnsafeToUseLegacyTypeSystem: true,
+				PlannedIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"name": cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"name": cty.StringVal("Peter"),
+							}),
+						),
+					},
+				},
+			},
+		},
+		"no identity schema": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 4,
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeInt,
+								Optional: true,
+							},
+						},
+						Identity: &ResourceIdentity{
+							Version: 1,
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"foo": cty.Number,
+						}),
+						cty.NullVal(
+							cty.Object(map[string]cty.Type{
+								"foo": cty.Number,
+							}),
+						),
+					),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.Number,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.NullVal(cty.Number),
+						}),
+					),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.Number,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.NullVal(cty.Number),
+						}),
+					),
+				},
+				PriorIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"name": cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"name": cty.StringVal("test-name"),
+							}),
+						),
+					},
+				},
+			},
+			expected: &tfprotov5.PlanResourceChangeResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "getting identity schema failed for resource 'test': resource does not have an identity schema",
+					},
+				},
+				UnsafeToUseLegacyTypeSystem: true,
+			},
+		},
+		"empty identity schema": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 4,
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeInt,
+								Optional: true,
+							},
+						},
+						Identity: &ResourceIdentity{
+							Version: 1,
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{}
+							},
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"foo": cty.Number,
+						}),
+						cty.NullVal(
+							cty.Object(map[string]cty.Type{
+								"foo": cty.Number,
+							}),
+						),
+					),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.Number,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.NullVal(cty.Number),
+						}),
+					),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.Number,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.NullVal(cty.Number),
+						}),
+					),
+				},
+				PriorIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"name": cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"name": cty.StringVal("test-name"),
+							}),
+						),
+					},
+				},
+			},
+			expected: &tfprotov5.PlanResourceChangeResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
 						Severity: tfprotov5.DiagnosticSeverityError,
 						Summary:  "getting identity schema failed for resource 'test': identity schema must have at least one attribute",
 					},
 				},
-				UnsafeToUseLegacyTypeSystem: true,
+				UnsafeToUseLegacyTypeSystem: true,
+			},
+		},
+		"basic-plan-EnableLegacyTypeSystemPlanErrors": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						// Will set UnsafeToUseLegacyTypeSystem to false
+						EnableLegacyTypeSystemPlanErrors: true,
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeInt,
+								Optional: true,
+							},
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"foo": cty.Number,
+						}),
+						cty.NullVal(
+							cty.Object(map[string]cty.Type{
+								"foo": cty.Number,
+							}),
+						),
+					),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.Number,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.NullVal(cty.Number),
+						}),
+					),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.Number,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.NullVal(cty.Number),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.PlanResourceChangeResponse{
+				PlannedState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.Number,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.NullVal(cty.Number),
+						}),
+					),
+				},
+				RequiresReplace: []*tftypes.AttributePath{
+					tftypes.NewAttributePath().WithAttributeName("id"),
+				},
+				PlannedPrivate:              []byte(`{"_new_extra_shim":{}}`),
+				UnsafeToUseLegacyTypeSystem: false,
+			},
+		},
+		"deferred-with-provider-plan-modification": {
+			server: NewGRPCProviderServer(&Provider{
+				providerDeferred: &Deferred{
+					Reason: DeferredReasonProviderConfigUnknown,
+				},
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						ResourceBehavior: ResourceBehavior{
+							ProviderDeferred: ProviderDeferredBehavior{
+								// Will ensure that CustomizeDiff is called
+								EnablePlanModification: true,
+							},
+						},
+						SchemaVersion: 4,
+						CustomizeDiff: func(ctx context.Context, d *ResourceDiff, i interface{}) error {
+							return d.SetNew("foo", "new-foo-value")
+						},
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeString,
+								Optional: true,
+								Computed: true,
+							},
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				ClientCapabilities: &tfprotov5.PlanResourceChangeClientCapabilities{
+					DeferralAllowed: true,
+				},
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"foo": cty.String,
+						}),
+						cty.NullVal(
+							cty.Object(map[string]cty.Type{
+								"foo": cty.String,
+							}),
+						),
+					),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.UnknownVal(cty.String),
+						}),
+					),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.NullVal(cty.String),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.PlanResourceChangeResponse{
+				Deferred: &tfprotov5.Deferred{
+					Reason: tfprotov5.DeferredReasonProviderConfigUnknown,
+				},
+				PlannedState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.StringVal("new-foo-value"),
+						}),
+					),
+				},
+				RequiresReplace: []*tftypes.AttributePath{
+					tftypes.NewAttributePath().WithAttributeName("id"),
+				},
+				PlannedPrivate:              []byte(`{"_new_extra_shim":{}}`),
+				UnsafeToUseLegacyTypeSystem: true,
+			},
+		},
+		"deferred-skip-plan-modification": {
+			server: NewGRPCProviderServer(&Provider{
+				providerDeferred: &Deferred{
+					Reason: DeferredReasonProviderConfigUnknown,
+				},
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 4,
+						CustomizeDiff: func(ctx context.Context, d *ResourceDiff, i interface{}) error {
+							return errors.New("Test assertion failed: CustomizeDiff shouldn't be called")
+						},
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeString,
+								Optional: true,
+								Computed: true,
+							},
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				ClientCapabilities: &tfprotov5.PlanResourceChangeClientCapabilities{
+					DeferralAllowed: true,
+				},
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"foo": cty.String,
+						}),
+						cty.NullVal(
+							cty.Object(map[string]cty.Type{
+								"foo": cty.String,
+							}),
+						),
+					),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.StringVal("from-config!"),
+						}),
+					),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.StringVal("from-config!"),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.PlanResourceChangeResponse{
+				Deferred: &tfprotov5.Deferred{
+					Reason: tfprotov5.DeferredReasonProviderConfigUnknown,
+				},
+				// Returns proposed new state with deferred response
+				PlannedState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.StringVal("from-config!"),
+						}),
+					),
+				},
+				UnsafeToUseLegacyTypeSystem: true,
+			},
+		},
+		"create: write-only value can be retrieved in CustomizeDiff": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 4,
+						CustomizeDiff: func(ctx context.Context, d *ResourceDiff, i interface{}) error {
+							val := d.Get("foo")
+							if val != "bar" {
+								t.Fatalf("Incorrect write-only value")
+							}
+
+							return nil
+						},
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:      TypeString,
+								Optional:  true,
+								WriteOnly: true,
+							},
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"foo": cty.String,
+						}),
+						cty.NullVal(
+							cty.Object(map[string]cty.Type{
+								"foo": cty.String,
+							}),
+						),
+					),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.StringVal("bar"),
+						}),
+					),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.StringVal("bar"),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.PlanResourceChangeResponse{
+				PlannedState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.NullVal(cty.String),
+						}),
+					),
+				},
+				PlannedPrivate: []byte(`{"_new_extra_shim":{}}`),
+				RequiresReplace: []*tftypes.AttributePath{
+					tftypes.NewAttributePath().WithAttributeName("id"),
+				},
+				UnsafeToUseLegacyTypeSystem: true,
+			},
+		},
+		"create: nested write-only value can be retrieved from raw config and plan in CustomizeDiff": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 4,
+						CustomizeDiff: func(ctx context.Context, d *ResourceDiff, i interface{}) error {
+							for name, raw := range map[string]cty.Value{
+								"config": d.GetRawConfig(),
+								"plan":   d.GetRawPlan(),
+							} {
+								val := raw.GetAttr("block").Index(cty.NumberIntVal(0)).GetAttr("foo")
+								if !val.RawEquals(cty.StringVal("bar")) {
+									t.Fatalf("Incorrect write-only value in raw %s: %#v", name, val)
+								}
+							}
+
+							return nil
+						},
+						Schema: map[string]*Schema{
+							"block": {
+								Type:     TypeList,
+								Optional: true,
+								Elem: &Resource{
+									Schema: map[string]*Schema{
+										"foo": {
+											Type:      TypeString,
+											Optional:  true,
+											WriteOnly: true,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id": cty.String,
+							"block": cty.List(cty.Object(map[string]cty.Type{
+								"foo": cty.String,
+							})),
+						}),
+						cty.NullVal(
+							cty.Object(map[string]cty.Type{
+								"id": cty.String,
+								"block": cty.List(cty.Object(map[string]cty.Type{
+									"foo": cty.String,
+								})),
+							}),
+						),
+					),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id": cty.String,
+							"block": cty.List(cty.Object(map[string]cty.Type{
+								"foo": cty.String,
+							})),
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id": cty.UnknownVal(cty.String),
+							"block": cty.ListVal([]cty.Value{
+								cty.ObjectVal(map[string]cty.Value{
+									"foo": cty.StringVal("bar"),
+								}),
+							}),
+						}),
+					),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id": cty.String,
+							"block": cty.List(cty.Object(map[string]cty.Type{
+								"foo": cty.String,
+							})),
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id": cty.NullVal(cty.String),
+							"block": cty.ListVal([]cty.Value{
+								cty.ObjectVal(map[string]cty.Value{
+									"foo": cty.StringVal("bar"),
+								}),
+							}),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.PlanResourceChangeResponse{
+				PlannedState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id": cty.String,
+							"block": cty.List(cty.Object(map[string]cty.Type{
+								"foo": cty.String,
+							})),
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id": cty.UnknownVal(cty.String),
+							"block": cty.ListVal([]cty.Value{
+								cty.ObjectVal(map[string]cty.Value{
+									"foo": cty.NullVal(cty.String),
+								}),
+							}),
+						}),
+					),
+				},
+				PlannedPrivate: []byte(`{"_new_extra_shim":{}}`),
+				RequiresReplace: []*tftypes.AttributePath{
+					tftypes.NewAttributePath().WithAttributeName("id"),
+				},
+				UnsafeToUseLegacyTypeSystem: true,
+			},
+		},
+		"create: write-only values are nullified in PlanResourceChangeResponse": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 4,
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:      TypeString,
+								Optional:  true,
+								WriteOnly: true,
+							},
+							"bar": {
+								Type:      TypeString,
+								Optional:  true,
+								WriteOnly: true,
+							},
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"foo": cty.String,
+							"bar": cty.String,
+						}),
+						cty.NullVal(
+							cty.Object(map[string]cty.Type{
+								"foo": cty.String,
+								"bar": cty.String,
+							}),
+						),
+					),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+							"bar": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.StringVal("baz"),
+							"bar": cty.StringVal("boop"),
+						}),
+					),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+							"bar": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.NullVal(cty.String),
+							"foo": cty.StringVal("baz"),
+							"bar": cty.StringVal("boop"),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.PlanResourceChangeResponse{
+				PlannedState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
+							"foo": cty.String,
+							"bar": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":  cty.UnknownVal(cty.String),
+							"foo": cty.NullVal(cty.String),
+							"bar": cty.NullVal(cty.String),
+						}),
+					),
+				},
+				PlannedPrivate: []byte(`{"_new_extra_shim":{}}`),
+				RequiresReplace: []*tftypes.AttributePath{
+					tftypes.NewAttributePath().WithAttributeName("id"),
+				},
+				UnsafeToUseLegacyTypeSystem: true,
+			},
+		},
+		"update: write-only value can be retrieved in CustomizeDiff": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 4,
+						CustomizeDiff: func(ctx context.Context, d *ResourceDiff, i interface{}) error {
+							val := d.Get("write_only")
+							if val != "bar" {
+								t.Fatalf("Incorrect write-only value")
+							}
+
+							return nil
+						},
+						Schema: map[string]*Schema{
+							"configured": {
+								Type:     TypeString,
+								Optional: true,
+							},
+							"write_only": {
+								Type:      TypeString,
+								Optional:  true,
+								WriteOnly: true,
+							},
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":         cty.String,
+							"configured": cty.String,
+							"write_only": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":         cty.NullVal(cty.String),
+							"configured": cty.StringVal("prior_val"),
+							"write_only": cty.NullVal(cty.String),
+						}),
+					),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":         cty.String,
+							"configured": cty.String,
+							"write_only": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":         cty.UnknownVal(cty.String),
+							"configured": cty.StringVal("updated_val"),
+							"write_only": cty.StringVal("bar"),
+						}),
+					),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":         cty.String,
+							"configured": cty.String,
+							"write_only": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":         cty.NullVal(cty.String),
+							"configured": cty.StringVal("updated_val"),
+							"write_only": cty.StringVal("bar"),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.PlanResourceChangeResponse{
+				PlannedState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":         cty.String,
+							"configured": cty.String,
+							"write_only": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":         cty.UnknownVal(cty.String),
+							"configured": cty.StringVal("updated_val"),
+							"write_only": cty.NullVal(cty.String),
+						}),
+					),
+				},
+				PlannedPrivate: []byte(`{"_new_extra_shim":{}}`),
+				RequiresReplace: []*tftypes.AttributePath{
+					tftypes.NewAttributePath().WithAttributeName("id"),
+				},
+				UnsafeToUseLegacyTypeSystem: true,
+			},
+		},
+		"update: write-only values are nullified in PlanResourceChangeResponse": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 4,
+						Schema: map[string]*Schema{
+							"configured": {
+								Type:     TypeString,
+								Optional: true,
+							},
+							"write_onlyA": {
+								Type:      TypeString,
+								Optional:  true,
+								WriteOnly: true,
+							},
+							"write_onlyB": {
+								Type:      TypeString,
+								Optional:  true,
+								WriteOnly: true,
+							},
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":          cty.String,
+							"configured":  cty.String,
+							"write_onlyA": cty.String,
+							"write_onlyB": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":          cty.NullVal(cty.String),
+							"configured":  cty.StringVal("prior_val"),
+							"write_onlyA": cty.NullVal(cty.String),
+							"write_onlyB": cty.NullVal(cty.String),
+						}),
+					),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":          cty.String,
+							"configured":  cty.String,
+							"write_onlyA": cty.String,
+							"write_onlyB": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":          cty.UnknownVal(cty.String),
+							"configured":  cty.StringVal("updated_val"),
+							"write_onlyA": cty.StringVal("foo"),
+							"write_onlyB": cty.StringVal("bar"),
+						}),
+					),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":          cty.String,
+							"configured":  cty.String,
+							"write_onlyA": cty.String,
+							"write_onlyB": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":          cty.NullVal(cty.String),
+							"configured":  cty.StringVal("updated_val"),
+							"write_onlyA": cty.StringVal("foo"),
+							"write_onlyB": cty.StringVal("bar"),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.PlanResourceChangeResponse{
+				PlannedState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":          cty.String,
+							"configured":  cty.String,
+							"write_onlyA": cty.String,
+							"write_onlyB": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":          cty.UnknownVal(cty.String),
+							"configured":  cty.StringVal("updated_val"),
+							"write_onlyA": cty.NullVal(cty.String),
+							"write_onlyB": cty.NullVal(cty.String),
+						}),
+					),
+				},
+				PlannedPrivate: []byte(`{"_new_extra_shim":{}}`),
+				RequiresReplace: []*tftypes.AttributePath{
+					tftypes.NewAttributePath().WithAttributeName("id"),
+				},
+				UnsafeToUseLegacyTypeSystem: true,
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, err := testCase.server.PlanResourceChange(context.Background(), testCase.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(resp, testCase.expected, valueComparer); diff != "" {
+				ty := testCase.server.getResourceSchemaBlock("test").ImpliedType()
+
+				if resp != nil && resp.PlannedState != nil {
+					t.Logf("resp.PlannedState.MsgPack: %s", mustMsgpackUnmarshal(ty, resp.PlannedState.MsgPack))
+				}
+
+				if testCase.expected != nil && testCase.expected.PlannedState != nil {
+					t.Logf("expected: %s", mustMsgpackUnmarshal(ty, testCase.expected.PlannedState.MsgPack))
+				}
+
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+// PlanResourceChange is called concurrently by Terraform for independent
+// resources, so CustomizeDiff must be able to run in parallel without sharing
+// any ResourceDiff state. Run with -race to verify.
+func TestPlanResourceChange_concurrentCustomizeDiff(t *testing.T) {
+	t.Parallel()
+
+	const resourceCount = 8
+
+	var arrived int32
+	allArrived := make(chan struct{})
+
+	provider := &Provider{
+		ResourcesMap: map[string]*Resource{},
+	}
+
+	for i := 0; i < resourceCount; i++ {
+		provider.ResourcesMap[fmt.Sprintf("test_%d", i)] = &Resource{
+			Schema: map[string]*Schema{
+				"name": {
+					Type:     TypeString,
+					Required: true,
+				},
+				"computed": {
+					Type:     TypeString,
+					Computed: true,
+				},
+			},
+			CustomizeDiff: func(ctx context.Context, d *ResourceDiff, meta interface{}) error {
+				// Block until every CustomizeDiff is running at once, which
+				// can only happen if they are not serialized.
+				if atomic.AddInt32(&arrived, 1) == resourceCount {
+					close(allArrived)
+				}
+
+				select {
+				case <-allArrived:
+				case <-time.After(10 * time.Second):
+					return errors.New("CustomizeDiff calls did not run concurrently")
+				}
+
+				return d.SetNew("computed", d.Get("name"))
+			},
+		}
+	}
+
+	server := NewGRPCProviderServer(provider)
+
+	ty := cty.Object(map[string]cty.Type{
+		"id":       cty.String,
+		"name":     cty.String,
+		"computed": cty.String,
+	})
+
+	var wg sync.WaitGroup
+	responses := make([]*tfprotov5.PlanResourceChangeResponse, resourceCount)
+	errs := make([]error, resourceCount)
+
+	for i := 0; i < resourceCount; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			typeName := fmt.Sprintf("test_%d", i)
+			config := cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.NullVal(cty.String),
+				"name":     cty.StringVal(typeName),
+				"computed": cty.NullVal(cty.String),
+			})
+			proposedNewState := cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.UnknownVal(cty.String),
+				"name":     cty.StringVal(typeName),
+				"computed": cty.UnknownVal(cty.String),
+			})
+
+			responses[i], errs[i] = server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+				TypeName: typeName,
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, cty.NullVal(ty)),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, proposedNewState),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, config),
+				},
+			})
+		}(i)
+	}
+
+	wg.Wait()
+
+	for i := 0; i < resourceCount; i++ {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+
+		for _, d := range responses[i].Diagnostics {
+			t.Errorf("unexpected diagnostic: %s: %s", d.Summary, d.Detail)
+		}
+
+		planned, err := msgpack.Unmarshal(responses[i].PlannedState.MsgPack, ty)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := planned.GetAttr("computed"), cty.StringVal(fmt.Sprintf("test_%d", i)); !got.RawEquals(want) {
+			t.Errorf("unexpected planned value for test_%d: %#v", i, got)
+		}
+	}
+}
+
+func TestPlanResourceChange_customizeDiffRequiresReplace(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		paths           []*tftypes.AttributePath
+		expected        []*tftypes.AttributePath
+		expectedDiagErr string
+	}{
+		"nested attribute": {
+			paths: []*tftypes.AttributePath{
+				tftypes.NewAttributePath().WithAttributeName("block").WithElementKeyInt(0).WithAttributeName("nested"),
+			},
+			expected: []*tftypes.AttributePath{
+				tftypes.NewAttributePath().WithAttributeName("block").WithElementKeyInt(0).WithAttributeName("nested"),
+			},
+		},
+		"set element": {
+			paths: []*tftypes.AttributePath{
+				tftypes.NewAttributePath().WithAttributeName("set_attr").WithElementKeyValue(tftypes.NewValue(tftypes.String, "y")),
+			},
+			expected: []*tftypes.AttributePath{
+				tftypes.NewAttributePath().WithAttributeName("set_attr").WithElementKeyValue(tftypes.NewValue(tftypes.String, "y")),
+			},
+		},
+		"duplicate paths": {
+			paths: []*tftypes.AttributePath{
+				tftypes.NewAttributePath().WithAttributeName("foo"),
+				tftypes.NewAttributePath().WithAttributeName("foo"),
+			},
+			expected: []*tftypes.AttributePath{
+				tftypes.NewAttributePath().WithAttributeName("foo"),
+			},
+		},
+		"invalid attribute": {
+			paths: []*tftypes.AttributePath{
+				tftypes.NewAttributePath().WithAttributeName("invalid"),
+			},
+			expectedDiagErr: "SetRequiresReplace: invalid is not a valid key",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &Resource{
+				CustomizeDiff: func(ctx context.Context, d *ResourceDiff, meta interface{}) error {
+					return d.SetRequiresReplace(testCase.paths...)
+				},
+				Schema: map[string]*Schema{
+					"foo": {
+						Type:     TypeString,
+						Optional: true,
+					},
+					"block": {
+						Type:     TypeList,
+						Optional: true,
+						Elem: &Resource{
+							Schema: map[string]*Schema{
+								"nested": {
+									Type:     TypeString,
+									Optional: true,
+								},
+							},
+						},
+					},
+					"set_attr": {
+						Type:     TypeSet,
+						Optional: true,
+						Elem:     &Schema{Type: TypeString},
+					},
+				},
+			}
+
+			server := NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": r,
+				},
+			})
+
+			ty := r.CoreConfigSchema().ImpliedType()
+
+			priorState := cty.ObjectVal(map[string]cty.Value{
+				"id":  cty.StringVal("test"),
+				"foo": cty.StringVal("a"),
+				"block": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"nested": cty.StringVal("a"),
+					}),
+				}),
+				"set_attr": cty.SetVal([]cty.Value{cty.StringVal("x")}),
+			})
+
+			config := cty.ObjectVal(map[string]cty.Value{
+				"id":  cty.NullVal(cty.String),
+				"foo": cty.StringVal("b"),
+				"block": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"nested": cty.StringVal("b"),
+					}),
+				}),
+				"set_attr": cty.SetVal([]cty.Value{cty.StringVal("y")}),
+			})
+
+			proposedNewState := cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.StringVal("test"),
+				"foo":      config.GetAttr("foo"),
+				"block":    config.GetAttr("block"),
+				"set_attr": config.GetAttr("set_attr"),
+			})
+
+			resp, err := server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, priorState),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, proposedNewState),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, config),
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if testCase.expectedDiagErr != "" {
+				AssertDiagnosticCount(t, resp.Diagnostics, 1)
+				AssertDiagnosticContains(t, resp.Diagnostics, tfprotov5.DiagnosticSeverityError, testCase.expectedDiagErr)
+
+				return
+			}
+
+			if len(resp.Diagnostics) > 0 {
+				t.Fatalf("unexpected diagnostics: %#v", resp.Diagnostics)
+			}
+
+			if diff := cmp.Diff(resp.RequiresReplace, testCase.expected); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestPlanResourceChange_forceNewFunc(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		prior    cty.Value
+		config   cty.Value
+		expected []*tftypes.AttributePath
+	}{
+		"growing": {
+			prior:  cty.NumberIntVal(2),
+			config: cty.NumberIntVal(4),
+		},
+		"shrinking": {
+			prior:  cty.NumberIntVal(4),
+			config: cty.NumberIntVal(2),
+			expected: []*tftypes.AttributePath{
+				tftypes.NewAttributePath().WithAttributeName("size"),
+				tftypes.NewAttributePath().WithAttributeName("id"),
+			},
+		},
+		"unknown falls back to ForceNew": {
+			prior:  cty.NumberIntVal(4),
+			config: cty.UnknownVal(cty.Number),
+			expected: []*tftypes.AttributePath{
+				tftypes.NewAttributePath().WithAttributeName("size"),
+				tftypes.NewAttributePath().WithAttributeName("id"),
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &Resource{
+				Schema: map[string]*Schema{
+					"size": {
+						Type:     TypeInt,
+						Optional: true,
+						ForceNew: true,
+						ForceNewFunc: func(ctx context.Context, oldValue, newValue interface{}) bool {
+							if !testCase.config.IsKnown() {
+								t.Error("ForceNewFunc called with unknown value")
+							}
+
+							return newValue.(int) < oldValue.(int)
+						},
+					},
+				},
+			}
+
+			server := NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": r,
+				},
+			})
+
+			ty := r.CoreConfigSchema().ImpliedType()
+
+			priorState := cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.StringVal("test"),
+				"size": testCase.prior,
+			})
+
+			config := cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.NullVal(cty.String),
+				"size": testCase.config,
+			})
+
+			proposedNewState := cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.StringVal("test"),
+				"size": testCase.config,
+			})
+
+			resp, err := server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, priorState),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, proposedNewState),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, config),
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.Diagnostics) > 0 {
+				t.Fatalf("unexpected diagnostics: %#v", resp.Diagnostics)
+			}
+
+			if diff := cmp.Diff(resp.RequiresReplace, testCase.expected); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestPlanResourceChange_forceNewFuncNestedForceNew(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		prior    cty.Value
+		config   cty.Value
+		expected []*tftypes.AttributePath
+	}{
+		"ForceNew child changed": {
+			prior: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("foo"),
+				"size": cty.NumberIntVal(2),
+			}),
+			config: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("bar"),
+				"size": cty.NumberIntVal(2),
+			}),
+			expected: []*tftypes.AttributePath{
+				tftypes.NewAttributePath().WithAttributeName("block").WithElementKeyInt(0).WithAttributeName("name"),
+				tftypes.NewAttributePath().WithAttributeName("id"),
+			},
+		},
+		"other child changed": {
+			prior: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("foo"),
+				"size": cty.NumberIntVal(2),
+			}),
+			config: cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("foo"),
+				"size": cty.NumberIntVal(4),
+			}),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &Resource{
+				Schema: map[string]*Schema{
+					"block": {
+						Type:     TypeList,
+						Optional: true,
+						ForceNew: true,
+						ForceNewFunc: func(ctx context.Context, oldValue, newValue interface{}) bool {
+							return false
+						},
+						Elem: &Resource{
+							Schema: map[string]*Schema{
+								"name": {
+									Type:     TypeString,
+									Optional: true,
+									ForceNew: true,
+								},
+								"size": {
+									Type:     TypeInt,
+									Optional: true,
+								},
+							},
+						},
+					},
+				},
+			}
+
+			server := NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": r,
+				},
+			})
+
+			ty := r.CoreConfigSchema().ImpliedType()
+
+			priorState := cty.ObjectVal(map[string]cty.Value{
+				"id":    cty.StringVal("test"),
+				"block": cty.ListVal([]cty.Value{testCase.prior}),
+			})
+
+			config := cty.ObjectVal(map[string]cty.Value{
+				"id":    cty.NullVal(cty.String),
+				"block": cty.ListVal([]cty.Value{testCase.config}),
+			})
+
+			proposedNewState := cty.ObjectVal(map[string]cty.Value{
+				"id":    cty.StringVal("test"),
+				"block": cty.ListVal([]cty.Value{testCase.config}),
+			})
+
+			resp, err := server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, priorState),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, proposedNewState),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, config),
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.Diagnostics) > 0 {
+				t.Fatalf("unexpected diagnostics: %#v", resp.Diagnostics)
+			}
+
+			if diff := cmp.Diff(resp.RequiresReplace, testCase.expected); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestPlanResourceChange_setNewComputed(t *testing.T) {
+	t.Parallel()
+
+	r := &Resource{
+		Schema: map[string]*Schema{
+			"foo": {
+				Type:     TypeString,
+				Optional: true,
+			},
+			"computed_string": {
+				Type:     TypeString,
+				Computed: true,
+			},
+			"computed_list": {
+				Type:     TypeList,
+				Computed: true,
+				Elem:     &Schema{Type: TypeString},
+			},
+			"computed_map": {
+				Type:     TypeMap,
+				Computed: true,
+				Elem:     &Schema{Type: TypeString},
+			},
+		},
+		CustomizeDiff: func(_ context.Context, d *ResourceDiff, _ interface{}) error {
+			if !d.HasChange("foo") {
+				return nil
+			}
+
+			for _, k := range []string{"computed_string", "computed_list", "computed_map"} {
+				if err := d.SetNewComputed(k); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": r,
+		},
+	})
+
+	ty := r.CoreConfigSchema().ImpliedType()
+
+	testCases := map[string]struct {
+		foo      string
+		expected cty.Value
+	}{
+		"unchanged": {
+			foo: "a",
+			expected: cty.ObjectVal(map[string]cty.Value{
+				"id":              cty.StringVal("test"),
+				"foo":             cty.StringVal("a"),
+				"computed_string": cty.StringVal("value"),
+				"computed_list":   cty.ListVal([]cty.Value{cty.StringVal("value")}),
+				"computed_map":    cty.MapVal(map[string]cty.Value{"key": cty.StringVal("value")}),
+			}),
+		},
+		"changed": {
+			foo: "b",
+			expected: cty.ObjectVal(map[string]cty.Value{
+				"id":              cty.StringVal("test"),
+				"foo":             cty.StringVal("b"),
+				"computed_string": cty.UnknownVal(cty.String),
+				"computed_list":   cty.UnknownVal(cty.List(cty.String)),
+				"computed_map":    cty.UnknownVal(cty.Map(cty.String)),
+			}),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			priorState := cty.ObjectVal(map[string]cty.Value{
+				"id":              cty.StringVal("test"),
+				"foo":             cty.StringVal("a"),
+				"computed_string": cty.StringVal("value"),
+				"computed_list":   cty.ListVal([]cty.Value{cty.StringVal("value")}),
+				"computed_map":    cty.MapVal(map[string]cty.Value{"key": cty.StringVal("value")}),
+			})
+
+			config := cty.ObjectVal(map[string]cty.Value{
+				"id":              cty.NullVal(cty.String),
+				"foo":             cty.StringVal(testCase.foo),
+				"computed_string": cty.NullVal(cty.String),
+				"computed_list":   cty.NullVal(cty.List(cty.String)),
+				"computed_map":    cty.NullVal(cty.Map(cty.String)),
+			})
+
+			proposedNewState := cty.ObjectVal(map[string]cty.Value{
+				"id":              priorState.GetAttr("id"),
+				"foo":             config.GetAttr("foo"),
+				"computed_string": priorState.GetAttr("computed_string"),
+				"computed_list":   priorState.GetAttr("computed_list"),
+				"computed_map":    priorState.GetAttr("computed_map"),
+			})
+
+			resp, err := server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, priorState),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, proposedNewState),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, config),
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(resp.Diagnostics) > 0 {
+				t.Fatalf("unexpected diagnostics: %#v", resp.Diagnostics)
+			}
+
+			plannedState, err := msgpack.Unmarshal(resp.PlannedState.MsgPack, ty)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !plannedState.RawEquals(testCase.expected) {
+				t.Errorf("expected planned state %#v, got %#v", testCase.expected, plannedState)
+			}
+		})
+	}
+}
+
+func TestPlanResourceChange_setOrdering(t *testing.T) {
+	t.Parallel()
+
+	r := &Resource{
+		Schema: map[string]*Schema{
+			"tags": {
+				Type:     TypeSet,
+				Optional: true,
+				Elem:     &Schema{Type: TypeString},
+			},
+			"rule": {
+				Type:     TypeSet,
+				Optional: true,
+				Elem: &Resource{
+					Schema: map[string]*Schema{
+						"port": {
+							Type:     TypeInt,
+							Optional: true,
+						},
+						"protocol": {
+							Type:     TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": r,
+		},
+	})
+
+	ty := r.CoreConfigSchema().ImpliedType()
+
+	// The sets are encoded as lists, which have the same msgpack encoding, so
+	// that the request contains the elements in the given order.
+	ruleTy := cty.Object(map[string]cty.Type{
+		"port":     cty.Number,
+		"protocol": cty.String,
+	})
+	orderedTy := cty.Object(map[string]cty.Type{
+		"id":   cty.String,
+		"tags": cty.List(cty.String),
+		"rule": cty.List(ruleTy),
+	})
+
+	plan := func(tags []cty.Value, rules []cty.Value) []byte {
+		t.Helper()
+
+		config := mustMsgpackMarshal(orderedTy, cty.ObjectVal(map[string]cty.Value{
+			"id":   cty.NullVal(cty.String),
+			"tags": cty.ListVal(tags),
+			"rule": cty.ListVal(rules),
+		}))
+
+		resp, err := server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+			TypeName: "test",
+			PriorState: &tfprotov5.DynamicValue{
+				MsgPack: mustMsgpackMarshal(ty, cty.NullVal(ty)),
+			},
+			ProposedNewState: &tfprotov5.DynamicValue{
+				MsgPack: config,
+			},
+			Config: &tfprotov5.DynamicValue{
+				MsgPack: config,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(resp.Diagnostics) > 0 {
+			t.Fatalf("unexpected diagnostics: %#v", resp.Diagnostics)
+		}
+
+		return resp.PlannedState.MsgPack
+	}
+
+	ruleA := cty.ObjectVal(map[string]cty.Value{
+		"port":     cty.NumberIntVal(80),
+		"protocol": cty.StringVal("tcp"),
+	})
+	ruleB := cty.ObjectVal(map[string]cty.Value{
+		"port":     cty.NumberIntVal(53),
+		"protocol": cty.StringVal("udp"),
+	})
+
+	first := plan(
+		[]cty.Value{cty.StringVal("a"), cty.StringVal("b"), cty.StringVal("c")},
+		[]cty.Value{ruleA, ruleB},
+	)
+	second := plan(
+		[]cty.Value{cty.StringVal("c"), cty.StringVal("a"), cty.StringVal("b")},
+		[]cty.Value{ruleB, ruleA},
+	)
+
+	if !bytes.Equal(first, second) {
+		t.Errorf("expected identical planned states, got:\n%x\n%x", first, second)
+	}
+}
+
+func TestPlanResourceChange_planModifiers(t *testing.T) {
+	t.Parallel()
+
+	lower := func(ctx context.Context, req AttributePlanModifyRequest, resp *AttributePlanModifyResponse) {
+		if req.PlanValue.IsNull() || !req.PlanValue.IsKnown() {
+			return
+		}
+
+		resp.PlanValue = cty.StringVal(strings.ToLower(req.PlanValue.AsString()))
+	}
+
+	ty := cty.Object(map[string]cty.Type{
+		"id":   cty.String,
+		"name": cty.String,
+	})
+
+	testCases := map[string]struct {
+		modifiers     []SchemaPlanModifierFunc
+		priorState    cty.Value
+		config        cty.Value
+		expected      cty.Value
+		expectedDiags []*tfprotov5.Diagnostic
+	}{
+		"normalize on create": {
+			modifiers:  []SchemaPlanModifierFunc{lower},
+			priorState: cty.NullVal(ty),
+			config: cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.NullVal(cty.String),
+				"name": cty.StringVal("FOO"),
+			}),
+			expected: cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.UnknownVal(cty.String),
+				"name": cty.StringVal("foo"),
+			}),
+		},
+		"normalized value matches prior state": {
+			modifiers: []SchemaPlanModifierFunc{lower},
+			priorState: cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.StringVal("test"),
+				"name": cty.StringVal("foo"),
+			}),
+			config: cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.NullVal(cty.String),
+				"name": cty.StringVal("FOO"),
+			}),
+			expected: cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.StringVal("test"),
+				"name": cty.StringVal("foo"),
+			}),
+		},
+		"modifiers called in order": {
+			modifiers: []SchemaPlanModifierFunc{
+				lower,
+				func(ctx context.Context, req AttributePlanModifyRequest, resp *AttributePlanModifyResponse) {
+					resp.PlanValue = cty.StringVal(req.PlanValue.AsString() + "-" + req.ConfigValue.AsString())
+				},
+			},
+			priorState: cty.NullVal(ty),
+			config: cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.NullVal(cty.String),
+				"name": cty.StringVal("FOO"),
+			}),
+			expected: cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.UnknownVal(cty.String),
+				"name": cty.StringVal("foo-FOO"),
+			}),
+		},
+		"error stops modifiers": {
+			modifiers: []SchemaPlanModifierFunc{
+				func(ctx context.Context, req AttributePlanModifyRequest, resp *AttributePlanModifyResponse) {
+					resp.Diagnostics = diag.Errorf("invalid name")
+				},
+				func(ctx context.Context, req AttributePlanModifyRequest, resp *AttributePlanModifyResponse) {
+					t.Error("plan modifier called after error")
+				},
+			},
+			priorState: cty.NullVal(ty),
+			config: cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.NullVal(cty.String),
+				"name": cty.StringVal("FOO"),
+			}),
+			expectedDiags: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "invalid name",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("name"),
+				},
+			},
+		},
+		"invalid planned value type": {
+			modifiers: []SchemaPlanModifierFunc{
+				func(ctx context.Context, req AttributePlanModifyRequest, resp *AttributePlanModifyResponse) {
+					resp.PlanValue = cty.NumberIntVal(1)
+				},
+			},
+			priorState: cty.NullVal(ty),
+			config: cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.NullVal(cty.String),
+				"name": cty.StringVal("FOO"),
+			}),
+			expectedDiags: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "Invalid Planned Value",
+					Detail:    "A plan modifier for attribute \"name\" returned a value which does not conform to the attribute type. This is an issue with the provider and should be reported to the provider developers.",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("name"),
+				},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						Schema: map[string]*Schema{
+							"name": {
+								Type:          TypeString,
+								Optional:      true,
+								Computed:      true,
+								PlanModifiers: tc.modifiers,
+							},
+						},
+					},
+				},
+			})
+
+			config := mustMsgpackMarshal(ty, tc.config)
+
+			resp, err := server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, tc.priorState),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: config,
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: config,
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(tc.expectedDiags, resp.Diagnostics); diff != "" {
+				t.Fatalf("unexpected diagnostics difference: %s", diff)
+			}
+
+			if tc.expectedDiags != nil {
+				return
+			}
+
+			plannedStateVal, err := msgpack.Unmarshal(resp.PlannedState.MsgPack, ty)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !tc.expected.RawEquals(plannedStateVal) {
+				t.Fatalf("expected planned state %#v, got %#v", tc.expected, plannedStateVal)
+			}
+		})
+	}
+}
+
+func TestPlanResourceChange_skipUnchangedComputedDiff(t *testing.T) {
+	t.Parallel()
+
+	ty := cty.Object(map[string]cty.Type{
+		"id":        cty.String,
+		"name":      cty.String,
+		"size":      cty.Number,
+		"arn":       cty.String,
+		"tags_all":  cty.Map(cty.String),
+		"addresses": cty.List(cty.String),
+		"empty":     cty.List(cty.String),
+		"updated":   cty.String,
+	})
+
+	priorState := cty.ObjectVal(map[string]cty.Value{
+		"id":        cty.StringVal("test"),
+		"name":      cty.StringVal("foo"),
+		"size":      cty.NumberIntVal(1),
+		"arn":       cty.StringVal("arn:foo"),
+		"tags_all":  cty.MapVal(map[string]cty.Value{"a": cty.StringVal("b")}),
+		"addresses": cty.ListVal([]cty.Value{cty.StringVal("10.0.0.1")}),
+		"empty":     cty.ListValEmpty(cty.String),
+		"updated":   cty.StringVal("t1"),
+	})
+
+	testCases := map[string]struct {
+		name                    string
+		size                    int64
+		expectedRequiresReplace []*tftypes.AttributePath
+	}{
+		"no changes": {
+			name: "foo",
+			size: 1,
+		},
+		"update": {
+			name: "foo",
+			size: 2,
+		},
+		"replace": {
+			name: "bar",
+			size: 1,
+			expectedRequiresReplace: []*tftypes.AttributePath{
+				tftypes.NewAttributePath().WithAttributeName("name"),
+				tftypes.NewAttributePath().WithAttributeName("id"),
+			},
+		},
+	}
+
+	plan := func(t *testing.T, skip bool, name string, size int64) *tfprotov5.PlanResourceChangeResponse {
+		t.Helper()
+
+		server := NewGRPCProviderServer(&Provider{
+			ResourcesMap: map[string]*Resource{
+				"test": {
+					SkipUnchangedComputedDiff: skip,
+					Schema: map[string]*Schema{
+						"name": {
+							Type:     TypeString,
+							Required: true,
+							ForceNew: true,
+						},
+						"size": {
+							Type:     TypeInt,
+							Required: true,
+						},
+						"arn": {
+							Type:     TypeString,
+							Computed: true,
+						},
+						"tags_all": {
+							Type:     TypeMap,
+							Computed: true,
+							Elem:     &Schema{Type: TypeString},
+						},
+						"addresses": {
+							Type:     TypeList,
+							Computed: true,
+							Elem:     &Schema{Type: TypeString},
+						},
+						"empty": {
+							Type:     TypeList,
+							Computed: true,
+							Elem:     &Schema{Type: TypeString},
+						},
+						"updated": {
+							Type:     TypeString,
+							Computed: true,
+						},
+					},
+					CustomizeDiff: func(ctx context.Context, d *ResourceDiff, meta interface{}) error {
+						if d.HasChange("size") {
+							return d.SetNewComputed("updated")
+						}
+						return nil
+					},
+				},
+			},
+		})
+
+		config := cty.ObjectVal(map[string]cty.Value{
+			"id":        cty.NullVal(cty.String),
+			"name":      cty.StringVal(name),
+			"size":      cty.NumberIntVal(size),
+			"arn":       cty.NullVal(cty.String),
+			"tags_all":  cty.NullVal(cty.Map(cty.String)),
+			"addresses": cty.NullVal(cty.List(cty.String)),
+			"empty":     cty.NullVal(cty.List(cty.String)),
+			"updated":   cty.NullVal(cty.String),
+		})
+
+		proposedNewState := cty.ObjectVal(map[string]cty.Value{
+			"id":        priorState.GetAttr("id"),
+			"name":      cty.StringVal(name),
+			"size":      cty.NumberIntVal(size),
+			"arn":       priorState.GetAttr("arn"),
+			"tags_all":  priorState.GetAttr("tags_all"),
+			"addresses": priorState.GetAttr("addresses"),
+			"empty":     priorState.GetAttr("empty"),
+			"updated":   priorState.GetAttr("updated"),
+		})
+
+		resp, err := server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+			TypeName: "test",
+			PriorState: &tfprotov5.DynamicValue{
+				MsgPack: mustMsgpackMarshal(ty, priorState),
+			},
+			ProposedNewState: &tfprotov5.DynamicValue{
+				MsgPack: mustMsgpackMarshal(ty, proposedNewState),
+			},
+			Config: &tfprotov5.DynamicValue{
+				MsgPack: mustMsgpackMarshal(ty, config),
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		AssertNoDiagnostics(t, resp.Diagnostics)
+
+		return resp
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			expected := plan(t, false, tc.name, tc.size)
+			got := plan(t, true, tc.name, tc.size)
+
+			expectedState := mustMsgpackUnmarshal(ty, expected.PlannedState.MsgPack)
+			gotState := mustMsgpackUnmarshal(ty, got.PlannedState.MsgPack)
+
+			if !expectedState.RawEquals(gotState) {
+				t.Fatalf("expected planned state %#v, got %#v", expectedState, gotState)
+			}
+
+			if diff := cmp.Diff(tc.expectedRequiresReplace, got.RequiresReplace); diff != "" {
+				t.Fatalf("unexpected RequiresReplace difference: %s", diff)
+			}
+
+			if diff := cmp.Diff(expected.RequiresReplace, got.RequiresReplace); diff != "" {
+				t.Fatalf("unexpected RequiresReplace difference from full diff: %s", diff)
+			}
+		})
+	}
+}
+
+func BenchmarkPlanResourceChange_skipUnchangedComputedDiff(b *testing.B) {
+	const attributes = 200
+
+	schema := map[string]*Schema{
+		"name": {
+			Type:     TypeString,
+			Required: true,
+		},
+	}
+	ctyTypes := map[string]cty.Type{
+		"id":   cty.String,
+		"name": cty.String,
+	}
+	priorVals := map[string]cty.Value{
+		"id":   cty.StringVal("test"),
+		"name": cty.StringVal("foo"),
+	}
+	configVals := map[string]cty.Value{
+		"id":   cty.NullVal(cty.String),
+		"name": cty.StringVal("bar"),
+	}
+
+	for i := 0; i < attributes; i++ {
+		k := fmt.Sprintf("computed_%d", i)
+
+		schema[k] = &Schema{
+			Type:     TypeList,
+			Computed: true,
+			Elem:     &Schema{Type: TypeString},
+		}
+		ctyTypes[k] = cty.List(cty.String)
+		priorVals[k] = cty.ListVal([]cty.Value{cty.StringVal("a"), cty.StringVal("b")})
+		configVals[k] = cty.NullVal(cty.List(cty.String))
+	}
+
+	ty := cty.Object(ctyTypes)
+	priorState := mustMsgpackMarshal(ty, cty.ObjectVal(priorVals))
+	config := mustMsgpackMarshal(ty, cty.ObjectVal(configVals))
+
+	proposedVals := make(map[string]cty.Value, len(priorVals))
+	for k, v := range priorVals {
+		proposedVals[k] = v
+	}
+	proposedVals["name"] = configVals["name"]
+	proposedNewState := mustMsgpackMarshal(ty, cty.ObjectVal(proposedVals))
+
+	for _, skip := range []bool{false, true} {
+		b.Run(fmt.Sprintf("skip=%t", skip), func(b *testing.B) {
+			server := NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SkipUnchangedComputedDiff: skip,
+						Schema:                    schema,
+					},
+				},
+			})
+
+			req := &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: priorState,
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: proposedNewState,
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: config,
+				},
+			}
+
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := server.PlanResourceChange(context.Background(), req); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanResourceChange_validateProposedState(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		priorState       cty.Value
+		proposedNewState cty.Value
+		expected         []*tfprotov5.Diagnostic
+	}{
+		"computed value carried over": {
+			priorState: cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.StringVal("test"),
+				"name":     cty.StringVal("a"),
+				"foo":      cty.StringVal("a"),
+				"computed": cty.StringVal("value"),
+			}),
+			proposedNewState: cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.StringVal("test"),
+				"name":     cty.StringVal("a"),
+				"foo":      cty.StringVal("b"),
+				"computed": cty.StringVal("value"),
+			}),
+		},
+		"computed value dropped": {
+			priorState: cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.StringVal("test"),
+				"name":     cty.StringVal("a"),
+				"foo":      cty.StringVal("a"),
+				"computed": cty.StringVal("value"),
+			}),
+			proposedNewState: cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.StringVal("test"),
+				"name":     cty.StringVal("a"),
+				"foo":      cty.StringVal("b"),
+				"computed": cty.NullVal(cty.String),
+			}),
+			expected: []*tfprotov5.Diagnostic{
+				{
+					Severity: tfprotov5.DiagnosticSeverityWarning,
+					Summary:  "Computed Attribute Missing From Proposed New State",
+					Detail: "The proposed new state has a null value for computed attribute \"computed\", which had a known value in the prior state. " +
+						"Computed values are expected to be carried over from the prior state. This may indicate an issue with Terraform or the provider and should be reported to the provider developers.",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("computed"),
+				},
+			},
+		},
+		"computed value dropped with replacement": {
+			priorState: cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.StringVal("test"),
+				"name":     cty.StringVal("a"),
+				"foo":      cty.StringVal("a"),
+				"computed": cty.StringVal("value"),
+			}),
+			proposedNewState: cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.StringVal("test"),
+				"name":     cty.StringVal("b"),
+				"foo":      cty.StringVal("a"),
+				"computed": cty.NullVal(cty.String),
+			}),
+		},
+		"create": {
+			priorState: cty.NullVal(cty.Object(map[string]cty.Type{
+				"id":       cty.String,
+				"name":     cty.String,
+				"foo":      cty.String,
+				"computed": cty.String,
+			})),
+			proposedNewState: cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.NullVal(cty.String),
+				"name":     cty.StringVal("a"),
+				"foo":      cty.StringVal("a"),
+				"computed": cty.NullVal(cty.String),
+			}),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &Resource{
+				ValidateProposedState: true,
+				Schema: map[string]*Schema{
+					"name": {
+						Type:     TypeString,
+						Required: true,
+						ForceNew: true,
+					},
+					"foo": {
+						Type:     TypeString,
+						Optional: true,
+					},
+					"computed": {
+						Type:     TypeString,
+						Computed: true,
+					},
+				},
+			}
+
+			server := NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": r,
+				},
+			})
+
+			ty := r.CoreConfigSchema().ImpliedType()
+
+			config := cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.NullVal(cty.String),
+				"name":     testCase.proposedNewState.GetAttr("name"),
+				"foo":      testCase.proposedNewState.GetAttr("foo"),
+				"computed": cty.NullVal(cty.String),
+			})
+
+			resp, err := server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, testCase.priorState),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, testCase.proposedNewState),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, config),
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(resp.Diagnostics, testCase.expected); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestPlanResourceChange_sensitiveSetElementPaths(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		secretSensitive bool
+		expected        []*tfprotov5.Diagnostic
+	}{
+		"sensitive": {
+			secretSensitive: true,
+			expected: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "Null value found in list",
+					Detail:    "Null values are not allowed for this attribute value.",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("block"),
+				},
+			},
+		},
+		"not sensitive": {
+			expected: []*tfprotov5.Diagnostic{
+				{
+					Severity: tfprotov5.DiagnosticSeverityError,
+					Summary:  "Null value found in list",
+					Detail:   "Null values are not allowed for this attribute value.",
+					Attribute: tftypes.NewAttributePath().
+						WithAttributeName("block").
+						WithElementKeyValue(tftypes.NewValue(tftypes.Object{
+							AttributeTypes: map[string]tftypes.Type{
+								"secret": tftypes.String,
+								"values": tftypes.List{ElementType: tftypes.String},
+							},
+						}, map[string]tftypes.Value{
+							"secret": tftypes.NewValue(tftypes.String, "hunter2"),
+							"values": tftypes.NewValue(tftypes.List{ElementType: tftypes.String}, []tftypes.Value{
+								tftypes.NewValue(tftypes.String, "a"),
+								tftypes.NewValue(tftypes.String, nil),
+							}),
+						})).
+						WithAttributeName("values").
+						WithElementKeyInt(1),
+				},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &Resource{
+				Schema: map[string]*Schema{
+					"block": {
+						Type:     TypeSet,
+						Optional: true,
+						Elem: &Resource{
+							Schema: map[string]*Schema{
+								"secret": {
+									Type:      TypeString,
+									Optional:  true,
+									Sensitive: testCase.secretSensitive,
+								},
+								"values": {
+									Type:     TypeList,
+									Optional: true,
+									Elem:     &Schema{Type: TypeString},
+								},
+							},
+						},
+					},
+				},
+			}
+
+			server := NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": r,
+				},
+			})
+
+			ty := r.CoreConfigSchema().ImpliedType()
+
+			proposedNewState := cty.ObjectVal(map[string]cty.Value{
+				"id": cty.UnknownVal(cty.String),
+				"block": cty.SetVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"secret": cty.StringVal("hunter2"),
+						"values": cty.ListVal([]cty.Value{
+							cty.StringVal("a"),
+							cty.NullVal(cty.String),
+						}),
+					}),
+				}),
+			})
+
+			resp, err := server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, cty.NullVal(ty)),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, proposedNewState),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, proposedNewState),
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(resp.Diagnostics, testCase.expected); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestPlanResourceChange_unknownIfChanged(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		foo      cty.Value
+		bar      cty.Value
+		expected cty.Value
+	}{
+		"unchanged": {
+			foo:      cty.StringVal("a"),
+			bar:      cty.StringVal("a"),
+			expected: cty.StringVal("value"),
+		},
+		"unreferenced attribute changed": {
+			foo:      cty.StringVal("a"),
+			bar:      cty.StringVal("b"),
+			expected: cty.StringVal("value"),
+		},
+		"referenced attribute changed": {
+			foo:      cty.StringVal("b"),
+			bar:      cty.StringVal("a"),
+			expected: cty.UnknownVal(cty.String),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &Resource{
+				Schema: map[string]*Schema{
+					"foo": {
+						Type:     TypeString,
+						Optional: true,
+					},
+					"bar": {
+						Type:     TypeString,
+						Optional: true,
+					},
+					"computed": {
+						Type:             TypeString,
+						Computed:         true,
+						UnknownIfChanged: []string{"foo"},
+					},
+				},
+			}
+
+			server := NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": r,
+				},
+			})
+
+			ty := r.CoreConfigSchema().ImpliedType()
+
+			priorState := cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.StringVal("test"),
+				"foo":      cty.StringVal("a"),
+				"bar":      cty.StringVal("a"),
+				"computed": cty.StringVal("value"),
+			})
+
+			proposedNewState := cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.StringVal("test"),
+				"foo":      testCase.foo,
+				"bar":      testCase.bar,
+				"computed": cty.StringVal("value"),
+			})
+
+			config := cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.NullVal(cty.String),
+				"foo":      testCase.foo,
+				"bar":      testCase.bar,
+				"computed": cty.NullVal(cty.String),
+			})
+
+			resp, err := server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, priorState),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, proposedNewState),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, config),
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			AssertNoDiagnostics(t, resp.Diagnostics)
+
+			plannedState, err := msgpack.Unmarshal(resp.PlannedState.MsgPack, ty)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := plannedState.GetAttr("computed"); !got.RawEquals(testCase.expected) {
+				t.Fatalf("unexpected planned computed value\ngot:  %#v\nwant: %#v", got, testCase.expected)
+			}
+		})
+	}
+}
+
+func TestPlanResourceChange_bigint(t *testing.T) {
+	r := &Resource{
+		UseJSONNumber: true,
+		Schema: map[string]*Schema{
+			"foo": {
+				Type:     TypeInt,
+				Required: true,
+			},
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": r,
+		},
+	})
+
+	schema := r.CoreConfigSchema()
+	priorState, err := msgpack.Marshal(cty.NullVal(schema.ImpliedType()), schema.ImpliedType())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proposedVal := cty.ObjectVal(map[string]cty.Value{
+		"id":  cty.UnknownVal(cty.String),
+		"foo": cty.MustParseNumberVal("7227701560655103598"),
+	})
+	proposedState, err := msgpack.Marshal(proposedVal, schema.ImpliedType())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := schema.CoerceValue(cty.ObjectVal(map[string]cty.Value{
+		"id":  cty.NullVal(cty.String),
+		"foo": cty.MustParseNumberVal("7227701560655103598"),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configBytes, err := msgpack.Marshal(config, schema.ImpliedType())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testReq := &tfprotov5.PlanResourceChangeRequest{
+		TypeName: "test",
+		PriorState: &tfprotov5.DynamicValue{
+			MsgPack: priorState,
+		},
+		ProposedNewState: &tfprotov5.DynamicValue{
+			MsgPack: proposedState,
+		},
+		Config: &tfprotov5.DynamicValue{
+			MsgPack: configBytes,
+		},
+	}
+
+	resp, err := server.PlanResourceChange(context.Background(), testReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plannedStateVal, err := msgpack.Unmarshal(resp.PlannedState.MsgPack, schema.ImpliedType())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !cmp.Equal(proposedVal, plannedStateVal, valueComparer) {
+		t.Fatal(cmp.Diff(proposedVal, plannedStateVal, valueComparer))
+	}
+
+	plannedStateFoo, acc := plannedStateVal.GetAttr("foo").AsBigFloat().Int64()
+	if acc != big.Exact {
+		t.Fatalf("Expected exact accuracy, got %s", acc)
+	}
+	if plannedStateFoo != 7227701560655103598 {
+		t.Fatalf("Expected %d, got %d, this represents a loss of precision in planning large numbers", 7227701560655103598, plannedStateFoo)
+	}
+}
+
+func TestReadResource_setRawState(t *testing.T) {
+	t.Parallel()
+
+	res := &Resource{
+		Schema: map[string]*Schema{
+			"name": {
+				Type:     TypeString,
+				Optional: true,
+			},
+			"secret": {
+				Type:      TypeString,
+				Optional:  true,
+				WriteOnly: true,
+			},
+			"network": {
+				Type:     TypeList,
+				Optional: true,
+				Elem: &Resource{
+					Schema: map[string]*Schema{
+						"name": {
+							Type:     TypeString,
+							Optional: true,
+						},
+						"subnet": {
+							Type:     TypeList,
+							Optional: true,
+							Elem: &Resource{
+								Schema: map[string]*Schema{
+									"cidr": {
+										Type:     TypeString,
+										Optional: true,
+									},
+									"tags": {
+										Type:     TypeMap,
+										Optional: true,
+										Elem:     &Schema{Type: TypeString},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	network := cty.ListVal([]cty.Value{
+		cty.ObjectVal(map[string]cty.Value{
+			"name": cty.StringVal("primary"),
+			"subnet": cty.ListVal([]cty.Value{
+				cty.ObjectVal(map[string]cty.Value{
+					"cidr": cty.StringVal("10.0.0.0/24"),
+					"tags": cty.MapVal(map[string]cty.Value{
+						"tier": cty.StringVal("web"),
+					}),
+				}),
+				cty.ObjectVal(map[string]cty.Value{
+					"cidr": cty.StringVal("10.0.1.0/24"),
+					"tags": cty.NullVal(cty.Map(cty.String)),
+				}),
+			}),
+		}),
+	})
+
+	res.ReadContext = func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+		err := d.SetRawState(cty.ObjectVal(map[string]cty.Value{
+			"name":    cty.StringVal("example"),
+			"secret":  cty.StringVal("hunter2"),
+			"network": network,
+		}))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		if got := d.Get("network.0.subnet.1.cidr"); got != "10.0.1.0/24" {
+			return diag.Errorf("unexpected network.0.subnet.1.cidr: %#v", got)
+		}
+
+		return nil
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": res,
+		},
+	})
+
+	ty := res.CoreConfigSchema().ImpliedType()
+
+	resp, err := server.ReadResource(context.Background(), &tfprotov5.ReadResourceRequest{
+		TypeName: "test",
+		CurrentState: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+				"id":      cty.StringVal("foo"),
+				"name":    cty.StringVal("old"),
+				"secret":  cty.NullVal(cty.String),
+				"network": cty.ListValEmpty(network.Type().ElementType()),
+			})),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	AssertNoDiagnostics(t, resp.Diagnostics)
+
+	got, err := msgpack.Unmarshal(resp.NewState.MsgPack, ty)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"id":      cty.StringVal("foo"),
+		"name":    cty.StringVal("example"),
+		"secret":  cty.NullVal(cty.String),
+		"network": network,
+	})
+
+	if !got.RawEquals(expected) {
+		t.Fatalf("unexpected new state\ngot:  %#v\nwant: %#v", got, expected)
+	}
+}
+
+func TestReadResource_getRawStateAt(t *testing.T) {
+	t.Parallel()
+
+	res := &Resource{
+		Schema: map[string]*Schema{
+			"network": {
+				Type:     TypeList,
+				Optional: true,
+				Elem: &Resource{
+					Schema: map[string]*Schema{
+						"name": {
+							Type:     TypeString,
+							Optional: true,
+						},
+						"cidr": {
+							Type:     TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	res.ReadContext = func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+		prior, err := d.GetRawStateAt(cty.GetAttrPath("network").IndexInt(1).GetAttr("cidr"))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		if !prior.RawEquals(cty.StringVal("10.0.1.0/24")) {
+			return diag.Errorf("unexpected prior network.1.cidr: %#v", prior)
+		}
+
+		// The remote object now reports a different CIDR, which is compared
+		// against the prior state value rather than the refreshed value.
+		if err := d.Set("network", []interface{}{
+			map[string]interface{}{
+				"name": "primary",
+				"cidr": "10.0.0.0/24",
+			},
+			map[string]interface{}{
+				"name": "secondary",
+				"cidr": "10.0.2.0/24",
+			},
+		}); err != nil {
+			return diag.FromErr(err)
+		}
+
+		prior, err = d.GetRawStateAt(cty.GetAttrPath("network").IndexInt(1).GetAttr("cidr"))
+		if err != nil {
+			return diag.FromErr(err)
+		}
+
+		if !prior.RawEquals(cty.StringVal("10.0.1.0/24")) {
+			return diag.Errorf("unexpected prior network.1.cidr after Set: %#v", prior)
+		}
+
+		if _, err := d.GetRawStateAt(cty.GetAttrPath("network").IndexInt(2).GetAttr("cidr")); err == nil {
+			return diag.Errorf("expected error for network.2.cidr")
+		}
+
+		return nil
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": res,
+		},
+	})
+
+	ty := res.CoreConfigSchema().ImpliedType()
+
+	resp, err := server.ReadResource(context.Background(), &tfprotov5.ReadResourceRequest{
+		TypeName: "test",
+		CurrentState: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+				"id": cty.StringVal("foo"),
+				"network": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"name": cty.StringVal("primary"),
+						"cidr": cty.StringVal("10.0.0.0/24"),
+					}),
+					cty.ObjectVal(map[string]cty.Value{
+						"name": cty.StringVal("secondary"),
+						"cidr": cty.StringVal("10.0.1.0/24"),
+					}),
+				}),
+			})),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	AssertNoDiagnostics(t, resp.Diagnostics)
+}
+
+func TestReadResource_exposeRawRequest(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		exposeRawRequest bool
+	}{
+		"disabled": {
+			exposeRawRequest: false,
+		},
+		"enabled": {
+			exposeRawRequest: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var rawReq interface{}
+			var rawReqOk bool
+
+			res := &Resource{
+				Schema: map[string]*Schema{
+					"name": {
+						Type:     TypeString,
+						Optional: true,
+					},
+				},
+				ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					rawReq, rawReqOk = RawRequestFromContext(ctx)
+
+					return nil
+				},
+			}
+
+			server := NewGRPCProviderServer(&Provider{
+				ExposeRawRequest: testCase.exposeRawRequest,
+				ResourcesMap: map[string]*Resource{
+					"test": res,
+				},
+			})
+
+			ty := res.CoreConfigSchema().ImpliedType()
+
+			req := &tfprotov5.ReadResourceRequest{
+				TypeName: "test",
+				CurrentState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+						"id":   cty.StringVal("foo"),
+						"name": cty.StringVal("bar"),
+					})),
+				},
+			}
+
+			resp, err := server.ReadResource(context.Background(), req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			AssertNoDiagnostics(t, resp.Diagnostics)
+
+			if rawReqOk != testCase.exposeRawRequest {
+				t.Fatalf("expected raw request availability %t, got %t", testCase.exposeRawRequest, rawReqOk)
+			}
+
+			if !testCase.exposeRawRequest {
+				return
+			}
+
+			got, ok := rawReq.(*tfprotov5.ReadResourceRequest)
+			if !ok {
+				t.Fatalf("expected *tfprotov5.ReadResourceRequest, got %T", rawReq)
+			}
+
+			if got != req {
+				t.Fatalf("expected the originating request, got %#v", got)
+			}
+		})
+	}
+}
+
+func TestPrepareProviderConfig_exposeRawRequest(t *testing.T) {
+	t.Parallel()
+
+	var rawReq interface{}
+	var rawReqOk bool
+
+	server := NewGRPCProviderServer(&Provider{
+		ExposeRawRequest: true,
+		Schema: map[string]*Schema{
+			"foo": {
+				Type:     TypeString,
+				Optional: true,
+				DefaultContextFunc: func(ctx context.Context) (interface{}, error) {
+					rawReq, rawReqOk = RawRequestFromContext(ctx)
+
+					return "default", nil
+				},
+			},
+		},
+	})
+
+	ty := server.getProviderSchemaBlock().ImpliedType()
+
+	req := &tfprotov5.PrepareProviderConfigRequest{
+		Config: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.NullVal(cty.String),
+			})),
+		},
+	}
+
+	resp, err := server.PrepareProviderConfig(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	AssertNoDiagnostics(t, resp.Diagnostics)
+
+	if !rawReqOk {
+		t.Fatal("expected raw request to be available")
+	}
+
+	if got, ok := rawReq.(*tfprotov5.PrepareProviderConfigRequest); !ok || got != req {
+		t.Fatalf("expected the originating request, got %#v", rawReq)
+	}
+}
+
+func TestReadResource_clientCapabilities(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		clientCapabilities *tfprotov5.ReadResourceClientCapabilities
+		expected           ClientCapabilities
+	}{
+		"nil": {
+			clientCapabilities: nil,
+			expected:           ClientCapabilities{},
+		},
+		"deferral not allowed": {
+			clientCapabilities: &tfprotov5.ReadResourceClientCapabilities{
+				DeferralAllowed: false,
+			},
+			expected: ClientCapabilities{},
+		},
+		"deferral allowed": {
+			clientCapabilities: &tfprotov5.ReadResourceClientCapabilities{
+				DeferralAllowed: true,
+			},
+			expected: ClientCapabilities{
+				DeferralAllowed: true,
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got ClientCapabilities
+
+			res := &Resource{
+				Schema: map[string]*Schema{
+					"name": {
+						Type:     TypeString,
+						Optional: true,
+					},
+				},
+				ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					got = ClientCapabilitiesFromContext(ctx)
+
+					return nil
+				},
+			}
+
+			server := NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": res,
+				},
+			})
+
+			ty := res.CoreConfigSchema().ImpliedType()
+
+			resp, err := server.ReadResource(context.Background(), &tfprotov5.ReadResourceRequest{
+				TypeName: "test",
+				CurrentState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+						"id":   cty.StringVal("foo"),
+						"name": cty.StringVal("bar"),
+					})),
+				},
+				ClientCapabilities: testCase.clientCapabilities,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			AssertNoDiagnostics(t, resp.Diagnostics)
+
+			if diff := cmp.Diff(got, testCase.expected); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestValidateResourceTypeConfig_clientCapabilities(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		clientCapabilities *tfprotov5.ValidateResourceTypeConfigClientCapabilities
+		expected           ClientCapabilities
+	}{
+		"nil": {
+			clientCapabilities: nil,
+			expected:           ClientCapabilities{},
+		},
+		"write-only attributes allowed": {
+			clientCapabilities: &tfprotov5.ValidateResourceTypeConfigClientCapabilities{
+				WriteOnlyAttributesAllowed: true,
+			},
+			expected: ClientCapabilities{
+				WriteOnlyAttributesAllowed: true,
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var got ClientCapabilities
+
+			res := &Resource{
+				Schema: map[string]*Schema{
+					"name": {
+						Type:     TypeString,
+						Optional: true,
+					},
+				},
+				ValidateRawResourceConfigFuncs: []ValidateRawResourceConfigFunc{
+					func(ctx context.Context, req ValidateResourceConfigFuncRequest, resp *ValidateResourceConfigFuncResponse) {
+						got = ClientCapabilitiesFromContext(ctx)
+					},
+				},
+			}
+
+			server := NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": res,
+				},
+			})
+
+			ty := res.CoreConfigSchema().ImpliedType()
+
+			resp, err := server.ValidateResourceTypeConfig(context.Background(), &tfprotov5.ValidateResourceTypeConfigRequest{
+				TypeName: "test",
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+						"id":   cty.NullVal(cty.String),
+						"name": cty.StringVal("bar"),
+					})),
+				},
+				ClientCapabilities: testCase.clientCapabilities,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			AssertNoDiagnostics(t, resp.Diagnostics)
+
+			if diff := cmp.Diff(got, testCase.expected); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
+func TestReadResource_terraformVersion(t *testing.T) {
+	t.Parallel()
+
+	var configureVersion, readVersion string
+
+	res := &Resource{
+		Schema: map[string]*Schema{
+			"name": {
+				Type:     TypeString,
+				Optional: true,
+			},
+		},
+		ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+			readVersion = TerraformVersionFromContext(ctx)
+
+			return nil
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ConfigureContextFunc: func(ctx context.Context, d *ResourceData) (interface{}, diag.Diagnostics) {
+			configureVersion = TerraformVersionFromContext(ctx)
+
+			return nil, nil
+		},
+		ResourcesMap: map[string]*Resource{
+			"test": res,
+		},
+	})
+
+	configureResp, err := server.ConfigureProvider(context.Background(), &tfprotov5.ConfigureProviderRequest{
+		TerraformVersion: "1.11.0",
+		Config: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(cty.EmptyObject, cty.EmptyObjectVal),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	AssertNoDiagnostics(t, configureResp.Diagnostics)
+
+	ty := res.CoreConfigSchema().ImpliedType()
+
+	readResp, err := server.ReadResource(context.Background(), &tfprotov5.ReadResourceRequest{
+		TypeName: "test",
+		CurrentState: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.StringVal("foo"),
+				"name": cty.StringVal("bar"),
+			})),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	AssertNoDiagnostics(t, readResp.Diagnostics)
+
+	if configureVersion != "1.11.0" {
+		t.Errorf("expected Terraform version 1.11.0 in ConfigureContextFunc, got: %q", configureVersion)
+	}
+
+	if readVersion != "1.11.0" {
+		t.Errorf("expected Terraform version 1.11.0 in ReadContext, got: %q", readVersion)
+	}
+}
+
+func TestReadResource_computedAttributeProviders(t *testing.T) {
+	t.Parallel()
+
+	ty := cty.Object(map[string]cty.Type{
+		"id":       cty.String,
+		"tags":     cty.Map(cty.String),
+		"tags_all": cty.Map(cty.String),
+	})
+
+	testCases := map[string]struct {
+		ComputedAttributeFunc ComputedAttributeFunc
+		ExpectedTagsAll       cty.Value
+		ExpectedDiags         []*tfprotov5.Diagnostic
+	}{
+		"merged": {
+			ComputedAttributeFunc: func(ctx context.Context, typeName string, d *ResourceData, meta interface{}) (interface{}, error) {
+				tags := map[string]interface{}{
+					"default":  "true",
+					"resource": typeName,
+				}
+				for k, v := range d.Get("tags").(map[string]interface{}) {
+					tags[k] = v
+				}
+				return tags, nil
+			},
+			ExpectedTagsAll: cty.MapVal(map[string]cty.Value{
+				"default":  cty.StringVal("true"),
+				"env":      cty.StringVal("prod"),
+				"resource": cty.StringVal("test"),
+			}),
+		},
+		"error": {
+			ComputedAttributeFunc: func(ctx context.Context, typeName string, d *ResourceData, meta interface{}) (interface{}, error) {
+				return nil, errors.New("no default tags")
+			},
+			ExpectedTagsAll: cty.MapVal(map[string]cty.Value{
+				"env": cty.StringVal("prod"),
+			}),
+			ExpectedDiags: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "Error computing attribute value",
+					Detail:    `The computed attribute provider for "tags_all" returned an error: no default tags`,
+					Attribute: tftypes.NewAttributePath().WithAttributeName("tags_all"),
+				},
+			},
+		},
+		"invalid type": {
+			ComputedAttributeFunc: func(ctx context.Context, typeName string, d *ResourceData, meta interface{}) (interface{}, error) {
+				return 42, nil
+			},
+			ExpectedTagsAll: cty.MapVal(map[string]cty.Value{
+				"env": cty.StringVal("prod"),
+			}),
+			ExpectedDiags: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "Invalid computed attribute value",
+					Detail:    `The computed attribute provider for "tags_all" returned a value which does not match the attribute type: tags_all: must be a map`,
+					Attribute: tftypes.NewAttributePath().WithAttributeName("tags_all"),
+				},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := NewGRPCProviderServer(&Provider{
+				ComputedAttributeProviders: map[string]ComputedAttributeFunc{
+					"tags_all": testCase.ComputedAttributeFunc,
+				},
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						Schema: map[string]*Schema{
+							"tags": {
+								Type:     TypeMap,
+								Optional: true,
+								Elem:     &Schema{Type: TypeString},
+							},
+							"tags_all": {
+								Type:     TypeMap,
+								Computed: true,
+								Elem:     &Schema{Type: TypeString},
+							},
+						},
+						ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+							// the resource's own value is overridden by the provider
+							return diag.FromErr(d.Set("tags_all", d.Get("tags")))
+						},
+					},
+				},
+			})
+
+			tags := cty.MapVal(map[string]cty.Value{
+				"env": cty.StringVal("prod"),
+			})
+
+			resp, err := server.ReadResource(context.Background(), &tfprotov5.ReadResourceRequest{
+				TypeName: "test",
+				CurrentState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+						"id":       cty.StringVal("foo"),
+						"tags":     tags,
+						"tags_all": cty.NullVal(cty.Map(cty.String)),
+					})),
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(testCase.ExpectedDiags, resp.Diagnostics); diff != "" {
+				t.Fatalf("unexpected diagnostics difference: %s", diff)
+			}
+
+			got, err := msgpack.Unmarshal(resp.NewState.MsgPack, ty)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expected := cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.StringVal("foo"),
+				"tags":     tags,
+				"tags_all": testCase.ExpectedTagsAll,
+			})
+
+			if !got.RawEquals(expected) {
+				t.Fatalf("unexpected new state\ngot:  %#v\nwant: %#v", got, expected)
+			}
+		})
+	}
+}
+
+func TestApplyResourceChange_computedAttributeProviders(t *testing.T) {
+	t.Parallel()
+
+	server := NewGRPCProviderServer(&Provider{
+		ComputedAttributeProviders: map[string]ComputedAttributeFunc{
+			"region": func(ctx context.Context, typeName string, d *ResourceData, meta interface{}) (interface{}, error) {
+				return "us-east-1", nil
+			},
+			// not called, the resource has no such attribute
+			"tags_all": func(ctx context.Context, typeName string, d *ResourceData, meta interface{}) (interface{}, error) {
+				return nil, errors.New("unexpected call")
+			},
+		},
+		ResourcesMap: map[string]*Resource{
+			"test": {
+				Schema: map[string]*Schema{
+					"name": {
+						Type:     TypeString,
+						Required: true,
+					},
+					"region": {
+						Type:     TypeString,
+						Computed: true,
+					},
+				},
+				CreateContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					d.SetId(d.Get("name").(string))
+					return nil
+				},
 			},
 		},
-		"basic-plan-EnableLegacyTypeSystemPlanErrors": {
+	})
+
+	ty := cty.Object(map[string]cty.Type{
+		"id":     cty.String,
+		"name":   cty.String,
+		"region": cty.String,
+	})
+
+	resp, err := server.ApplyResourceChange(context.Background(), &tfprotov5.ApplyResourceChangeRequest{
+		TypeName: "test",
+		PriorState: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, cty.NullVal(ty)),
+		},
+		PlannedState: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+				"id":     cty.UnknownVal(cty.String),
+				"name":   cty.StringVal("foo"),
+				"region": cty.UnknownVal(cty.String),
+			})),
+		},
+		Config: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, cty.ObjectVal(map[string]cty.Value{
+				"id":     cty.NullVal(cty.String),
+				"name":   cty.StringVal("foo"),
+				"region": cty.NullVal(cty.String),
+			})),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	AssertNoDiagnostics(t, resp.Diagnostics)
+
+	got, err := msgpack.Unmarshal(resp.NewState.MsgPack, ty)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"id":     cty.StringVal("foo"),
+		"name":   cty.StringVal("foo"),
+		"region": cty.StringVal("us-east-1"),
+	})
+
+	if !got.RawEquals(expected) {
+		t.Fatalf("unexpected new state\ngot:  %#v\nwant: %#v", got, expected)
+	}
+}
+
+func TestPlanResourceChange_computedAttributeProviders(t *testing.T) {
+	t.Parallel()
+
+	ty := cty.Object(map[string]cty.Type{
+		"id":       cty.String,
+		"tags":     cty.Map(cty.String),
+		"tags_all": cty.Map(cty.String),
+	})
+
+	server := NewGRPCProviderServer(&Provider{
+		ComputedAttributeProviders: map[string]ComputedAttributeFunc{
+			"tags_all": func(ctx context.Context, typeName string, d *ResourceData, meta interface{}) (interface{}, error) {
+				tags := map[string]interface{}{
+					"default": "true",
+				}
+				for k, v := range d.Get("tags").(map[string]interface{}) {
+					tags[k] = v
+				}
+				return tags, nil
+			},
+		},
+		ResourcesMap: map[string]*Resource{
+			"test": {
+				Schema: map[string]*Schema{
+					"tags": {
+						Type:     TypeMap,
+						Optional: true,
+						Elem:     &Schema{Type: TypeString},
+					},
+					"tags_all": {
+						Type:     TypeMap,
+						Computed: true,
+						Elem:     &Schema{Type: TypeString},
+					},
+				},
+				CreateContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					d.SetId("foo")
+					return nil
+				},
+				ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					return nil
+				},
+				UpdateContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					return nil
+				},
+			},
+		},
+	})
+
+	testCases := map[string]struct {
+		PriorState      cty.Value
+		Config          cty.Value
+		ExpectedTagsAll cty.Value
+		ExpectedNoOp    bool
+	}{
+		"create": {
+			PriorState: cty.NullVal(ty),
+			Config: cty.ObjectVal(map[string]cty.Value{
+				"id": cty.NullVal(cty.String),
+				"tags": cty.MapVal(map[string]cty.Value{
+					"env": cty.StringVal("prod"),
+				}),
+				"tags_all": cty.NullVal(cty.Map(cty.String)),
+			}),
+			ExpectedTagsAll: cty.UnknownVal(cty.Map(cty.String)),
+		},
+		"update unchanged value": {
+			PriorState: cty.ObjectVal(map[string]cty.Value{
+				"id": cty.StringVal("foo"),
+				"tags": cty.MapVal(map[string]cty.Value{
+					"env": cty.StringVal("prod"),
+				}),
+				"tags_all": cty.MapVal(map[string]cty.Value{
+					"default": cty.StringVal("true"),
+					"env":     cty.StringVal("prod"),
+				}),
+			}),
+			Config: cty.ObjectVal(map[string]cty.Value{
+				"id": cty.NullVal(cty.String),
+				"tags": cty.MapVal(map[string]cty.Value{
+					"env": cty.StringVal("prod"),
+				}),
+				"tags_all": cty.NullVal(cty.Map(cty.String)),
+			}),
+			ExpectedTagsAll: cty.MapVal(map[string]cty.Value{
+				"default": cty.StringVal("true"),
+				"env":     cty.StringVal("prod"),
+			}),
+			ExpectedNoOp: true,
+		},
+		"update changed value": {
+			PriorState: cty.ObjectVal(map[string]cty.Value{
+				"id": cty.StringVal("foo"),
+				"tags": cty.MapVal(map[string]cty.Value{
+					"env": cty.StringVal("prod"),
+				}),
+				"tags_all": cty.MapVal(map[string]cty.Value{
+					"default": cty.StringVal("true"),
+					"env":     cty.StringVal("prod"),
+				}),
+			}),
+			Config: cty.ObjectVal(map[string]cty.Value{
+				"id": cty.NullVal(cty.String),
+				"tags": cty.MapVal(map[string]cty.Value{
+					"env": cty.StringVal("dev"),
+				}),
+				"tags_all": cty.NullVal(cty.Map(cty.String)),
+			}),
+			ExpectedTagsAll: cty.UnknownVal(cty.Map(cty.String)),
+		},
+		"update stale prior value": {
+			PriorState: cty.ObjectVal(map[string]cty.Value{
+				"id": cty.StringVal("foo"),
+				"tags": cty.MapVal(map[string]cty.Value{
+					"env": cty.StringVal("prod"),
+				}),
+				"tags_all": cty.MapVal(map[string]cty.Value{
+					"env": cty.StringVal("prod"),
+				}),
+			}),
+			Config: cty.ObjectVal(map[string]cty.Value{
+				"id": cty.NullVal(cty.String),
+				"tags": cty.MapVal(map[string]cty.Value{
+					"env": cty.StringVal("prod"),
+				}),
+				"tags_all": cty.NullVal(cty.Map(cty.String)),
+			}),
+			ExpectedTagsAll: cty.UnknownVal(cty.Map(cty.String)),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			proposedVal := testCase.Config
+			if !testCase.PriorState.IsNull() {
+				proposedVal = cty.ObjectVal(map[string]cty.Value{
+					"id":       testCase.PriorState.GetAttr("id"),
+					"tags":     testCase.Config.GetAttr("tags"),
+					"tags_all": testCase.PriorState.GetAttr("tags_all"),
+				})
+			}
+
+			planResp, err := server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, testCase.PriorState),
+				},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, proposedVal),
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, testCase.Config),
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			AssertNoDiagnostics(t, planResp.Diagnostics)
+
+			plannedVal, err := msgpack.Unmarshal(planResp.PlannedState.MsgPack, ty)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := plannedVal.GetAttr("tags_all"); !got.RawEquals(testCase.ExpectedTagsAll) {
+				t.Fatalf("unexpected planned tags_all\ngot:  %#v\nwant: %#v", got, testCase.ExpectedTagsAll)
+			}
+
+			if testCase.ExpectedNoOp {
+				if !plannedVal.RawEquals(testCase.PriorState) {
+					t.Fatalf("expected no changes\ngot:  %#v\nwant: %#v", plannedVal, testCase.PriorState)
+				}
+				return
+			}
+
+			applyResp, err := server.ApplyResourceChange(context.Background(), &tfprotov5.ApplyResourceChangeRequest{
+				TypeName:       "test",
+				PriorState:     &tfprotov5.DynamicValue{MsgPack: mustMsgpackMarshal(ty, testCase.PriorState)},
+				PlannedState:   planResp.PlannedState,
+				PlannedPrivate: planResp.PlannedPrivate,
+				Config:         &tfprotov5.DynamicValue{MsgPack: mustMsgpackMarshal(ty, testCase.Config)},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			AssertNoDiagnostics(t, applyResp.Diagnostics)
+
+			newVal, err := msgpack.Unmarshal(applyResp.NewState.MsgPack, ty)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Terraform requires known planned values to be unchanged after
+			// apply.
+			for attr, planned := range plannedVal.AsValueMap() {
+				if planned.IsWhollyKnown() && !planned.RawEquals(newVal.GetAttr(attr)) {
+					t.Errorf("inconsistent result after apply for %s\nplanned: %#v\nnew:     %#v", attr, planned, newVal.GetAttr(attr))
+				}
+			}
+
+			expectedTagsAll := cty.MapVal(map[string]cty.Value{
+				"default": cty.StringVal("true"),
+				"env":     testCase.Config.GetAttr("tags").Index(cty.StringVal("env")),
+			})
+
+			if got := newVal.GetAttr("tags_all"); !got.RawEquals(expectedTagsAll) {
+				t.Fatalf("unexpected new tags_all\ngot:  %#v\nwant: %#v", got, expectedTagsAll)
+			}
+		})
+	}
+}
+
+func TestApplyResourceChange(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		server   *GRPCProviderServer
+		req      *tfprotov5.ApplyResourceChangeRequest
+		expected *tfprotov5.ApplyResourceChangeResponse
+	}{
+		"create: write-only values are nullified in ApplyResourceChangeResponse": {
 			server: NewGRPCProviderServer(&Provider{
 				ResourcesMap: map[string]*Resource{
 					"test": {
-						// Will set UnsafeToUseLegacyTypeSystem to false
-						EnableLegacyTypeSystemPlanErrors: true,
+						SchemaVersion: 4,
+						CreateContext: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
+							rd.SetId("baz")
+							return nil
+						},
 						Schema: map[string]*Schema{
 							"foo": {
-								Type:     TypeInt,
-								Optional: true,
+								Type:      TypeString,
+								Optional:  true,
+								WriteOnly: true,
+							},
+							"bar": {
+								Type:      TypeString,
+								Optional:  true,
+								WriteOnly: true,
 							},
 						},
 					},
 				},
 			}),
-			req: &tfprotov5.PlanResourceChangeRequest{
+			req: &tfprotov5.ApplyResourceChangeRequest{
 				TypeName: "test",
 				PriorState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"foo": cty.Number,
+							"foo": cty.String,
+							"bar": cty.String,
 						}),
 						cty.NullVal(
 							cty.Object(map[string]cty.Type{
-								"foo": cty.Number,
+								"foo": cty.String,
+								"bar": cty.String,
 							}),
 						),
 					),
 				},
-				ProposedNewState: &tfprotov5.DynamicValue{
+				PlannedState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
 							"id":  cty.String,
-							"foo": cty.Number,
+							"foo": cty.String,
+							"bar": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
 							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.NullVal(cty.Number),
+							"foo": cty.StringVal("baz"),
+							"bar": cty.StringVal("boop"),
 						}),
 					),
 				},
@@ -5960,166 +12361,178 @@ func TestPlanResourceChange(t *testing.T) {
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
 							"id":  cty.String,
-							"foo": cty.Number,
+							"foo": cty.String,
+							"bar": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
 							"id":  cty.NullVal(cty.String),
-							"foo": cty.NullVal(cty.Number),
+							"foo": cty.StringVal("baz"),
+							"bar": cty.StringVal("boop"),
 						}),
 					),
 				},
 			},
-			expected: &tfprotov5.PlanResourceChangeResponse{
-				PlannedState: &tfprotov5.DynamicValue{
+			expected: &tfprotov5.ApplyResourceChangeResponse{
+				NewState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
 							"id":  cty.String,
-							"foo": cty.Number,
+							"foo": cty.String,
+							"bar": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.NullVal(cty.Number),
+							"id":  cty.StringVal("baz"),
+							"foo": cty.NullVal(cty.String),
+							"bar": cty.NullVal(cty.String),
 						}),
 					),
 				},
-				RequiresReplace: []*tftypes.AttributePath{
-					tftypes.NewAttributePath().WithAttributeName("id"),
-				},
-				PlannedPrivate:              []byte(`{"_new_extra_shim":{}}`),
-				UnsafeToUseLegacyTypeSystem: false,
+				Private:                     []uint8(`{"schema_version":"4"}`),
+				UnsafeToUseLegacyTypeSystem: true,
 			},
 		},
-		"deferred-with-provider-plan-modification": {
+		"update: write-only values are nullified in ApplyResourceChangeResponse": {
 			server: NewGRPCProviderServer(&Provider{
-				providerDeferred: &Deferred{
-					Reason: DeferredReasonProviderConfigUnknown,
-				},
 				ResourcesMap: map[string]*Resource{
 					"test": {
-						ResourceBehavior: ResourceBehavior{
-							ProviderDeferred: ProviderDeferredBehavior{
-								// Will ensure that CustomizeDiff is called
-								EnablePlanModification: true,
-							},
-						},
 						SchemaVersion: 4,
-						CustomizeDiff: func(ctx context.Context, d *ResourceDiff, i interface{}) error {
-							return d.SetNew("foo", "new-foo-value")
+						CreateContext: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
+							rd.SetId("baz")
+							s := rd.Get("configured").(string)
+							err := rd.Set("configured", s)
+							if err != nil {
+								return nil
+							}
+							return nil
 						},
 						Schema: map[string]*Schema{
-							"foo": {
+							"configured": {
 								Type:     TypeString,
 								Optional: true,
-								Computed: true,
+							},
+							"write_onlyA": {
+								Type:      TypeString,
+								Optional:  true,
+								WriteOnly: true,
+							},
+							"write_onlyB": {
+								Type:      TypeString,
+								Optional:  true,
+								WriteOnly: true,
 							},
 						},
 					},
 				},
 			}),
-			req: &tfprotov5.PlanResourceChangeRequest{
+			req: &tfprotov5.ApplyResourceChangeRequest{
 				TypeName: "test",
-				ClientCapabilities: &tfprotov5.PlanResourceChangeClientCapabilities{
-					DeferralAllowed: true,
-				},
 				PriorState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"foo": cty.String,
+							"id":          cty.String,
+							"configured":  cty.String,
+							"write_onlyA": cty.String,
+							"write_onlyB": cty.String,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":          cty.NullVal(cty.String),
+							"configured":  cty.StringVal("prior_val"),
+							"write_onlyA": cty.NullVal(cty.String),
+							"write_onlyB": cty.NullVal(cty.String),
 						}),
-						cty.NullVal(
-							cty.Object(map[string]cty.Type{
-								"foo": cty.String,
-							}),
-						),
 					),
 				},
-				ProposedNewState: &tfprotov5.DynamicValue{
+				PlannedState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.String,
+							"id":          cty.String,
+							"configured":  cty.String,
+							"write_onlyA": cty.String,
+							"write_onlyB": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.UnknownVal(cty.String),
+							"id":          cty.UnknownVal(cty.String),
+							"configured":  cty.StringVal("updated_val"),
+							"write_onlyA": cty.StringVal("foo"),
+							"write_onlyB": cty.StringVal("bar"),
 						}),
 					),
 				},
 				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.String,
+							"id":          cty.String,
+							"configured":  cty.String,
+							"write_onlyA": cty.String,
+							"write_onlyB": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.NullVal(cty.String),
-							"foo": cty.NullVal(cty.String),
+							"id":          cty.NullVal(cty.String),
+							"configured":  cty.StringVal("updated_val"),
+							"write_onlyA": cty.StringVal("foo"),
+							"write_onlyB": cty.StringVal("bar"),
 						}),
 					),
 				},
 			},
-			expected: &tfprotov5.PlanResourceChangeResponse{
-				Deferred: &tfprotov5.Deferred{
-					Reason: tfprotov5.DeferredReasonProviderConfigUnknown,
-				},
-				PlannedState: &tfprotov5.DynamicValue{
+			expected: &tfprotov5.ApplyResourceChangeResponse{
+				NewState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.String,
+							"id":          cty.String,
+							"configured":  cty.String,
+							"write_onlyA": cty.String,
+							"write_onlyB": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.StringVal("new-foo-value"),
+							"id":          cty.StringVal("baz"),
+							"configured":  cty.StringVal("updated_val"),
+							"write_onlyA": cty.NullVal(cty.String),
+							"write_onlyB": cty.NullVal(cty.String),
 						}),
 					),
 				},
-				RequiresReplace: []*tftypes.AttributePath{
-					tftypes.NewAttributePath().WithAttributeName("id"),
-				},
-				PlannedPrivate:              []byte(`{"_new_extra_shim":{}}`),
+				Private:                     []uint8(`{"schema_version":"4"}`),
 				UnsafeToUseLegacyTypeSystem: true,
 			},
 		},
-		"deferred-skip-plan-modification": {
+		"create: write-only values return error with ErrorOnWriteOnlyInState": {
 			server: NewGRPCProviderServer(&Provider{
-				providerDeferred: &Deferred{
-					Reason: DeferredReasonProviderConfigUnknown,
-				},
 				ResourcesMap: map[string]*Resource{
 					"test": {
-						SchemaVersion: 4,
-						CustomizeDiff: func(ctx context.Context, d *ResourceDiff, i interface{}) error {
-							return errors.New("Test assertion failed: CustomizeDiff shouldn't be called")
+						SchemaVersion:           4,
+						ErrorOnWriteOnlyInState: true,
+						CreateContext: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
+							rd.SetId("baz")
+							return diag.FromErr(rd.Set("foo", "leaked"))
 						},
 						Schema: map[string]*Schema{
 							"foo": {
-								Type:     TypeString,
-								Optional: true,
-								Computed: true,
+								Type:      TypeString,
+								Optional:  true,
+								WriteOnly: true,
 							},
 						},
 					},
 				},
 			}),
-			req: &tfprotov5.PlanResourceChangeRequest{
+			req: &tfprotov5.ApplyResourceChangeRequest{
 				TypeName: "test",
-				ClientCapabilities: &tfprotov5.PlanResourceChangeClientCapabilities{
-					DeferralAllowed: true,
-				},
 				PriorState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
+							"id":  cty.String,
 							"foo": cty.String,
 						}),
 						cty.NullVal(
 							cty.Object(map[string]cty.Type{
+								"id":  cty.String,
 								"foo": cty.String,
 							}),
 						),
 					),
 				},
-				ProposedNewState: &tfprotov5.DynamicValue{
+				PlannedState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
 							"id":  cty.String,
@@ -6127,7 +12540,7 @@ func TestPlanResourceChange(t *testing.T) {
 						}),
 						cty.ObjectVal(map[string]cty.Value{
 							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.StringVal("from-config!"),
+							"foo": cty.NullVal(cty.String),
 						}),
 					),
 				},
@@ -6139,391 +12552,387 @@ func TestPlanResourceChange(t *testing.T) {
 						}),
 						cty.ObjectVal(map[string]cty.Value{
 							"id":  cty.NullVal(cty.String),
-							"foo": cty.StringVal("from-config!"),
+							"foo": cty.StringVal("baz"),
 						}),
 					),
 				},
 			},
-			expected: &tfprotov5.PlanResourceChangeResponse{
-				Deferred: &tfprotov5.Deferred{
-					Reason: tfprotov5.DeferredReasonProviderConfigUnknown,
+			expected: &tfprotov5.ApplyResourceChangeResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "Write-only Attribute Set in State",
+						Detail: "The resource state contains a non-null value for write-only attribute \"foo\". " +
+							"Write-only values are never persisted. This is always an issue in the provider and should be reported to the provider developers.",
+						Attribute: tftypes.NewAttributePath().WithAttributeName("foo"),
+					},
 				},
-				// Returns proposed new state with deferred response
-				PlannedState: &tfprotov5.DynamicValue{
+				NewState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
 							"id":  cty.String,
 							"foo": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.StringVal("from-config!"),
+							"id":  cty.StringVal("baz"),
+							"foo": cty.NullVal(cty.String),
 						}),
 					),
 				},
+				Private:                     []uint8(`{"schema_version":"4"}`),
 				UnsafeToUseLegacyTypeSystem: true,
 			},
 		},
-		"create: write-only value can be retrieved in CustomizeDiff": {
+		"create: identity returned in ApplyResourceChangeResponse": {
 			server: NewGRPCProviderServer(&Provider{
 				ResourcesMap: map[string]*Resource{
 					"test": {
 						SchemaVersion: 4,
-						CustomizeDiff: func(ctx context.Context, d *ResourceDiff, i interface{}) error {
-							val := d.Get("foo")
-							if val != "bar" {
-								t.Fatalf("Incorrect write-only value")
+						CreateContext: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
+							rd.SetId("baz")
+							identity, err := rd.Identity()
+							if err != nil {
+								t.Fatal(err)
+							}
+							err = identity.Set("ident", "bazz")
+							if err != nil {
+								t.Fatal(err)
 							}
-
 							return nil
 						},
-						Schema: map[string]*Schema{
-							"foo": {
-								Type:      TypeString,
-								Optional:  true,
-								WriteOnly: true,
+						Schema: map[string]*Schema{},
+						Identity: &ResourceIdentity{
+							Version: 1,
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{
+									"ident": {
+										Type:              TypeString,
+										RequiredForImport: true,
+									},
+								}
 							},
 						},
 					},
 				},
 			}),
-			req: &tfprotov5.PlanResourceChangeRequest{
+			req: &tfprotov5.ApplyResourceChangeRequest{
 				TypeName: "test",
 				PriorState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"foo": cty.String,
-						}),
+						cty.Object(map[string]cty.Type{}),
 						cty.NullVal(
-							cty.Object(map[string]cty.Type{
-								"foo": cty.String,
-							}),
+							cty.Object(map[string]cty.Type{}),
 						),
 					),
 				},
-				ProposedNewState: &tfprotov5.DynamicValue{
+				PlannedState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.String,
+							"id": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.StringVal("bar"),
+							"id": cty.UnknownVal(cty.String),
 						}),
 					),
 				},
+				PlannedIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"ident": cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"ident": cty.UnknownVal(cty.String),
+							}),
+						),
+					},
+				},
 				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.String,
+							"id": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.NullVal(cty.String),
-							"foo": cty.StringVal("bar"),
+							"id": cty.NullVal(cty.String),
 						}),
 					),
 				},
 			},
-			expected: &tfprotov5.PlanResourceChangeResponse{
-				PlannedState: &tfprotov5.DynamicValue{
+			expected: &tfprotov5.ApplyResourceChangeResponse{
+				NewState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.String,
+							"id": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.NullVal(cty.String),
+							"id": cty.StringVal("baz"),
 						}),
 					),
 				},
-				PlannedPrivate: []byte(`{"_new_extra_shim":{}}`),
-				RequiresReplace: []*tftypes.AttributePath{
-					tftypes.NewAttributePath().WithAttributeName("id"),
-				},
+				Private:                     []uint8(`{"schema_version":"4"}`),
 				UnsafeToUseLegacyTypeSystem: true,
+				NewIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"ident": cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"ident": cty.StringVal("bazz"),
+							}),
+						),
+					},
+				},
 			},
 		},
-		"create: write-only values are nullified in PlanResourceChangeResponse": {
+		"create: identity SetRaw returned in ApplyResourceChangeResponse": {
 			server: NewGRPCProviderServer(&Provider{
 				ResourcesMap: map[string]*Resource{
 					"test": {
 						SchemaVersion: 4,
-						Schema: map[string]*Schema{
-							"foo": {
-								Type:      TypeString,
-								Optional:  true,
-								WriteOnly: true,
-							},
-							"bar": {
-								Type:      TypeString,
-								Optional:  true,
-								WriteOnly: true,
+						CreateContext: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
+							rd.SetId("baz")
+							identity, err := rd.Identity()
+							if err != nil {
+								return diag.FromErr(err)
+							}
+							err = identity.SetRaw(cty.ObjectVal(map[string]cty.Value{
+								"ident":  cty.StringVal("bazz"),
+								"region": cty.StringVal("us-east-1"),
+							}))
+							if err != nil {
+								return diag.FromErr(err)
+							}
+							return nil
+						},
+						Schema: map[string]*Schema{},
+						Identity: &ResourceIdentity{
+							Version: 1,
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{
+									"ident": {
+										Type:              TypeString,
+										RequiredForImport: true,
+									},
+									"region": {
+										Type:              TypeString,
+										OptionalForImport: true,
+									},
+								}
 							},
 						},
 					},
 				},
 			}),
-			req: &tfprotov5.PlanResourceChangeRequest{
+			req: &tfprotov5.ApplyResourceChangeRequest{
 				TypeName: "test",
 				PriorState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"foo": cty.String,
-							"bar": cty.String,
-						}),
+						cty.Object(map[string]cty.Type{}),
 						cty.NullVal(
-							cty.Object(map[string]cty.Type{
-								"foo": cty.String,
-								"bar": cty.String,
-							}),
+							cty.Object(map[string]cty.Type{}),
 						),
 					),
 				},
-				ProposedNewState: &tfprotov5.DynamicValue{
+				PlannedState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.String,
-							"bar": cty.String,
+							"id": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.StringVal("baz"),
-							"bar": cty.StringVal("boop"),
+							"id": cty.UnknownVal(cty.String),
 						}),
 					),
 				},
+				PlannedIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"ident":  cty.String,
+								"region": cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"ident":  cty.UnknownVal(cty.String),
+								"region": cty.UnknownVal(cty.String),
+							}),
+						),
+					},
+				},
 				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.String,
-							"bar": cty.String,
+							"id": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.NullVal(cty.String),
-							"foo": cty.StringVal("baz"),
-							"bar": cty.StringVal("boop"),
+							"id": cty.NullVal(cty.String),
 						}),
 					),
 				},
 			},
-			expected: &tfprotov5.PlanResourceChangeResponse{
-				PlannedState: &tfprotov5.DynamicValue{
+			expected: &tfprotov5.ApplyResourceChangeResponse{
+				NewState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.String,
-							"bar": cty.String,
+							"id": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.NullVal(cty.String),
-							"bar": cty.NullVal(cty.String),
+							"id": cty.StringVal("baz"),
 						}),
 					),
 				},
-				PlannedPrivate: []byte(`{"_new_extra_shim":{}}`),
-				RequiresReplace: []*tftypes.AttributePath{
-					tftypes.NewAttributePath().WithAttributeName("id"),
-				},
+				Private:                     []uint8(`{"schema_version":"4"}`),
 				UnsafeToUseLegacyTypeSystem: true,
+				NewIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"ident":  cty.String,
+								"region": cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"ident":  cty.StringVal("bazz"),
+								"region": cty.StringVal("us-east-1"),
+							}),
+						),
+					},
+				},
 			},
 		},
-		"update: write-only value can be retrieved in CustomizeDiff": {
+		"create: no identity schema diag in ApplyResourceChangeResponse": {
 			server: NewGRPCProviderServer(&Provider{
 				ResourcesMap: map[string]*Resource{
 					"test": {
 						SchemaVersion: 4,
-						CustomizeDiff: func(ctx context.Context, d *ResourceDiff, i interface{}) error {
-							val := d.Get("write_only")
-							if val != "bar" {
-								t.Fatalf("Incorrect write-only value")
-							}
-
-							return nil
-						},
-						Schema: map[string]*Schema{
-							"configured": {
-								Type:     TypeString,
-								Optional: true,
-							},
-							"write_only": {
-								Type:      TypeString,
-								Optional:  true,
-								WriteOnly: true,
-							},
+						Schema:        map[string]*Schema{},
+						Identity: &ResourceIdentity{
+							Version: 1,
 						},
 					},
 				},
 			}),
-			req: &tfprotov5.PlanResourceChangeRequest{
+			req: &tfprotov5.ApplyResourceChangeRequest{
 				TypeName: "test",
 				PriorState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":         cty.String,
-							"configured": cty.String,
-							"write_only": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":         cty.NullVal(cty.String),
-							"configured": cty.StringVal("prior_val"),
-							"write_only": cty.NullVal(cty.String),
-						}),
+						cty.Object(map[string]cty.Type{}),
+						cty.NullVal(
+							cty.Object(map[string]cty.Type{}),
+						),
 					),
 				},
-				ProposedNewState: &tfprotov5.DynamicValue{
+				PlannedState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":         cty.String,
-							"configured": cty.String,
-							"write_only": cty.String,
+							"id": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":         cty.UnknownVal(cty.String),
-							"configured": cty.StringVal("updated_val"),
-							"write_only": cty.StringVal("bar"),
+							"id": cty.UnknownVal(cty.String),
 						}),
 					),
 				},
+				PlannedIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"ident": cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"ident": cty.UnknownVal(cty.String),
+							}),
+						),
+					},
+				},
 				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":         cty.String,
-							"configured": cty.String,
-							"write_only": cty.String,
+							"id": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":         cty.NullVal(cty.String),
-							"configured": cty.StringVal("updated_val"),
-							"write_only": cty.StringVal("bar"),
+							"id": cty.NullVal(cty.String),
 						}),
 					),
 				},
 			},
-			expected: &tfprotov5.PlanResourceChangeResponse{
-				PlannedState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":         cty.String,
-							"configured": cty.String,
-							"write_only": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":         cty.UnknownVal(cty.String),
-							"configured": cty.StringVal("updated_val"),
-							"write_only": cty.NullVal(cty.String),
-						}),
-					),
+			expected: &tfprotov5.ApplyResourceChangeResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "getting identity schema failed for resource 'test': resource does not have an identity schema",
+					},
 				},
-				PlannedPrivate: []byte(`{"_new_extra_shim":{}}`),
-				RequiresReplace: []*tftypes.AttributePath{
-					tftypes.NewAttributePath().WithAttributeName("id"),
+				NewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(cty.DynamicPseudoType, cty.NullVal(cty.DynamicPseudoType)),
 				},
-				UnsafeToUseLegacyTypeSystem: true,
 			},
 		},
-		"update: write-only values are nullified in PlanResourceChangeResponse": {
+		"create: empty identity schema diag in ApplyResourceChangeResponse": {
 			server: NewGRPCProviderServer(&Provider{
 				ResourcesMap: map[string]*Resource{
 					"test": {
 						SchemaVersion: 4,
-						Schema: map[string]*Schema{
-							"configured": {
-								Type:     TypeString,
-								Optional: true,
-							},
-							"write_onlyA": {
-								Type:      TypeString,
-								Optional:  true,
-								WriteOnly: true,
-							},
-							"write_onlyB": {
-								Type:      TypeString,
-								Optional:  true,
-								WriteOnly: true,
+						Schema:        map[string]*Schema{},
+						Identity: &ResourceIdentity{
+							Version: 1,
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{}
 							},
 						},
 					},
 				},
 			}),
-			req: &tfprotov5.PlanResourceChangeRequest{
+			req: &tfprotov5.ApplyResourceChangeRequest{
 				TypeName: "test",
 				PriorState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":          cty.String,
-							"configured":  cty.String,
-							"write_onlyA": cty.String,
-							"write_onlyB": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":          cty.NullVal(cty.String),
-							"configured":  cty.StringVal("prior_val"),
-							"write_onlyA": cty.NullVal(cty.String),
-							"write_onlyB": cty.NullVal(cty.String),
-						}),
+						cty.Object(map[string]cty.Type{}),
+						cty.NullVal(
+							cty.Object(map[string]cty.Type{}),
+						),
 					),
 				},
-				ProposedNewState: &tfprotov5.DynamicValue{
+				PlannedState: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":          cty.String,
-							"configured":  cty.String,
-							"write_onlyA": cty.String,
-							"write_onlyB": cty.String,
+							"id": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":          cty.UnknownVal(cty.String),
-							"configured":  cty.StringVal("updated_val"),
-							"write_onlyA": cty.StringVal("foo"),
-							"write_onlyB": cty.StringVal("bar"),
+							"id": cty.UnknownVal(cty.String),
 						}),
 					),
 				},
+				PlannedIdentity: &tfprotov5.ResourceIdentityData{
+					IdentityData: &tfprotov5.DynamicValue{
+						MsgPack: mustMsgpackMarshal(
+							cty.Object(map[string]cty.Type{
+								"ident": cty.String,
+							}),
+							cty.ObjectVal(map[string]cty.Value{
+								"ident": cty.UnknownVal(cty.String),
+							}),
+						),
+					},
+				},
 				Config: &tfprotov5.DynamicValue{
 					MsgPack: mustMsgpackMarshal(
 						cty.Object(map[string]cty.Type{
-							"id":          cty.String,
-							"configured":  cty.String,
-							"write_onlyA": cty.String,
-							"write_onlyB": cty.String,
+							"id": cty.String,
 						}),
 						cty.ObjectVal(map[string]cty.Value{
-							"id":          cty.NullVal(cty.String),
-							"configured":  cty.StringVal("updated_val"),
-							"write_onlyA": cty.StringVal("foo"),
-							"write_onlyB": cty.StringVal("bar"),
+							"id": cty.NullVal(cty.String),
 						}),
 					),
 				},
 			},
-			expected: &tfprotov5.PlanResourceChangeResponse{
-				PlannedState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":          cty.String,
-							"configured":  cty.String,
-							"write_onlyA": cty.String,
-							"write_onlyB": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":          cty.UnknownVal(cty.String),
-							"configured":  cty.StringVal("updated_val"),
-							"write_onlyA": cty.NullVal(cty.String),
-							"write_onlyB": cty.NullVal(cty.String),
-						}),
-					),
+			expected: &tfprotov5.ApplyResourceChangeResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "getting identity schema failed for resource 'test': identity schema must have at least one attribute",
+					},
 				},
-				PlannedPrivate: []byte(`{"_new_extra_shim":{}}`),
-				RequiresReplace: []*tftypes.AttributePath{
-					tftypes.NewAttributePath().WithAttributeName("id"),
+				NewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(cty.DynamicPseudoType, cty.NullVal(cty.DynamicPseudoType)),
 				},
-				UnsafeToUseLegacyTypeSystem: true,
 			},
 		},
 	}
@@ -6532,7 +12941,7 @@ func TestPlanResourceChange(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			resp, err := testCase.server.PlanResourceChange(context.Background(), testCase.req)
+			resp, err := testCase.server.ApplyResourceChange(context.Background(), testCase.req)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -6540,12 +12949,12 @@ func TestPlanResourceChange(t *testing.T) {
 			if diff := cmp.Diff(resp, testCase.expected, valueComparer); diff != "" {
 				ty := testCase.server.getResourceSchemaBlock("test").ImpliedType()
 
-				if resp != nil && resp.PlannedState != nil {
-					t.Logf("resp.PlannedState.MsgPack: %s", mustMsgpackUnmarshal(ty, resp.PlannedState.MsgPack))
+				if resp != nil && resp.NewState != nil {
+					t.Logf("resp.NewState.MsgPack: %s", mustMsgpackUnmarshal(ty, resp.NewState.MsgPack))
 				}
 
-				if testCase.expected != nil && testCase.expected.PlannedState != nil {
-					t.Logf("expected: %s", mustMsgpackUnmarshal(ty, testCase.expected.PlannedState.MsgPack))
+				if testCase.expected != nil && testCase.expected.NewState != nil {
+					t.Logf("expected: %s", mustMsgpackUnmarshal(ty, testCase.expected.NewState.MsgPack))
 				}
 
 				t.Error(diff)
@@ -6554,666 +12963,971 @@ func TestPlanResourceChange(t *testing.T) {
 	}
 }
 
-func TestPlanResourceChange_bigint(t *testing.T) {
-	r := &Resource{
-		UseJSONNumber: true,
-		Schema: map[string]*Schema{
-			"foo": {
-				Type:     TypeInt,
-				Required: true,
+func TestApplyResourceChange_ResourceFuncs(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		TestResource                   *Resource
+		ExpectedUnsafeLegacyTypeSystem bool
+	}{
+		"Create": {
+			TestResource: &Resource{
+				SchemaVersion: 4,
+				Schema: map[string]*Schema{
+					"foo": {
+						Type:     TypeInt,
+						Optional: true,
+					},
+				},
+				Create: func(rd *ResourceData, _ interface{}) error {
+					rd.SetId("bar")
+					return nil
+				},
+			},
+			ExpectedUnsafeLegacyTypeSystem: true,
+		},
+		"CreateContext": {
+			TestResource: &Resource{
+				SchemaVersion: 4,
+				Schema: map[string]*Schema{
+					"foo": {
+						Type:     TypeInt,
+						Optional: true,
+					},
+				},
+				CreateContext: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
+					rd.SetId("bar")
+					return nil
+				},
+			},
+			ExpectedUnsafeLegacyTypeSystem: true,
+		},
+		"CreateWithoutTimeout": {
+			TestResource: &Resource{
+				SchemaVersion: 4,
+				Schema: map[string]*Schema{
+					"foo": {
+						Type:     TypeInt,
+						Optional: true,
+					},
+				},
+				CreateWithoutTimeout: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
+					rd.SetId("bar")
+					return nil
+				},
+			},
+			ExpectedUnsafeLegacyTypeSystem: true,
+		},
+		"Create_cty": {
+			TestResource: &Resource{
+				SchemaVersion: 4,
+				Schema: map[string]*Schema{
+					"foo": {
+						Type:     TypeInt,
+						Optional: true,
+					},
+				},
+				CreateWithoutTimeout: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
+					if rd.GetRawConfig().IsNull() {
+						return diag.FromErr(errors.New("null raw config"))
+					}
+					if !rd.GetRawState().IsNull() {
+						return diag.FromErr(fmt.Errorf("non-null raw state: %s", rd.GetRawState().GoString()))
+					}
+					if rd.GetRawPlan().IsNull() {
+						return diag.FromErr(errors.New("null raw plan"))
+					}
+					rd.SetId("bar")
+					return nil
+				},
+			},
+			ExpectedUnsafeLegacyTypeSystem: true,
+		},
+		"CreateContext_SchemaFunc": {
+			TestResource: &Resource{
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"id": {
+							Type:     TypeString,
+							Computed: true,
+						},
+					}
+				},
+				CreateContext: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
+					rd.SetId("bar") // expected in response
+					return nil
+				},
+			},
+			ExpectedUnsafeLegacyTypeSystem: true,
+		},
+		"EnableLegacyTypeSystemApplyErrors": {
+			TestResource: &Resource{
+				EnableLegacyTypeSystemApplyErrors: true,
+				Schema: map[string]*Schema{
+					"foo": {
+						Type:     TypeInt,
+						Optional: true,
+					},
+				},
+				CreateContext: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
+					rd.SetId("bar")
+					return nil
+				},
 			},
+			ExpectedUnsafeLegacyTypeSystem: false,
 		},
 	}
 
-	server := NewGRPCProviderServer(&Provider{
-		ResourcesMap: map[string]*Resource{
-			"test": r,
-		},
-	})
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
 
-	schema := r.CoreConfigSchema()
-	priorState, err := msgpack.Marshal(cty.NullVal(schema.ImpliedType()), schema.ImpliedType())
-	if err != nil {
-		t.Fatal(err)
-	}
+			server := NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": testCase.TestResource,
+				},
+			})
 
-	proposedVal := cty.ObjectVal(map[string]cty.Value{
-		"id":  cty.UnknownVal(cty.String),
-		"foo": cty.MustParseNumberVal("7227701560655103598"),
-	})
-	proposedState, err := msgpack.Marshal(proposedVal, schema.ImpliedType())
-	if err != nil {
-		t.Fatal(err)
-	}
+			schema := testCase.TestResource.CoreConfigSchema()
+			priorState, err := msgpack.Marshal(cty.NullVal(schema.ImpliedType()), schema.ImpliedType())
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	config, err := schema.CoerceValue(cty.ObjectVal(map[string]cty.Value{
-		"id":  cty.NullVal(cty.String),
-		"foo": cty.MustParseNumberVal("7227701560655103598"),
-	}))
-	if err != nil {
-		t.Fatal(err)
-	}
-	configBytes, err := msgpack.Marshal(config, schema.ImpliedType())
-	if err != nil {
-		t.Fatal(err)
-	}
+			// A proposed state with only the ID unknown will produce a nil diff, and
+			// should return the proposed state value.
+			plannedVal, err := schema.CoerceValue(cty.ObjectVal(map[string]cty.Value{
+				"id": cty.UnknownVal(cty.String),
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			plannedState, err := msgpack.Marshal(plannedVal, schema.ImpliedType())
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	testReq := &tfprotov5.PlanResourceChangeRequest{
-		TypeName: "test",
-		PriorState: &tfprotov5.DynamicValue{
-			MsgPack: priorState,
-		},
-		ProposedNewState: &tfprotov5.DynamicValue{
-			MsgPack: proposedState,
-		},
-		Config: &tfprotov5.DynamicValue{
-			MsgPack: configBytes,
-		},
-	}
+			config, err := schema.CoerceValue(cty.ObjectVal(map[string]cty.Value{
+				"id": cty.NullVal(cty.String),
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			configBytes, err := msgpack.Marshal(config, schema.ImpliedType())
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	resp, err := server.PlanResourceChange(context.Background(), testReq)
-	if err != nil {
-		t.Fatal(err)
-	}
+			testReq := &tfprotov5.ApplyResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: priorState,
+				},
+				PlannedState: &tfprotov5.DynamicValue{
+					MsgPack: plannedState,
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: configBytes,
+				},
+			}
 
-	plannedStateVal, err := msgpack.Unmarshal(resp.PlannedState.MsgPack, schema.ImpliedType())
-	if err != nil {
-		t.Fatal(err)
-	}
+			resp, err := server.ApplyResourceChange(context.Background(), testReq)
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	if !cmp.Equal(proposedVal, plannedStateVal, valueComparer) {
-		t.Fatal(cmp.Diff(proposedVal, plannedStateVal, valueComparer))
-	}
+			newStateVal, err := msgpack.Unmarshal(resp.NewState.MsgPack, schema.ImpliedType())
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	plannedStateFoo, acc := plannedStateVal.GetAttr("foo").AsBigFloat().Int64()
-	if acc != big.Exact {
-		t.Fatalf("Expected exact accuracy, got %s", acc)
-	}
-	if plannedStateFoo != 7227701560655103598 {
-		t.Fatalf("Expected %d, got %d, this represents a loss of precision in planning large numbers", 7227701560655103598, plannedStateFoo)
+			id := newStateVal.GetAttr("id").AsString()
+			if id != "bar" {
+				t.Fatalf("incorrect final state: %#v\n", newStateVal)
+			}
+
+			//nolint:staticcheck // explicitly for this SDK
+			if testCase.ExpectedUnsafeLegacyTypeSystem != resp.UnsafeToUseLegacyTypeSystem {
+				//nolint:staticcheck // explicitly for this SDK
+				t.Fatalf("expected UnsafeLegacyTypeSystem %t, got: %t", testCase.ExpectedUnsafeLegacyTypeSystem, resp.UnsafeToUseLegacyTypeSystem)
+			}
+		})
 	}
 }
 
-func TestApplyResourceChange(t *testing.T) {
+func TestNewGRPCProviderServerWithOptions_internalValidate(t *testing.T) {
 	t.Parallel()
 
 	testCases := map[string]struct {
-		server   *GRPCProviderServer
-		req      *tfprotov5.ApplyResourceChangeRequest
-		expected *tfprotov5.ApplyResourceChangeResponse
+		provider    *Provider
+		expectPanic string
 	}{
-		"create: write-only values are nullified in ApplyResourceChangeResponse": {
-			server: NewGRPCProviderServer(&Provider{
+		"valid": {
+			provider: &Provider{
 				ResourcesMap: map[string]*Resource{
 					"test": {
-						SchemaVersion: 4,
-						CreateContext: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
-							rd.SetId("baz")
-							return nil
-						},
 						Schema: map[string]*Schema{
 							"foo": {
-								Type:      TypeString,
-								Optional:  true,
-								WriteOnly: true,
-							},
-							"bar": {
-								Type:      TypeString,
-								Optional:  true,
-								WriteOnly: true,
+								Type:     TypeString,
+								Required: true,
+								ForceNew: true,
 							},
 						},
+						CreateContext: NoopContext,
+						ReadContext:   NoopContext,
+						DeleteContext: NoopContext,
 					},
 				},
-			}),
-			req: &tfprotov5.ApplyResourceChangeRequest{
-				TypeName: "test",
-				PriorState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"foo": cty.String,
-							"bar": cty.String,
-						}),
-						cty.NullVal(
-							cty.Object(map[string]cty.Type{
-								"foo": cty.String,
-								"bar": cty.String,
-							}),
-						),
-					),
-				},
-				PlannedState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.String,
-							"bar": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.UnknownVal(cty.String),
-							"foo": cty.StringVal("baz"),
-							"bar": cty.StringVal("boop"),
-						}),
-					),
-				},
-				Config: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.String,
-							"bar": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.NullVal(cty.String),
-							"foo": cty.StringVal("baz"),
-							"bar": cty.StringVal("boop"),
-						}),
-					),
-				},
-			},
-			expected: &tfprotov5.ApplyResourceChangeResponse{
-				NewState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":  cty.String,
-							"foo": cty.String,
-							"bar": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":  cty.StringVal("baz"),
-							"foo": cty.NullVal(cty.String),
-							"bar": cty.NullVal(cty.String),
-						}),
-					),
-				},
-				Private:                     []uint8(`{"schema_version":"4"}`),
-				UnsafeToUseLegacyTypeSystem: true,
 			},
 		},
-		"update: write-only values are nullified in ApplyResourceChangeResponse": {
-			server: NewGRPCProviderServer(&Provider{
+		"invalid": {
+			provider: &Provider{
 				ResourcesMap: map[string]*Resource{
 					"test": {
-						SchemaVersion: 4,
-						CreateContext: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
-							rd.SetId("baz")
-							s := rd.Get("configured").(string)
-							err := rd.Set("configured", s)
-							if err != nil {
-								return nil
-							}
-							return nil
-						},
 						Schema: map[string]*Schema{
-							"configured": {
+							"foo": {
 								Type:     TypeString,
-								Optional: true,
-							},
-							"write_onlyA": {
-								Type:      TypeString,
-								Optional:  true,
-								WriteOnly: true,
-							},
-							"write_onlyB": {
-								Type:      TypeString,
-								Optional:  true,
-								WriteOnly: true,
+								ForceNew: true,
 							},
 						},
+						CreateContext: NoopContext,
+						ReadContext:   NoopContext,
+						DeleteContext: NoopContext,
 					},
 				},
-			}),
-			req: &tfprotov5.ApplyResourceChangeRequest{
-				TypeName: "test",
-				PriorState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":          cty.String,
-							"configured":  cty.String,
-							"write_onlyA": cty.String,
-							"write_onlyB": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":          cty.NullVal(cty.String),
-							"configured":  cty.StringVal("prior_val"),
-							"write_onlyA": cty.NullVal(cty.String),
-							"write_onlyB": cty.NullVal(cty.String),
-						}),
-					),
-				},
-				PlannedState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":          cty.String,
-							"configured":  cty.String,
-							"write_onlyA": cty.String,
-							"write_onlyB": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":          cty.UnknownVal(cty.String),
-							"configured":  cty.StringVal("updated_val"),
-							"write_onlyA": cty.StringVal("foo"),
-							"write_onlyB": cty.StringVal("bar"),
-						}),
-					),
-				},
-				Config: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":          cty.String,
-							"configured":  cty.String,
-							"write_onlyA": cty.String,
-							"write_onlyB": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":          cty.NullVal(cty.String),
-							"configured":  cty.StringVal("updated_val"),
-							"write_onlyA": cty.StringVal("foo"),
-							"write_onlyB": cty.StringVal("bar"),
-						}),
-					),
-				},
-			},
-			expected: &tfprotov5.ApplyResourceChangeResponse{
-				NewState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":          cty.String,
-							"configured":  cty.String,
-							"write_onlyA": cty.String,
-							"write_onlyB": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id":          cty.StringVal("baz"),
-							"configured":  cty.StringVal("updated_val"),
-							"write_onlyA": cty.NullVal(cty.String),
-							"write_onlyB": cty.NullVal(cty.String),
-						}),
-					),
-				},
-				Private:                     []uint8(`{"schema_version":"4"}`),
-				UnsafeToUseLegacyTypeSystem: true,
 			},
+			expectPanic: "Error: Invalid Provider Schema: resource test: foo: One of optional, required, or computed must be set",
 		},
-		"create: identity returned in ApplyResourceChangeResponse": {
-			server: NewGRPCProviderServer(&Provider{
-				ResourcesMap: map[string]*Resource{
-					"test": {
-						SchemaVersion: 4,
-						CreateContext: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
-							rd.SetId("baz")
-							identity, err := rd.Identity()
-							if err != nil {
-								t.Fatal(err)
-							}
-							err = identity.Set("ident", "bazz")
-							if err != nil {
-								t.Fatal(err)
-							}
-							return nil
-						},
-						Schema: map[string]*Schema{},
-						Identity: &ResourceIdentity{
-							Version: 1,
-							SchemaFunc: func() map[string]*Schema {
-								return map[string]*Schema{
-									"ident": {
-										Type:              TypeString,
-										RequiredForImport: true,
-									},
-								}
-							},
-						},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			defer func() {
+				r := recover()
+
+				if testCase.expectPanic == "" {
+					if r != nil {
+						t.Fatalf("unexpected panic: %v", r)
+					}
+					return
+				}
+
+				if r == nil {
+					t.Fatal("expected panic")
+				}
+
+				if !strings.Contains(fmt.Sprint(r), testCase.expectPanic) {
+					t.Fatalf("expected panic containing %q, got: %v", testCase.expectPanic, r)
+				}
+			}()
+
+			NewGRPCProviderServerWithOptions(testCase.provider, WithInternalValidate())
+		})
+	}
+}
+
+func TestApplyResourceChange_maxConcurrentApply(t *testing.T) {
+	t.Parallel()
+
+	const maxConcurrent = 2
+
+	var running, maxRunning int32
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	server := NewGRPCProviderServerWithOptions(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": {
+				Schema: map[string]*Schema{
+					"foo": {
+						Type:     TypeString,
+						Optional: true,
 					},
 				},
-			}),
-			req: &tfprotov5.ApplyResourceChangeRequest{
-				TypeName: "test",
-				PriorState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{}),
-						cty.NullVal(
-							cty.Object(map[string]cty.Type{}),
-						),
-					),
+				CreateContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					n := atomic.AddInt32(&running, 1)
+					defer atomic.AddInt32(&running, -1)
+
+					for {
+						m := atomic.LoadInt32(&maxRunning)
+						if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+							break
+						}
+					}
+
+					started <- struct{}{}
+					<-release
+
+					d.SetId("bar")
+					return nil
 				},
-				PlannedState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id": cty.UnknownVal(cty.String),
-						}),
-					),
+				ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					return nil
 				},
-				PlannedIdentity: &tfprotov5.ResourceIdentityData{
-					IdentityData: &tfprotov5.DynamicValue{
-						MsgPack: mustMsgpackMarshal(
-							cty.Object(map[string]cty.Type{
-								"ident": cty.String,
-							}),
-							cty.ObjectVal(map[string]cty.Value{
-								"ident": cty.UnknownVal(cty.String),
-							}),
-						),
+				DeleteContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					return nil
+				},
+			},
+		},
+	}, WithMaxConcurrentApply(maxConcurrent))
+
+	req := testApplyResourceChangeCreateRequest(t, server)
+
+	const applies = 5
+
+	var wg sync.WaitGroup
+	errs := make(chan error, applies)
+
+	for i := 0; i < applies; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			resp, err := server.ApplyResourceChange(context.Background(), req)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			if len(resp.Diagnostics) > 0 {
+				errs <- fmt.Errorf("unexpected diagnostics: %v", resp.Diagnostics)
+			}
+		}()
+	}
+
+	for i := 0; i < maxConcurrent; i++ {
+		<-started
+	}
+
+	select {
+	case <-started:
+		t.Fatalf("expected at most %d concurrent applies", maxConcurrent)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	go func() {
+		for i := maxConcurrent; i < applies; i++ {
+			<-started
+		}
+	}()
+
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if got := atomic.LoadInt32(&maxRunning); got != maxConcurrent {
+		t.Fatalf("expected %d concurrent applies, got %d", maxConcurrent, got)
+	}
+}
+
+func TestApplyResourceChange_maxConcurrentApplyContextDone(t *testing.T) {
+	t.Parallel()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	server := NewGRPCProviderServerWithOptions(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": {
+				Schema: map[string]*Schema{
+					"foo": {
+						Type:     TypeString,
+						Optional: true,
 					},
 				},
-				Config: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id": cty.NullVal(cty.String),
-						}),
-					),
+				CreateContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					close(started)
+					<-release
+
+					d.SetId("bar")
+					return nil
 				},
-			},
-			expected: &tfprotov5.ApplyResourceChangeResponse{
-				NewState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id": cty.StringVal("baz"),
-						}),
-					),
+				ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					return nil
 				},
-				Private:                     []uint8(`{"schema_version":"4"}`),
-				UnsafeToUseLegacyTypeSystem: true,
-				NewIdentity: &tfprotov5.ResourceIdentityData{
-					IdentityData: &tfprotov5.DynamicValue{
-						MsgPack: mustMsgpackMarshal(
-							cty.Object(map[string]cty.Type{
-								"ident": cty.String,
-							}),
-							cty.ObjectVal(map[string]cty.Value{
-								"ident": cty.StringVal("bazz"),
-							}),
-						),
-					},
+				DeleteContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					return nil
 				},
 			},
 		},
-		"create: no identity schema diag in ApplyResourceChangeResponse": {
-			server: NewGRPCProviderServer(&Provider{
-				ResourcesMap: map[string]*Resource{
-					"test": {
-						SchemaVersion: 4,
-						Schema:        map[string]*Schema{},
-						Identity: &ResourceIdentity{
-							Version: 1,
-						},
+	}, WithMaxConcurrentApply(1))
+
+	req := testApplyResourceChangeCreateRequest(t, server)
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		if _, err := server.ApplyResourceChange(context.Background(), req); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	<-started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	resp, err := server.ApplyResourceChange(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	AssertDiagnosticCount(t, resp.Diagnostics, 1)
+	AssertDiagnosticContains(t, resp.Diagnostics, tfprotov5.DiagnosticSeverityError, "Apply Wait Interrupted")
+
+	close(release)
+	<-done
+}
+
+// testApplyResourceChangeCreateRequest returns an ApplyResourceChange request
+// which creates the "test" resource with a single optional "foo" attribute.
+func testApplyResourceChangeCreateRequest(t *testing.T, server *GRPCProviderServer) *tfprotov5.ApplyResourceChangeRequest {
+	t.Helper()
+
+	schema := server.getResourceSchemaBlock("test")
+	ty := schema.ImpliedType()
+
+	plannedVal, err := schema.CoerceValue(cty.ObjectVal(map[string]cty.Value{
+		"id":  cty.UnknownVal(cty.String),
+		"foo": cty.StringVal("bar"),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configVal, err := schema.CoerceValue(cty.ObjectVal(map[string]cty.Value{
+		"id":  cty.NullVal(cty.String),
+		"foo": cty.StringVal("bar"),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &tfprotov5.ApplyResourceChangeRequest{
+		TypeName: "test",
+		PriorState: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, cty.NullVal(ty)),
+		},
+		PlannedState: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, plannedVal),
+		},
+		Config: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, configVal),
+		},
+	}
+}
+
+func TestApplyResourceChange_customizeTimeout(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		customizeTimeout CustomizeTimeoutFunc
+		expectedError    string
+	}{
+		"timeout computed from config": {
+			customizeTimeout: func(ctx context.Context, d *ResourceData, rt *ResourceTimeout) error {
+				rt.Create = DefaultTimeout(time.Duration(d.Get("nodes").(int)) * 10 * time.Millisecond)
+				return nil
+			},
+			expectedError: "context deadline exceeded",
+		},
+		"error": {
+			customizeTimeout: func(ctx context.Context, d *ResourceData, rt *ResourceTimeout) error {
+				return errors.New("customize timeout error")
+			},
+			expectedError: "customize timeout error",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testResource := &Resource{
+				Schema: map[string]*Schema{
+					"nodes": {
+						Type:     TypeInt,
+						Required: true,
+						ForceNew: true,
 					},
 				},
-			}),
-			req: &tfprotov5.ApplyResourceChangeRequest{
+				Timeouts: &ResourceTimeout{
+					Create: DefaultTimeout(time.Hour),
+				},
+				CustomizeTimeout: testCase.customizeTimeout,
+				CreateContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					if timeout := d.Timeout(TimeoutCreate); timeout != 30*time.Millisecond {
+						return diag.Errorf("expected create timeout of 30ms, got %s", timeout)
+					}
+
+					select {
+					case <-ctx.Done():
+						return diag.FromErr(ctx.Err())
+					case <-time.After(5 * time.Second):
+						return diag.Errorf("expected context to be cancelled by the customized timeout")
+					}
+				},
+				ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					return nil
+				},
+				DeleteContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					return nil
+				},
+			}
+
+			server := NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": testResource,
+				},
+			})
+
+			schema := testResource.CoreConfigSchema()
+			priorState, err := msgpack.Marshal(cty.NullVal(schema.ImpliedType()), schema.ImpliedType())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			plannedState, err := msgpack.Marshal(cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.UnknownVal(cty.String),
+				"nodes":    cty.NumberIntVal(3),
+				"timeouts": cty.NullVal(schema.ImpliedType().AttributeType("timeouts")),
+			}), schema.ImpliedType())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config, err := msgpack.Marshal(cty.ObjectVal(map[string]cty.Value{
+				"id":       cty.NullVal(cty.String),
+				"nodes":    cty.NumberIntVal(3),
+				"timeouts": cty.NullVal(schema.ImpliedType().AttributeType("timeouts")),
+			}), schema.ImpliedType())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := server.ApplyResourceChange(context.Background(), &tfprotov5.ApplyResourceChangeRequest{
 				TypeName: "test",
 				PriorState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{}),
-						cty.NullVal(
-							cty.Object(map[string]cty.Type{}),
-						),
-					),
+					MsgPack: priorState,
 				},
 				PlannedState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id": cty.UnknownVal(cty.String),
-						}),
-					),
-				},
-				PlannedIdentity: &tfprotov5.ResourceIdentityData{
-					IdentityData: &tfprotov5.DynamicValue{
-						MsgPack: mustMsgpackMarshal(
-							cty.Object(map[string]cty.Type{
-								"ident": cty.String,
-							}),
-							cty.ObjectVal(map[string]cty.Value{
-								"ident": cty.UnknownVal(cty.String),
-							}),
-						),
-					},
+					MsgPack: plannedState,
 				},
 				Config: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id": cty.NullVal(cty.String),
-						}),
-					),
+					MsgPack: config,
 				},
-			},
-			expected: &tfprotov5.ApplyResourceChangeResponse{
-				Diagnostics: []*tfprotov5.Diagnostic{
-					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "getting identity schema failed for resource 'test': resource does not have an identity schema",
-					},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			AssertDiagnosticCount(t, resp.Diagnostics, 1)
+			AssertDiagnosticContains(t, resp.Diagnostics, tfprotov5.DiagnosticSeverityError, testCase.expectedError)
+		})
+	}
+}
+
+func TestApplyResourceChange_objectMap(t *testing.T) {
+	t.Parallel()
+
+	endpointVal := func(address string, port int64) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"address": cty.StringVal(address),
+			"port":    cty.NumberIntVal(port),
+		})
+	}
+
+	testCases := map[string]struct {
+		prior    cty.Value
+		config   cty.Value
+		expected map[string]interface{}
+	}{
+		"add and change": {
+			prior: cty.MapVal(map[string]cty.Value{
+				"primary": endpointVal("10.0.0.1", 80),
+			}),
+			config: cty.MapVal(map[string]cty.Value{
+				"primary":   endpointVal("10.0.0.1", 81),
+				"secondary": endpointVal("10.0.0.2", 8080),
+			}),
+			expected: map[string]interface{}{
+				"primary": map[string]interface{}{
+					"address": "10.0.0.1",
+					"port":    81,
+				},
+				"secondary": map[string]interface{}{
+					"address": "10.0.0.2",
+					"port":    8080,
 				},
-				NewState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(cty.DynamicPseudoType, cty.NullVal(cty.DynamicPseudoType)),
+			},
+		},
+		"remove": {
+			prior: cty.MapVal(map[string]cty.Value{
+				"primary":   endpointVal("10.0.0.1", 80),
+				"secondary": endpointVal("10.0.0.2", 8080),
+			}),
+			config: cty.MapVal(map[string]cty.Value{
+				"secondary": endpointVal("10.0.0.2", 8080),
+			}),
+			expected: map[string]interface{}{
+				"secondary": map[string]interface{}{
+					"address": "10.0.0.2",
+					"port":    8080,
 				},
 			},
 		},
-		"create: empty identity schema diag in ApplyResourceChangeResponse": {
-			server: NewGRPCProviderServer(&Provider{
-				ResourcesMap: map[string]*Resource{
-					"test": {
-						SchemaVersion: 4,
-						Schema:        map[string]*Schema{},
-						Identity: &ResourceIdentity{
-							Version: 1,
-							SchemaFunc: func() map[string]*Schema {
-								return map[string]*Schema{}
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var updated bool
+
+			testResource := &Resource{
+				Schema: map[string]*Schema{
+					"endpoints": {
+						Type:       TypeMap,
+						Optional:   true,
+						ConfigMode: SchemaConfigModeAttr,
+						Elem: &Resource{
+							Schema: map[string]*Schema{
+								"address": {
+									Type:     TypeString,
+									Optional: true,
+								},
+								"port": {
+									Type:     TypeInt,
+									Optional: true,
+								},
 							},
 						},
 					},
 				},
-			}),
-			req: &tfprotov5.ApplyResourceChangeRequest{
+				UpdateContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					updated = true
+
+					if !d.HasChange("endpoints") {
+						return diag.Errorf("expected endpoints to have changed")
+					}
+
+					if diff := cmp.Diff(testCase.expected, d.Get("endpoints")); diff != "" {
+						return diag.Errorf("unexpected endpoints difference: %s", diff)
+					}
+
+					return nil
+				},
+				ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					return nil
+				},
+				DeleteContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+					return nil
+				},
+			}
+
+			server := NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": testResource,
+				},
+			})
+
+			ty := testResource.CoreConfigSchema().ImpliedType()
+
+			priorState := cty.ObjectVal(map[string]cty.Value{
+				"id":        cty.StringVal("test"),
+				"endpoints": testCase.prior,
+			})
+
+			config := cty.ObjectVal(map[string]cty.Value{
+				"id":        cty.NullVal(cty.String),
+				"endpoints": testCase.config,
+			})
+
+			proposedNewState := cty.ObjectVal(map[string]cty.Value{
+				"id":        cty.StringVal("test"),
+				"endpoints": testCase.config,
+			})
+
+			planResp, err := server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
 				TypeName: "test",
 				PriorState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{}),
-						cty.NullVal(
-							cty.Object(map[string]cty.Type{}),
-						),
-					),
-				},
-				PlannedState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id": cty.UnknownVal(cty.String),
-						}),
-					),
+					MsgPack: mustMsgpackMarshal(ty, priorState),
 				},
-				PlannedIdentity: &tfprotov5.ResourceIdentityData{
-					IdentityData: &tfprotov5.DynamicValue{
-						MsgPack: mustMsgpackMarshal(
-							cty.Object(map[string]cty.Type{
-								"ident": cty.String,
-							}),
-							cty.ObjectVal(map[string]cty.Value{
-								"ident": cty.UnknownVal(cty.String),
-							}),
-						),
-					},
+				ProposedNewState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, proposedNewState),
 				},
 				Config: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id": cty.String,
-						}),
-						cty.ObjectVal(map[string]cty.Value{
-							"id": cty.NullVal(cty.String),
-						}),
-					),
+					MsgPack: mustMsgpackMarshal(ty, config),
 				},
-			},
-			expected: &tfprotov5.ApplyResourceChangeResponse{
-				Diagnostics: []*tfprotov5.Diagnostic{
-					{
-						Severity: tfprotov5.DiagnosticSeverityError,
-						Summary:  "getting identity schema failed for resource 'test': identity schema must have at least one attribute",
-					},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(planResp.Diagnostics) > 0 {
+				t.Fatalf("unexpected plan diagnostics: %#v", planResp.Diagnostics)
+			}
+
+			plannedState, err := msgpack.Unmarshal(planResp.PlannedState.MsgPack, ty)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !plannedState.RawEquals(proposedNewState) {
+				t.Fatalf("expected planned state %#v, got %#v", proposedNewState, plannedState)
+			}
+
+			applyResp, err := server.ApplyResourceChange(context.Background(), &tfprotov5.ApplyResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, priorState),
 				},
-				NewState: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(cty.DynamicPseudoType, cty.NullVal(cty.DynamicPseudoType)),
+				PlannedState:   planResp.PlannedState,
+				PlannedPrivate: planResp.PlannedPrivate,
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(ty, config),
 				},
-			},
-		},
-	}
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
 
-	for name, testCase := range testCases {
-		t.Run(name, func(t *testing.T) {
-			t.Parallel()
+			if len(applyResp.Diagnostics) > 0 {
+				t.Fatalf("unexpected apply diagnostics: %#v", applyResp.Diagnostics)
+			}
 
-			resp, err := testCase.server.ApplyResourceChange(context.Background(), testCase.req)
+			if !updated {
+				t.Fatal("expected UpdateContext to be called")
+			}
+
+			newState, err := msgpack.Unmarshal(applyResp.NewState.MsgPack, ty)
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			if diff := cmp.Diff(resp, testCase.expected, valueComparer); diff != "" {
-				ty := testCase.server.getResourceSchemaBlock("test").ImpliedType()
+			if !newState.RawEquals(proposedNewState) {
+				t.Errorf("expected new state %#v, got %#v", proposedNewState, newState)
+			}
+		})
+	}
+}
 
-				if resp != nil && resp.NewState != nil {
-					t.Logf("resp.NewState.MsgPack: %s", mustMsgpackUnmarshal(ty, resp.NewState.MsgPack))
-				}
+func TestApplyResourceChange_private(t *testing.T) {
+	t.Parallel()
 
-				if testCase.expected != nil && testCase.expected.NewState != nil {
-					t.Logf("expected: %s", mustMsgpackUnmarshal(ty, testCase.expected.NewState.MsgPack))
-				}
+	testResource := &Resource{
+		SchemaVersion: 1,
+		Schema: map[string]*Schema{
+			"foo": {
+				Type:     TypeString,
+				Optional: true,
+			},
+			"token": {
+				Type:     TypeString,
+				Computed: true,
+			},
+		},
+		Timeouts: &ResourceTimeout{
+			Create: DefaultTimeout(10 * time.Minute),
+		},
+		CustomizeDiff: func(ctx context.Context, d *ResourceDiff, meta interface{}) error {
+			if err := d.SetPrivate("token", []byte("secret-"+d.Get("foo").(string))); err != nil {
+				return err
+			}
 
-				t.Error(diff)
+			value, err := d.GetPrivate("token")
+			if err != nil {
+				return err
 			}
-		})
+
+			if string(value) != "secret-bar" {
+				return fmt.Errorf("unexpected private value in CustomizeDiff: %q", value)
+			}
+
+			return nil
+		},
+		CreateContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+			value, err := d.GetPrivate("token")
+			if err != nil {
+				return diag.FromErr(err)
+			}
+
+			missing, err := d.GetPrivate("missing")
+			if err != nil {
+				return diag.FromErr(err)
+			}
+
+			if missing != nil {
+				return diag.Errorf("unexpected private value for missing key: %q", missing)
+			}
+
+			d.SetId("test")
+
+			return diag.FromErr(d.Set("token", string(value)))
+		},
+		ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+			return nil
+		},
+		DeleteContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+			return nil
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{
+		ResourcesMap: map[string]*Resource{
+			"test": testResource,
+		},
+	})
+
+	schema := testResource.CoreConfigSchema()
+	ty := schema.ImpliedType()
+
+	config, err := schema.CoerceValue(cty.ObjectVal(map[string]cty.Value{
+		"foo": cty.StringVal("bar"),
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	planResp, err := server.PlanResourceChange(context.Background(), &tfprotov5.PlanResourceChangeRequest{
+		TypeName: "test",
+		PriorState: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, cty.NullVal(ty)),
+		},
+		ProposedNewState: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, config),
+		},
+		Config: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, config),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(planResp.Diagnostics) > 0 {
+		t.Fatalf("unexpected plan diagnostics: %#v", planResp.Diagnostics)
+	}
+
+	var plannedPrivate map[string]interface{}
+	if err := json.Unmarshal(planResp.PlannedPrivate, &plannedPrivate); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, k := range []string{newExtraKey, TimeoutKey, privateKey} {
+		if _, ok := plannedPrivate[k]; !ok {
+			t.Errorf("expected planned private to contain %s, got: %s", k, planResp.PlannedPrivate)
+		}
+	}
+
+	applyResp, err := server.ApplyResourceChange(context.Background(), &tfprotov5.ApplyResourceChangeRequest{
+		TypeName: "test",
+		PriorState: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, cty.NullVal(ty)),
+		},
+		PlannedState:   planResp.PlannedState,
+		PlannedPrivate: planResp.PlannedPrivate,
+		Config: &tfprotov5.DynamicValue{
+			MsgPack: mustMsgpackMarshal(ty, config),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(applyResp.Diagnostics) > 0 {
+		t.Fatalf("unexpected apply diagnostics: %#v", applyResp.Diagnostics)
+	}
+
+	newState, err := msgpack.Unmarshal(applyResp.NewState.MsgPack, ty)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := newState.GetAttr("token"); !got.RawEquals(cty.StringVal("secret-bar")) {
+		t.Errorf("expected token to be set from private value, got: %#v", got)
+	}
+
+	var private map[string]interface{}
+	if err := json.Unmarshal(applyResp.Private, &private); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := private[privateKey]; ok {
+		t.Errorf("expected private values not to be stored in state, got: %s", applyResp.Private)
 	}
 }
 
-func TestApplyResourceChange_ResourceFuncs(t *testing.T) {
+func TestApplyResourceChange_DeleteFuncs(t *testing.T) {
 	t.Parallel()
 
 	testCases := map[string]struct {
-		TestResource                   *Resource
-		ExpectedUnsafeLegacyTypeSystem bool
+		TestResource func(deleted *bool) *Resource
 	}{
-		"Create": {
-			TestResource: &Resource{
-				SchemaVersion: 4,
-				Schema: map[string]*Schema{
-					"foo": {
-						Type:     TypeInt,
-						Optional: true,
-					},
-				},
-				Create: func(rd *ResourceData, _ interface{}) error {
-					rd.SetId("bar")
-					return nil
-				},
-			},
-			ExpectedUnsafeLegacyTypeSystem: true,
-		},
-		"CreateContext": {
-			TestResource: &Resource{
-				SchemaVersion: 4,
-				Schema: map[string]*Schema{
-					"foo": {
-						Type:     TypeInt,
-						Optional: true,
+		"Delete": {
+			TestResource: func(deleted *bool) *Resource {
+				return &Resource{
+					Schema: map[string]*Schema{
+						"foo": {
+							Type:     TypeInt,
+							Optional: true,
+						},
 					},
-				},
-				CreateContext: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
-					rd.SetId("bar")
-					return nil
-				},
-			},
-			ExpectedUnsafeLegacyTypeSystem: true,
-		},
-		"CreateWithoutTimeout": {
-			TestResource: &Resource{
-				SchemaVersion: 4,
-				Schema: map[string]*Schema{
-					"foo": {
-						Type:     TypeInt,
-						Optional: true,
+					Delete: func(rd *ResourceData, _ interface{}) error {
+						*deleted = true
+						return nil
 					},
-				},
-				CreateWithoutTimeout: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
-					rd.SetId("bar")
-					return nil
-				},
+				}
 			},
-			ExpectedUnsafeLegacyTypeSystem: true,
 		},
-		"Create_cty": {
-			TestResource: &Resource{
-				SchemaVersion: 4,
-				Schema: map[string]*Schema{
-					"foo": {
-						Type:     TypeInt,
-						Optional: true,
+		"DeleteContext": {
+			TestResource: func(deleted *bool) *Resource {
+				return &Resource{
+					Schema: map[string]*Schema{
+						"foo": {
+							Type:     TypeInt,
+							Optional: true,
+						},
 					},
-				},
-				CreateWithoutTimeout: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
-					if rd.GetRawConfig().IsNull() {
-						return diag.FromErr(errors.New("null raw config"))
-					}
-					if !rd.GetRawState().IsNull() {
-						return diag.FromErr(fmt.Errorf("non-null raw state: %s", rd.GetRawState().GoString()))
-					}
-					if rd.GetRawPlan().IsNull() {
-						return diag.FromErr(errors.New("null raw plan"))
-					}
-					rd.SetId("bar")
-					return nil
-				},
+					DeleteContext: func(ctx context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
+						if _, ok := ctx.Deadline(); !ok {
+							return diag.Errorf("expected context deadline")
+						}
+
+						*deleted = true
+						return nil
+					},
+				}
 			},
-			ExpectedUnsafeLegacyTypeSystem: true,
 		},
-		"CreateContext_SchemaFunc": {
-			TestResource: &Resource{
-				SchemaFunc: func() map[string]*Schema {
-					return map[string]*Schema{
-						"id": {
-							Type:     TypeString,
-							Computed: true,
+		"DeleteWithoutTimeout": {
+			TestResource: func(deleted *bool) *Resource {
+				return &Resource{
+					Schema: map[string]*Schema{
+						"foo": {
+							Type:     TypeInt,
+							Optional: true,
 						},
-					}
-				},
-				CreateContext: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
-					rd.SetId("bar") // expected in response
-					return nil
-				},
-			},
-			ExpectedUnsafeLegacyTypeSystem: true,
-		},
-		"EnableLegacyTypeSystemApplyErrors": {
-			TestResource: &Resource{
-				EnableLegacyTypeSystemApplyErrors: true,
-				Schema: map[string]*Schema{
-					"foo": {
-						Type:     TypeInt,
-						Optional: true,
 					},
-				},
-				CreateContext: func(_ context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
-					rd.SetId("bar")
-					return nil
-				},
+					DeleteWithoutTimeout: func(ctx context.Context, rd *ResourceData, _ interface{}) diag.Diagnostics {
+						if _, ok := ctx.Deadline(); ok {
+							return diag.Errorf("unexpected context deadline")
+						}
+
+						*deleted = true
+						return nil
+					},
+				}
 			},
-			ExpectedUnsafeLegacyTypeSystem: false,
 		},
 	}
 
@@ -7221,43 +13935,36 @@ func TestApplyResourceChange_ResourceFuncs(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
+			var deleted bool
+
+			testResource := testCase.TestResource(&deleted)
+
 			server := NewGRPCProviderServer(&Provider{
 				ResourcesMap: map[string]*Resource{
-					"test": testCase.TestResource,
+					"test": testResource,
 				},
 			})
 
-			schema := testCase.TestResource.CoreConfigSchema()
-			priorState, err := msgpack.Marshal(cty.NullVal(schema.ImpliedType()), schema.ImpliedType())
+			schema := testResource.CoreConfigSchema()
+			priorState, err := msgpack.Marshal(cty.ObjectVal(map[string]cty.Value{
+				"id":  cty.StringVal("bar"),
+				"foo": cty.NumberIntVal(1),
+			}), schema.ImpliedType())
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			// A proposed state with only the ID unknown will produce a nil diff, and
-			// should return the proposed state value.
-			plannedVal, err := schema.CoerceValue(cty.ObjectVal(map[string]cty.Value{
-				"id": cty.UnknownVal(cty.String),
-			}))
-			if err != nil {
-				t.Fatal(err)
-			}
-			plannedState, err := msgpack.Marshal(plannedVal, schema.ImpliedType())
+			plannedState, err := msgpack.Marshal(cty.NullVal(schema.ImpliedType()), schema.ImpliedType())
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			config, err := schema.CoerceValue(cty.ObjectVal(map[string]cty.Value{
-				"id": cty.NullVal(cty.String),
-			}))
-			if err != nil {
-				t.Fatal(err)
-			}
-			configBytes, err := msgpack.Marshal(config, schema.ImpliedType())
+			config, err := msgpack.Marshal(cty.NullVal(schema.ImpliedType()), schema.ImpliedType())
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			testReq := &tfprotov5.ApplyResourceChangeRequest{
+			resp, err := server.ApplyResourceChange(context.Background(), &tfprotov5.ApplyResourceChangeRequest{
 				TypeName: "test",
 				PriorState: &tfprotov5.DynamicValue{
 					MsgPack: priorState,
@@ -7266,29 +13973,26 @@ func TestApplyResourceChange_ResourceFuncs(t *testing.T) {
 					MsgPack: plannedState,
 				},
 				Config: &tfprotov5.DynamicValue{
-					MsgPack: configBytes,
+					MsgPack: config,
 				},
-			}
-
-			resp, err := server.ApplyResourceChange(context.Background(), testReq)
+			})
 			if err != nil {
 				t.Fatal(err)
 			}
 
+			AssertNoDiagnostics(t, resp.Diagnostics)
+
+			if !deleted {
+				t.Fatal("expected delete function to be called")
+			}
+
 			newStateVal, err := msgpack.Unmarshal(resp.NewState.MsgPack, schema.ImpliedType())
 			if err != nil {
 				t.Fatal(err)
 			}
 
-			id := newStateVal.GetAttr("id").AsString()
-			if id != "bar" {
-				t.Fatalf("incorrect final state: %#v\n", newStateVal)
-			}
-
-			//nolint:staticcheck // explicitly for this SDK
-			if testCase.ExpectedUnsafeLegacyTypeSystem != resp.UnsafeToUseLegacyTypeSystem {
-				//nolint:staticcheck // explicitly for this SDK
-				t.Fatalf("expected UnsafeLegacyTypeSystem %t, got: %t", testCase.ExpectedUnsafeLegacyTypeSystem, resp.UnsafeToUseLegacyTypeSystem)
+			if !newStateVal.IsNull() {
+				t.Fatalf("expected null new state, got: %#v", newStateVal)
 			}
 		})
 	}
@@ -7605,64 +14309,209 @@ func TestApplyResourceChange_ResourceFuncs_writeOnly(t *testing.T) {
 				t.Fatal(err)
 			}
 
-			config, err := schema.CoerceValue(cty.ObjectVal(map[string]cty.Value{
-				"id":             cty.NullVal(cty.String),
-				"write_only_bar": cty.StringVal("bar"),
-			}))
-			if err != nil {
-				t.Fatal(err)
-			}
-			configBytes, err := msgpack.Marshal(config, schema.ImpliedType())
-			if err != nil {
-				t.Fatal(err)
-			}
+			config, err := schema.CoerceValue(cty.ObjectVal(map[string]cty.Value{
+				"id":             cty.NullVal(cty.String),
+				"write_only_bar": cty.StringVal("bar"),
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			configBytes, err := msgpack.Marshal(config, schema.ImpliedType())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			testReq := &tfprotov5.ApplyResourceChangeRequest{
+				TypeName: "test",
+				PriorState: &tfprotov5.DynamicValue{
+					MsgPack: priorState,
+				},
+				PlannedState: &tfprotov5.DynamicValue{
+					MsgPack: plannedState,
+				},
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: configBytes,
+				},
+			}
+
+			resp, err := server.ApplyResourceChange(context.Background(), testReq)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			newStateVal, err := msgpack.Unmarshal(resp.NewState.MsgPack, schema.ImpliedType())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			id := newStateVal.GetAttr("id").AsString()
+			if id != "baz" {
+				t.Fatalf("incorrect final state: %#v\n", newStateVal)
+			}
+
+			//nolint:staticcheck // explicitly for this SDK
+			if testCase.ExpectedUnsafeLegacyTypeSystem != resp.UnsafeToUseLegacyTypeSystem {
+				//nolint:staticcheck // explicitly for this SDK
+				t.Fatalf("expected UnsafeLegacyTypeSystem %t, got: %t", testCase.ExpectedUnsafeLegacyTypeSystem, resp.UnsafeToUseLegacyTypeSystem)
+			}
+		})
+	}
+}
+
+func TestImportResourceState(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		server   *GRPCProviderServer
+		req      *tfprotov5.ImportResourceStateRequest
+		expected *tfprotov5.ImportResourceStateResponse
+	}{
+		"basic-import": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 1,
+						Schema: map[string]*Schema{
+							"id": {
+								Type:     TypeString,
+								Required: true,
+							},
+							"test_string": {
+								Type:     TypeString,
+								Computed: true,
+							},
+						},
+						Importer: &ResourceImporter{
+							StateContext: func(ctx context.Context, d *ResourceData, meta interface{}) ([]*ResourceData, error) {
+								err := d.Set("test_string", "new-imported-val")
+								if err != nil {
+									return nil, err
+								}
+
+								return []*ResourceData{d}, nil
+							},
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.ImportResourceStateRequest{
+				TypeName: "test",
+				ID:       "imported-id",
+			},
+			expected: &tfprotov5.ImportResourceStateResponse{
+				ImportedResources: []*tfprotov5.ImportedResource{
+					{
+						TypeName: "test",
+						State: &tfprotov5.DynamicValue{
+							MsgPack: mustMsgpackMarshal(
+								cty.Object(map[string]cty.Type{
+									"id":          cty.String,
+									"test_string": cty.String,
+								}),
+								cty.ObjectVal(map[string]cty.Value{
+									"id":          cty.StringVal("imported-id"),
+									"test_string": cty.StringVal("new-imported-val"),
+								}),
+							),
+						},
+						Private: []byte(`{"schema_version":"1"}`),
+					},
+				},
+			},
+		},
+		"multiple-resource-types": {
+			server: NewGRPCProviderServer(&Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_instance": {
+						SchemaVersion: 1,
+						Schema: map[string]*Schema{
+							"id": {
+								Type:     TypeString,
+								Required: true,
+							},
+						},
+						Importer: &ResourceImporter{
+							StateContext: func(ctx context.Context, d *ResourceData, meta interface{}) ([]*ResourceData, error) {
+								volume := (&Resource{
+									SchemaVersion: 2,
+									Schema: map[string]*Schema{
+										"id": {
+											Type:     TypeString,
+											Required: true,
+										},
+										"instance_id": {
+											Type:     TypeString,
+											Computed: true,
+										},
+									},
+								}).Data(nil)
+								volume.SetId("imported-volume-id")
+								volume.SetType("test_volume")
+
+								err := volume.Set("instance_id", d.Id())
+								if err != nil {
+									return nil, err
+								}
 
-			testReq := &tfprotov5.ApplyResourceChangeRequest{
-				TypeName: "test",
-				PriorState: &tfprotov5.DynamicValue{
-					MsgPack: priorState,
-				},
-				PlannedState: &tfprotov5.DynamicValue{
-					MsgPack: plannedState,
+								return []*ResourceData{d, volume}, nil
+							},
+						},
+					},
+					"test_volume": {
+						SchemaVersion: 2,
+						Schema: map[string]*Schema{
+							"id": {
+								Type:     TypeString,
+								Required: true,
+							},
+							"instance_id": {
+								Type:     TypeString,
+								Computed: true,
+							},
+						},
+					},
 				},
-				Config: &tfprotov5.DynamicValue{
-					MsgPack: configBytes,
+			}),
+			req: &tfprotov5.ImportResourceStateRequest{
+				TypeName: "test_instance",
+				ID:       "imported-id",
+			},
+			expected: &tfprotov5.ImportResourceStateResponse{
+				ImportedResources: []*tfprotov5.ImportedResource{
+					{
+						TypeName: "test_instance",
+						State: &tfprotov5.DynamicValue{
+							MsgPack: mustMsgpackMarshal(
+								cty.Object(map[string]cty.Type{
+									"id": cty.String,
+								}),
+								cty.ObjectVal(map[string]cty.Value{
+									"id": cty.StringVal("imported-id"),
+								}),
+							),
+						},
+						Private: []byte(`{"schema_version":"1"}`),
+					},
+					{
+						TypeName: "test_volume",
+						State: &tfprotov5.DynamicValue{
+							MsgPack: mustMsgpackMarshal(
+								cty.Object(map[string]cty.Type{
+									"id":          cty.String,
+									"instance_id": cty.String,
+								}),
+								cty.ObjectVal(map[string]cty.Value{
+									"id":          cty.StringVal("imported-volume-id"),
+									"instance_id": cty.StringVal("imported-id"),
+								}),
+							),
+						},
+						Private: []byte(`{"schema_version":"2"}`),
+					},
 				},
-			}
-
-			resp, err := server.ApplyResourceChange(context.Background(), testReq)
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			newStateVal, err := msgpack.Unmarshal(resp.NewState.MsgPack, schema.ImpliedType())
-			if err != nil {
-				t.Fatal(err)
-			}
-
-			id := newStateVal.GetAttr("id").AsString()
-			if id != "baz" {
-				t.Fatalf("incorrect final state: %#v\n", newStateVal)
-			}
-
-			//nolint:staticcheck // explicitly for this SDK
-			if testCase.ExpectedUnsafeLegacyTypeSystem != resp.UnsafeToUseLegacyTypeSystem {
-				//nolint:staticcheck // explicitly for this SDK
-				t.Fatalf("expected UnsafeLegacyTypeSystem %t, got: %t", testCase.ExpectedUnsafeLegacyTypeSystem, resp.UnsafeToUseLegacyTypeSystem)
-			}
-		})
-	}
-}
-
-func TestImportResourceState(t *testing.T) {
-	t.Parallel()
-
-	testCases := map[string]struct {
-		server   *GRPCProviderServer
-		req      *tfprotov5.ImportResourceStateRequest
-		expected *tfprotov5.ImportResourceStateResponse
-	}{
-		"basic-import": {
+			},
+		},
+		"import-identity": {
 			server: NewGRPCProviderServer(&Provider{
 				ResourcesMap: map[string]*Resource{
 					"test": {
@@ -7677,13 +14526,43 @@ func TestImportResourceState(t *testing.T) {
 								Computed: true,
 							},
 						},
+						Identity: &ResourceIdentity{
+							Version: 1,
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{
+									"region": {
+										Type:              TypeString,
+										RequiredForImport: true,
+									},
+									"name": {
+										Type:              TypeString,
+										RequiredForImport: true,
+									},
+								}
+							},
+						},
 						Importer: &ResourceImporter{
-							StateContext: func(ctx context.Context, d *ResourceData, meta interface{}) ([]*ResourceData, error) {
+							IdentityContext: func(ctx context.Context, d *ResourceData, meta interface{}) ([]*ResourceData, error) {
 								err := d.Set("test_string", "new-imported-val")
 								if err != nil {
 									return nil, err
 								}
 
+								identity, err := d.Identity()
+								if err != nil {
+									return nil, err
+								}
+
+								err = identity.Set("region", "us-east-1")
+								if err != nil {
+									return nil, err
+								}
+
+								err = identity.Set("name", d.Id())
+								if err != nil {
+									return nil, err
+								}
+
 								return []*ResourceData{d}, nil
 							},
 						},
@@ -7710,6 +14589,20 @@ func TestImportResourceState(t *testing.T) {
 								}),
 							),
 						},
+						Identity: &tfprotov5.ResourceIdentityData{
+							IdentityData: &tfprotov5.DynamicValue{
+								MsgPack: mustMsgpackMarshal(
+									cty.Object(map[string]cty.Type{
+										"region": cty.String,
+										"name":   cty.String,
+									}),
+									cty.ObjectVal(map[string]cty.Value{
+										"region": cty.StringVal("us-east-1"),
+										"name":   cty.StringVal("imported-id"),
+									}),
+								),
+							},
+						},
 						Private: []byte(`{"schema_version":"1"}`),
 					},
 				},
@@ -8539,24 +15432,142 @@ func TestReadDataSource(t *testing.T) {
 				},
 			},
 			expected: &tfprotov5.ReadDataSourceResponse{
-				State: &tfprotov5.DynamicValue{
-					MsgPack: mustMsgpackMarshal(
-						cty.Object(map[string]cty.Type{
-							"id":        cty.String,
-							"test":      cty.String,
-							"test_bool": cty.Bool,
-						}),
-						cty.UnknownVal(
-							cty.Object(map[string]cty.Type{
-								"id":        cty.String,
-								"test":      cty.String,
-								"test_bool": cty.Bool,
-							}),
-						),
-					),
-				},
-				Deferred: &tfprotov5.Deferred{
-					Reason: tfprotov5.DeferredReasonProviderConfigUnknown,
+				State: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":        cty.String,
+							"test":      cty.String,
+							"test_bool": cty.Bool,
+						}),
+						cty.UnknownVal(
+							cty.Object(map[string]cty.Type{
+								"id":        cty.String,
+								"test":      cty.String,
+								"test_bool": cty.Bool,
+							}),
+						),
+					),
+				},
+				Deferred: &tfprotov5.Deferred{
+					Reason: tfprotov5.DeferredReasonProviderConfigUnknown,
+				},
+			},
+		},
+		"SetDeferred-deferral-allowed": {
+			server: NewGRPCProviderServer(&Provider{
+				DataSourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 1,
+						Schema: map[string]*Schema{
+							"test": {
+								Type:     TypeString,
+								Required: true,
+							},
+							"test_bool": {
+								Type:     TypeBool,
+								Computed: true,
+							},
+						},
+						ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+							SetDeferred(ctx, &Deferred{
+								Reason: DeferredReasonAbsentPrereq,
+							})
+							return nil
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.ReadDataSourceRequest{
+				ClientCapabilities: &tfprotov5.ReadDataSourceClientCapabilities{
+					DeferralAllowed: true,
+				},
+				TypeName: "test",
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":        cty.String,
+							"test":      cty.String,
+							"test_bool": cty.Bool,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":        cty.NullVal(cty.String),
+							"test":      cty.StringVal("test-string"),
+							"test_bool": cty.NullVal(cty.Bool),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.ReadDataSourceResponse{
+				State: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":        cty.String,
+							"test":      cty.String,
+							"test_bool": cty.Bool,
+						}),
+						cty.UnknownVal(
+							cty.Object(map[string]cty.Type{
+								"id":        cty.String,
+								"test":      cty.String,
+								"test_bool": cty.Bool,
+							}),
+						),
+					),
+				},
+				Deferred: &tfprotov5.Deferred{
+					Reason: tfprotov5.DeferredReasonAbsentPrereq,
+				},
+			},
+		},
+		"SetDeferred-deferral-not-allowed": {
+			server: NewGRPCProviderServer(&Provider{
+				DataSourcesMap: map[string]*Resource{
+					"test": {
+						SchemaVersion: 1,
+						Schema: map[string]*Schema{
+							"test": {
+								Type:     TypeString,
+								Required: true,
+							},
+							"test_bool": {
+								Type:     TypeBool,
+								Computed: true,
+							},
+						},
+						ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+							SetDeferred(ctx, &Deferred{
+								Reason: DeferredReasonAbsentPrereq,
+							})
+							return nil
+						},
+					},
+				},
+			}),
+			req: &tfprotov5.ReadDataSourceRequest{
+				TypeName: "test",
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(
+						cty.Object(map[string]cty.Type{
+							"id":        cty.String,
+							"test":      cty.String,
+							"test_bool": cty.Bool,
+						}),
+						cty.ObjectVal(map[string]cty.Value{
+							"id":        cty.NullVal(cty.String),
+							"test":      cty.StringVal("test-string"),
+							"test_bool": cty.NullVal(cty.Bool),
+						}),
+					),
+				},
+			},
+			expected: &tfprotov5.ReadDataSourceResponse{
+				Diagnostics: []*tfprotov5.Diagnostic{
+					{
+						Severity: tfprotov5.DiagnosticSeverityError,
+						Summary:  "Invalid Deferred Data Source Response",
+						Detail: "Data source returned a deferred response but the Terraform request " +
+							"did not indicate support for deferred actions. This is an issue with the provider and should be reported to the provider developers.",
+					},
 				},
 			},
 		},
@@ -8590,11 +15601,12 @@ func TestReadDataSource(t *testing.T) {
 
 func TestPrepareProviderConfig(t *testing.T) {
 	for _, tc := range []struct {
-		Name         string
-		Schema       map[string]*Schema
-		ConfigVal    cty.Value
-		ExpectError  string
-		ExpectConfig cty.Value
+		Name          string
+		Schema        map[string]*Schema
+		ConfigVal     cty.Value
+		ExpectError   string
+		ExpectWarning string
+		ExpectConfig  cty.Value
 	}{
 		{
 			Name: "test prepare",
@@ -8645,6 +15657,61 @@ func TestPrepareProviderConfig(t *testing.T) {
 				"foo": cty.StringVal("defaultfunc"),
 			}),
 		},
+		{
+			Name: "test defaultcontextfunc",
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:     TypeString,
+					Optional: true,
+					DefaultContextFunc: func(ctx context.Context) (interface{}, error) {
+						if ctx == nil {
+							return nil, errors.New("missing context")
+						}
+						return "defaultcontextfunc", nil
+					},
+				},
+			},
+			ConfigVal: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.NullVal(cty.String),
+			}),
+			ExpectConfig: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.StringVal("defaultcontextfunc"),
+			}),
+		},
+		{
+			Name: "test defaultcontextfunc with config",
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:     TypeString,
+					Optional: true,
+					DefaultContextFunc: func(ctx context.Context) (interface{}, error) {
+						return "defaultcontextfunc", nil
+					},
+				},
+			},
+			ConfigVal: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.StringVal("bar"),
+			}),
+			ExpectConfig: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.StringVal("bar"),
+			}),
+		},
+		{
+			Name: "test defaultcontextfunc error",
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:     TypeString,
+					Optional: true,
+					DefaultContextFunc: func(ctx context.Context) (interface{}, error) {
+						return nil, errors.New("secret store unavailable")
+					},
+				},
+			},
+			ConfigVal: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.NullVal(cty.String),
+			}),
+			ExpectError: "error getting default for \"foo\": error loading default: secret store unavailable",
+		},
 		{
 			Name: "test default required",
 			Schema: map[string]*Schema{
@@ -8694,6 +15761,24 @@ func TestPrepareProviderConfig(t *testing.T) {
 				"foo": cty.StringVal("true"),
 			}),
 		},
+		{
+			Name: "test incorrect default type with WarnOnCoercion",
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:           TypeString,
+					Optional:       true,
+					Default:        true,
+					WarnOnCoercion: true,
+				},
+			},
+			ConfigVal: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.NullVal(cty.String),
+			}),
+			ExpectConfig: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.StringVal("true"),
+			}),
+			ExpectWarning: "Value converted to a different type",
+		},
 		{
 			Name: "test incorrect default bool type",
 			Schema: map[string]*Schema{
@@ -8710,6 +15795,101 @@ func TestPrepareProviderConfig(t *testing.T) {
 				"foo": cty.False,
 			}),
 		},
+		{
+			Name: "test list default",
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:     TypeList,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+					Default:  []interface{}{"a", "b"},
+				},
+			},
+			ConfigVal: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.NullVal(cty.List(cty.String)),
+			}),
+			ExpectConfig: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.ListVal([]cty.Value{
+					cty.StringVal("a"),
+					cty.StringVal("b"),
+				}),
+			}),
+		},
+		{
+			Name: "test empty list default",
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:     TypeList,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+					Default:  []interface{}{},
+				},
+			},
+			ConfigVal: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.NullVal(cty.List(cty.String)),
+			}),
+			ExpectConfig: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.ListValEmpty(cty.String),
+			}),
+		},
+		{
+			Name: "test set default",
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:     TypeSet,
+					Optional: true,
+					Elem:     &Schema{Type: TypeInt},
+					Default:  []interface{}{1, 2},
+				},
+			},
+			ConfigVal: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.NullVal(cty.Set(cty.Number)),
+			}),
+			ExpectConfig: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.SetVal([]cty.Value{
+					cty.NumberIntVal(1),
+					cty.NumberIntVal(2),
+				}),
+			}),
+		},
+		{
+			Name: "test map default",
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:     TypeMap,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+					Default: map[string]interface{}{
+						"key": "value",
+					},
+				},
+			},
+			ConfigVal: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.NullVal(cty.Map(cty.String)),
+			}),
+			ExpectConfig: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.MapVal(map[string]cty.Value{
+					"key": cty.StringVal("value"),
+				}),
+			}),
+		},
+		{
+			Name: "test list default with configured value",
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:     TypeList,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+					Default:  []interface{}{"a"},
+				},
+			},
+			ConfigVal: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.ListVal([]cty.Value{cty.StringVal("b")}),
+			}),
+			ExpectConfig: cty.ObjectVal(map[string]cty.Value{
+				"foo": cty.ListVal([]cty.Value{cty.StringVal("b")}),
+			}),
+		},
 	} {
 		t.Run(tc.Name, func(t *testing.T) {
 			server := NewGRPCProviderServer(&Provider{
@@ -8750,6 +15930,11 @@ func TestPrepareProviderConfig(t *testing.T) {
 				}
 			}
 
+			if tc.ExpectWarning != "" {
+				AssertDiagnosticCount(t, resp.Diagnostics, 1)
+				AssertDiagnosticContains(t, resp.Diagnostics, tfprotov5.DiagnosticSeverityWarning, tc.ExpectWarning)
+			}
+
 			val, err := msgpack.Unmarshal(resp.PreparedConfig.MsgPack, block.ImpliedType())
 			if err != nil {
 				t.Fatal(err)
@@ -8762,6 +15947,133 @@ func TestPrepareProviderConfig(t *testing.T) {
 	}
 }
 
+func TestPrepareProviderConfig_validateProviderConfig(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		config   cty.Value
+		expected []*tfprotov5.Diagnostic
+	}{
+		"valid": {
+			config: cty.ObjectVal(map[string]cty.Value{
+				"region":   cty.StringVal("us-east-1"),
+				"endpoint": cty.NullVal(cty.String),
+			}),
+		},
+		"warning": {
+			config: cty.ObjectVal(map[string]cty.Value{
+				"region":   cty.StringVal("legacy"),
+				"endpoint": cty.NullVal(cty.String),
+			}),
+			expected: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityWarning,
+					Summary:   "Legacy region",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("region"),
+				},
+			},
+		},
+		"error": {
+			config: cty.ObjectVal(map[string]cty.Value{
+				"region":   cty.StringVal("us-east-1"),
+				"endpoint": cty.StringVal("https://example.com"),
+			}),
+			expected: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "Conflicting configuration",
+					Detail:    "endpoint cannot be set with region us-east-1",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("endpoint"),
+				},
+			},
+		},
+		"schema validation error": {
+			config: cty.ObjectVal(map[string]cty.Value{
+				"region":   cty.StringVal(""),
+				"endpoint": cty.NullVal(cty.String),
+			}),
+			expected: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "region must not be empty",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("region"),
+				},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := &Provider{
+				Schema: map[string]*Schema{
+					"region": {
+						Type:     TypeString,
+						Required: true,
+						ValidateDiagFunc: func(v interface{}, path cty.Path) diag.Diagnostics {
+							if v.(string) == "" {
+								return diag.Diagnostics{
+									{
+										Severity:      diag.Error,
+										Summary:       "region must not be empty",
+										AttributePath: path,
+									},
+								}
+							}
+
+							return nil
+						},
+					},
+					"endpoint": {
+						Type:     TypeString,
+						Optional: true,
+					},
+				},
+				ValidateProviderConfig: func(ctx context.Context, req ValidateProviderConfigRequest, resp *ValidateProviderConfigResponse) {
+					if req.ResourceData.Get("region").(string) == "" {
+						t.Error("ValidateProviderConfig called after schema validation error")
+					}
+
+					if req.ResourceData.Get("region") == "legacy" {
+						resp.Diagnostics = append(resp.Diagnostics, diag.Diagnostic{
+							Severity:      diag.Warning,
+							Summary:       "Legacy region",
+							AttributePath: cty.GetAttrPath("region"),
+						})
+					}
+
+					if !req.RawConfig.GetAttr("endpoint").IsNull() && req.ResourceData.Get("region") == "us-east-1" {
+						resp.Diagnostics = append(resp.Diagnostics, diag.Diagnostic{
+							Severity:      diag.Error,
+							Summary:       "Conflicting configuration",
+							Detail:        "endpoint cannot be set with region us-east-1",
+							AttributePath: cty.GetAttrPath("endpoint"),
+						})
+					}
+				},
+			}
+
+			server := NewGRPCProviderServer(p)
+
+			block := InternalMap(p.Schema).CoreConfigSchema()
+
+			resp, err := server.PrepareProviderConfig(context.Background(), &tfprotov5.PrepareProviderConfigRequest{
+				Config: &tfprotov5.DynamicValue{
+					MsgPack: mustMsgpackMarshal(block.ImpliedType(), testCase.config),
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if diff := cmp.Diff(resp.Diagnostics, testCase.expected); diff != "" {
+				t.Error(diff)
+			}
+		})
+	}
+}
+
 func TestGetSchemaTimeouts(t *testing.T) {
 	r := &Resource{
 		SchemaVersion: 4,
@@ -9697,3 +17009,137 @@ func mustMsgpackUnmarshal(ty cty.Type, b []byte) cty.Value {
 
 	return result
 }
+
+func TestStripResourceModifiers_schemaFunc(t *testing.T) {
+	t.Parallel()
+
+	r := &Resource{
+		SchemaFunc: func() map[string]*Schema {
+			return map[string]*Schema{
+				"foo": {
+					Type:     TypeString,
+					Optional: true,
+					StateFunc: func(v interface{}) string {
+						return v.(string) + "-modified"
+					},
+				},
+			}
+		},
+		CustomizeDiff: func(_ context.Context, _ *ResourceDiff, _ interface{}) error {
+			return nil
+		},
+	}
+
+	// Populate the SchemaFunc memoization before stripping.
+	r.SchemaMap()
+
+	stripped := stripResourceModifiers(r)
+
+	if stripped.CustomizeDiff != nil {
+		t.Fatal("expected CustomizeDiff to be removed")
+	}
+
+	if stripped.SchemaFunc != nil {
+		t.Fatal("expected SchemaFunc to be replaced by Schema")
+	}
+
+	if stripped.SchemaMap()["foo"].StateFunc != nil {
+		t.Fatal("expected StateFunc to be removed from stripped schema")
+	}
+
+	if r.SchemaMap()["foo"].StateFunc == nil {
+		t.Fatal("expected original schema to keep StateFunc")
+	}
+}
+
+// TestStripResourceModifiers_fields ensures that every Resource field is
+// either copied by stripResourceModifiers or explicitly excluded, since the
+// Resource cannot be copied by value.
+func TestStripResourceModifiers_fields(t *testing.T) {
+	t.Parallel()
+
+	excluded := map[string]bool{
+		// Replaced by the stripped Schema.
+		"Schema":     true,
+		"SchemaFunc": true,
+		// Removed by stripResourceModifiers.
+		"CustomizeDiff": true,
+		// SchemaFunc memoization state.
+		"schemaFuncOnce":   true,
+		"schemaFuncSchema": true,
+	}
+
+	r := &Resource{}
+	rv := reflect.ValueOf(r).Elem()
+
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+
+		if excluded[field.Name] {
+			continue
+		}
+
+		if !field.IsExported() {
+			t.Fatalf("unexported Resource field %s must be copied by stripResourceModifiers or excluded in this test", field.Name)
+		}
+
+		rv.Field(i).Set(nonZeroValue(t, field.Type))
+	}
+
+	stripped := reflect.ValueOf(stripResourceModifiers(r)).Elem()
+
+	for i := 0; i < stripped.NumField(); i++ {
+		field := stripped.Type().Field(i)
+
+		if excluded[field.Name] {
+			continue
+		}
+
+		if stripped.Field(i).IsZero() {
+			t.Errorf("Resource field %s is not copied by stripResourceModifiers", field.Name)
+		}
+	}
+}
+
+// nonZeroValue returns a non-zero value of the given type.
+func nonZeroValue(t *testing.T, typ reflect.Type) reflect.Value {
+	t.Helper()
+
+	v := reflect.New(typ).Elem()
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(1)
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(1)
+	case reflect.String:
+		v.SetString("test")
+	case reflect.Func:
+		v.Set(reflect.MakeFunc(typ, func(args []reflect.Value) []reflect.Value {
+			results := make([]reflect.Value, typ.NumOut())
+			for i := range results {
+				results[i] = reflect.Zero(typ.Out(i))
+			}
+
+			return results
+		}))
+	case reflect.Ptr:
+		v.Set(reflect.New(typ.Elem()))
+	case reflect.Slice:
+		v.Set(reflect.MakeSlice(typ, 1, 1))
+	case reflect.Map:
+		v.Set(reflect.MakeMap(typ))
+	case reflect.Struct:
+		for i := 0; i < typ.NumField(); i++ {
+			if typ.Field(i).IsExported() {
+				v.Field(i).Set(nonZeroValue(t, typ.Field(i).Type))
+			}
+		}
+	default:
+		t.Fatalf("unsupported field type %s", typ)
+	}
+
+	return v
+}
@@ -4,10 +4,16 @@
 package schema
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"reflect"
 	"strings"
 	"sync"
+
+	"github.com/hashicorp/go-cty/cty"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/configs/hcl2shim"
 )
 
 type IdentityData struct {
@@ -79,6 +85,79 @@ func (d *IdentityData) Set(key string, value interface{}) error {
 	return err
 }
 
+// GetRaw returns all identity attributes as a single object conforming to
+// the identity schema, including any changes made with Set or SetRaw.
+func (d *IdentityData) GetRaw() cty.Value {
+	val, err := d.getRawValue()
+	if err != nil {
+		if d.panicOnError {
+			panic(err)
+		}
+
+		log.Printf("[ERROR] reading identity: %s", err)
+
+		return cty.NullVal(cty.DynamicPseudoType)
+	}
+
+	return val
+}
+
+// SetRaw replaces all identity attributes with the attributes of the given
+// object, discarding any changes made with Set. An error is returned if the
+// value does not conform to the identity schema, or is null or not wholly
+// known.
+func (d *IdentityData) SetRaw(val cty.Value) error {
+	block, err := identitySchemaBlock(d.schema)
+	if err != nil {
+		return err
+	}
+
+	if val.IsNull() {
+		return errors.New("identity value must not be null")
+	}
+
+	if !val.IsWhollyKnown() {
+		return errors.New("identity value must be wholly known")
+	}
+
+	val, err = block.CoerceValue(val)
+	if err != nil {
+		return fmt.Errorf("identity value does not conform to the identity schema: %w", err)
+	}
+
+	d.once.Do(d.init)
+
+	d.raw = hcl2shim.FlatmapValueFromHCL2(val)
+	d.init()
+
+	return nil
+}
+
+func (d *IdentityData) getRawValue() (cty.Value, error) {
+	block, err := identitySchemaBlock(d.schema)
+	if err != nil {
+		return cty.NilVal, err
+	}
+
+	rawMap := make(map[string]interface{})
+	for k := range d.schema {
+		raw := d.get([]string{k})
+		if raw.Exists && !raw.Computed {
+			rawMap[k] = raw.Value
+			if raw.ValueProcessed != nil {
+				rawMap[k] = raw.ValueProcessed
+			}
+		}
+	}
+
+	w := &MapFieldWriter{Schema: d.schema}
+	if err := w.WriteField(nil, rawMap); err != nil {
+		return cty.NilVal, err
+	}
+
+	return hcl2shim.HCL2ValueFromFlatmap(w.Map(), block.ImpliedType())
+}
+
 func (d *IdentityData) init() {
 	// Initialize the map for storing data set by the user
 	d.setWriter = &MapFieldWriter{Schema: d.schema}
@@ -7,6 +7,8 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/hashicorp/go-cty/cty"
+
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
@@ -667,3 +669,143 @@ func TestIdentityDataSet(t *testing.T) {
 		})
 	}
 }
+
+func TestIdentityDataGetRaw(t *testing.T) {
+	d := &IdentityData{
+		schema: map[string]*Schema{
+			"region": {
+				Type:              TypeString,
+				RequiredForImport: true,
+			},
+			"name": {
+				Type:              TypeString,
+				OptionalForImport: true,
+			},
+			"port": {
+				Type:              TypeInt,
+				OptionalForImport: true,
+			},
+		},
+		raw: map[string]string{
+			"region": "us-east-1",
+			"port":   "80",
+		},
+	}
+
+	if err := d.Set("name", "foo"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := cty.ObjectVal(map[string]cty.Value{
+		"region": cty.StringVal("us-east-1"),
+		"name":   cty.StringVal("foo"),
+		"port":   cty.NumberIntVal(80),
+	})
+
+	if got := d.GetRaw(); !got.RawEquals(expected) {
+		t.Fatalf("expected %#v, got %#v", expected, got)
+	}
+}
+
+func TestIdentityDataSetRaw(t *testing.T) {
+	identitySchema := map[string]*Schema{
+		"region": {
+			Type:              TypeString,
+			RequiredForImport: true,
+		},
+		"name": {
+			Type:              TypeString,
+			OptionalForImport: true,
+		},
+	}
+
+	cases := map[string]struct {
+		Value    cty.Value
+		Expected cty.Value
+		Err      bool
+	}{
+		"valid": {
+			Value: cty.ObjectVal(map[string]cty.Value{
+				"region": cty.StringVal("us-west-2"),
+				"name":   cty.StringVal("bar"),
+			}),
+			Expected: cty.ObjectVal(map[string]cty.Value{
+				"region": cty.StringVal("us-west-2"),
+				"name":   cty.StringVal("bar"),
+			}),
+		},
+		"null attribute": {
+			Value: cty.ObjectVal(map[string]cty.Value{
+				"region": cty.StringVal("us-west-2"),
+				"name":   cty.NullVal(cty.String),
+			}),
+			Expected: cty.ObjectVal(map[string]cty.Value{
+				"region": cty.StringVal("us-west-2"),
+				"name":   cty.NullVal(cty.String),
+			}),
+		},
+		"null": {
+			Value: cty.NullVal(cty.Object(map[string]cty.Type{
+				"region": cty.String,
+				"name":   cty.String,
+			})),
+			Err: true,
+		},
+		"unknown attribute": {
+			Value: cty.ObjectVal(map[string]cty.Value{
+				"region": cty.UnknownVal(cty.String),
+				"name":   cty.StringVal("bar"),
+			}),
+			Err: true,
+		},
+		"unexpected attribute": {
+			Value: cty.ObjectVal(map[string]cty.Value{
+				"region": cty.StringVal("us-west-2"),
+				"zone":   cty.StringVal("a"),
+			}),
+			Err: true,
+		},
+		"wrong type": {
+			Value: cty.StringVal("us-west-2"),
+			Err:   true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			d := &IdentityData{
+				schema: identitySchema,
+				raw: map[string]string{
+					"region": "us-east-1",
+					"name":   "foo",
+				},
+			}
+
+			err := d.SetRaw(tc.Value)
+			if err != nil != tc.Err {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if tc.Err {
+				expected := cty.ObjectVal(map[string]cty.Value{
+					"region": cty.StringVal("us-east-1"),
+					"name":   cty.StringVal("foo"),
+				})
+
+				if got := d.GetRaw(); !got.RawEquals(expected) {
+					t.Fatalf("expected identity to be unchanged, got %#v", got)
+				}
+
+				return
+			}
+
+			if got := d.GetRaw(); !got.RawEquals(tc.Expected) {
+				t.Fatalf("expected %#v, got %#v", tc.Expected, got)
+			}
+
+			if got := d.Get("region"); got != tc.Expected.GetAttr("region").AsString() {
+				t.Fatalf("expected region %q, got %#v", tc.Expected.GetAttr("region").AsString(), got)
+			}
+		})
+	}
+}
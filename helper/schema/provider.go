@@ -12,6 +12,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
@@ -73,14 +74,28 @@ type Provider struct {
 	// and must *not* implement Create, Update or Delete.
 	DataSourcesMap map[string]*Resource
 
+	// EphemeralResourcesMap is the collection of available ephemeral
+	// resources that this provider implements. Ephemeral resources are only
+	// supported in Terraform 1.10 and later.
+	EphemeralResourcesMap map[string]*EphemeralResource
+
 	// ProviderMetaSchema is the schema for the configuration of the meta
 	// information for this provider. If this provider has no meta info,
 	// this can be omitted. This functionality is currently experimental
 	// and subject to change or break without warning; it should only be
 	// used by providers that are collaborating on its use with the
 	// Terraform team.
+	//
+	// Terraform only sends provider_meta values with resource and data
+	// source requests, not when configuring the provider, so the values are
+	// available through ResourceData.GetProviderMeta in CRUD functions.
 	ProviderMetaSchema map[string]*Schema
 
+	// Functions is the collection of provider-defined functions that this
+	// provider implements, keyed by function name. Unlike resources and data
+	// sources, function names are not prefixed with the provider name.
+	Functions map[string]*Function
+
 	// ConfigureFunc is a function for configuring the provider. If the
 	// provider doesn't need to be configured, this can be omitted.
 	//
@@ -103,11 +118,62 @@ type Provider struct {
 	// Terraform sends a cancellation signal.
 	ConfigureProvider func(context.Context, ConfigureProviderRequest, *ConfigureProviderResponse)
 
+	// ValidateProviderConfig is an optional function for validating the
+	// provider configuration beyond what the Schema validates, such as
+	// relationships between attributes. It is called during the
+	// PrepareProviderConfig RPC after the Schema validation succeeds, and
+	// receives both the configuration as a ResourceData and the raw cty
+	// configuration value.
+	//
+	// Configuration values may be unknown during validation, so this
+	// function must not assume that all values are known.
+	ValidateProviderConfig func(context.Context, ValidateProviderConfigRequest, *ValidateProviderConfigResponse)
+
+	// ComputedAttributeProviders is a map of attribute names to functions
+	// which compute the value of that attribute for every managed resource
+	// of this provider which declares a top level Computed attribute with
+	// the same name. This centralizes logic shared across many resources,
+	// such as merging resource tags with provider default tags.
+	//
+	// The functions are called after the resource's own Create, Read, or
+	// Update and the returned value takes precedence over any value the
+	// resource set for the attribute. The returned value must be valid for
+	// the attribute's schema type, otherwise an error is returned to
+	// Terraform.
+	//
+	// The functions are also called during plan. The attribute keeps its
+	// prior value if the function returns that same value and all other
+	// planned values are known, otherwise it is planned as unknown. The
+	// functions should therefore only depend on the resource data and the
+	// provider configuration, so the value returned during apply is
+	// consistent with the plan.
+	ComputedAttributeProviders map[string]ComputedAttributeFunc
+
+	// ExposeRawRequest makes the terraform-plugin-go protocol request which
+	// originated a callback available to that callback through
+	// RawRequestFromContext, such as for providers which proxy requests to
+	// another provider.
+	//
+	// NOTE: This functionality is unstable and intended only for advanced
+	// interoperability use cases. It is not protected by version
+	// compatibility guarantees and may change or be removed without warning.
+	ExposeRawRequest bool
+
+	// RequireDescriptions makes InternalValidate return an error for each
+	// Required attribute or block, including nested ones, of the provider,
+	// resource and data source schemas which has neither a Description nor
+	// a MarkdownDescription. It is disabled by default, since many existing
+	// providers have attributes without descriptions.
+	RequireDescriptions bool
+
 	// configured is enabled after a Configure() call
 	configured bool
 
 	meta interface{}
 
+	// TerraformVersion is the version of Terraform which configured the
+	// provider. It is populated by the ConfigureProvider RPC request and
+	// is empty until then.
 	TerraformVersion string
 
 	// deferralAllowed is populated by the ConfigureProvider RPC request and
@@ -120,6 +186,29 @@ type Provider struct {
 	// providerDeferred is a global deferred response that will be returned automatically
 	// for all resources and data sources associated to this provider server.
 	providerDeferred *Deferred
+
+	// providerDeferredResources and providerDeferredDataSources limit
+	// providerDeferred to the listed resource and data source types. When
+	// both are empty, providerDeferred applies to all types.
+	providerDeferredResources   []string
+	providerDeferredDataSources []string
+}
+
+type ValidateProviderConfigRequest struct {
+	// ResourceData is used to query the attributes of the provider
+	// configuration.
+	ResourceData *ResourceData
+
+	// RawConfig is the raw provider configuration value provided by
+	// Terraform core.
+	RawConfig cty.Value
+}
+
+type ValidateProviderConfigResponse struct {
+	// Diagnostics report errors or warnings related to the provider
+	// configuration. An empty slice indicates success, with no warnings or
+	// errors generated.
+	Diagnostics diag.Diagnostics
 }
 
 type ConfigureProviderRequest struct {
@@ -129,7 +218,8 @@ type ConfigureProviderRequest struct {
 	//
 	// If true: `(schema.ConfigureProviderResponse).Deferred` can be
 	// set to automatically defer all resources and data sources associated
-	// with this provider.
+	// with this provider, or those listed in DeferredResources and
+	// DeferredDataSources.
 	//
 	// If false: `(schema.ConfigureProviderResponse).Deferred`
 	// will return an error diagnostic if set.
@@ -140,6 +230,11 @@ type ConfigureProviderRequest struct {
 
 	// ResourceData is used to query and set the attributes of a resource.
 	ResourceData *ResourceData
+
+	// TerraformVersion is the version of Terraform configuring the provider,
+	// such as "1.11.0". It may be empty if the version is not known, such as
+	// when the provider is configured in unit tests.
+	TerraformVersion string
 }
 
 type ConfigureProviderResponse struct {
@@ -154,7 +249,8 @@ type ConfigureProviderResponse struct {
 	Diagnostics diag.Diagnostics
 
 	// Deferred indicates that Terraform should automatically defer
-	// all resources and data sources for this provider.
+	// all resources and data sources for this provider, unless limited by
+	// DeferredResources or DeferredDataSources.
 	//
 	// This field can only be set if
 	// `(schema.ConfigureProviderRequest).DeferralAllowed` is true.
@@ -162,8 +258,31 @@ type ConfigureProviderResponse struct {
 	// NOTE: This functionality is related to deferred action support, which is currently experimental and is subject
 	// to change or break without warning. It is not protected by version compatibility guarantees.
 	Deferred *Deferred
+
+	// DeferredResources limits Deferred to the managed resource types with
+	// these names. When both DeferredResources and DeferredDataSources are
+	// empty, Deferred applies to all resources and data sources. Otherwise,
+	// types not listed in either field are not deferred.
+	//
+	// NOTE: This functionality is related to deferred action support, which is currently experimental and is subject
+	// to change or break without warning. It is not protected by version compatibility guarantees.
+	DeferredResources []string
+
+	// DeferredDataSources limits Deferred to the data source types with
+	// these names. When both DeferredResources and DeferredDataSources are
+	// empty, Deferred applies to all resources and data sources. Otherwise,
+	// types not listed in either field are not deferred.
+	//
+	// NOTE: This functionality is related to deferred action support, which is currently experimental and is subject
+	// to change or break without warning. It is not protected by version compatibility guarantees.
+	DeferredDataSources []string
 }
 
+// ComputedAttributeFunc computes the value of a Computed attribute for the
+// resource of type typeName. The ResourceData contains the state written by
+// the resource and must not be modified.
+type ComputedAttributeFunc func(ctx context.Context, typeName string, d *ResourceData, meta interface{}) (interface{}, error)
+
 // ConfigureFunc is the function used to configure a Provider.
 //
 // Deprecated: Please use ConfigureContextFunc
@@ -183,67 +302,143 @@ type ConfigureContextFunc func(context.Context, *ResourceData) (interface{}, dia
 // This should be called in a unit test for any provider to verify
 // before release that a provider is properly configured for use with
 // this library.
+//
+// The returned error joins each of the problems found, which are SchemaError
+// values. Use SchemaErrors to get them as a slice instead.
 func (p *Provider) InternalValidate() error {
+	schemaErrs := p.SchemaErrors()
+
+	errs := make([]error, 0, len(schemaErrs))
+	for _, err := range schemaErrs {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// SchemaErrors returns each of the problems found by InternalValidate as a
+// SchemaError, so that tooling can identify the resource, attribute, and
+// kind of each problem. It returns nil if the provider is valid.
+func (p *Provider) SchemaErrors() []SchemaError {
 	if p == nil {
-		return errors.New("provider is nil")
+		return []SchemaError{newSchemaError("", SchemaErrorReasonInvalidProvider, errors.New("provider is nil"))}
 	}
 
 	if p.ConfigureFunc != nil && p.ConfigureContextFunc != nil {
-		return errors.New("ConfigureFunc and ConfigureContextFunc must not both be set")
+		return []SchemaError{newSchemaError("", SchemaErrorReasonInvalidProvider, errors.New("ConfigureFunc and ConfigureContextFunc must not both be set"))}
 	}
 
-	var validationErrors []error
+	var validationErrors []SchemaError
 
 	// Provider schema validation
 	sm := schemaMap(p.Schema)
-	if err := sm.InternalValidate(sm); err != nil {
-		validationErrors = append(validationErrors, err)
+	if err := sm.internalValidate(sm, false, true); err != nil {
+		validationErrors = append(validationErrors, newSchemaError("", SchemaErrorReasonInvalidAttribute, err))
 	}
 
 	if sm.hasWriteOnly() {
-		validationErrors = append(validationErrors, fmt.Errorf("provider schema cannot contain write-only attributes"))
+		validationErrors = append(validationErrors, newSchemaError("", SchemaErrorReasonInvalidAttribute, fmt.Errorf("provider schema cannot contain write-only attributes")))
+	}
+
+	if p.RequireDescriptions {
+		for _, path := range sm.requiredWithoutDescription() {
+			validationErrors = append(validationErrors, SchemaError{
+				AttributePath: path,
+				Reason:        SchemaErrorReasonInvalidAttribute,
+				Err:           fmt.Errorf("%s: Required attributes must have a Description or MarkdownDescription", path),
+			})
+		}
 	}
 
 	// Provider meta schema validation
 	providerMeta := schemaMap(p.ProviderMetaSchema)
 	if providerMeta.hasWriteOnly() {
-		validationErrors = append(validationErrors, fmt.Errorf("provider meta schema cannot contain write-only attributes"))
+		validationErrors = append(validationErrors, newSchemaError("", SchemaErrorReasonInvalidAttribute, fmt.Errorf("provider meta schema cannot contain write-only attributes")))
 	}
 
 	// Provider-specific checks
 	for k := range sm {
 		if isReservedProviderFieldName(k) {
-			return fmt.Errorf("%s is a reserved field name for a provider", k)
+			return []SchemaError{{
+				AttributePath: k,
+				Reason:        SchemaErrorReasonReservedFieldName,
+				Err:           fmt.Errorf("%s is a reserved field name for a provider", k),
+			}}
 		}
 	}
 
 	for k, r := range p.ResourcesMap {
-		if r.Identity != nil {
-			if err := r.Identity.InternalIdentityValidate(); err != nil {
-				validationErrors = append(validationErrors, fmt.Errorf("resource %s identity: %s", k, err))
-			}
+		if k == "" {
+			validationErrors = append(validationErrors, newSchemaError(k, SchemaErrorReasonInvalidTypeName, errors.New("resource type name must not be empty")))
 		}
+
 		if err := r.InternalValidate(nil, true); err != nil {
-			validationErrors = append(validationErrors, fmt.Errorf("resource %s: %s", k, err))
+			validationErrors = append(validationErrors, newSchemaError(k, SchemaErrorReasonInvalidResource, fmt.Errorf("resource %s: %w", k, err)))
+		}
+
+		if len(r.ValidateRawDataSourceConfigFuncs) > 0 {
+			validationErrors = append(validationErrors, newSchemaError(k, SchemaErrorReasonInvalidResource, fmt.Errorf("resource %s cannot contain ValidateRawDataSourceConfigFuncs", k)))
+		}
+
+		if p.RequireDescriptions {
+			for _, path := range schemaMap(r.SchemaMap()).requiredWithoutDescription() {
+				validationErrors = append(validationErrors, SchemaError{
+					ResourceType:  k,
+					AttributePath: path,
+					Reason:        SchemaErrorReasonInvalidAttribute,
+					Err:           fmt.Errorf("resource %s: %s: Required attributes must have a Description or MarkdownDescription", k, path),
+				})
+			}
 		}
 	}
 
 	for k, r := range p.DataSourcesMap {
+		if k == "" {
+			validationErrors = append(validationErrors, newSchemaError(k, SchemaErrorReasonInvalidTypeName, errors.New("data source type name must not be empty")))
+		}
+
 		if err := r.InternalValidate(nil, false); err != nil {
-			validationErrors = append(validationErrors, fmt.Errorf("data source %s: %s", k, err))
+			validationErrors = append(validationErrors, newSchemaError(k, SchemaErrorReasonInvalidResource, fmt.Errorf("data source %s: %w", k, err)))
 		}
 
 		if len(r.ValidateRawResourceConfigFuncs) > 0 {
-			validationErrors = append(validationErrors, fmt.Errorf("data source %s cannot contain ValidateRawResourceConfigFuncs", k))
+			validationErrors = append(validationErrors, newSchemaError(k, SchemaErrorReasonInvalidResource, fmt.Errorf("data source %s cannot contain ValidateRawResourceConfigFuncs", k)))
 		}
 
 		dataSourceSchema := schemaMap(r.SchemaMap())
 		if dataSourceSchema.hasWriteOnly() {
-			validationErrors = append(validationErrors, fmt.Errorf("data source %s cannot contain write-only attributes", k))
+			validationErrors = append(validationErrors, newSchemaError(k, SchemaErrorReasonInvalidAttribute, fmt.Errorf("data source %s cannot contain write-only attributes", k)))
+		}
+
+		if p.RequireDescriptions {
+			for _, path := range dataSourceSchema.requiredWithoutDescription() {
+				validationErrors = append(validationErrors, SchemaError{
+					ResourceType:  k,
+					AttributePath: path,
+					Reason:        SchemaErrorReasonInvalidAttribute,
+					Err:           fmt.Errorf("data source %s: %s: Required attributes must have a Description or MarkdownDescription", k, path),
+				})
+			}
 		}
 	}
 
-	return errors.Join(validationErrors...)
+	for k, r := range p.EphemeralResourcesMap {
+		if k == "" {
+			validationErrors = append(validationErrors, newSchemaError(k, SchemaErrorReasonInvalidTypeName, errors.New("ephemeral resource type name must not be empty")))
+		}
+
+		if err := r.InternalValidate(); err != nil {
+			validationErrors = append(validationErrors, newSchemaError(k, SchemaErrorReasonInvalidResource, fmt.Errorf("ephemeral resource %s: %w", k, err)))
+		}
+	}
+
+	for name, f := range p.Functions {
+		if err := f.InternalValidate(); err != nil {
+			validationErrors = append(validationErrors, newSchemaError("", SchemaErrorReasonInvalidFunction, fmt.Errorf("function %s: %w", name, err)))
+		}
+	}
+
+	return validationErrors
 }
 
 func isReservedProviderFieldName(name string) bool {
@@ -268,6 +463,58 @@ func (p *Provider) SetMeta(v interface{}) {
 	p.meta = v
 }
 
+// RegisterResource adds the given managed resource to ResourcesMap with the
+// given type name. Unlike assigning to ResourcesMap directly, an error is
+// returned if the type name is empty or already registered, which can catch
+// mistakes when ResourcesMap is built programmatically.
+func (p *Provider) RegisterResource(name string, r *Resource) error {
+	if name == "" {
+		return errors.New("resource type name must not be empty")
+	}
+
+	if r == nil {
+		return fmt.Errorf("resource %s is nil", name)
+	}
+
+	if _, ok := p.ResourcesMap[name]; ok {
+		return fmt.Errorf("resource %s is already registered", name)
+	}
+
+	if p.ResourcesMap == nil {
+		p.ResourcesMap = make(map[string]*Resource)
+	}
+
+	p.ResourcesMap[name] = r
+
+	return nil
+}
+
+// RegisterDataSource adds the given data source to DataSourcesMap with the
+// given type name. Unlike assigning to DataSourcesMap directly, an error is
+// returned if the type name is empty or already registered, which can catch
+// mistakes when DataSourcesMap is built programmatically.
+func (p *Provider) RegisterDataSource(name string, r *Resource) error {
+	if name == "" {
+		return errors.New("data source type name must not be empty")
+	}
+
+	if r == nil {
+		return fmt.Errorf("data source %s is nil", name)
+	}
+
+	if _, ok := p.DataSourcesMap[name]; ok {
+		return fmt.Errorf("data source %s is already registered", name)
+	}
+
+	if p.DataSourcesMap == nil {
+		p.DataSourcesMap = make(map[string]*Resource)
+	}
+
+	p.DataSourcesMap[name] = r
+
+	return nil
+}
+
 // GetSchema returns the config schema for the main provider
 // configuration, as would appear in a "provider" block in the
 // configuration files.
@@ -322,6 +569,35 @@ func (p *Provider) Validate(c *terraform.ResourceConfig) diag.Diagnostics {
 	return schemaMap(p.Schema).Validate(c)
 }
 
+// validateProviderConfig calls ValidateProviderConfig with the given
+// configuration.
+func (p *Provider) validateProviderConfig(ctx context.Context, c *terraform.ResourceConfig, rawConfig cty.Value) diag.Diagnostics {
+	sm := schemaMap(p.Schema)
+
+	// Get a ResourceData for this configuration, as in Configure.
+	diff, err := sm.Diff(ctx, nil, c, nil, p.meta, true)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	data, err := sm.Data(nil, diff)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	data.config = c
+
+	req := ValidateProviderConfigRequest{
+		ResourceData: data,
+		RawConfig:    rawConfig,
+	}
+	resp := &ValidateProviderConfigResponse{}
+
+	p.ValidateProviderConfig(ctx, req, resp)
+
+	return resp.Diagnostics
+}
+
 // ValidateResource is called once at the beginning with the raw
 // configuration (no interpolation done) and can return diagnostics.
 //
@@ -405,8 +681,9 @@ func (p *Provider) Configure(ctx context.Context, c *terraform.ResourceConfig) d
 
 	if p.ConfigureProvider != nil {
 		req := ConfigureProviderRequest{
-			DeferralAllowed: p.deferralAllowed,
-			ResourceData:    data,
+			DeferralAllowed:  p.deferralAllowed,
+			ResourceData:     data,
+			TerraformVersion: p.TerraformVersion,
 		}
 		resp := ConfigureProviderResponse{}
 
@@ -419,6 +696,8 @@ func (p *Provider) Configure(ctx context.Context, c *terraform.ResourceConfig) d
 
 		p.meta = resp.Meta
 		p.providerDeferred = resp.Deferred
+		p.providerDeferredResources = resp.DeferredResources
+		p.providerDeferredDataSources = resp.DeferredDataSources
 	}
 
 	p.configured = true
@@ -426,6 +705,32 @@ func (p *Provider) Configure(ctx context.Context, c *terraform.ResourceConfig) d
 	return diags
 }
 
+// resourceDeferred returns the provider deferred response for the given
+// managed resource type, or nil if the resource type is not deferred.
+func (p *Provider) resourceDeferred(typeName string) *Deferred {
+	if !p.providerDeferredScoped() || deferredTypeName(typeName, p.providerDeferredResources) {
+		return p.providerDeferred
+	}
+
+	return nil
+}
+
+// dataSourceDeferred returns the provider deferred response for the given
+// data source type, or nil if the data source type is not deferred.
+func (p *Provider) dataSourceDeferred(typeName string) *Deferred {
+	if !p.providerDeferredScoped() || deferredTypeName(typeName, p.providerDeferredDataSources) {
+		return p.providerDeferred
+	}
+
+	return nil
+}
+
+// providerDeferredScoped returns true if the provider deferred response is
+// limited to specific resource or data source types.
+func (p *Provider) providerDeferredScoped() bool {
+	return len(p.providerDeferredResources) > 0 || len(p.providerDeferredDataSources) > 0
+}
+
 // Resources returns all the available resource types that this provider
 // knows how to manage.
 func (p *Provider) Resources() []terraform.ResourceType {
@@ -487,18 +792,22 @@ func (p *Provider) ImportState(
 		return nil, fmt.Errorf("resource %s doesn't support import", info.Type)
 	}
 
-	// Create the data
+	// Create the data, including the identity schema so that the import
+	// function can populate identity attributes
 	data := r.Data(nil)
+	data.identitySchema = r.Identity.SchemaMap()
 	data.SetId(id)
 	data.SetType(info.Type)
 
 	// Call the import function
 	results := []*ResourceData{data}
-	if r.Importer.State != nil || r.Importer.StateContext != nil {
+	if r.Importer.IdentityContext != nil || r.Importer.State != nil || r.Importer.StateContext != nil {
 		var err error
 		logging.HelperSchemaTrace(ctx, "Calling downstream")
 
-		if r.Importer.StateContext != nil {
+		if r.Importer.IdentityContext != nil {
+			results, err = r.Importer.IdentityContext(ctx, data, p.meta)
+		} else if r.Importer.StateContext != nil {
 			results, err = r.Importer.StateContext(ctx, data, p.meta)
 		} else {
 			results, err = r.Importer.State(data, p.meta)
@@ -569,6 +878,23 @@ func (p *Provider) ValidateDataSource(
 	return r.Validate(c)
 }
 
+// ValidateEphemeralResource validates the ephemeral resource configuration
+// against the schema.
+func (p *Provider) ValidateEphemeralResource(
+	t string, c *terraform.ResourceConfig) diag.Diagnostics {
+	r, ok := p.EphemeralResourcesMap[t]
+	if !ok {
+		return []diag.Diagnostic{
+			{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Provider doesn't support ephemeral resource: %s", t),
+			},
+		}
+	}
+
+	return r.Validate(c)
+}
+
 // DataSources returns all of the available data sources that this
 // provider implements.
 func (p *Provider) DataSources() []terraform.DataSource {
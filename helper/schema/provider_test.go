@@ -5,6 +5,7 @@ package schema
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"reflect"
@@ -13,10 +14,12 @@ import (
 	"time"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/hashicorp/go-cty/cty"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/configs/configschema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/configs/hcl2shim"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/meta"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
@@ -302,6 +305,32 @@ func TestProviderConfigure(t *testing.T) {
 				"test": cty.NullVal(cty.String),
 			}),
 		},
+		"ConfigureContextFunc-Get-DefaultContextFunc": {
+			P: &Provider{
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Optional: true,
+						DefaultContextFunc: func(ctx context.Context) (interface{}, error) {
+							return "default-value", nil
+						},
+					},
+				},
+				ConfigureContextFunc: func(ctx context.Context, d *ResourceData) (interface{}, diag.Diagnostics) {
+					got := d.Get("test").(string)
+					expected := "default-value"
+
+					if got != expected {
+						return nil, diag.Errorf("unexpected Get difference: expected: %s, got: %s", expected, got)
+					}
+
+					return nil, nil
+				},
+			},
+			Config: cty.ObjectVal(map[string]cty.Value{
+				"test": cty.NullVal(cty.String),
+			}),
+		},
 		"ConfigureFunc-Get-null-other-value": {
 			P: &Provider{
 				Schema: map[string]*Schema{
@@ -1953,6 +1982,108 @@ func TestProviderValidate_attributePath(t *testing.T) {
 				},
 			},
 		},
+		{ // validate path automatically built, across nested blocks
+			P: &Provider{
+				Schema: map[string]*Schema{
+					"foo": {
+						Type:     TypeList,
+						Required: true,
+						Elem: &Resource{
+							Schema: map[string]*Schema{
+								"bar": {
+									Type:     TypeList,
+									Required: true,
+									Elem: &Resource{
+										Schema: map[string]*Schema{
+											"baz": {
+												Type:     TypeString,
+												Required: true,
+												ValidateDiagFunc: func(v interface{}, path cty.Path) diag.Diagnostics {
+													return diag.Diagnostics{{Severity: diag.Error}}
+												},
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			Config: map[string]interface{}{
+				"foo": []interface{}{
+					map[string]interface{}{
+						"bar": []interface{}{
+							map[string]interface{}{
+								"baz": "qux",
+							},
+						},
+					},
+				},
+			},
+			ExpectedDiags: diag.Diagnostics{
+				{
+					Severity:      diag.Error,
+					AttributePath: cty.Path{cty.GetAttrStep{Name: "foo"}, cty.IndexStep{Key: cty.NumberIntVal(0)}, cty.GetAttrStep{Name: "bar"}, cty.IndexStep{Key: cty.NumberIntVal(0)}, cty.GetAttrStep{Name: "baz"}},
+				},
+			},
+		},
+		{ // unknown nested values are not validated
+			P: &Provider{
+				Schema: map[string]*Schema{
+					"foo": {
+						Type:     TypeList,
+						Required: true,
+						Elem: &Resource{
+							Schema: map[string]*Schema{
+								"bar": {
+									Type:     TypeString,
+									Required: true,
+									ValidateDiagFunc: func(v interface{}, path cty.Path) diag.Diagnostics {
+										return diag.Diagnostics{{Severity: diag.Error}}
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			Config: map[string]interface{}{
+				"foo": []interface{}{
+					map[string]interface{}{
+						"bar": hcl2shim.UnknownVariableValue,
+					},
+				},
+			},
+			ExpectedDiags: nil,
+		},
+		{ // empty blocks are not validated
+			P: &Provider{
+				Schema: map[string]*Schema{
+					"foo": {
+						Type:     TypeList,
+						Optional: true,
+						Elem: &Resource{
+							Schema: map[string]*Schema{
+								"bar": {
+									Type:     TypeString,
+									Optional: true,
+									ValidateDiagFunc: func(v interface{}, path cty.Path) diag.Diagnostics {
+										return diag.Diagnostics{{Severity: diag.Error}}
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			Config: map[string]interface{}{
+				"foo": []interface{}{
+					map[string]interface{}{},
+				},
+			},
+			ExpectedDiags: nil,
+		},
 	}
 
 	for i, tc := range cases {
@@ -2284,6 +2415,162 @@ func TestProviderMeta(t *testing.T) {
 	}
 }
 
+func TestProviderRegisterResource(t *testing.T) {
+	t.Parallel()
+
+	existing := &Resource{}
+
+	testCases := map[string]struct {
+		name        string
+		resource    *Resource
+		expectedErr string
+	}{
+		"valid": {
+			name:     "test_new",
+			resource: &Resource{},
+		},
+		"empty-name": {
+			name:        "",
+			resource:    &Resource{},
+			expectedErr: "resource type name must not be empty",
+		},
+		"nil-resource": {
+			name:        "test_new",
+			expectedErr: "resource test_new is nil",
+		},
+		"duplicate": {
+			name:        "test_existing",
+			resource:    &Resource{},
+			expectedErr: "resource test_existing is already registered",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := &Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_existing": existing,
+				},
+			}
+
+			err := p.RegisterResource(testCase.name, testCase.resource)
+
+			if testCase.expectedErr != "" {
+				if err == nil || err.Error() != testCase.expectedErr {
+					t.Fatalf("expected error %q, got: %v", testCase.expectedErr, err)
+				}
+
+				if p.ResourcesMap["test_existing"] != existing {
+					t.Fatal("expected existing resource to be unchanged")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if p.ResourcesMap[testCase.name] != testCase.resource {
+				t.Fatalf("expected resource %s to be registered", testCase.name)
+			}
+		})
+	}
+}
+
+func TestProviderRegisterResource_nilMap(t *testing.T) {
+	p := &Provider{}
+	r := &Resource{}
+
+	if err := p.RegisterResource("test", r); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if p.ResourcesMap["test"] != r {
+		t.Fatal("expected resource to be registered")
+	}
+}
+
+func TestProviderRegisterDataSource(t *testing.T) {
+	t.Parallel()
+
+	existing := &Resource{}
+
+	testCases := map[string]struct {
+		name        string
+		dataSource  *Resource
+		expectedErr string
+	}{
+		"valid": {
+			name:       "test_new",
+			dataSource: &Resource{},
+		},
+		"empty-name": {
+			name:        "",
+			dataSource:  &Resource{},
+			expectedErr: "data source type name must not be empty",
+		},
+		"nil-data-source": {
+			name:        "test_new",
+			expectedErr: "data source test_new is nil",
+		},
+		"duplicate": {
+			name:        "test_existing",
+			dataSource:  &Resource{},
+			expectedErr: "data source test_existing is already registered",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			p := &Provider{
+				DataSourcesMap: map[string]*Resource{
+					"test_existing": existing,
+				},
+			}
+
+			err := p.RegisterDataSource(testCase.name, testCase.dataSource)
+
+			if testCase.expectedErr != "" {
+				if err == nil || err.Error() != testCase.expectedErr {
+					t.Fatalf("expected error %q, got: %v", testCase.expectedErr, err)
+				}
+
+				if p.DataSourcesMap["test_existing"] != existing {
+					t.Fatal("expected existing data source to be unchanged")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if p.DataSourcesMap[testCase.name] != testCase.dataSource {
+				t.Fatalf("expected data source %s to be registered", testCase.name)
+			}
+		})
+	}
+}
+
+func TestProviderRegisterDataSource_nilMap(t *testing.T) {
+	p := &Provider{}
+	r := &Resource{}
+
+	if err := p.RegisterDataSource("test", r); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if p.DataSourcesMap["test"] != r {
+		t.Fatal("expected data source to be registered")
+	}
+}
+
 func TestProvider_InternalValidate(t *testing.T) {
 	cases := map[string]struct {
 		P           *Provider
@@ -2315,6 +2602,57 @@ func TestProvider_InternalValidate(t *testing.T) {
 			},
 			ExpectedErr: nil,
 		},
+		"Empty resource type name returns an error": {
+			P: &Provider{
+				ResourcesMap: map[string]*Resource{
+					"": {
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeString,
+								Required: true,
+								ForceNew: true,
+							},
+						},
+						Create: func(d *ResourceData, meta interface{}) error { return nil },
+						Read:   func(d *ResourceData, meta interface{}) error { return nil },
+						Delete: func(d *ResourceData, meta interface{}) error { return nil },
+					},
+				},
+			},
+			ExpectedErr: fmt.Errorf("resource type name must not be empty"),
+		},
+		"Empty data source type name returns an error": {
+			P: &Provider{
+				DataSourcesMap: map[string]*Resource{
+					"": {
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeString,
+								Required: true,
+							},
+						},
+						Read: func(d *ResourceData, meta interface{}) error { return nil },
+					},
+				},
+			},
+			ExpectedErr: fmt.Errorf("data source type name must not be empty"),
+		},
+		"Empty ephemeral resource type name returns an error": {
+			P: &Provider{
+				EphemeralResourcesMap: map[string]*EphemeralResource{
+					"": {
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeString,
+								Required: true,
+							},
+						},
+						OpenContext: func(ctx context.Context, req EphemeralOpenRequest, resp *EphemeralOpenResponse) {},
+					},
+				},
+			},
+			ExpectedErr: fmt.Errorf("ephemeral resource type name must not be empty"),
+		},
 		"Reserved provider fields returns an error": { //
 			P: &Provider{
 				Schema: map[string]*Schema{
@@ -2476,6 +2814,174 @@ func TestProvider_InternalValidate(t *testing.T) {
 			},
 			ExpectedErr: nil,
 		},
+		"Data source with ValidateRawDataSourceConfigFuncs returns no errors": {
+			P: &Provider{
+				DataSourcesMap: map[string]*Resource{
+					"data-foo": {
+						ValidateRawDataSourceConfigFuncs: []ValidateDataSourceConfigFunc{
+							func(ctx context.Context, req ValidateDataSourceConfigFuncRequest, resp *ValidateDataSourceConfigFuncResponse) {
+
+							},
+						},
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+					},
+				},
+			},
+			ExpectedErr: nil,
+		},
+		"Resource with ValidateRawDataSourceConfigFuncs returns an error": {
+			P: &Provider{
+				ResourcesMap: map[string]*Resource{
+					"resource-foo": {
+						ValidateRawDataSourceConfigFuncs: []ValidateDataSourceConfigFunc{
+							func(ctx context.Context, req ValidateDataSourceConfigFuncRequest, resp *ValidateDataSourceConfigFuncResponse) {
+
+							},
+						},
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+					},
+				},
+			},
+			ExpectedErr: fmt.Errorf("resource resource-foo cannot contain ValidateRawDataSourceConfigFuncs"),
+		},
+		"Resource with WriteOnly and Computed attribute returns an error": {
+			P: &Provider{
+				ResourcesMap: map[string]*Resource{
+					"resource-foo": {
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:      TypeString,
+								Optional:  true,
+								Computed:  true,
+								WriteOnly: true,
+							},
+						},
+					},
+				},
+			},
+			ExpectedErr: fmt.Errorf("resource resource-foo: foo: WriteOnly cannot be set with Computed"),
+		},
+		"Resource with WriteOnly attribute in Computed block returns an error": {
+			P: &Provider{
+				ResourcesMap: map[string]*Resource{
+					"resource-foo": {
+						Schema: map[string]*Schema{
+							"block": {
+								Type:     TypeList,
+								Computed: true,
+								Elem: &Resource{
+									Schema: map[string]*Schema{
+										"foo": {
+											Type:      TypeString,
+											Optional:  true,
+											WriteOnly: true,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			ExpectedErr: fmt.Errorf("resource resource-foo: block: Block types with Computed set to true cannot contain WriteOnly attributes"),
+		},
+		"Resource with WriteOnly attribute in Optional and Computed block returns an error": {
+			P: &Provider{
+				ResourcesMap: map[string]*Resource{
+					"resource-foo": {
+						Schema: map[string]*Schema{
+							"block": {
+								Type:     TypeList,
+								Optional: true,
+								Computed: true,
+								Elem: &Resource{
+									Schema: map[string]*Schema{
+										"foo": {
+											Type:      TypeString,
+											Optional:  true,
+											WriteOnly: true,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			ExpectedErr: fmt.Errorf("resource resource-foo: block: Block types with Computed set to true cannot contain WriteOnly attributes"),
+		},
+		"Ephemeral resource without OpenContext returns an error": {
+			P: &Provider{
+				EphemeralResourcesMap: map[string]*EphemeralResource{
+					"ephemeral-foo": {
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+					},
+				},
+			},
+			ExpectedErr: fmt.Errorf("ephemeral resource ephemeral-foo: OpenContext must be implemented"),
+		},
+		"Ephemeral resource with write-only attribute returns an error": {
+			P: &Provider{
+				EphemeralResourcesMap: map[string]*EphemeralResource{
+					"ephemeral-foo": {
+						OpenContext: func(ctx context.Context, req EphemeralOpenRequest, resp *EphemeralOpenResponse) {},
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:      TypeString,
+								Optional:  true,
+								WriteOnly: true,
+							},
+						},
+					},
+				},
+			},
+			ExpectedErr: fmt.Errorf("ephemeral resource ephemeral-foo: ephemeral resources cannot contain write-only attributes"),
+		},
+		"Resource with unsupported identity attribute type returns an error": {
+			P: &Provider{
+				ResourcesMap: map[string]*Resource{
+					"foo": {
+						CreateContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics { return nil },
+						ReadContext:   func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics { return nil },
+						DeleteContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics { return nil },
+						Schema: map[string]*Schema{
+							"name": {
+								Type:     TypeString,
+								Required: true,
+								ForceNew: true,
+							},
+						},
+						Identity: &ResourceIdentity{
+							SchemaFunc: func() map[string]*Schema {
+								return map[string]*Schema{
+									"tags": {
+										Type:              TypeSet,
+										RequiredForImport: true,
+										Elem:              &Schema{Type: TypeString},
+									},
+								}
+							},
+						},
+					},
+				},
+			},
+			ExpectedErr: fmt.Errorf("resource foo: identity: tags: TypeSet is not valid for resource identity"),
+		},
 	}
 
 	for name, tc := range cases {
@@ -2496,6 +3002,254 @@ func TestProvider_InternalValidate(t *testing.T) {
 	}
 }
 
+func TestProvider_SchemaErrors(t *testing.T) {
+	t.Parallel()
+
+	validResource := func(schema map[string]*Schema) *Resource {
+		return &Resource{
+			Schema:        schema,
+			CreateContext: NoopContext,
+			ReadContext:   NoopContext,
+			DeleteContext: NoopContext,
+		}
+	}
+
+	testCases := map[string]struct {
+		provider *Provider
+		expected []SchemaError
+	}{
+		"valid": {
+			provider: &Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_resource": validResource(map[string]*Schema{
+						"foo": {
+							Type:     TypeString,
+							Required: true,
+							ForceNew: true,
+						},
+					}),
+				},
+			},
+		},
+		"invalid-nested-attribute": {
+			provider: &Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_resource": validResource(map[string]*Schema{
+						"block": {
+							Type:     TypeList,
+							Required: true,
+							ForceNew: true,
+							Elem: &Resource{
+								Schema: map[string]*Schema{
+									"foo": {
+										Type:     TypeString,
+										Optional: true,
+										Required: true,
+									},
+								},
+							},
+						},
+					}),
+				},
+			},
+			expected: []SchemaError{
+				{
+					ResourceType:  "test_resource",
+					AttributePath: "block.foo",
+					Reason:        SchemaErrorReasonInvalidAttribute,
+				},
+			},
+		},
+		"reserved-field-name": {
+			provider: &Provider{
+				DataSourcesMap: map[string]*Resource{
+					"test_data_source": {
+						Schema: map[string]*Schema{
+							"count": {
+								Type:     TypeInt,
+								Computed: true,
+							},
+						},
+						ReadContext: NoopContext,
+					},
+				},
+			},
+			expected: []SchemaError{
+				{
+					ResourceType:  "test_data_source",
+					AttributePath: "count",
+					Reason:        SchemaErrorReasonReservedFieldName,
+				},
+			},
+		},
+		"invalid-resource": {
+			provider: &Provider{
+				ResourcesMap: map[string]*Resource{
+					"test_resource": {
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeString,
+								Required: true,
+								ForceNew: true,
+							},
+						},
+						CreateContext: NoopContext,
+						DeleteContext: NoopContext,
+					},
+				},
+			},
+			expected: []SchemaError{
+				{
+					ResourceType: "test_resource",
+					Reason:       SchemaErrorReasonInvalidResource,
+				},
+			},
+		},
+		"empty-type-name": {
+			provider: &Provider{
+				ResourcesMap: map[string]*Resource{
+					"": validResource(map[string]*Schema{
+						"foo": {
+							Type:     TypeString,
+							Required: true,
+							ForceNew: true,
+						},
+					}),
+				},
+			},
+			expected: []SchemaError{
+				{
+					Reason: SchemaErrorReasonInvalidTypeName,
+				},
+			},
+		},
+		"require-descriptions": {
+			provider: &Provider{
+				RequireDescriptions: true,
+				Schema: map[string]*Schema{
+					"region": {
+						Type:     TypeString,
+						Required: true,
+					},
+				},
+				ResourcesMap: map[string]*Resource{
+					"test_resource": validResource(map[string]*Schema{
+						"described": {
+							Type:        TypeString,
+							Required:    true,
+							ForceNew:    true,
+							Description: "A described attribute.",
+						},
+						"markdown": {
+							Type:                TypeString,
+							Required:            true,
+							ForceNew:            true,
+							MarkdownDescription: "A `markdown` described attribute.",
+						},
+						"optional": {
+							Type:     TypeString,
+							Optional: true,
+							ForceNew: true,
+						},
+						"block": {
+							Type:        TypeList,
+							Optional:    true,
+							ForceNew:    true,
+							Description: "A described block.",
+							Elem: &Resource{
+								Schema: map[string]*Schema{
+									"foo": {
+										Type:     TypeString,
+										Required: true,
+									},
+								},
+							},
+						},
+					}),
+				},
+				DataSourcesMap: map[string]*Resource{
+					"test_data_source": {
+						Schema: map[string]*Schema{
+							"name": {
+								Type:     TypeString,
+								Required: true,
+							},
+						},
+						ReadContext: NoopContext,
+					},
+				},
+			},
+			expected: []SchemaError{
+				{
+					AttributePath: "region",
+					Reason:        SchemaErrorReasonInvalidAttribute,
+				},
+				{
+					ResourceType:  "test_resource",
+					AttributePath: "block.foo",
+					Reason:        SchemaErrorReasonInvalidAttribute,
+				},
+				{
+					ResourceType:  "test_data_source",
+					AttributePath: "name",
+					Reason:        SchemaErrorReasonInvalidAttribute,
+				},
+			},
+		},
+		"require-descriptions-disabled": {
+			provider: &Provider{
+				Schema: map[string]*Schema{
+					"region": {
+						Type:     TypeString,
+						Required: true,
+					},
+				},
+			},
+		},
+		"invalid-provider": {
+			provider: &Provider{
+				ConfigureFunc:        func(*ResourceData) (interface{}, error) { return nil, nil },
+				ConfigureContextFunc: func(context.Context, *ResourceData) (interface{}, diag.Diagnostics) { return nil, nil },
+			},
+			expected: []SchemaError{
+				{
+					Reason: SchemaErrorReasonInvalidProvider,
+				},
+			},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := testCase.provider.SchemaErrors()
+
+			if diff := cmp.Diff(testCase.expected, got, cmpopts.IgnoreFields(SchemaError{}, "Err")); diff != "" {
+				t.Fatalf("unexpected difference: %s", diff)
+			}
+
+			err := testCase.provider.InternalValidate()
+
+			if len(testCase.expected) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+
+			var schemaErr SchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("expected SchemaError, got: %#v", err)
+			}
+
+			if schemaErr.Error() != got[0].Error() {
+				t.Fatalf("expected error %q, got %q", got[0].Error(), schemaErr.Error())
+			}
+		})
+	}
+}
+
 func TestProviderUserAgentAppendViaEnvVar(t *testing.T) {
 	if oldenv, isSet := os.LookupEnv(uaEnvVar); isSet {
 		//nolint:usetesting
@@ -0,0 +1,158 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package schema
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// RateLimitConfig configures a token bucket rate limit that is applied
+// before every create, read, update, and delete call of a Resource.
+//
+// The limit is shared by all instances of the resource type served by a
+// provider server, regardless of how many operations Terraform runs
+// concurrently.
+type RateLimitConfig struct {
+	// RequestsPerSecond is the rate at which tokens are added to the bucket,
+	// which is the sustained number of calls allowed per second. It must be
+	// greater than zero.
+	RequestsPerSecond float64
+
+	// Burst is the maximum number of tokens in the bucket, which is the
+	// number of calls that can be made at once before the rate applies.
+	// Values less than 1 are treated as 1.
+	Burst int
+}
+
+// rateLimiterKey identifies the rate limiter of a resource or data source
+// type, as a data source may share its type name with a managed resource.
+type rateLimiterKey struct {
+	typeName   string
+	dataSource bool
+}
+
+// rateLimiter is a token bucket rate limiter.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a rateLimiter with a full bucket of tokens.
+func newRateLimiter(config *RateLimitConfig) *rateLimiter {
+	burst := float64(config.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{
+		rate:   config.RequestsPerSecond,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+	}
+}
+
+// reserve takes a token from the bucket and returns how long the caller
+// must wait before the token is available.
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+
+	if l.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// release returns a reserved token to the bucket.
+func (l *rateLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens++
+}
+
+// Wait blocks until a token is available or the context is done, in which
+// case the reserved token is returned to the bucket and the context error
+// is returned.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	wait := l.reserve()
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		l.release()
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// withRateLimiter returns a context in which waitRateLimit waits on l.
+func withRateLimiter(ctx context.Context, l *rateLimiter) context.Context {
+	return context.WithValue(ctx, rateLimiterContextKey, l)
+}
+
+// waitRateLimit blocks until the rate limiter of the context allows another
+// call, returning an error diagnostic if the context is done first. Calls
+// are not limited if the Resource has no RateLimit or the context has no
+// rate limiter, such as when the Resource is not called by a provider
+// server.
+func (r *Resource) waitRateLimit(ctx context.Context) diag.Diagnostics {
+	if r.RateLimit == nil {
+		return nil
+	}
+
+	limiter, ok := ctx.Value(rateLimiterContextKey).(*rateLimiter)
+	if !ok {
+		return nil
+	}
+
+	// InternalValidate rejects this, but guard against dividing by zero in
+	// providers which skip validation.
+	if r.RateLimit.RequestsPerSecond <= 0 {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Invalid Rate Limit",
+				Detail:   "The resource rate limit must have a RequestsPerSecond greater than zero. This is always an issue in the provider and should be reported to the provider developers.",
+			},
+		}
+	}
+
+	if err := limiter.Wait(ctx); err != nil {
+		return diag.Diagnostics{
+			{
+				Severity: diag.Error,
+				Summary:  "Rate Limit Wait Interrupted",
+				Detail:   fmt.Sprintf("The operation was interrupted while waiting for the resource rate limit: %s", err),
+			},
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package schema
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	t.Parallel()
+
+	l := newRateLimiter(&RateLimitConfig{
+		RequestsPerSecond: 20,
+		Burst:             2,
+	})
+
+	start := time.Now()
+
+	// The burst is available immediately.
+	for i := 0; i < 2; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Fatalf("expected burst to be available immediately, took %s", elapsed)
+	}
+
+	// The next call must wait for a token to be added.
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Fatalf("expected call to wait for a token, took %s", elapsed)
+	}
+}
+
+func TestRateLimiterWait_contextCancelled(t *testing.T) {
+	t.Parallel()
+
+	l := newRateLimiter(&RateLimitConfig{
+		RequestsPerSecond: 1,
+	})
+
+	if err := l.Wait(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := l.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context deadline exceeded error, got: %v", err)
+	}
+
+	// The token reserved by the cancelled call must be released.
+	if l.tokens < -1 {
+		t.Fatalf("expected cancelled reservation to be released, got %f tokens", l.tokens)
+	}
+}
+
+func TestResourceRateLimit(t *testing.T) {
+	t.Parallel()
+
+	r := &Resource{
+		RateLimit: &RateLimitConfig{
+			RequestsPerSecond: 20,
+		},
+		Schema: map[string]*Schema{
+			"foo": {
+				Type:     TypeString,
+				Optional: true,
+			},
+		},
+		ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+			return nil
+		},
+	}
+
+	ctx := withRateLimiter(context.Background(), newRateLimiter(r.RateLimit))
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if diags := r.read(ctx, r.TestResourceData(), nil); diags.HasError() {
+			t.Fatalf("unexpected error: %v", diags)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("expected reads to be rate limited, took %s", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	diags := r.read(ctx, r.TestResourceData(), nil)
+
+	if !diags.HasError() {
+		t.Fatal("expected error when context is cancelled while waiting")
+	}
+
+	if diags[0].Summary != "Rate Limit Wait Interrupted" {
+		t.Fatalf("unexpected diagnostic summary: %s", diags[0].Summary)
+	}
+}
+
+func TestResourceRateLimit_noLimiter(t *testing.T) {
+	t.Parallel()
+
+	r := &Resource{
+		RateLimit: &RateLimitConfig{
+			RequestsPerSecond: 1,
+		},
+		Schema: map[string]*Schema{
+			"foo": {
+				Type:     TypeString,
+				Optional: true,
+			},
+		},
+		ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+			return nil
+		},
+	}
+
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if diags := r.read(context.Background(), r.TestResourceData(), nil); diags.HasError() {
+			t.Fatalf("unexpected error: %v", diags)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("expected reads without a rate limiter not to be limited, took %s", elapsed)
+	}
+}
+
+func TestResourceRateLimit_invalidRate(t *testing.T) {
+	t.Parallel()
+
+	r := &Resource{
+		RateLimit: &RateLimitConfig{},
+		Schema: map[string]*Schema{
+			"foo": {
+				Type:     TypeString,
+				Optional: true,
+			},
+		},
+		ReadContext: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+			return nil
+		},
+	}
+
+	ctx := withRateLimiter(context.Background(), newRateLimiter(r.RateLimit))
+
+	// Waiting on a zero rate would divide by zero once the burst is used.
+	if diags := r.read(ctx, r.TestResourceData(), nil); len(diags) == 0 || diags[0].Summary != "Invalid Rate Limit" {
+		t.Fatalf("expected invalid rate limit error, got: %v", diags)
+	}
+}
+
+func TestGRPCProviderServerWithRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	res := &Resource{
+		RateLimit: &RateLimitConfig{
+			RequestsPerSecond: 1,
+		},
+	}
+
+	server := NewGRPCProviderServer(&Provider{})
+
+	limiterFromContext := func(ctx context.Context) *rateLimiter {
+		l, _ := ctx.Value(rateLimiterContextKey).(*rateLimiter)
+		return l
+	}
+
+	first := limiterFromContext(server.withRateLimiter(context.Background(), "test_thing", false, res))
+	if first == nil {
+		t.Fatal("expected a rate limiter in the context")
+	}
+
+	if got := limiterFromContext(server.withRateLimiter(context.Background(), "test_thing", false, res)); got != first {
+		t.Fatal("expected the rate limiter to be shared by calls for the same type name")
+	}
+
+	if got := limiterFromContext(server.withRateLimiter(context.Background(), "test_other", false, res)); got == first {
+		t.Fatal("expected a separate rate limiter for a different type name")
+	}
+
+	if got := limiterFromContext(server.withRateLimiter(context.Background(), "test_thing", true, res)); got == first {
+		t.Fatal("expected a separate rate limiter for a data source with the same type name")
+	}
+
+	if got := limiterFromContext(server.withRateLimiter(context.Background(), "test_none", false, &Resource{})); got != nil {
+		t.Fatal("expected no rate limiter for a resource without a RateLimit")
+	}
+
+	if got := len(server.rateLimiters); got != 3 {
+		t.Fatalf("expected 3 rate limiters, got %d", got)
+	}
+}
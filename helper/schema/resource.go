@@ -8,6 +8,8 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/hashicorp/go-cty/cty"
 
@@ -73,6 +75,11 @@ type Resource struct {
 	// The keys of this map are the names used in a practitioner configuration,
 	// such as the attribute or block name. The values describe the structure
 	// and type information of that attribute or block.
+	//
+	// The result of SchemaFunc is memoized after the first call, so the
+	// function is invoked at most once for the lifetime of the Resource. The
+	// memoized map is returned to every caller of SchemaMap and must not be
+	// modified. A Resource using SchemaFunc must not be copied after first use.
 	SchemaFunc func() map[string]*Schema
 
 	// SchemaVersion is the version number for this resource's Schema
@@ -127,6 +134,38 @@ type Resource struct {
 	// MigrateState.
 	StateUpgraders []StateUpgrader
 
+	// UpgradeIdentityState is responsible for updating the resource identity
+	// when the resource SchemaVersion has changed. This field is only valid
+	// when the Resource is a managed resource with an Identity.
+	//
+	// The UpgradeResourceState protocol response cannot carry identity data,
+	// so this function is instead called during the ReadResource which
+	// follows UpgradeResourceState, before Read. It is called when the
+	// schema version recorded in the resource private state is less than
+	// the current SchemaVersion, and receives the current SchemaVersion as
+	// the final state version. The ReadResource response then records the
+	// current SchemaVersion in the private state, so the function is called
+	// once for each schema version, including for imported resources and
+	// resources which have not been applied since the upgrade.
+	//
+	// IdentityUpgraders, rather than this function, should be used to
+	// upgrade identity data when the identity Version changes.
+	UpgradeIdentityState IdentityStateUpgradeFunc
+
+	// MoveState is a list of movers responsible for converting the state of
+	// a resource of another type, potentially from another provider, into
+	// the state of this resource. They are called by Terraform when a moved
+	// configuration block changes the resource type of an existing resource
+	// instance to this resource type. This field is only valid when the
+	// Resource is a managed resource.
+	//
+	// The first mover with a SourceTypeName matching the source resource
+	// type is called. A mover without a SourceTypeName handles any source
+	// resource type, and must be the last mover. If no mover matches,
+	// Terraform will return an error when moving resource state across
+	// resource types into this resource.
+	MoveState []StateMover
+
 	// Create is called when the provider must create a new instance of a
 	// managed resource. This field is only valid when the Resource is a
 	// managed resource. Only one of Create, CreateContext, or
@@ -586,6 +625,17 @@ type Resource struct {
 	// always overrides any default values set here, whether shorter or longer.
 	Timeouts *ResourceTimeout
 
+	// CustomizeTimeout is an optional function which can modify the
+	// timeouts of an apply operation based on the planned resource data,
+	// such as computing a create timeout from the number of nodes in the
+	// configuration. It is called before the create, update, or delete
+	// operation with the merged default and practitioner timeouts, which it
+	// may modify in place. The modified timeouts are used for the context
+	// deadline of the operation and are returned by the ResourceData Timeout
+	// method. This field is only valid when the Resource is a managed
+	// resource.
+	CustomizeTimeout CustomizeTimeoutFunc
+
 	// Description is used as the description for docs, the language server and
 	// other user facing usage. It can be plain-text or markdown depending on the
 	// global DescriptionKind setting. This field is valid for any Resource.
@@ -602,6 +652,39 @@ type Resource struct {
 	// details.
 	UseJSONNumber bool
 
+	// PreferJSONStateEncoding causes the upgraded state returned to Terraform
+	// after running any StateUpgraders to be JSON encoded, rather than the
+	// default MessagePack encoding. This can be useful when debugging state
+	// upgrades, since the response is then readable in the SDK logs and by
+	// other tools. This field is only valid when the Resource is a managed
+	// resource.
+	PreferJSONStateEncoding bool
+
+	// AllowEmptyID disables the error returned when the create function of
+	// this resource completes without error diagnostics but without setting
+	// an ID. Terraform treats a resource with an empty ID as deleted, so this
+	// should only be enabled for resources that intentionally remove
+	// themselves from the state during create. This field is only valid when
+	// the Resource is a managed resource.
+	AllowEmptyID bool
+
+	// SkipUnchangedComputedDiff skips diffing top level Computed-only
+	// attributes which have a value in the prior state when planning changes
+	// to an existing resource. Since these attributes cannot be configured,
+	// their planned value is always the prior value unless it is updated by
+	// CustomizeDiff, which is still supported. This can reduce the time spent
+	// planning resources with many Computed-only attributes. This field is
+	// only valid when the Resource is a managed resource.
+	SkipUnchangedComputedDiff bool
+
+	// WarnOnContextIgnored enables a warning diagnostic when a context aware
+	// CRUD function, such as CreateContext or ReadWithoutTimeout, returns
+	// without error diagnostics after its context was done. This usually
+	// means the function did not check the context and the operation
+	// continued past a cancellation by Terraform or a timeout, which can help
+	// find functions that should handle the context.
+	WarnOnContextIgnored bool
+
 	// EnableLegacyTypeSystemApplyErrors when enabled will prevent the SDK from
 	// setting the legacy type system flag in the protocol during
 	// ApplyResourceChange (Create, Update, and Delete) operations. Before
@@ -647,10 +730,36 @@ type Resource struct {
 	// logic fixes that should be applicable for both SDKs to be resolved.
 	EnableLegacyTypeSystemPlanErrors bool
 
+	// ErrorOnWriteOnlyInState when enabled returns an error diagnostic for
+	// each WriteOnly attribute which has a non-null value in the new state
+	// returned by Read, Create, or Update, such as after calling Set on the
+	// attribute. By default these values are silently set to null, which can
+	// hide logic errors in the provider.
+	//
+	// Planning is not checked, since the planned state always contains the
+	// configured values of write-only attributes.
+	ErrorOnWriteOnlyInState bool
+
+	// ValidateProposedState when enabled returns a warning diagnostic during
+	// planning for each top-level Computed attribute, which is not Optional,
+	// that had a known value in the prior state but is null in the proposed
+	// new state sent by Terraform. Such values would normally have been
+	// carried over from the prior state, so this can surface unexpected
+	// plan behavior. The check is skipped when the resource is being created
+	// or replaced, or when the provider has deferred the response.
+	ValidateProposedState bool
+
 	// ResourceBehavior is used to control SDK-specific logic when
 	// interacting with this resource.
 	ResourceBehavior ResourceBehavior
 
+	// RateLimit, if set, limits the rate at which the create, read, update,
+	// and delete functions of this resource are called. The limit is shared
+	// across all instances of this resource type served by a provider
+	// server, and is not applied when the Resource is called directly.
+	// Calls wait for the limit while respecting context cancellation.
+	RateLimit *RateLimitConfig
+
 	// ValidateRawResourceConfigFuncs allows functions to define arbitrary validation
 	// logic during the ValidateResourceTypeConfig RPC. ValidateRawResourceConfigFunc receives
 	// the client capabilities from the ValidateResourceTypeConfig RPC and the raw cty
@@ -658,11 +767,30 @@ type Resource struct {
 	// diagnostics based on the inspection of those values.
 	//
 	// ValidateRawResourceConfigFuncs is only valid for Managed Resource types and will not be
-	// called for Data Resource or Provider types.
+	// called for Data Resource or Provider types. Data Resource types should use
+	// ValidateRawDataSourceConfigFuncs instead.
 	//
 	// Developers should prefer other validation methods first as this validation function
 	// deals with raw cty values.
 	ValidateRawResourceConfigFuncs []ValidateRawResourceConfigFunc
+
+	// ValidateRawDataSourceConfigFuncs allows functions to define arbitrary
+	// validation logic during the ValidateDataSourceConfig RPC.
+	// ValidateDataSourceConfigFunc receives the raw cty config value for the
+	// entire data source before it is shimmed, and it can return diagnostics
+	// based on the inspection of that value.
+	//
+	// ValidateRawDataSourceConfigFuncs is only valid for Data Resource types
+	// and will not be called for Managed Resource or Provider types.
+	//
+	// Developers should prefer other validation methods first as this
+	// validation function deals with raw cty values.
+	ValidateRawDataSourceConfigFuncs []ValidateDataSourceConfigFunc
+
+	// schemaFuncOnce and schemaFuncSchema memoize the result of SchemaFunc
+	// for this Resource.
+	schemaFuncOnce   sync.Once
+	schemaFuncSchema map[string]*Schema
 }
 
 // ResourceBehavior controls SDK-specific logic when interacting
@@ -689,6 +817,10 @@ type ProviderDeferredBehavior struct {
 	EnablePlanModification bool
 }
 
+// CustomizeTimeoutFunc is a function which can modify the timeouts of an
+// apply operation based on the planned resource data.
+type CustomizeTimeoutFunc func(context.Context, *ResourceData, *ResourceTimeout) error
+
 // ValidateRawResourceConfigFunc is a function used to validate the raw resource config
 // and has Diagnostic support. it is only valid for Managed Resource types and will not be
 // called for Data Resource or Block types.
@@ -708,12 +840,33 @@ type ValidateResourceConfigFuncResponse struct {
 	Diagnostics diag.Diagnostics
 }
 
+// ValidateDataSourceConfigFunc is a function used to validate the raw data
+// source config and has Diagnostic support. It is only valid for Data Resource
+// types and will not be called for Managed Resource or Block types.
+type ValidateDataSourceConfigFunc func(context.Context, ValidateDataSourceConfigFuncRequest, *ValidateDataSourceConfigFuncResponse)
+
+// ValidateDataSourceConfigFuncRequest has no equivalent of the
+// ValidateResourceConfigFuncRequest WriteOnlyAttributesAllowed field, since
+// data sources cannot contain write-only attributes.
+type ValidateDataSourceConfigFuncRequest struct {
+	// The raw config value provided by Terraform core
+	RawConfig cty.Value
+}
+
+type ValidateDataSourceConfigFuncResponse struct {
+	Diagnostics diag.Diagnostics
+}
+
 // SchemaMap returns the schema information for this Resource whether it is
 // defined via the SchemaFunc field or Schema field. The SchemaFunc field, if
 // defined, takes precedence over the Schema field.
 func (r *Resource) SchemaMap() map[string]*Schema {
 	if r.SchemaFunc != nil {
-		return r.SchemaFunc()
+		r.schemaFuncOnce.Do(func() {
+			r.schemaFuncSchema = r.SchemaFunc()
+		})
+
+		return r.schemaFuncSchema
 	}
 
 	return r.Schema
@@ -824,10 +977,112 @@ type StateUpgrader struct {
 // align to the typing mentioned above.
 type StateUpgradeFunc func(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error)
 
+// IdentityStateUpgradeFunc is the function signature for updating the
+// resource identity after a resource schema version change.
+//
+// The stateVersion parameter is the schema version the resource state was
+// upgraded to. The rawIdentity parameter contains the current identity
+// data as a JSON map, which follows the same typing rules as
+// StateUpgradeFunc. The returned map should contain the updated identity
+// data.
+type IdentityStateUpgradeFunc func(ctx context.Context, stateVersion int, rawIdentity map[string]interface{}, meta interface{}) (map[string]interface{}, error)
+
+// StateMover converts the state of a source resource type into the state of
+// the resource. See the Resource MoveState field.
+type StateMover struct {
+	// SourceTypeName is the resource type of the source state handled by
+	// this mover, such as "examplecloud_old_thing". If empty, the mover
+	// handles any source resource type.
+	SourceTypeName string
+
+	// SourceSchema is the optional schema of the source resource type. If
+	// set, the source state is decoded with this schema into the SourceState
+	// field of the MoveStateRequest. The id attribute is added automatically,
+	// as with managed resources.
+	SourceSchema map[string]*Schema
+
+	// StateMover is called to convert the source state. It is required.
+	StateMover MoveStateFunc
+}
+
+// MoveStateFunc is the function called to convert the state of a source
+// resource type into the state of the resource.
+//
+// The Context parameter stores SDK information, such as loggers. It also
+// is wired to receive any cancellation from Terraform such as a system or
+// practitioner sending SIGINT (Ctrl-c).
+type MoveStateFunc func(context.Context, MoveStateRequest, *MoveStateResponse)
+
+// MoveStateRequest is the request passed to a MoveStateFunc, describing the
+// source resource state being moved into the resource.
+type MoveStateRequest struct {
+	// SourceProviderAddress is the address of the provider of the source
+	// resource, such as "registry.terraform.io/hashicorp/examplecloud".
+	SourceProviderAddress string
+
+	// SourceTypeName is the resource type of the source state.
+	SourceTypeName string
+
+	// SourceSchemaVersion is the schema version of the source state.
+	// Implementations should return an error diagnostic for any schema
+	// version they do not support.
+	SourceSchemaVersion int64
+
+	// SourceRawState contains the source state data. If the source state is
+	// stored as JSON, the keys are top level attribute or block names mapped
+	// to decoded JSON values. If the source state is stored in the legacy
+	// flatmap format, the keys are the flatmap keys, such as "list.0.attr",
+	// mapped to string values.
+	SourceRawState map[string]interface{}
+
+	// SourceState is the source state decoded with the SourceSchema of the
+	// mover. It is a cty.NilVal if the mover has no SourceSchema.
+	SourceState cty.Value
+
+	// SourceRawIdentity contains the source identity data decoded from JSON,
+	// if Terraform sent an identity.
+	SourceRawIdentity map[string]interface{}
+
+	// Meta is the value returned when configuring the provider.
+	Meta interface{}
+}
+
+// MoveStateResponse is the response populated by a MoveStateFunc with the
+// state of the resource.
+type MoveStateResponse struct {
+	// TargetState should contain the state data for this resource type at
+	// its current schema version. Values must align to the typing described
+	// in the StateUpgradeFunc documentation.
+	TargetState map[string]interface{}
+
+	// TargetIdentity should contain the identity data for this resource
+	// type, if the resource has an Identity.
+	TargetIdentity map[string]interface{}
+
+	// Diagnostics report errors or warnings related to moving the state.
+	Diagnostics diag.Diagnostics
+}
+
+// stateMover returns the first MoveState mover for the given source resource
+// type, or nil if there is none.
+func (r *Resource) stateMover(sourceTypeName string) *StateMover {
+	for i := range r.MoveState {
+		if r.MoveState[i].SourceTypeName == sourceTypeName || r.MoveState[i].SourceTypeName == "" {
+			return &r.MoveState[i]
+		}
+	}
+
+	return nil
+}
+
 // See Resource documentation.
 type CustomizeDiffFunc func(context.Context, *ResourceDiff, interface{}) error
 
 func (r *Resource) create(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+	if diags := r.waitRateLimit(ctx); diags.HasError() {
+		return diags
+	}
+
 	if r.Create != nil {
 		if err := r.Create(d, meta); err != nil {
 			return diag.FromErr(err)
@@ -836,15 +1091,19 @@ func (r *Resource) create(ctx context.Context, d *ResourceData, meta interface{}
 	}
 
 	if r.CreateWithoutTimeout != nil {
-		return r.CreateWithoutTimeout(ctx, d, meta)
+		return r.contextIgnoredDiags(ctx, "create", r.CreateWithoutTimeout(ctx, d, meta))
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, d.Timeout(TimeoutCreate))
 	defer cancel()
-	return r.CreateContext(ctx, d, meta)
+	return r.contextIgnoredDiags(ctx, "create", r.CreateContext(ctx, d, meta))
 }
 
 func (r *Resource) read(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+	if diags := r.waitRateLimit(ctx); diags.HasError() {
+		return diags
+	}
+
 	if r.Read != nil {
 		if err := r.Read(d, meta); err != nil {
 			return diag.FromErr(err)
@@ -853,15 +1112,19 @@ func (r *Resource) read(ctx context.Context, d *ResourceData, meta interface{})
 	}
 
 	if r.ReadWithoutTimeout != nil {
-		return r.ReadWithoutTimeout(ctx, d, meta)
+		return r.contextIgnoredDiags(ctx, "read", r.ReadWithoutTimeout(ctx, d, meta))
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, d.Timeout(TimeoutRead))
 	defer cancel()
-	return r.ReadContext(ctx, d, meta)
+	return r.contextIgnoredDiags(ctx, "read", r.ReadContext(ctx, d, meta))
 }
 
 func (r *Resource) update(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+	if diags := r.waitRateLimit(ctx); diags.HasError() {
+		return diags
+	}
+
 	if r.Update != nil {
 		if err := r.Update(d, meta); err != nil {
 			return diag.FromErr(err)
@@ -870,15 +1133,19 @@ func (r *Resource) update(ctx context.Context, d *ResourceData, meta interface{}
 	}
 
 	if r.UpdateWithoutTimeout != nil {
-		return r.UpdateWithoutTimeout(ctx, d, meta)
+		return r.contextIgnoredDiags(ctx, "update", r.UpdateWithoutTimeout(ctx, d, meta))
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, d.Timeout(TimeoutUpdate))
 	defer cancel()
-	return r.UpdateContext(ctx, d, meta)
+	return r.contextIgnoredDiags(ctx, "update", r.UpdateContext(ctx, d, meta))
 }
 
 func (r *Resource) delete(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+	if diags := r.waitRateLimit(ctx); diags.HasError() {
+		return diags
+	}
+
 	if r.Delete != nil {
 		if err := r.Delete(d, meta); err != nil {
 			return diag.FromErr(err)
@@ -887,12 +1154,29 @@ func (r *Resource) delete(ctx context.Context, d *ResourceData, meta interface{}
 	}
 
 	if r.DeleteWithoutTimeout != nil {
-		return r.DeleteWithoutTimeout(ctx, d, meta)
+		return r.contextIgnoredDiags(ctx, "delete", r.DeleteWithoutTimeout(ctx, d, meta))
 	}
 
 	ctx, cancel := context.WithTimeout(ctx, d.Timeout(TimeoutDelete))
 	defer cancel()
-	return r.DeleteContext(ctx, d, meta)
+	return r.contextIgnoredDiags(ctx, "delete", r.DeleteContext(ctx, d, meta))
+}
+
+// contextIgnoredDiags appends a warning to the diagnostics returned by a
+// context aware CRUD function if WarnOnContextIgnored is enabled and the
+// function returned without error diagnostics after ctx was done.
+func (r *Resource) contextIgnoredDiags(ctx context.Context, operation string, diags diag.Diagnostics) diag.Diagnostics {
+	if !r.WarnOnContextIgnored || ctx.Err() == nil || diags.HasError() {
+		return diags
+	}
+
+	return append(diags, diag.Diagnostic{
+		Severity: diag.Warning,
+		Summary:  "Context Ignored",
+		Detail: fmt.Sprintf("The resource %s operation continued after its context was done (%s) and returned without an error. "+
+			"The operation may have completed after Terraform canceled it or after the operation timeout. "+
+			"This is an issue with the provider and should be reported to the provider developers.", operation, ctx.Err()),
+	})
 }
 
 // Apply creates, updates, and/or deletes a resource.
@@ -929,6 +1213,16 @@ func (r *Resource) Apply(
 	}
 	data.timeouts = &rt
 
+	if r.CustomizeTimeout != nil {
+		logging.HelperSchemaTrace(ctx, "Calling downstream")
+		err := r.CustomizeTimeout(ctx, data, data.timeouts)
+		logging.HelperSchemaTrace(ctx, "Called downstream")
+
+		if err != nil {
+			return s, diag.FromErr(err)
+		}
+	}
+
 	if s == nil {
 		// The Terraform API dictates that this should never happen, but
 		// it doesn't hurt to be safe in this case.
@@ -974,6 +1268,15 @@ func (r *Resource) Apply(
 		logging.HelperSchemaTrace(ctx, "Calling downstream")
 		diags = append(diags, r.create(ctx, data, meta)...)
 		logging.HelperSchemaTrace(ctx, "Called downstream")
+
+		if !diags.HasError() && data.Id() == "" && !r.AllowEmptyID {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  "Empty Resource ID",
+				Detail: "The resource was created without an ID, so Terraform would treat it as deleted. " +
+					"This is an issue with the provider and should be reported to the provider developers.",
+			})
+		}
 	} else {
 		if !r.updateFuncSet() {
 			return s, append(diags, diag.Diagnostic{
@@ -986,9 +1289,28 @@ func (r *Resource) Apply(
 		logging.HelperSchemaTrace(ctx, "Called downstream")
 	}
 
+	diags = append(diags, resourceIDDiags(data.Id())...)
+
 	return r.recordCurrentSchemaVersion(data.State()), diags
 }
 
+// resourceIDDiags returns an error diagnostic if the given resource ID
+// cannot be safely stored in the Terraform state.
+func resourceIDDiags(id string) diag.Diagnostics {
+	if !strings.ContainsRune(id, 0) {
+		return nil
+	}
+
+	return diag.Diagnostics{
+		{
+			Severity: diag.Error,
+			Summary:  "Invalid Resource ID",
+			Detail: fmt.Sprintf("The resource ID %q contains a null byte, which cannot be stored in the Terraform state. ", id) +
+				"This is an issue with the provider and should be reported to the provider developers.",
+		},
+	}
+}
+
 // Diff returns a diff of this resource.
 func (r *Resource) Diff(
 	ctx context.Context,
@@ -1026,7 +1348,7 @@ func (r *Resource) SimpleDiff(
 	meta interface{}) (*terraform.InstanceDiff, error) {
 
 	// TODO: figure out if it makes sense to be able to set identity in CustomizeDiff at all
-	instanceDiff, err := schemaMapWithIdentity{r.SchemaMap(), r.Identity.SchemaMap()}.Diff(ctx, s, c, r.CustomizeDiff, meta, false)
+	instanceDiff, err := schemaMapWithIdentity{r.SchemaMap(), r.Identity.SchemaMap()}.diffWithOptions(ctx, s, c, r.CustomizeDiff, meta, false, r.SkipUnchangedComputedDiff)
 	if err != nil {
 		return instanceDiff, err
 	}
@@ -1211,6 +1533,12 @@ func (r *Resource) InternalValidate(topSchemaMap schemaMap, writable bool) error
 	schema := schemaMap(r.SchemaMap())
 	tsm := topSchemaMap
 
+	if writable && r.Identity != nil {
+		if err := r.Identity.InternalIdentityValidate(); err != nil {
+			return fmt.Errorf("identity: %w", err)
+		}
+	}
+
 	if r.isTopLevel() && writable {
 		// All non-Computed attributes must be ForceNew if Update is not defined
 		if !r.updateFuncSet() {
@@ -1255,6 +1583,14 @@ func (r *Resource) InternalValidate(topSchemaMap schemaMap, writable bool) error
 			}
 		}
 
+		if r.Importer != nil && r.Importer.IdentityContext != nil && r.Identity == nil {
+			return fmt.Errorf("Importer.IdentityContext requires Identity to be set")
+		}
+
+		if r.UpgradeIdentityState != nil && r.Identity == nil {
+			return fmt.Errorf("UpgradeIdentityState requires Identity to be set")
+		}
+
 		if f, ok := tsm["id"]; ok {
 			// if there is an explicit ID, validate it...
 			err := validateResourceID(f)
@@ -1265,13 +1601,39 @@ func (r *Resource) InternalValidate(topSchemaMap schemaMap, writable bool) error
 
 		for k := range tsm {
 			if isReservedResourceFieldName(k) {
-				return fmt.Errorf("%s is a reserved field name", k)
+				return SchemaError{
+					AttributePath: k,
+					Reason:        SchemaErrorReasonReservedFieldName,
+					Err:           fmt.Errorf("%s is a reserved field name", k),
+				}
 			}
 		}
 	}
 
+	for i, m := range r.MoveState {
+		if m.SourceTypeName == "" && i != len(r.MoveState)-1 {
+			return fmt.Errorf("MoveState %d: a mover without SourceTypeName must be the last mover", i)
+		}
+
+		if m.StateMover == nil {
+			return fmt.Errorf("MoveState %d (%s): StateMover must be set", i, m.SourceTypeName)
+		}
+
+		if err := schemaMap(m.SourceSchema).InternalValidate(nil); err != nil {
+			return fmt.Errorf("MoveState %d (%s): SourceSchema: %w", i, m.SourceTypeName, err)
+		}
+	}
+
 	lastVersion := -1
 	for _, u := range r.StateUpgraders {
+		if u.Version < 0 {
+			return fmt.Errorf("StateUpgrader version %d must not be negative", u.Version)
+		}
+
+		if lastVersion >= 0 && u.Version <= lastVersion {
+			return fmt.Errorf("StateUpgrader version %d must be greater than the previous version %d", u.Version, lastVersion)
+		}
+
 		if lastVersion >= 0 && u.Version-lastVersion > 1 {
 			return fmt.Errorf("missing schema version between %d and %d", lastVersion, u.Version)
 		}
@@ -1300,7 +1662,11 @@ func (r *Resource) InternalValidate(topSchemaMap schemaMap, writable bool) error
 		tsm = schema
 		for k := range tsm {
 			if isReservedDataSourceFieldName(k) {
-				return fmt.Errorf("%s is a reserved field name", k)
+				return SchemaError{
+					AttributePath: k,
+					Reason:        SchemaErrorReasonReservedFieldName,
+					Err:           fmt.Errorf("%s is a reserved field name", k),
+				}
 			}
 		}
 	}
@@ -1309,6 +1675,10 @@ func (r *Resource) InternalValidate(topSchemaMap schemaMap, writable bool) error
 		return fmt.Errorf("SchemaFunc and Schema should not both be set")
 	}
 
+	if r.RateLimit != nil && r.RateLimit.RequestsPerSecond <= 0 {
+		return fmt.Errorf("RateLimit RequestsPerSecond must be greater than zero")
+	}
+
 	// check context funcs are not set alongside their nonctx counterparts
 	if r.CreateContext != nil && r.Create != nil {
 		return fmt.Errorf("CreateContext and Create should not both be set")
@@ -1447,6 +1817,25 @@ func (r *Resource) recordCurrentSchemaVersion(
 	return state
 }
 
+// recordedSchemaVersion returns the schema version stored in the private
+// state by recordCurrentSchemaVersion, or 0 if none was recorded.
+func recordedSchemaVersion(meta map[string]interface{}) int {
+	switch v := meta["schema_version"].(type) {
+	case string:
+		version, err := strconv.Atoi(v)
+		if err != nil {
+			return 0
+		}
+		return version
+	case float64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
 // Noop is a convenience implementation of resource function which takes
 // no action and returns no error.
 func Noop(*ResourceData, interface{}) error {
@@ -1479,26 +1868,23 @@ func (r *ResourceIdentity) InternalIdentityValidate() error {
 
 	for k, v := range r.SchemaMap() {
 		if !v.OptionalForImport && !v.RequiredForImport {
-			return fmt.Errorf(`OptionalForImport or RequiredForImport must be set for resource identity`)
+			return fmt.Errorf("%s: OptionalForImport or RequiredForImport must be set for resource identity", k)
 		}
 		if v.OptionalForImport && v.RequiredForImport {
-			return fmt.Errorf(`OptionalForImport or RequiredForImport must be set for resource identity, not both`)
+			return fmt.Errorf("%s: OptionalForImport or RequiredForImport must be set for resource identity, not both", k)
 		}
 
-		if v.Type == TypeMap {
-			return fmt.Errorf(`TypeMap is not valid for resource identity`)
-		}
 		if v.Type == TypeSet {
-			return fmt.Errorf(`TypeSet is not valid for resource identity`)
+			return fmt.Errorf("%s: TypeSet is not valid for resource identity", k)
 		}
 		if v.Type == typeObject {
-			return fmt.Errorf(`TypeObject is not valid for resource identity`)
+			return fmt.Errorf("%s: TypeObject is not valid for resource identity", k)
 		}
 		if v.Type == TypeInvalid {
-			return fmt.Errorf(`TypeInvalid is not valid for resource identity`)
+			return fmt.Errorf("%s: TypeInvalid is not valid for resource identity", k)
 		}
 
-		if v.Type == TypeList {
+		if v.Type == TypeList || v.Type == TypeMap {
 			if v.Elem != nil {
 				if v.Elem == TypeMap {
 					return fmt.Errorf(`TypeMap is not valid for resource identity element type`)
@@ -1515,6 +1901,10 @@ func (r *ResourceIdentity) InternalIdentityValidate() error {
 			}
 		}
 
+		if err := validateIdentityElem(k, v); err != nil {
+			return err
+		}
+
 		if v.ForceNew {
 			return fmt.Errorf(`ForceNew is not used in resource identity`)
 		}
@@ -1561,6 +1951,10 @@ func (r *ResourceIdentity) InternalIdentityValidate() error {
 			return fmt.Errorf("%s: DefaultFunc is for configurable attributes,"+
 				"there's nothing to configure for resource identity", k)
 		}
+		if v.DefaultContextFunc != nil {
+			return fmt.Errorf("%s: DefaultContextFunc is for configurable attributes,"+
+				"there's nothing to configure for resource identity", k)
+		}
 		if v.DiffSuppressFunc != nil {
 			return fmt.Errorf("%s: DiffSuppressFunc is for suppressing differences"+
 				" between config and state representation. "+
@@ -1596,5 +1990,39 @@ func (r *ResourceIdentity) InternalIdentityValidate() error {
 		}
 	}
 
+	lastVersion := int64(-1)
+	lastPassthrough := false
+	for _, u := range r.IdentityUpgraders {
+		if u.Version < 0 {
+			return fmt.Errorf("IdentityUpgrader version %d must not be negative", u.Version)
+		}
+
+		if lastVersion >= 0 && u.Version <= lastVersion {
+			return fmt.Errorf("IdentityUpgrader %d must be ordered after IdentityUpgrader %d", u.Version, lastVersion)
+		}
+
+		if lastVersion >= 0 && u.Version-lastVersion > 1 && !lastPassthrough {
+			return fmt.Errorf("missing IdentityUpgrader between %d and %d", lastVersion, u.Version)
+		}
+
+		if u.Version >= r.Version {
+			return fmt.Errorf("IdentityUpgrader version %d is >= current version %d", u.Version, r.Version)
+		}
+
+		if u.Passthrough {
+			if u.Upgrade != nil {
+				return fmt.Errorf("IdentityUpgrader %d cannot set both Passthrough and Upgrade", u.Version)
+			}
+		} else if u.Upgrade == nil {
+			return fmt.Errorf("IdentityUpgrader %d missing ResourceIdentityUpgradeFunc", u.Version)
+		}
+
+		lastVersion = u.Version
+		lastPassthrough = u.Passthrough
+	}
+
+	if lastVersion >= 0 && lastVersion != r.Version-1 && !lastPassthrough {
+		return fmt.Errorf("missing IdentityUpgrader between %d and %d", lastVersion, r.Version)
+	}
 	return nil
 }
@@ -4,9 +4,14 @@
 package schema
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"math/big"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -16,6 +21,8 @@ import (
 	"github.com/hashicorp/go-cty/cty/gocty"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/configs/hcl2shim"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/tfdiags"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
@@ -36,12 +43,14 @@ type ResourceData struct {
 	meta           map[string]interface{}
 	timeouts       *ResourceTimeout
 	providerMeta   cty.Value
+	ctx            context.Context
 
 	// Don't set
 	multiReader *MultiLevelFieldReader
 	setWriter   *MapFieldWriter
 	newState    *terraform.InstanceState
 	newIdentity *IdentityData
+	newRawState cty.Value
 	partial     bool
 	once        sync.Once
 	isNew       bool
@@ -73,6 +82,82 @@ func (d *ResourceData) Get(key string) interface{} {
 	return v
 }
 
+// GetStringSlice returns the value of the TypeList or TypeSet attribute with
+// TypeString elements at the given key as a []string, in the same order as
+// Get. An empty slice is returned if the value is null or unset.
+//
+// If the attribute is not a TypeList or TypeSet with TypeString elements, an
+// error is logged and an empty slice is returned.
+func (d *ResourceData) GetStringSlice(key string) []string {
+	raw, err := d.getPrimitiveSlice(key, TypeString)
+	if err != nil {
+		if d.panicOnError {
+			panic(err)
+		}
+
+		log.Printf("[ERROR] reading string slice: %s", err)
+	}
+
+	result := make([]string, 0, len(raw))
+	for _, v := range raw {
+		s, _ := v.(string)
+		result = append(result, s)
+	}
+
+	return result
+}
+
+// GetIntSlice returns the value of the TypeList or TypeSet attribute with
+// TypeInt elements at the given key as a []int, in the same order as Get. An
+// empty slice is returned if the value is null or unset.
+//
+// If the attribute is not a TypeList or TypeSet with TypeInt elements, an
+// error is logged and an empty slice is returned.
+func (d *ResourceData) GetIntSlice(key string) []int {
+	raw, err := d.getPrimitiveSlice(key, TypeInt)
+	if err != nil {
+		if d.panicOnError {
+			panic(err)
+		}
+
+		log.Printf("[ERROR] reading int slice: %s", err)
+	}
+
+	result := make([]int, 0, len(raw))
+	for _, v := range raw {
+		i, _ := v.(int)
+		result = append(result, i)
+	}
+
+	return result
+}
+
+// getPrimitiveSlice returns the elements of the TypeList or TypeSet attribute
+// at the given key, after checking that its elements are of the given type.
+func (d *ResourceData) getPrimitiveSlice(key string, elemType ValueType) ([]interface{}, error) {
+	r := d.getRaw(key, getSourceSet)
+	if r.Schema == nil {
+		return nil, fmt.Errorf("%s: invalid key", key)
+	}
+
+	if r.Schema.Type != TypeList && r.Schema.Type != TypeSet {
+		return nil, fmt.Errorf("%s: expected TypeList or TypeSet, got %s", key, r.Schema.Type)
+	}
+
+	if elem, ok := r.Schema.Elem.(*Schema); !ok || elem.Type != elemType {
+		return nil, fmt.Errorf("%s: expected %s elements", key, elemType)
+	}
+
+	switch v := r.Value.(type) {
+	case []interface{}:
+		return v, nil
+	case *Set:
+		return v.List(), nil
+	default:
+		return nil, nil
+	}
+}
+
 // GetChange returns the old and new value for a given key.
 //
 // HasChange should be used to check if a change exists. It is possible
@@ -111,13 +196,96 @@ func (d *ResourceData) GetOk(key string) (interface{}, bool) {
 // no Default value have been set.
 //
 // Deprecated: usage is discouraged due to undefined behaviors and may be
-// removed in a future version of the SDK
+// removed in a future version of the SDK. Use GetConfigValue instead to
+// determine whether an attribute was set in the configuration.
 func (d *ResourceData) GetOkExists(key string) (interface{}, bool) {
 	r := d.getRaw(key, getSourceSet)
 	exists := r.Exists && !r.Computed
 	return r.Value, exists
 }
 
+// GetConfigValue returns the value for the given key directly from the raw
+// configuration returned by GetRawConfig, bypassing the zero value handling
+// of Get, GetOk and GetOkExists. The key uses the same format as Get, such
+// as "block.0.attr" or "tags.key". Set elements cannot be addressed.
+//
+// The second result is true only if the value is wholly known and non-null,
+// so a configured empty string or false value can be distinguished from an
+// unconfigured attribute. The returned value can be checked with IsNull and
+// IsKnown to distinguish between the other cases. If the key does not match
+// the configuration structure, cty.NilVal is returned.
+func (d *ResourceData) GetConfigValue(key string) (cty.Value, bool) {
+	val := d.GetRawConfig()
+
+	if key != "" {
+		for _, part := range strings.Split(key, ".") {
+			val = configValueStep(val, part)
+			if val == cty.NilVal {
+				return cty.NilVal, false
+			}
+		}
+	}
+
+	return val, val.IsWhollyKnown() && !val.IsNull()
+}
+
+// configValueStep returns the nested value of val for the given part of a
+// key, or cty.NilVal if val has no such nested value. The nested value of a
+// null or unknown value is a null or unknown value of the nested type.
+func configValueStep(val cty.Value, part string) cty.Value {
+	ty := val.Type()
+
+	var nestedTy cty.Type
+	var key cty.Value
+
+	switch {
+	case ty.IsObjectType():
+		if !ty.HasAttribute(part) {
+			return cty.NilVal
+		}
+
+		nestedTy = ty.AttributeType(part)
+	case ty.IsListType() || ty.IsTupleType():
+		i, err := strconv.Atoi(part)
+		if err != nil || i < 0 {
+			return cty.NilVal
+		}
+
+		if ty.IsTupleType() {
+			if i >= len(ty.TupleElementTypes()) {
+				return cty.NilVal
+			}
+
+			nestedTy = ty.TupleElementType(i)
+		} else {
+			nestedTy = ty.ElementType()
+		}
+
+		key = cty.NumberIntVal(int64(i))
+	case ty.IsMapType():
+		nestedTy = ty.ElementType()
+		key = cty.StringVal(part)
+	default:
+		return cty.NilVal
+	}
+
+	switch {
+	case val.IsNull():
+		return cty.NullVal(nestedTy)
+	case !val.IsKnown():
+		return cty.UnknownVal(nestedTy)
+	case ty.IsObjectType():
+		return val.GetAttr(part)
+	case ty.IsTupleType():
+		return val.Index(key)
+	case val.HasIndex(key).True():
+		return val.Index(key)
+	default:
+		// list elements or map keys which are not configured
+		return cty.NullVal(nestedTy)
+	}
+}
+
 func (d *ResourceData) getRaw(key string, level getSource) getResult {
 	var parts []string
 	if key != "" {
@@ -163,6 +331,36 @@ func (d *ResourceData) HasChangesExcept(keys ...string) bool {
 	return false
 }
 
+// GetChangedKeys returns the sorted root attribute keys which have been
+// changed. Changes within nested blocks or collections are reported by the
+// root attribute key.
+func (d *ResourceData) GetChangedKeys() []string {
+	if d == nil || d.diff == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var keys []string
+
+	for attr := range d.diff.Attributes {
+		rootAttr := strings.Split(attr, ".")[0]
+
+		if seen[rootAttr] {
+			continue
+		}
+
+		seen[rootAttr] = true
+
+		if d.HasChange(rootAttr) {
+			keys = append(keys, rootAttr)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
 // HasChange returns whether or not the given key has been changed.
 func (d *ResourceData) HasChange(key string) bool {
 	o, n := d.GetChange(key)
@@ -192,6 +390,30 @@ func (d *ResourceData) HasChangeExcept(key string) bool {
 	return false
 }
 
+// HasChangeInPrefix returns whether the given key, or any key nested under it,
+// has been changed. The prefix is an address such as "network" or
+// "network.0", and may end with ".*" to make the nesting explicit, such as
+// "network.0.*".
+func (d *ResourceData) HasChangeInPrefix(prefix string) bool {
+	if d == nil || d.diff == nil {
+		return false
+	}
+
+	prefix = strings.TrimSuffix(strings.TrimSuffix(prefix, "*"), ".")
+
+	for attr := range d.diff.Attributes {
+		if attr != prefix && !strings.HasPrefix(attr, prefix+".") {
+			continue
+		}
+
+		if d.HasChange(attr) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // Partial is a legacy function that was used for capturing state of specific
 // attributes if an update only partially worked. Enabling this flag without
 // setting any specific keys with the now removed SetPartial has a useful side
@@ -239,8 +461,231 @@ func (d *ResourceData) Set(key string, value interface{}) error {
 		} else {
 			log.Printf("[ERROR] setting state: %s", err)
 		}
+		return err
 	}
-	return err
+
+	// Any value previously given to SetRawState no longer reflects the
+	// complete state.
+	d.newRawState = cty.NilVal
+
+	return nil
+}
+
+// SetAt sets the value at the given path. Each step of the path must match
+// the schema: attribute steps select attributes of the resource or of a nested
+// block, and index steps select existing elements of a TypeList by number or
+// elements of a TypeMap by string. Elements of a TypeSet cannot be addressed.
+//
+// The current value of the top level attribute is updated at the given path
+// and then written with Set, so the same type rules apply.
+func (d *ResourceData) SetAt(path cty.Path, value interface{}) error {
+	if len(path) == 0 {
+		return errors.New("SetAt: path must not be empty")
+	}
+
+	var current *Schema
+	attrs := d.schema
+
+	for i, step := range path {
+		stepPath := tfdiags.FormatCtyPath(path[:i+1])
+
+		switch step := step.(type) {
+		case cty.GetAttrStep:
+			if attrs == nil {
+				return fmt.Errorf("SetAt: %s: attribute step is not valid for %s", stepPath, current.Type)
+			}
+
+			schema, ok := attrs[step.Name]
+			if !ok {
+				return fmt.Errorf("SetAt: %s: unsupported attribute %q", stepPath, step.Name)
+			}
+
+			current = schema
+			attrs = nil
+		case cty.IndexStep:
+			if current == nil || step.Key.IsNull() || !step.Key.IsKnown() {
+				return fmt.Errorf("SetAt: %s: index step is not valid here", stepPath)
+			}
+
+			switch current.Type {
+			case TypeList:
+				if step.Key.Type() != cty.Number {
+					return fmt.Errorf("SetAt: %s: list index must be a number", stepPath)
+				}
+			case TypeMap:
+				if step.Key.Type() != cty.String {
+					return fmt.Errorf("SetAt: %s: map key must be a string", stepPath)
+				}
+			case TypeSet:
+				return fmt.Errorf("SetAt: %s: set elements cannot be addressed", stepPath)
+			default:
+				return fmt.Errorf("SetAt: %s: index step is not valid for %s", stepPath, current.Type)
+			}
+
+			switch elem := current.Elem.(type) {
+			case *Resource:
+				current = &Schema{Type: typeObject}
+				attrs = elem.SchemaMap()
+			case *Schema:
+				current = elem
+			case ValueType:
+				current = &Schema{Type: elem}
+			default:
+				current = &Schema{Type: TypeString}
+			}
+		default:
+			return fmt.Errorf("SetAt: %s: unsupported path step %T", stepPath, step)
+		}
+	}
+
+	name := path[0].(cty.GetAttrStep).Name
+
+	newValue, err := setValueAtPath(d.Get(name), path, 1, value)
+	if err != nil {
+		return fmt.Errorf("SetAt: %w", err)
+	}
+
+	return d.Set(name, newValue)
+}
+
+// setValueAtPath returns the given value of an attribute with the element at
+// path[i:] replaced by value. Maps and nested blocks are updated in place.
+func setValueAtPath(current interface{}, path cty.Path, i int, value interface{}) (interface{}, error) {
+	if i == len(path) {
+		return value, nil
+	}
+
+	stepPath := tfdiags.FormatCtyPath(path[:i+1])
+
+	switch step := path[i].(type) {
+	case cty.GetAttrStep:
+		m, _ := current.(map[string]interface{})
+		if m == nil {
+			m = make(map[string]interface{})
+		}
+
+		v, err := setValueAtPath(m[step.Name], path, i+1, value)
+		if err != nil {
+			return nil, err
+		}
+
+		m[step.Name] = v
+
+		return m, nil
+	case cty.IndexStep:
+		if step.Key.Type() == cty.String {
+			m, _ := current.(map[string]interface{})
+			if m == nil {
+				m = make(map[string]interface{})
+			}
+
+			v, err := setValueAtPath(m[step.Key.AsString()], path, i+1, value)
+			if err != nil {
+				return nil, err
+			}
+
+			m[step.Key.AsString()] = v
+
+			return m, nil
+		}
+
+		l, _ := current.([]interface{})
+		idx, acc := step.Key.AsBigFloat().Int64()
+		if acc != big.Exact || idx < 0 || idx >= int64(len(l)) {
+			return nil, fmt.Errorf("%s: list index out of range", stepPath)
+		}
+
+		v, err := setValueAtPath(l[idx], path, i+1, value)
+		if err != nil {
+			return nil, err
+		}
+
+		l[idx] = v
+
+		return l, nil
+	}
+
+	return nil, fmt.Errorf("%s: unsupported path step %T", stepPath, path[i])
+}
+
+// SetAll sets the value for each of the given keys, as if Set was called for
+// each of them in sorted key order. All keys are attempted even if setting an
+// earlier key fails; every failure is returned as an error diagnostic whose
+// summary includes the key.
+func (d *ResourceData) SetAll(values map[string]interface{}) diag.Diagnostics {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var diags diag.Diagnostics
+	for _, k := range keys {
+		if err := d.Set(k, values[k]); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Error,
+				Summary:  fmt.Sprintf("Error setting %q", k),
+				Detail:   err.Error(),
+			})
+		}
+	}
+
+	return diags
+}
+
+// SetRawState replaces the entire state of the resource with the given value,
+// which must conform to the type implied by the resource schema, excluding
+// the implicit "id" attribute and the timeouts block. The value must be wholly
+// known.
+//
+// When called during Create, Read, or Update, the SDK returns this value to
+// Terraform as the new state rather than rebuilding the state from the
+// individual attributes. Values for write-only attributes are always nulled
+// before the state is returned. Calling Set afterwards discards the raw value
+// and the state is built from the individual attributes again.
+//
+// SetRawState is considered experimental and advanced functionality, and
+// familiarity with the Terraform protocol is suggested when using it.
+func (d *ResourceData) SetRawState(val cty.Value) error {
+	d.once.Do(d.init)
+
+	if val.IsNull() {
+		return fmt.Errorf("SetRawState: value must not be null")
+	}
+	if !val.IsWhollyKnown() {
+		return fmt.Errorf("SetRawState: value must be wholly known")
+	}
+
+	ty := schemaMap(d.schema).CoreConfigSchema().ImpliedType()
+	if errs := val.Type().TestConformance(ty); len(errs) > 0 {
+		return fmt.Errorf("SetRawState: value does not conform to the resource schema: %s", tfdiags.FormatError(errs[0]))
+	}
+
+	// Keep the field readers in sync so that Get returns the same values
+	// that will be written to the state.
+	reader := &MapFieldReader{
+		Schema: d.schema,
+		Map:    BasicMapReader(hcl2shim.FlatmapValueFromHCL2(val)),
+	}
+	for k := range d.schema {
+		result, err := reader.ReadField([]string{k})
+		if err != nil {
+			return fmt.Errorf("SetRawState: reading %q: %w", k, err)
+		}
+
+		var v interface{}
+		if result.Exists {
+			v = result.Value
+		}
+
+		if err := d.setWriter.WriteField([]string{k}, v); err != nil {
+			return fmt.Errorf("SetRawState: setting %q: %w", k, err)
+		}
+	}
+
+	d.newRawState = val
+
+	return nil
 }
 
 func (d *ResourceData) MarkNewResource() {
@@ -287,6 +732,11 @@ func (d *ResourceData) ConnInfo() map[string]string {
 
 // SetId sets the ID of the resource. If the value is blank, then the
 // resource is destroyed.
+//
+// The ID is validated once the create or update function returns. An error
+// diagnostic is returned if the ID contains a null byte, or if it is blank
+// after create, unless the Resource sets AllowEmptyID. The ID is not
+// validated after read, so that existing resources can still be refreshed.
 func (d *ResourceData) SetId(v string) {
 	d.once.Do(d.init)
 	d.newState.ID = v
@@ -390,6 +840,10 @@ func (d *ResourceData) State() *terraform.InstanceState {
 		result.Ephemeral = d.newState.Ephemeral
 	}
 
+	if !d.newRawState.IsNull() {
+		result.RawState = d.newRawState
+	}
+
 	// TODO: This is hacky and we can remove this when we have a proper
 	// state writer. We should instead have a proper StateFieldWriter
 	// and use that.
@@ -479,6 +933,16 @@ func (d *ResourceData) Timeout(key string) time.Duration {
 	return defaultTimeout
 }
 
+// TimeoutContext returns a context derived from ctx with a deadline of the
+// timeout for the given key from now, as returned by Timeout, and its cancel
+// function. Unknown keys use the Default timeout. The deadline is never later
+// than that of ctx, such as the deadline the SDK sets for the CRUD function
+// receiving ctx, so waiting on the returned context is always bounded by the
+// time remaining for the operation.
+func (d *ResourceData) TimeoutContext(ctx context.Context, key string) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, d.Timeout(key))
+}
+
 func (d *ResourceData) init() {
 	// Initialize the field that will store our new state
 	var copyState terraform.InstanceState
@@ -505,6 +969,7 @@ func (d *ResourceData) init() {
 		readers["config"] = &ConfigFieldReader{
 			Schema: d.schema,
 			Config: d.config,
+			ctx:    d.ctx,
 		}
 	}
 	if d.diff != nil {
@@ -612,6 +1077,10 @@ func (d *ResourceData) get(addr []string, source getSource) getResult {
 	}
 }
 
+// GetProviderMeta decodes the provider_meta block of the module containing the
+// resource into dst, using the Provider.ProviderMetaSchema. It is only
+// populated in resource and data source operations, since Terraform does not
+// send provider_meta values when configuring the provider.
 func (d *ResourceData) GetProviderMeta(dst interface{}) error {
 	if d.providerMeta.IsNull() {
 		return nil
@@ -619,6 +1088,18 @@ func (d *ResourceData) GetProviderMeta(dst interface{}) error {
 	return gocty.FromCtyValue(d.providerMeta, &dst)
 }
 
+// GetPrivate returns the value stored with ResourceDiff.SetPrivate for the key
+// when the change being applied was planned, or nil if no value was stored.
+// It is only populated when applying a change, such as in CreateContext or
+// UpdateContext.
+func (d *ResourceData) GetPrivate(key string) ([]byte, error) {
+	if d.diff == nil {
+		return nil, nil
+	}
+
+	return privateValue(d.diff.Meta, key)
+}
+
 // GetRawConfig returns the cty.Value that Terraform sent the SDK for the
 // config. If no value was sent, or if a null value was sent, the value will be
 // a null value of the resource's type.
@@ -717,6 +1198,39 @@ func (d *ResourceData) GetRawState() cty.Value {
 	return cty.NullVal(schemaMap(d.schema).CoreConfigSchema().ImpliedType())
 }
 
+// GetRawStateAt is a helper method for retrieving specific values
+// from the RawState returned from GetRawState. It returns the cty.Value
+// for a given cty.Path or an error if there is no prior state or the value
+// at the given path does not exist.
+//
+// GetRawStateAt is considered advanced functionality, and
+// familiarity with the Terraform protocol is suggested when using it.
+func (d *ResourceData) GetRawStateAt(valPath cty.Path) (cty.Value, error) {
+	rawState := d.GetRawState()
+
+	if rawState.IsNull() {
+		return cty.DynamicVal, fmt.Errorf("cannot retrieve state value at %s: the prior state is empty", hcl2shim.FlatmapKeyFromPath(valPath))
+	}
+
+	stateVal := cty.DynamicVal
+	err := cty.Walk(rawState, func(path cty.Path, value cty.Value) (bool, error) {
+		if path.Equals(valPath) {
+			stateVal = value
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil {
+		return cty.DynamicVal, fmt.Errorf("cannot retrieve state value at %s: %w", hcl2shim.FlatmapKeyFromPath(valPath), err)
+	}
+
+	if stateVal.RawEquals(cty.DynamicVal) {
+		return cty.DynamicVal, fmt.Errorf("cannot retrieve state value at %s: no value exists at the given path", hcl2shim.FlatmapKeyFromPath(valPath))
+	}
+
+	return stateVal, nil
+}
+
 // GetRawPlan returns the cty.Value that Terraform sent the SDK for the plan.
 // If no value was sent, or if a null value was sent, the value will be a null
 // value of the resource's type.
@@ -734,8 +1248,15 @@ func (d *ResourceData) GetRawPlan() cty.Value {
 	return cty.NullVal(schemaMap(d.schema).CoreConfigSchema().ImpliedType())
 }
 
+// ErrNoIdentitySchema is returned by Identity when the resource does not
+// have an Identity schema.
+var ErrNoIdentitySchema = errors.New("Resource does not have Identity schema. Please set one in order to use Identity(). This is always a problem in the provider code.")
+
 // IdentityData is only available for managed resources, data sources
 // will return an error. // TODO: return error in case of data sources
+//
+// If the resource does not have an Identity schema, ErrNoIdentitySchema is
+// returned.
 func (d *ResourceData) Identity() (*IdentityData, error) {
 	// return memoized value if available
 	if d.newIdentity != nil {
@@ -743,7 +1264,7 @@ func (d *ResourceData) Identity() (*IdentityData, error) {
 	}
 
 	if d.identitySchema == nil {
-		return nil, fmt.Errorf("Resource does not have Identity schema. Please set one in order to use Identity(). This is always a problem in the provider code.")
+		return nil, ErrNoIdentitySchema
 	}
 
 	var identityData map[string]string
@@ -762,3 +1283,21 @@ func (d *ResourceData) Identity() (*IdentityData, error) {
 
 	return d.newIdentity, nil
 }
+
+// HasIdentity returns whether the resource has an Identity schema, in which
+// case Identity does not return an error.
+func (d *ResourceData) HasIdentity() bool {
+	return d.newIdentity != nil || d.identitySchema != nil
+}
+
+// MustIdentity is like Identity, but panics if the resource does not have an
+// Identity schema. It is intended for tests and code paths where the
+// resource is known to have one.
+func (d *ResourceData) MustIdentity() *IdentityData {
+	identity, err := d.Identity()
+	if err != nil {
+		panic(fmt.Sprintf("MustIdentity: %s", err))
+	}
+
+	return identity
+}
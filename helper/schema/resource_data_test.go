@@ -4,9 +4,12 @@
 package schema
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math"
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
@@ -1089,6 +1092,182 @@ func TestResourceDataGetOk(t *testing.T) {
 	}
 }
 
+func TestResourceDataGetStringSlice(t *testing.T) {
+	testCases := map[string]struct {
+		Schema   map[string]*Schema
+		State    *terraform.InstanceState
+		Set      []interface{}
+		Expected []string
+	}{
+		"list": {
+			Schema: map[string]*Schema{
+				"names": {
+					Type:     TypeList,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+				},
+			},
+			State: &terraform.InstanceState{
+				Attributes: map[string]string{
+					"names.#": "2",
+					"names.0": "foo",
+					"names.1": "bar",
+				},
+			},
+			Expected: []string{"foo", "bar"},
+		},
+		"set": {
+			Schema: map[string]*Schema{
+				"names": {
+					Type:     TypeSet,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+				},
+			},
+			Set:      []interface{}{"foo", "bar", "baz"},
+			Expected: []string{"bar", "baz", "foo"},
+		},
+		"null": {
+			Schema: map[string]*Schema{
+				"names": {
+					Type:     TypeList,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+				},
+			},
+			Expected: []string{},
+		},
+		"wrong element type": {
+			Schema: map[string]*Schema{
+				"names": {
+					Type:     TypeList,
+					Optional: true,
+					Elem:     &Schema{Type: TypeInt},
+				},
+			},
+			State: &terraform.InstanceState{
+				Attributes: map[string]string{
+					"names.#": "1",
+					"names.0": "1",
+				},
+			},
+			Expected: []string{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			d, err := schemaMap(tc.Schema).Data(tc.State, nil)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			if tc.Set != nil {
+				if err := d.Set("names", tc.Set); err != nil {
+					t.Fatalf("err: %s", err)
+				}
+			}
+
+			actual := d.GetStringSlice("names")
+			if tc.Schema["names"].Type == TypeSet {
+				sort.Strings(actual)
+			}
+
+			if diff := cmp.Diff(tc.Expected, actual); diff != "" {
+				t.Fatalf("unexpected difference: %s", diff)
+			}
+		})
+	}
+}
+
+func TestResourceDataGetIntSlice(t *testing.T) {
+	testCases := map[string]struct {
+		Schema   map[string]*Schema
+		State    *terraform.InstanceState
+		Set      []interface{}
+		Expected []int
+	}{
+		"list": {
+			Schema: map[string]*Schema{
+				"ports": {
+					Type:     TypeList,
+					Optional: true,
+					Elem:     &Schema{Type: TypeInt},
+				},
+			},
+			State: &terraform.InstanceState{
+				Attributes: map[string]string{
+					"ports.#": "2",
+					"ports.0": "443",
+					"ports.1": "80",
+				},
+			},
+			Expected: []int{443, 80},
+		},
+		"set": {
+			Schema: map[string]*Schema{
+				"ports": {
+					Type:     TypeSet,
+					Optional: true,
+					Elem:     &Schema{Type: TypeInt},
+				},
+			},
+			Set:      []interface{}{443, 80, 8080},
+			Expected: []int{80, 443, 8080},
+		},
+		"null": {
+			Schema: map[string]*Schema{
+				"ports": {
+					Type:     TypeSet,
+					Optional: true,
+					Elem:     &Schema{Type: TypeInt},
+				},
+			},
+			Expected: []int{},
+		},
+		"wrong element type": {
+			Schema: map[string]*Schema{
+				"ports": {
+					Type:     TypeList,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+				},
+			},
+			State: &terraform.InstanceState{
+				Attributes: map[string]string{
+					"ports.#": "1",
+					"ports.0": "80",
+				},
+			},
+			Expected: []int{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			d, err := schemaMap(tc.Schema).Data(tc.State, nil)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			if tc.Set != nil {
+				if err := d.Set("ports", tc.Set); err != nil {
+					t.Fatalf("err: %s", err)
+				}
+			}
+
+			actual := d.GetIntSlice("ports")
+			if tc.Schema["ports"].Type == TypeSet {
+				sort.Ints(actual)
+			}
+
+			if diff := cmp.Diff(tc.Expected, actual); diff != "" {
+				t.Fatalf("unexpected difference: %s", diff)
+			}
+		})
+	}
+}
+
 func TestResourceDataGetOkExists(t *testing.T) {
 	cases := []struct {
 		Name   string
@@ -1418,6 +1597,54 @@ func TestResourceDataTimeout(t *testing.T) {
 	}
 }
 
+func TestResourceDataTimeoutContext(t *testing.T) {
+	d := &ResourceData{timeouts: timeoutForValues(10, 3, 0, 15, 7)}
+	expected := expectedTimeoutForValues(10, 3, 7, 15, 7)
+
+	cases := map[string]time.Duration{
+		TimeoutCreate:  *expected.Create,
+		TimeoutRead:    *expected.Read,
+		TimeoutUpdate:  *expected.Update,
+		TimeoutDelete:  *expected.Delete,
+		TimeoutDefault: *expected.Default,
+		// There is no import timeout, so it uses the default.
+		"import": *expected.Default,
+	}
+
+	for key, timeout := range cases {
+		t.Run(key, func(t *testing.T) {
+			start := time.Now()
+
+			ctx, cancel := d.TimeoutContext(context.Background(), key)
+			defer cancel()
+
+			deadline, ok := ctx.Deadline()
+			if !ok {
+				t.Fatal("expected context deadline")
+			}
+
+			if deadline.Before(start.Add(timeout)) || deadline.After(time.Now().Add(timeout)) {
+				t.Fatalf("expected deadline %s from now, got %s", timeout, deadline.Sub(start))
+			}
+		})
+	}
+
+	t.Run("parent deadline", func(t *testing.T) {
+		parent, parentCancel := context.WithTimeout(context.Background(), time.Minute)
+		defer parentCancel()
+
+		ctx, cancel := d.TimeoutContext(parent, TimeoutCreate)
+		defer cancel()
+
+		parentDeadline, _ := parent.Deadline()
+		deadline, _ := ctx.Deadline()
+
+		if !deadline.Equal(parentDeadline) {
+			t.Fatalf("expected parent deadline %s, got %s", parentDeadline, deadline)
+		}
+	})
+}
+
 func TestResourceDataHasChanges(t *testing.T) {
 	cases := []struct {
 		Schema map[string]*Schema
@@ -1929,130 +2156,415 @@ func TestResourceDataHasChangesExcept(t *testing.T) {
 	}
 }
 
-func TestResourceDataHasChange(t *testing.T) {
-	cases := []struct {
-		Schema map[string]*Schema
-		State  *terraform.InstanceState
-		Diff   *terraform.InstanceDiff
-		Key    string
-		Change bool
-	}{
-		{
-			Schema: map[string]*Schema{
-				"availability_zone": {
-					Type:     TypeString,
-					Optional: true,
-					Computed: true,
-					ForceNew: true,
-				},
-			},
-
-			State: nil,
+func TestResourceDataGetChangedKeys(t *testing.T) {
+	t.Parallel()
 
-			Diff: &terraform.InstanceDiff{
-				Attributes: map[string]*terraform.ResourceAttrDiff{
-					"availability_zone": {
-						Old:         "",
-						New:         "foo",
-						RequiresNew: true,
+	testSchema := map[string]*Schema{
+		"added": {
+			Type:     TypeString,
+			Optional: true,
+		},
+		"removed": {
+			Type:     TypeString,
+			Optional: true,
+		},
+		"unchanged": {
+			Type:     TypeString,
+			Optional: true,
+		},
+		"block": {
+			Type:     TypeList,
+			Optional: true,
+			Elem: &Resource{
+				Schema: map[string]*Schema{
+					"nested": {
+						Type:     TypeInt,
+						Optional: true,
 					},
 				},
 			},
-
-			Key: "availability_zone",
-
-			Change: true,
 		},
+		"tags": {
+			Type:     TypeMap,
+			Optional: true,
+			Elem:     &Schema{Type: TypeString},
+		},
+	}
 
-		{
-			Schema: map[string]*Schema{
-				"availability_zone": {
-					Type:     TypeString,
-					Optional: true,
-					Computed: true,
-					ForceNew: true,
+	testCases := map[string]struct {
+		State    *terraform.InstanceState
+		Diff     *terraform.InstanceDiff
+		Expected []string
+	}{
+		"no diff": {
+			State: &terraform.InstanceState{
+				Attributes: map[string]string{
+					"unchanged": "foo",
 				},
 			},
-
+			Expected: nil,
+		},
+		"addition": {
 			State: &terraform.InstanceState{
 				Attributes: map[string]string{
-					"availability_zone": "foo",
+					"unchanged": "foo",
 				},
 			},
-
 			Diff: &terraform.InstanceDiff{
 				Attributes: map[string]*terraform.ResourceAttrDiff{
-					"availability_zone": {
-						Old:         "",
-						New:         "foo",
-						RequiresNew: true,
+					"added": {
+						Old: "",
+						New: "bar",
+					},
+					"unchanged": {
+						Old: "foo",
+						New: "foo",
 					},
 				},
 			},
-
-			Key: "availability_zone",
-
-			Change: false,
+			Expected: []string{"added"},
 		},
-
-		{
-			Schema: map[string]*Schema{
-				"tags": {
-					Type:     TypeMap,
-					Optional: true,
-					Computed: true,
+		"deletion": {
+			State: &terraform.InstanceState{
+				Attributes: map[string]string{
+					"removed":  "foo",
+					"tags.%":   "1",
+					"tags.env": "dev",
 				},
 			},
-
-			State: nil,
-
 			Diff: &terraform.InstanceDiff{
 				Attributes: map[string]*terraform.ResourceAttrDiff{
-					"tags.Name": {
-						Old: "foo",
-						New: "foo",
+					"removed": {
+						Old:        "foo",
+						NewRemoved: true,
+					},
+					"tags.%": {
+						Old: "1",
+						New: "0",
+					},
+					"tags.env": {
+						Old:        "dev",
+						NewRemoved: true,
 					},
 				},
 			},
-
-			Key: "tags",
-
-			Change: true,
+			Expected: []string{"removed", "tags"},
 		},
-
-		{
-			Schema: map[string]*Schema{
-				"ports": {
-					Type:     TypeSet,
-					Optional: true,
-					Elem:     &Schema{Type: TypeInt},
-					Set:      func(a interface{}) int { return a.(int) },
-				},
-			},
-
+		"nested change reported by root key": {
 			State: &terraform.InstanceState{
 				Attributes: map[string]string{
-					"ports.#":  "1",
-					"ports.80": "80",
+					"block.#":        "1",
+					"block.0.nested": "1",
+					"unchanged":      "foo",
 				},
 			},
-
 			Diff: &terraform.InstanceDiff{
 				Attributes: map[string]*terraform.ResourceAttrDiff{
-					"ports.#": {
+					"block.0.nested": {
 						Old: "1",
-						New: "0",
+						New: "2",
+					},
+					"added": {
+						Old: "",
+						New: "bar",
 					},
 				},
 			},
+			Expected: []string{"added", "block"},
+		},
+	}
 
-			Key: "ports",
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
 
-			Change: true,
-		},
+			d, err := schemaMap(testSchema).Data(testCase.State, testCase.Diff)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
 
-		// https://github.com/hashicorp/terraform/issues/927
-		{
+			actual := d.GetChangedKeys()
+			if diff := cmp.Diff(testCase.Expected, actual); diff != "" {
+				t.Errorf("unexpected difference: %s", diff)
+			}
+		})
+	}
+}
+
+func TestResourceDataHasChangeInPrefix(t *testing.T) {
+	t.Parallel()
+
+	testSchema := map[string]*Schema{
+		"name": {
+			Type:     TypeString,
+			Optional: true,
+		},
+		"network": {
+			Type:     TypeList,
+			Optional: true,
+			Elem: &Resource{
+				Schema: map[string]*Schema{
+					"name": {
+						Type:     TypeString,
+						Optional: true,
+					},
+					"subnet": {
+						Type:     TypeList,
+						Optional: true,
+						Elem: &Resource{
+							Schema: map[string]*Schema{
+								"cidr": {
+									Type:     TypeString,
+									Optional: true,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"network_name": {
+			Type:     TypeString,
+			Optional: true,
+		},
+	}
+
+	state := &terraform.InstanceState{
+		Attributes: map[string]string{
+			"name":                    "foo",
+			"network.#":               "2",
+			"network.0.name":          "primary",
+			"network.0.subnet.#":      "1",
+			"network.0.subnet.0.cidr": "10.0.0.0/24",
+			"network.1.name":          "secondary",
+			"network.1.subnet.#":      "0",
+			"network_name":            "foo",
+		},
+	}
+
+	testCases := map[string]struct {
+		Diff     *terraform.InstanceDiff
+		Prefix   string
+		Expected bool
+	}{
+		"no diff": {
+			Prefix:   "network",
+			Expected: false,
+		},
+		"change outside prefix": {
+			Diff: &terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"name": {
+						Old: "foo",
+						New: "bar",
+					},
+				},
+			},
+			Prefix:   "network",
+			Expected: false,
+		},
+		"change in attribute sharing the prefix name": {
+			Diff: &terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"network_name": {
+						Old: "foo",
+						New: "bar",
+					},
+				},
+			},
+			Prefix:   "network",
+			Expected: false,
+		},
+		"unchanged diff attribute in prefix": {
+			Diff: &terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"network.0.name": {
+						Old: "primary",
+						New: "primary",
+					},
+				},
+			},
+			Prefix:   "network",
+			Expected: false,
+		},
+		"nested block change": {
+			Diff: &terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"network.0.subnet.0.cidr": {
+						Old: "10.0.0.0/24",
+						New: "10.0.1.0/24",
+					},
+				},
+			},
+			Prefix:   "network",
+			Expected: true,
+		},
+		"nested block change with wildcard": {
+			Diff: &terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"network.0.subnet.0.cidr": {
+						Old: "10.0.0.0/24",
+						New: "10.0.1.0/24",
+					},
+				},
+			},
+			Prefix:   "network.0.*",
+			Expected: true,
+		},
+		"change in other block element": {
+			Diff: &terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"network.1.name": {
+						Old: "secondary",
+						New: "tertiary",
+					},
+				},
+			},
+			Prefix:   "network.0.*",
+			Expected: false,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d, err := schemaMap(testSchema).Data(state, testCase.Diff)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			if actual := d.HasChangeInPrefix(testCase.Prefix); actual != testCase.Expected {
+				t.Fatalf("expected %t, got %t", testCase.Expected, actual)
+			}
+		})
+	}
+}
+
+func TestResourceDataHasChange(t *testing.T) {
+	cases := []struct {
+		Schema map[string]*Schema
+		State  *terraform.InstanceState
+		Diff   *terraform.InstanceDiff
+		Key    string
+		Change bool
+	}{
+		{
+			Schema: map[string]*Schema{
+				"availability_zone": {
+					Type:     TypeString,
+					Optional: true,
+					Computed: true,
+					ForceNew: true,
+				},
+			},
+
+			State: nil,
+
+			Diff: &terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"availability_zone": {
+						Old:         "",
+						New:         "foo",
+						RequiresNew: true,
+					},
+				},
+			},
+
+			Key: "availability_zone",
+
+			Change: true,
+		},
+
+		{
+			Schema: map[string]*Schema{
+				"availability_zone": {
+					Type:     TypeString,
+					Optional: true,
+					Computed: true,
+					ForceNew: true,
+				},
+			},
+
+			State: &terraform.InstanceState{
+				Attributes: map[string]string{
+					"availability_zone": "foo",
+				},
+			},
+
+			Diff: &terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"availability_zone": {
+						Old:         "",
+						New:         "foo",
+						RequiresNew: true,
+					},
+				},
+			},
+
+			Key: "availability_zone",
+
+			Change: false,
+		},
+
+		{
+			Schema: map[string]*Schema{
+				"tags": {
+					Type:     TypeMap,
+					Optional: true,
+					Computed: true,
+				},
+			},
+
+			State: nil,
+
+			Diff: &terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"tags.Name": {
+						Old: "foo",
+						New: "foo",
+					},
+				},
+			},
+
+			Key: "tags",
+
+			Change: true,
+		},
+
+		{
+			Schema: map[string]*Schema{
+				"ports": {
+					Type:     TypeSet,
+					Optional: true,
+					Elem:     &Schema{Type: TypeInt},
+					Set:      func(a interface{}) int { return a.(int) },
+				},
+			},
+
+			State: &terraform.InstanceState{
+				Attributes: map[string]string{
+					"ports.#":  "1",
+					"ports.80": "80",
+				},
+			},
+
+			Diff: &terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"ports.#": {
+						Old: "1",
+						New: "0",
+					},
+				},
+			},
+
+			Key: "ports",
+
+			Change: true,
+		},
+
+		// https://github.com/hashicorp/terraform/issues/927
+		{
 			Schema: map[string]*Schema{
 				"ports": {
 					Type:     TypeSet,
@@ -3919,6 +4431,84 @@ func TestResourceData_nonStringValuesInMap(t *testing.T) {
 	}
 }
 
+func TestResourceDataSet_objectMap(t *testing.T) {
+	t.Parallel()
+
+	sm := map[string]*Schema{
+		"endpoints": {
+			Type:       TypeMap,
+			Optional:   true,
+			ConfigMode: SchemaConfigModeAttr,
+			Elem: &Resource{
+				Schema: map[string]*Schema{
+					"address": {
+						Type:     TypeString,
+						Optional: true,
+					},
+					"port": {
+						Type:     TypeInt,
+						Optional: true,
+					},
+				},
+			},
+		},
+	}
+
+	expected := map[string]interface{}{
+		"primary": map[string]interface{}{
+			"address": "10.0.0.1",
+			"port":    80,
+		},
+		"secondary": map[string]interface{}{
+			"address": "10.0.0.2",
+			"port":    8080,
+		},
+	}
+
+	d, err := schemaMap(sm).Data(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := d.Set("endpoints", expected); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if diff := cmp.Diff(expected, d.Get("endpoints")); diff != "" {
+		t.Errorf("unexpected difference: %s", diff)
+	}
+
+	if got := d.Get("endpoints.secondary.port"); got != 8080 {
+		t.Errorf("expected endpoints.secondary.port to be 8080, got %#v", got)
+	}
+
+	d.SetId("test")
+
+	state := d.State()
+	expectedAttributes := map[string]string{
+		"id":                          "test",
+		"endpoints.%":                 "2",
+		"endpoints.primary.address":   "10.0.0.1",
+		"endpoints.primary.port":      "80",
+		"endpoints.secondary.address": "10.0.0.2",
+		"endpoints.secondary.port":    "8080",
+	}
+
+	if diff := cmp.Diff(expectedAttributes, state.Attributes); diff != "" {
+		t.Errorf("unexpected state difference: %s", diff)
+	}
+
+	// read back from the state
+	d, err = schemaMap(sm).Data(state, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if diff := cmp.Diff(expected, d.Get("endpoints")); diff != "" {
+		t.Errorf("unexpected difference reading state: %s", diff)
+	}
+}
+
 func TestResourceDataGetRawConfigAt(t *testing.T) {
 	cases := map[string]struct {
 		RawConfig     cty.Value
@@ -4082,6 +4672,395 @@ func TestResourceDataGetRawConfigAt(t *testing.T) {
 	}
 }
 
+func TestResourceDataGetRawStateAt(t *testing.T) {
+	cases := map[string]struct {
+		RawState    cty.Value
+		Path        cty.Path
+		Value       cty.Value
+		ExpectedErr string
+	}{
+		"null RawState returns error": {
+			RawState:    cty.NullVal(cty.EmptyObject),
+			Path:        cty.GetAttrPath("invalid_root_path"),
+			Value:       cty.DynamicVal,
+			ExpectedErr: "cannot retrieve state value at invalid_root_path: the prior state is empty",
+		},
+		"null value in state": {
+			RawState: cty.ObjectVal(map[string]cty.Value{
+				"StateAttribute": cty.NullVal(cty.Number),
+			}),
+			Path:  cty.GetAttrPath("StateAttribute"),
+			Value: cty.NullVal(cty.Number),
+		},
+		"invalid path returns error": {
+			RawState: cty.ObjectVal(map[string]cty.Value{
+				"StateAttribute": cty.NumberIntVal(42),
+			}),
+			Path:        cty.GetAttrPath("invalid_root_path"),
+			Value:       cty.DynamicVal,
+			ExpectedErr: "cannot retrieve state value at invalid_root_path: no value exists at the given path",
+		},
+		"root level attribute": {
+			RawState: cty.ObjectVal(map[string]cty.Value{
+				"StateAttribute": cty.NumberIntVal(42),
+			}),
+			Path:  cty.GetAttrPath("StateAttribute"),
+			Value: cty.NumberIntVal(42),
+		},
+		"root level set attribute": {
+			RawState: cty.ObjectVal(map[string]cty.Value{
+				"StateAttribute": cty.SetVal([]cty.Value{
+					cty.StringVal("valueA"),
+					cty.StringVal("valueB"),
+				}),
+			}),
+			Path:  cty.GetAttrPath("StateAttribute").Index(cty.StringVal("valueA")),
+			Value: cty.StringVal("valueA"),
+		},
+		"list nested block attribute": {
+			RawState: cty.ObjectVal(map[string]cty.Value{
+				"list_nested_block": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"StateAttribute": cty.StringVal("valueA"),
+					}),
+					cty.ObjectVal(map[string]cty.Value{
+						"StateAttribute": cty.StringVal("valueB"),
+					}),
+				}),
+			}),
+			Path:  cty.GetAttrPath("list_nested_block").IndexInt(1).GetAttr("StateAttribute"),
+			Value: cty.StringVal("valueB"),
+		},
+		"list nested block index out of range returns error": {
+			RawState: cty.ObjectVal(map[string]cty.Value{
+				"list_nested_block": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"StateAttribute": cty.StringVal("valueA"),
+					}),
+				}),
+			}),
+			Path:        cty.GetAttrPath("list_nested_block").IndexInt(1).GetAttr("StateAttribute"),
+			Value:       cty.DynamicVal,
+			ExpectedErr: "cannot retrieve state value at list_nested_block.1.StateAttribute: no value exists at the given path",
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			d := &ResourceData{
+				state: &terraform.InstanceState{
+					RawState: tc.RawState,
+				},
+			}
+
+			v, err := d.GetRawStateAt(tc.Path)
+			if err != nil && tc.ExpectedErr == "" {
+				t.Fatalf("expected no error but got %s", err)
+			}
+
+			if tc.ExpectedErr != "" && (err == nil || err.Error() != tc.ExpectedErr) {
+				t.Fatalf("expected error %q but got %v", tc.ExpectedErr, err)
+			}
+
+			if !reflect.DeepEqual(v, tc.Value) {
+				t.Errorf("Bad: %s\n\n%#v\n\nExpected: %#v", tn, v, tc.Value)
+			}
+		})
+	}
+}
+
+func TestResourceDataGetConfigValue(t *testing.T) {
+	t.Parallel()
+
+	rawConfig := cty.ObjectVal(map[string]cty.Value{
+		"null":    cty.NullVal(cty.String),
+		"empty":   cty.StringVal(""),
+		"string":  cty.StringVal("value"),
+		"bool":    cty.False,
+		"unknown": cty.UnknownVal(cty.String),
+		"list": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{
+				"attr": cty.StringVal("valueA"),
+			}),
+		}),
+		"null_list": cty.NullVal(cty.List(cty.Object(map[string]cty.Type{
+			"attr": cty.String,
+		}))),
+		"map": cty.MapVal(map[string]cty.Value{
+			"key": cty.StringVal("valueB"),
+		}),
+		"set": cty.SetVal([]cty.Value{
+			cty.StringVal("valueC"),
+		}),
+	})
+
+	cases := map[string]struct {
+		Key   string
+		Value cty.Value
+		Ok    bool
+	}{
+		"null": {
+			Key:   "null",
+			Value: cty.NullVal(cty.String),
+		},
+		"empty string": {
+			Key:   "empty",
+			Value: cty.StringVal(""),
+			Ok:    true,
+		},
+		"set": {
+			Key:   "string",
+			Value: cty.StringVal("value"),
+			Ok:    true,
+		},
+		"false": {
+			Key:   "bool",
+			Value: cty.False,
+			Ok:    true,
+		},
+		"unknown": {
+			Key:   "unknown",
+			Value: cty.UnknownVal(cty.String),
+		},
+		"list element attribute": {
+			Key:   "list.0.attr",
+			Value: cty.StringVal("valueA"),
+			Ok:    true,
+		},
+		"list element out of range": {
+			Key:   "list.1.attr",
+			Value: cty.NullVal(cty.String),
+		},
+		"null list element attribute": {
+			Key:   "null_list.0.attr",
+			Value: cty.NullVal(cty.String),
+		},
+		"map key": {
+			Key:   "map.key",
+			Value: cty.StringVal("valueB"),
+			Ok:    true,
+		},
+		"missing map key": {
+			Key:   "map.missing",
+			Value: cty.NullVal(cty.String),
+		},
+		"whole set": {
+			Key: "set",
+			Value: cty.SetVal([]cty.Value{
+				cty.StringVal("valueC"),
+			}),
+			Ok: true,
+		},
+		"set element": {
+			Key:   "set.0",
+			Value: cty.NilVal,
+		},
+		"invalid key": {
+			Key:   "invalid",
+			Value: cty.NilVal,
+		},
+		"invalid list index": {
+			Key:   "list.invalid.attr",
+			Value: cty.NilVal,
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			t.Parallel()
+
+			d := &ResourceData{
+				diff: &terraform.InstanceDiff{
+					RawConfig: rawConfig,
+				},
+			}
+
+			v, ok := d.GetConfigValue(tc.Key)
+
+			if ok != tc.Ok {
+				t.Errorf("expected ok to be %t, got %t", tc.Ok, ok)
+			}
+
+			if tc.Value == cty.NilVal {
+				if v != cty.NilVal {
+					t.Errorf("expected cty.NilVal, got %#v", v)
+				}
+
+				return
+			}
+
+			if v == cty.NilVal || !v.RawEquals(tc.Value) {
+				t.Errorf("expected value %#v, got %#v", tc.Value, v)
+			}
+		})
+	}
+}
+
+func TestResourceDataSetAll(t *testing.T) {
+	t.Parallel()
+
+	d, err := schemaMap(map[string]*Schema{
+		"name": {
+			Type:     TypeString,
+			Optional: true,
+		},
+		"ports": {
+			Type:     TypeList,
+			Optional: true,
+			Elem:     &Schema{Type: TypeInt},
+		},
+		"size": {
+			Type:     TypeInt,
+			Optional: true,
+		},
+	}).Data(nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	diags := d.SetAll(map[string]interface{}{
+		"name":  "example",
+		"ports": "not-a-list",
+		"size":  3,
+	})
+
+	if len(diags) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d: %#v", len(diags), diags)
+	}
+
+	if diags[0].Severity != diag.Error {
+		t.Errorf("expected error severity, got %v", diags[0].Severity)
+	}
+
+	if expected := `Error setting "ports"`; diags[0].Summary != expected {
+		t.Errorf("expected summary %q, got %q", expected, diags[0].Summary)
+	}
+
+	if v := d.Get("name"); v != "example" {
+		t.Errorf("expected name to be set, got %#v", v)
+	}
+
+	if v := d.Get("size"); v != 3 {
+		t.Errorf("expected size to be set, got %#v", v)
+	}
+}
+
+func TestResourceDataSetAt(t *testing.T) {
+	t.Parallel()
+
+	testSchema := map[string]*Schema{
+		"block": {
+			Type:     TypeList,
+			Optional: true,
+			Elem: &Resource{
+				Schema: map[string]*Schema{
+					"attr": {
+						Type:     TypeString,
+						Optional: true,
+					},
+				},
+			},
+		},
+		"tags": {
+			Type:     TypeMap,
+			Optional: true,
+			Elem:     &Schema{Type: TypeString},
+		},
+		"ports": {
+			Type:     TypeSet,
+			Optional: true,
+			Elem:     &Schema{Type: TypeInt},
+		},
+	}
+
+	testState := &terraform.InstanceState{
+		ID: "test",
+		Attributes: map[string]string{
+			"id":           "test",
+			"block.#":      "1",
+			"block.0.attr": "old",
+			"tags.%":       "1",
+			"tags.env":     "dev",
+		},
+	}
+
+	cases := map[string]struct {
+		Path        cty.Path
+		Value       interface{}
+		Key         string
+		ExpectedErr string
+	}{
+		"list block element attribute": {
+			Path:  cty.GetAttrPath("block").IndexInt(0).GetAttr("attr"),
+			Value: "new",
+			Key:   "block.0.attr",
+		},
+		"map element": {
+			Path:  cty.GetAttrPath("tags").IndexString("env"),
+			Value: "prod",
+			Key:   "tags.env",
+		},
+		"unknown attribute": {
+			Path:        cty.GetAttrPath("block").IndexInt(0).GetAttr("invalid"),
+			Value:       "new",
+			ExpectedErr: `SetAt: .block[0].invalid: unsupported attribute "invalid"`,
+		},
+		"string index for list": {
+			Path:        cty.GetAttrPath("block").IndexString("a").GetAttr("attr"),
+			Value:       "new",
+			ExpectedErr: "SetAt: .block[\"a\"]: list index must be a number",
+		},
+		"list index out of range": {
+			Path:        cty.GetAttrPath("block").IndexInt(1).GetAttr("attr"),
+			Value:       "new",
+			ExpectedErr: "SetAt: .block[1]: list index out of range",
+		},
+		"set element": {
+			Path:        cty.GetAttrPath("ports").IndexInt(0),
+			Value:       80,
+			ExpectedErr: "SetAt: .ports[0]: set elements cannot be addressed",
+		},
+		"attribute step on primitive": {
+			Path:        cty.GetAttrPath("tags").IndexString("env").GetAttr("attr"),
+			Value:       "new",
+			ExpectedErr: `SetAt: .tags["env"].attr: attribute step is not valid for TypeString`,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d, err := schemaMap(testSchema).Data(testState, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			err = d.SetAt(tc.Path, tc.Value)
+
+			if tc.ExpectedErr != "" {
+				if err == nil {
+					t.Fatalf("expected error %q, got none", tc.ExpectedErr)
+				}
+
+				if err.Error() != tc.ExpectedErr {
+					t.Fatalf("expected error %q, got %q", tc.ExpectedErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if v := d.Get(tc.Key); v != tc.Value {
+				t.Fatalf("expected %q to be %#v, got %#v", tc.Key, tc.Value, v)
+			}
+		})
+	}
+}
+
 func TestResourceDataSetConnInfo(t *testing.T) {
 	d := &ResourceData{}
 	d.SetId("foo")
@@ -4312,6 +5291,148 @@ func TestResourceDataIdentity_no_schema(t *testing.T) {
 	if diff := cmp.Diff("Resource does not have Identity schema. Please set one in order to use Identity(). This is always a problem in the provider code.", err.Error()); diff != "" {
 		t.Fatalf("unexpected error message (-want +got):\n%s", diff)
 	}
+	if !errors.Is(err, ErrNoIdentitySchema) {
+		t.Fatalf("expected ErrNoIdentitySchema, got: %s", err)
+	}
+	if d.HasIdentity() {
+		t.Fatal("expected HasIdentity to be false")
+	}
+}
+
+func TestResourceDataMustIdentity(t *testing.T) {
+	d := &ResourceData{
+		identitySchema: map[string]*Schema{
+			"foo": {
+				Type:              TypeString,
+				RequiredForImport: true,
+			},
+		},
+	}
+
+	if !d.HasIdentity() {
+		t.Fatal("expected HasIdentity to be true")
+	}
+
+	if err := d.MustIdentity().Set("foo", "bar"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := d.MustIdentity().Get("foo"); got != "bar" {
+		t.Fatalf("expected identity to contain bar value for foo, got: %#v", got)
+	}
+}
+
+func TestResourceDataMustIdentity_no_schema(t *testing.T) {
+	d := &ResourceData{}
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("expected panic since there's no identity schema")
+		}
+
+		expected := "MustIdentity: " + ErrNoIdentitySchema.Error()
+		if diff := cmp.Diff(expected, r); diff != "" {
+			t.Fatalf("unexpected panic (-want +got):\n%s", diff)
+		}
+	}()
+
+	d.MustIdentity()
+}
+
+func TestResourceDataSetRawState(t *testing.T) {
+	t.Parallel()
+
+	testSchema := map[string]*Schema{
+		"name": {
+			Type:     TypeString,
+			Optional: true,
+		},
+		"ports": {
+			Type:     TypeSet,
+			Optional: true,
+			Elem:     &Schema{Type: TypeInt},
+		},
+	}
+
+	cases := map[string]struct {
+		Value       cty.Value
+		ExpectedErr string
+	}{
+		"valid": {
+			Value: cty.ObjectVal(map[string]cty.Value{
+				"name":  cty.StringVal("example"),
+				"ports": cty.SetVal([]cty.Value{cty.NumberIntVal(80), cty.NumberIntVal(443)}),
+			}),
+		},
+		"null": {
+			Value:       cty.NullVal(cty.Object(map[string]cty.Type{"name": cty.String, "ports": cty.Set(cty.Number)})),
+			ExpectedErr: "SetRawState: value must not be null",
+		},
+		"unknown": {
+			Value: cty.ObjectVal(map[string]cty.Value{
+				"name":  cty.UnknownVal(cty.String),
+				"ports": cty.NullVal(cty.Set(cty.Number)),
+			}),
+			ExpectedErr: "SetRawState: value must be wholly known",
+		},
+		"wrong type": {
+			Value: cty.ObjectVal(map[string]cty.Value{
+				"name":  cty.StringVal("example"),
+				"ports": cty.ListVal([]cty.Value{cty.NumberIntVal(80)}),
+			}),
+			ExpectedErr: "SetRawState: value does not conform to the resource schema: .ports: set of number required, but received list of number",
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			d, err := schemaMap(testSchema).Data(nil, nil)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			d.SetId("foo")
+
+			err = d.SetRawState(tc.Value)
+			if tc.ExpectedErr != "" {
+				if err == nil || err.Error() != tc.ExpectedErr {
+					t.Fatalf("expected error %q, got: %v", tc.ExpectedErr, err)
+				}
+				if !d.State().RawState.IsNull() {
+					t.Fatal("expected no raw state after error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			if got := d.Get("name"); got != "example" {
+				t.Fatalf("unexpected name: %#v", got)
+			}
+			if got := d.Get("ports").(*Set).Len(); got != 2 {
+				t.Fatalf("unexpected number of ports: %d", got)
+			}
+
+			state := d.State()
+			if !state.RawState.RawEquals(tc.Value) {
+				t.Fatalf("unexpected raw state: %#v", state.RawState)
+			}
+			if state.Attributes["ports.#"] != "2" {
+				t.Fatalf("unexpected state attributes: %#v", state.Attributes)
+			}
+
+			// Set discards the raw state
+			if err := d.Set("name", "other"); err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !d.State().RawState.IsNull() {
+				t.Fatal("expected Set to discard the raw state")
+			}
+		})
+	}
 }
 
 func testPtrTo(raw interface{}) interface{} {
@@ -4,15 +4,20 @@
 package schema
 
 import (
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 	"sync"
 
 	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/configs/hcl2shim"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
@@ -69,6 +74,29 @@ func (w *newValueWriter) ComputedKeysMap() map[string]bool {
 	return w.computedKeys
 }
 
+// clearField removes the value written for the key and any of its sub-fields,
+// including whether they were flagged as computed.
+func (w *newValueWriter) clearField(key string) {
+	m := w.Map()
+	w.MapFieldWriter.lock.Lock()
+	for k := range m {
+		if k == key || childAddrOf(k, key) {
+			delete(m, k)
+		}
+	}
+	w.MapFieldWriter.lock.Unlock()
+
+	w.once.Do(w.init)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	for k := range w.computedKeys {
+		if k == key || childAddrOf(k, key) {
+			delete(w.computedKeys, k)
+		}
+	}
+}
+
 // newValueReader is a minor re-implementation of MapFieldReader and is the
 // read counterpart to MapValueWriter, allowing the read of keys flagged as
 // computed to accommodate the diff override logic in ResourceDiff.
@@ -137,6 +165,10 @@ type ResourceDiff struct {
 	// diff, and the new diff.
 	multiReader *MultiLevelFieldReader
 
+	// The context passed to Schema.DefaultContextFunc when reading defaults
+	// from the config.
+	ctx context.Context
+
 	// A writer that writes overridden new fields.
 	newWriter *newValueWriter
 
@@ -145,16 +177,26 @@ type ResourceDiff struct {
 	// just removed (re-running on the latter would just roll back the removal).
 	updatedKeys map[string]bool
 
+	// The diff attributes removed when the new value of a key was first set,
+	// so that they can be restored by ResetNew.
+	originalDiffs map[string]map[string]*terraform.ResourceAttrDiff
+
 	// Tracks which keys were flagged as forceNew. These keys are not saved in
 	// newWriter, but we need to track them so that they can be re-diffed later.
 	forcedNewKeys map[string]bool
 
+	// Tracks paths flagged via SetRequiresReplace. Unlike forcedNewKeys, these
+	// do not alter the schema and are only passed back to Terraform as part of
+	// the plan.
+	requiresReplace []*tftypes.AttributePath
+
 	newIdentity *IdentityData
 }
 
 // newResourceDiff creates a new ResourceDiff instance.
-func newResourceDiff(schema schemaMapWithIdentity, config *terraform.ResourceConfig, state *terraform.InstanceState, diff *terraform.InstanceDiff) *ResourceDiff {
+func newResourceDiff(ctx context.Context, schema schemaMapWithIdentity, config *terraform.ResourceConfig, state *terraform.InstanceState, diff *terraform.InstanceDiff) *ResourceDiff {
 	d := &ResourceDiff{
+		ctx:            ctx,
 		config:         config,
 		state:          state,
 		diff:           diff,
@@ -178,6 +220,7 @@ func newResourceDiff(schema schemaMapWithIdentity, config *terraform.ResourceCon
 		readers["config"] = &ConfigFieldReader{
 			Schema: d.schema,
 			Config: d.config,
+			ctx:    d.ctx,
 		}
 	}
 	if d.diff != nil {
@@ -209,6 +252,7 @@ func newResourceDiff(schema schemaMapWithIdentity, config *terraform.ResourceCon
 	}
 
 	d.updatedKeys = make(map[string]bool)
+	d.originalDiffs = make(map[string]map[string]*terraform.ResourceAttrDiff)
 	d.forcedNewKeys = make(map[string]bool)
 
 	return d
@@ -240,8 +284,8 @@ func (d *ResourceDiff) UpdatedKeys() []string {
 // functionality to remove any possibility of conflicts, but can be called on
 // its own to just remove a specific key from the diff completely.
 //
-// Note that this does not wipe an override. This function is only allowed on
-// computed keys.
+// Note that this does not wipe an override, use ResetNew for that. This
+// function is only allowed on computed keys.
 func (d *ResourceDiff) Clear(key string) error {
 	if err := d.checkKey(key, "Clear", true); err != nil {
 		return err
@@ -318,8 +362,55 @@ func (d *ResourceDiff) SetNewComputed(key string) error {
 	return d.setDiff(key, nil, true)
 }
 
+// ResetNew discards the new value set for the key with SetNew or
+// SetNewComputed, restoring the diff that was planned for the key before it
+// was set. This can be used when a later condition in CustomizeDiff means the
+// value set earlier no longer applies. It is a no-op if no new value was set
+// for the key.
+//
+// ResetNew does not undo ForceNew. If ForceNew was called for the key, the
+// restored diff will still force a new resource if it has a change.
+//
+// This function is only allowed on computed attributes.
+func (d *ResourceDiff) ResetNew(key string) error {
+	if err := d.checkKey(key, "ResetNew", false); err != nil {
+		return err
+	}
+
+	original, ok := d.originalDiffs[key]
+	if !ok {
+		return nil
+	}
+
+	if err := d.clear(key); err != nil {
+		return err
+	}
+
+	d.newWriter.clearField(key)
+
+	for k, attrDiff := range original {
+		d.diff.Attributes[k] = attrDiff
+	}
+
+	delete(d.originalDiffs, key)
+	delete(d.updatedKeys, key)
+
+	return nil
+}
+
 // setDiff performs common diff setting behaviour.
 func (d *ResourceDiff) setDiff(key string, newValue interface{}, computed bool) error {
+	if _, ok := d.originalDiffs[key]; !ok && d.diff != nil {
+		original := make(map[string]*terraform.ResourceAttrDiff)
+		for k, attrDiff := range d.diff.Attributes {
+			if k == key || childAddrOf(k, key) {
+				original[k] = attrDiff
+			}
+		}
+
+		d.originalDiffs[key] = original
+	}
+
 	if err := d.clear(key); err != nil {
 		return err
 	}
@@ -333,6 +424,75 @@ func (d *ResourceDiff) setDiff(key string, newValue interface{}, computed bool)
 	return nil
 }
 
+// SetPrivate stores a value in the private state of the planned change, which
+// is not shown to practitioners. The value can then be read with GetPrivate
+// on the ResourceData when the change is applied, such as to pass data that
+// was looked up during planning to the apply. A nil value removes the key.
+//
+// Private values are only kept between the plan and the apply of a change
+// and are not stored in the resulting state. They are also not kept if the
+// plan has no changes.
+func (d *ResourceDiff) SetPrivate(key string, value []byte) error {
+	if d.diff == nil {
+		return fmt.Errorf("SetPrivate: no diff to store private value for key %s", key)
+	}
+
+	if d.diff.Meta == nil {
+		d.diff.Meta = make(map[string]interface{})
+	}
+
+	private, ok := d.diff.Meta[privateKey].(map[string]interface{})
+	if !ok {
+		private = make(map[string]interface{})
+		d.diff.Meta[privateKey] = private
+	}
+
+	if value == nil {
+		delete(private, key)
+		return nil
+	}
+
+	private[key] = base64.StdEncoding.EncodeToString(value)
+
+	return nil
+}
+
+// GetPrivate returns the value stored with SetPrivate for the key, or nil if
+// no value was stored.
+func (d *ResourceDiff) GetPrivate(key string) ([]byte, error) {
+	if d.diff == nil {
+		return nil, nil
+	}
+
+	return privateValue(d.diff.Meta, key)
+}
+
+// privateValue returns the value stored with ResourceDiff.SetPrivate for the
+// key in the given diff metadata, or nil if no value was stored.
+func privateValue(meta map[string]interface{}, key string) ([]byte, error) {
+	private, ok := meta[privateKey].(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	raw, ok := private[key]
+	if !ok {
+		return nil, nil
+	}
+
+	encoded, ok := raw.(string)
+	if !ok {
+		return nil, fmt.Errorf("private value for key %s has unexpected type %T", key, raw)
+	}
+
+	value, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decoding private value for key %s: %w", key, err)
+	}
+
+	return value, nil
+}
+
 // ForceNew force-flags ForceNew in the schema for a specific key, and
 // re-calculates its diff, effectively causing this attribute to force a new
 // resource.
@@ -363,6 +523,7 @@ func (d *ResourceDiff) ForceNew(key string) error {
 	}
 
 	schema.ForceNew = true
+	schema.ForceNewFunc = nil
 
 	// Flag this for a re-diff. Don't save any values to guarantee that existing
 	// diffs aren't messed with, as this gets messy when dealing with complex
@@ -372,6 +533,129 @@ func (d *ResourceDiff) ForceNew(key string) error {
 	return nil
 }
 
+// SetRequiresReplace flags the given attribute paths as requiring replacement
+// of the resource if their values change. Unlike ForceNew, this does not
+// modify the schema or trigger a re-diff; the paths are only merged into the
+// RequiresReplace paths of the plan returned to Terraform.
+//
+// Paths may address set elements by value with ElementKeyValue steps. These
+// are kept in the plan, although Terraform only receives the prefix of the
+// path before the set element step, as the protocol cannot represent it.
+func (d *ResourceDiff) SetRequiresReplace(paths ...*tftypes.AttributePath) error {
+	for _, path := range paths {
+		if path == nil || len(path.Steps()) == 0 {
+			return errors.New("SetRequiresReplace: path must not be empty")
+		}
+
+		name, ok := path.Steps()[0].(tftypes.AttributeName)
+		if !ok {
+			return fmt.Errorf("SetRequiresReplace: path %s must begin with an attribute name", path)
+		}
+
+		if _, ok := d.schema[string(name)]; !ok {
+			return fmt.Errorf("SetRequiresReplace: %s is not a valid key", name)
+		}
+
+		d.requiresReplace = append(d.requiresReplace, path)
+	}
+
+	return nil
+}
+
+// CheckConstraints evaluates the ConflictsWith, ExactlyOneOf, AtLeastOneOf,
+// and RequiredWith constraints of the top level attributes in the schema
+// against the current diff, including any values changed with SetNew or
+// SetNewComputed. Unlike configuration validation, this allows constraints
+// to be enforced against computed values.
+//
+// Attributes with unknown values are treated the same as during
+// configuration validation, so the constraints are only enforced once the
+// values are known.
+func (d *ResourceDiff) CheckConstraints() diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	c := resourceDiffConstraintReader{d: d}
+
+	keys := make([]string, 0, len(d.schema))
+	for k := range d.schema {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		schema := d.schema[k]
+		path := cty.GetAttrPath(k)
+
+		if err := validateExactlyOneAttribute(k, schema, d.schema, c); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       "Invalid combination of arguments",
+				Detail:        err.Error(),
+				AttributePath: path,
+			})
+			continue
+		}
+
+		if err := validateAtLeastOneAttribute(k, schema, c); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       "Missing required argument",
+				Detail:        err.Error(),
+				AttributePath: path,
+			})
+			continue
+		}
+
+		if _, ok := c.Get(k); !ok {
+			continue
+		}
+
+		if err := validateRequiredWithAttribute(k, schema, d.schema, c); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       "Missing required argument",
+				Detail:        err.Error(),
+				AttributePath: path,
+			})
+			continue
+		}
+
+		if c.IsComputed(k) {
+			continue
+		}
+
+		if err := validateConflictingAttributes(k, schema, d.schema, c); err != nil {
+			diags = append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       "Conflicting configuration arguments",
+				Detail:        err.Error(),
+				AttributePath: path,
+			})
+		}
+	}
+
+	return diags
+}
+
+// resourceDiffConstraintReader implements constraintValueReader for a
+// ResourceDiff. Values are considered set if they are unknown, or if they
+// are known and not the zero value.
+type resourceDiffConstraintReader struct {
+	d *ResourceDiff
+}
+
+func (r resourceDiffConstraintReader) Get(k string) (interface{}, bool) {
+	if r.IsComputed(k) {
+		return hcl2shim.UnknownVariableValue, true
+	}
+
+	return r.d.GetOk(k)
+}
+
+func (r resourceDiffConstraintReader) IsComputed(k string) bool {
+	return !r.d.NewValueKnown(k)
+}
+
 // Get hands off to ResourceData.Get.
 func (d *ResourceDiff) Get(key string) interface{} {
 	r, _ := d.GetOk(key)
@@ -689,12 +973,18 @@ func (d *ResourceDiff) checkKey(key, caller string, nested bool) error {
 	return nil
 }
 
+// Identity returns the identity data of the resource. If the resource does
+// not have an Identity schema, ErrNoIdentitySchema is returned.
 func (d *ResourceDiff) Identity() (*IdentityData, error) {
 	// return memoized value if available
 	if d.newIdentity != nil {
 		return d.newIdentity, nil
 	}
 
+	if d.identitySchema == nil {
+		return nil, ErrNoIdentitySchema
+	}
+
 	identity := map[string]string{}
 	if d.state != nil && d.state.Identity != nil {
 		identity = d.state.Identity
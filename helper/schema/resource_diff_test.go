@@ -5,6 +5,7 @@ package schema
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"sort"
@@ -646,7 +647,7 @@ func TestSetNew(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
 			m := schemaMap(tc.Schema)
-			d := newResourceDiff(schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, tc.Config, tc.State, tc.Diff)
+			d := newResourceDiff(context.Background(), schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, tc.Config, tc.State, tc.Diff)
 			err := d.SetNew(tc.Key, tc.NewValue)
 			switch {
 			case err != nil && !tc.ExpectedError:
@@ -673,7 +674,7 @@ func TestSetNewComputed(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.Name, func(t *testing.T) {
 			m := schemaMap(tc.Schema)
-			d := newResourceDiff(schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, tc.Config, tc.State, tc.Diff)
+			d := newResourceDiff(context.Background(), schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, tc.Config, tc.State, tc.Diff)
 			err := d.SetNewComputed(tc.Key)
 			switch {
 			case err != nil && !tc.ExpectedError:
@@ -942,7 +943,7 @@ func TestForceNew(t *testing.T) {
 	for _, tc := range cases {
 		t.Run(tc.Name, func(t *testing.T) {
 			m := schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}
-			d := newResourceDiff(m, tc.Config, tc.State, tc.Diff)
+			d := newResourceDiff(context.Background(), m, tc.Config, tc.State, tc.Diff)
 			err := d.ForceNew(tc.Key)
 			switch {
 			case err != nil && !tc.ExpectedError:
@@ -1191,7 +1192,7 @@ func TestClear(t *testing.T) {
 	for _, tc := range cases {
 		t.Run(tc.Name, func(t *testing.T) {
 			m := schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}
-			d := newResourceDiff(m, tc.Config, tc.State, tc.Diff)
+			d := newResourceDiff(context.Background(), m, tc.Config, tc.State, tc.Diff)
 			err := d.Clear(tc.Key)
 			switch {
 			case err != nil && !tc.ExpectedError:
@@ -1440,7 +1441,7 @@ func TestGetChangedKeysPrefix(t *testing.T) {
 	for _, tc := range cases {
 		t.Run(tc.Name, func(t *testing.T) {
 			m := schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}
-			d := newResourceDiff(m, tc.Config, tc.State, tc.Diff)
+			d := newResourceDiff(context.Background(), m, tc.Config, tc.State, tc.Diff)
 			keys := d.GetChangedKeysPrefix(tc.Key)
 
 			for _, k := range d.UpdatedKeys() {
@@ -1816,7 +1817,7 @@ func TestResourceDiffGetOkExists(t *testing.T) {
 
 	for i, tc := range cases {
 		t.Run(fmt.Sprintf("%d-%s", i, tc.Name), func(t *testing.T) {
-			d := newResourceDiff(schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, tc.Config, tc.State, tc.Diff)
+			d := newResourceDiff(context.Background(), schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, tc.Config, tc.State, tc.Diff)
 
 			v, ok := d.GetOkExists(tc.Key)
 			if s, ok := v.(*Set); ok {
@@ -1862,7 +1863,7 @@ func TestResourceDiffGetOkExistsSetNew(t *testing.T) {
 		Ok:    true,
 	}
 
-	d := newResourceDiff(schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, testConfig(t, map[string]interface{}{}), tc.State, tc.Diff)
+	d := newResourceDiff(context.Background(), schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, testConfig(t, map[string]interface{}{}), tc.State, tc.Diff)
 
 	if err := d.SetNew(tc.Key, tc.Value); err != nil {
 		t.Fatalf("unexpected SetNew error: %s", err)
@@ -1914,7 +1915,7 @@ func TestResourceDiffGetOkExistsSetNewComputed(t *testing.T) {
 		Ok:    false,
 	}
 
-	d := newResourceDiff(schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, testConfig(t, map[string]interface{}{}), tc.State, tc.Diff)
+	d := newResourceDiff(context.Background(), schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, testConfig(t, map[string]interface{}{}), tc.State, tc.Diff)
 
 	if err := d.SetNewComputed(tc.Key); err != nil {
 		t.Fatalf("unexpected SetNewComputed error: %s", err)
@@ -2107,7 +2108,7 @@ func TestResourceDiffNewValueKnown(t *testing.T) {
 
 	for i, tc := range cases {
 		t.Run(fmt.Sprintf("%d-%s", i, tc.Name), func(t *testing.T) {
-			d := newResourceDiff(schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, tc.Config, tc.State, tc.Diff)
+			d := newResourceDiff(context.Background(), schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, tc.Config, tc.State, tc.Diff)
 
 			actual := d.NewValueKnown(tc.Key)
 			if tc.Expected != actual {
@@ -2160,7 +2161,7 @@ func TestResourceDiffNewValueKnownSetNew(t *testing.T) {
 		Expected: true,
 	}
 
-	d := newResourceDiff(schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, tc.Config, tc.State, tc.Diff)
+	d := newResourceDiff(context.Background(), schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, tc.Config, tc.State, tc.Diff)
 
 	if err := d.SetNew(tc.Key, tc.Value); err != nil {
 		t.Fatalf("unexpected SetNew error: %s", err)
@@ -2201,7 +2202,7 @@ func TestResourceDiffNewValueKnownSetNewComputed(t *testing.T) {
 		Expected: false,
 	}
 
-	d := newResourceDiff(schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, tc.Config, tc.State, tc.Diff)
+	d := newResourceDiff(context.Background(), schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, tc.Config, tc.State, tc.Diff)
 
 	if err := d.SetNewComputed(tc.Key); err != nil {
 		t.Fatalf("unexpected SetNewComputed error: %s", err)
@@ -2309,7 +2310,7 @@ func TestResourceDiffHasChanges(t *testing.T) {
 	}
 
 	for i, tc := range cases {
-		d := newResourceDiff(schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, testConfig(t, map[string]interface{}{}), tc.State, tc.Diff)
+		d := newResourceDiff(context.Background(), schemaMapWithIdentity{tc.Schema, tc.IdentitySchema}, testConfig(t, map[string]interface{}{}), tc.State, tc.Diff)
 
 		actual := d.HasChanges(tc.Keys...)
 		if actual != tc.Change {
@@ -2417,3 +2418,214 @@ func TestResourceDiffGetRawConfigAt(t *testing.T) {
 		})
 	}
 }
+
+func TestResourceDiffCheckConstraints(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		Schema    map[string]*Schema
+		State     *terraform.InstanceState
+		Config    map[string]interface{}
+		Customize func(*ResourceDiff) error
+		Expected  diag.Diagnostics
+	}{
+		"no constraints": {
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:     TypeString,
+					Optional: true,
+				},
+			},
+			Config: map[string]interface{}{
+				"foo": "bar",
+			},
+		},
+		"ConflictsWith after SetNew": {
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:          TypeString,
+					Optional:      true,
+					ConflictsWith: []string{"bar"},
+				},
+				"bar": {
+					Type:     TypeString,
+					Optional: true,
+					Computed: true,
+				},
+			},
+			Config: map[string]interface{}{
+				"foo": "foo",
+			},
+			Customize: func(d *ResourceDiff) error {
+				return d.SetNew("bar", "bar")
+			},
+			Expected: diag.Diagnostics{
+				{
+					Severity:      diag.Error,
+					Summary:       "Conflicting configuration arguments",
+					Detail:        `"foo": conflicts with bar`,
+					AttributePath: cty.GetAttrPath("foo"),
+				},
+			},
+		},
+		"ConflictsWith after SetNewComputed": {
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:          TypeString,
+					Optional:      true,
+					ConflictsWith: []string{"bar"},
+				},
+				"bar": {
+					Type:     TypeString,
+					Optional: true,
+					Computed: true,
+				},
+			},
+			State: &terraform.InstanceState{
+				Attributes: map[string]string{
+					"bar": "bar",
+				},
+			},
+			Config: map[string]interface{}{
+				"foo": "foo",
+			},
+			Customize: func(d *ResourceDiff) error {
+				return d.SetNewComputed("bar")
+			},
+		},
+		"ExactlyOneOf with unknown and known values": {
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:         TypeString,
+					Optional:     true,
+					Computed:     true,
+					ExactlyOneOf: []string{"foo", "bar"},
+				},
+				"bar": {
+					Type:         TypeString,
+					Optional:     true,
+					Computed:     true,
+					ExactlyOneOf: []string{"foo", "bar"},
+				},
+			},
+			Config: map[string]interface{}{},
+			Customize: func(d *ResourceDiff) error {
+				if err := d.SetNewComputed("foo"); err != nil {
+					return err
+				}
+
+				return d.SetNew("bar", "bar")
+			},
+		},
+		"ExactlyOneOf none set": {
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:         TypeString,
+					Optional:     true,
+					ExactlyOneOf: []string{"foo", "bar"},
+				},
+				"bar": {
+					Type:     TypeString,
+					Optional: true,
+				},
+			},
+			Config: map[string]interface{}{},
+			Expected: diag.Diagnostics{
+				{
+					Severity:      diag.Error,
+					Summary:       "Invalid combination of arguments",
+					Detail:        "\"foo\": one of `bar,foo` must be specified",
+					AttributePath: cty.GetAttrPath("foo"),
+				},
+			},
+		},
+		"AtLeastOneOf after SetNew": {
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:         TypeString,
+					Optional:     true,
+					Computed:     true,
+					AtLeastOneOf: []string{"bar"},
+				},
+				"bar": {
+					Type:     TypeString,
+					Optional: true,
+					Computed: true,
+				},
+			},
+			Config: map[string]interface{}{},
+			Customize: func(d *ResourceDiff) error {
+				return d.SetNew("bar", "bar")
+			},
+		},
+		"RequiredWith after SetNew": {
+			Schema: map[string]*Schema{
+				"foo": {
+					Type:         TypeString,
+					Optional:     true,
+					Computed:     true,
+					RequiredWith: []string{"bar"},
+				},
+				"bar": {
+					Type:     TypeString,
+					Optional: true,
+				},
+			},
+			Config: map[string]interface{}{},
+			Customize: func(d *ResourceDiff) error {
+				return d.SetNew("foo", "foo")
+			},
+			Expected: diag.Diagnostics{
+				{
+					Severity:      diag.Error,
+					Summary:       "Missing required argument",
+					Detail:        "\"foo\": all of `bar,foo` must be specified",
+					AttributePath: cty.GetAttrPath("foo"),
+				},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := terraform.NewResourceConfigRaw(tc.Config)
+
+			diff, err := schemaMap(tc.Schema).Diff(context.Background(), tc.State, c, nil, nil, false)
+			if err != nil {
+				t.Fatalf("unexpected diff error: %s", err)
+			}
+
+			if diff == nil {
+				diff = terraform.NewInstanceDiff()
+			}
+
+			d := newResourceDiff(context.Background(), schemaMapWithIdentity{tc.Schema, nil}, c, tc.State, diff)
+
+			if tc.Customize != nil {
+				if err := tc.Customize(d); err != nil {
+					t.Fatalf("unexpected customize error: %s", err)
+				}
+			}
+
+			diags := d.CheckConstraints()
+
+			if diff := cmp.Diff(tc.Expected, diags,
+				cmp.AllowUnexported(cty.GetAttrStep{}, cty.IndexStep{}),
+				cmp.Comparer(indexStepComparer),
+			); diff != "" {
+				t.Errorf("Unexpected diagnostics (-wanted +got): %s", diff)
+			}
+		})
+	}
+}
+
+func TestResourceDiffIdentity_no_schema(t *testing.T) {
+	d := newResourceDiff(context.Background(), schemaMapWithIdentity{}, testConfig(t, map[string]interface{}{}), nil, nil)
+
+	_, err := d.Identity()
+	if !errors.Is(err, ErrNoIdentitySchema) {
+		t.Fatalf("expected ErrNoIdentitySchema, got: %v", err)
+	}
+}
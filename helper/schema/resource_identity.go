@@ -26,6 +26,13 @@ type IdentityUpgrader struct {
 	// is up to the StateUpgradeFunc to ensure that the returned value can be
 	// encoded using the new schema.
 	Upgrade ResourceIdentityUpgradeFunc
+
+	// Passthrough declares that the identity data of Version is compatible
+	// with every version up to the Version of the next IdentityUpgrader, or
+	// the current identity Version if there is no next IdentityUpgrader. The
+	// identity data is passed through unchanged to that version, so Upgrade
+	// must not be set.
+	Passthrough bool
 }
 
 type ResourceIdentity struct {
@@ -44,7 +51,11 @@ type ResourceIdentity struct {
 	//   - TypeList (of any of the above types)
 	SchemaFunc func() map[string]*Schema
 
-	// New struct, will be similar to (Resource).StateUpgraders
+	// IdentityUpgraders contains the functions responsible for upgrading an
+	// existing identity with an old schema version to a newer schema. They
+	// must be ordered by Version and cover every version from the first
+	// IdentityUpgrader up to the current Version, except for the versions
+	// covered by a Passthrough IdentityUpgrader.
 	IdentityUpgraders []IdentityUpgrader
 }
 
@@ -29,6 +29,14 @@ type ResourceImporter struct {
 	// the ID is passed straight through. This function receives a context
 	// that will cancel if Terraform sends a cancellation signal.
 	StateContext StateContextFunc
+
+	// IdentityContext is called instead of StateContext to import a
+	// resource which defines an Identity. The given ResourceData has only
+	// ID set, and d.Identity() can be used to populate the identity
+	// attributes of the imported resource. The identity of each returned
+	// ResourceData is sent to Terraform alongside its state. Only one of
+	// State, StateContext and IdentityContext can be set.
+	IdentityContext StateContextFunc
 }
 
 // StateFunc is the function called to import a resource into the Terraform state.
@@ -49,7 +57,9 @@ type StateFunc func(*ResourceData, interface{}) ([]*ResourceData, error)
 // multiple.
 //
 // To create the ResourceData structures for other resource types (if
-// you have to), instantiate your resource and call the Data function.
+// you have to), instantiate your resource and call the Data function. The
+// resource type of each returned ResourceData must then be set with SetType,
+// otherwise it is imported as the resource type being imported.
 type StateContextFunc func(context.Context, *ResourceData, interface{}) ([]*ResourceData, error)
 
 // InternalValidate should be called to validate the structure of this
@@ -63,6 +73,9 @@ func (r *ResourceImporter) InternalValidate() error {
 	if r.State != nil && r.StateContext != nil {
 		return errors.New("Both State and StateContext cannot be set.")
 	}
+	if r.IdentityContext != nil && (r.State != nil || r.StateContext != nil) {
+		return errors.New("IdentityContext cannot be set with State or StateContext.")
+	}
 	return nil
 }
 
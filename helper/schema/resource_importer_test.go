@@ -13,4 +13,12 @@ func TestInternalValidate(t *testing.T) {
 	if err := r.InternalValidate(); err == nil {
 		t.Fatal("ResourceImporter should not allow State and StateContext to be set")
 	}
+
+	r = &ResourceImporter{
+		StateContext:    ImportStatePassthroughContext,
+		IdentityContext: ImportStatePassthroughContext,
+	}
+	if err := r.InternalValidate(); err == nil {
+		t.Fatal("ResourceImporter should not allow StateContext and IdentityContext to be set")
+	}
 }
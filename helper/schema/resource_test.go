@@ -6,8 +6,12 @@ package schema
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -73,6 +77,203 @@ func TestResourceApply_create(t *testing.T) {
 	}
 }
 
+func TestResourceApply_createID(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		id           string
+		allowEmptyID bool
+		expectError  string
+	}{
+		"valid": {
+			id: "foo",
+		},
+		"empty": {
+			id:          "",
+			expectError: "Empty Resource ID",
+		},
+		"empty-allowed": {
+			id:           "",
+			allowEmptyID: true,
+		},
+		"null-byte": {
+			id:          "foo\x00bar",
+			expectError: "Invalid Resource ID",
+		},
+		"newline": {
+			id: "foo\nbar",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &Resource{
+				AllowEmptyID: testCase.allowEmptyID,
+				Schema: map[string]*Schema{
+					"foo": {
+						Type:     TypeInt,
+						Optional: true,
+					},
+				},
+				Create: func(d *ResourceData, m interface{}) error {
+					d.SetId(testCase.id)
+					return nil
+				},
+			}
+
+			d := &terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"foo": {
+						New: "42",
+					},
+				},
+			}
+
+			_, diags := r.Apply(context.Background(), nil, d, nil)
+
+			if testCase.expectError == "" {
+				if diags.HasError() {
+					t.Fatalf("unexpected error: %s", diagutils.ErrorDiags(diags))
+				}
+				return
+			}
+
+			if !diags.HasError() {
+				t.Fatalf("expected error %q, got none", testCase.expectError)
+			}
+
+			if diags[0].Summary != testCase.expectError {
+				t.Fatalf("expected error %q, got %q", testCase.expectError, diags[0].Summary)
+			}
+		})
+	}
+}
+
+func TestResourceApply_contextIgnored(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		warnOnContextIgnored bool
+		createErr            error
+		expectWarning        bool
+	}{
+		"warning-disabled": {},
+		"warning-enabled": {
+			warnOnContextIgnored: true,
+			expectWarning:        true,
+		},
+		"error-returned": {
+			warnOnContextIgnored: true,
+			createErr:            errors.New("create failed"),
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &Resource{
+				WarnOnContextIgnored: testCase.warnOnContextIgnored,
+				Schema: map[string]*Schema{
+					"foo": {
+						Type:     TypeInt,
+						Optional: true,
+					},
+				},
+				CreateContext: func(ctx context.Context, d *ResourceData, m interface{}) diag.Diagnostics {
+					// ignores ctx
+					d.SetId("foo")
+					return diag.FromErr(testCase.createErr)
+				},
+			}
+
+			d := &terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"foo": {
+						New: "42",
+					},
+				},
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			_, diags := r.Apply(ctx, nil, d, nil)
+
+			if testCase.createErr != nil && !diags.HasError() {
+				t.Fatal("expected error, got none")
+			}
+
+			if testCase.createErr == nil && diags.HasError() {
+				t.Fatalf("unexpected error: %s", diagutils.ErrorDiags(diags))
+			}
+
+			var warnings diag.Diagnostics
+			for _, d := range diags {
+				if d.Severity == diag.Warning {
+					warnings = append(warnings, d)
+				}
+			}
+
+			if !testCase.expectWarning {
+				if len(warnings) > 0 {
+					t.Fatalf("unexpected warnings: %#v", warnings)
+				}
+				return
+			}
+
+			if len(warnings) != 1 || warnings[0].Summary != "Context Ignored" {
+				t.Fatalf("expected Context Ignored warning, got: %#v", diags)
+			}
+
+			if !strings.Contains(warnings[0].Detail, "create") {
+				t.Fatalf("expected warning detail to mention the create operation, got: %s", warnings[0].Detail)
+			}
+		})
+	}
+}
+
+func TestResourceApply_updateInvalidID(t *testing.T) {
+	r := &Resource{
+		Schema: map[string]*Schema{
+			"foo": {
+				Type:     TypeInt,
+				Optional: true,
+			},
+		},
+		Update: func(d *ResourceData, m interface{}) error {
+			d.SetId("foo\x00bar")
+			return nil
+		},
+	}
+
+	s := &terraform.InstanceState{
+		ID: "foo",
+		Attributes: map[string]string{
+			"foo": "12",
+		},
+	}
+
+	d := &terraform.InstanceDiff{
+		Attributes: map[string]*terraform.ResourceAttrDiff{
+			"foo": {
+				New: "13",
+			},
+		},
+	}
+
+	_, diags := r.Apply(context.Background(), s, d, nil)
+	if !diags.HasError() {
+		t.Fatal("expected error, got none")
+	}
+
+	if diags[0].Summary != "Invalid Resource ID" {
+		t.Fatalf("unexpected error: %s", diagutils.ErrorDiags(diags))
+	}
+}
+
 func TestResourceApply_Timeout_state(t *testing.T) {
 	r := &Resource{
 		SchemaVersion: 2,
@@ -331,6 +532,52 @@ func TestResourceApply_destroy(t *testing.T) {
 	}
 }
 
+func TestResourceApply_destroyWithoutTimeout(t *testing.T) {
+	r := &Resource{
+		Schema: map[string]*Schema{
+			"foo": {
+				Type:     TypeInt,
+				Optional: true,
+			},
+		},
+		Timeouts: &ResourceTimeout{
+			Delete: DefaultTimeout(time.Millisecond),
+		},
+	}
+
+	called := false
+	r.DeleteWithoutTimeout = func(ctx context.Context, d *ResourceData, m interface{}) diag.Diagnostics {
+		called = true
+
+		if _, ok := ctx.Deadline(); ok {
+			return diag.Errorf("unexpected context deadline")
+		}
+
+		return nil
+	}
+
+	s := &terraform.InstanceState{
+		ID: "bar",
+	}
+
+	d := &terraform.InstanceDiff{
+		Destroy: true,
+	}
+
+	actual, diags := r.Apply(context.Background(), s, d, nil)
+	if diags.HasError() {
+		t.Fatalf("err: %s", diagutils.ErrorDiags(diags))
+	}
+
+	if !called {
+		t.Fatal("delete not called")
+	}
+
+	if actual != nil {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
 func TestResourceApply_destroyCreate(t *testing.T) {
 	r := &Resource{
 		Schema: map[string]*Schema{
@@ -937,6 +1184,22 @@ func TestResourceInternalValidate(t *testing.T) {
 			true,
 			true,
 		},
+		"CRUD WithoutTimeout functions": {
+			&Resource{
+				CreateWithoutTimeout: NoopContext,
+				ReadWithoutTimeout:   NoopContext,
+				UpdateWithoutTimeout: NoopContext,
+				DeleteWithoutTimeout: NoopContext,
+				Schema: map[string]*Schema{
+					"foo": {
+						Type:     TypeString,
+						Optional: true,
+					},
+				},
+			},
+			true,
+			false,
+		},
 		"Create and CreateWithoutTimeout should not both be set": {
 			&Resource{
 				Create:               Noop,
@@ -1118,87 +1381,508 @@ func TestResourceInternalValidate(t *testing.T) {
 			Writable: true,
 			Err:      true,
 		},
-	}
-
-	for name, tc := range cases {
-		t.Run(name, func(t *testing.T) {
-			sm := schemaMap{}
-			if tc.In != nil {
-				sm = schemaMap(tc.In.Schema)
-			}
-			err := tc.In.InternalValidate(sm, tc.Writable)
-			if err != nil && !tc.Err {
-				t.Fatalf("%s: expected validation to pass: %s", name, err)
-			}
-			if err == nil && tc.Err {
-				t.Fatalf("%s: expected validation to fail", name)
-			}
-		})
-	}
-}
-
-func TestResourceRefresh(t *testing.T) {
-	r := &Resource{
-		SchemaVersion: 2,
-		Schema: map[string]*Schema{
-			"foo": {
-				Type:     TypeInt,
-				Optional: true,
+		"RateLimit with zero RequestsPerSecond": {
+			In: &Resource{
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Required: true,
+					},
+				},
+				RateLimit: &RateLimitConfig{},
+				Read:      Noop,
 			},
+			Writable: false,
+			Err:      true,
 		},
-	}
-
-	r.Read = func(d *ResourceData, m interface{}) error {
-		if m != 42 {
-			return fmt.Errorf("meta not passed")
-		}
-
-		return d.Set("foo", d.Get("foo").(int)+1)
-	}
-
-	s := &terraform.InstanceState{
-		ID: "bar",
-		Attributes: map[string]string{
-			"foo": "12",
-		},
-	}
-
-	expected := &terraform.InstanceState{
-		ID: "bar",
-		Attributes: map[string]string{
-			"id":  "bar",
-			"foo": "13",
-		},
-		Meta: map[string]interface{}{
-			"schema_version": "2",
-		},
-	}
-
-	actual, diags := r.RefreshWithoutUpgrade(context.Background(), s, 42)
-	if diags.HasError() {
-		t.Fatalf("err: %s", diagutils.ErrorDiags(diags))
-	}
-
-	if !reflect.DeepEqual(actual, expected) {
-		t.Fatalf("bad: %#v", actual)
-	}
-}
-
-func TestResourceRefresh_DiffSuppressOnRefresh(t *testing.T) {
-	r := &Resource{
-		SchemaVersion: 2,
-		Schema: map[string]*Schema{
-			"foo": {
-				Type:     TypeString,
-				Optional: true,
-				DiffSuppressFunc: func(key, oldV, newV string, d *ResourceData) bool {
-					return true
+		"RateLimit with positive RequestsPerSecond": {
+			In: &Resource{
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Required: true,
+					},
 				},
-				DiffSuppressOnRefresh: true,
+				RateLimit: &RateLimitConfig{
+					RequestsPerSecond: 1,
+				},
+				Read: Noop,
 			},
+			Writable: false,
+			Err:      false,
 		},
-	}
-
+		"List with Default": {
+			In: &Resource{
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeList,
+						Optional: true,
+						Elem:     &Schema{Type: TypeString},
+						Default:  []interface{}{"a"},
+					},
+				},
+				Read: Noop,
+			},
+			Writable: false,
+			Err:      true,
+		},
+		"Map with Default": {
+			In: &Resource{
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeMap,
+						Optional: true,
+						Elem:     &Schema{Type: TypeString},
+						Default: map[string]interface{}{
+							"key": "value",
+						},
+					},
+				},
+				Read: Noop,
+			},
+			Writable: false,
+			Err:      true,
+		},
+		"Nested List with Default": {
+			In: &Resource{
+				Schema: map[string]*Schema{
+					"block": {
+						Type:     TypeList,
+						Optional: true,
+						Elem: &Resource{
+							Schema: map[string]*Schema{
+								"test": {
+									Type:     TypeList,
+									Optional: true,
+									Elem:     &Schema{Type: TypeString},
+									Default:  []interface{}{"a"},
+								},
+							},
+						},
+					},
+				},
+				Read: Noop,
+			},
+			Writable: false,
+			Err:      true,
+		},
+		"MoveState": {
+			In: &Resource{
+				Create: Noop,
+				Read:   Noop,
+				Delete: Noop,
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Required: true,
+						ForceNew: true,
+					},
+				},
+				MoveState: []StateMover{
+					{
+						SourceTypeName: "old_type",
+						StateMover:     func(ctx context.Context, req MoveStateRequest, resp *MoveStateResponse) {},
+					},
+				},
+			},
+			Writable: true,
+			Err:      false,
+		},
+		"MoveState without SourceTypeName": {
+			In: &Resource{
+				Create: Noop,
+				Read:   Noop,
+				Delete: Noop,
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Required: true,
+						ForceNew: true,
+					},
+				},
+				MoveState: []StateMover{
+					{
+						SourceTypeName: "old_type",
+						StateMover:     func(ctx context.Context, req MoveStateRequest, resp *MoveStateResponse) {},
+					},
+					{
+						StateMover: func(ctx context.Context, req MoveStateRequest, resp *MoveStateResponse) {},
+					},
+				},
+			},
+			Writable: true,
+			Err:      false,
+		},
+		"MoveState without SourceTypeName before other movers": {
+			In: &Resource{
+				Create: Noop,
+				Read:   Noop,
+				Delete: Noop,
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Required: true,
+						ForceNew: true,
+					},
+				},
+				MoveState: []StateMover{
+					{
+						StateMover: func(ctx context.Context, req MoveStateRequest, resp *MoveStateResponse) {},
+					},
+					{
+						SourceTypeName: "old_type",
+						StateMover:     func(ctx context.Context, req MoveStateRequest, resp *MoveStateResponse) {},
+					},
+				},
+			},
+			Writable: true,
+			Err:      true,
+		},
+		"MoveState without StateMover": {
+			In: &Resource{
+				Create: Noop,
+				Read:   Noop,
+				Delete: Noop,
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Required: true,
+						ForceNew: true,
+					},
+				},
+				MoveState: []StateMover{
+					{
+						SourceTypeName: "old_type",
+					},
+				},
+			},
+			Writable: true,
+			Err:      true,
+		},
+		"Importer.IdentityContext with Identity": {
+			In: &Resource{
+				Create: Noop,
+				Read:   Noop,
+				Delete: Noop,
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Required: true,
+						ForceNew: true,
+					},
+				},
+				Identity: &ResourceIdentity{
+					SchemaFunc: func() map[string]*Schema {
+						return map[string]*Schema{
+							"name": {
+								Type:              TypeString,
+								RequiredForImport: true,
+							},
+						}
+					},
+				},
+				Importer: &ResourceImporter{
+					IdentityContext: ImportStatePassthroughContext,
+				},
+			},
+			Writable: true,
+			Err:      false,
+		},
+		"Importer.IdentityContext without Identity": {
+			In: &Resource{
+				Create: Noop,
+				Read:   Noop,
+				Delete: Noop,
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Required: true,
+						ForceNew: true,
+					},
+				},
+				Importer: &ResourceImporter{
+					IdentityContext: ImportStatePassthroughContext,
+				},
+			},
+			Writable: true,
+			Err:      true,
+		},
+		"Importer.IdentityContext with Importer.StateContext": {
+			In: &Resource{
+				Create: Noop,
+				Read:   Noop,
+				Delete: Noop,
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Required: true,
+						ForceNew: true,
+					},
+				},
+				Identity: &ResourceIdentity{
+					SchemaFunc: func() map[string]*Schema {
+						return map[string]*Schema{
+							"name": {
+								Type:              TypeString,
+								RequiredForImport: true,
+							},
+						}
+					},
+				},
+				Importer: &ResourceImporter{
+					StateContext:    ImportStatePassthroughContext,
+					IdentityContext: ImportStatePassthroughContext,
+				},
+			},
+			Writable: true,
+			Err:      true,
+		},
+		"UpgradeIdentityState with Identity": {
+			In: &Resource{
+				Create: Noop,
+				Read:   Noop,
+				Delete: Noop,
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Required: true,
+						ForceNew: true,
+					},
+				},
+				SchemaVersion: 1,
+				Identity: &ResourceIdentity{
+					SchemaFunc: func() map[string]*Schema {
+						return map[string]*Schema{
+							"name": {
+								Type:              TypeString,
+								RequiredForImport: true,
+							},
+						}
+					},
+				},
+				UpgradeIdentityState: func(ctx context.Context, stateVersion int, rawIdentity map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+					return rawIdentity, nil
+				},
+			},
+			Writable: true,
+			Err:      false,
+		},
+		"UpgradeIdentityState without Identity": {
+			In: &Resource{
+				Create: Noop,
+				Read:   Noop,
+				Delete: Noop,
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Required: true,
+						ForceNew: true,
+					},
+				},
+				SchemaVersion: 1,
+				UpgradeIdentityState: func(ctx context.Context, stateVersion int, rawIdentity map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+					return rawIdentity, nil
+				},
+			},
+			Writable: true,
+			Err:      true,
+		},
+
+		"Identity is valid": {
+			In: &Resource{
+				Create: Noop,
+				Read:   Noop,
+				Update: Noop,
+				Delete: Noop,
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Required: true,
+					},
+				},
+				Identity: &ResourceIdentity{
+					SchemaFunc: func() map[string]*Schema {
+						return map[string]*Schema{
+							"name": {
+								Type:              TypeString,
+								RequiredForImport: true,
+							},
+						}
+					},
+				},
+			},
+			Writable: true,
+			Err:      false,
+		},
+
+		"Identity TypeSet is not valid": {
+			In: &Resource{
+				Create: Noop,
+				Read:   Noop,
+				Update: Noop,
+				Delete: Noop,
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Required: true,
+					},
+				},
+				Identity: &ResourceIdentity{
+					SchemaFunc: func() map[string]*Schema {
+						return map[string]*Schema{
+							"name": {
+								Type:              TypeSet,
+								RequiredForImport: true,
+								Elem:              &Schema{Type: TypeString},
+							},
+						}
+					},
+				},
+			},
+			Writable: true,
+			Err:      true,
+		},
+
+		"Identity TypeMap of nested objects is not valid": {
+			In: &Resource{
+				Create: Noop,
+				Read:   Noop,
+				Update: Noop,
+				Delete: Noop,
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Required: true,
+					},
+				},
+				Identity: &ResourceIdentity{
+					SchemaFunc: func() map[string]*Schema {
+						return map[string]*Schema{
+							"name": {
+								Type:              TypeMap,
+								RequiredForImport: true,
+								Elem: &Resource{
+									Schema: map[string]*Schema{
+										"id": {
+											Type: TypeString,
+										},
+									},
+								},
+							},
+						}
+					},
+				},
+			},
+			Writable: true,
+			Err:      true,
+		},
+
+		"Identity OptionalForImport and RequiredForImport both false": {
+			In: &Resource{
+				Create: Noop,
+				Read:   Noop,
+				Update: Noop,
+				Delete: Noop,
+				Schema: map[string]*Schema{
+					"test": {
+						Type:     TypeString,
+						Required: true,
+					},
+				},
+				Identity: &ResourceIdentity{
+					SchemaFunc: func() map[string]*Schema {
+						return map[string]*Schema{
+							"name": {
+								Type: TypeString,
+							},
+						}
+					},
+				},
+			},
+			Writable: true,
+			Err:      true,
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			sm := schemaMap{}
+			if tc.In != nil {
+				sm = schemaMap(tc.In.Schema)
+			}
+			err := tc.In.InternalValidate(sm, tc.Writable)
+			if err != nil && !tc.Err {
+				t.Fatalf("%s: expected validation to pass: %s", name, err)
+			}
+			if err == nil && tc.Err {
+				t.Fatalf("%s: expected validation to fail", name)
+			}
+		})
+	}
+}
+
+func TestResourceRefresh(t *testing.T) {
+	r := &Resource{
+		SchemaVersion: 2,
+		Schema: map[string]*Schema{
+			"foo": {
+				Type:     TypeInt,
+				Optional: true,
+			},
+		},
+	}
+
+	r.Read = func(d *ResourceData, m interface{}) error {
+		if m != 42 {
+			return fmt.Errorf("meta not passed")
+		}
+
+		return d.Set("foo", d.Get("foo").(int)+1)
+	}
+
+	s := &terraform.InstanceState{
+		ID: "bar",
+		Attributes: map[string]string{
+			"foo": "12",
+		},
+	}
+
+	expected := &terraform.InstanceState{
+		ID: "bar",
+		Attributes: map[string]string{
+			"id":  "bar",
+			"foo": "13",
+		},
+		Meta: map[string]interface{}{
+			"schema_version": "2",
+		},
+	}
+
+	actual, diags := r.RefreshWithoutUpgrade(context.Background(), s, 42)
+	if diags.HasError() {
+		t.Fatalf("err: %s", diagutils.ErrorDiags(diags))
+	}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestResourceRefresh_DiffSuppressOnRefresh(t *testing.T) {
+	r := &Resource{
+		SchemaVersion: 2,
+		Schema: map[string]*Schema{
+			"foo": {
+				Type:     TypeString,
+				Optional: true,
+				DiffSuppressFunc: func(key, oldV, newV string, d *ResourceData) bool {
+					return true
+				},
+				DiffSuppressOnRefresh: true,
+			},
+		},
+	}
+
 	r.Read = func(d *ResourceData, m interface{}) error {
 		return d.Set("foo", "howdy")
 	}
@@ -1260,6 +1944,75 @@ func TestResourceRefresh_blankId(t *testing.T) {
 	}
 }
 
+func TestResourceRefresh_invalidId(t *testing.T) {
+	r := &Resource{
+		Schema: map[string]*Schema{
+			"foo": {
+				Type:     TypeInt,
+				Optional: true,
+			},
+		},
+	}
+
+	r.Read = func(d *ResourceData, m interface{}) error {
+		return nil
+	}
+
+	// Existing resources are refreshed even if their ID would be rejected
+	// after create, as the provider has no way to migrate them.
+	s := &terraform.InstanceState{
+		ID:         "foo\x00bar",
+		Attributes: map[string]string{},
+	}
+
+	actual, diags := r.RefreshWithoutUpgrade(context.Background(), s, 42)
+	if diags.HasError() {
+		t.Fatalf("err: %s", diagutils.ErrorDiags(diags))
+	}
+
+	if actual == nil || actual.ID != "foo\x00bar" {
+		t.Fatalf("bad: %#v", actual)
+	}
+}
+
+func TestResourceRefresh_contextIgnored(t *testing.T) {
+	r := &Resource{
+		WarnOnContextIgnored: true,
+		Schema: map[string]*Schema{
+			"foo": {
+				Type:     TypeInt,
+				Optional: true,
+			},
+		},
+		ReadWithoutTimeout: func(ctx context.Context, d *ResourceData, m interface{}) diag.Diagnostics {
+			// ignores ctx
+			return nil
+		},
+	}
+
+	s := &terraform.InstanceState{
+		ID:         "foo",
+		Attributes: map[string]string{},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, diags := r.RefreshWithoutUpgrade(ctx, s, 42)
+	if diags.HasError() {
+		t.Fatalf("unexpected error: %s", diagutils.ErrorDiags(diags))
+	}
+
+	if len(diags) != 1 || diags[0].Severity != diag.Warning || diags[0].Summary != "Context Ignored" {
+		t.Fatalf("expected Context Ignored warning, got: %#v", diags)
+	}
+
+	_, diags = r.RefreshWithoutUpgrade(context.Background(), s, 42)
+	if len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %#v", diags)
+	}
+}
+
 func TestResourceRefresh_delete(t *testing.T) {
 	r := &Resource{
 		Schema: map[string]*Schema{
@@ -1606,6 +2359,20 @@ func TestResource_ValidateUpgradeState(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// check for a duplicated version
+	r.StateUpgraders[2].Version = 1
+	if err := r.InternalValidate(nil, true); err == nil {
+		t.Fatal("StateUpgraders cannot have duplicate versions")
+	}
+	r.StateUpgraders[2].Version = 2
+
+	// check for a negative version
+	r.StateUpgraders[0].Version = -1
+	if err := r.InternalValidate(nil, true); err == nil {
+		t.Fatal("StateUpgraders cannot have negative versions")
+	}
+	r.StateUpgraders[0].Version = 0
+
 	// can't add an upgrader for a schema >= the current version
 	r.StateUpgraders = append(r.StateUpgraders, StateUpgrader{
 		Version: 3,
@@ -1705,11 +2472,43 @@ func TestResourceInternalIdentityValidate(t *testing.T) {
 			true,
 		},
 
-		"TypeMap is not valid": {
+		"TypeMap is valid": {
+			&ResourceIdentity{
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"foo": {Type: TypeMap, Elem: &Schema{Type: TypeString}, OptionalForImport: true},
+					}
+				},
+			},
+			false,
+		},
+
+		"TypeMap contains nested object": {
+			&ResourceIdentity{
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"foo": {
+							Type: TypeMap,
+							Elem: &Resource{
+								Schema: map[string]*Schema{
+									"bar": {
+										Type: TypeString,
+									},
+								},
+							},
+							OptionalForImport: true,
+						},
+					}
+				},
+			},
+			true,
+		},
+
+		"TypeMap contains TypeSet": {
 			&ResourceIdentity{
 				SchemaFunc: func() map[string]*Schema {
 					return map[string]*Schema{
-						"foo": {Type: TypeMap, OptionalForImport: true},
+						"foo": {Type: TypeMap, Elem: TypeSet, OptionalForImport: true},
 					}
 				},
 			},
@@ -1783,6 +2582,49 @@ func TestResourceInternalIdentityValidate(t *testing.T) {
 			true,
 		},
 
+		"TypeList contains nested object": {
+			&ResourceIdentity{
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"foo": {
+							Type: TypeList,
+							Elem: &Resource{
+								Schema: map[string]*Schema{
+									"bar": {
+										Type: TypeString,
+									},
+								},
+							},
+							RequiredForImport: true,
+						},
+					}
+				},
+			},
+			false,
+		},
+
+		"TypeList contains nested object with TypeList": {
+			&ResourceIdentity{
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"foo": {
+							Type: TypeList,
+							Elem: &Resource{
+								Schema: map[string]*Schema{
+									"bar": {
+										Type: TypeList,
+										Elem: &Schema{Type: TypeString},
+									},
+								},
+							},
+							RequiredForImport: true,
+						},
+					}
+				},
+			},
+			true,
+		},
+
 		"TypeList contains TypeInvalid": {
 			&ResourceIdentity{
 				SchemaFunc: func() map[string]*Schema {
@@ -2074,6 +2916,277 @@ func TestResourceInternalIdentityValidate(t *testing.T) {
 			},
 			false,
 		},
+
+		"IdentityUpgraders contiguous": {
+			&ResourceIdentity{
+				Version: 2,
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"foo": {
+							Type:              TypeString,
+							RequiredForImport: true,
+						},
+					}
+				},
+				IdentityUpgraders: []IdentityUpgrader{
+					{
+						Version: 0,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+					{
+						Version: 1,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+				},
+			},
+			false,
+		},
+
+		"IdentityUpgraders missing intermediate version": {
+			&ResourceIdentity{
+				Version: 3,
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"foo": {
+							Type:              TypeString,
+							RequiredForImport: true,
+						},
+					}
+				},
+				IdentityUpgraders: []IdentityUpgrader{
+					{
+						Version: 0,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+					{
+						Version: 2,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+				},
+			},
+			true,
+		},
+
+		"IdentityUpgraders missing last version": {
+			&ResourceIdentity{
+				Version: 2,
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"foo": {
+							Type:              TypeString,
+							RequiredForImport: true,
+						},
+					}
+				},
+				IdentityUpgraders: []IdentityUpgrader{
+					{
+						Version: 0,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+				},
+			},
+			true,
+		},
+
+		"IdentityUpgraders passthrough intermediate versions": {
+			&ResourceIdentity{
+				Version: 4,
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"foo": {
+							Type:              TypeString,
+							RequiredForImport: true,
+						},
+					}
+				},
+				IdentityUpgraders: []IdentityUpgrader{
+					{
+						Version: 0,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+					{
+						Version:     1,
+						Passthrough: true,
+					},
+					{
+						Version: 3,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+				},
+			},
+			false,
+		},
+
+		"IdentityUpgraders passthrough to current version": {
+			&ResourceIdentity{
+				Version: 3,
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"foo": {
+							Type:              TypeString,
+							RequiredForImport: true,
+						},
+					}
+				},
+				IdentityUpgraders: []IdentityUpgrader{
+					{
+						Version: 0,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+					{
+						Version:     1,
+						Passthrough: true,
+					},
+				},
+			},
+			false,
+		},
+
+		"IdentityUpgraders passthrough with Upgrade": {
+			&ResourceIdentity{
+				Version: 1,
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"foo": {
+							Type:              TypeString,
+							RequiredForImport: true,
+						},
+					}
+				},
+				IdentityUpgraders: []IdentityUpgrader{
+					{
+						Version:     0,
+						Upgrade:     testIdentityUpgradeFunc,
+						Passthrough: true,
+					},
+				},
+			},
+			true,
+		},
+
+		"IdentityUpgraders missing Upgrade": {
+			&ResourceIdentity{
+				Version: 1,
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"foo": {
+							Type:              TypeString,
+							RequiredForImport: true,
+						},
+					}
+				},
+				IdentityUpgraders: []IdentityUpgrader{
+					{
+						Version: 0,
+					},
+				},
+			},
+			true,
+		},
+
+		"IdentityUpgraders out of order": {
+			&ResourceIdentity{
+				Version: 2,
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"foo": {
+							Type:              TypeString,
+							RequiredForImport: true,
+						},
+					}
+				},
+				IdentityUpgraders: []IdentityUpgrader{
+					{
+						Version: 1,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+					{
+						Version: 0,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+				},
+			},
+			true,
+		},
+
+		"IdentityUpgraders negative version": {
+			&ResourceIdentity{
+				Version: 1,
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"foo": {
+							Type:              TypeString,
+							RequiredForImport: true,
+						},
+					}
+				},
+				IdentityUpgraders: []IdentityUpgrader{
+					{
+						Version: -1,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+					{
+						Version: 0,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+				},
+			},
+			true,
+		},
+
+		"IdentityUpgraders duplicate version": {
+			&ResourceIdentity{
+				Version: 2,
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"foo": {
+							Type:              TypeString,
+							RequiredForImport: true,
+						},
+					}
+				},
+				IdentityUpgraders: []IdentityUpgrader{
+					{
+						Version: 0,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+					{
+						Version: 1,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+					{
+						Version: 1,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+				},
+			},
+			true,
+		},
+
+		"IdentityUpgraders version not less than current version": {
+			&ResourceIdentity{
+				Version: 1,
+				SchemaFunc: func() map[string]*Schema {
+					return map[string]*Schema{
+						"foo": {
+							Type:              TypeString,
+							RequiredForImport: true,
+						},
+					}
+				},
+				IdentityUpgraders: []IdentityUpgrader{
+					{
+						Version: 0,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+					{
+						Version: 1,
+						Upgrade: testIdentityUpgradeFunc,
+					},
+				},
+			},
+			true,
+		},
 	}
 
 	for name, tc := range cases {
@@ -2088,3 +3201,57 @@ func TestResourceInternalIdentityValidate(t *testing.T) {
 		})
 	}
 }
+
+func testIdentityUpgradeFunc(ctx context.Context, rawState map[string]interface{}, meta interface{}) (map[string]interface{}, error) {
+	return rawState, nil
+}
+
+func TestResourceSchemaMap_schemaFuncCached(t *testing.T) {
+	t.Parallel()
+
+	var calls int32
+
+	newResource := func() *Resource {
+		return &Resource{
+			SchemaFunc: func() map[string]*Schema {
+				atomic.AddInt32(&calls, 1)
+
+				return map[string]*Schema{
+					"foo": {
+						Type:     TypeString,
+						Optional: true,
+					},
+				}
+			},
+		}
+	}
+
+	r := newResource()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			r.SchemaMap()
+			r.CoreConfigSchema()
+		}()
+	}
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Fatalf("expected SchemaFunc to be called once, got %d", got)
+	}
+
+	if _, ok := r.SchemaMap()["foo"]; !ok {
+		t.Fatal("expected cached schema to contain foo")
+	}
+
+	// A recreated resource must call SchemaFunc again.
+	newResource().SchemaMap()
+
+	if got := atomic.LoadInt32(&calls); got != 2 {
+		t.Fatalf("expected SchemaFunc to be called twice, got %d", got)
+	}
+}
@@ -55,6 +55,33 @@ type ResourceTimeout struct {
 	Create, Read, Update, Delete, Default *time.Duration
 }
 
+// DefaultTimeouts returns the default timeout configured in Timeouts for each
+// operation of the resource, keyed by TimeoutCreate, TimeoutRead,
+// TimeoutUpdate, TimeoutDelete, and TimeoutDefault. Operations without a
+// configured timeout are omitted. This is intended for documentation and
+// other tooling; the timeouts block in the resource schema is unaffected.
+func (r *Resource) DefaultTimeouts() map[string]time.Duration {
+	if r.Timeouts == nil {
+		return nil
+	}
+
+	m := make(map[string]time.Duration)
+
+	for k, v := range map[string]*time.Duration{
+		TimeoutCreate:  r.Timeouts.Create,
+		TimeoutRead:    r.Timeouts.Read,
+		TimeoutUpdate:  r.Timeouts.Update,
+		TimeoutDelete:  r.Timeouts.Delete,
+		TimeoutDefault: r.Timeouts.Default,
+	} {
+		if v != nil {
+			m[k] = *v
+		}
+	}
+
+	return m
+}
+
 // ConfigDecode takes a schema and the configuration (available in Diff) and
 // validates, parses the timeouts into `t`
 func (t *ResourceTimeout) ConfigDecode(s *Resource, c *terraform.ResourceConfig) error {
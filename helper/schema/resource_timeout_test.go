@@ -125,6 +125,57 @@ func TestResourceTimeout_ConfigDecode(t *testing.T) {
 	}
 }
 
+func TestResourceDefaultTimeouts(t *testing.T) {
+	cases := map[string]struct {
+		Timeouts *ResourceTimeout
+		Expected map[string]time.Duration
+	}{
+		"no timeouts": {
+			Timeouts: nil,
+			Expected: nil,
+		},
+		"some operations": {
+			Timeouts: &ResourceTimeout{
+				Create: DefaultTimeout(30 * time.Minute),
+				Delete: DefaultTimeout(10 * time.Minute),
+			},
+			Expected: map[string]time.Duration{
+				TimeoutCreate: 30 * time.Minute,
+				TimeoutDelete: 10 * time.Minute,
+			},
+		},
+		"all operations": {
+			Timeouts: &ResourceTimeout{
+				Create:  DefaultTimeout(30 * time.Minute),
+				Read:    DefaultTimeout(1 * time.Minute),
+				Update:  DefaultTimeout(20 * time.Minute),
+				Delete:  DefaultTimeout(10 * time.Minute),
+				Default: DefaultTimeout(5 * time.Minute),
+			},
+			Expected: map[string]time.Duration{
+				TimeoutCreate:  30 * time.Minute,
+				TimeoutRead:    1 * time.Minute,
+				TimeoutUpdate:  20 * time.Minute,
+				TimeoutDelete:  10 * time.Minute,
+				TimeoutDefault: 5 * time.Minute,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &Resource{
+				Timeouts: tc.Timeouts,
+			}
+
+			actual := r.DefaultTimeouts()
+			if !reflect.DeepEqual(actual, tc.Expected) {
+				t.Fatalf("bad default timeouts.\nExpected:\n%#v\nGot:\n%#v\n", tc.Expected, actual)
+			}
+		})
+	}
+}
+
 func TestResourceTimeout_legacyConfigDecode(t *testing.T) {
 	r := &Resource{
 		Timeouts: &ResourceTimeout{
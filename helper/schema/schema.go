@@ -26,6 +26,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/go-cty/cty"
+	ctyconvert "github.com/hashicorp/go-cty/cty/convert"
 	"github.com/hashicorp/terraform-plugin-log/tfsdklog"
 	"github.com/mitchellh/copystructure"
 	"github.com/mitchellh/mapstructure"
@@ -33,6 +34,7 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/configs/hcl2shim"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/logging"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/tfdiags"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
@@ -125,6 +127,18 @@ type Schema struct {
 	// CustomizeDiff field to call the ResourceDiff type ForceNew method.
 	ForceNew bool
 
+	// ForceNewFunc is an optional function which determines whether a change
+	// in this value requires the replacement of the managed resource
+	// instance, for example when shrinking a disk size but not when growing
+	// it. It receives the prior state value and the configuration value and
+	// takes precedence over ForceNew when set. Nested attributes of a block
+	// with ForceNewFunc which set ForceNew still require replacement when
+	// they change, regardless of the func.
+	//
+	// ForceNewFunc is not called when creating a resource or when either
+	// value is unknown, in which case ForceNew applies.
+	ForceNewFunc SchemaForceNewFunc
+
 	// If this is non-nil, the provided function will be used during diff
 	// of this field. If this is nil, a default diff for the type of the
 	// schema will be used.
@@ -179,6 +193,11 @@ type Schema struct {
 	// implementation of an Elem field of another Schema, such as trying to
 	// set a default value for a TypeList or TypeSet.
 	//
+	// Top-level attributes of a provider schema can also set a Default for
+	// TypeList or TypeSet using a []interface{}, or for TypeMap using a
+	// map[string]interface{}, when the Elem is a primitive *Schema. The
+	// default value must conform to the Elem type.
+	//
 	// Changing either Default can be a breaking change, especially if the
 	// attribute has ForceNew enabled. If a default needs to change to align
 	// with changing assumptions in an upstream API, then it may be necessary
@@ -202,11 +221,25 @@ type Schema struct {
 	// default.
 	DefaultFunc SchemaDefaultFunc
 
+	// DefaultContextFunc is a context-aware version of DefaultFunc. It is
+	// called with the request context to compute a default when this
+	// attribute is not set in the configuration, such as when configuring
+	// the provider or planning a resource. DefaultContextFunc can only be
+	// used with primitive types, and cannot be used with Default,
+	// DefaultFunc or Required.
+	DefaultContextFunc SchemaDefaultContextFunc
+
 	// Description is used as the description for docs, the language server and
 	// other user facing usage. It can be plain-text or markdown depending on the
 	// global DescriptionKind setting.
 	Description string
 
+	// MarkdownDescription is a markdown formatted description which is used
+	// in place of Description if the SchemaDescriptionBuilder returns an
+	// empty description, regardless of the global DescriptionKind setting.
+	// This allows markdown descriptions to be adopted one attribute at a time.
+	MarkdownDescription string
+
 	// InputDefault is the default value to use for when inputs are requested.
 	// This differs from Default in that if Default is set, no input is
 	// asked for. If Input is asked, this will be the default value offered.
@@ -218,9 +251,25 @@ type Schema struct {
 	// to simply store the hash of it.
 	StateFunc SchemaStateFunc
 
+	// PlanModifiers are functions called in order to modify the planned value
+	// of this attribute after the SDK has planned the resource change, for
+	// example to normalize a configured value. Each function receives the
+	// planned value returned by the previous one. If a function returns an
+	// error diagnostic, the remaining functions are not called and the plan
+	// fails.
+	//
+	// This is only valid for top level Computed attributes of managed
+	// resources. Terraform only accepts a planned value which differs from a
+	// known configuration value for Computed attributes, so a Required
+	// attribute cannot be normalized. The modified planned value is the
+	// value passed to the apply functions.
+	PlanModifiers []SchemaPlanModifierFunc
+
 	// Elem represents the element type for a TypeList, TypeSet, or TypeMap
 	// attribute or block. The only valid types are *Schema and *Resource.
-	// Only TypeList and TypeSet support *Resource.
+	// Only TypeList and TypeSet support *Resource, except for TypeMap with
+	// ConfigMode set to SchemaConfigModeAttr, which represents a map of
+	// objects.
 	//
 	// If the Elem is a *Schema, the surrounding Schema represents a single
 	// attribute with a single element type for underlying elements. In
@@ -243,6 +292,12 @@ type Schema struct {
 	//   https://www.terraform.io/docs/language/expressions/dynamic-blocks.html
 	//
 	// The underlying *Resource must only implement the Schema field.
+	//
+	// If the Elem is a *Resource and the surrounding Schema is a TypeMap with
+	// ConfigMode set to SchemaConfigModeAttr, the surrounding Schema
+	// represents a map of objects attribute. Each object is addressed by its
+	// map key, such as "mymap.key.attr", so validation rejects map keys that
+	// contain periods.
 	Elem interface{}
 
 	// MaxItems defines a maximum amount of items that can exist within a
@@ -269,18 +324,35 @@ type Schema struct {
 	// declaration should be removed.
 	ComputedWhen []string
 
+	// UnknownIfChanged is a set of attribute paths which, when any of them
+	// has a planned change, causes this Computed attribute to be planned as
+	// unknown instead of keeping its prior state value. This replaces the
+	// common CustomizeDiff pattern of calling ResourceDiff.SetNewComputed
+	// when ResourceDiff.HasChange is true. Any CustomizeDiff function is
+	// called afterwards and can override the planned value.
+	//
+	// This is only valid for top level Computed attributes of managed
+	// resources. The attribute paths use the same syntax as
+	// ResourceDiff.HasChange.
+	UnknownIfChanged []string
+
 	// ConflictsWith is a set of attribute paths, including this attribute,
 	// whose configurations cannot be set simultaneously. This implements the
 	// validation logic declaratively within the schema and can trigger earlier
 	// in Terraform operations, rather than using create or update logic which
 	// only triggers during apply.
 	//
-	// Only absolute attribute paths, ones starting with top level attribute
+	// Absolute attribute paths, ones starting with top level attribute
 	// names, are supported. Attribute paths cannot be accurately declared
 	// for TypeList (if MaxItems is greater than 1), TypeMap, or TypeSet
 	// attributes. To reference an attribute under a single configuration block
 	// (TypeList with Elem of *Resource and MaxItems of 1), the syntax is
 	// "parent_block_name.0.child_attribute_name".
+	//
+	// For an attribute nested in a TypeList or TypeSet configuration block,
+	// a path which is not an absolute attribute path is relative to the
+	// containing block instead, such as "sibling_attribute_name". This is
+	// validated separately for each block.
 	ConflictsWith []string
 
 	// ExactlyOneOf is a set of attribute paths, including this attribute,
@@ -290,12 +362,17 @@ type Schema struct {
 	// earlier in Terraform operations, rather than using create or update
 	// logic which only triggers during apply.
 	//
-	// Only absolute attribute paths, ones starting with top level attribute
+	// Absolute attribute paths, ones starting with top level attribute
 	// names, are supported. Attribute paths cannot be accurately declared
 	// for TypeList (if MaxItems is greater than 1), TypeMap, or TypeSet
 	// attributes. To reference an attribute under a single configuration block
 	// (TypeList with Elem of *Resource and MaxItems of 1), the syntax is
 	// "parent_block_name.0.child_attribute_name".
+	//
+	// For an attribute nested in a TypeList or TypeSet configuration block,
+	// a path which is not an absolute attribute path is relative to the
+	// containing block instead, such as "sibling_attribute_name". This is
+	// validated separately for each block.
 	ExactlyOneOf []string
 
 	// AtLeastOneOf is a set of attribute paths, including this attribute,
@@ -318,12 +395,17 @@ type Schema struct {
 	// operations, rather than using create or update logic which only triggers
 	// during apply.
 	//
-	// Only absolute attribute paths, ones starting with top level attribute
+	// Absolute attribute paths, ones starting with top level attribute
 	// names, are supported. Attribute paths cannot be accurately declared
 	// for TypeList (if MaxItems is greater than 1), TypeMap, or TypeSet
 	// attributes. To reference an attribute under a single configuration block
 	// (TypeList with Elem of *Resource and MaxItems of 1), the syntax is
 	// "parent_block_name.0.child_attribute_name".
+	//
+	// For an attribute nested in a TypeList or TypeSet configuration block,
+	// a path which is not an absolute attribute path is relative to the
+	// containing block instead, such as "sibling_attribute_name". This is
+	// validated separately for each block.
 	RequiredWith []string
 
 	// Deprecated defines warning diagnostic details to display when
@@ -355,6 +437,14 @@ type Schema struct {
 	//  - https://github.com/hashicorp/terraform/issues/7569
 	Deprecated string
 
+	// DeprecatedReplacedBy is the path of the attribute which replaces this
+	// deprecated attribute, such as "other_attribute" or
+	// "block.0.other_attribute". When set, the Deprecated warning diagnostic
+	// detail also tells practitioners to use the replacement attribute
+	// instead. A path which is not an absolute attribute path is relative to
+	// the containing block. It can only be set along with Deprecated.
+	DeprecatedReplacedBy string
+
 	// ValidateFunc allows individual fields to define arbitrary validation
 	// logic. It is yielded the provided config value as an interface{} that is
 	// guaranteed to be of the proper Schema type, and it can yield warnings or
@@ -387,9 +477,39 @@ type Schema struct {
 	//  AttributePath: append(path, cty.IndexStep{Key: cty.StringVal("key_name")})
 	ValidateDiagFunc SchemaValidateDiagFunc
 
+	// ValidateKeysFunc allows TypeMap attributes to validate each key of the
+	// configured map, such as to reject tag keys which a remote system does
+	// not allow. It is called for each key once the map is known, in key
+	// order. Returned Diagnostics without an AttributePath are reported for
+	// the map element of the key.
+	//
+	// ValidateKeysFunc is only valid when the schema's Type is set to TypeMap.
+	ValidateKeysFunc SchemaValidateKeysFunc
+
+	// WarnOnCoercion enables a warning diagnostic whenever the SDK converts a
+	// value of a different type into this schema's Type, such as a Default of
+	// 8080 for a TypeString attribute, or a DefaultFunc like EnvDefaultFunc
+	// returning a string for a TypeInt attribute. Such conversions are silent
+	// by default. Terraform converts configuration values to the attribute
+	// type before sending them to the provider, so in practice the warning is
+	// raised for Default and DefaultFunc values during validation.
+	//
+	// WarnOnCoercion is honored only when the schema's Type is set to TypeInt,
+	// TypeFloat, TypeString, or TypeBool. It is ignored for all other types.
+	WarnOnCoercion bool
+
+	// NoLog omits the attribute's value from logs emitted by the SDK, such
+	// as the trace logs of planned attribute changes, including the values of
+	// any nested attributes. Unlike Sensitive, it has no effect on how the
+	// value is shown by Terraform in plan or state output. Sensitive values
+	// are always omitted from SDK logs.
+	NoLog bool
+
 	// Sensitive ensures that the attribute's value does not get displayed in
 	// the Terraform user interface output. It should be used for password or
-	// other values which should be hidden.
+	// other values which should be hidden. If set on a block, such as a
+	// TypeList or TypeSet with an Elem of *Resource, all nested attributes
+	// are marked Sensitive.
 	//
 	// Terraform does not support conditional sensitivity, so if the value may
 	// only be sensitive in certain scenarios, a pragmatic choice will be
@@ -443,10 +563,54 @@ const (
 // Return true if the diff should be suppressed, false to retain it.
 type SchemaDiffSuppressFunc func(k, oldValue, newValue string, d *ResourceData) bool
 
+// SchemaForceNewFunc is a function which determines whether a change from
+// the old value to the new value of a schema element requires the
+// replacement of the managed resource instance.
+type SchemaForceNewFunc func(ctx context.Context, oldValue, newValue interface{}) bool
+
+// SchemaPlanModifierFunc is a function which can modify the planned value
+// of a schema element, by setting the PlanValue of the response.
+type SchemaPlanModifierFunc func(ctx context.Context, req AttributePlanModifyRequest, resp *AttributePlanModifyResponse)
+
+// AttributePlanModifyRequest is the request passed to a
+// SchemaPlanModifierFunc.
+type AttributePlanModifyRequest struct {
+	// Key is the name of the attribute being planned.
+	Key string
+
+	// PriorValue is the prior state value of the attribute. It is null when
+	// the resource is being created.
+	PriorValue cty.Value
+
+	// ConfigValue is the configuration value of the attribute.
+	ConfigValue cty.Value
+
+	// PlanValue is the value of the attribute planned by the SDK and any
+	// previous plan modifiers.
+	PlanValue cty.Value
+}
+
+// AttributePlanModifyResponse is the response populated by a
+// SchemaPlanModifierFunc.
+type AttributePlanModifyResponse struct {
+	// PlanValue is the planned value of the attribute. It is initialized to
+	// the request PlanValue and must conform to the attribute type.
+	PlanValue cty.Value
+
+	// Diagnostics report errors or warnings related to modifying the planned
+	// value. Diagnostics without an AttributePath are reported for the
+	// attribute.
+	Diagnostics diag.Diagnostics
+}
+
 // SchemaDefaultFunc is a function called to return a default value for
 // a field.
 type SchemaDefaultFunc func() (interface{}, error)
 
+// SchemaDefaultContextFunc is a function called with a context to return a
+// default value for a field.
+type SchemaDefaultContextFunc func(context.Context) (interface{}, error)
+
 // EnvDefaultFunc is a helper function that returns the value of the
 // given environment variable, if one exists, or the default value
 // otherwise.
@@ -493,6 +657,10 @@ type SchemaValidateFunc func(interface{}, string) ([]string, []error)
 // schema and has Diagnostic support.
 type SchemaValidateDiagFunc func(interface{}, cty.Path) diag.Diagnostics
 
+// SchemaValidateKeysFunc is a function used to validate a single key of a
+// TypeMap field in the schema.
+type SchemaValidateKeysFunc func(key string) diag.Diagnostics
+
 func (s *Schema) GoString() string {
 	return fmt.Sprintf("*%#v", *s)
 }
@@ -515,6 +683,35 @@ func (s *Schema) DefaultValue() (interface{}, error) {
 	return nil, nil
 }
 
+// DefaultValueContext returns a default value for this schema by evaluating
+// DefaultContextFunc with the given context, if defined. Otherwise it behaves
+// the same as DefaultValue.
+func (s *Schema) DefaultValueContext(ctx context.Context) (interface{}, error) {
+	if s.DefaultContextFunc != nil {
+		defaultValue, err := s.DefaultContextFunc(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error loading default: %s", err)
+		}
+		return defaultValue, nil
+	}
+
+	return s.DefaultValue()
+}
+
+// isObjectMap returns true if the schema is a TypeMap of objects, which is
+// only supported when ConfigMode is explicitly set to SchemaConfigModeAttr.
+// Otherwise, an Elem of *Resource is treated as a TypeString for
+// compatibility with existing providers.
+func (s *Schema) isObjectMap() bool {
+	if s.Type != TypeMap || s.ConfigMode != SchemaConfigModeAttr {
+		return false
+	}
+
+	_, ok := s.Elem.(*Resource)
+
+	return ok
+}
+
 // Returns a zero value for the schema.
 func (s *Schema) ZeroValue() interface{} {
 	// If it's a set then we'll do a bit of extra work to provide the
@@ -712,9 +909,25 @@ func (m schemaMapWithIdentity) Diff(
 	customizeDiff CustomizeDiffFunc,
 	meta interface{},
 	handleRequiresNew bool) (*terraform.InstanceDiff, error) {
+	return m.diffWithOptions(ctx, s, c, customizeDiff, meta, handleRequiresNew, false)
+}
+
+// diffWithOptions is Diff with the additional option of skipping the diff of
+// top level Computed-only attributes which are unchanged from the prior
+// state. See Resource.SkipUnchangedComputedDiff.
+func (m schemaMapWithIdentity) diffWithOptions(
+	ctx context.Context,
+	s *terraform.InstanceState,
+	c *terraform.ResourceConfig,
+	customizeDiff CustomizeDiffFunc,
+	meta interface{},
+	handleRequiresNew bool,
+	skipUnchangedComputed bool) (*terraform.InstanceDiff, error) {
 	result := new(terraform.InstanceDiff)
 	result.Attributes = make(map[string]*terraform.ResourceAttrDiff)
 
+	customizeDiff = m.schemaMap.unknownIfChangedCustomizeDiff(customizeDiff)
+
 	// Make sure to mark if the resource is tainted
 	if s != nil {
 		result.DestroyTainted = s.Tainted
@@ -729,10 +942,15 @@ func (m schemaMapWithIdentity) Diff(
 		identitySchema: m.identitySchema,
 		state:          s,
 		config:         c,
+		ctx:            ctx,
 		panicOnError:   m.panicOnError(),
 	}
 
 	for k, schema := range m.schemaMap {
+		if skipUnchangedComputed && isUnchangedComputedOnly(k, schema, s) {
+			continue
+		}
+
 		err := m.diff(ctx, k, schema, result, d, false)
 		if err != nil {
 			return nil, err
@@ -750,7 +968,7 @@ func (m schemaMapWithIdentity) Diff(
 	// defined.
 	if !result.DestroyTainted && customizeDiff != nil {
 		mc := m.DeepCopy()
-		rd := newResourceDiff(mc, c, s, result)
+		rd := newResourceDiff(ctx, mc, c, s, result)
 
 		logging.HelperSchemaTrace(ctx, "Calling downstream")
 		err := customizeDiff(ctx, rd, meta)
@@ -765,6 +983,7 @@ func (m schemaMapWithIdentity) Diff(
 				return nil, err
 			}
 		}
+		result.RequiresReplace = append(result.RequiresReplace, rd.requiresReplace...)
 		// copy over identity data (by getting it so we also include changes)
 		// In order to build the final identity attributes, we read the full
 		// attribute set as a map[string]interface{}, write it to a MapFieldWriter,
@@ -824,7 +1043,7 @@ func (m schemaMapWithIdentity) Diff(
 			// Re-run customization
 			if !result2.DestroyTainted && customizeDiff != nil {
 				mc := m.DeepCopy()
-				rd := newResourceDiff(mc, c, d.state, result2)
+				rd := newResourceDiff(ctx, mc, c, d.state, result2)
 				if err := customizeDiff(ctx, rd, meta); err != nil {
 					return nil, err
 				}
@@ -834,6 +1053,7 @@ func (m schemaMapWithIdentity) Diff(
 						return nil, err
 					}
 				}
+				result2.RequiresReplace = append(result2.RequiresReplace, rd.requiresReplace...)
 				// copy over identity data (by getting it so we also include changes)
 				// In order to build the final identity attributes, we read the full
 				// attribute set as a map[string]interface{}, write it to a MapFieldWriter,
@@ -906,6 +1126,8 @@ func (m schemaMapWithIdentity) Diff(
 	// finished the diff.
 	// TODO
 
+	m.schemaMap.logDiffAttributes(ctx, result)
+
 	if result.Empty() {
 		// If we don't have any diff elements, just return nil
 		return nil, nil
@@ -914,6 +1136,52 @@ func (m schemaMapWithIdentity) Diff(
 	return result, nil
 }
 
+// logDiffAttributes emits a trace log for each attribute change in the diff.
+// Values are omitted for attributes which are Sensitive, NoLog or WriteOnly,
+// or which are nested within such an attribute.
+func (m schemaMap) logDiffAttributes(ctx context.Context, diff *terraform.InstanceDiff) {
+	keys := make([]string, 0, len(diff.Attributes))
+	for k := range diff.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		attr := diff.Attributes[k]
+		if attr == nil {
+			continue
+		}
+
+		fields := map[string]interface{}{
+			logging.KeyAttributePath: k,
+		}
+
+		if m.attributeLoggable(k) {
+			fields[logging.KeyAttributeOldValue] = attr.Old
+			fields[logging.KeyAttributeNewValue] = attr.New
+		}
+
+		logging.HelperSchemaTrace(ctx, "Planned attribute change", fields)
+	}
+}
+
+// attributeLoggable returns whether the value of the attribute at the given
+// flatmap key may be included in SDK logs.
+func (m schemaMap) attributeLoggable(k string) bool {
+	schemaList := addrToSchema(strings.Split(k, "."), m)
+	if len(schemaList) == 0 {
+		return false
+	}
+
+	for _, schema := range schemaList {
+		if schema.Sensitive || schema.NoLog || schema.WriteOnly {
+			return false
+		}
+	}
+
+	return true
+}
+
 // Diff returns the diff for a resource given the schema map,
 // state, and configuration.
 func (m schemaMap) Diff(
@@ -926,6 +1194,72 @@ func (m schemaMap) Diff(
 	return schemaMapWithIdentity{m, nil}.Diff(ctx, s, c, customizeDiff, meta, handleRequiresNew)
 }
 
+// isUnchangedComputedOnly returns true if the given top level attribute is
+// Computed-only and has a value in the prior state of an existing resource.
+// Since such an attribute cannot be configured, its planned value is the
+// prior value and diffing it produces no changes, unless it is later
+// updated by CustomizeDiff. Empty collections are not considered unchanged,
+// since the diff may mark them as unknown.
+func isUnchangedComputedOnly(k string, schema *Schema, s *terraform.InstanceState) bool {
+	if !schema.Computed || schema.Optional || s == nil || s.ID == "" {
+		return false
+	}
+
+	switch schema.Type {
+	case TypeList, TypeSet:
+		v, ok := s.Attributes[k+".#"]
+		return ok && v != "0"
+	case TypeMap:
+		v, ok := s.Attributes[k+".%"]
+		return ok && v != "0"
+	default:
+		_, ok := s.Attributes[k]
+		return ok
+	}
+}
+
+// unknownIfChangedCustomizeDiff returns a CustomizeDiffFunc which plans the
+// Computed attributes with UnknownIfChanged as unknown when any of their
+// referenced attributes has changed, before calling the given
+// CustomizeDiffFunc. The given function is returned as-is if no attributes
+// set UnknownIfChanged.
+func (m schemaMap) unknownIfChangedCustomizeDiff(customizeDiff CustomizeDiffFunc) CustomizeDiffFunc {
+	var keys []string
+
+	for k, s := range m {
+		if s.Computed && len(s.UnknownIfChanged) > 0 {
+			keys = append(keys, k)
+		}
+	}
+
+	if len(keys) == 0 {
+		return customizeDiff
+	}
+
+	sort.Strings(keys)
+
+	return func(ctx context.Context, d *ResourceDiff, meta interface{}) error {
+		// Computed attributes are always unknown when creating a resource.
+		if d.Id() != "" {
+			for _, k := range keys {
+				if !d.HasChanges(m[k].UnknownIfChanged...) {
+					continue
+				}
+
+				if err := d.SetNewComputed(k); err != nil {
+					return err
+				}
+			}
+		}
+
+		if customizeDiff == nil {
+			return nil
+		}
+
+		return customizeDiff(ctx, d, meta)
+	}
+}
+
 // Validate validates the configuration against this schema mapping.
 func (m schemaMap) Validate(c *terraform.ResourceConfig) diag.Diagnostics {
 	return m.validateObject("", m, c, cty.Path{})
@@ -935,278 +1269,409 @@ func (m schemaMap) Validate(c *terraform.ResourceConfig) diag.Diagnostics {
 // from a unit test (and not in user-path code) to verify that a schema
 // is properly built.
 func (m schemaMap) InternalValidate(topSchemaMap schemaMap) error {
-	return m.internalValidate(topSchemaMap, false)
+	return m.internalValidate(topSchemaMap, false, false)
 }
 
 // TODO: Think about how to check something is a resource Identity so that we can check if RequiredForImport or OptionalForImport is set
-func (m schemaMap) internalValidate(topSchemaMap schemaMap, attrsOnly bool) error {
+//
+// The collectionDefaults parameter allows a Default for TypeList, TypeSet
+// and TypeMap attributes, which are only applied to the top-level attributes
+// of the provider configuration.
+func (m schemaMap) internalValidate(topSchemaMap schemaMap, attrsOnly bool, collectionDefaults bool) error {
 	if topSchemaMap == nil {
 		topSchemaMap = m
 	}
 	for k, v := range m {
-		if v.Type == TypeInvalid {
-			return fmt.Errorf("%s: Type must be specified", k)
+		if err := m.internalValidateAttribute(k, v, topSchemaMap, attrsOnly, collectionDefaults); err != nil {
+			return attributeSchemaError(k, err)
 		}
+	}
 
-		if v.Optional && v.Required {
-			return fmt.Errorf("%s: Optional or Required must be set, not both", k)
-		}
+	return nil
+}
 
-		if v.Required && v.Computed {
-			return fmt.Errorf("%s: Cannot be both Required and Computed", k)
-		}
+// internalValidateAttribute validates the schema of the attribute k of m.
+func (m schemaMap) internalValidateAttribute(k string, v *Schema, topSchemaMap schemaMap, attrsOnly bool, collectionDefaults bool) error {
+	if v.Type == TypeInvalid {
+		return fmt.Errorf("%s: Type must be specified", k)
+	}
 
-		if !v.Required && !v.Optional && !v.Computed {
-			return fmt.Errorf("%s: One of optional, required, or computed must be set", k)
-		}
+	if (v.Type == TypeList || v.Type == TypeSet || v.Type == TypeMap) && v.Default != nil && !collectionDefaults {
+		return fmt.Errorf("%s: Default for lists, sets or maps is only supported in top-level attributes of provider schemas", k)
+	}
 
-		if v.WriteOnly && v.Required && v.Optional {
-			return fmt.Errorf("%s: WriteOnly must be set with either Required or Optional", k)
-		}
+	if v.Optional && v.Required {
+		return fmt.Errorf("%s: Optional or Required must be set, not both", k)
+	}
 
-		if v.WriteOnly && v.Computed {
-			return fmt.Errorf("%s: WriteOnly cannot be set with Computed", k)
-		}
+	if v.Required && v.Computed {
+		return fmt.Errorf("%s: Cannot be both Required and Computed", k)
+	}
 
-		if v.WriteOnly && v.ForceNew {
-			return fmt.Errorf("%s: WriteOnly cannot be set with ForceNew", k)
-		}
+	if !v.Required && !v.Optional && !v.Computed {
+		return fmt.Errorf("%s: One of optional, required, or computed must be set", k)
+	}
 
-		if v.RequiredForImport {
-			return fmt.Errorf("%s: RequiredForImport is only valid for resource identity schemas", k)
-		}
-		if v.OptionalForImport {
-			return fmt.Errorf("%s: OptionalForImport is only valid for resource identity schemas", k)
-		}
+	if v.WriteOnly && v.Required && v.Optional {
+		return fmt.Errorf("%s: WriteOnly must be set with either Required or Optional", k)
+	}
 
-		computedOnly := v.Computed && !v.Optional
+	if v.WriteOnly && v.Computed {
+		return fmt.Errorf("%s: WriteOnly cannot be set with Computed", k)
+	}
 
-		switch v.ConfigMode {
-		case SchemaConfigModeBlock:
-			if _, ok := v.Elem.(*Resource); !ok {
-				return fmt.Errorf("%s: ConfigMode of block is allowed only when Elem is *schema.Resource", k)
-			}
+	if v.WriteOnly && v.ForceNew {
+		return fmt.Errorf("%s: WriteOnly cannot be set with ForceNew", k)
+	}
+
+	if v.WriteOnly && v.ForceNewFunc != nil {
+		return fmt.Errorf("%s: WriteOnly cannot be set with ForceNewFunc", k)
+	}
+
+	if v.RequiredForImport {
+		return fmt.Errorf("%s: RequiredForImport is only valid for resource identity schemas", k)
+	}
+	if v.OptionalForImport {
+		return fmt.Errorf("%s: OptionalForImport is only valid for resource identity schemas", k)
+	}
+
+	computedOnly := v.Computed && !v.Optional
+
+	switch v.ConfigMode {
+	case SchemaConfigModeBlock:
+		if _, ok := v.Elem.(*Resource); !ok {
+			return fmt.Errorf("%s: ConfigMode of block is allowed only when Elem is *schema.Resource", k)
+		}
+		if attrsOnly {
+			return fmt.Errorf("%s: ConfigMode of block cannot be used in child of schema with ConfigMode of attribute", k)
+		}
+		if computedOnly {
+			return fmt.Errorf("%s: ConfigMode of block cannot be used for computed schema", k)
+		}
+	case SchemaConfigModeAttr:
+		// anything goes
+	case SchemaConfigModeAuto:
+		// Since "Auto" for Elem: *Resource would create a nested block,
+		// and that's impossible inside an attribute, we require it to be
+		// explicitly overridden as mode "Attr" for clarity.
+		if _, ok := v.Elem.(*Resource); ok {
 			if attrsOnly {
-				return fmt.Errorf("%s: ConfigMode of block cannot be used in child of schema with ConfigMode of attribute", k)
-			}
-			if computedOnly {
-				return fmt.Errorf("%s: ConfigMode of block cannot be used for computed schema", k)
-			}
-		case SchemaConfigModeAttr:
-			// anything goes
-		case SchemaConfigModeAuto:
-			// Since "Auto" for Elem: *Resource would create a nested block,
-			// and that's impossible inside an attribute, we require it to be
-			// explicitly overridden as mode "Attr" for clarity.
-			if _, ok := v.Elem.(*Resource); ok {
-				if attrsOnly {
-					return fmt.Errorf("%s: in *schema.Resource with ConfigMode of attribute, so must also have ConfigMode of attribute", k)
-				}
+				return fmt.Errorf("%s: in *schema.Resource with ConfigMode of attribute, so must also have ConfigMode of attribute", k)
 			}
-		default:
-			return fmt.Errorf("%s: invalid ConfigMode value", k)
 		}
+	default:
+		return fmt.Errorf("%s: invalid ConfigMode value", k)
+	}
 
-		if v.Computed && v.Default != nil {
-			return fmt.Errorf("%s: Default must be nil if computed", k)
-		}
+	if v.Computed && v.Default != nil {
+		return fmt.Errorf("%s: Default must be nil if computed", k)
+	}
 
-		if v.Required && v.Default != nil {
-			return fmt.Errorf("%s: Default cannot be set with Required", k)
-		}
+	if v.Required && v.Default != nil {
+		return fmt.Errorf("%s: Default cannot be set with Required", k)
+	}
 
-		if v.WriteOnly && v.Default != nil {
-			return fmt.Errorf("%s: Default cannot be set with WriteOnly", k)
-		}
+	if v.WriteOnly && v.Default != nil {
+		return fmt.Errorf("%s: Default cannot be set with WriteOnly", k)
+	}
+
+	if v.WriteOnly && v.Default != nil {
+		return fmt.Errorf("%s: Default cannot be set with WriteOnly", k)
+	}
+
+	if v.WriteOnly && v.DefaultFunc != nil {
+		return fmt.Errorf("%s: DefaultFunc cannot be set with WriteOnly", k)
+	}
 
-		if v.WriteOnly && v.Default != nil {
-			return fmt.Errorf("%s: Default cannot be set with WriteOnly", k)
+	if v.DefaultContextFunc != nil {
+		if v.Default != nil || v.DefaultFunc != nil {
+			return fmt.Errorf("%s: DefaultContextFunc cannot be set with Default or DefaultFunc", k)
 		}
 
-		if v.WriteOnly && v.DefaultFunc != nil {
-			return fmt.Errorf("%s: DefaultFunc cannot be set with WriteOnly", k)
+		if v.Required {
+			return fmt.Errorf("%s: DefaultContextFunc cannot be set with Required", k)
 		}
 
-		if len(v.ComputedWhen) > 0 && !v.Computed {
-			return fmt.Errorf("%s: ComputedWhen can only be set with Computed", k)
+		if v.WriteOnly {
+			return fmt.Errorf("%s: DefaultContextFunc cannot be set with WriteOnly", k)
 		}
 
-		if len(v.ConflictsWith) > 0 && v.Required {
-			return fmt.Errorf("%s: ConflictsWith cannot be set with Required", k)
+		if v.Type == TypeList || v.Type == TypeSet || v.Type == TypeMap {
+			return fmt.Errorf("%s: DefaultContextFunc is only supported for primitive types", k)
 		}
+	}
 
-		if len(v.ExactlyOneOf) > 0 && v.Required {
-			return fmt.Errorf("%s: ExactlyOneOf cannot be set with Required", k)
+	if len(v.ComputedWhen) > 0 && !v.Computed {
+		return fmt.Errorf("%s: ComputedWhen can only be set with Computed", k)
+	}
+
+	if len(v.PlanModifiers) > 0 {
+		if topSchemaMap[k] != v {
+			return fmt.Errorf("%s: PlanModifiers is only valid for top level attributes", k)
 		}
 
-		if len(v.AtLeastOneOf) > 0 && v.Required {
-			return fmt.Errorf("%s: AtLeastOneOf cannot be set with Required", k)
+		if !v.Computed {
+			return fmt.Errorf("%s: PlanModifiers can only be set with Computed", k)
 		}
+	}
 
-		if len(v.ConflictsWith) > 0 {
-			err := checkKeysAgainstSchemaFlags(k, v.ConflictsWith, topSchemaMap, v, false)
-			if err != nil {
-				return fmt.Errorf("ConflictsWith: %+v", err)
-			}
+	if len(v.UnknownIfChanged) > 0 {
+		if !v.Computed {
+			return fmt.Errorf("%s: UnknownIfChanged can only be set with Computed", k)
 		}
 
-		if len(v.RequiredWith) > 0 {
-			err := checkKeysAgainstSchemaFlags(k, v.RequiredWith, topSchemaMap, v, true)
-			if err != nil {
-				return fmt.Errorf("RequiredWith: %+v", err)
-			}
+		if topSchemaMap[k] != v {
+			return fmt.Errorf("%s: UnknownIfChanged is only valid for top level attributes", k)
 		}
 
-		if len(v.ExactlyOneOf) > 0 {
-			err := checkKeysAgainstSchemaFlags(k, v.ExactlyOneOf, topSchemaMap, v, true)
-			if err != nil {
-				return fmt.Errorf("ExactlyOneOf: %+v", err)
+		for _, key := range v.UnknownIfChanged {
+			if key == k {
+				return fmt.Errorf("%s: UnknownIfChanged cannot reference self", k)
 			}
-		}
 
-		if len(v.AtLeastOneOf) > 0 {
-			err := checkKeysAgainstSchemaFlags(k, v.AtLeastOneOf, topSchemaMap, v, true)
-			if err != nil {
-				return fmt.Errorf("AtLeastOneOf: %+v", err)
+			if len(addrToSchema(strings.Split(key, "."), topSchemaMap)) == 0 {
+				return fmt.Errorf("%s: UnknownIfChanged references unknown attribute (%s)", k, key)
 			}
 		}
+	}
 
-		if v.DiffSuppressOnRefresh && v.DiffSuppressFunc == nil {
-			return fmt.Errorf("%s: cannot set DiffSuppressOnRefresh without DiffSuppressFunc", k)
-		}
+	if len(v.ConflictsWith) > 0 && v.Required {
+		return fmt.Errorf("%s: ConflictsWith cannot be set with Required", k)
+	}
 
-		if v.Type == TypeList || v.Type == TypeSet {
-			if v.WriteOnly {
-				return fmt.Errorf("%s: WriteOnly is not valid for lists or sets", k)
-			}
+	if len(v.ExactlyOneOf) > 0 && v.Required {
+		return fmt.Errorf("%s: ExactlyOneOf cannot be set with Required", k)
+	}
 
-			if v.Elem == nil {
-				return fmt.Errorf("%s: Elem must be set for lists", k)
-			}
+	if len(v.AtLeastOneOf) > 0 && v.Required {
+		return fmt.Errorf("%s: AtLeastOneOf cannot be set with Required", k)
+	}
 
-			if v.Default != nil {
-				return fmt.Errorf("%s: Default is not valid for lists or sets", k)
-			}
+	if len(v.ConflictsWith) > 0 {
+		err := m.checkConstraintKeys(k, v.ConflictsWith, topSchemaMap, v, false)
+		if err != nil {
+			return fmt.Errorf("ConflictsWith: %+v", err)
+		}
+	}
 
-			if v.Type != TypeSet && v.Set != nil {
-				return fmt.Errorf("%s: Set can only be set for TypeSet", k)
-			}
+	if len(v.RequiredWith) > 0 {
+		err := m.checkConstraintKeys(k, v.RequiredWith, topSchemaMap, v, true)
+		if err != nil {
+			return fmt.Errorf("RequiredWith: %+v", err)
+		}
+	}
 
-			switch t := v.Elem.(type) {
-			case *Resource:
-				attrsOnly := attrsOnly || v.ConfigMode == SchemaConfigModeAttr
+	if len(v.ExactlyOneOf) > 0 {
+		err := m.checkConstraintKeys(k, v.ExactlyOneOf, topSchemaMap, v, true)
+		if err != nil {
+			return fmt.Errorf("ExactlyOneOf: %+v", err)
+		}
+	}
 
-				blockHasWriteOnly := schemaMap(t.SchemaMap()).hasWriteOnly()
+	if len(v.AtLeastOneOf) > 0 {
+		err := checkKeysAgainstSchemaFlags(k, v.AtLeastOneOf, topSchemaMap, v, true)
+		if err != nil {
+			return fmt.Errorf("AtLeastOneOf: %+v", err)
+		}
+	}
 
-				if v.Type == TypeSet && blockHasWriteOnly {
-					return fmt.Errorf("%s: Set Block type cannot contain WriteOnly attributes", k)
-				}
+	if v.DiffSuppressOnRefresh && v.DiffSuppressFunc == nil {
+		return fmt.Errorf("%s: cannot set DiffSuppressOnRefresh without DiffSuppressFunc", k)
+	}
 
-				if v.Computed && blockHasWriteOnly {
-					return fmt.Errorf("%s: Block types with Computed set to true cannot contain WriteOnly attributes", k)
-				}
+	if v.Type == TypeList || v.Type == TypeSet {
+		if v.WriteOnly {
+			return fmt.Errorf("%s: WriteOnly is not valid for lists or sets", k)
+		}
 
-				if err := schemaMap(t.SchemaMap()).internalValidate(topSchemaMap, attrsOnly); err != nil {
-					return err
-				}
-			case *Schema:
-				bad := t.Computed || t.Optional || t.Required
-				if bad {
-					return fmt.Errorf(
-						"%s: Elem must have only Type set", k)
-				}
-			}
-		} else {
-			if v.MaxItems > 0 || v.MinItems > 0 {
-				return fmt.Errorf("%s: MaxItems and MinItems are only supported on lists or sets", k)
-			}
+		if v.Elem == nil {
+			return fmt.Errorf("%s: Elem must be set for lists", k)
 		}
 
-		if v.Type == TypeMap && v.Elem != nil {
-			if v.WriteOnly {
-				return fmt.Errorf("%s: WriteOnly is not valid for maps", k)
+		if v.Default != nil {
+			if _, ok := v.Elem.(*Resource); ok {
+				return fmt.Errorf("%s: Default is not valid for lists or sets of nested blocks", k)
 			}
 
-			switch v.Elem.(type) {
-			case *Resource:
-				return fmt.Errorf("%s: TypeMap with Elem *Resource not supported,"+
-					"use TypeList/TypeSet with Elem *Resource or TypeMap with Elem *Schema", k)
+			if err := validateCollectionDefault(v); err != nil {
+				return fmt.Errorf("%s: %w", k, err)
 			}
 		}
 
-		if computedOnly {
-			if len(v.AtLeastOneOf) > 0 {
-				return fmt.Errorf("%s: AtLeastOneOf is for configurable attributes,"+
-					"there's nothing to configure on computed-only field", k)
-			}
-			if len(v.ConflictsWith) > 0 {
-				return fmt.Errorf("%s: ConflictsWith is for configurable attributes,"+
-					"there's nothing to configure on computed-only field", k)
-			}
-			if v.Default != nil {
-				return fmt.Errorf("%s: Default is for configurable attributes,"+
-					"there's nothing to configure on computed-only field", k)
-			}
-			if v.DefaultFunc != nil {
-				return fmt.Errorf("%s: DefaultFunc is for configurable attributes,"+
-					"there's nothing to configure on computed-only field", k)
-			}
-			if v.DiffSuppressFunc != nil {
-				return fmt.Errorf("%s: DiffSuppressFunc is for suppressing differences"+
-					" between config and state representation. "+
-					"There is no config for computed-only field, nothing to compare.", k)
-			}
-			if len(v.ExactlyOneOf) > 0 {
-				return fmt.Errorf("%s: ExactlyOneOf is for configurable attributes,"+
-					"there's nothing to configure on computed-only field", k)
+		if v.Type != TypeSet && v.Set != nil {
+			return fmt.Errorf("%s: Set can only be set for TypeSet", k)
+		}
+
+		switch t := v.Elem.(type) {
+		case *Resource:
+			attrsOnly := attrsOnly || v.ConfigMode == SchemaConfigModeAttr
+
+			blockHasWriteOnly := schemaMap(t.SchemaMap()).hasWriteOnly()
+
+			if v.Type == TypeSet && blockHasWriteOnly {
+				return fmt.Errorf("%s: Set Block type cannot contain WriteOnly attributes", k)
 			}
-			if v.InputDefault != "" {
-				return fmt.Errorf("%s: InputDefault is for configurable attributes,"+
-					"there's nothing to configure on computed-only field", k)
+
+			if v.Computed && blockHasWriteOnly {
+				return fmt.Errorf("%s: Block types with Computed set to true cannot contain WriteOnly attributes", k)
 			}
-			if v.MaxItems > 0 {
-				return fmt.Errorf("%s: MaxItems is for configurable attributes,"+
-					"there's nothing to configure on computed-only field", k)
+
+			if err := schemaMap(t.SchemaMap()).internalValidate(topSchemaMap, attrsOnly, false); err != nil {
+				return err
 			}
-			if v.MinItems > 0 {
-				return fmt.Errorf("%s: MinItems is for configurable attributes,"+
-					"there's nothing to configure on computed-only field", k)
+		case *Schema:
+			bad := t.Computed || t.Optional || t.Required
+			if bad {
+				return fmt.Errorf(
+					"%s: Elem must have only Type set", k)
 			}
-			if v.StateFunc != nil {
-				return fmt.Errorf("%s: StateFunc is extraneous, "+
-					"value should just be changed before setting on computed-only field", k)
+
+			if t.WriteOnly {
+				return fmt.Errorf("%s: Elem cannot set WriteOnly, since list and set elements are stored in state", k)
 			}
-			if v.ValidateFunc != nil {
-				return fmt.Errorf("%s: ValidateFunc is for validating user input, "+
-					"there's nothing to validate on computed-only field", k)
+		}
+	} else {
+		if v.MaxItems > 0 || v.MinItems > 0 {
+			return fmt.Errorf("%s: MaxItems and MinItems are only supported on lists or sets", k)
+		}
+	}
+
+	if v.Type == TypeMap && v.Elem != nil {
+		if v.WriteOnly {
+			return fmt.Errorf("%s: WriteOnly is not valid for maps", k)
+		}
+
+		switch t := v.Elem.(type) {
+		case *Resource:
+			if !v.isObjectMap() {
+				return fmt.Errorf("%s: TypeMap with Elem *Resource not supported,"+
+					"use TypeList/TypeSet with Elem *Resource, TypeMap with Elem *Schema or TypeMap with ConfigMode of attribute", k)
 			}
-			if v.ValidateDiagFunc != nil {
-				return fmt.Errorf("%s: ValidateDiagFunc is for validating user input, "+
-					"there's nothing to validate on computed-only field", k)
+
+			if err := schemaMap(t.SchemaMap()).internalValidate(topSchemaMap, true, false); err != nil {
+				return err
 			}
 		}
 
-		if v.ValidateFunc != nil || v.ValidateDiagFunc != nil {
-			switch v.Type {
-			case TypeList, TypeSet:
-				return fmt.Errorf("%s: ValidateFunc and ValidateDiagFunc are not yet supported on lists or sets.", k)
+		if v.Default != nil {
+			if err := validateCollectionDefault(v); err != nil {
+				return fmt.Errorf("%s: %w", k, err)
 			}
 		}
+	}
 
-		if v.ValidateFunc != nil && v.ValidateDiagFunc != nil {
-			return fmt.Errorf("%s: ValidateFunc and ValidateDiagFunc cannot both be set", k)
+	if computedOnly {
+		if len(v.AtLeastOneOf) > 0 {
+			return fmt.Errorf("%s: AtLeastOneOf is for configurable attributes,"+
+				"there's nothing to configure on computed-only field", k)
+		}
+		if len(v.ConflictsWith) > 0 {
+			return fmt.Errorf("%s: ConflictsWith is for configurable attributes,"+
+				"there's nothing to configure on computed-only field", k)
+		}
+		if v.Default != nil {
+			return fmt.Errorf("%s: Default is for configurable attributes,"+
+				"there's nothing to configure on computed-only field", k)
+		}
+		if v.DefaultFunc != nil {
+			return fmt.Errorf("%s: DefaultFunc is for configurable attributes,"+
+				"there's nothing to configure on computed-only field", k)
+		}
+		if v.DefaultContextFunc != nil {
+			return fmt.Errorf("%s: DefaultContextFunc is for configurable attributes,"+
+				"there's nothing to configure on computed-only field", k)
+		}
+		if v.DiffSuppressFunc != nil {
+			return fmt.Errorf("%s: DiffSuppressFunc is for suppressing differences"+
+				" between config and state representation. "+
+				"There is no config for computed-only field, nothing to compare.", k)
+		}
+		if len(v.ExactlyOneOf) > 0 {
+			return fmt.Errorf("%s: ExactlyOneOf is for configurable attributes,"+
+				"there's nothing to configure on computed-only field", k)
+		}
+		if v.InputDefault != "" {
+			return fmt.Errorf("%s: InputDefault is for configurable attributes,"+
+				"there's nothing to configure on computed-only field", k)
 		}
+		if v.MaxItems > 0 {
+			return fmt.Errorf("%s: MaxItems is for configurable attributes,"+
+				"there's nothing to configure on computed-only field", k)
+		}
+		if v.MinItems > 0 {
+			return fmt.Errorf("%s: MinItems is for configurable attributes,"+
+				"there's nothing to configure on computed-only field", k)
+		}
+		if v.StateFunc != nil {
+			return fmt.Errorf("%s: StateFunc is extraneous, "+
+				"value should just be changed before setting on computed-only field", k)
+		}
+		if v.ValidateFunc != nil {
+			return fmt.Errorf("%s: ValidateFunc is for validating user input, "+
+				"there's nothing to validate on computed-only field", k)
+		}
+		if v.ValidateDiagFunc != nil {
+			return fmt.Errorf("%s: ValidateDiagFunc is for validating user input, "+
+				"there's nothing to validate on computed-only field", k)
+		}
+	}
+
+	if v.ValidateFunc != nil || v.ValidateDiagFunc != nil {
+		switch v.Type {
+		case TypeList, TypeSet:
+			return fmt.Errorf("%s: ValidateFunc and ValidateDiagFunc are not yet supported on lists or sets.", k)
+		}
+	}
+
+	if v.ValidateFunc != nil && v.ValidateDiagFunc != nil {
+		return fmt.Errorf("%s: ValidateFunc and ValidateDiagFunc cannot both be set", k)
+	}
+
+	if v.ValidateKeysFunc != nil && v.Type != TypeMap {
+		return fmt.Errorf("%s: ValidateKeysFunc is only supported on maps", k)
+	}
 
+	if v.DeprecatedReplacedBy != "" {
 		if v.Deprecated == "" {
-			if !isValidFieldName(k) {
-				return fmt.Errorf("%s: Field name may only contain lowercase alphanumeric characters & underscores.", k)
-			}
+			return fmt.Errorf("%s: DeprecatedReplacedBy can only be set with Deprecated", k)
+		}
+
+		parts := strings.Split(v.DeprecatedReplacedBy, ".")
+		if len(addrToSchema(parts, topSchemaMap)) == 0 && len(addrToSchema(parts, m)) == 0 {
+			return fmt.Errorf("%s: DeprecatedReplacedBy references unknown attribute (%s)", k, v.DeprecatedReplacedBy)
+		}
+	}
+
+	if v.Deprecated == "" {
+		if !isValidFieldName(k) {
+			return fmt.Errorf("%s: Field name may only contain lowercase alphanumeric characters & underscores.", k)
 		}
 	}
 
 	return nil
 }
 
+// checkConstraintKeys is checkKeysAgainstSchemaFlags for constraint keys
+// which can be relative to the containing block. Keys of a nested attribute
+// which are not absolute attribute paths are checked against its siblings.
+func (m schemaMap) checkConstraintKeys(k string, keys []string, topSchemaMap schemaMap, self *Schema, allowSelfReference bool) error {
+	var absoluteKeys, relativeKeys []string
+	for _, key := range keys {
+		if topSchemaMap[k] != self && len(addrToSchema(strings.Split(key, "."), topSchemaMap)) == 0 {
+			relativeKeys = append(relativeKeys, key)
+			continue
+		}
+
+		absoluteKeys = append(absoluteKeys, key)
+	}
+
+	if err := checkKeysAgainstSchemaFlags(k, absoluteKeys, topSchemaMap, self, allowSelfReference); err != nil {
+		return err
+	}
+
+	return checkKeysAgainstSchemaFlags(k, relativeKeys, m, self, allowSelfReference)
+}
+
 func checkKeysAgainstSchemaFlags(k string, keys []string, topSchemaMap schemaMap, self *Schema, allowSelfReference bool) error {
 	for _, key := range keys {
 		parts := strings.Split(key, ".")
@@ -1263,6 +1728,29 @@ func checkKeysAgainstSchemaFlags(k string, keys []string, topSchemaMap schemaMap
 	return nil
 }
 
+// validateCollectionDefault ensures the Default of a TypeList, TypeSet, or
+// TypeMap schema structurally matches the Elem type.
+func validateCollectionDefault(schema *Schema) error {
+	switch schema.Type {
+	case TypeList, TypeSet:
+		if _, ok := schema.Default.([]interface{}); !ok {
+			return fmt.Errorf("Default must be a []interface{}, got %T", schema.Default)
+		}
+	case TypeMap:
+		if _, ok := schema.Default.(map[string]interface{}); !ok {
+			return fmt.Errorf("Default must be a map[string]interface{}, got %T", schema.Default)
+		}
+	}
+
+	val := hcl2shim.HCL2ValueFromConfigValue(schema.Default)
+
+	if _, err := ctyconvert.Convert(val, schema.coreConfigSchemaType()); err != nil {
+		return fmt.Errorf("Default does not match the Elem type: %s", tfdiags.FormatError(err))
+	}
+
+	return nil
+}
+
 var validFieldNameRe = regexp.MustCompile("^[a-z0-9_]+$")
 
 func isValidFieldName(name string) bool {
@@ -1300,13 +1788,21 @@ func (m schemaMap) diff(
 	case TypeList:
 		err = m.diffList(ctx, k, schema, unsuppressedDiff, d, all)
 	case TypeMap:
-		err = m.diffMap(k, schema, unsuppressedDiff, d, all)
+		if schema.isObjectMap() {
+			err = m.diffObjectMap(ctx, k, schema, unsuppressedDiff, d, all)
+		} else {
+			err = m.diffMap(k, schema, unsuppressedDiff, d, all)
+		}
 	case TypeSet:
 		err = m.diffSet(ctx, k, schema, unsuppressedDiff, d, all)
 	default:
 		err = fmt.Errorf("%s: unknown type %#v", k, schema.Type)
 	}
 
+	if schema.ForceNewFunc != nil {
+		m.applyForceNewFunc(ctx, k, schema, unsuppressedDiff, d, all)
+	}
+
 	for attrK, attrV := range unsuppressedDiff.Attributes {
 		switch rd := d.(type) {
 		case *ResourceData:
@@ -1319,17 +1815,81 @@ func (m schemaMap) diff(
 					continue
 				}
 
-				logging.HelperSchemaDebug(ctx, "Ignoring change due to DiffSuppressFunc", map[string]interface{}{logging.KeyAttributePath: attrK})
-				attrV = &terraform.ResourceAttrDiff{
-					Old: attrV.Old,
-					New: attrV.Old,
-				}
+				logging.HelperSchemaDebug(ctx, "Ignoring change due to DiffSuppressFunc", map[string]interface{}{logging.KeyAttributePath: attrK})
+				attrV = &terraform.ResourceAttrDiff{
+					Old: attrV.Old,
+					New: attrV.Old,
+				}
+			}
+		}
+		diff.Attributes[attrK] = attrV
+	}
+
+	return err
+}
+
+// applyForceNewFunc sets RequiresNew on the changed attributes of the diff
+// of k according to the ForceNewFunc of its schema. When the func returns
+// false, only the RequiresNew set by the ForceNew of the schema itself is
+// removed, so nested attributes which are ForceNew still require
+// replacement.
+func (m schemaMap) applyForceNewFunc(
+	ctx context.Context,
+	k string,
+	schema *Schema,
+	diff *terraform.InstanceDiff,
+	d resourceDiffer,
+	all bool) {
+	if d.Id() == "" {
+		return
+	}
+
+	o, n, _, computed, _ := d.diffChange(k)
+	if computed {
+		return
+	}
+
+	for _, attr := range diff.Attributes {
+		if attr != nil && attr.NewComputed {
+			return
+		}
+	}
+
+	if schema.ForceNewFunc(ctx, o, n) {
+		for _, attr := range diff.Attributes {
+			if attr != nil && attr.Old != attr.New {
+				attr.RequiresNew = true
 			}
 		}
-		diff.Attributes[attrK] = attrV
+
+		return
 	}
 
-	return err
+	if !schema.ForceNew {
+		return
+	}
+
+	// Diff again without the ForceNew of the schema to find the attributes
+	// which require replacement regardless of it.
+	withoutForceNew := *schema
+	withoutForceNew.ForceNew = false
+	withoutForceNew.ForceNewFunc = nil
+
+	base := new(terraform.InstanceDiff)
+	base.Attributes = make(map[string]*terraform.ResourceAttrDiff)
+
+	if err := m.diff(ctx, k, &withoutForceNew, base, d, all); err != nil {
+		return
+	}
+
+	for attrK, attr := range diff.Attributes {
+		if attr == nil {
+			continue
+		}
+
+		baseAttr := base.Attributes[attrK]
+		attr.RequiresNew = baseAttr != nil && baseAttr.RequiresNew
+	}
 }
 
 func (m schemaMap) diffList(
@@ -1572,6 +2132,93 @@ func (m schemaMap) diffMap(
 	return nil
 }
 
+// diffObjectMap diffs a TypeMap of objects. The count is diffed like any
+// other map, while each object is diffed attribute by attribute like the
+// elements of a TypeList of *Resource.
+func (m schemaMap) diffObjectMap(
+	ctx context.Context,
+	k string,
+	schema *Schema,
+	diff *terraform.InstanceDiff,
+	d resourceDiffer,
+	all bool) error {
+	o, n, _, nComputed, customized := d.diffChange(k)
+	stateMap, _ := o.(map[string]interface{})
+	configMap, _ := n.(map[string]interface{})
+
+	// Keep track of whether the state _exists_ at all prior to clearing it
+	stateExists := o != nil
+
+	// Check if the number of elements has changed.
+	oldLen, newLen := len(stateMap), len(configMap)
+	changed := oldLen != newLen
+	if oldLen != 0 && newLen == 0 && schema.Computed {
+		changed = false
+	}
+
+	// It is computed if we have no old value, no new value, the schema
+	// says it is computed, and it didn't exist in the state before.
+	computed := oldLen == 0 && newLen == 0 && schema.Computed && !stateExists
+
+	if changed || computed || nComputed || all {
+		countSchema := &Schema{
+			Type:     TypeInt,
+			Computed: schema.Computed || nComputed,
+			ForceNew: schema.ForceNew,
+		}
+
+		oldStr := strconv.FormatInt(int64(oldLen), 10)
+		newStr := ""
+		if !computed && !nComputed {
+			newStr = strconv.FormatInt(int64(newLen), 10)
+		} else {
+			oldStr = ""
+		}
+
+		finalizedAttr := countSchema.finalizeDiff(
+			&terraform.ResourceAttrDiff{
+				Old: oldStr,
+				New: newStr,
+			},
+			customized,
+		)
+		if finalizedAttr != nil {
+			diff.Attributes[k+".%"] = finalizedAttr
+		} else {
+			delete(diff.Attributes, k+".%")
+		}
+	}
+
+	// If the new map is computed, or nil and we're computed, then there is
+	// nothing more to diff.
+	if nComputed || (n == nil && schema.Computed) {
+		return nil
+	}
+
+	keys := make(map[string]struct{}, len(stateMap)+len(configMap))
+	for key := range stateMap {
+		keys[key] = struct{}{}
+	}
+	for key := range configMap {
+		keys[key] = struct{}{}
+	}
+
+	for key := range keys {
+		if strings.Contains(key, ".") {
+			return fmt.Errorf("%s: map key %q must not contain periods", k, key)
+		}
+
+		for k2, schema := range schema.Elem.(*Resource).SchemaMap() {
+			subK := fmt.Sprintf("%s.%s.%s", k, key, k2)
+			if err := m.diff(ctx, subK, schema, diff, d, all); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func (m schemaMap) diffSet(
 	ctx context.Context,
 	k string,
@@ -1792,6 +2439,9 @@ func (m schemaMapWithIdentity) handleDiffSuppressOnRefresh(ctx context.Context,
 		if newV == oldV {
 			continue // no change to test
 		}
+		if newV == hcl2shim.UnknownVariableValue || oldV == hcl2shim.UnknownVariableValue {
+			continue // only known values can be compared
+		}
 
 		schemaList := addrToSchema(strings.Split(k, "."), m.schemaMap)
 		if len(schemaList) == 0 {
@@ -1818,6 +2468,10 @@ func (m schemaMapWithIdentity) handleDiffSuppressOnRefresh(ctx context.Context,
 		if schema.DiffSuppressFunc(k, oldV, newV, d) {
 			tfsdklog.Debug(ctx, fmt.Sprintf("ignoring change of %q due to DiffSuppressFunc", k))
 			newState.Attributes[k] = oldV // keep the old value, then
+
+			// A value given to ResourceData.SetRawState would otherwise
+			// take precedence over the suppressed attributes.
+			newState.RawState = cty.NilVal
 		}
 	}
 }
@@ -1848,9 +2502,17 @@ func (m schemaMap) validate(
 		ok = raw != nil
 	}
 
-	err := validateExactlyOneAttribute(k, schema, c)
-	if err != nil {
-		return append(diags, diag.Diagnostic{
+	// A static Default is not validated, but the config field reader still
+	// converts it to the schema's Type when the attribute is read.
+	if !ok && schema.Default != nil {
+		diags = append(diags, schema.coercionWarning(schema.Default, "default value", k, path)...)
+	}
+
+	// The ConflictsWith, ExactlyOneOf, AtLeastOneOf, and RequiredWith
+	// constraints are independent of each other, so all of their errors are
+	// reported together rather than only the first one.
+	if err := validateExactlyOneAttribute(k, schema, m, c); err != nil {
+		diags = append(diags, diag.Diagnostic{
 			Severity:      diag.Error,
 			Summary:       "Invalid combination of arguments",
 			Detail:        err.Error(),
@@ -1858,9 +2520,8 @@ func (m schemaMap) validate(
 		})
 	}
 
-	err = validateAtLeastOneAttribute(k, schema, c)
-	if err != nil {
-		return append(diags, diag.Diagnostic{
+	if err := validateAtLeastOneAttribute(k, schema, c); err != nil {
+		diags = append(diags, diag.Diagnostic{
 			Severity:      diag.Error,
 			Summary:       "Missing required argument",
 			Detail:        err.Error(),
@@ -1890,9 +2551,8 @@ func (m schemaMap) validate(
 		})
 	}
 
-	err = validateRequiredWithAttribute(k, schema, c)
-	if err != nil {
-		return append(diags, diag.Diagnostic{
+	if err := validateRequiredWithAttribute(k, schema, m, c); err != nil {
+		diags = append(diags, diag.Diagnostic{
 			Severity:      diag.Error,
 			Summary:       "Missing required argument",
 			Detail:        err.Error(),
@@ -1906,12 +2566,11 @@ func (m schemaMap) validate(
 	// The SDK has to allow the unknown value through initially, so that
 	// Required fields set via an interpolated value are accepted.
 	if !isWhollyKnown(raw) {
-		return nil
+		return diags
 	}
 
-	err = validateConflictingAttributes(k, schema, c)
-	if err != nil {
-		return append(diags, diag.Diagnostic{
+	if err := validateConflictingAttributes(k, schema, m, c); err != nil {
+		diags = append(diags, diag.Diagnostic{
 			Severity:      diag.Error,
 			Summary:       "Conflicting configuration arguments",
 			Detail:        err.Error(),
@@ -1919,9 +2578,40 @@ func (m schemaMap) validate(
 		})
 	}
 
+	if diags.HasError() {
+		return diags
+	}
+
 	return m.validateType(k, raw, schema, c, path)
 }
 
+// constraintValueReader is the interface used to evaluate the ConflictsWith,
+// ExactlyOneOf, AtLeastOneOf, and RequiredWith schema constraints. It is
+// implemented by terraform.ResourceConfig for configuration validation and
+// by resourceDiffConstraintReader for ResourceDiff.CheckConstraints.
+type constraintValueReader interface {
+	Get(k string) (interface{}, bool)
+	IsComputed(k string) bool
+}
+
+// isUnknownConstraintKey returns true if the value at the configuration key
+// k is unknown, or if it is nested in a configuration block which is unknown
+// as a whole.
+func isUnknownConstraintKey(c constraintValueReader, k string) bool {
+	if c.IsComputed(k) {
+		return true
+	}
+
+	parts := strings.Split(k, ".")
+	for i := 1; i < len(parts); i++ {
+		if raw, ok := c.Get(strings.Join(parts[:i], ".")); ok && raw == hcl2shim.UnknownVariableValue {
+			return true
+		}
+	}
+
+	return false
+}
+
 // isWhollyKnown returns false if the argument contains an UnknownVariableValue
 func isWhollyKnown(raw interface{}) bool {
 	switch raw := raw.(type) {
@@ -1947,13 +2637,14 @@ func isWhollyKnown(raw interface{}) bool {
 func validateConflictingAttributes(
 	k string,
 	schema *Schema,
-	c *terraform.ResourceConfig) error {
+	topSchemaMap schemaMap,
+	c constraintValueReader) error {
 
 	if len(schema.ConflictsWith) == 0 {
 		return nil
 	}
 
-	for _, conflictingKey := range schema.ConflictsWith {
+	for _, conflictingKey := range constraintKeys(k, schema.ConflictsWith, topSchemaMap) {
 		if raw, ok := c.Get(conflictingKey); ok {
 			if raw == hcl2shim.UnknownVariableValue {
 				// An unknown value might become unset (null) once known, so
@@ -1985,16 +2676,23 @@ func removeDuplicates(elements []string) []string {
 func validateRequiredWithAttribute(
 	k string,
 	schema *Schema,
-	c *terraform.ResourceConfig) error {
+	topSchemaMap schemaMap,
+	c constraintValueReader) error {
 
 	if len(schema.RequiredWith) == 0 {
 		return nil
 	}
 
-	allKeys := removeDuplicates(append(schema.RequiredWith, k))
+	allKeys := removeDuplicates(append(constraintKeys(k, schema.RequiredWith, topSchemaMap), k))
 	sort.Strings(allKeys)
 
 	for _, key := range allKeys {
+		// An unknown value, including one in an unknown configuration block,
+		// might be set once known, so only a known missing value is an error.
+		if isUnknownConstraintKey(c, key) {
+			continue
+		}
+
 		if _, ok := c.Get(key); !ok {
 			return fmt.Errorf("%q: all of `%s` must be specified", k, strings.Join(allKeys, ","))
 		}
@@ -2003,16 +2701,39 @@ func validateRequiredWithAttribute(
 	return nil
 }
 
+// constraintKeys returns the ConflictsWith, ExactlyOneOf, or RequiredWith
+// keys of the attribute at k as absolute configuration keys. For a nested
+// attribute, such as "block.1.attr", keys which are not absolute attribute
+// paths are relative to the containing block, such as "block.1.sibling".
+func constraintKeys(k string, keys []string, topSchemaMap schemaMap) []string {
+	i := strings.LastIndex(k, ".")
+	if i == -1 {
+		return keys
+	}
+
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if len(addrToSchema(strings.Split(key, "."), topSchemaMap)) == 0 {
+			key = k[:i+1] + key
+		}
+
+		result = append(result, key)
+	}
+
+	return result
+}
+
 func validateExactlyOneAttribute(
 	k string,
 	schema *Schema,
-	c *terraform.ResourceConfig) error {
+	topSchemaMap schemaMap,
+	c constraintValueReader) error {
 
 	if len(schema.ExactlyOneOf) == 0 {
 		return nil
 	}
 
-	allKeys := removeDuplicates(append(schema.ExactlyOneOf, k))
+	allKeys := removeDuplicates(append(constraintKeys(k, schema.ExactlyOneOf, topSchemaMap), k))
 	sort.Strings(allKeys)
 	specified := make([]string, 0)
 	unknownVariableValueCount := 0
@@ -2042,7 +2763,7 @@ func validateExactlyOneAttribute(
 func validateAtLeastOneAttribute(
 	k string,
 	schema *Schema,
-	c *terraform.ResourceConfig) error {
+	c constraintValueReader) error {
 
 	if len(schema.AtLeastOneOf) == 0 {
 		return nil
@@ -2201,15 +2922,59 @@ func (m schemaMap) validateMap(
 		})
 	}
 
+	if t, ok := schema.Elem.(*Resource); ok && schema.isObjectMap() {
+		mapIface, ok := rawV.Interface().(map[string]interface{})
+		if !ok {
+			return append(diags, diag.Diagnostic{
+				Severity:      diag.Error,
+				Summary:       "Attribute must be a map",
+				AttributePath: path,
+			})
+		}
+
+		diags = append(diags, schema.validateKeys(mapIface, path)...)
+
+		keys := make([]string, 0, len(mapIface))
+		for key := range mapIface {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			p := append(path.Copy(), cty.IndexStep{Key: cty.StringVal(key)})
+
+			// The flatmap representation of a map of objects cannot
+			// distinguish a period in a key from an attribute separator.
+			if strings.Contains(key, ".") {
+				diags = append(diags, diag.Diagnostic{
+					Severity:      diag.Error,
+					Summary:       "Invalid map key",
+					Detail:        fmt.Sprintf("Map key %q must not contain periods.", key),
+					AttributePath: p,
+				})
+				continue
+			}
+
+			diags = append(diags, m.validateObject(k+"."+key, t.SchemaMap(), c, p)...)
+		}
+
+		if diags.HasError() {
+			return diags
+		}
+
+		return append(diags, schema.validateFunc(mapIface, k, path)...)
+	}
+
 	// If it is not a slice, validate directly
 	if rawV.Kind() != reflect.Slice {
 		mapIface := rawV.Interface()
+		diags = append(diags, schema.validateKeys(mapIface.(map[string]interface{}), path)...)
 		diags = append(diags, validateMapValues(k, mapIface.(map[string]interface{}), schema, path)...)
 		if diags.HasError() {
 			return diags
 		}
 
-		return schema.validateFunc(mapIface, k, path)
+		return append(diags, schema.validateFunc(mapIface, k, path)...)
 	}
 
 	// It is a slice, verify that all the elements are maps
@@ -2228,6 +2993,7 @@ func (m schemaMap) validateMap(
 			})
 		}
 		mapIface := v.Interface()
+		diags = append(diags, schema.validateKeys(mapIface.(map[string]interface{}), path)...)
 		diags = append(diags, validateMapValues(k, mapIface.(map[string]interface{}), schema, path)...)
 		if diags.HasError() {
 			return diags
@@ -2241,7 +3007,36 @@ func (m schemaMap) validateMap(
 		}
 	}
 
-	return schema.validateFunc(validatableMap, k, path)
+	return append(diags, schema.validateFunc(validatableMap, k, path)...)
+}
+
+// validateKeys calls the ValidateKeysFunc of the schema, if any, for each key
+// of the map, in key order.
+func (s *Schema) validateKeys(m map[string]interface{}, path cty.Path) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	if s.ValidateKeysFunc == nil {
+		return diags
+	}
+
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		keyDiags := s.ValidateKeysFunc(key)
+		for i := range keyDiags {
+			if keyDiags[i].AttributePath == nil {
+				keyDiags[i].AttributePath = append(path.Copy(), cty.IndexStep{Key: cty.StringVal(key)})
+			}
+		}
+
+		diags = append(diags, keyDiags...)
+	}
+
+	return diags
 }
 
 func validateMapValues(k string, m map[string]interface{}, schema *Schema, path cty.Path) diag.Diagnostics {
@@ -2482,9 +3277,77 @@ func (m schemaMap) validatePrimitive(
 		panic(fmt.Sprintf("Unknown validation type: %#v", schema.Type))
 	}
 
+	diags = append(diags, schema.coercionWarning(raw, "value given", k, path)...)
+
 	return append(diags, schema.validateFunc(decoded, k, path)...)
 }
 
+// coercionWarning returns a warning diagnostic if WarnOnCoercion is enabled
+// and the raw value will be converted to the schema's Type. The desc names
+// the origin of the value, such as "value given" or "default value".
+func (s *Schema) coercionWarning(raw interface{}, desc, k string, path cty.Path) diag.Diagnostics {
+	if !s.WarnOnCoercion {
+		return nil
+	}
+
+	from, ok := coercedValueType(raw, s.Type)
+	if !ok {
+		return nil
+	}
+
+	return diag.Diagnostics{
+		{
+			Severity:      diag.Warning,
+			Summary:       "Value converted to a different type",
+			Detail:        fmt.Sprintf("The %s %s for %q was converted to a %s.", from, desc, k, schemaTypeName(s.Type)),
+			AttributePath: path,
+		},
+	}
+}
+
+// coercedValueType returns the name of the type of the raw configuration
+// value if it differs from, and will be converted to, the given primitive
+// ValueType. Whole numbers given for TypeFloat are not considered converted.
+func coercedValueType(raw interface{}, t ValueType) (string, bool) {
+	var from string
+	switch raw.(type) {
+	case bool:
+		from = "bool"
+	case string:
+		from = "string"
+	case int, int32, int64, float32, float64:
+		from = "number"
+	default:
+		return "", false
+	}
+
+	switch t {
+	case TypeBool:
+		return from, from != "bool"
+	case TypeInt, TypeFloat:
+		return from, from != "number"
+	case TypeString:
+		return from, from != "string"
+	}
+
+	return "", false
+}
+
+// schemaTypeName returns the configuration language name of a primitive
+// ValueType, for use in diagnostics.
+func schemaTypeName(t ValueType) string {
+	switch t {
+	case TypeBool:
+		return "bool"
+	case TypeInt, TypeFloat:
+		return "number"
+	case TypeString:
+		return "string"
+	}
+
+	return t.String()
+}
+
 func (m schemaMap) validateType(
 	k string,
 	raw interface{},
@@ -2497,14 +3360,9 @@ func (m schemaMap) validateType(
 	case TypeList:
 		diags = m.validateList(k, raw, schema, c, path)
 	case TypeSet:
-		// indexing into sets is not representable in the current protocol
-		// best we can do is associate the path up to this attribute.
 		diags = m.validateList(k, raw, schema, c, path)
 		if len(diags) > 0 {
-			log.Printf("[WARN] Truncating attribute path of %d diagnostics for TypeSet", len(diags))
-			for i := range diags {
-				diags[i].AttributePath = path
-			}
+			diags = setElementDiagnosticPaths(diags, c, path, schemaMap{k: schema}.hasSensitive())
 		}
 	case TypeMap:
 		diags = m.validateMap(k, raw, schema, c, path)
@@ -2513,10 +3371,15 @@ func (m schemaMap) validateType(
 	}
 
 	if schema.Deprecated != "" {
+		detail := schema.Deprecated
+		if schema.DeprecatedReplacedBy != "" {
+			detail += fmt.Sprintf("\n\nUse %s instead.", schema.DeprecatedReplacedBy)
+		}
+
 		diags = append(diags, diag.Diagnostic{
 			Severity:      diag.Warning,
 			Summary:       "Argument is deprecated",
-			Detail:        schema.Deprecated,
+			Detail:        detail,
 			AttributePath: path,
 		})
 	}
@@ -2524,6 +3387,78 @@ func (m schemaMap) validateType(
 	return diags
 }
 
+// setElementDiagnosticPaths replaces the list index steps which validateList
+// uses for set elements with the set element values, since sets can only be
+// indexed by value. The values are only available if the raw configuration
+// was given, otherwise the best we can do is associate the path up to the set
+// attribute. This is also the case if the set elements contain a sensitive
+// value, which would otherwise be included in the path in plaintext.
+func setElementDiagnosticPaths(diags diag.Diagnostics, c *terraform.ResourceConfig, path cty.Path, sensitive bool) diag.Diagnostics {
+	var elems []cty.Value
+
+	if c != nil && !c.CtyValue.IsNull() && !sensitive {
+		setVal, err := path.Apply(c.CtyValue)
+		if err == nil && !setVal.IsNull() && setVal.IsWhollyKnown() && setVal.Type().IsSetType() {
+			elems = setVal.AsValueSlice()
+		}
+	}
+
+	truncated := 0
+
+	for i := range diags {
+		attrPath := diags[i].AttributePath
+
+		if len(attrPath) > len(path) && attrPath.HasPrefix(path) {
+			if step, ok := attrPath[len(path)].(cty.IndexStep); ok && step.Key.Type() == cty.Number {
+				idx, _ := step.Key.AsBigFloat().Int64()
+
+				if idx >= 0 && idx < int64(len(elems)) {
+					newPath := make(cty.Path, 0, len(attrPath))
+					newPath = append(newPath, path...)
+					newPath = append(newPath, cty.IndexStep{Key: elems[idx]})
+					newPath = append(newPath, attrPath[len(path)+1:]...)
+
+					diags[i].AttributePath = newPath
+
+					continue
+				}
+			}
+		}
+
+		diags[i].AttributePath = path
+		truncated++
+	}
+
+	if truncated > 0 {
+		log.Printf("[WARN] Truncating attribute path of %d diagnostics for TypeSet", truncated)
+	}
+
+	return diags
+}
+
+// hasSensitive returns true if the schemaMap contains any Sensitive attributes,
+// including within nested blocks and collection elements.
+func (m schemaMap) hasSensitive() bool {
+	for _, v := range m {
+		if v.Sensitive {
+			return true
+		}
+
+		switch t := v.Elem.(type) {
+		case *Resource:
+			if schemaMap(t.SchemaMap()).hasSensitive() {
+				return true
+			}
+		case *Schema:
+			if schemaMap(map[string]*Schema{"elem": t}).hasSensitive() {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 // hasWriteOnly returns true if the schemaMap contains any WriteOnly attributes.
 func (m schemaMap) hasWriteOnly() bool {
 	for _, v := range m {
@@ -2534,7 +3469,9 @@ func (m schemaMap) hasWriteOnly() bool {
 		if v.Elem != nil {
 			switch t := v.Elem.(type) {
 			case *Resource:
-				return schemaMap(t.SchemaMap()).hasWriteOnly()
+				if schemaMap(t.SchemaMap()).hasWriteOnly() {
+					return true
+				}
 			case *Schema:
 				if t.WriteOnly {
 					return true
@@ -2554,6 +3491,29 @@ func (m schemaMap) hasWriteOnly() bool {
 	return false
 }
 
+// requiredWithoutDescription returns the sorted, dot separated paths of the
+// Required attributes and blocks, including nested ones, which have neither
+// a Description nor a MarkdownDescription.
+func (m schemaMap) requiredWithoutDescription() []string {
+	var paths []string
+
+	for k, v := range m {
+		if v.Required && v.Description == "" && v.MarkdownDescription == "" {
+			paths = append(paths, k)
+		}
+
+		if t, ok := v.Elem.(*Resource); ok {
+			for _, path := range schemaMap(t.SchemaMap()).requiredWithoutDescription() {
+				paths = append(paths, k+"."+path)
+			}
+		}
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
 // Zero returns the zero value for a type.
 func (t ValueType) Zero() interface{} {
 	switch t {
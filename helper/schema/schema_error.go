@@ -0,0 +1,139 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package schema
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+)
+
+// SchemaErrorReason describes the kind of problem reported by a SchemaError.
+type SchemaErrorReason int
+
+const (
+	// SchemaErrorReasonUnknown is used for problems which do not belong to
+	// any other reason.
+	SchemaErrorReasonUnknown SchemaErrorReason = iota
+
+	// SchemaErrorReasonInvalidProvider is used when the definition of the
+	// provider outside of its attributes is invalid, such as conflicting
+	// configure functions.
+	SchemaErrorReasonInvalidProvider
+
+	// SchemaErrorReasonInvalidAttribute is used when the Schema of an
+	// attribute or block is invalid, such as conflicting Schema fields.
+	SchemaErrorReasonInvalidAttribute
+
+	// SchemaErrorReasonReservedFieldName is used when an attribute name is
+	// reserved for use by the SDK or by Terraform.
+	SchemaErrorReasonReservedFieldName
+
+	// SchemaErrorReasonInvalidResource is used when the definition of a
+	// resource, data source, or ephemeral resource outside of its attributes
+	// is invalid, such as a missing CRUD function.
+	SchemaErrorReasonInvalidResource
+
+	// SchemaErrorReasonInvalidTypeName is used when a resource, data source,
+	// or ephemeral resource type name is invalid.
+	SchemaErrorReasonInvalidTypeName
+
+	// SchemaErrorReasonInvalidFunction is used when the definition of a
+	// provider function is invalid.
+	SchemaErrorReasonInvalidFunction
+)
+
+// SchemaError is a problem with a provider, resource, or attribute schema
+// found by InternalValidate. Use Provider.SchemaErrors to get each of the
+// problems of a provider, or errors.As to get a SchemaError from the error
+// returned by InternalValidate.
+type SchemaError struct {
+	// ResourceType is the type name of the resource, data source, or
+	// ephemeral resource with the problem. It is empty for problems with the
+	// provider itself.
+	ResourceType string
+
+	// AttributePath is the dot separated path of attribute and block names
+	// of the attribute with the problem, such as "block.attr". It is empty
+	// for problems which do not belong to an attribute.
+	AttributePath string
+
+	// Reason is the kind of problem.
+	Reason SchemaErrorReason
+
+	// Err describes the problem.
+	Err error
+}
+
+func (e SchemaError) Error() string {
+	if e.Err == nil {
+		return "invalid schema"
+	}
+
+	return e.Err.Error()
+}
+
+func (e SchemaError) Unwrap() error {
+	return e.Err
+}
+
+// newSchemaError returns err as a SchemaError for the given resource type. If
+// err wraps a SchemaError, its AttributePath and Reason are kept, otherwise
+// the given reason is used.
+func newSchemaError(resourceType string, reason SchemaErrorReason, err error) SchemaError {
+	schemaErr := SchemaError{
+		ResourceType: resourceType,
+		Reason:       reason,
+		Err:          err,
+	}
+
+	var nested SchemaError
+	if errors.As(err, &nested) {
+		schemaErr.AttributePath = nested.AttributePath
+		schemaErr.Reason = nested.Reason
+	}
+
+	return schemaErr
+}
+
+// attributeSchemaError returns err as a SchemaError for the attribute k. If
+// err is already a SchemaError of a nested attribute, k is prepended to its
+// AttributePath.
+func attributeSchemaError(k string, err error) error {
+	if schemaErr, ok := err.(SchemaError); ok && schemaErr.AttributePath != "" {
+		schemaErr.AttributePath = k + "." + schemaErr.AttributePath
+
+		return schemaErr
+	}
+
+	return SchemaError{
+		AttributePath: k,
+		Reason:        SchemaErrorReasonInvalidAttribute,
+		Err:           err,
+	}
+}
+
+// schemaErrorDiagnostics converts schema errors into error diagnostics.
+func schemaErrorDiagnostics(errs []SchemaError) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for _, err := range errs {
+		d := diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Invalid Provider Schema",
+			Detail:   err.Error(),
+		}
+
+		if err.AttributePath != "" {
+			for _, name := range strings.Split(err.AttributePath, ".") {
+				d.AttributePath = d.AttributePath.GetAttr(name)
+			}
+		}
+
+		diags = append(diags, d)
+	}
+
+	return diags
+}
@@ -0,0 +1,265 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/configs/configschema"
+)
+
+// providerSchemaJSON is the JSON representation of a provider and its
+// resource, data source, ephemeral resource and resource identity schemas. It
+// is modelled after the output of the "terraform providers schema -json"
+// command.
+type providerSchemaJSON struct {
+	Provider                 *schemaJSON                    `json:"provider,omitempty"`
+	ResourceSchemas          map[string]*schemaJSON         `json:"resource_schemas,omitempty"`
+	DataSourceSchemas        map[string]*schemaJSON         `json:"data_source_schemas,omitempty"`
+	EphemeralResourceSchemas map[string]*schemaJSON         `json:"ephemeral_resource_schemas,omitempty"`
+	ResourceIdentitySchemas  map[string]*identitySchemaJSON `json:"resource_identity_schemas,omitempty"`
+}
+
+type schemaJSON struct {
+	Version int        `json:"version"`
+	Block   *blockJSON `json:"block"`
+}
+
+type blockJSON struct {
+	Attributes      map[string]*attributeJSON `json:"attributes,omitempty"`
+	BlockTypes      map[string]*blockTypeJSON `json:"block_types,omitempty"`
+	Description     string                    `json:"description,omitempty"`
+	DescriptionKind string                    `json:"description_kind"`
+	Deprecated      bool                      `json:"deprecated,omitempty"`
+}
+
+type attributeJSON struct {
+	AttributeType   json.RawMessage `json:"type"`
+	Description     string          `json:"description,omitempty"`
+	DescriptionKind string          `json:"description_kind"`
+	Deprecated      bool            `json:"deprecated,omitempty"`
+	Required        bool            `json:"required,omitempty"`
+	Optional        bool            `json:"optional,omitempty"`
+	Computed        bool            `json:"computed,omitempty"`
+	Sensitive       bool            `json:"sensitive,omitempty"`
+	WriteOnly       bool            `json:"write_only,omitempty"`
+}
+
+type blockTypeJSON struct {
+	NestingMode string     `json:"nesting_mode"`
+	Block       *blockJSON `json:"block"`
+	MinItems    int        `json:"min_items,omitempty"`
+	MaxItems    int        `json:"max_items,omitempty"`
+}
+
+type identitySchemaJSON struct {
+	Version    int64                             `json:"version"`
+	Attributes map[string]*identityAttributeJSON `json:"attributes,omitempty"`
+}
+
+type identityAttributeJSON struct {
+	IdentityType      json.RawMessage `json:"type"`
+	Description       string          `json:"description,omitempty"`
+	RequiredForImport bool            `json:"required_for_import,omitempty"`
+	OptionalForImport bool            `json:"optional_for_import,omitempty"`
+}
+
+// SchemaJSON returns a JSON representation of the resource schema, in the
+// same format as each resource schema in the output of the
+// "terraform providers schema -json" command. Map keys are sorted, so the
+// output is stable for a given schema.
+func (r *Resource) SchemaJSON() ([]byte, error) {
+	s, err := newSchemaJSON(r.SchemaVersion, r.CoreConfigSchema())
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(s)
+}
+
+// IdentitySchemaJSON returns a JSON representation of the resource identity
+// schema, in the same format as each resource identity schema in the output
+// of the "terraform providers schema -json" command. Map keys are sorted, so
+// the output is stable for a given schema.
+func (r *Resource) IdentitySchemaJSON() ([]byte, error) {
+	s, err := newIdentitySchemaJSON(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(s)
+}
+
+// SchemaJSON returns a JSON representation of the provider schema and all of
+// its resource, data source, ephemeral resource and resource identity
+// schemas, in the same format as the output of the
+// "terraform providers schema -json" command for a single provider. Map keys
+// are sorted, so the output is stable for a given provider.
+func (p *Provider) SchemaJSON() ([]byte, error) {
+	var err error
+
+	s := &providerSchemaJSON{
+		ResourceSchemas:          make(map[string]*schemaJSON, len(p.ResourcesMap)),
+		DataSourceSchemas:        make(map[string]*schemaJSON, len(p.DataSourcesMap)),
+		EphemeralResourceSchemas: make(map[string]*schemaJSON, len(p.EphemeralResourcesMap)),
+	}
+
+	s.Provider, err = newSchemaJSON(0, InternalMap(p.Schema).CoreConfigSchema())
+	if err != nil {
+		return nil, fmt.Errorf("provider: %w", err)
+	}
+
+	for k, r := range p.ResourcesMap {
+		s.ResourceSchemas[k], err = newSchemaJSON(r.SchemaVersion, r.CoreConfigSchema())
+		if err != nil {
+			return nil, fmt.Errorf("resource %s: %w", k, err)
+		}
+
+		if r.Identity == nil {
+			continue
+		}
+
+		if s.ResourceIdentitySchemas == nil {
+			s.ResourceIdentitySchemas = make(map[string]*identitySchemaJSON)
+		}
+
+		s.ResourceIdentitySchemas[k], err = newIdentitySchemaJSON(r)
+		if err != nil {
+			return nil, fmt.Errorf("resource identity %s: %w", k, err)
+		}
+	}
+
+	for k, r := range p.DataSourcesMap {
+		s.DataSourceSchemas[k], err = newSchemaJSON(r.SchemaVersion, r.CoreConfigSchema())
+		if err != nil {
+			return nil, fmt.Errorf("data source %s: %w", k, err)
+		}
+	}
+
+	for k, r := range p.EphemeralResourcesMap {
+		s.EphemeralResourceSchemas[k], err = newSchemaJSON(0, r.CoreConfigSchema())
+		if err != nil {
+			return nil, fmt.Errorf("ephemeral resource %s: %w", k, err)
+		}
+	}
+
+	return json.Marshal(s)
+}
+
+func newSchemaJSON(version int, block *configschema.Block) (*schemaJSON, error) {
+	b, err := newBlockJSON(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schemaJSON{
+		Version: version,
+		Block:   b,
+	}, nil
+}
+
+func newIdentitySchemaJSON(r *Resource) (*identitySchemaJSON, error) {
+	block, err := r.CoreIdentitySchema()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &identitySchemaJSON{
+		Version:    r.Identity.Version,
+		Attributes: make(map[string]*identityAttributeJSON, len(block.Attributes)),
+	}
+
+	for k, attr := range block.Attributes {
+		ty, err := attr.Type.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+
+		s.Attributes[k] = &identityAttributeJSON{
+			IdentityType:      ty,
+			Description:       attr.Description,
+			RequiredForImport: attr.RequiredForImport,
+			OptionalForImport: attr.OptionalForImport,
+		}
+	}
+
+	return s, nil
+}
+
+func newBlockJSON(block *configschema.Block) (*blockJSON, error) {
+	b := &blockJSON{
+		Description:     block.Description,
+		DescriptionKind: stringKindJSON(block.DescriptionKind),
+		Deprecated:      block.Deprecated,
+	}
+
+	if len(block.Attributes) > 0 {
+		b.Attributes = make(map[string]*attributeJSON, len(block.Attributes))
+	}
+
+	for k, attr := range block.Attributes {
+		ty, err := attr.Type.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+
+		b.Attributes[k] = &attributeJSON{
+			AttributeType:   ty,
+			Description:     attr.Description,
+			DescriptionKind: stringKindJSON(attr.DescriptionKind),
+			Deprecated:      attr.Deprecated,
+			Required:        attr.Required,
+			Optional:        attr.Optional,
+			Computed:        attr.Computed,
+			Sensitive:       attr.Sensitive,
+			WriteOnly:       attr.WriteOnly,
+		}
+	}
+
+	if len(block.BlockTypes) > 0 {
+		b.BlockTypes = make(map[string]*blockTypeJSON, len(block.BlockTypes))
+	}
+
+	for k, nested := range block.BlockTypes {
+		nestedBlock, err := newBlockJSON(&nested.Block)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", k, err)
+		}
+
+		b.BlockTypes[k] = &blockTypeJSON{
+			NestingMode: nestingModeJSON(nested.Nesting),
+			Block:       nestedBlock,
+			MinItems:    nested.MinItems,
+			MaxItems:    nested.MaxItems,
+		}
+	}
+
+	return b, nil
+}
+
+func stringKindJSON(kind configschema.StringKind) string {
+	if kind == configschema.StringMarkdown {
+		return "markdown"
+	}
+
+	return "plain"
+}
+
+func nestingModeJSON(mode configschema.NestingMode) string {
+	switch mode {
+	case configschema.NestingSingle:
+		return "single"
+	case configschema.NestingGroup:
+		return "group"
+	case configschema.NestingList:
+		return "list"
+	case configschema.NestingSet:
+		return "set"
+	case configschema.NestingMap:
+		return "map"
+	default:
+		return "invalid"
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// setPlainDescriptions resets the global description settings, which are
+// modified by TestSchemaMapCoreConfigSchema, for the duration of the test.
+// Tests calling this must not be run in parallel.
+func setPlainDescriptions(t *testing.T) {
+	t.Helper()
+
+	descriptionKind, schemaDescriptionBuilder := DescriptionKind, SchemaDescriptionBuilder
+
+	DescriptionKind = StringPlain
+	SchemaDescriptionBuilder = func(s *Schema) string {
+		return s.Description
+	}
+
+	t.Cleanup(func() {
+		DescriptionKind, SchemaDescriptionBuilder = descriptionKind, schemaDescriptionBuilder
+	})
+}
+
+func TestResourceSchemaJSON(t *testing.T) {
+	setPlainDescriptions(t)
+
+	r := &Resource{
+		SchemaVersion: 2,
+		Description:   "A test resource.",
+		Schema: map[string]*Schema{
+			"name": {
+				Type:        TypeString,
+				Required:    true,
+				Description: "The name of the resource.",
+			},
+			"password": {
+				Type:      TypeString,
+				Optional:  true,
+				Sensitive: true,
+				WriteOnly: true,
+			},
+			"tags": {
+				Type:     TypeMap,
+				Optional: true,
+				Computed: true,
+				Elem:     &Schema{Type: TypeString},
+			},
+			"rule": {
+				Type:     TypeSet,
+				Optional: true,
+				MaxItems: 10,
+				Elem: &Resource{
+					Schema: map[string]*Schema{
+						"port": {
+							Type:     TypeInt,
+							Required: true,
+						},
+						"cidr_blocks": {
+							Type:     TypeList,
+							Optional: true,
+							Elem:     &Schema{Type: TypeString},
+						},
+						"target": {
+							Type:     TypeList,
+							Required: true,
+							MinItems: 1,
+							MaxItems: 1,
+							Elem: &Resource{
+								Schema: map[string]*Schema{
+									"address": {
+										Type:       TypeString,
+										Optional:   true,
+										Deprecated: "use something else",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	got, err := r.SchemaJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, got, "", "  "); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected, err := os.ReadFile(filepath.Join("testdata", "resource_schema.json"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if diff := cmp.Diff(string(bytes.TrimSpace(expected)), indented.String()); diff != "" {
+		t.Errorf("unexpected difference: %s", diff)
+	}
+}
+
+func TestProviderSchemaJSON(t *testing.T) {
+	setPlainDescriptions(t)
+
+	p := &Provider{
+		Schema: map[string]*Schema{
+			"region": {
+				Type:     TypeString,
+				Optional: true,
+			},
+		},
+		ResourcesMap: map[string]*Resource{
+			"test_resource": {
+				SchemaVersion: 1,
+				Schema: map[string]*Schema{
+					"name": {
+						Type:     TypeString,
+						Required: true,
+					},
+				},
+				Identity: &ResourceIdentity{
+					Version: 1,
+					SchemaFunc: func() map[string]*Schema {
+						return map[string]*Schema{
+							"name": {
+								Type:              TypeString,
+								RequiredForImport: true,
+							},
+						}
+					},
+				},
+			},
+		},
+		DataSourcesMap: map[string]*Resource{
+			"test_data_source": {
+				Schema: map[string]*Schema{
+					"name": {
+						Type:     TypeString,
+						Computed: true,
+					},
+				},
+			},
+		},
+	}
+
+	got, err := p.SchemaJSON()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := `{"provider":{"version":0,"block":{"attributes":{"region":{"type":"string","description_kind":"plain","optional":true}},"description_kind":"plain"}},` +
+		`"resource_schemas":{"test_resource":{"version":1,"block":{"attributes":{"id":{"type":"string","description_kind":"plain","optional":true,"computed":true},"name":{"type":"string","description_kind":"plain","required":true}},"description_kind":"plain"}}},` +
+		`"data_source_schemas":{"test_data_source":{"version":0,"block":{"attributes":{"id":{"type":"string","description_kind":"plain","optional":true,"computed":true},"name":{"type":"string","description_kind":"plain","computed":true}},"description_kind":"plain"}}},` +
+		`"resource_identity_schemas":{"test_resource":{"version":1,"attributes":{"name":{"type":"string","required_for_import":true}}}}}`
+
+	if diff := cmp.Diff(expected, string(got)); diff != "" {
+		t.Errorf("unexpected difference: %s", diff)
+	}
+}
+
+func TestResourceIdentitySchemaJSON(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		Resource *Resource
+		Want     string
+		Err      string
+	}{
+		"attributes": {
+			&Resource{
+				Identity: &ResourceIdentity{
+					Version: 2,
+					SchemaFunc: func() map[string]*Schema {
+						return map[string]*Schema{
+							"region": {
+								Type:              TypeString,
+								OptionalForImport: true,
+								Description:       "The region of the resource.",
+							},
+							"name": {
+								Type:              TypeString,
+								RequiredForImport: true,
+							},
+							"ports": {
+								Type:              TypeList,
+								Elem:              &Schema{Type: TypeInt},
+								OptionalForImport: true,
+							},
+						}
+					},
+				},
+			},
+			`{"version":2,"attributes":{` +
+				`"name":{"type":"string","required_for_import":true},` +
+				`"ports":{"type":["list","number"],"optional_for_import":true},` +
+				`"region":{"type":"string","description":"The region of the resource.","optional_for_import":true}` +
+				`}}`,
+			"",
+		},
+		"no identity": {
+			&Resource{},
+			"",
+			"resource does not have an identity schema",
+		},
+		"empty identity schema": {
+			&Resource{
+				Identity: &ResourceIdentity{
+					SchemaFunc: func() map[string]*Schema {
+						return map[string]*Schema{}
+					},
+				},
+			},
+			"",
+			"identity schema must have at least one attribute",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := test.Resource.IdentitySchemaJSON()
+			if test.Err != "" {
+				if err == nil || err.Error() != test.Err {
+					t.Fatalf("expected error %q, got %v", test.Err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			if diff := cmp.Diff(test.Want, string(got)); diff != "" {
+				t.Fatalf("unexpected JSON: %s", diff)
+			}
+		})
+	}
+}
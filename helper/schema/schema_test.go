@@ -17,11 +17,13 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-log/tfsdklogtest"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/configs/hcl2shim"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/diagutils"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/helper/hashcode"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/logging"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
 )
 
@@ -2784,6 +2786,89 @@ func TestSchemaMap_Diff(t *testing.T) {
 			Err: false,
 		},
 
+		{
+			Name: "overridden diff reset with a CustomizeDiff function",
+			Schema: map[string]*Schema{
+				"availability_zone": {
+					Type:     TypeString,
+					Optional: true,
+					Computed: true,
+				},
+			},
+
+			State: nil,
+
+			Config: map[string]interface{}{
+				"availability_zone": "foo",
+			},
+
+			CustomizeDiff: func(_ context.Context, d *ResourceDiff, meta interface{}) error {
+				if err := d.SetNew("availability_zone", "bar"); err != nil {
+					return err
+				}
+				if err := d.ResetNew("availability_zone"); err != nil {
+					return err
+				}
+				return nil
+			},
+
+			Diff: &terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"availability_zone": {
+						Old: "",
+						New: "foo",
+					},
+				},
+			},
+
+			Err: false,
+		},
+
+		{
+			Name: "computed diff reset with a CustomizeDiff function",
+			Schema: map[string]*Schema{
+				"ami_id": {
+					Type:     TypeString,
+					Required: true,
+				},
+				"instance_id": {
+					Type:     TypeString,
+					Computed: true,
+				},
+			},
+
+			State: nil,
+
+			Config: map[string]interface{}{
+				"ami_id": "foo",
+			},
+
+			CustomizeDiff: func(_ context.Context, d *ResourceDiff, meta interface{}) error {
+				if err := d.SetNew("instance_id", "bar"); err != nil {
+					return err
+				}
+				if err := d.ResetNew("instance_id"); err != nil {
+					return err
+				}
+				return nil
+			},
+
+			Diff: &terraform.InstanceDiff{
+				Attributes: map[string]*terraform.ResourceAttrDiff{
+					"ami_id": {
+						Old: "",
+						New: "foo",
+					},
+					"instance_id": {
+						Old:         "",
+						NewComputed: true,
+					},
+				},
+			},
+
+			Err: false,
+		},
+
 		{
 			Name: "required field with computed diff added with CustomizeDiff function",
 			Schema: map[string]*Schema{
@@ -3103,6 +3188,37 @@ func TestSchemaMap_Diff(t *testing.T) {
 				},
 			},
 		},
+
+		{
+			Name: "map of objects key containing period",
+			Schema: map[string]*Schema{
+				"endpoints": {
+					Type:       TypeMap,
+					Optional:   true,
+					ConfigMode: SchemaConfigModeAttr,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"address": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+					},
+				},
+			},
+
+			State: nil,
+
+			Config: map[string]interface{}{
+				"endpoints": map[string]interface{}{
+					"example.com": map[string]interface{}{
+						"address": "10.0.0.1",
+					},
+				},
+			},
+
+			Err: true,
+		},
 	}
 
 	for i, tc := range cases {
@@ -3131,207 +3247,510 @@ func TestSchemaMap_InternalValidate(t *testing.T) {
 			false,
 		},
 
-		"Both optional and required": {
+		"UnknownIfChanged": {
 			map[string]*Schema{
 				"foo": {
-					Type:     TypeInt,
+					Type:     TypeString,
 					Optional: true,
-					Required: true,
+				},
+				"computed": {
+					Type:             TypeString,
+					Computed:         true,
+					UnknownIfChanged: []string{"foo"},
 				},
 			},
-			true,
+			false,
 		},
 
-		"No optional and no required": {
+		"DeprecatedReplacedBy": {
 			map[string]*Schema{
-				"foo": {
-					Type: TypeInt,
+				"old": {
+					Type:                 TypeString,
+					Optional:             true,
+					Deprecated:           "old is deprecated.",
+					DeprecatedReplacedBy: "new",
+				},
+				"new": {
+					Type:     TypeString,
+					Optional: true,
 				},
 			},
-			true,
+			false,
 		},
 
-		"Missing Type": {
+		"DeprecatedReplacedBy without Deprecated": {
 			map[string]*Schema{
-				"foo": {
-					Required: true,
+				"old": {
+					Type:                 TypeString,
+					Optional:             true,
+					DeprecatedReplacedBy: "new",
+				},
+				"new": {
+					Type:     TypeString,
+					Optional: true,
 				},
 			},
 			true,
 		},
 
-		"Required but computed": {
+		"DeprecatedReplacedBy unknown attribute": {
 			map[string]*Schema{
-				"foo": {
-					Type:     TypeInt,
-					Required: true,
-					Computed: true,
+				"old": {
+					Type:                 TypeString,
+					Optional:             true,
+					Deprecated:           "old is deprecated.",
+					DeprecatedReplacedBy: "missing",
 				},
 			},
 			true,
 		},
 
-		"Looks good": {
+		"DeprecatedReplacedBy relative nested attribute": {
 			map[string]*Schema{
-				"foo": {
-					Type:     TypeString,
-					Required: true,
+				"block": {
+					Type:     TypeList,
+					Optional: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"old": {
+								Type:                 TypeString,
+								Optional:             true,
+								Deprecated:           "old is deprecated.",
+								DeprecatedReplacedBy: "new",
+							},
+							"new": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+					},
 				},
 			},
 			false,
 		},
 
-		"Computed but has default": {
+		"DefaultContextFunc": {
 			map[string]*Schema{
 				"foo": {
-					Type:     TypeInt,
+					Type:     TypeString,
 					Optional: true,
-					Computed: true,
-					Default:  "foo",
+					DefaultContextFunc: func(context.Context) (interface{}, error) {
+						return "foo", nil
+					},
 				},
 			},
-			true,
+			false,
 		},
 
-		"Required but has default": {
+		"DefaultContextFunc with DefaultFunc": {
 			map[string]*Schema{
 				"foo": {
-					Type:     TypeInt,
+					Type:     TypeString,
 					Optional: true,
-					Required: true,
-					Default:  "foo",
+					DefaultFunc: func() (interface{}, error) {
+						return "foo", nil
+					},
+					DefaultContextFunc: func(context.Context) (interface{}, error) {
+						return "foo", nil
+					},
 				},
 			},
 			true,
 		},
 
-		"List element not set": {
+		"DefaultContextFunc with Default": {
 			map[string]*Schema{
 				"foo": {
-					Type: TypeList,
+					Type:     TypeString,
+					Optional: true,
+					Default:  "foo",
+					DefaultContextFunc: func(context.Context) (interface{}, error) {
+						return "foo", nil
+					},
 				},
 			},
 			true,
 		},
 
-		"List default": {
+		"DefaultContextFunc with Required": {
 			map[string]*Schema{
 				"foo": {
-					Type:    TypeList,
-					Elem:    &Schema{Type: TypeInt},
-					Default: "foo",
+					Type:     TypeString,
+					Required: true,
+					DefaultContextFunc: func(context.Context) (interface{}, error) {
+						return "foo", nil
+					},
 				},
 			},
 			true,
 		},
 
-		"List element computed": {
+		"DefaultContextFunc with TypeList": {
 			map[string]*Schema{
 				"foo": {
 					Type:     TypeList,
 					Optional: true,
-					Elem: &Schema{
-						Type:     TypeInt,
-						Computed: true,
+					Elem:     &Schema{Type: TypeString},
+					DefaultContextFunc: func(context.Context) (interface{}, error) {
+						return []interface{}{"foo"}, nil
 					},
 				},
 			},
 			true,
 		},
 
-		"List element with Set set": {
+		"DefaultContextFunc with TypeMap": {
 			map[string]*Schema{
 				"foo": {
-					Type:     TypeList,
-					Elem:     &Schema{Type: TypeInt},
-					Set:      func(interface{}) int { return 0 },
+					Type:     TypeMap,
 					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+					DefaultContextFunc: func(context.Context) (interface{}, error) {
+						return map[string]interface{}{"foo": "bar"}, nil
+					},
 				},
 			},
 			true,
 		},
 
-		"Set element with no Set set": {
-			map[string]*Schema{
-				"foo": {
-					Type:     TypeSet,
-					Elem:     &Schema{Type: TypeInt},
-					Optional: true,
-				},
-			},
-			false,
-		},
-
-		"Required but computedWhen": {
+		"DefaultContextFunc with computed-only": {
 			map[string]*Schema{
 				"foo": {
-					Type:         TypeInt,
-					Required:     true,
-					ComputedWhen: []string{"foo"},
+					Type:     TypeString,
+					Computed: true,
+					DefaultContextFunc: func(context.Context) (interface{}, error) {
+						return "foo", nil
+					},
 				},
 			},
 			true,
 		},
 
-		"Conflicting attributes cannot be required": {
+		"UnknownIfChanged without Computed": {
 			map[string]*Schema{
-				"blacklist": {
-					Type:     TypeBool,
-					Required: true,
+				"foo": {
+					Type:     TypeString,
+					Optional: true,
 				},
-				"whitelist": {
-					Type:          TypeBool,
-					Optional:      true,
-					ConflictsWith: []string{"blacklist"},
+				"bar": {
+					Type:             TypeString,
+					Optional:         true,
+					UnknownIfChanged: []string{"foo"},
 				},
 			},
 			true,
 		},
 
-		"Attribute with conflicts cannot be required": {
+		"UnknownIfChanged unknown attribute": {
 			map[string]*Schema{
-				"whitelist": {
-					Type:          TypeBool,
-					Required:      true,
-					ConflictsWith: []string{"blacklist"},
+				"computed": {
+					Type:             TypeString,
+					Computed:         true,
+					UnknownIfChanged: []string{"foo"},
 				},
 			},
 			true,
 		},
 
-		"ConflictsWith cannot be used w/ ComputedWhen": {
+		"UnknownIfChanged nested attribute": {
 			map[string]*Schema{
-				"blacklist": {
-					Type:         TypeBool,
-					ComputedWhen: []string{"foor"},
-				},
-				"whitelist": {
-					Type:          TypeBool,
-					Required:      true,
-					ConflictsWith: []string{"blacklist"},
+				"foo": {
+					Type:     TypeString,
+					Optional: true,
 				},
-			},
-			true,
-		},
-
-		"AtLeastOneOf list index syntax with self reference": {
-			map[string]*Schema{
-				"config_block_attr": {
+				"block": {
 					Type:     TypeList,
 					Optional: true,
-					MaxItems: 1,
 					Elem: &Resource{
 						Schema: map[string]*Schema{
-							"nested_attr": {
-								Type:         TypeString,
-								Optional:     true,
-								AtLeastOneOf: []string{"config_block_attr.0.nested_attr"},
+							"computed": {
+								Type:             TypeString,
+								Computed:         true,
+								UnknownIfChanged: []string{"foo"},
 							},
 						},
 					},
 				},
 			},
-			false,
+			true,
+		},
+
+		"PlanModifiers": {
+			map[string]*Schema{
+				"foo": {
+					Type:     TypeString,
+					Optional: true,
+					Computed: true,
+					PlanModifiers: []SchemaPlanModifierFunc{
+						func(ctx context.Context, req AttributePlanModifyRequest, resp *AttributePlanModifyResponse) {},
+					},
+				},
+			},
+			false,
+		},
+
+		"PlanModifiers without Computed": {
+			map[string]*Schema{
+				"foo": {
+					Type:     TypeString,
+					Required: true,
+					PlanModifiers: []SchemaPlanModifierFunc{
+						func(ctx context.Context, req AttributePlanModifyRequest, resp *AttributePlanModifyResponse) {},
+					},
+				},
+			},
+			true,
+		},
+
+		"PlanModifiers nested attribute": {
+			map[string]*Schema{
+				"block": {
+					Type:     TypeList,
+					Optional: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"foo": {
+								Type:     TypeString,
+								Optional: true,
+								Computed: true,
+								PlanModifiers: []SchemaPlanModifierFunc{
+									func(ctx context.Context, req AttributePlanModifyRequest, resp *AttributePlanModifyResponse) {},
+								},
+							},
+						},
+					},
+				},
+			},
+			true,
+		},
+
+		"Both optional and required": {
+			map[string]*Schema{
+				"foo": {
+					Type:     TypeInt,
+					Optional: true,
+					Required: true,
+				},
+			},
+			true,
+		},
+
+		"No optional and no required": {
+			map[string]*Schema{
+				"foo": {
+					Type: TypeInt,
+				},
+			},
+			true,
+		},
+
+		"Missing Type": {
+			map[string]*Schema{
+				"foo": {
+					Required: true,
+				},
+			},
+			true,
+		},
+
+		"List with Default": {
+			map[string]*Schema{
+				"foo": {
+					Type:     TypeList,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+					Default:  []interface{}{"a"},
+				},
+			},
+			true,
+		},
+
+		"Map with Default": {
+			map[string]*Schema{
+				"foo": {
+					Type:     TypeMap,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+					Default: map[string]interface{}{
+						"key": "value",
+					},
+				},
+			},
+			true,
+		},
+
+		"Required but computed": {
+			map[string]*Schema{
+				"foo": {
+					Type:     TypeInt,
+					Required: true,
+					Computed: true,
+				},
+			},
+			true,
+		},
+
+		"Looks good": {
+			map[string]*Schema{
+				"foo": {
+					Type:     TypeString,
+					Required: true,
+				},
+			},
+			false,
+		},
+
+		"Computed but has default": {
+			map[string]*Schema{
+				"foo": {
+					Type:     TypeInt,
+					Optional: true,
+					Computed: true,
+					Default:  "foo",
+				},
+			},
+			true,
+		},
+
+		"Required but has default": {
+			map[string]*Schema{
+				"foo": {
+					Type:     TypeInt,
+					Optional: true,
+					Required: true,
+					Default:  "foo",
+				},
+			},
+			true,
+		},
+
+		"List element not set": {
+			map[string]*Schema{
+				"foo": {
+					Type: TypeList,
+				},
+			},
+			true,
+		},
+
+		"List default": {
+			map[string]*Schema{
+				"foo": {
+					Type:    TypeList,
+					Elem:    &Schema{Type: TypeInt},
+					Default: "foo",
+				},
+			},
+			true,
+		},
+
+		"List element computed": {
+			map[string]*Schema{
+				"foo": {
+					Type:     TypeList,
+					Optional: true,
+					Elem: &Schema{
+						Type:     TypeInt,
+						Computed: true,
+					},
+				},
+			},
+			true,
+		},
+
+		"List element with Set set": {
+			map[string]*Schema{
+				"foo": {
+					Type:     TypeList,
+					Elem:     &Schema{Type: TypeInt},
+					Set:      func(interface{}) int { return 0 },
+					Optional: true,
+				},
+			},
+			true,
+		},
+
+		"Set element with no Set set": {
+			map[string]*Schema{
+				"foo": {
+					Type:     TypeSet,
+					Elem:     &Schema{Type: TypeInt},
+					Optional: true,
+				},
+			},
+			false,
+		},
+
+		"Required but computedWhen": {
+			map[string]*Schema{
+				"foo": {
+					Type:         TypeInt,
+					Required:     true,
+					ComputedWhen: []string{"foo"},
+				},
+			},
+			true,
+		},
+
+		"Conflicting attributes cannot be required": {
+			map[string]*Schema{
+				"blacklist": {
+					Type:     TypeBool,
+					Required: true,
+				},
+				"whitelist": {
+					Type:          TypeBool,
+					Optional:      true,
+					ConflictsWith: []string{"blacklist"},
+				},
+			},
+			true,
+		},
+
+		"Attribute with conflicts cannot be required": {
+			map[string]*Schema{
+				"whitelist": {
+					Type:          TypeBool,
+					Required:      true,
+					ConflictsWith: []string{"blacklist"},
+				},
+			},
+			true,
+		},
+
+		"ConflictsWith cannot be used w/ ComputedWhen": {
+			map[string]*Schema{
+				"blacklist": {
+					Type:         TypeBool,
+					ComputedWhen: []string{"foor"},
+				},
+				"whitelist": {
+					Type:          TypeBool,
+					Required:      true,
+					ConflictsWith: []string{"blacklist"},
+				},
+			},
+			true,
+		},
+
+		"AtLeastOneOf list index syntax with self reference": {
+			map[string]*Schema{
+				"config_block_attr": {
+					Type:     TypeList,
+					Optional: true,
+					MaxItems: 1,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"nested_attr": {
+								Type:         TypeString,
+								Optional:     true,
+								AtLeastOneOf: []string{"config_block_attr.0.nested_attr"},
+							},
+						},
+					},
+				},
+			},
+			false,
 		},
 
 		"AtLeastOneOf list index syntax with list configuration block existing attribute": {
@@ -4666,6 +5085,105 @@ func TestSchemaMap_InternalValidate(t *testing.T) {
 			false,
 		},
 
+		"RequiredWith relative syntax with list configuration block sibling": {
+			map[string]*Schema{
+				"config_block_attr": {
+					Type:     TypeList,
+					Optional: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"nested_attr": {
+								Type:         TypeString,
+								Optional:     true,
+								RequiredWith: []string{"other_nested_attr"},
+							},
+							"other_nested_attr": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+					},
+				},
+			},
+			false,
+		},
+
+		"RequiredWith relative syntax with missing sibling": {
+			map[string]*Schema{
+				"config_block_attr": {
+					Type:     TypeSet,
+					Optional: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"nested_attr": {
+								Type:         TypeString,
+								Optional:     true,
+								RequiredWith: []string{"missing_attr"},
+							},
+						},
+					},
+				},
+			},
+			true,
+		},
+
+		"RequiredWith relative syntax with top level attribute": {
+			map[string]*Schema{
+				"nested_attr": {
+					Type:     TypeString,
+					Optional: true,
+				},
+				"test": {
+					Type:         TypeBool,
+					Optional:     true,
+					RequiredWith: []string{"other_attr"},
+				},
+			},
+			true,
+		},
+
+		"ExactlyOneOf relative syntax with set configuration block sibling": {
+			map[string]*Schema{
+				"config_block_attr": {
+					Type:     TypeSet,
+					Optional: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"nested_attr": {
+								Type:         TypeString,
+								Optional:     true,
+								ExactlyOneOf: []string{"other_nested_attr"},
+							},
+							"other_nested_attr": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+					},
+				},
+			},
+			false,
+		},
+
+		"ConflictsWith relative syntax with missing sibling": {
+			map[string]*Schema{
+				"config_block_attr": {
+					Type:     TypeSet,
+					Optional: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"nested_attr": {
+								Type:          TypeString,
+								Optional:      true,
+								ConflictsWith: []string{"missing_attr"},
+							},
+						},
+					},
+				},
+			},
+			true,
+		},
+
 		"RequiredWith string syntax with self reference": {
 			map[string]*Schema{
 				"test": {
@@ -5037,6 +5555,66 @@ func TestSchemaMap_InternalValidate(t *testing.T) {
 			true,
 		},
 
+		"TypeMap with Elem *Resource and ConfigMode of attribute": {
+			map[string]*Schema{
+				"map": {
+					Type:       TypeMap,
+					Optional:   true,
+					ConfigMode: SchemaConfigModeAttr,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"name": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+					},
+				},
+			},
+			false,
+		},
+
+		"TypeMap with Elem *Resource and ConfigMode of attribute containing block": {
+			map[string]*Schema{
+				"map": {
+					Type:       TypeMap,
+					Optional:   true,
+					ConfigMode: SchemaConfigModeAttr,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"list": {
+								Type:     TypeList,
+								Optional: true,
+								Elem: &Resource{
+									Schema: map[string]*Schema{
+										"name": {
+											Type:     TypeString,
+											Optional: true,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			true, // nested *Resource must also have ConfigMode of attribute
+		},
+
+		"ValidateKeysFunc on a list": {
+			map[string]*Schema{
+				"foo": {
+					Type:     TypeList,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+					ValidateKeysFunc: func(key string) diag.Diagnostics {
+						return nil
+					},
+				},
+			},
+			true,
+		},
+
 		"ValidateFunc and ValidateDiagFunc cannot both be set": {
 			map[string]*Schema{
 				"foo": {
@@ -5086,12 +5664,26 @@ func TestSchemaMap_InternalValidate(t *testing.T) {
 			true,
 		},
 
-		"Attribute with WriteOnly and ForceNew set returns error": {
+		"Attribute with WriteOnly and ForceNew set returns error": {
+			map[string]*Schema{
+				"foo": {
+					Type:      TypeString,
+					ForceNew:  true,
+					Optional:  true,
+					WriteOnly: true,
+				},
+			},
+			true,
+		},
+
+		"Attribute with WriteOnly and ForceNewFunc set returns error": {
 			map[string]*Schema{
 				"foo": {
-					Type:      TypeString,
-					ForceNew:  true,
-					Optional:  true,
+					Type:     TypeString,
+					Optional: true,
+					ForceNewFunc: func(ctx context.Context, oldValue, newValue interface{}) bool {
+						return true
+					},
 					WriteOnly: true,
 				},
 			},
@@ -5256,139 +5848,343 @@ func TestSchemaMap_InternalValidate(t *testing.T) {
 			false,
 		},
 
-		"Map configuration attribute with WriteOnly set returns error": {
+		"Map configuration attribute with WriteOnly set returns error": {
+			map[string]*Schema{
+				"config_block_attr": {
+					Type:      TypeMap,
+					Optional:  true,
+					WriteOnly: true,
+					Elem: &Schema{
+						Type:     TypeString,
+						Optional: true,
+					},
+				},
+			},
+			true,
+		},
+		"Map configuration attribute nested attribute with WriteOnly set returns no errors": {
+			map[string]*Schema{
+				"config_block_attr": {
+					Type:     TypeMap,
+					Optional: true,
+					Elem: &Schema{
+						Type:      TypeString,
+						Optional:  true,
+						WriteOnly: true,
+					},
+				},
+			},
+			false,
+		},
+
+		"Set configuration block with WriteOnly set returns error": {
+			map[string]*Schema{
+				"config_block_attr": {
+					Type:      TypeSet,
+					Optional:  true,
+					WriteOnly: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"nested_attr": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+					},
+				},
+			},
+			true,
+		},
+		"Set configuration block nested attribute with WriteOnly set returns error": {
+			map[string]*Schema{
+				"config_block_attr": {
+					Type:     TypeSet,
+					Optional: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"nested_attr": {
+								Type:      TypeString,
+								Optional:  true,
+								WriteOnly: true,
+							},
+						},
+					},
+				},
+			},
+			true,
+		},
+		"List configuration block with ConfigModeAttr set, sub block nested attribute with WriteOnly set returns no errors": {
+			map[string]*Schema{
+				"block": {
+					Type:       TypeList,
+					ConfigMode: SchemaConfigModeAttr,
+					Optional:   true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"sub": {
+								Type:       TypeList,
+								ConfigMode: SchemaConfigModeAttr,
+								Optional:   true,
+								Elem: &Resource{
+									Schema: map[string]*Schema{
+										"nested_attr": {
+											Type:      TypeString,
+											Optional:  true,
+											WriteOnly: true,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			false,
+		},
+
+		"Set configuration block with ConfigModeAttr set, sub block nested attribute with WriteOnly set returns error": {
+			map[string]*Schema{
+				"block": {
+					Type:       TypeSet,
+					ConfigMode: SchemaConfigModeAttr,
+					Optional:   true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"sub": {
+								Type:       TypeSet,
+								ConfigMode: SchemaConfigModeAttr,
+								Optional:   true,
+								Elem: &Resource{
+									Schema: map[string]*Schema{
+										"nested_attr": {
+											Type:      TypeString,
+											Optional:  true,
+											WriteOnly: true,
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			true,
+		},
+		"List computed block nested attribute with WriteOnly set returns error": {
+			map[string]*Schema{
+				"config_block_attr": {
+					Type:     TypeList,
+					Computed: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"nested_attr": {
+								Type:      TypeString,
+								Optional:  true,
+								WriteOnly: true,
+							},
+						},
+					},
+				},
+			},
+			true,
+		},
+		"Set computed block nested attribute with WriteOnly set returns error": {
+			map[string]*Schema{
+				"config_block_attr": {
+					Type:     TypeSet,
+					Computed: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"nested_attr": {
+								Type:      TypeString,
+								Required:  true,
+								WriteOnly: true,
+							},
+						},
+					},
+				},
+			},
+			true,
+		},
+		"Set attribute with WriteOnly Elem returns error": {
+			map[string]*Schema{
+				"set_attr": {
+					Type:     TypeSet,
+					Optional: true,
+					Elem: &Schema{
+						Type:      TypeString,
+						WriteOnly: true,
+					},
+				},
+			},
+			true,
+		},
+		"Set configuration block with WriteOnly attribute after nested block returns error": {
+			map[string]*Schema{
+				"config_block_attr": {
+					Type:     TypeSet,
+					Optional: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"a_nested_block": {
+								Type:     TypeList,
+								Optional: true,
+								Elem: &Resource{
+									Schema: map[string]*Schema{
+										"nested_attr": {
+											Type:     TypeString,
+											Optional: true,
+										},
+									},
+								},
+							},
+							"b_writeonly_attr": {
+								Type:      TypeString,
+								Optional:  true,
+								WriteOnly: true,
+							},
+						},
+					},
+				},
+			},
+			true,
+		},
+		"OptionalForImport returns error": {
+			map[string]*Schema{
+				"foo": {
+					Type:              TypeInt,
+					OptionalForImport: true,
+					Optional:          true,
+				},
+			},
+			true,
+		},
+		"RequiredForImport returns error": {
+			map[string]*Schema{
+				"foo": {
+					Type:              TypeString,
+					RequiredForImport: true,
+					Required:          true,
+				},
+			},
+			true,
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			err := schemaMap(tc.In).InternalValidate(nil)
+			if err != nil != tc.Err {
+				if tc.Err {
+					t.Fatalf("%q: Expected error did not occur:\n\n%#v", tn, tc.In)
+				}
+				t.Fatalf("%q: Unexpected error occurred: %s\n\n%#v", tn, err, tc.In)
+			}
+		})
+	}
+
+}
+
+func TestSchemaMap_InternalValidate_collectionDefaults(t *testing.T) {
+	cases := map[string]struct {
+		In  map[string]*Schema
+		Err bool
+	}{
+		"List with Default": {
+			map[string]*Schema{
+				"foo": {
+					Type:     TypeList,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+					Default:  []interface{}{"a"},
+				},
+			},
+			false,
+		},
+
+		"List with Default of wrong element type": {
 			map[string]*Schema{
-				"config_block_attr": {
-					Type:      TypeMap,
-					Optional:  true,
-					WriteOnly: true,
-					Elem: &Schema{
-						Type:     TypeString,
-						Optional: true,
-					},
+				"foo": {
+					Type:     TypeList,
+					Optional: true,
+					Elem:     &Schema{Type: TypeBool},
+					Default:  []interface{}{"a"},
 				},
 			},
 			true,
 		},
-		"Map configuration attribute nested attribute with WriteOnly set returns no errors": {
+
+		"List with Default that is not a slice": {
 			map[string]*Schema{
-				"config_block_attr": {
-					Type:     TypeMap,
+				"foo": {
+					Type:     TypeList,
 					Optional: true,
-					Elem: &Schema{
-						Type:      TypeString,
-						Optional:  true,
-						WriteOnly: true,
-					},
+					Elem:     &Schema{Type: TypeString},
+					Default:  "a",
 				},
 			},
-			false,
+			true,
 		},
 
-		"Set configuration block with WriteOnly set returns error": {
+		"List of nested blocks with Default": {
 			map[string]*Schema{
-				"config_block_attr": {
-					Type:      TypeSet,
-					Optional:  true,
-					WriteOnly: true,
+				"foo": {
+					Type:     TypeList,
+					Optional: true,
 					Elem: &Resource{
 						Schema: map[string]*Schema{
-							"nested_attr": {
+							"bar": {
 								Type:     TypeString,
 								Optional: true,
 							},
 						},
 					},
+					Default: []interface{}{},
 				},
 			},
 			true,
 		},
-		"Set configuration block nested attribute with WriteOnly set returns error": {
+
+		"Map with Default of wrong element type": {
 			map[string]*Schema{
-				"config_block_attr": {
-					Type:     TypeSet,
+				"foo": {
+					Type:     TypeMap,
 					Optional: true,
-					Elem: &Resource{
-						Schema: map[string]*Schema{
-							"nested_attr": {
-								Type:      TypeString,
-								Optional:  true,
-								WriteOnly: true,
-							},
-						},
+					Elem:     &Schema{Type: TypeBool},
+					Default: map[string]interface{}{
+						"key": "value",
 					},
 				},
 			},
 			true,
 		},
-		"List configuration block with ConfigModeAttr set, sub block nested attribute with WriteOnly set returns no errors": {
+
+		"Map with Default": {
 			map[string]*Schema{
-				"block": {
-					Type:       TypeList,
-					ConfigMode: SchemaConfigModeAttr,
-					Optional:   true,
-					Elem: &Resource{
-						Schema: map[string]*Schema{
-							"sub": {
-								Type:       TypeList,
-								ConfigMode: SchemaConfigModeAttr,
-								Optional:   true,
-								Elem: &Resource{
-									Schema: map[string]*Schema{
-										"nested_attr": {
-											Type:      TypeString,
-											Optional:  true,
-											WriteOnly: true,
-										},
-									},
-								},
-							},
-						},
+				"foo": {
+					Type:     TypeMap,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+					Default: map[string]interface{}{
+						"key": "value",
 					},
 				},
 			},
 			false,
 		},
 
-		"Set configuration block with ConfigModeAttr set, sub block nested attribute with WriteOnly set returns error": {
-			map[string]*Schema{
-				"block": {
-					Type:       TypeSet,
-					ConfigMode: SchemaConfigModeAttr,
-					Optional:   true,
-					Elem: &Resource{
-						Schema: map[string]*Schema{
-							"sub": {
-								Type:       TypeSet,
-								ConfigMode: SchemaConfigModeAttr,
-								Optional:   true,
-								Elem: &Resource{
-									Schema: map[string]*Schema{
-										"nested_attr": {
-											Type:      TypeString,
-											Optional:  true,
-											WriteOnly: true,
-										},
-									},
-								},
-							},
-						},
-					},
-				},
-			},
-			true,
-		},
-		"List computed block nested attribute with WriteOnly set returns error": {
+		"Nested List with Default": {
 			map[string]*Schema{
-				"config_block_attr": {
+				"foo": {
 					Type:     TypeList,
-					Computed: true,
+					Optional: true,
 					Elem: &Resource{
 						Schema: map[string]*Schema{
-							"nested_attr": {
-								Type:      TypeString,
-								Optional:  true,
-								WriteOnly: true,
+							"bar": {
+								Type:     TypeList,
+								Optional: true,
+								Elem:     &Schema{Type: TypeString},
+								Default:  []interface{}{"a"},
 							},
 						},
 					},
@@ -5396,17 +6192,21 @@ func TestSchemaMap_InternalValidate(t *testing.T) {
 			},
 			true,
 		},
-		"Set computed block nested attribute with WriteOnly set returns error": {
+
+		"Nested Map with Default": {
 			map[string]*Schema{
-				"config_block_attr": {
-					Type:     TypeSet,
-					Computed: true,
+				"foo": {
+					Type:     TypeList,
+					Optional: true,
 					Elem: &Resource{
 						Schema: map[string]*Schema{
-							"nested_attr": {
-								Type:      TypeString,
-								Required:  true,
-								WriteOnly: true,
+							"bar": {
+								Type:     TypeMap,
+								Optional: true,
+								Elem:     &Schema{Type: TypeString},
+								Default: map[string]interface{}{
+									"key": "value",
+								},
 							},
 						},
 					},
@@ -5414,31 +6214,11 @@ func TestSchemaMap_InternalValidate(t *testing.T) {
 			},
 			true,
 		},
-		"OptionalForImport returns error": {
-			map[string]*Schema{
-				"foo": {
-					Type:              TypeInt,
-					OptionalForImport: true,
-					Optional:          true,
-				},
-			},
-			true,
-		},
-		"RequiredForImport returns error": {
-			map[string]*Schema{
-				"foo": {
-					Type:              TypeString,
-					RequiredForImport: true,
-					Required:          true,
-				},
-			},
-			true,
-		},
 	}
 
 	for tn, tc := range cases {
 		t.Run(tn, func(t *testing.T) {
-			err := schemaMap(tc.In).InternalValidate(nil)
+			err := schemaMap(tc.In).internalValidate(nil, false, true)
 			if err != nil != tc.Err {
 				if tc.Err {
 					t.Fatalf("%q: Expected error did not occur:\n\n%#v", tn, tc.In)
@@ -5447,7 +6227,6 @@ func TestSchemaMap_InternalValidate(t *testing.T) {
 			}
 		})
 	}
-
 }
 
 func TestSchemaMap_DiffSuppress(t *testing.T) {
@@ -5722,6 +6501,175 @@ func TestSchemaMap_DiffSuppress(t *testing.T) {
 	}
 }
 
+func TestSchemaMap_Diff_defaultContextFunc(t *testing.T) {
+	t.Parallel()
+
+	type ctxKey struct{}
+
+	schema := schemaMap{
+		"region": {
+			Type:     TypeString,
+			Optional: true,
+			DefaultContextFunc: func(ctx context.Context) (interface{}, error) {
+				region, ok := ctx.Value(ctxKey{}).(string)
+				if !ok {
+					return nil, errors.New("missing context value")
+				}
+				return region, nil
+			},
+		},
+		"block": {
+			Type:     TypeList,
+			Optional: true,
+			Elem: &Resource{
+				Schema: map[string]*Schema{
+					"zone": {
+						Type:     TypeString,
+						Optional: true,
+						DefaultContextFunc: func(ctx context.Context) (interface{}, error) {
+							return ctx.Value(ctxKey{}).(string) + "a", nil
+						},
+					},
+				},
+			},
+		},
+	}
+
+	c := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"block": []interface{}{
+			map[string]interface{}{},
+		},
+	})
+
+	ctx := context.WithValue(context.Background(), ctxKey{}, "us-east-1")
+
+	d, err := schema.Diff(ctx, nil, c, nil, nil, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := map[string]string{
+		"block.#":      "1",
+		"block.0.zone": "us-east-1a",
+		"region":       "us-east-1",
+	}
+
+	got := make(map[string]string, len(d.Attributes))
+	for k, attr := range d.Attributes {
+		got[k] = attr.New
+	}
+
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Errorf("unexpected difference: %s", diff)
+	}
+}
+
+func TestSchemaMap_Diff_logging(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	ctx := tfsdklogtest.RootLogger(context.Background(), &output)
+	ctx = logging.InitContext(ctx)
+
+	schema := schemaMap{
+		"name": {
+			Type:     TypeString,
+			Optional: true,
+		},
+		"blob": {
+			Type:     TypeString,
+			Optional: true,
+			NoLog:    true,
+		},
+		"password": {
+			Type:      TypeString,
+			Optional:  true,
+			Sensitive: true,
+		},
+		"token": {
+			Type:      TypeString,
+			Optional:  true,
+			WriteOnly: true,
+		},
+		"settings": {
+			Type:     TypeList,
+			Optional: true,
+			NoLog:    true,
+			Elem: &Resource{
+				Schema: map[string]*Schema{
+					"value": {
+						Type:     TypeString,
+						Optional: true,
+					},
+				},
+			},
+		},
+	}
+
+	c := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"name":     "example",
+		"blob":     "large-value",
+		"password": "secret",
+		"token":    "write-only-secret",
+		"settings": []interface{}{
+			map[string]interface{}{
+				"value": "nested-value",
+			},
+		},
+	})
+
+	_, err := schema.Diff(ctx, nil, c, nil, nil, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	entries, err := tfsdklogtest.MultilineJSONDecode(&output)
+	if err != nil {
+		t.Fatalf("unable to read multiple line JSON: %s", err)
+	}
+
+	var actual []map[string]interface{}
+	for _, entry := range entries {
+		if entry["@message"] != "Planned attribute change" {
+			continue
+		}
+
+		delete(entry, "@caller")
+		delete(entry, "@level")
+		delete(entry, "@message")
+		delete(entry, "@module")
+		actual = append(actual, entry)
+	}
+
+	expected := []map[string]interface{}{
+		{
+			logging.KeyAttributePath: "blob",
+		},
+		{
+			logging.KeyAttributePath:     "name",
+			logging.KeyAttributeOldValue: "",
+			logging.KeyAttributeNewValue: "example",
+		},
+		{
+			logging.KeyAttributePath: "password",
+		},
+		{
+			logging.KeyAttributePath: "settings.#",
+		},
+		{
+			logging.KeyAttributePath: "settings.0.value",
+		},
+		{
+			logging.KeyAttributePath: "token",
+		},
+	}
+
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("unexpected difference: %s", diff)
+	}
+}
+
 func TestSchema_DiffSuppressOnRefresh(t *testing.T) {
 	cases := map[string]struct {
 		Schema     schemaMap
@@ -6538,25 +7486,64 @@ func TestSchemaMap_Validate(t *testing.T) {
 
 		"Bad, should not allow maps to be assigned to string attributes": {
 			Schema: map[string]*Schema{
-				"availability_zone": {
-					Type:     TypeString,
-					Required: true,
+				"availability_zone": {
+					Type:     TypeString,
+					Required: true,
+				},
+			},
+
+			Config: map[string]interface{}{
+				"availability_zone": map[string]interface{}{"foo": "bar", "baz": "thing"},
+			},
+
+			Err: true,
+		},
+
+		"Deprecated attribute usage generates warning, but not error": {
+			Schema: map[string]*Schema{
+				"old_news": {
+					Type:       TypeString,
+					Optional:   true,
+					Deprecated: "please use 'new_news' instead",
+				},
+			},
+
+			Config: map[string]interface{}{
+				"old_news": "extra extra!",
+			},
+
+			Err: false,
+
+			Warnings: []string{
+				"Warning: Argument is deprecated: please use 'new_news' instead",
+			},
+		},
+
+		"Deprecated generates no warnings if attr not used": {
+			Schema: map[string]*Schema{
+				"old_news": {
+					Type:       TypeString,
+					Optional:   true,
+					Deprecated: "please use 'new_news' instead",
 				},
 			},
 
-			Config: map[string]interface{}{
-				"availability_zone": map[string]interface{}{"foo": "bar", "baz": "thing"},
-			},
+			Err: false,
 
-			Err: true,
+			Warnings: nil,
 		},
 
-		"Deprecated attribute usage generates warning, but not error": {
+		"Deprecated with replacement generates warning with replacement": {
 			Schema: map[string]*Schema{
 				"old_news": {
-					Type:       TypeString,
-					Optional:   true,
-					Deprecated: "please use 'new_news' instead",
+					Type:                 TypeString,
+					Optional:             true,
+					Deprecated:           "old_news is deprecated.",
+					DeprecatedReplacedBy: "new_news",
+				},
+				"new_news": {
+					Type:     TypeString,
+					Optional: true,
 				},
 			},
 
@@ -6567,19 +7554,28 @@ func TestSchemaMap_Validate(t *testing.T) {
 			Err: false,
 
 			Warnings: []string{
-				"Warning: Argument is deprecated: please use 'new_news' instead",
+				"Warning: Argument is deprecated: old_news is deprecated.\n\nUse new_news instead.",
 			},
 		},
 
-		"Deprecated generates no warnings if attr not used": {
+		"Deprecated with replacement generates no warnings if attr not used": {
 			Schema: map[string]*Schema{
 				"old_news": {
-					Type:       TypeString,
-					Optional:   true,
-					Deprecated: "please use 'new_news' instead",
+					Type:                 TypeString,
+					Optional:             true,
+					Deprecated:           "old_news is deprecated.",
+					DeprecatedReplacedBy: "new_news",
+				},
+				"new_news": {
+					Type:     TypeString,
+					Optional: true,
 				},
 			},
 
+			Config: map[string]interface{}{
+				"new_news": "extra extra!",
+			},
+
 			Err: false,
 
 			Warnings: nil,
@@ -7148,6 +8144,264 @@ func TestSchemaMap_Validate(t *testing.T) {
 				},
 			},
 		},
+
+		"WarnOnCoercion number given for string": {
+			Schema: map[string]*Schema{
+				"string_field": {
+					Type:           TypeString,
+					Optional:       true,
+					WarnOnCoercion: true,
+				},
+			},
+
+			Config: map[string]interface{}{
+				"string_field": 3,
+			},
+
+			Warnings: []string{
+				"Warning: Value converted to a different type: The number value given for \"string_field\" was converted to a string.",
+			},
+		},
+
+		"WarnOnCoercion string given for bool": {
+			Schema: map[string]*Schema{
+				"bool_field": {
+					Type:           TypeBool,
+					Optional:       true,
+					WarnOnCoercion: true,
+				},
+			},
+
+			Config: map[string]interface{}{
+				"bool_field": "true",
+			},
+
+			Warnings: []string{
+				"Warning: Value converted to a different type: The string value given for \"bool_field\" was converted to a bool.",
+			},
+		},
+
+		"WarnOnCoercion whole number given for float": {
+			Schema: map[string]*Schema{
+				"float_field": {
+					Type:           TypeFloat,
+					Optional:       true,
+					WarnOnCoercion: true,
+				},
+			},
+
+			Config: map[string]interface{}{
+				"float_field": 3,
+			},
+		},
+
+		"WarnOnCoercion matching type": {
+			Schema: map[string]*Schema{
+				"string_field": {
+					Type:           TypeString,
+					Optional:       true,
+					WarnOnCoercion: true,
+				},
+			},
+
+			Config: map[string]interface{}{
+				"string_field": "3",
+			},
+		},
+
+		"ValidateKeysFunc valid keys": {
+			Schema: map[string]*Schema{
+				"tags": {
+					Type:     TypeMap,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+					ValidateKeysFunc: func(key string) diag.Diagnostics {
+						if strings.HasPrefix(key, "aws:") {
+							return diag.Errorf("tag key %q must not start with aws:", key)
+						}
+
+						return nil
+					},
+				},
+			},
+
+			Config: map[string]interface{}{
+				"tags": map[string]interface{}{
+					"Name": "test",
+					"Env":  "prod",
+				},
+			},
+		},
+
+		"ValidateKeysFunc invalid key": {
+			Schema: map[string]*Schema{
+				"tags": {
+					Type:     TypeMap,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+					ValidateKeysFunc: func(key string) diag.Diagnostics {
+						if strings.HasPrefix(key, "aws:") {
+							return diag.Errorf("tag key %q must not start with aws:", key)
+						}
+
+						return nil
+					},
+				},
+			},
+
+			Config: map[string]interface{}{
+				"tags": map[string]interface{}{
+					"Name":         "test",
+					"aws:internal": "true",
+				},
+			},
+
+			Err: true,
+			Errors: []error{
+				fmt.Errorf("Error: tag key \"aws:internal\" must not start with aws:"),
+			},
+		},
+
+		"ValidateKeysFunc unknown map": {
+			Schema: map[string]*Schema{
+				"tags": {
+					Type:     TypeMap,
+					Optional: true,
+					Elem:     &Schema{Type: TypeString},
+					ValidateKeysFunc: func(key string) diag.Diagnostics {
+						return diag.Errorf("ValidateKeysFunc called for unknown map")
+					},
+				},
+			},
+
+			Config: map[string]interface{}{
+				"tags": hcl2shim.UnknownVariableValue,
+			},
+		},
+
+		"map of objects key containing period": {
+			Schema: map[string]*Schema{
+				"endpoints": {
+					Type:       TypeMap,
+					Optional:   true,
+					ConfigMode: SchemaConfigModeAttr,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"address": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+					},
+				},
+			},
+
+			Config: map[string]interface{}{
+				"endpoints": map[string]interface{}{
+					"primary": map[string]interface{}{
+						"address": "10.0.0.1",
+					},
+					"example.com": map[string]interface{}{
+						"address": "10.0.0.2",
+					},
+				},
+			},
+
+			Err: true,
+			Errors: []error{
+				fmt.Errorf(`Error: Invalid map key: Map key "example.com" must not contain periods.`),
+			},
+		},
+
+		"map of objects": {
+			Schema: map[string]*Schema{
+				"endpoints": {
+					Type:       TypeMap,
+					Optional:   true,
+					ConfigMode: SchemaConfigModeAttr,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"address": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+					},
+				},
+			},
+
+			Config: map[string]interface{}{
+				"endpoints": map[string]interface{}{
+					"primary": map[string]interface{}{
+						"address": "10.0.0.1",
+					},
+				},
+			},
+		},
+
+		"WarnOnCoercion number default for string": {
+			Schema: map[string]*Schema{
+				"string_field": {
+					Type:           TypeString,
+					Optional:       true,
+					Default:        8080,
+					WarnOnCoercion: true,
+				},
+			},
+
+			Config: map[string]interface{}{},
+
+			Warnings: []string{
+				"Warning: Value converted to a different type: The number default value for \"string_field\" was converted to a string.",
+			},
+		},
+
+		"WarnOnCoercion default with configured value": {
+			Schema: map[string]*Schema{
+				"string_field": {
+					Type:           TypeString,
+					Optional:       true,
+					Default:        8080,
+					WarnOnCoercion: true,
+				},
+			},
+
+			Config: map[string]interface{}{
+				"string_field": "80",
+			},
+		},
+
+		"WarnOnCoercion string DefaultFunc for int": {
+			Schema: map[string]*Schema{
+				"int_field": {
+					Type:     TypeInt,
+					Optional: true,
+					DefaultFunc: func() (interface{}, error) {
+						return "8080", nil
+					},
+					WarnOnCoercion: true,
+				},
+			},
+
+			Config: map[string]interface{}{},
+
+			Warnings: []string{
+				"Warning: Value converted to a different type: The string value given for \"int_field\" was converted to a number.",
+			},
+		},
+
+		"coercion without WarnOnCoercion": {
+			Schema: map[string]*Schema{
+				"string_field": {
+					Type:     TypeString,
+					Optional: true,
+				},
+			},
+
+			Config: map[string]interface{}{
+				"string_field": 3,
+			},
+		},
 	}
 
 	for tn, tc := range cases {
@@ -7442,28 +8696,114 @@ func TestSchemaSet_ValidateMinItems(t *testing.T) {
 			}
 		}
 	}
-}
-
-// errorSort implements sort.Interface to sort errors by their error message
-type errorSort []error
+}
+
+// errorSort implements sort.Interface to sort errors by their error message
+type errorSort []error
+
+func (e errorSort) Len() int      { return len(e) }
+func (e errorSort) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e errorSort) Less(i, j int) bool {
+	return e[i].Error() < e[j].Error()
+}
+
+func TestSchemaMapDeepCopy(t *testing.T) {
+	schema := map[string]*Schema{
+		"foo": {
+			Type: TypeString,
+		},
+	}
+	source := schemaMap(schema)
+	dest := source.DeepCopy()
+	dest["foo"].ForceNew = true
+	if reflect.DeepEqual(source, dest) {
+		t.Fatalf("source and dest should not match")
+	}
+}
+
+func TestSchemaMap_Validate_validateKeysFuncPath(t *testing.T) {
+	sm := schemaMap{
+		"tags": {
+			Type:     TypeMap,
+			Optional: true,
+			Elem:     &Schema{Type: TypeString},
+			ValidateKeysFunc: func(key string) diag.Diagnostics {
+				return diag.Diagnostics{
+					{
+						Severity: diag.Warning,
+						Summary:  "key " + key,
+					},
+				}
+			},
+		},
+	}
+
+	c := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"tags": map[string]interface{}{
+			"b": "2",
+			"a": "1",
+		},
+	})
+
+	expected := diag.Diagnostics{
+		{
+			Severity:      diag.Warning,
+			Summary:       "key a",
+			AttributePath: cty.GetAttrPath("tags").IndexString("a"),
+		},
+		{
+			Severity:      diag.Warning,
+			Summary:       "key b",
+			AttributePath: cty.GetAttrPath("tags").IndexString("b"),
+		},
+	}
+
+	if diff := cmp.Diff(expected, sm.Validate(c), cmp.Comparer(func(a, b cty.Path) bool { return a.Equals(b) })); diff != "" {
+		t.Fatalf("unexpected diagnostics difference: %s", diff)
+	}
+}
+
+func TestSchemaMap_Validate_deprecatedReplacedByPath(t *testing.T) {
+	sm := schemaMap{
+		"block": {
+			Type:     TypeList,
+			Optional: true,
+			Elem: &Resource{
+				Schema: map[string]*Schema{
+					"old": {
+						Type:                 TypeString,
+						Optional:             true,
+						Deprecated:           "old is deprecated.",
+						DeprecatedReplacedBy: "new",
+					},
+					"new": {
+						Type:     TypeString,
+						Optional: true,
+					},
+				},
+			},
+		},
+	}
 
-func (e errorSort) Len() int      { return len(e) }
-func (e errorSort) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
-func (e errorSort) Less(i, j int) bool {
-	return e[i].Error() < e[j].Error()
-}
+	c := terraform.NewResourceConfigRaw(map[string]interface{}{
+		"block": []interface{}{
+			map[string]interface{}{
+				"old": "value",
+			},
+		},
+	})
 
-func TestSchemaMapDeepCopy(t *testing.T) {
-	schema := map[string]*Schema{
-		"foo": {
-			Type: TypeString,
+	expected := diag.Diagnostics{
+		{
+			Severity:      diag.Warning,
+			Summary:       "Argument is deprecated",
+			Detail:        "old is deprecated.\n\nUse new instead.",
+			AttributePath: cty.GetAttrPath("block").IndexInt(0).GetAttr("old"),
 		},
 	}
-	source := schemaMap(schema)
-	dest := source.DeepCopy()
-	dest["foo"].ForceNew = true
-	if reflect.DeepEqual(source, dest) {
-		t.Fatalf("source and dest should not match")
+
+	if diff := cmp.Diff(expected, sm.Validate(c), cmp.Comparer(func(a, b cty.Path) bool { return a.Equals(b) })); diff != "" {
+		t.Fatalf("unexpected diagnostics difference: %s", diff)
 	}
 }
 
@@ -7849,7 +9189,7 @@ func TestValidateConflictingAttributes(t *testing.T) {
 		t.Run(tn, func(t *testing.T) {
 			c := terraform.NewResourceConfigRaw(tc.Config)
 
-			err := validateConflictingAttributes(tc.Key, tc.Schema, c)
+			err := validateConflictingAttributes(tc.Key, tc.Schema, nil, c)
 			if err == nil && tc.Err {
 				t.Fatalf("expected error")
 			}
@@ -8653,7 +9993,7 @@ func TestValidateExactlyOneOfAttributes(t *testing.T) {
 		t.Run(tn, func(t *testing.T) {
 			c := terraform.NewResourceConfigRaw(tc.Config)
 
-			err := validateExactlyOneAttribute(tc.Key, tc.Schema[tc.Key], c)
+			err := validateExactlyOneAttribute(tc.Key, tc.Schema[tc.Key], schemaMap(tc.Schema), c)
 			if err == nil && tc.Err {
 				t.Fatalf("expected error")
 			}
@@ -8666,6 +10006,156 @@ func TestValidateExactlyOneOfAttributes(t *testing.T) {
 
 }
 
+func TestValidateNestedBlockConstraintAttributes(t *testing.T) {
+	ruleSchema := func(blockType ValueType, port *Schema) map[string]*Schema {
+		return map[string]*Schema{
+			"rule": {
+				Type:     blockType,
+				Optional: true,
+				Elem: &Resource{
+					Schema: map[string]*Schema{
+						"port": port,
+						"port_range": {
+							Type:     TypeString,
+							Optional: true,
+						},
+					},
+				},
+			},
+		}
+	}
+
+	exactlyOneOf := &Schema{
+		Type:         TypeInt,
+		Optional:     true,
+		ExactlyOneOf: []string{"port_range"},
+	}
+	conflictsWith := &Schema{
+		Type:          TypeInt,
+		Optional:      true,
+		ConflictsWith: []string{"port_range"},
+	}
+
+	cases := map[string]struct {
+		Schema map[string]*Schema
+		Config map[string]interface{}
+		Err    bool
+	}{
+		"ExactlyOneOf set block one specified in each element": {
+			Schema: ruleSchema(TypeSet, exactlyOneOf),
+			Config: map[string]interface{}{
+				"rule": []interface{}{
+					map[string]interface{}{
+						"port": 80,
+					},
+					map[string]interface{}{
+						"port_range": "8000-8080",
+					},
+				},
+			},
+			Err: false,
+		},
+
+		"ExactlyOneOf set block both specified": {
+			Schema: ruleSchema(TypeSet, exactlyOneOf),
+			Config: map[string]interface{}{
+				"rule": []interface{}{
+					map[string]interface{}{
+						"port": 80,
+					},
+					map[string]interface{}{
+						"port":       443,
+						"port_range": "8000-8080",
+					},
+				},
+			},
+			Err: true,
+		},
+
+		"ExactlyOneOf set block none specified": {
+			Schema: ruleSchema(TypeSet, exactlyOneOf),
+			Config: map[string]interface{}{
+				"rule": []interface{}{
+					map[string]interface{}{},
+				},
+			},
+			Err: true,
+		},
+
+		"ExactlyOneOf list block both specified": {
+			Schema: ruleSchema(TypeList, exactlyOneOf),
+			Config: map[string]interface{}{
+				"rule": []interface{}{
+					map[string]interface{}{
+						"port":       443,
+						"port_range": "8000-8080",
+					},
+				},
+			},
+			Err: true,
+		},
+
+		"ExactlyOneOf set block unknown sibling": {
+			Schema: ruleSchema(TypeSet, exactlyOneOf),
+			Config: map[string]interface{}{
+				"rule": []interface{}{
+					map[string]interface{}{
+						"port_range": hcl2shim.UnknownVariableValue,
+					},
+				},
+			},
+			Err: false,
+		},
+
+		"ConflictsWith set block specified in different elements": {
+			Schema: ruleSchema(TypeSet, conflictsWith),
+			Config: map[string]interface{}{
+				"rule": []interface{}{
+					map[string]interface{}{
+						"port": 80,
+					},
+					map[string]interface{}{
+						"port_range": "8000-8080",
+					},
+				},
+			},
+			Err: false,
+		},
+
+		"ConflictsWith set block specified in same element": {
+			Schema: ruleSchema(TypeSet, conflictsWith),
+			Config: map[string]interface{}{
+				"rule": []interface{}{
+					map[string]interface{}{
+						"port":       443,
+						"port_range": "8000-8080",
+					},
+				},
+			},
+			Err: true,
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			c := terraform.NewResourceConfigRaw(tc.Config)
+			diags := schemaMap(tc.Schema).Validate(c)
+			es := diagutils.ErrorDiags(diags).Errors()
+			if len(es) > 0 != tc.Err {
+				if len(es) == 0 {
+					t.Fatalf("expected error")
+				}
+
+				for _, e := range es {
+					t.Fatalf("didn't expect error, got error: %+v", e)
+				}
+
+				t.FailNow()
+			}
+		})
+	}
+}
+
 func TestValidateAtLeastOneOfAttributes(t *testing.T) {
 	cases := map[string]struct {
 		Key    string
@@ -9184,6 +10674,110 @@ func TestValidateRequiredWithAttributes(t *testing.T) {
 
 			Err: true,
 		},
+
+		"nested list block sibling specified": {
+			Key: "rule",
+			Schema: map[string]*Schema{
+				"rule": {
+					Type:     TypeList,
+					Optional: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"port": {
+								Type:         TypeInt,
+								Optional:     true,
+								RequiredWith: []string{"protocol"},
+							},
+							"protocol": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+					},
+				},
+			},
+
+			Config: map[string]interface{}{
+				"rule": []interface{}{
+					map[string]interface{}{
+						"port":     80,
+						"protocol": "tcp",
+					},
+					map[string]interface{}{
+						"protocol": "udp",
+					},
+				},
+			},
+			Err: false,
+		},
+
+		"nested list block sibling missing": {
+			Key: "rule",
+			Schema: map[string]*Schema{
+				"rule": {
+					Type:     TypeList,
+					Optional: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"port": {
+								Type:         TypeInt,
+								Optional:     true,
+								RequiredWith: []string{"protocol"},
+							},
+							"protocol": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+					},
+				},
+			},
+
+			Config: map[string]interface{}{
+				"rule": []interface{}{
+					map[string]interface{}{
+						"port":     80,
+						"protocol": "tcp",
+					},
+					map[string]interface{}{
+						"port": 53,
+					},
+				},
+			},
+			Err: true,
+		},
+
+		"nested set block sibling missing": {
+			Key: "rule",
+			Schema: map[string]*Schema{
+				"rule": {
+					Type:     TypeSet,
+					Optional: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"port": {
+								Type:         TypeInt,
+								Optional:     true,
+								RequiredWith: []string{"protocol"},
+							},
+							"protocol": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+					},
+				},
+			},
+
+			Config: map[string]interface{}{
+				"rule": []interface{}{
+					map[string]interface{}{
+						"port": 53,
+					},
+				},
+			},
+			Err: true,
+		},
 	}
 
 	for tn, tc := range cases {
@@ -9206,6 +10800,116 @@ func TestValidateRequiredWithAttributes(t *testing.T) {
 	}
 }
 
+func TestValidateRequiredWithAttributes_unknownConfig(t *testing.T) {
+	sm := schemaMap{
+		"port": {
+			Type:         TypeInt,
+			Optional:     true,
+			RequiredWith: []string{"protocol", "settings.0.mode"},
+		},
+		"protocol": {
+			Type:     TypeString,
+			Optional: true,
+		},
+		"settings": {
+			Type:     TypeList,
+			Optional: true,
+			MaxItems: 1,
+			Elem: &Resource{
+				Schema: map[string]*Schema{
+					"mode": {
+						Type:     TypeString,
+						Optional: true,
+					},
+				},
+			},
+		},
+	}
+	block := InternalMap(sm).CoreConfigSchema()
+	settingsType := block.ImpliedType().AttributeType("settings")
+
+	cases := map[string]struct {
+		Config cty.Value
+		Err    bool
+	}{
+		"all known": {
+			Config: cty.ObjectVal(map[string]cty.Value{
+				"port":     cty.NumberIntVal(80),
+				"protocol": cty.StringVal("tcp"),
+				"settings": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"mode": cty.StringVal("strict"),
+					}),
+				}),
+			}),
+			Err: false,
+		},
+
+		"unknown attribute": {
+			Config: cty.ObjectVal(map[string]cty.Value{
+				"port":     cty.NumberIntVal(80),
+				"protocol": cty.UnknownVal(cty.String),
+				"settings": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"mode": cty.StringVal("strict"),
+					}),
+				}),
+			}),
+			Err: false,
+		},
+
+		"unknown configuration block": {
+			Config: cty.ObjectVal(map[string]cty.Value{
+				"port":     cty.NumberIntVal(80),
+				"protocol": cty.StringVal("tcp"),
+				"settings": cty.UnknownVal(settingsType),
+			}),
+			Err: false,
+		},
+
+		"unknown attribute and missing attribute": {
+			Config: cty.ObjectVal(map[string]cty.Value{
+				"port":     cty.NumberIntVal(80),
+				"protocol": cty.UnknownVal(cty.String),
+				"settings": cty.NullVal(settingsType),
+			}),
+			Err: true,
+		},
+
+		"missing attribute": {
+			Config: cty.ObjectVal(map[string]cty.Value{
+				"port":     cty.NumberIntVal(80),
+				"protocol": cty.NullVal(cty.String),
+				"settings": cty.ListVal([]cty.Value{
+					cty.ObjectVal(map[string]cty.Value{
+						"mode": cty.StringVal("strict"),
+					}),
+				}),
+			}),
+			Err: true,
+		},
+	}
+
+	for tn, tc := range cases {
+		t.Run(tn, func(t *testing.T) {
+			c := terraform.NewResourceConfigShimmed(tc.Config, block)
+			diags := sm.Validate(c)
+			es := diagutils.ErrorDiags(diags).Errors()
+			if len(es) > 0 != tc.Err {
+				if len(es) == 0 {
+					t.Fatalf("expected error")
+				}
+
+				for _, e := range es {
+					t.Fatalf("didn't expect error, got error: %+v", e)
+				}
+
+				t.FailNow()
+			}
+		})
+	}
+}
+
 func TestHasWriteOnly(t *testing.T) {
 	cases := map[string]struct {
 		Schema          map[string]*Schema
@@ -9466,6 +11170,28 @@ func TestHasWriteOnly(t *testing.T) {
 			},
 			expectWriteOnly: true,
 		},
+		"Nested block without WriteOnly and top-level WriteOnly set returns true": {
+			Schema: map[string]*Schema{
+				"block": {
+					Type:     TypeList,
+					Optional: true,
+					Elem: &Resource{
+						Schema: map[string]*Schema{
+							"nested_attr": {
+								Type:     TypeString,
+								Optional: true,
+							},
+						},
+					},
+				},
+				"top-level": {
+					Type:      TypeString,
+					Optional:  true,
+					WriteOnly: true,
+				},
+			},
+			expectWriteOnly: true,
+		},
 	}
 
 	for name, tc := range cases {
@@ -6,6 +6,7 @@ package schema
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 
 	"github.com/hashicorp/go-cty/cty"
 	ctyjson "github.com/hashicorp/go-cty/cty/json"
@@ -131,3 +132,31 @@ func JSONMapToStateValue(m map[string]interface{}, block *configschema.Block) (c
 func StateValueFromInstanceState(is *terraform.InstanceState, ty cty.Type) (cty.Value, error) {
 	return is.AttrsAsObjectValue(ty)
 }
+
+// HashicorpCtyToFlatmap converts a cty.Value of an object type to the legacy
+// flatmap representation used by terraform.InstanceState attributes, such as
+// the state passed to Resource.MigrateState. An error is returned if the
+// value is not of an object type.
+//
+// Null values are omitted, while unknown values are written as the legacy
+// unknown placeholder "74D93920-ED26-11E3-AC10-0800200C9A66".
+//
+// Set elements are keyed by their index in the set, such as "tags.0",
+// rather than by the hash code helper/schema uses when writing state, so the
+// resulting keys do not match a state written by a resource.
+func HashicorpCtyToFlatmap(v cty.Value) (map[string]string, error) {
+	if !v.Type().IsObjectType() {
+		return nil, fmt.Errorf("expected an object value, got %s", v.Type().FriendlyName())
+	}
+
+	return hcl2shim.FlatmapValueFromHCL2(v), nil
+}
+
+// FlatmapToCty converts the legacy flatmap representation of a resource
+// state to a cty.Value conforming to the given resource schema, including
+// the implicit "id" attribute.
+func FlatmapToCty(m map[string]string, schema map[string]*Schema) (cty.Value, error) {
+	ty := (&Resource{Schema: schema}).CoreConfigSchema().ImpliedType()
+
+	return hcl2shim.HCL2ValueFromFlatmap(m, ty)
+}
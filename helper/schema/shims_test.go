@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -3498,3 +3499,152 @@ func TestRemoveConfigUnknowns(t *testing.T) {
 		t.Fatalf("\nexpected: %#v\ngot:      %#v", expect, cfg)
 	}
 }
+
+func TestFlatmapToCty_roundTrip(t *testing.T) {
+	t.Parallel()
+
+	schema := map[string]*Schema{
+		"name": {
+			Type:     TypeString,
+			Required: true,
+		},
+		"tags": {
+			Type:     TypeSet,
+			Optional: true,
+			Elem:     &Schema{Type: TypeString},
+		},
+		"network": {
+			Type:     TypeList,
+			Optional: true,
+			Elem: &Resource{
+				Schema: map[string]*Schema{
+					"cidr": {
+						Type:     TypeString,
+						Optional: true,
+					},
+					"ports": {
+						Type:     TypeList,
+						Optional: true,
+						Elem:     &Schema{Type: TypeInt},
+					},
+				},
+			},
+		},
+	}
+
+	val := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.StringVal("test"),
+		"name": cty.StringVal("foo"),
+		"tags": cty.SetVal([]cty.Value{
+			cty.StringVal("a"),
+			cty.StringVal("b"),
+		}),
+		"network": cty.ListVal([]cty.Value{
+			cty.ObjectVal(map[string]cty.Value{
+				"cidr": cty.StringVal("10.0.0.0/16"),
+				"ports": cty.ListVal([]cty.Value{
+					cty.NumberIntVal(80),
+					cty.NumberIntVal(443),
+				}),
+			}),
+		}),
+	})
+
+	flatmap, err := HashicorpCtyToFlatmap(val)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expectedFlatmap := map[string]string{
+		"id":                "test",
+		"name":              "foo",
+		"tags.#":            "2",
+		"network.#":         "1",
+		"network.0.cidr":    "10.0.0.0/16",
+		"network.0.ports.#": "2",
+		"network.0.ports.0": "80",
+		"network.0.ports.1": "443",
+	}
+
+	// set element keys are not stable, so compare them separately
+	tags := map[string]bool{}
+	for k, v := range flatmap {
+		if strings.HasPrefix(k, "tags.") && k != "tags.#" {
+			tags[v] = true
+			delete(flatmap, k)
+		}
+	}
+
+	if diff := cmp.Diff(expectedFlatmap, flatmap); diff != "" {
+		t.Fatalf("unexpected flatmap difference: %s", diff)
+	}
+
+	if diff := cmp.Diff(map[string]bool{"a": true, "b": true}, tags); diff != "" {
+		t.Fatalf("unexpected set elements difference: %s", diff)
+	}
+
+	flatmap, err = HashicorpCtyToFlatmap(val)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	got, err := FlatmapToCty(flatmap, schema)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !got.RawEquals(val) {
+		t.Fatalf("expected %#v, got %#v", val, got)
+	}
+}
+
+func TestHashicorpCtyToFlatmap_unknown(t *testing.T) {
+	t.Parallel()
+
+	val := cty.ObjectVal(map[string]cty.Value{
+		"id":   cty.StringVal("test"),
+		"name": cty.UnknownVal(cty.String),
+		"tags": cty.UnknownVal(cty.List(cty.String)),
+		"note": cty.NullVal(cty.String),
+	})
+
+	got, err := HashicorpCtyToFlatmap(val)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := map[string]string{
+		"id":     "test",
+		"name":   hcl2shim.UnknownVariableValue,
+		"tags.#": hcl2shim.UnknownVariableValue,
+	}
+
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Fatalf("unexpected flatmap difference: %s", diff)
+	}
+}
+
+func TestHashicorpCtyToFlatmap_invalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := HashicorpCtyToFlatmap(cty.StringVal("foo"))
+	if err == nil {
+		t.Fatal("expected error, got none")
+	}
+}
+
+func TestFlatmapToCty_invalid(t *testing.T) {
+	t.Parallel()
+
+	schema := map[string]*Schema{
+		"count": {
+			Type:     TypeInt,
+			Optional: true,
+		},
+	}
+
+	_, err := FlatmapToCty(map[string]string{"count": "not-a-number"}, schema)
+	if err == nil {
+		t.Fatal("expected error, got none")
+	}
+}
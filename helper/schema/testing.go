@@ -5,7 +5,13 @@ package schema
 
 import (
 	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
 
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
 	testing "github.com/mitchellh/go-testing-interface"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
@@ -30,3 +36,225 @@ func TestResourceDataRaw(t testing.T, schema map[string]*Schema, raw map[string]
 
 	return result
 }
+
+// AssertNoDiagnostics fails the test if there are any diagnostics, such as
+// those of a GRPCProviderServer response.
+func AssertNoDiagnostics(t testing.T, diags []*tfprotov5.Diagnostic) {
+	t.Helper()
+
+	if len(diags) > 0 {
+		t.Fatalf("expected no diagnostics, got %d:\n%s", len(diags), formatDiagnostics(diags))
+	}
+}
+
+// AssertDiagnosticCount fails the test if the number of diagnostics is not n.
+func AssertDiagnosticCount(t testing.T, diags []*tfprotov5.Diagnostic, n int) {
+	t.Helper()
+
+	if len(diags) != n {
+		t.Fatalf("expected %d diagnostics, got %d:\n%s", n, len(diags), formatDiagnostics(diags))
+	}
+}
+
+// AssertDiagnosticContains fails the test if none of the diagnostics has the
+// given severity and summary.
+func AssertDiagnosticContains(t testing.T, diags []*tfprotov5.Diagnostic, severity tfprotov5.DiagnosticSeverity, summary string) {
+	t.Helper()
+
+	for _, d := range diags {
+		if d != nil && d.Severity == severity && d.Summary == summary {
+			return
+		}
+	}
+
+	t.Fatalf("expected %s diagnostic with summary %q, got %d:\n%s", severity, summary, len(diags), formatDiagnostics(diags))
+}
+
+// formatDiagnostics returns the diagnostics as indented lines of severity,
+// summary and detail for test failure messages.
+func formatDiagnostics(diags []*tfprotov5.Diagnostic) string {
+	lines := make([]string, 0, len(diags))
+
+	for _, d := range diags {
+		if d == nil {
+			lines = append(lines, "\t<nil>")
+			continue
+		}
+
+		line := fmt.Sprintf("\t%s: %s", d.Severity, d.Summary)
+		if d.Detail != "" {
+			line += ": " + d.Detail
+		}
+
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// AssertStableRead calls the read path of the given resource the given number
+// of runs, starting each run from the state described by config, and fails the
+// test if the resulting state differs between runs. The states are compared
+// with StatesEqual, so functions registered with RegisterSemanticEqual for
+// typeName are respected. The config value must conform to the resource
+// schema and include a non-empty "id" attribute, and runs must be at least 2.
+//
+// This is intended to catch non-deterministic reads, such as unsorted lists
+// returned by a remote API, which cause perpetual differences in plans.
+func AssertStableRead(t testing.T, typeName string, r *Resource, config cty.Value, meta interface{}, runs int) {
+	t.Helper()
+
+	if runs < 2 {
+		t.Fatalf("runs must be at least 2 to compare reads, got %d", runs)
+	}
+
+	initial, err := r.ShimInstanceStateFromValue(config)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+
+	if initial.ID == "" {
+		t.Fatalf("config must include a non-empty id")
+	}
+
+	ty := r.CoreConfigSchema().ImpliedType()
+
+	var first *terraform.InstanceState
+	var firstVal cty.Value
+
+	for i := 0; i < runs; i++ {
+		state, diags := r.RefreshWithoutUpgrade(context.Background(), initial.DeepCopy(), meta)
+		if diags.HasError() {
+			t.Fatalf("read %d returned errors: %v", i+1, diags)
+		}
+
+		if state == nil {
+			t.Fatalf("read %d removed the resource from state", i+1)
+		}
+
+		val, err := StateValueFromInstanceState(state, ty)
+		if err != nil {
+			t.Fatalf("read %d returned an invalid state: %s", i+1, err)
+		}
+
+		if first == nil {
+			first = state
+			firstVal = val
+			continue
+		}
+
+		if StatesEqual(typeName, firstVal, val) {
+			continue
+		}
+
+		// Only report the attributes which StatesEqual considers unequal.
+		var unstable []string
+		for _, k := range unstableAttributes(first, state) {
+			attr := strings.SplitN(k, ".", 2)[0]
+
+			if ty.HasAttribute(attr) && attributeEqual(typeName, attr, firstVal.GetAttr(attr), val.GetAttr(attr)) {
+				continue
+			}
+
+			unstable = append(unstable, k)
+		}
+
+		t.Fatalf("read %d returned a different state than read 1, unstable attributes: %s",
+			i+1, strings.Join(unstable, ", "))
+	}
+}
+
+// unstableAttributes returns the sorted flatmap keys whose values differ
+// between the two given states.
+func unstableAttributes(a, b *terraform.InstanceState) []string {
+	unstable := make(map[string]struct{})
+
+	if a.ID != b.ID {
+		unstable["id"] = struct{}{}
+	}
+
+	for k, v := range a.Attributes {
+		if other, ok := b.Attributes[k]; !ok || v != other {
+			unstable[k] = struct{}{}
+		}
+	}
+
+	for k := range b.Attributes {
+		if _, ok := a.Attributes[k]; !ok {
+			unstable[k] = struct{}{}
+		}
+	}
+
+	keys := make([]string, 0, len(unstable))
+	for k := range unstable {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// SemanticEqualFunc reports whether two values of an attribute are
+// semantically equal, such as two JSON strings which only differ in
+// whitespace or key order.
+type SemanticEqualFunc func(a, b cty.Value) bool
+
+var (
+	semanticEqualFuncsMu sync.RWMutex
+	semanticEqualFuncs   = make(map[string]map[string]SemanticEqualFunc)
+)
+
+// RegisterSemanticEqual registers a semantic equality function for the given
+// top level attribute of the given resource type, which StatesEqual consults
+// instead of exact comparison. Registering another function for the same
+// attribute replaces the previous one.
+//
+// This is only intended for test helpers and has no effect on planning or
+// applying changes. Use DiffSuppressFunc to suppress differences there.
+func RegisterSemanticEqual(typeName, attr string, fn SemanticEqualFunc) {
+	semanticEqualFuncsMu.Lock()
+	defer semanticEqualFuncsMu.Unlock()
+
+	if semanticEqualFuncs[typeName] == nil {
+		semanticEqualFuncs[typeName] = make(map[string]SemanticEqualFunc)
+	}
+
+	semanticEqualFuncs[typeName][attr] = fn
+}
+
+// StatesEqual reports whether two state values of the given resource type are
+// equal. Top level attributes with a function registered by
+// RegisterSemanticEqual are compared with that function when both values are
+// known and not null, while all other values must be exactly equal.
+func StatesEqual(typeName string, a, b cty.Value) bool {
+	if !a.Type().Equals(b.Type()) {
+		return false
+	}
+
+	if !a.Type().IsObjectType() || a.IsNull() || b.IsNull() || !a.IsKnown() || !b.IsKnown() {
+		return a.RawEquals(b)
+	}
+
+	for attr := range a.Type().AttributeTypes() {
+		if !attributeEqual(typeName, attr, a.GetAttr(attr), b.GetAttr(attr)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// attributeEqual reports whether two values of the given top level attribute
+// of the given resource type are equal, as described by StatesEqual.
+func attributeEqual(typeName, attr string, a, b cty.Value) bool {
+	semanticEqualFuncsMu.RLock()
+	fn, ok := semanticEqualFuncs[typeName][attr]
+	semanticEqualFuncsMu.RUnlock()
+
+	if ok && !a.IsNull() && !b.IsNull() && a.IsWhollyKnown() && b.IsWhollyKnown() {
+		return fn(a, b)
+	}
+
+	return a.RawEquals(b)
+}
@@ -0,0 +1,358 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
+	testinginterface "github.com/mitchellh/go-testing-interface"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+)
+
+func TestAssertStableRead(t *testing.T) {
+	t.Parallel()
+
+	RegisterSemanticEqual("test_assert_stable_read_semantic", "json", func(a, b cty.Value) bool {
+		var av, bv interface{}
+
+		if err := json.Unmarshal([]byte(a.AsString()), &av); err != nil {
+			return false
+		}
+
+		if err := json.Unmarshal([]byte(b.AsString()), &bv); err != nil {
+			return false
+		}
+
+		return reflect.DeepEqual(av, bv)
+	})
+
+	// alternating returns a ReadContextFunc which sets the given attribute to
+	// the first value on odd runs and the second value on even runs.
+	alternating := func(attr string, first, second interface{}) ReadContextFunc {
+		var runs int
+
+		return func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+			runs++
+
+			if runs%2 == 0 {
+				return diag.FromErr(d.Set(attr, second))
+			}
+
+			return diag.FromErr(d.Set(attr, first))
+		}
+	}
+
+	testCases := map[string]struct {
+		typeName    string
+		read        ReadContextFunc
+		runs        int
+		expectFatal bool
+	}{
+		"stable": {
+			typeName: "test_assert_stable_read",
+			read: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+				return diag.FromErr(d.Set("list", []interface{}{"a", "b"}))
+			},
+			runs: 3,
+		},
+		"unstable": {
+			typeName:    "test_assert_stable_read",
+			read:        alternating("list", []interface{}{"a", "b"}, []interface{}{"b", "a"}),
+			runs:        3,
+			expectFatal: true,
+		},
+		"semantically equal": {
+			typeName: "test_assert_stable_read_semantic",
+			read:     alternating("json", `{"a":1,"b":2}`, `{"b": 2, "a": 1}`),
+			runs:     3,
+		},
+		"semantically equal without registered func": {
+			typeName:    "test_assert_stable_read",
+			read:        alternating("json", `{"a":1,"b":2}`, `{"b": 2, "a": 1}`),
+			runs:        3,
+			expectFatal: true,
+		},
+		"single run": {
+			typeName: "test_assert_stable_read",
+			read: func(ctx context.Context, d *ResourceData, meta interface{}) diag.Diagnostics {
+				return nil
+			},
+			runs:        1,
+			expectFatal: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r := &Resource{
+				ReadContext: testCase.read,
+				Schema: map[string]*Schema{
+					"list": {
+						Type:     TypeList,
+						Computed: true,
+						Elem:     &Schema{Type: TypeString},
+					},
+					"json": {
+						Type:     TypeString,
+						Computed: true,
+					},
+				},
+			}
+
+			config := cty.ObjectVal(map[string]cty.Value{
+				"id":   cty.StringVal("test"),
+				"list": cty.NullVal(cty.List(cty.String)),
+				"json": cty.NullVal(cty.String),
+			})
+
+			var fatal interface{}
+
+			func() {
+				defer func() {
+					fatal = recover()
+				}()
+
+				AssertStableRead(&testinginterface.RuntimeT{}, testCase.typeName, r, config, nil, testCase.runs)
+			}()
+
+			if testCase.expectFatal && fatal == nil {
+				t.Fatal("expected failure, got none")
+			}
+
+			if !testCase.expectFatal && fatal != nil {
+				t.Fatalf("unexpected failure: %v", fatal)
+			}
+		})
+	}
+}
+
+func TestAssertDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	diags := []*tfprotov5.Diagnostic{
+		{
+			Severity: tfprotov5.DiagnosticSeverityWarning,
+			Summary:  "warning summary",
+		},
+		{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  "error summary",
+			Detail:   "error detail",
+		},
+	}
+
+	testCases := map[string]struct {
+		assert      func(testinginterface.T)
+		expectFatal bool
+	}{
+		"no diagnostics": {
+			assert: func(t testinginterface.T) {
+				AssertNoDiagnostics(t, nil)
+			},
+		},
+		"no diagnostics failure": {
+			assert: func(t testinginterface.T) {
+				AssertNoDiagnostics(t, diags)
+			},
+			expectFatal: true,
+		},
+		"count": {
+			assert: func(t testinginterface.T) {
+				AssertDiagnosticCount(t, diags, 2)
+			},
+		},
+		"count failure": {
+			assert: func(t testinginterface.T) {
+				AssertDiagnosticCount(t, diags, 1)
+			},
+			expectFatal: true,
+		},
+		"contains": {
+			assert: func(t testinginterface.T) {
+				AssertDiagnosticContains(t, diags, tfprotov5.DiagnosticSeverityError, "error summary")
+			},
+		},
+		"contains severity mismatch": {
+			assert: func(t testinginterface.T) {
+				AssertDiagnosticContains(t, diags, tfprotov5.DiagnosticSeverityError, "warning summary")
+			},
+			expectFatal: true,
+		},
+		"contains summary mismatch": {
+			assert: func(t testinginterface.T) {
+				AssertDiagnosticContains(t, diags, tfprotov5.DiagnosticSeverityError, "other summary")
+			},
+			expectFatal: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var fatal interface{}
+
+			func() {
+				defer func() {
+					fatal = recover()
+				}()
+
+				testCase.assert(&testinginterface.RuntimeT{})
+			}()
+
+			if testCase.expectFatal && fatal == nil {
+				t.Fatal("expected failure, got none")
+			}
+
+			if !testCase.expectFatal && fatal != nil {
+				t.Fatalf("unexpected failure: %v", fatal)
+			}
+		})
+	}
+}
+
+func TestFormatDiagnostics(t *testing.T) {
+	t.Parallel()
+
+	diags := []*tfprotov5.Diagnostic{
+		{
+			Severity: tfprotov5.DiagnosticSeverityWarning,
+			Summary:  "warning summary",
+		},
+		{
+			Severity: tfprotov5.DiagnosticSeverityError,
+			Summary:  "error summary",
+			Detail:   "error detail",
+		},
+	}
+
+	expected := "\tWARNING: warning summary\n\tERROR: error summary: error detail"
+
+	if diff := cmp.Diff(formatDiagnostics(diags), expected); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestUnstableAttributes(t *testing.T) {
+	t.Parallel()
+
+	a := &terraform.InstanceState{
+		ID: "test",
+		Attributes: map[string]string{
+			"id":     "test",
+			"stable": "foo",
+			"list.#": "2",
+			"list.0": "a",
+			"list.1": "b",
+			"old":    "bar",
+		},
+	}
+	b := &terraform.InstanceState{
+		ID: "test",
+		Attributes: map[string]string{
+			"id":     "test",
+			"stable": "foo",
+			"list.#": "2",
+			"list.0": "b",
+			"list.1": "a",
+			"new":    "baz",
+		},
+	}
+
+	expected := []string{"list.0", "list.1", "new", "old"}
+
+	if diff := cmp.Diff(unstableAttributes(a, b), expected); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestStatesEqual(t *testing.T) {
+	t.Parallel()
+
+	RegisterSemanticEqual("test_states_equal", "policy", func(a, b cty.Value) bool {
+		var av, bv interface{}
+
+		if err := json.Unmarshal([]byte(a.AsString()), &av); err != nil {
+			return false
+		}
+
+		if err := json.Unmarshal([]byte(b.AsString()), &bv); err != nil {
+			return false
+		}
+
+		return reflect.DeepEqual(av, bv)
+	})
+
+	state := func(policy, name cty.Value) cty.Value {
+		return cty.ObjectVal(map[string]cty.Value{
+			"id":     cty.StringVal("test"),
+			"name":   name,
+			"policy": policy,
+		})
+	}
+
+	testCases := map[string]struct {
+		typeName string
+		a        cty.Value
+		b        cty.Value
+		expected bool
+	}{
+		"equal": {
+			typeName: "test_states_equal",
+			a:        state(cty.StringVal(`{"a":1}`), cty.StringVal("foo")),
+			b:        state(cty.StringVal(`{"a":1}`), cty.StringVal("foo")),
+			expected: true,
+		},
+		"semantically equal": {
+			typeName: "test_states_equal",
+			a:        state(cty.StringVal(`{"a":1,"b":2}`), cty.StringVal("foo")),
+			b:        state(cty.StringVal(`{ "b": 2, "a": 1 }`), cty.StringVal("foo")),
+			expected: true,
+		},
+		"semantically different": {
+			typeName: "test_states_equal",
+			a:        state(cty.StringVal(`{"a":1}`), cty.StringVal("foo")),
+			b:        state(cty.StringVal(`{"a":2}`), cty.StringVal("foo")),
+			expected: false,
+		},
+		"other attribute different": {
+			typeName: "test_states_equal",
+			a:        state(cty.StringVal(`{"a":1}`), cty.StringVal("foo")),
+			b:        state(cty.StringVal(`{ "a": 1 }`), cty.StringVal("bar")),
+			expected: false,
+		},
+		"null value": {
+			typeName: "test_states_equal",
+			a:        state(cty.StringVal(`{"a":1}`), cty.StringVal("foo")),
+			b:        state(cty.NullVal(cty.String), cty.StringVal("foo")),
+			expected: false,
+		},
+		"unregistered type": {
+			typeName: "test_states_equal_unregistered",
+			a:        state(cty.StringVal(`{"a":1,"b":2}`), cty.StringVal("foo")),
+			b:        state(cty.StringVal(`{ "b": 2, "a": 1 }`), cty.StringVal("foo")),
+			expected: false,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if actual := StatesEqual(testCase.typeName, testCase.a, testCase.b); actual != testCase.expected {
+				t.Errorf("expected %t, got %t", testCase.expected, actual)
+			}
+		})
+	}
+}
@@ -212,3 +212,36 @@ func validateWriteOnlyNullValues(val cty.Value, schema *configschema.Block, path
 
 	return diags
 }
+
+// validateWriteOnlyStateNullValues returns an error diagnostic for each
+// non-null write-only attribute value in a new resource state, for resources
+// which enable ErrorOnWriteOnlyInState.
+func validateWriteOnlyStateNullValues(val cty.Value, schema *configschema.Block) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for _, d := range validateWriteOnlyNullValues(val, schema, cty.Path{}) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Error,
+			Summary:  "Write-only Attribute Set in State",
+			Detail: fmt.Sprintf("The resource state contains a non-null value for write-only attribute %q. ", writeOnlyAttributeName(d.AttributePath)) +
+				"Write-only values are never persisted. This is always an issue in the provider and should be reported to the provider developers.",
+			AttributePath: d.AttributePath,
+		})
+	}
+
+	return diags
+}
+
+// writeOnlyAttributeName returns the name of the attribute a
+// validateWriteOnlyNullValues diagnostic path points to.
+func writeOnlyAttributeName(path cty.Path) string {
+	if len(path) == 0 {
+		return ""
+	}
+
+	if step, ok := path[len(path)-1].(cty.GetAttrStep); ok {
+		return step.Name
+	}
+
+	return ""
+}
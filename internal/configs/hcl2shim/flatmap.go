@@ -277,16 +277,26 @@ func hcl2ValueFromFlatmapMap(m map[string]string, prefix string, ty cty.Type) (c
 
 		// The flatmap format doesn't allow us to distinguish between keys
 		// that contain periods and nested objects, so by convention a
-		// map is only ever of primitive type in flatmap, and we just assume
-		// that the remainder of the raw key (dots and all) is the key we
-		// want in the result value.
+		// map of primitive type assumes that the remainder of the raw key
+		// (dots and all) is the key we want in the result value, while a
+		// map of any other type assumes that keys do not contain periods.
 		key := fullKey[len(prefix):]
 		if key == "%" {
 			// Ignore the "count" key
 			continue
 		}
 
-		val, err := hcl2ValueFromFlatmapValue(m, fullKey, ety)
+		if !ety.IsPrimitiveType() {
+			if i := strings.Index(key, "."); i >= 0 {
+				key = key[:i]
+			}
+
+			if _, exists := vals[key]; exists {
+				continue
+			}
+		}
+
+		val, err := hcl2ValueFromFlatmapValue(m, prefix+key, ety)
 		if err != nil {
 			return cty.DynamicVal, err
 		}
@@ -15,9 +15,20 @@ const (
 	// as parent.0.child in this project.
 	KeyAttributePath = "tf_attribute_path"
 
+	// Prior value of an attribute when logging a planned change, in
+	// flatmap form.
+	KeyAttributeOldValue = "tf_attribute_old_value"
+
+	// Planned value of an attribute when logging a planned change, in
+	// flatmap form.
+	KeyAttributeNewValue = "tf_attribute_new_value"
+
 	// The type of data source being operated on, such as "archive_file"
 	KeyDataSourceType = "tf_data_source_type"
 
+	// The type of ephemeral resource being operated on, such as "random_password"
+	KeyEphemeralResourceType = "tf_ephemeral_resource_type"
+
 	// Underlying Go error string when logging an error.
 	KeyError = "error"
 
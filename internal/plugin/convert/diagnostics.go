@@ -7,6 +7,7 @@ import (
 	"context"
 
 	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/go-cty/cty/msgpack"
 
 	"github.com/hashicorp/terraform-plugin-go/tfprotov5"
 	"github.com/hashicorp/terraform-plugin-go/tftypes"
@@ -155,11 +156,31 @@ func PathToAttributePath(p cty.Path) *tftypes.AttributePath {
 				v, _ := key.AsBigFloat().Int64()
 				ap = ap.WithElementKeyInt(int(v))
 			default:
-				// We'll bail early if we encounter anything else, and just
-				// return the valid prefix.
-				return ap
+				// Set elements are addressed by their value. We'll bail
+				// early if the value cannot be converted, and just return
+				// the valid prefix.
+				val, err := tfValueFromCtyValue(key)
+				if err != nil {
+					return ap
+				}
+				ap = ap.WithElementKeyValue(val)
 			}
 		}
 	}
 	return ap
 }
+
+// tfValueFromCtyValue converts a cty.Value into the equivalent tftypes.Value.
+func tfValueFromCtyValue(val cty.Value) (tftypes.Value, error) {
+	tfType, err := TFTypeFromCtyType(val.Type())
+	if err != nil {
+		return tftypes.Value{}, err
+	}
+
+	mp, err := msgpack.Marshal(val, val.Type())
+	if err != nil {
+		return tftypes.Value{}, err
+	}
+
+	return (&tfprotov5.DynamicValue{MsgPack: mp}).Unmarshal(tfType)
+}
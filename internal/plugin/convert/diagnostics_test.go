@@ -4,6 +4,8 @@
 package convert
 
 import (
+	"context"
+	"errors"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -283,6 +285,26 @@ func TestPathToAttributePath(t *testing.T) {
 			path: cty.Path{},
 			want: nil,
 		},
+		"list index": {
+			path: cty.GetAttrPath("list").IndexInt(1).GetAttr("name"),
+			want: tftypes.NewAttributePath().WithAttributeName("list").WithElementKeyInt(1).WithAttributeName("name"),
+		},
+		"map key": {
+			path: cty.GetAttrPath("map").IndexString("key"),
+			want: tftypes.NewAttributePath().WithAttributeName("map").WithElementKeyString("key"),
+		},
+		"set element": {
+			path: cty.GetAttrPath("set").Index(cty.ObjectVal(map[string]cty.Value{
+				"name": cty.StringVal("test"),
+			})).GetAttr("name"),
+			want: tftypes.NewAttributePath().
+				WithAttributeName("set").
+				WithElementKeyValue(tftypes.NewValue(
+					tftypes.Object{AttributeTypes: map[string]tftypes.Type{"name": tftypes.String}},
+					map[string]tftypes.Value{"name": tftypes.NewValue(tftypes.String, "test")},
+				)).
+				WithAttributeName("name"),
+		},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -293,3 +315,108 @@ func TestPathToAttributePath(t *testing.T) {
 		})
 	}
 }
+
+func TestDiagnosticsWithPathRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		diags diag.Diagnostics
+		want  []*tfprotov5.Diagnostic
+	}{
+		"FromErrWithPath nil error": {
+			diags: diag.FromErrWithPath(cty.GetAttrPath("attr"), nil),
+			want:  nil,
+		},
+		"FromErrWithPath attribute": {
+			diags: diag.FromErrWithPath(cty.GetAttrPath("attr"), errors.New("error")),
+			want: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "error",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("attr"),
+				},
+			},
+		},
+		"FromErrWithPath list element attribute": {
+			diags: diag.FromErrWithPath(cty.GetAttrPath("list").IndexInt(1).GetAttr("attr"), errors.New("error")),
+			want: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "error",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("list").WithElementKeyInt(1).WithAttributeName("attr"),
+				},
+			},
+		},
+		"FromErrPath nil error": {
+			diags: diag.FromErrPath(nil, cty.GetAttrPath("attr")),
+			want:  nil,
+		},
+		"FromErrPath nested block attribute": {
+			diags: diag.FromErrPath(errors.New("error"), cty.GetAttrPath("block").IndexInt(0).GetAttr("nested").IndexString("key").GetAttr("attr")),
+			want: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "error",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("block").WithElementKeyInt(0).WithAttributeName("nested").WithElementKeyString("key").WithAttributeName("attr"),
+				},
+			},
+		},
+		"WithPath map element": {
+			diags: diag.Diagnostics{
+				diag.WithPath(cty.GetAttrPath("map").IndexString("key"), diag.Diagnostic{
+					Severity: diag.Warning,
+					Summary:  "warning",
+					Detail:   "detail",
+				}),
+			},
+			want: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityWarning,
+					Summary:   "warning",
+					Detail:    "detail",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("map").WithElementKeyString("key"),
+				},
+			},
+		},
+		"WithPath replaces existing path": {
+			diags: diag.Diagnostics{
+				diag.WithPath(cty.GetAttrPath("new"), diag.Diagnostic{
+					Severity:      diag.Error,
+					Summary:       "error",
+					AttributePath: cty.GetAttrPath("old"),
+				}),
+			},
+			want: []*tfprotov5.Diagnostic{
+				{
+					Severity:  tfprotov5.DiagnosticSeverityError,
+					Summary:   "error",
+					Attribute: tftypes.NewAttributePath().WithAttributeName("new"),
+				},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := AppendProtoDiag(context.Background(), nil, tc.diags)
+
+			if diff := cmp.Diff(got, tc.want); diff != "" {
+				t.Fatalf("unexpected protocol diagnostics difference: %s", diff)
+			}
+
+			roundTrip := ProtoToDiags(got)
+
+			if len(roundTrip) != len(tc.diags) {
+				t.Fatalf("expected %d diagnostics, got %d", len(tc.diags), len(roundTrip))
+			}
+
+			for i := range roundTrip {
+				if !roundTrip[i].AttributePath.Equals(tc.diags[i].AttributePath) {
+					t.Errorf("expected path %#v, got %#v", tc.diags[i].AttributePath, roundTrip[i].AttributePath)
+				}
+			}
+		})
+	}
+}
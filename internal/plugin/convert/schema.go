@@ -17,6 +17,12 @@ import (
 	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/logging"
 )
 
+// TFTypeFromCtyType converts a cty.Type to the equivalent tftypes.Type, such
+// as for the parameter and return types of provider-defined functions.
+func TFTypeFromCtyType(in cty.Type) (tftypes.Type, error) {
+	return tftypeFromCtyType(in)
+}
+
 func tftypeFromCtyType(in cty.Type) (tftypes.Type, error) {
 	switch {
 	case in.Equals(cty.String):
@@ -187,6 +187,72 @@ func TestConvertSchemaBlocks(t *testing.T) {
 				},
 			},
 		},
+		"sensitive nested attributes": {
+			&tfprotov5.SchemaBlock{
+				BlockTypes: []*tfprotov5.SchemaNestedBlock{
+					{
+						TypeName: "list",
+						Nesting:  tfprotov5.SchemaNestedBlockNestingModeList,
+						Block: &tfprotov5.SchemaBlock{
+							Attributes: []*tfprotov5.SchemaAttribute{
+								{
+									Name:      "password",
+									Type:      tftypes.String,
+									Optional:  true,
+									Sensitive: true,
+								},
+							},
+							BlockTypes: []*tfprotov5.SchemaNestedBlock{
+								{
+									TypeName: "set",
+									Nesting:  tfprotov5.SchemaNestedBlockNestingModeSet,
+									Block: &tfprotov5.SchemaBlock{
+										Attributes: []*tfprotov5.SchemaAttribute{
+											{
+												Name:      "token",
+												Type:      tftypes.String,
+												Computed:  true,
+												Sensitive: true,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			&configschema.Block{
+				BlockTypes: map[string]*configschema.NestedBlock{
+					"list": {
+						Nesting: configschema.NestingList,
+						Block: configschema.Block{
+							Attributes: map[string]*configschema.Attribute{
+								"password": {
+									Type:      cty.String,
+									Optional:  true,
+									Sensitive: true,
+								},
+							},
+							BlockTypes: map[string]*configschema.NestedBlock{
+								"set": {
+									Nesting: configschema.NestingSet,
+									Block: configschema.Block{
+										Attributes: map[string]*configschema.Attribute{
+											"token": {
+												Type:      cty.String,
+												Computed:  true,
+												Sensitive: true,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for name, tc := range tests {
@@ -374,6 +440,127 @@ func TestConvertProtoSchemaBlocks(t *testing.T) {
 				},
 			},
 		},
+		"sensitive nested attributes": {
+			&tfprotov5.SchemaBlock{
+				BlockTypes: []*tfprotov5.SchemaNestedBlock{
+					{
+						TypeName: "list",
+						Nesting:  tfprotov5.SchemaNestedBlockNestingModeList,
+						Block: &tfprotov5.SchemaBlock{
+							Attributes: []*tfprotov5.SchemaAttribute{
+								{
+									Name:      "password",
+									Type:      tftypes.String,
+									Optional:  true,
+									Sensitive: true,
+								},
+							},
+							BlockTypes: []*tfprotov5.SchemaNestedBlock{
+								{
+									TypeName: "set",
+									Nesting:  tfprotov5.SchemaNestedBlockNestingModeSet,
+									Block: &tfprotov5.SchemaBlock{
+										Attributes: []*tfprotov5.SchemaAttribute{
+											{
+												Name:      "token",
+												Type:      tftypes.String,
+												Computed:  true,
+												Sensitive: true,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+			&configschema.Block{
+				BlockTypes: map[string]*configschema.NestedBlock{
+					"list": {
+						Nesting: configschema.NestingList,
+						Block: configschema.Block{
+							Attributes: map[string]*configschema.Attribute{
+								"password": {
+									Type:      cty.String,
+									Optional:  true,
+									Sensitive: true,
+								},
+							},
+							BlockTypes: map[string]*configschema.NestedBlock{
+								"set": {
+									Nesting: configschema.NestingSet,
+									Block: configschema.Block{
+										Attributes: map[string]*configschema.Attribute{
+											"token": {
+												Type:      cty.String,
+												Computed:  true,
+												Sensitive: true,
+											},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		"descriptions": {
+			&tfprotov5.SchemaBlock{
+				Attributes: []*tfprotov5.SchemaAttribute{
+					{
+						Name:            "markdown",
+						Type:            tftypes.String,
+						Required:        true,
+						Description:     "**markdown** description",
+						DescriptionKind: tfprotov5.StringKindMarkdown,
+					},
+					{
+						Name:            "plain",
+						Type:            tftypes.String,
+						Optional:        true,
+						Description:     "plain description",
+						DescriptionKind: tfprotov5.StringKindPlain,
+					},
+				},
+				BlockTypes: []*tfprotov5.SchemaNestedBlock{
+					{
+						TypeName: "block",
+						Nesting:  tfprotov5.SchemaNestedBlockNestingModeList,
+						Block: &tfprotov5.SchemaBlock{
+							Description:     "**markdown** block description",
+							DescriptionKind: tfprotov5.StringKindMarkdown,
+						},
+					},
+				},
+			},
+			&configschema.Block{
+				Attributes: map[string]*configschema.Attribute{
+					"markdown": {
+						Type:            cty.String,
+						Required:        true,
+						Description:     "**markdown** description",
+						DescriptionKind: configschema.StringMarkdown,
+					},
+					"plain": {
+						Type:            cty.String,
+						Optional:        true,
+						Description:     "plain description",
+						DescriptionKind: configschema.StringPlain,
+					},
+				},
+				BlockTypes: map[string]*configschema.NestedBlock{
+					"block": {
+						Nesting: configschema.NestingList,
+						Block: configschema.Block{
+							Description:     "**markdown** block description",
+							DescriptionKind: configschema.StringMarkdown,
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for name, tc := range tests {
@@ -14,6 +14,7 @@ import (
 	"sync"
 
 	"github.com/hashicorp/go-cty/cty"
+	"github.com/hashicorp/terraform-plugin-go/tftypes"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/configs/configschema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/internal/configs/hcl2shim"
@@ -55,6 +56,11 @@ type InstanceDiff struct {
 	// Identity is the identity data used to track resource identity
 	// starting in Terraform 1.12+
 	Identity map[string]string
+
+	// RequiresReplace contains any additional paths that were flagged as
+	// requiring replacement by ResourceDiff.SetRequiresReplace during
+	// CustomizeDiff.
+	RequiresReplace []*tftypes.AttributePath
 }
 
 func (d *InstanceDiff) Lock()   { d.mu.Lock() }